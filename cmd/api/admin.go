@@ -0,0 +1,120 @@
+// File: cmd/api/admin.go
+// Description: the operational listener for metrics, profiling and health checks
+
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// adminRoutes serves the operational endpoints on the -admin-port listener. That listener binds to
+// -admin-addr, loopback by default, and nothing here asks for a token: monitoring keeps working while
+// the database is down, and the public port never exposes profiling. Nothing served here echoes the
+// command line, which carries secrets passed as flags. A ServeMux is used because
+// httprouter cannot mix the named pprof handlers with the catch-all for the other profiles.
+func (app *app) adminRoutes() http.Handler {
+	mux := http.NewServeMux()
+
+	// Health and Build Info
+	mux.HandleFunc("GET /v1/healthcheck", app.healthcheckHandler)
+	mux.HandleFunc("GET /v1/version", app.versionHandler)
+
+	// Metrics, the same as on the public port without the metrics:view check
+	mux.HandleFunc("GET /v1/metrics", app.expvarHandler)
+	mux.HandleFunc("GET /v1/metrics/prometheus", app.prometheusMetricsHandler)
+
+	// The running configuration with secrets redacted
+	mux.HandleFunc("GET /v1/config", app.configHandler)
+
+	// Profiling, such as /debug/pprof/heap and /debug/pprof/profile?seconds=30. The index treats
+	// cmdline as an unknown profile, so the command line is not served.
+	mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+
+	return app.recoverPanic(mux)
+}
+
+// configHandler shows the settings the server is running with. Credentials are only reported as
+// set or not, so the view can be pasted into a ticket.
+func (app *app) configHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := app.config
+
+	env := envelope{
+		"env":                 cfg.env,
+		"port":                cfg.port,
+		"admin_addr":          cfg.adminAddr,
+		"admin_port":          cfg.adminPort,
+		"base_currency":       cfg.baseCurrency,
+		"request_timeout_max": cfg.requestTimeoutMax.String(),
+		"reauth_window":       cfg.reauthWindow.String(),
+		"db": envelope{
+			"dsn":             redacted(cfg.db.dsn),
+			"max_open_conns":  cfg.db.maxOpenConns,
+			"max_idle_conns":  cfg.db.maxIdleConns,
+			"max_idle_time":   cfg.db.maxIdleTime.String(),
+			"health_interval": cfg.db.healthInterval.String(),
+			"health_timeout":  cfg.db.healthTimeout.String(),
+			"timeouts": envelope{
+				"read":   cfg.db.timeouts.Read.String(),
+				"write":  cfg.db.timeouts.Write.String(),
+				"report": cfg.db.timeouts.Report.String(),
+				"export": cfg.db.timeouts.Export.String(),
+			},
+		},
+		"cors": envelope{
+			"trusted_origins":   cfg.cors.trustedOrigins,
+			"allow_credentials": cfg.cors.allowCredentials,
+		},
+		"limiter": envelope{
+			"enabled":         cfg.limiter.enabled,
+			"rps":             cfg.limiter.rps,
+			"burst":           cfg.limiter.burst,
+			"login_enabled":   cfg.limiter.loginEnabled,
+			"exempt_paths":    cfg.limiter.exemptPaths,
+			"exempt_networks": cfg.limiter.exemptNetworks,
+			"exempt_keys":     len(cfg.limiter.exemptKeys),
+		},
+		"smtp": envelope{
+			"host":           cfg.smtp.host,
+			"port":           cfg.smtp.port,
+			"username":       cfg.smtp.username,
+			"password":       redacted(cfg.smtp.password),
+			"sender":         cfg.smtp.sender,
+			"webhook_secret": redacted(cfg.smtp.webhookSecret),
+		},
+		"sms": envelope{
+			"account_sid": cfg.sms.accountSID,
+			"auth_token":  redacted(cfg.sms.authToken),
+			"from":        cfg.sms.from,
+		},
+		"totp": envelope{
+			"encryption_key": redacted(string(cfg.totp.encryptionKey)),
+			"issuer":         cfg.totp.issuer,
+		},
+		"github_token": redacted(cfg.github.token),
+		"token_ttl": envelope{
+			"activation":     cfg.tokens.activationTTL.String(),
+			"authentication": cfg.tokens.authenticationTTL.String(),
+		},
+		"scheduler_interval": cfg.scheduler.interval.String(),
+		"low_stock_interval": cfg.lowStock.interval.String(),
+		"storage_dir":        cfg.storage.dir,
+		"chaos_enabled":      cfg.chaos.enabled,
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"config": env}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// redacted hides a secret, only telling whether it is set.
+func redacted(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "[redacted]"
+}
@@ -0,0 +1,70 @@
+// File: cmd/api/admin_test.go
+// Description: test suite for the operational listener's routes
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestAdminRoutes tests that the admin listener serves metrics and profiles without a token and
+// none of the public API
+func TestAdminRoutes(t *testing.T) {
+	app := newTestApp()
+	handler := app.adminRoutes()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		expected int
+	}{
+		{"Healthcheck", http.MethodGet, "/v1/healthcheck", http.StatusOK},
+		{"Metrics", http.MethodGet, "/v1/metrics", http.StatusOK},
+		{"Prometheus Metrics", http.MethodGet, "/v1/metrics/prometheus", http.StatusOK},
+		{"Config", http.MethodGet, "/v1/config", http.StatusOK},
+		{"Profile Index", http.MethodGet, "/debug/pprof/", http.StatusOK},
+		{"Command Line", http.MethodGet, "/debug/pprof/cmdline", http.StatusNotFound},
+		{"Named Profile", http.MethodGet, "/debug/pprof/goroutine?debug=1", http.StatusOK},
+		{"Public API", http.MethodGet, "/v1/products", http.StatusNotFound},
+		{"Wrong Method", http.MethodPost, "/v1/metrics", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rr.Code != tt.expected {
+				t.Errorf("expected status %d, got %d: %s", tt.expected, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+// TestAdminRoutesRedactSecrets tests that neither the metrics nor the config view reveal secrets
+// passed on the command line
+func TestAdminRoutesRedactSecrets(t *testing.T) {
+	app := newTestApp()
+	app.config.db.dsn = "postgres://sales:hunter2@db/sales"
+	app.config.smtp.password = "hunter2"
+	handler := app.adminRoutes()
+
+	for _, path := range []string{"/v1/metrics", "/v1/config"} {
+		t.Run(path, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+			body := rr.Body.String()
+			if strings.Contains(body, "hunter2") || strings.Contains(body, `"cmdline"`) {
+				t.Errorf("response leaks a secret: %s", body)
+			}
+		})
+	}
+}
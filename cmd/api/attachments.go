@@ -0,0 +1,289 @@
+// File: cmd/api/attachments.go
+// Description: handlers for attaching files to sales, and removal of the files of deleted attachments
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/storage"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// listSaleAttachmentsHandler returns the attachments of a sale, oldest first.
+func (app *app) listSaleAttachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	attachments, err := app.models.Attachments.GetAllForSale(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"attachments": attachments}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// uploadSaleAttachmentHandler attaches the file sent as the "file" field of a multipart/form-data
+// body to a sale. Its type is sniffed from the contents, whatever the client says it is.
+func (app *app) uploadSaleAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	if app.files == nil {
+		app.storageUnavailableResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	filename, contents, err := app.readFormFile(w, r, "file", app.config.storage.maxBytes)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(contents))
+	attachment := &data.Attachment{
+		SaleID:      id,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        int64(len(contents)),
+		CreatedBy:   &app.contextGetUser(r).ID,
+	}
+
+	v := validator.New()
+	if data.ValidateAttachment(v, attachment, app.config.storage.maxBytes); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	attachment.Key, err = attachmentKey(attachment)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if err := app.files.Put(r.Context(), attachment.Key, bytes.NewReader(contents)); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.models.Attachments.Insert(attachment); err != nil {
+		// Nothing refers to the stored file, so it goes straight away
+		if err := app.files.Delete(context.Background(), attachment.Key); err != nil {
+			app.logger.Error("failed to remove an unused attachment file", slog.String("key", attachment.Key), slog.Any("error", err))
+		}
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrTooManyAttachments):
+			v.AddError("file", fmt.Sprintf("a sale must not have more than %d attachments", data.MaxSaleAttachments))
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/sales/%d/attachments/%d", id, attachment.ID))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"attachment": attachment}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// downloadSaleAttachmentHandler sends the file of an attachment, to be saved rather than shown.
+func (app *app) downloadSaleAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	if app.files == nil {
+		app.storageUnavailableResponse(w, r)
+		return
+	}
+
+	saleID, attachmentID, err := app.readAttachmentParameters(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	attachment, err := app.models.Attachments.Get(saleID, attachmentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	file, err := app.files.Open(r.Context(), attachment.Key)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, file); err != nil {
+		app.logError(r, err)
+	}
+}
+
+// deleteSaleAttachmentHandler removes an attachment of a sale and its file.
+func (app *app) deleteSaleAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	saleID, attachmentID, err := app.readAttachmentParameters(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	attachment, err := app.models.Attachments.Delete(saleID, attachmentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// The file was queued for the cleanup job with the row, so failing here only delays its removal
+	if _, err := app.removeDeletedFiles(attachment.Key); err != nil {
+		app.logger.Error("failed to remove an attachment file", slog.String("key", attachment.Key), slog.Any("error", err))
+	}
+
+	if err := app.writeJSON(w, http.StatusNoContent, nil, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// readAttachmentParameters returns the sale and attachment IDs of the URL.
+func (app *app) readAttachmentParameters(r *http.Request) (int64, int64, error) {
+	saleID, err := app.readIDParameter(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	attachmentID, err := strconv.ParseInt(httprouter.ParamsFromContext(r.Context()).ByName("attachment_id"), 10, 64)
+	if err != nil || attachmentID < 1 {
+		return 0, 0, errors.New("invalid attachment_id parameter")
+	}
+	return saleID, attachmentID, nil
+}
+
+// readFormFile reads the name and contents of a file field of a multipart/form-data body. At most
+// limit+1 bytes of the file are read, so a file that is too large can still be told apart.
+func (app *app) readFormFile(w http.ResponseWriter, r *http.Request, field string, limit int64) (string, []byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return "", nil, errors.New("the body must be multipart/form-data")
+	}
+
+	// Leave room for the multipart boundaries and headers around the file
+	r.Body = http.MaxBytesReader(w, r.Body, limit+64_000)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return "", nil, fmt.Errorf("the body contains a badly-formed form: %w", err)
+	}
+
+	for {
+		part, err := reader.NextPart()
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.Is(err, io.EOF):
+			return "", nil, fmt.Errorf("the body must contain a %q file", field)
+		case errors.As(err, &maxBytesError):
+			return "", nil, fmt.Errorf("the body must not be larger than %d bytes", maxBytesError.Limit)
+		case err != nil:
+			return "", nil, fmt.Errorf("the body contains a badly-formed form: %w", err)
+		}
+		if part.FormName() != field {
+			continue
+		}
+
+		contents, err := io.ReadAll(io.LimitReader(part, limit+1))
+		if errors.As(err, &maxBytesError) {
+			return "", nil, fmt.Errorf("the body must not be larger than %d bytes", maxBytesError.Limit)
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("the body contains a badly-formed form: %w", err)
+		}
+		return part.FileName(), contents, nil
+	}
+}
+
+// attachmentKey returns a new, unguessable key to store an attachment's file under.
+func attachmentKey(attachment *data.Attachment) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sales/%d/%s%s", attachment.SaleID, hex.EncodeToString(random), data.AttachmentTypes[attachment.ContentType]), nil
+}
+
+// removeDeletedFiles removes the given files of deleted attachments, or up to 1000 of those still
+// queued when none are given, and takes them off the queue. It returns how many were removed.
+func (app *app) removeDeletedFiles(keys ...string) (int, error) {
+	if app.files == nil {
+		return 0, nil
+	}
+	if len(keys) == 0 {
+		var err error
+		if keys, err = app.models.Attachments.PendingFileDeletions(1000); err != nil {
+			return 0, err
+		}
+	}
+
+	removed := make([]string, 0, len(keys))
+	var errs []error
+	for _, key := range keys {
+		if err := app.files.Delete(context.Background(), key); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, key)
+	}
+	if len(removed) > 0 {
+		if err := app.models.Attachments.ForgetFileDeletions(removed); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(removed), errors.Join(errs...)
+}
@@ -0,0 +1,97 @@
+// File: cmd/api/authevents.go
+// Description: counters and the admin report of authentication outcomes, for spotting credential
+// stuffing and accounts that never get activated
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// authCounters counts authentication outcomes since the process started. The zero value is ready
+// to use.
+type authCounters struct {
+	mu     sync.Mutex
+	counts map[string]int64 // outcomes by event, one of data.AuthEvents
+}
+
+// add counts one outcome.
+func (c *authCounters) add(event string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts == nil {
+		c.counts = make(map[string]int64)
+	}
+	c.counts[event]++
+}
+
+// snapshot returns the outcomes counted so far, every event included.
+func (c *authCounters) snapshot() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := make(map[string]int64, len(data.AuthEvents))
+	for _, event := range data.AuthEvents {
+		counts[event] = c.counts[event]
+	}
+	return counts
+}
+
+// recordAuthEvent counts an authentication outcome and writes it to the auth events log in the
+// background, so a flood of failed logins is not slowed down further by the writes. userID is nil
+// when the attempt named no account.
+func (app *app) recordAuthEvent(r *http.Request, event string, userID *int64) {
+	app.authEvents.add(event)
+
+	ip := clientIP(r)
+	app.background(func() {
+		if err := app.models.AuthEvents.Insert(event, userID, ip); err != nil {
+			app.logger.Error("failed to record auth event", "event", event, "error", err)
+		}
+	})
+}
+
+// writeAuthEvents writes the authentication outcomes counted since startup.
+func writeAuthEvents(w io.Writer, counts map[string]int64) error {
+	if _, err := fmt.Fprint(w, "# HELP auth_events_total Authentication outcomes since startup by event.\n# TYPE auth_events_total counter\n"); err != nil {
+		return err
+	}
+	for _, event := range slices.Sorted(maps.Keys(counts)) {
+		if _, err := fmt.Fprintf(w, "auth_events_total{event=%q} %d\n", event, counts[event]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authReportHandler returns the authentication outcomes of each day of a range with their totals,
+// the share of logins that failed and activations per registration, so admins can spot credential
+// stuffing and accounts that are never activated.
+func (app *app) authReportHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	reportRange := app.readReportRange(r.URL.Query(), app.contextGetUser(r), v)
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	report, err := app.modelsFor(r).AuthEvents.Report(reportRange)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"report": report}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,54 @@
+// File: cmd/api/authevents_test.go
+// Description: test suite for the authentication outcome counters
+
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+)
+
+// TestAuthEventCounters tests that lockouts and throttled logins are counted and exposed in the
+// Prometheus format with every event listed
+func TestAuthEventCounters(t *testing.T) {
+	app := newTestApp()
+	app.logger = slog.New(slog.NewTextHandler(io.Discard, nil)) // the event log has no database here
+	app.config.limiter.loginEnabled = true
+	app.config.limiter.loginAttempts = 10
+	app.config.limiter.loginBackoff = time.Minute
+	app.config.limiter.loginMaxBackoff = time.Hour
+
+	handler := app.limitLogins(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	for range 3 {
+		req := httptest.NewRequest(http.MethodPost, "/v1/tokens/authentication", strings.NewReader(`{"email": "a@example.com"}`))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	app.wg.Wait()
+
+	counts := app.authEvents.snapshot()
+	if counts[data.AuthLockout] != 1 || counts[data.AuthLoginThrottled] != 1 {
+		t.Errorf("expected one lockout and one throttled login, got %v", counts)
+	}
+	if len(counts) != len(data.AuthEvents) {
+		t.Errorf("expected every event in the snapshot, got %v", counts)
+	}
+
+	var out strings.Builder
+	if err := writeAuthEvents(&out, counts); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`auth_events_total{event="lockout"} 1`, `auth_events_total{event="login_succeeded"} 0`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics are missing %q:\n%s", want, out.String())
+		}
+	}
+}
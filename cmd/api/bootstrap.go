@@ -0,0 +1,100 @@
+// File: cmd/api/bootstrap.go
+// Description: first-run setup, creating the initial admin of an empty database
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// showBootstrapHandler tells setup wizards whether the install still needs its first admin.
+func (app *app) showBootstrapHandler(w http.ResponseWriter, r *http.Request) {
+	completed, err := app.models.Bootstrap.Completed()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"bootstrap": envelope{"required": !completed}}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// bootstrapHandler creates the first admin of a new install, seeding the permissions and built in
+// roles with it, so no SQL has to be run by hand. The admin is active straight away. It only ever
+// succeeds once: after that, or on a database that already has users, it returns 409 Conflict.
+func (app *app) bootstrapHandler(w http.ResponseWriter, r *http.Request) {
+	// BootstrapPayload struct to hold the incoming JSON payload
+	var BootstrapPayload struct {
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Email     string `json:"email"`
+		Password  string `json:"password"`
+		Timezone  string `json:"timezone,omitempty"` // Optional - will default to UTC
+		Locale    string `json:"locale,omitempty"`   // Optional - will default to en
+	}
+
+	if err := app.readJSON(w, r, &BootstrapPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := &data.User{
+		FirstName: BootstrapPayload.FirstName,
+		LastName:  BootstrapPayload.LastName,
+		Role:      data.AdminRole,
+		Email:     BootstrapPayload.Email,
+		Timezone:  BootstrapPayload.Timezone,
+		Locale:    BootstrapPayload.Locale,
+	}
+
+	if err := user.Password.Set(BootstrapPayload.Password); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateUser(v, user); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Bootstrap.Run(user); err != nil {
+		switch {
+		case errors.Is(err, data.ErrBootstrapped):
+			app.bootstrappedResponse(w, r)
+		case errors.Is(err, data.ErrInvalidData):
+			v.AddError("user", "invalid user data provided")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	entry := &data.AuditEntry{
+		UserID:    &user.ID,
+		Action:    data.ActionBootstrap,
+		Entity:    "users",
+		EntityIDs: []int64{user.ID},
+		Details:   map[string]any{"email": user.Email},
+	}
+	if err := app.models.Audit.Insert(entry); err != nil {
+		app.logger.Error("failed to write audit log", "action", entry.Action, "user_id", user.ID, "error", err)
+	}
+	app.logger.Warn("bootstrap completed, first admin created", "user_id", user.ID)
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/users/%d", user.ID))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"user": user}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
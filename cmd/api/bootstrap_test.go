@@ -0,0 +1,48 @@
+// File: cmd/api/bootstrap_test.go
+// Description: test suite for the first-run setup handler
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestBootstrapValidation tests that the first admin's details are validated before the database is used
+func TestBootstrapValidation(t *testing.T) {
+	app := newTestApp()
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		field  string
+	}{
+		{"Malformed JSON", `{"email": `, http.StatusBadRequest, ""},
+		{"Unknown Field", `{"role": "guest"}`, http.StatusBadRequest, ""},
+		{"Missing Name", `{"last_name": "Doe", "email": "admin@example.com", "password": "SecurePassword123!"}`, http.StatusUnprocessableEntity, "first_name"},
+		{"Invalid Email", `{"first_name": "Ana", "last_name": "Doe", "email": "admin", "password": "SecurePassword123!"}`, http.StatusUnprocessableEntity, "email"},
+		{"Weak Password", `{"first_name": "Ana", "last_name": "Doe", "email": "admin@example.com", "password": "short"}`, http.StatusUnprocessableEntity, "password"},
+		{"Unknown Timezone", `{"first_name": "Ana", "last_name": "Doe", "email": "admin@example.com", "password": "SecurePassword123!", "timezone": "Mars/Base"}`, http.StatusUnprocessableEntity, "timezone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/bootstrap", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			app.bootstrapHandler(rr, req)
+
+			if rr.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+			if tt.field != "" && !strings.Contains(rr.Body.String(), `"`+tt.field+`"`) {
+				t.Errorf("expected a %s error, got %s", tt.field, rr.Body.String())
+			}
+		})
+	}
+}
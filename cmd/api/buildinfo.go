@@ -0,0 +1,99 @@
+// File: cmd/api/buildinfo.go
+// Description: build metadata stamped in at link time and the endpoint that reports it
+
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, set with -ldflags "-X main.commit=<sha> -X main.buildTime=<RFC3339>". When left
+// empty they fall back to the VCS information the Go toolchain embeds in builds from a checkout.
+var (
+	commit    string
+	buildTime string
+)
+
+// buildInfo describes the running binary, for checking which build a deploy is serving.
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+	Modified  bool   `json:"modified,omitempty"` // built from a checkout with uncommitted changes
+}
+
+// readBuildInfo returns the build metadata of the running binary, "unknown" for anything that
+// was neither stamped in nor embedded by the toolchain.
+func readBuildInfo() buildInfo {
+	info := buildInfo{Version: version, Commit: commit, BuildTime: buildTime, GoVersion: runtime.Version()}
+
+	if embedded, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range embedded.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true" && commit == ""
+			}
+		}
+	}
+
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+}
+
+// features reports which optional integrations and protections this instance has switched on.
+func (app *app) features() map[string]bool {
+	return map[string]bool{
+		"email":            app.mailer != nil,
+		"email_webhook":    app.config.smtp.webhookSecret != "",
+		"sms_alerts":       app.notifier.Enabled(),
+		"low_stock_alerts": app.lowStockAlertsEnabled(),
+		"two_factor":       len(app.config.totp.encryptionKey) > 0,
+		"chatbot":          app.models.ChatbotModel.Configured(),
+		"attachments":      app.files != nil,
+		"rate_limiter":     app.config.limiter.enabled,
+		"login_throttle":   app.config.limiter.loginEnabled,
+		"fault_injection":  app.config.chaos.enabled,
+		"sandbox":          app.sandbox(),
+	}
+}
+
+// versionHandler reports the version, commit, build time and Go version of the running binary
+// with the features enabled and the token lifetimes of this instance. It needs no authentication or database.
+func (app *app) versionHandler(w http.ResponseWriter, r *http.Request) {
+	info := readBuildInfo()
+
+	env := envelope{
+		"version":    info.Version,
+		"commit":     info.Commit,
+		"build_time": info.BuildTime,
+		"go_version": info.GoVersion,
+		"modified":   info.Modified,
+		"env":        app.config.env,
+		"features":   app.features(),
+		"token_ttl_seconds": map[string]int64{
+			"activation":     int64(app.config.tokens.activationTTL.Seconds()),
+			"authentication": int64(app.config.tokens.authenticationTTL.Seconds()),
+		},
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
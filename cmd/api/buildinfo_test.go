@@ -0,0 +1,55 @@
+// File: cmd/api/buildinfo_test.go
+// Description: test suite for the build info endpoint
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+// TestVersionHandler tests that stamped build metadata and enabled features are reported
+func TestVersionHandler(t *testing.T) {
+	defer func(c, b string) { commit, buildTime = c, b }(commit, buildTime)
+	commit, buildTime = "abc1234", "2025-06-01T12:00:00Z"
+
+	app := newTestApp()
+	app.config.env = "staging"
+	app.config.limiter.enabled = true
+
+	rr := httptest.NewRecorder()
+	app.versionHandler(rr, httptest.NewRequest(http.MethodGet, "/v1/version", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+
+	var response struct {
+		Version   string           `json:"version"`
+		Commit    string           `json:"commit"`
+		BuildTime string           `json:"build_time"`
+		GoVersion string           `json:"go_version"`
+		Env       string           `json:"env"`
+		Features  map[string]bool  `json:"features"`
+		TokenTTLs map[string]int64 `json:"token_ttl_seconds"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Version != version || response.Commit != "abc1234" || response.BuildTime != "2025-06-01T12:00:00Z" {
+		t.Errorf("expected the stamped build metadata, got %+v", response)
+	}
+	if response.GoVersion != runtime.Version() || response.Env != "staging" {
+		t.Errorf("expected go version %s in staging, got %+v", runtime.Version(), response)
+	}
+	if !response.Features["rate_limiter"] || response.Features["email"] || response.Features["fault_injection"] {
+		t.Errorf("expected only the rate limiter enabled, got %v", response.Features)
+	}
+	if response.TokenTTLs["activation"] != int64(defaultActivationTokenTTL.Seconds()) || response.TokenTTLs["authentication"] != int64(defaultAuthenticationTokenTTL.Seconds()) {
+		t.Errorf("expected the default token lifetimes, got %v", response.TokenTTLs)
+	}
+}
@@ -0,0 +1,231 @@
+// File: cmd/api/calendar.go
+// Description: admin handlers for the business calendar of opening hours and holidays
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// getCalendarHandler returns the opening hours of the week and the holidays from ?from= to ?to=,
+// by default from today in the user's timezone to a year later.
+func (app *app) getCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	v := validator.New()
+
+	today := app.now().In(app.contextGetUser(r).Location())
+	from := app.getSingleQueryParameter(query, "from", today.Format(time.DateOnly))
+	to := app.getSingleQueryParameter(query, "to", today.AddDate(1, 0, 0).Format(time.DateOnly))
+	fromDate, err := time.Parse(time.DateOnly, from)
+	v.Check(err == nil, "from", "must be a valid date in YYYY-MM-DD format")
+	toDate, err := time.Parse(time.DateOnly, to)
+	v.Check(err == nil, "to", "must be a valid date in YYYY-MM-DD format")
+	if v.IsValid() {
+		v.Check(!toDate.Before(fromDate), "to", "must not be before from")
+		v.Check(toDate.Sub(fromDate) < 5*366*24*time.Hour, "to", "must be less than five years after from")
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	calendar, err := app.modelsFor(r).Calendar.Get(fromDate, toDate)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"calendar": calendar}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// updateBusinessHoursHandler replaces the opening hours of the weekdays given, leaving the others
+// as they are, and returns the whole week.
+func (app *app) updateBusinessHoursHandler(w http.ResponseWriter, r *http.Request) {
+	// UpdateHoursPayload struct to hold the incoming JSON payload
+	var UpdateHoursPayload struct {
+		Hours []*data.BusinessHours `json:"hours"`
+	}
+
+	if err := app.readJSON(w, r, &UpdateHoursPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(len(UpdateHoursPayload.Hours) > 0, "hours", "must contain at least one weekday")
+	v.Check(len(UpdateHoursPayload.Hours) <= len(data.Weekdays), "hours", "must not contain more than 7 weekdays")
+	seen := make(map[string]bool, len(UpdateHoursPayload.Hours))
+	userID := app.contextGetUser(r).ID
+	for _, hours := range UpdateHoursPayload.Hours {
+		if hours == nil {
+			v.AddError("hours", "must not contain null")
+			break
+		}
+		if data.ValidateBusinessHours(v, hours); !v.IsValid() {
+			break
+		}
+		v.Check(!seen[hours.Weekday], "hours", "must not contain a weekday more than once")
+		seen[hours.Weekday] = true
+		hours.UpdatedBy = &userID
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Calendar.UpdateHours(UpdateHoursPayload.Hours); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	week, err := app.models.Calendar.GetHours()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"hours": week}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// createHolidayHandler adds a date the business is closed on.
+func (app *app) createHolidayHandler(w http.ResponseWriter, r *http.Request) {
+	// CreateHolidayPayload struct to hold the incoming JSON payload
+	var CreateHolidayPayload struct {
+		Date string `json:"date"`
+		Name string `json:"name"`
+	}
+
+	if err := app.readJSON(w, r, &CreateHolidayPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	holiday := &data.Holiday{
+		Date:      CreateHolidayPayload.Date,
+		Name:      CreateHolidayPayload.Name,
+		CreatedBy: &app.contextGetUser(r).ID,
+	}
+
+	v := validator.New()
+	if data.ValidateHoliday(v, holiday); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Calendar.InsertHoliday(holiday); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateHoliday):
+			v.AddError("date", "is already a holiday")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/admin/calendar/holidays/%d", holiday.ID))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"holiday": holiday}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// updateHolidayHandler moves or renames a holiday.
+func (app *app) updateHolidayHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// UpdateHolidayPayload struct to hold the incoming JSON payload
+	var UpdateHolidayPayload struct {
+		Date *string `json:"date"`
+		Name *string `json:"name"`
+	}
+
+	if err := app.readJSON(w, r, &UpdateHolidayPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	holiday, err := app.models.Calendar.GetHoliday(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if UpdateHolidayPayload.Date != nil {
+		holiday.Date = *UpdateHolidayPayload.Date
+	}
+	if UpdateHolidayPayload.Name != nil {
+		holiday.Name = *UpdateHolidayPayload.Name
+	}
+
+	v := validator.New()
+	if data.ValidateHoliday(v, holiday); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Calendar.UpdateHoliday(holiday); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateHoliday):
+			v.AddError("date", "is already a holiday")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"holiday": holiday}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// deleteHolidayHandler removes a holiday, so the business opens as usual on its date.
+func (app *app) deleteHolidayHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.models.Calendar.DeleteHoliday(id); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusNoContent, nil, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,58 @@
+// File: cmd/api/chaos.go
+// Description: fault injection middleware for exercising client retry logic outside production
+
+package main
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// chaosRoll returns a number in [0, 100) that is compared against the fault percentages.
+// Tests replace it to make injection deterministic.
+var chaosRoll = func() float64 { return rand.Float64() * 100 }
+
+// injectFaults is a middleware that, when chaos is enabled, drops the connection, answers with an
+// error or delays the response on the configured percentage of requests. Monitoring endpoints are
+// left alone so the effects can be observed. Injected responses carry an X-Fault-Injected header.
+func (app *app) injectFaults(next http.Handler) http.Handler {
+	if !app.config.chaos.enabled {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/metrics") || r.URL.Path == "/v1/healthcheck" || r.URL.Path == "/v1/status" || r.URL.Path == "/v1/version" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if chaosRoll() < app.config.chaos.dropPercent {
+			// Close the connection without a response, as a crashed server or a broken proxy would
+			if conn, _, err := http.NewResponseController(w).Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+
+		if chaosRoll() < app.config.chaos.errorPercent {
+			w.Header().Set("X-Fault-Injected", "error")
+			app.errorResponseJSON(w, r, app.config.chaos.errorStatus, "fault injected")
+			return
+		}
+
+		if chaosRoll() < app.config.chaos.latencyPercent {
+			w.Header().Set("X-Fault-Injected", "latency")
+			timer := time.NewTimer(app.config.chaos.latency)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
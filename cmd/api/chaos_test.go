@@ -0,0 +1,80 @@
+// File: cmd/api/chaos_test.go
+// Description: test suite for the fault injection middleware
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestInjectFaults tests which fault is injected for a given roll and that monitoring is left alone
+func TestInjectFaults(t *testing.T) {
+	defer func(roll func() float64) { chaosRoll = roll }(chaosRoll)
+	chaosRoll = func() float64 { return 10 }
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		enabled        bool
+		errorPercent   float64
+		latencyPercent float64
+		path           string
+		expectedStatus int
+		expectedFault  string
+	}{
+		{name: "Disabled", enabled: false, errorPercent: 100, path: "/v1/products", expectedStatus: http.StatusOK},
+		{name: "Error Injected", enabled: true, errorPercent: 50, path: "/v1/products", expectedStatus: http.StatusServiceUnavailable, expectedFault: "error"},
+		{name: "Roll Above Percentage", enabled: true, errorPercent: 5, path: "/v1/products", expectedStatus: http.StatusOK},
+		{name: "Latency Injected", enabled: true, latencyPercent: 50, path: "/v1/products", expectedStatus: http.StatusOK, expectedFault: "latency"},
+		{name: "Healthcheck Skipped", enabled: true, errorPercent: 100, path: "/v1/healthcheck", expectedStatus: http.StatusOK},
+		{name: "Metrics Skipped", enabled: true, errorPercent: 100, path: "/v1/metrics/prometheus", expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp()
+			app.config.chaos.enabled = tt.enabled
+			app.config.chaos.errorPercent = tt.errorPercent
+			app.config.chaos.errorStatus = http.StatusServiceUnavailable
+			app.config.chaos.latencyPercent = tt.latencyPercent
+			app.config.chaos.latency = time.Millisecond
+
+			rr := httptest.NewRecorder()
+			app.injectFaults(ok).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if got := rr.Header().Get("X-Fault-Injected"); got != tt.expectedFault {
+				t.Errorf("expected fault %q, got %q", tt.expectedFault, got)
+			}
+		})
+	}
+}
+
+// TestInjectFaultsDropsConnection tests that a dropped request gets no response at all
+func TestInjectFaultsDropsConnection(t *testing.T) {
+	defer func(roll func() float64) { chaosRoll = roll }(chaosRoll)
+	chaosRoll = func() float64 { return 0 }
+
+	app := newTestApp()
+	app.config.chaos.enabled = true
+	app.config.chaos.dropPercent = 1
+
+	server := httptest.NewServer(app.injectFaults(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/v1/products")
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected the connection to be dropped, got status %d", resp.StatusCode)
+	}
+}
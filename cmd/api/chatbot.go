@@ -37,7 +37,7 @@ func (app *app) chatbotHandler(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Printf("📤 Chatbot request: '%s' from %s (%s)\n", input.Message, user.Email, user.Role)
 
-	chatbot := app.models.ChatbotModel
+	chatbot := app.modelsFor(r).ChatbotModel
 	response, err := chatbot.ProcessMessage(input.Message, user) // Pass full user object
 	if err != nil {
 		app.logger.Error(err.Error())
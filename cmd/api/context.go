@@ -10,7 +10,10 @@ import (
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey  = contextKey("user")
+	tokenContextKey = contextKey("token")
+)
 
 // contextSetUser adds the user information to the request context.
 func (app *app) contextSetUser(r *http.Request, user *data.User) *http.Request {
@@ -26,3 +29,15 @@ func (app *app) contextGetUser(r *http.Request) *data.User {
 	}
 	return user // Return the retrieved user
 }
+
+// contextSetToken adds the plaintext authentication token of the request to the context.
+func (app *app) contextSetToken(r *http.Request, token string) *http.Request {
+	ctx := context.WithValue(r.Context(), tokenContextKey, token)
+	return r.WithContext(ctx)
+}
+
+// contextGetToken retrieves the plaintext authentication token, empty for anonymous requests.
+func (app *app) contextGetToken(r *http.Request) string {
+	token, _ := r.Context().Value(tokenContextKey).(string)
+	return token
+}
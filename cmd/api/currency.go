@@ -0,0 +1,114 @@
+// File: cmd/api/currency.go
+// Description: handlers for exchange rates and helpers for converting amounts between currencies
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// listExchangeRatesHandler returns the base currency and the rates of every other currency against it.
+func (app *app) listExchangeRatesHandler(w http.ResponseWriter, r *http.Request) {
+	rates, err := app.models.ExchangeRates.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"base_currency": app.config.baseCurrency, "exchange_rates": rates}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// setExchangeRateHandler sets how many units of the currency in the URL one unit of the base
+// currency buys, replacing any existing rate.
+func (app *app) setExchangeRateHandler(w http.ResponseWriter, r *http.Request) {
+	// SetExchangeRatePayload struct to hold the incoming JSON payload
+	var SetExchangeRatePayload struct {
+		Rate float64 `json:"rate"`
+	}
+
+	if err := app.readJSON(w, r, &SetExchangeRatePayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rate := &data.ExchangeRate{
+		Currency:  httprouter.ParamsFromContext(r.Context()).ByName("currency"),
+		Rate:      SetExchangeRatePayload.Rate,
+		UpdatedBy: &app.contextGetUser(r).ID,
+	}
+
+	v := validator.New()
+	v.Check(rate.Currency != app.config.baseCurrency, "currency", "is the base currency, its rate is always 1")
+	if data.ValidateExchangeRate(v, rate); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.ExchangeRates.Upsert(rate); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"exchange_rate": rate}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// readConversion reads the currency query parameter and loads the conversion into it, defaulting
+// to the base currency. An unknown or malformed currency is added to v, only database errors are
+// returned.
+func (app *app) readConversion(query url.Values, v *validator.Validator) (*data.Conversion, error) {
+	currency := app.getSingleQueryParameter(query, "currency", app.config.baseCurrency)
+	if data.ValidateCurrency(v, "currency", currency); !v.IsValid() {
+		return nil, nil
+	}
+
+	conversion, err := app.models.ExchangeRates.Conversion(app.config.baseCurrency, currency)
+	if err != nil {
+		if errors.Is(err, data.ErrUnknownCurrency) {
+			v.AddError("currency", "has no exchange rate")
+			return nil, nil
+		}
+		return nil, err
+	}
+	return conversion, nil
+}
+
+// checkCurrency adds a validation error when amounts in currency cannot be converted into the
+// base currency, so every price can be totalled in reports.
+func (app *app) checkCurrency(v *validator.Validator, currency string) error {
+	if currency == app.config.baseCurrency {
+		return nil
+	}
+
+	conversion, err := app.models.ExchangeRates.Conversion(app.config.baseCurrency, app.config.baseCurrency)
+	if err != nil {
+		return err
+	}
+	v.Check(conversion.Knows(currency), "currency", "has no exchange rate, set one first")
+	return nil
+}
+
+// convertPrices converts the prices of products into the currency of fx. A nil fx leaves every
+// price in the product's own currency.
+func convertPrices(fx *data.Conversion, products ...*data.Product) {
+	if fx == nil {
+		return
+	}
+	for _, product := range products {
+		product.Price = fx.Convert(product.Price, product.Currency)
+		if fx.Knows(product.Currency) {
+			product.Currency = fx.Currency
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// File: cmd/api/currency_test.go
+// Description: test suite for exchange rate handlers and currency parameters
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/julienschmidt/httprouter"
+)
+
+// TestSetExchangeRateHandlerValidation tests that the base currency, bad codes and rates are rejected
+func TestSetExchangeRateHandlerValidation(t *testing.T) {
+	app := newTestApp()
+	app.config.baseCurrency = "USD"
+
+	tests := []struct {
+		name     string
+		currency string
+		body     string
+	}{
+		{"Base Currency", "USD", `{"rate": 1.5}`},
+		{"Lower Case Code", "bzd", `{"rate": 2}`},
+		{"Zero Rate", "BZD", `{"rate": 0}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/v1/exchange-rates/"+tt.currency, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			params := httprouter.Params{{Key: "currency", Value: tt.currency}}
+			req = req.WithContext(context.WithValue(req.Context(), httprouter.ParamsKey, params))
+			req = app.contextSetUser(req, &data.User{ID: 7})
+			rr := httptest.NewRecorder()
+
+			app.setExchangeRateHandler(rr, req)
+
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Errorf("expected status %d, got %d: %s", http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	t.Run("Malformed Report Currency", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/reports/sales/heatmap?currency=usd", nil)
+		req = app.contextSetUser(req, &data.User{ID: 7})
+		rr := httptest.NewRecorder()
+		app.salesHeatmapHandler(rr, req)
+
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+		}
+	})
+}
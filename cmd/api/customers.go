@@ -0,0 +1,286 @@
+// File: cmd/api/customers.go
+// Description: customers api handlers, and their purchase history
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// createCustomerHandler handles the creation of a new customer.
+func (app *app) createCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	// CustomerCreatePayload struct to hold the incoming JSON payload
+	var CustomerCreatePayload struct {
+		Name  string `json:"name"`
+		Email string `json:"email"` // Optional
+		Phone string `json:"phone"` // Optional
+	}
+
+	if err := app.readJSON(w, r, &CustomerCreatePayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	customer := &data.Customer{
+		Name:      CustomerCreatePayload.Name,
+		Email:     CustomerCreatePayload.Email,
+		Phone:     CustomerCreatePayload.Phone,
+		CreatedBy: &app.contextGetUser(r).ID,
+	}
+	data.NormalizeCustomer(customer)
+
+	v := validator.New()
+	if data.ValidateCustomer(v, customer); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Customers.Insert(customer); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "a customer with this email address already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/customers/%d", customer.ID))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"customer": customer}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// listCustomersHandler handles listing customers with optional filtering and pagination.
+func (app *app) listCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	v := validator.New()
+
+	CustomerSortSafelist := []string{"id", "name", "email", "created_at", "-id", "-name", "-email", "-created_at"}
+
+	filter := data.CustomerFilter{
+		Filter: app.readFilters(query, "customers", "name", CustomerSortSafelist, v),
+		Name:   app.getSingleQueryParameter(query, "name", ""),
+		Email:  app.getSingleQueryParameter(query, "email", ""),
+		Phone:  app.getSingleQueryParameter(query, "phone", ""),
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	customers, metadata, err := app.models.Customers.GetAll(filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidSort):
+			app.invalidSortResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"customers": customers, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// getCustomerHandler handles retrieving a customer by ID.
+func (app *app) getCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	customer, err := app.models.Customers.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"customer": customer}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// updateCustomerHandler handles updating an existing customer by ID. Fields left out keep their
+// value and "" removes the email or phone.
+func (app *app) updateCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	customer, err := app.models.Customers.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// CustomerUpdatePayload struct to hold the incoming JSON payload
+	var CustomerUpdatePayload struct {
+		Name  *string `json:"name"`
+		Email *string `json:"email"`
+		Phone *string `json:"phone"`
+	}
+
+	if err := app.readJSON(w, r, &CustomerUpdatePayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if CustomerUpdatePayload.Name != nil {
+		customer.Name = *CustomerUpdatePayload.Name
+	}
+	if CustomerUpdatePayload.Email != nil {
+		customer.Email = *CustomerUpdatePayload.Email
+	}
+	if CustomerUpdatePayload.Phone != nil {
+		customer.Phone = *CustomerUpdatePayload.Phone
+	}
+	customer.UpdatedBy = &app.contextGetUser(r).ID
+	data.NormalizeCustomer(customer)
+
+	v := validator.New()
+	if data.ValidateCustomer(v, customer); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Customers.Update(customer); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "a customer with this email address already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"customer": customer}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// deleteCustomerHandler handles deleting a customer by ID. Their sales are kept without a customer.
+func (app *app) deleteCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.models.Customers.Delete(id); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusNoContent, nil, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// listCustomerSalesHandler returns the purchase history of a customer, newest first unless sorted
+// otherwise. It takes the pagination and sort parameters of the sales listing.
+func (app *app) listCustomerSalesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	v := validator.New()
+
+	filter := data.SaleFilter{
+		Filter:     app.readFilters(query, "sales", "-sold_at", saleSortSafelist, v),
+		CustomerID: id,
+	}
+	if includeArchived := app.getOptionalBoolQueryParameter(query, "include_archived", v); includeArchived != nil {
+		filter.IncludeArchived = *includeArchived
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if _, err := app.models.Customers.Get(id); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	sales, metadata, err := app.models.Sales.GetAll(filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidSort):
+			app.invalidSortResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"sales": sales, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// checkSaleCustomer adds a validation error when the sale names a customer that does not exist. A
+// sale without a customer email is given the customer's, so their receipts follow them.
+func (app *app) checkSaleCustomer(v *validator.Validator, sale *data.Sale) error {
+	if sale.CustomerID == nil {
+		return nil
+	}
+
+	customer, err := app.models.Customers.Get(*sale.CustomerID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			v.AddError("customer_id", "must be an existing customer")
+			return nil
+		}
+		return err
+	}
+	if sale.CustomerEmail == "" {
+		sale.CustomerEmail = customer.Email
+	}
+	return nil
+}
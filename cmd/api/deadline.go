@@ -0,0 +1,67 @@
+// File: cmd/api/deadline.go
+// Description: request deadlines from the X-Request-Timeout header, passed down to database queries
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+)
+
+// parseRequestTimeout reads an X-Request-Timeout value, either seconds ("2.5") or a duration
+// with a unit ("1500ms").
+func parseRequestTimeout(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+
+	var timeout time.Duration
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		timeout = time.Duration(seconds * float64(time.Second))
+	} else if timeout, err = time.ParseDuration(value); err != nil {
+		return 0, errors.New("X-Request-Timeout must be a number of seconds or a duration such as 1500ms")
+	}
+
+	if timeout <= 0 {
+		return 0, errors.New("X-Request-Timeout must be greater than zero")
+	}
+	return timeout, nil
+}
+
+// requestDeadline is a middleware that gives the request context a deadline from the
+// X-Request-Timeout header, capped at the configured maximum, so work for a client that has
+// given up stops. Requests without the header keep the per query timeouts only.
+func (app *app) requestDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := r.Header.Get("X-Request-Timeout")
+		if value == "" || app.config.requestTimeoutMax <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		timeout, err := parseRequestTimeout(value)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		if timeout > app.config.requestTimeoutMax {
+			timeout = app.config.requestTimeoutMax
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// modelsFor returns the models with their queries bound to the request context, so they are
+// cancelled when the request's deadline passes or the client disconnects. Used by handlers
+// that run long report and bulk queries.
+func (app *app) modelsFor(r *http.Request) *data.Models {
+	models := app.models.WithContext(r.Context())
+	return &models
+}
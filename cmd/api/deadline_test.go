@@ -0,0 +1,106 @@
+// File: cmd/api/deadline_test.go
+// Description: test suite for request deadlines set with X-Request-Timeout
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestParseRequestTimeout tests the accepted X-Request-Timeout formats
+func TestParseRequestTimeout(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		valid    bool
+	}{
+		{"5", 5 * time.Second, true},
+		{"2.5", 2500 * time.Millisecond, true},
+		{" 1500ms ", 1500 * time.Millisecond, true},
+		{"1m", time.Minute, true},
+		{"0", 0, false},
+		{"-3", 0, false},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseRequestTimeout(tt.value)
+			if tt.valid && (err != nil || got != tt.expected) {
+				t.Errorf("expected %v, got %v (%v)", tt.expected, got, err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("expected an error, got %v", got)
+			}
+		})
+	}
+}
+
+// TestRequestDeadline tests that the header sets a capped deadline and that an expired request gets a 504
+func TestRequestDeadline(t *testing.T) {
+	app := newTestApp()
+	app.config.requestTimeoutMax = 2 * time.Second
+
+	tests := []struct {
+		name           string
+		header         string
+		expectedStatus int
+		maxRemaining   time.Duration
+	}{
+		{name: "No Header", expectedStatus: http.StatusOK},
+		{name: "Short Deadline", header: "1", expectedStatus: http.StatusOK, maxRemaining: time.Second},
+		{name: "Capped Deadline", header: "1h", expectedStatus: http.StatusOK, maxRemaining: 2 * time.Second},
+		{name: "Malformed Header", header: "soon", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var remaining time.Duration
+			var hasDeadline bool
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var deadline time.Time
+				if deadline, hasDeadline = r.Context().Deadline(); hasDeadline {
+					remaining = time.Until(deadline)
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/reports/sales/heatmap", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Request-Timeout", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			app.requestDeadline(next).ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if hasDeadline != (tt.maxRemaining > 0) {
+				t.Fatalf("expected deadline=%v, got %v", tt.maxRemaining > 0, hasDeadline)
+			}
+			if hasDeadline && (remaining > tt.maxRemaining || remaining < tt.maxRemaining-time.Second) {
+				t.Errorf("expected about %v left, got %v", tt.maxRemaining, remaining)
+			}
+		})
+	}
+
+	t.Run("Expired Deadline", func(t *testing.T) {
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			app.serverErrorResponse(w, r, errors.New("pq: canceling statement due to user request"))
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/reports/sales/heatmap", nil)
+		req.Header.Set("X-Request-Timeout", "10ms")
+		rr := httptest.NewRecorder()
+		app.requestDeadline(next).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusGatewayTimeout {
+			t.Errorf("expected status 504, got %d", rr.Code)
+		}
+	})
+}
@@ -0,0 +1,191 @@
+// File: cmd/api/discounts.go
+// Description: admin handlers for managing the discount codes applied to sales
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// readDiscount reads the fields of a discount from the request body into discount. Codes are upper
+// cased and tags lower cased, so both can be typed in any case; discounts are active unless asked
+// otherwise.
+func (app *app) readDiscount(w http.ResponseWriter, r *http.Request, discount *data.Discount) error {
+	// DiscountPayload struct to hold the incoming JSON payload
+	var DiscountPayload struct {
+		Code        string         `json:"code"`
+		Description string         `json:"description"`
+		Kind        string         `json:"kind"`
+		Value       float64        `json:"value"`
+		Currency    string         `json:"currency"`    // Required for a fixed amount
+		Tag         string         `json:"tag"`         // Optional - only products carrying this tag
+		ProductIDs  []int64        `json:"product_ids"` // Optional - only these products
+		StartsAt    data.Timestamp `json:"starts_at"`   // Optional - open ended when left out
+		EndsAt      data.Timestamp `json:"ends_at"`
+		Active      *bool          `json:"active"`
+	}
+
+	if err := app.readJSON(w, r, &DiscountPayload); err != nil {
+		return err
+	}
+
+	discount.Code = data.NormalizeDiscountCode(DiscountPayload.Code)
+	discount.Description = DiscountPayload.Description
+	discount.Kind = DiscountPayload.Kind
+	discount.Value = DiscountPayload.Value
+	discount.Currency = DiscountPayload.Currency
+	discount.Tag = ""
+	if tags := data.NormalizeTags([]string{DiscountPayload.Tag}); len(tags) > 0 {
+		discount.Tag = tags[0]
+	}
+	discount.ProductIDs = DiscountPayload.ProductIDs
+	if discount.ProductIDs == nil {
+		discount.ProductIDs = []int64{}
+	}
+	discount.StartsAt = DiscountPayload.StartsAt
+	discount.EndsAt = DiscountPayload.EndsAt
+	discount.Active = DiscountPayload.Active == nil || *DiscountPayload.Active
+	return nil
+}
+
+// listDiscountsHandler returns every discount, newest first.
+func (app *app) listDiscountsHandler(w http.ResponseWriter, r *http.Request) {
+	discounts, err := app.models.Discounts.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"discounts": discounts}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// createDiscountHandler adds a discount code.
+func (app *app) createDiscountHandler(w http.ResponseWriter, r *http.Request) {
+	discount := &data.Discount{CreatedBy: &app.contextGetUser(r).ID}
+	if err := app.readDiscount(w, r, discount); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateDiscount(v, discount); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Discounts.Insert(discount); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateDiscountCode):
+			v.AddError("code", "a discount with this code already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/admin/discounts/%d", discount.ID))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"discount": discount}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// getDiscountHandler returns a discount by ID.
+func (app *app) getDiscountHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	discount, err := app.models.Discounts.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"discount": discount}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// updateDiscountHandler replaces every field of a discount, so fields left out take the defaults
+// they have when a discount is created. Sales already made with it keep their amounts.
+func (app *app) updateDiscountHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	discount := &data.Discount{ID: id, UpdatedBy: &app.contextGetUser(r).ID}
+	if err := app.readDiscount(w, r, discount); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateDiscount(v, discount); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Discounts.Update(discount); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateDiscountCode):
+			v.AddError("code", "a discount with this code already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"discount": discount}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// deleteDiscountHandler removes a discount. Sales made with it keep its code and their amounts.
+func (app *app) deleteDiscountHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.models.Discounts.Delete(id); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusNoContent, nil, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
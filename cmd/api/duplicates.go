@@ -0,0 +1,56 @@
+// File: cmd/api/duplicates.go
+// Description: flagging likely duplicate sales, and the report listing them
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// duplicateSaleWarnings flags a newly recorded sale when the same user recorded the same products
+// and quantities within the duplicate window, and returns a warning for the response. Flagging
+// never blocks a sale, so failures are only logged.
+func (app *app) duplicateSaleWarnings(r *http.Request, sale *data.Sale) []string {
+	if app.config.duplicates.window <= 0 {
+		return nil
+	}
+
+	duplicateOf, err := app.models.Sales.FlagDuplicate(sale, app.config.duplicates.window)
+	if err != nil {
+		app.logger.Error("failed to check for a duplicate sale", "sale_id", sale.ID, "error", err)
+		return nil
+	}
+	if duplicateOf == nil {
+		return nil
+	}
+	return []string{fmt.Sprintf("possible duplicate of sale %d: the same items were sold by the same user within %s", *duplicateOf, app.config.duplicates.window)}
+}
+
+// duplicateSalesHandler lists the sales of a date range flagged as likely duplicates, newest
+// first, so they can be reviewed and the extra ones removed.
+func (app *app) duplicateSalesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	v := validator.New()
+
+	reportRange := app.readReportRange(query, app.contextGetUser(r), v)
+	filter := app.readFilters(query, "duplicate_sales", "-sold_at", []string{"-sold_at"}, v)
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	duplicates, metadata, err := app.modelsFor(r).Reports.DuplicateSales(reportRange, filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"duplicates": duplicates, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
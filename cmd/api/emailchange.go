@@ -0,0 +1,163 @@
+// File: cmd/api/emailchange.go
+// Description: handlers for changing a user's email address through a confirmation token
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/mailer"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// emailChangeTokenTTL is how long the confirmation link sent to the new address stays valid.
+const emailChangeTokenTTL = 24 * time.Hour
+
+// requestEmailChangeHandler stores a pending email for the authenticated user and sends a
+// confirmation token to the new address. The current email stays in use until it is confirmed.
+func (app *app) requestEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	// Without a mailer the confirmation token could never reach the new inbox
+	if app.mailer == nil {
+		app.mailerUnavailableResponse(w, r)
+		return
+	}
+
+	// EmailChangePayload struct to hold the incoming JSON payload
+	var EmailChangePayload struct {
+		Email    string `json:"email"`
+		Password string `json:"password"` // Optional when the session was opened within the reauth window
+	}
+
+	if err := app.readJSON(w, r, &EmailChangePayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	v := validator.New()
+	data.ValidateEmail(v, EmailChangePayload.Email)
+	v.Check(EmailChangePayload.Email != user.Email, "email", "must be different from the current email address")
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Re-check the password so a hijacked session cannot move the account to another inbox
+	confirmed, err := app.passwordConfirmed(user, EmailChangePayload.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !confirmed {
+		if EmailChangePayload.Password == "" {
+			app.passwordConfirmationRequiredResponse(w, r)
+		} else {
+			app.invalidCredentialsResponse(w, r)
+		}
+		return
+	}
+
+	_, err = app.models.Users.GetByEmail(EmailChangePayload.Email)
+	switch {
+	case err == nil:
+		v.AddError("email", "a user with this email address already exists")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	case !errors.Is(err, data.ErrRecordNotFound):
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	throttled, retryAfter, err := app.emailThrottled(&user.ID, EmailChangePayload.Email, mailer.EmailChangeEmail{})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if throttled {
+		app.emailRateLimitResponse(w, r, retryAfter)
+		return
+	}
+
+	if err := app.models.Users.SetPendingEmail(user.ID, EmailChangePayload.Email); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// New replaces any earlier email change token, so only the latest request can be confirmed
+	token, err := app.models.Tokens.New(user.ID, emailChangeTokenTTL, data.ScopeEmailChange)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.sendEmail(&user.ID, EmailChangePayload.Email, user.Locale, mailer.EmailChangeEmail{
+		FirstName:    user.FirstName,
+		CurrentEmail: user.Email,
+		NewEmail:     EmailChangePayload.Email,
+		Token:        token.Plaintext,
+	})
+
+	if err := app.writeJSON(w, http.StatusAccepted, envelope{"message": "a confirmation token has been sent to the new email address"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// confirmEmailChangeHandler applies a pending email change using the token sent to the new address.
+func (app *app) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	// ConfirmEmailChangePayload struct to hold the incoming JSON payload
+	var ConfirmEmailChangePayload struct {
+		TokenPlaintext string `json:"token"`
+	}
+
+	if err := app.readJSON(w, r, &ConfirmEmailChangePayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, ConfirmEmailChangePayload.TokenPlaintext); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopeEmailChange, ConfirmEmailChangePayload.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired email change token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.models.Users.ConfirmEmailChange(user); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired email change token")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "a user with this email address already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.models.Tokens.DeleteAllForUser(data.ScopeEmailChange, user.ID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,172 @@
+// File: cmd/api/emails.go
+// Description: tracked email delivery and the mail provider webhook for bounces and complaints
+
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/mailer"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// Outgoing emails per template allowed to one address or user within emailThrottleWindow.
+const (
+	emailThrottleLimit  = 3
+	emailThrottleWindow = time.Hour
+)
+
+// emailThrottled reports whether the address or user already received emailThrottleLimit emails
+// of this kind within the window, and how long until the next one is allowed.
+func (app *app) emailThrottled(userID *int64, recipient string, msg mailer.Message) (bool, time.Duration, error) {
+	if app.mailer == nil {
+		return false, 0, nil
+	}
+
+	count, oldest, err := app.models.Emails.CountRecent(userID, recipient, msg.Template(), emailThrottleWindow)
+	if err != nil || count < emailThrottleLimit {
+		return false, 0, err
+	}
+	return true, max(time.Until(oldest.Add(emailThrottleWindow)), time.Second), nil
+}
+
+// sendEmail records a template under a fresh Message-ID and sends it in the background. The
+// record is written before returning so emailThrottled sees it straight away. Suppressed
+// addresses are recorded but not mailed. userID may be nil for people without an account yet,
+// such as invitees. The message is rendered in the locale when it has been translated, and
+// any attachments are sent with it.
+func (app *app) sendEmail(userID *int64, recipient, locale string, msg mailer.Message, attachments ...mailer.Attachment) {
+	if app.mailer == nil {
+		return
+	}
+
+	templateName := msg.Template()
+	logger := app.logger.With("recipient", recipient, "template", templateName)
+
+	messageID, err := data.NewMessageID(app.mailer.Domain())
+	if err != nil {
+		logger.Error("failed to generate message id", "error", err)
+		return
+	}
+	email := &data.Email{
+		MessageID: messageID,
+		UserID:    userID,
+		Recipient: recipient,
+		Template:  templateName,
+	}
+
+	suppressed, err := app.models.Emails.IsSuppressed(recipient)
+	if err != nil {
+		logger.Error("failed to check email suppression", "error", err)
+	}
+	if suppressed {
+		email.Status = data.EmailSuppressed
+		if err := app.models.Emails.Insert(email); err != nil {
+			logger.Error("failed to record suppressed email", "error", err)
+		}
+		logger.Warn("email not sent because the address is suppressed")
+		return
+	}
+
+	// A failed insert only loses tracking, the message is still worth sending
+	if err := app.models.Emails.Insert(email); err != nil {
+		logger.Error("failed to record email", "error", err)
+	}
+
+	app.background(func() {
+		status, errorText := data.EmailSent, ""
+		if err := app.mailer.SendMessage(messageID, recipient, locale, msg, attachments...); err != nil {
+			logger.Error("failed to send email", "error", err)
+			status, errorText = data.EmailFailed, err.Error()
+		}
+		if _, err := app.models.Emails.SetStatus(messageID, status, errorText); err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			logger.Error("failed to update email status", "error", err)
+		}
+	})
+}
+
+// emailWebhookHandler receives delivery, bounce and complaint callbacks from the mail provider.
+// Hard bounces and complaints suppress the address, which then shows as email_undeliverable on the user.
+func (app *app) emailWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	secret := app.config.smtp.webhookSecret
+	if secret == "" {
+		app.notFoundResponse(w, r)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Webhook-Secret")), []byte(secret)) != 1 {
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	// EmailEventPayload struct to hold the incoming JSON payload
+	var EmailEventPayload struct {
+		MessageID  string `json:"message_id"`
+		Event      string `json:"event"`       // delivered, bounce or complaint
+		BounceType string `json:"bounce_type"` // hard or soft, only for bounces
+		Email      string `json:"email"`
+		Reason     string `json:"reason"`
+	}
+
+	if err := app.readJSON(w, r, &EmailEventPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	statuses := map[string]string{
+		"delivered": data.EmailDelivered,
+		"bounce":    data.EmailBounced,
+		"complaint": data.EmailComplained,
+	}
+
+	v := validator.New()
+	_, known := statuses[EmailEventPayload.Event]
+	v.Check(known, "event", "must be one of delivered, bounce or complaint")
+	v.Check(EmailEventPayload.MessageID != "" || EmailEventPayload.Email != "", "message_id", "must be provided when email is not")
+	if EmailEventPayload.Event == "bounce" {
+		v.Check(v.Permitted(EmailEventPayload.BounceType, "hard", "soft"), "bounce_type", "must be hard or soft")
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	recipient := EmailEventPayload.Email
+	if EmailEventPayload.MessageID != "" {
+		tracked, err := app.models.Emails.SetStatus(EmailEventPayload.MessageID, statuses[EmailEventPayload.Event], EmailEventPayload.Reason)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			// Mail sent before tracking existed, fall back to the address in the payload
+		case err != nil:
+			app.serverErrorResponse(w, r, err)
+			return
+		default:
+			recipient = tracked
+		}
+	}
+
+	var reason string
+	switch {
+	case EmailEventPayload.Event == "complaint":
+		reason = data.SuppressComplaint
+	case EmailEventPayload.Event == "bounce" && EmailEventPayload.BounceType == "hard":
+		reason = data.SuppressBounce
+	}
+
+	if reason != "" && recipient != "" {
+		if err := app.models.Emails.Suppress(recipient, reason, EmailEventPayload.MessageID); err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.logger.Warn("email address suppressed", "recipient", recipient, "reason", reason)
+	}
+
+	// Providers retry anything but a 2xx, so unknown messages are acknowledged as well
+	if err := app.writeJSON(w, http.StatusOK, envelope{"message": "event processed"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,98 @@
+// File: cmd/api/emails_test.go
+// Description: test suite for the mail provider webhook guards and validation
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/mailer"
+)
+
+// TestEmailWebhookGuards covers the checks that run before any database access
+func TestEmailWebhookGuards(t *testing.T) {
+	tests := []struct {
+		name           string
+		secret         string
+		header         string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "Disabled Without Secret",
+			secret:         "",
+			header:         "anything",
+			body:           `{"event": "delivered", "message_id": "<a@b>"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Wrong Secret",
+			secret:         "s3cret",
+			header:         "guess",
+			body:           `{"event": "delivered", "message_id": "<a@b>"}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Unknown Event",
+			secret:         "s3cret",
+			header:         "s3cret",
+			body:           `{"event": "opened", "message_id": "<a@b>"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Bounce Without Type",
+			secret:         "s3cret",
+			header:         "s3cret",
+			body:           `{"event": "bounce", "email": "user@example.com"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Missing Message ID And Email",
+			secret:         "s3cret",
+			header:         "s3cret",
+			body:           `{"event": "complaint"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp()
+			app.config.smtp.webhookSecret = tt.secret
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/webhooks/email", bytes.NewBufferString(tt.body))
+			req.Header.Set("X-Webhook-Secret", tt.header)
+			rr := httptest.NewRecorder()
+			app.emailWebhookHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+// TestEmailRateLimitResponse checks the 429 and its Retry-After rounding
+func TestEmailRateLimitResponse(t *testing.T) {
+	app := newTestApp()
+
+	rr := httptest.NewRecorder()
+	app.emailRateLimitResponse(rr, httptest.NewRequest(http.MethodPost, "/v1/users/email-change", nil), 90*time.Second+300*time.Millisecond)
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "91" {
+		t.Errorf("expected Retry-After 91, got %q", got)
+	}
+
+	// Without a mailer nothing is sent, so nothing is throttled
+	throttled, _, err := app.emailThrottled(nil, "user@example.com", mailer.InvitationEmail{})
+	if err != nil || throttled {
+		t.Errorf("expected no throttling without a mailer, got %v, %v", throttled, err)
+	}
+}
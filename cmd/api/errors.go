@@ -3,21 +3,73 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
 )
 
 /************************************************************************************************************/
 // General helper functions for error handling
 /************************************************************************************************************/
 
-// logs the error message along with the request method and URL
+// logs the error message along with the request method and URL, and the model operation that
+// failed when the error came from the data layer
 func (app *app) logError(r *http.Request, err error) {
-	method := r.Method                                          // get the HTTP method
-	uri := r.URL.RequestURI()                                   // get the request URI
+	method := r.Method        // get the HTTP method
+	uri := r.URL.RequestURI() // get the request URI
+	var domainErr *data.DomainError
+	if errors.As(err, &domainErr) {
+		app.logger.Error(err.Error(), "method", method, "uri", uri, "entity", domainErr.Entity, "operation", domainErr.Operation(), "cause", domainErr.Err.Error())
+		return
+	}
 	app.logger.Error(err.Error(), "method", method, "uri", uri) // log the error with method and URI
 }
 
+// unknownOperation labels server errors that did not come from a model operation.
+const unknownOperation = "unknown"
+
+// failureCounters counts server errors since the process started by the model operation that
+// failed. The zero value is ready to use.
+type failureCounters struct {
+	mu     sync.Mutex
+	counts map[string]int64 // errors by operation, such as "sale.Insert"
+}
+
+// add counts one server error caused by err.
+func (c *failureCounters) add(err error) {
+	operation := unknownOperation
+	var domainErr *data.DomainError
+	if errors.As(err, &domainErr) {
+		operation = domainErr.Operation()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts == nil {
+		c.counts = make(map[string]int64)
+	}
+	c.counts[operation]++
+}
+
+// snapshot returns the errors counted so far.
+func (c *failureCounters) snapshot() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := make(map[string]int64, len(c.counts))
+	for operation, count := range c.counts {
+		counts[operation] = count
+	}
+	return counts
+}
+
 // Sends an error response in JSON format
 func (app *app) errorResponseJSON(w http.ResponseWriter, r *http.Request, status int, message any) {
 	errorData := envelope{"error": message}         // wrap the message in an envelope
@@ -33,6 +85,12 @@ func (app *app) errorResponseJSON(w http.ResponseWriter, r *http.Request, status
 /************************************************************************************************************/
 // error response for total server failure with a 500 status code
 func (app *app) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	// A query cut short by the request deadline or a disconnect is not a server fault
+	if r.Context().Err() != nil {
+		app.deadlineExceededResponse(w, r)
+		return
+	}
+	app.serverErrors.add(err)                                                        // count it by failed operation
 	app.logError(r, err)                                                             // log the error
 	message := "the server encountered a problem and could not process your request" // client message
 	app.errorResponseJSON(w, r, http.StatusInternalServerError, message)             // send the error response
@@ -60,10 +118,24 @@ func (a *app) failedValidationResponse(w http.ResponseWriter, r *http.Request, e
 	a.errorResponseJSON(w, r, http.StatusUnprocessableEntity, errors)
 }
 
-// For rate limit exceeded errors with a 429 status code
-func (a *app) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+// error response for a sale whose products are priced in different currencies, reported as a failed validation check
+func (a *app) mixedCurrenciesResponse(w http.ResponseWriter, r *http.Request) {
+	a.failedValidationResponse(w, r, mixedCurrenciesErrors)
+}
+
+// error response for a sort value outside a listing's safelist, reported as a failed validation check
+func (a *app) invalidSortResponse(w http.ResponseWriter, r *http.Request) {
+	a.failedValidationResponse(w, r, map[string]string{"sort": data.ErrInvalidSort.Error()})
+}
+
+// For rate limit exceeded errors with a 429 status code, including the client's limiter state
+func (a *app) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, state rateLimitState) {
 	message := "rate limit exceeded"
-	a.errorResponseJSON(w, r, http.StatusTooManyRequests, message)
+	err := a.writeJSON(w, http.StatusTooManyRequests, envelope{"error": message, "rate_limit": state}, nil)
+	if err != nil {
+		a.logError(r, err)
+		w.WriteHeader(500)
+	}
 }
 
 // for edit conflict status 409
@@ -78,6 +150,12 @@ func (a *app) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request)
 	a.errorResponseJSON(w, r, http.StatusUnauthorized, message)
 }
 
+// Return a 401 status code when a sensitive action needs the password confirmed
+func (a *app) passwordConfirmationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this action requires your current password or a login within the last few minutes"
+	a.errorResponseJSON(w, r, http.StatusUnauthorized, message)
+}
+
 // Return an authentication required status code 401
 func (a *app) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("WWW-Authenticate", "Bearer")
@@ -85,6 +163,12 @@ func (a *app) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.
 	a.errorResponseJSON(w, r, http.StatusUnauthorized, message)
 }
 
+// Return a 401 status code when the second authentication factor is missing
+func (a *app) twoFactorRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a two-factor authentication code is required"
+	a.errorResponseJSON(w, r, http.StatusUnauthorized, message)
+}
+
 // Return an authentication required status code 401
 func (a *app) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
 	message := "you must be authenticated to access this resource"
@@ -97,6 +181,18 @@ func (a *app) inactiveAccountResponse(w http.ResponseWriter, r *http.Request) {
 	a.errorResponseJSON(w, r, http.StatusForbidden, message)
 }
 
+// Return a 504 status code when the request's deadline passed before it could be served
+func (a *app) deadlineExceededResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the request could not be completed before its deadline"
+	a.errorResponseJSON(w, r, http.StatusGatewayTimeout, message)
+}
+
+// Return a 503 status code
+func (a *app) serviceUnavailableResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the service is temporarily unavailable, please try again later"
+	a.errorResponseJSON(w, r, http.StatusServiceUnavailable, message)
+}
+
 // Return a 403 status code
 func (a *app) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
 	message := "you do not have the necessary permissions to access this resource"
@@ -108,3 +204,101 @@ func (a *app) conflictResponse(w http.ResponseWriter, r *http.Request) {
 	message := "the request could not be completed due to a conflict with the current state of the resource"
 	a.errorResponseJSON(w, r, http.StatusConflict, message)
 }
+
+// Return a 409 status code
+func (a *app) bootstrappedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the API has already been set up, log in as an admin to create users"
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+// Return a 409 status code
+func (a *app) roleInUseResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the role is still assigned to one or more users"
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+// Return a 409 status code
+func (a *app) systemRoleResponse(w http.ResponseWriter, r *http.Request) {
+	message := "built in roles cannot be renamed or deleted"
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+// Return a 409 status code
+func (a *app) lastAdminResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the last active admin cannot be demoted, deactivated or deleted"
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+// Return a 503 status code
+func (a *app) mailerUnavailableResponse(w http.ResponseWriter, r *http.Request) {
+	message := "email delivery is not configured on this server"
+	a.errorResponseJSON(w, r, http.StatusServiceUnavailable, message)
+}
+
+// Return a 503 status code
+func (a *app) storageUnavailableResponse(w http.ResponseWriter, r *http.Request) {
+	message := "file storage is not configured on this server"
+	a.errorResponseJSON(w, r, http.StatusServiceUnavailable, message)
+}
+
+// Return a 409 status code
+func (a *app) insufficientStockResponse(w http.ResponseWriter, r *http.Request) {
+	message := "there is not enough stock of this product left"
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+// Return a 409 status code
+func (a *app) mailerConfiguredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "activation tokens are sent by email and cannot be issued here while email delivery is configured"
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+// Return a 409 status code
+func (a *app) saleRefundedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a sale cannot be edited once it has been refunded, record another sale or refund instead"
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+// Return a 409 status code
+func (a *app) saleVoidedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a voided sale cannot be changed, record another sale instead"
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+// Return a 409 status code
+func (a *app) saleNotCompletedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "only completed sales can be paid or refunded, complete the draft first"
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+// Return a 409 status code
+func (a *app) saleNotDraftResponse(w http.ResponseWriter, r *http.Request) {
+	message := "only draft sales can be completed"
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+// Return a 409 status code
+func (a *app) salePaidResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a sale that has been paid or refunded cannot be voided, refund it instead"
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+// Return a 409 status code
+func (a *app) scheduleRunningResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this schedule is already running, please wait for the run to finish"
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+// Return a 429 status code when too many emails were requested for one address or user
+func (a *app) emailRateLimitResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	message := "too many emails have been requested for this address, please try again later"
+	a.errorResponseJSON(w, r, http.StatusTooManyRequests, message)
+}
+
+// Return a 429 status code when an email address or client IP made too many login attempts
+func (a *app) loginThrottledResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	message := "too many login attempts, please try again later"
+	a.errorResponseJSON(w, r, http.StatusTooManyRequests, message)
+}
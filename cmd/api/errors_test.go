@@ -0,0 +1,57 @@
+// File: cmd/api/errors_test.go
+// Description: test suite for the server error logging and counters
+
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+)
+
+// TestServerErrorsByOperation tests that server errors are logged with the model operation that
+// failed and counted under it, with errors from elsewhere counted as unknown
+func TestServerErrorsByOperation(t *testing.T) {
+	logs := new(bytes.Buffer)
+	app := newTestApp()
+	app.logger = slog.New(slog.NewTextHandler(logs, nil))
+
+	failures := []error{
+		&data.DomainError{Entity: "sale", Op: "Insert", Err: sql.ErrConnDone},
+		&data.DomainError{Entity: "sale", Op: "Insert", Err: sql.ErrConnDone},
+		errors.New("template missing"),
+	}
+	for _, err := range failures {
+		rr := httptest.NewRecorder()
+		app.serverErrorResponse(rr, httptest.NewRequest(http.MethodPost, "/v1/sales", nil), err)
+		if rr.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status 500, got %d", rr.Code)
+		}
+	}
+
+	for _, want := range []string{"operation=sale.Insert", "entity=sale", `cause="sql: connection is already closed"`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("expected %s in the log, got %s", want, logs.String())
+		}
+	}
+
+	counts := app.serverErrors.snapshot()
+	if counts["sale.Insert"] != 2 || counts[unknownOperation] != 1 {
+		t.Errorf("expected 2 sale.Insert and 1 unknown errors, got %v", counts)
+	}
+
+	var out strings.Builder
+	if err := writeServerErrors(&out, counts); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `server_errors_total{operation="sale.Insert"} 2`) {
+		t.Errorf("metrics are missing the sale.Insert count:\n%s", out.String())
+	}
+}
@@ -6,12 +6,16 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/notify"
 	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
@@ -19,8 +23,19 @@ import (
 // creating an envelope type
 type envelope map[string]any
 
+// writeJSON writes data as an indented JSON response. A 204 No Content response is written with
+// the headers only, since it must not carry a body, and data is ignored.
 func (a *app) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
 
+	// no content responses have no body and so no content type
+	if status == http.StatusNoContent {
+		for key, value := range headers {
+			w.Header()[key] = value
+		}
+		w.WriteHeader(status)
+		return nil
+	}
+
 	// encodes data into json format by using indenting for better readability
 	jsResponse, err := json.MarshalIndent(data, "", "\t")
 	if err != nil {
@@ -134,6 +149,15 @@ func (a *app) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+// now returns the current time from the app's clock, so tests can freeze it. Durations such as
+// request latency keep using the real time.
+func (app *app) now() time.Time {
+	if app.clock == nil {
+		return time.Now()
+	}
+	return app.clock.Now()
+}
+
 /************************************************************************************************************/
 // Helper functions for reading URL parameters
 /************************************************************************************************************/
@@ -208,8 +232,8 @@ func (app *app) getSingleDateQueryParameter(params url.Values, key string, defau
 		return defaultValue // return the default value if the parameter is not found
 	}
 
-	// Validate the date format (assuming "YYYY-MM-DD" format)
-	if _, err := strconv.ParseInt(strings.ReplaceAll(result, "-", ""), 10, 64); err != nil || len(result) != 10 {
+	// Validate the date format and that the day exists, "2024-13-45" is rejected
+	if _, err := time.Parse(time.DateOnly, result); err != nil {
 		v.AddError(key, "must be a valid date in YYYY-MM-DD format") // add a validation error if format is incorrect
 		return defaultValue                                          // return the default value in case of error
 	}
@@ -249,11 +273,14 @@ func (app *app) getOptionalInt64QueryParameter(params url.Values, key string, v
 	return &i
 }
 
-// readFilters constructs a Filters struct using standard query parameters and validates it.
-func (app *app) readFilters(query url.Values, defaultSort string, defaultPageSize int64, safelist []string, v *validator.Validator) data.Filter {
+// readFilters constructs a Filters struct using standard query parameters and validates it. The
+// page size defaults to, and is limited by, the page policy of the resource being listed.
+func (app *app) readFilters(query url.Values, resource, defaultSort string, safelist []string, v *validator.Validator) data.Filter {
+	policy := app.pagePolicy(resource)
 	filters := data.Filter{
 		Page:         app.getSingleIntQueryParameter(query, "page", 1, v),
-		PageSize:     app.getSingleIntQueryParameter(query, "page_size", defaultPageSize, v),
+		PageSize:     app.getSingleIntQueryParameter(query, "page_size", policy.Default, v),
+		MaxPageSize:  policy.Max,
 		SortBy:       app.getSingleQueryParameter(query, "sort", defaultSort),
 		SortSafeList: safelist,
 	}
@@ -282,3 +309,55 @@ func (app *app) background(fn func()) {
 		fn() // execute the provided function
 	}()
 }
+
+// alert sends a critical alert to administrators in the background. Failures are only logged,
+// the alert is never worth failing a request over.
+func (app *app) alert(subject, body string) {
+	if !app.notifier.Enabled() {
+		return
+	}
+	app.background(func() {
+		if err := app.notifier.Send(notify.Alert{Subject: subject, Body: body}); err != nil {
+			app.logger.Error("failed to send alert", "subject", subject, slog.Any("error", err))
+		}
+	})
+}
+
+// autoActivate reports whether new registrations skip email activation, which only happens in
+// development when no mailer is configured.
+func (app *app) autoActivate() bool {
+	return app.mailer == nil && app.config.env == "development"
+}
+
+// clientIP returns the host part of the request's remote address.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// parseNetwork parses a CIDR such as 10.0.0.0/8, or a single address as a network of its own.
+func parseNetwork(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix.Masked(), nil
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
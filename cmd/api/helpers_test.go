@@ -0,0 +1,226 @@
+// File: cmd/api/helpers_test.go
+// Description: fuzz targets for request body and query parameter parsing, sort validation and response writing tests
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// FuzzReadJSON checks that readJSON never panics and only accepts a single well-formed value
+func FuzzReadJSON(f *testing.F) {
+	seeds := []string{
+		`{"name": "Widget", "price": 9.99}`,
+		`{"name": "Widget"}{"name": "Gadget"}`,
+		`{"name": 5}`,
+		`{"unknown": true}`,
+		`{"name": "Widget",`,
+		`[1, 2, 3]`,
+		`null`,
+		``,
+		`<?xml version="1.0"?>`,
+		strings.Repeat(`{"name": "`, 1000),
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	app := newTestApp()
+	f.Fuzz(func(t *testing.T, body string) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		var dest struct {
+			Name  string  `json:"name"`
+			Price float64 `json:"price"`
+		}
+		err := app.readJSON(rr, req, &dest)
+		if err == nil && !json.Valid([]byte(strings.TrimSpace(body))) {
+			t.Errorf("accepted invalid JSON body %q", body)
+		}
+		if err != nil && err.Error() == "" {
+			t.Errorf("returned an empty error message for body %q", body)
+		}
+	})
+}
+
+// FuzzReadFilters checks that any accepted filter can be turned into SQL without panicking
+func FuzzReadFilters(f *testing.F) {
+	f.Add("1", "20", "id")
+	f.Add("0", "0", "")
+	f.Add("501", "101", "-price")
+	f.Add("-1", "abc", "name;DROP TABLE products")
+	f.Add("9223372036854775807", "100", "-id")
+	f.Add("1e3", " 5", "--id")
+
+	app := newTestApp()
+	safelist := []string{"id", "name", "price", "-id", "-name", "-price"}
+	f.Fuzz(func(t *testing.T, page, pageSize, sort string) {
+		query := url.Values{"page": {page}, "page_size": {pageSize}, "sort": {sort}}
+		v := validator.New()
+		filters := app.readFilters(query, "products", "id", safelist, v)
+		if !v.IsValid() {
+			return
+		}
+
+		if filters.Page < 1 || filters.Page > 500 || filters.PageSize < 1 || filters.PageSize > filters.MaxPageSize {
+			t.Errorf("accepted out of range pagination page=%d page_size=%d", filters.Page, filters.PageSize)
+		}
+		if filters.Offset() < 0 {
+			t.Errorf("negative offset %d", filters.Offset())
+		}
+		column, err := filters.SortColumn()
+		if err != nil {
+			t.Fatalf("accepted sort %q that cannot be used in a query: %v", sort, err)
+		}
+		if strings.HasPrefix(column, "-") {
+			t.Errorf("sort column %q keeps the direction prefix", column)
+		}
+		if direction := filters.SortDirection(); direction != "ASC" && direction != "DESC" {
+			t.Errorf("unexpected sort direction %q", direction)
+		}
+	})
+}
+
+// TestReadFiltersRejectsUnsafeSort tests that sort values outside the safelist fail validation
+// instead of reaching a query
+func TestReadFiltersRejectsUnsafeSort(t *testing.T) {
+	app := newTestApp()
+	safelist := []string{"id", "name", "-id", "-name"}
+	tests := []string{
+		"name;DROP TABLE products",
+		"id DESC, (SELECT 1)",
+		"--id",
+		"-",
+		"ID",
+		" id",
+		"name\x00",
+		"(CASE WHEN 1=1 THEN id END)",
+	}
+
+	for _, sort := range tests {
+		t.Run(sort, func(t *testing.T) {
+			v := validator.New()
+			filters := app.readFilters(url.Values{"sort": {sort}}, "products", "id", safelist, v)
+			if v.IsValid() {
+				t.Fatalf("sort %q passed validation", sort)
+			}
+			if _, ok := v.Errors["sort"]; !ok {
+				t.Errorf("expected a sort error, got %v", v.Errors)
+			}
+			if _, err := filters.SortColumn(); !errors.Is(err, data.ErrInvalidSort) {
+				t.Errorf("expected ErrInvalidSort from SortColumn, got %v", err)
+			}
+		})
+	}
+}
+
+// FuzzQueryParameters checks that the typed query helpers agree with the standard library parsers
+func FuzzQueryParameters(f *testing.F) {
+	for _, seed := range []string{"2024-02-29", "2023-02-29", "2024-13-45", "2024-00-10", "0000-00-00", "20240101", "2024-1-01", "true", "FALSE", "yes", "42", "-7", "99999999999999999999", "0x10", " 1"} {
+		f.Add(seed)
+	}
+
+	app := newTestApp()
+	f.Fuzz(func(t *testing.T, value string) {
+		query := url.Values{"value": {value}}
+
+		v := validator.New()
+		date := app.getSingleDateQueryParameter(query, "value", "", v)
+		if _, err := time.Parse(time.DateOnly, value); value != "" && (err == nil) != v.IsValid() {
+			t.Errorf("date %q: parse error %v but valid=%v", value, err, v.IsValid())
+		}
+		if v.IsValid() && date != value {
+			t.Errorf("date %q was returned as %q", value, date)
+		}
+
+		v = validator.New()
+		b := app.getOptionalBoolQueryParameter(query, "value", v)
+		if parsed, err := strconv.ParseBool(value); value != "" && (err == nil) != v.IsValid() {
+			t.Errorf("bool %q: parse error %v but valid=%v", value, err, v.IsValid())
+		} else if err == nil && (b == nil || *b != parsed) {
+			t.Errorf("bool %q was returned as %v", value, b)
+		}
+
+		v = validator.New()
+		i := app.getSingleIntQueryParameter(query, "value", -1, v)
+		if parsed, err := strconv.ParseInt(value, 10, 64); value != "" && (err == nil) != v.IsValid() {
+			t.Errorf("int %q: parse error %v but valid=%v", value, err, v.IsValid())
+		} else if err == nil && i != parsed {
+			t.Errorf("int %q was returned as %d", value, i)
+		}
+
+		v = validator.New()
+		p := app.getOptionalInt64QueryParameter(query, "value", v)
+		if parsed, err := strconv.ParseInt(value, 10, 64); value != "" && (err == nil) != v.IsValid() {
+			t.Errorf("int64 %q: parse error %v but valid=%v", value, err, v.IsValid())
+		} else if err == nil && (p == nil || *p != parsed) {
+			t.Errorf("int64 %q was returned as %v", value, p)
+		}
+	})
+}
+
+// TestWriteJSONNoContent tests that a 204 response has no body or content type but keeps extra headers
+func TestWriteJSONNoContent(t *testing.T) {
+	app := newTestApp()
+	headers := make(http.Header)
+	headers.Set("X-Deleted-ID", "42")
+
+	rr := httptest.NewRecorder()
+	if err := app.writeJSON(rr, http.StatusNoContent, envelope{"message": "ignored"}, headers); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", rr.Code)
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("expected an empty body, got %q", rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "" {
+		t.Errorf("expected no content type, got %q", got)
+	}
+	if got := rr.Header().Get("X-Deleted-ID"); got != "42" {
+		t.Errorf("expected extra headers to be kept, got %q", got)
+	}
+}
+
+// TestParseNetwork tests that CIDRs are masked and single addresses become networks of their own
+func TestParseNetwork(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{input: "10.1.2.3/8", expected: "10.0.0.0/8"},
+		{input: "192.0.2.7", expected: "192.0.2.7/32"},
+		{input: "::ffff:192.0.2.7", expected: "192.0.2.7/32"},
+		{input: "2001:db8::/32", expected: "2001:db8::/32"},
+		{input: "10.0.0.0/33", wantErr: true},
+		{input: "internal", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseNetwork(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %s", tt.input, got)
+			}
+			continue
+		}
+		if err != nil || got.String() != tt.expected {
+			t.Errorf("%s: expected %s, got %s (%v)", tt.input, tt.expected, got, err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// File: cmd/api/invitations.go
+// Description: handlers for inviting staff who then choose their own password
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/mailer"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// invitationTTL is how long an invitation can be accepted.
+const invitationTTL = 7 * 24 * time.Hour
+
+// createInvitationHandler invites a person by email to join with the given role.
+func (app *app) createInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	// CreateInvitationPayload struct to hold the incoming JSON payload
+	var CreateInvitationPayload struct {
+		Email  string `json:"email"`
+		Role   string `json:"role"`
+		Locale string `json:"locale,omitempty"` // Optional - language of the email, defaults to the inviter's
+	}
+
+	if err := app.readJSON(w, r, &CreateInvitationPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	inviter := app.contextGetUser(r)
+	invitation := &data.Invitation{
+		Email:     CreateInvitationPayload.Email,
+		Role:      CreateInvitationPayload.Role,
+		InvitedBy: &inviter.ID,
+	}
+
+	v := validator.New()
+	data.ValidateEmail(v, invitation.Email)
+	v.Check(invitation.Role != "", "role", "must be provided")
+	if CreateInvitationPayload.Locale == "" {
+		CreateInvitationPayload.Locale = inviter.Locale
+	}
+	data.ValidateLocale(v, CreateInvitationPayload.Locale)
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Inviting someone straight into a role needs the same permissions as moving them into it. An
+	// unknown role is refused when the invitation is saved
+	role, err := app.models.Roles.GetByName(invitation.Role)
+	switch {
+	case err == nil:
+		permissions, err := app.models.Permissions.GetAllForUser(inviter.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !roleAssignable(permissions, role) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	case !errors.Is(err, data.ErrRecordNotFound):
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	_, err = app.models.Users.GetByEmail(invitation.Email)
+	switch {
+	case err == nil:
+		v.AddError("email", "a user with this email address already exists")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	case !errors.Is(err, data.ErrRecordNotFound):
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	throttled, retryAfter, err := app.emailThrottled(nil, invitation.Email, mailer.InvitationEmail{})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if throttled {
+		app.emailRateLimitResponse(w, r, retryAfter)
+		return
+	}
+
+	if err := app.models.Invitations.New(invitation, invitationTTL); err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidRole):
+			v.AddError("role", "must be one of the permitted values")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.sendEmail(nil, invitation.Email, CreateInvitationPayload.Locale, mailer.InvitationEmail{
+		Email:           invitation.Email,
+		Role:            invitation.Role,
+		InviterName:     fmt.Sprintf("%s %s", inviter.FirstName, inviter.LastName),
+		InvitationToken: invitation.Plaintext,
+	})
+
+	env := envelope{"invitation": invitation}
+	if app.mailer == nil {
+		// Nothing will reach the invitee, so the inviting admin gets the token to pass on
+		app.logger.Warn("invitation email not sent because SMTP is not configured", "email", invitation.Email)
+		env["invitation_token"] = invitation.Plaintext
+	}
+
+	if err := app.writeJSON(w, http.StatusCreated, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// acceptInvitationHandler creates an activated account from an invitation token and the invitee's own password.
+func (app *app) acceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	// AcceptInvitationPayload struct to hold the incoming JSON payload
+	var AcceptInvitationPayload struct {
+		TokenPlaintext string `json:"token"`
+		FirstName      string `json:"first_name"`
+		LastName       string `json:"last_name"`
+		Password       string `json:"password"`
+		Timezone       string `json:"timezone,omitempty"` // Optional - will default to UTC
+		Locale         string `json:"locale,omitempty"`   // Optional - will default to en
+	}
+
+	if err := app.readJSON(w, r, &AcceptInvitationPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, AcceptInvitationPayload.TokenPlaintext); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	invitation, err := app.models.Invitations.GetForToken(AcceptInvitationPayload.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired invitation token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user := &data.User{
+		FirstName: AcceptInvitationPayload.FirstName,
+		LastName:  AcceptInvitationPayload.LastName,
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		Timezone:  AcceptInvitationPayload.Timezone,
+		Locale:    AcceptInvitationPayload.Locale,
+	}
+	if err := user.Password.Set(AcceptInvitationPayload.Password); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if data.ValidateUser(v, user); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Invitations.Accept(invitation, user); err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidToken):
+			v.AddError("token", "invalid or expired invitation token")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "a user with this email address already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrInvalidRole):
+			v.AddError("role", "the invited role no longer exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/user/%d", user.ID))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"user": user}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,183 @@
+// File: cmd/api/limiter.go
+// Description: per-client rate limiter state and the background sweep that forgets idle clients
+
+package main
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Idle clients are swept every limiterSweepInterval and forgotten once unseen for limiterIdleTimeout.
+const (
+	limiterSweepInterval = time.Minute
+	limiterIdleTimeout   = 3 * time.Minute
+)
+
+// limitedClient is the token bucket of one client.
+type limitedClient struct {
+	limiter  *rate.Limiter // Rate limiter for the client
+	lastSeen time.Time     // Last time the client was seen
+}
+
+// clientLimiter gives every client its own token bucket, sized by limits when the client is first seen.
+type clientLimiter struct {
+	limits func() (rps float64, burst int)
+
+	mu      sync.Mutex
+	clients map[string]*limitedClient // buckets keyed by client address
+	evicted int64                     // clients forgotten after going idle
+}
+
+// allow spends one request from the client's bucket and returns whether it was allowed,
+// along with the budget left for the rate limit headers.
+func (l *clientLimiter) allow(key string, now time.Time) (bool, rateLimitState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	client, found := l.clients[key]
+	if !found {
+		rps, burst := l.limits()
+		client = &limitedClient{limiter: rate.NewLimiter(rate.Limit(rps), burst)}
+		l.clients[key] = client
+	}
+	client.lastSeen = now
+	allowed := client.limiter.AllowN(now, 1)
+	return allowed, newRateLimitState(client.limiter, now) // Snapshot the limiter so clients can pace themselves
+}
+
+// sweep forgets the clients that have not been seen for limiterIdleTimeout and returns how many were removed.
+func (l *clientLimiter) sweep(now time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	removed := 0
+	for key, client := range l.clients {
+		if now.Sub(client.lastSeen) > limiterIdleTimeout {
+			delete(l.clients, key)
+			removed++
+		}
+	}
+	l.evicted += int64(removed)
+	return removed
+}
+
+// rateLimiters owns the per-client limiters of the app, so their state lives as long as the app
+// rather than the router, and sweeps them while the server runs. The zero value is ready to use;
+// without Start nothing is swept, which suits tests that build many short-lived apps.
+type rateLimiters struct {
+	mu       sync.Mutex
+	limiters map[string]*clientLimiter // limiters by name, such as "global" or "suggest"
+	exempted map[string]int64          // requests let through without limiting, by exemption reason
+	stop     chan struct{}             // closed by Stop to end the sweep
+	done     chan struct{}             // closed when the sweep has returned
+}
+
+// limiterSnapshot is the state of one limiter published in metrics.
+type limiterSnapshot struct {
+	Clients int   `json:"clients"`
+	Evicted int64 `json:"evicted"`
+}
+
+// get returns the limiter with the given name, creating it with limits on first use.
+func (rl *rateLimiters) get(name string, limits func() (rps float64, burst int)) *clientLimiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.limiters == nil {
+		rl.limiters = make(map[string]*clientLimiter)
+	}
+	l, ok := rl.limiters[name]
+	if !ok {
+		l = &clientLimiter{limits: limits, clients: make(map[string]*limitedClient)}
+		rl.limiters[name] = l
+	}
+	return l
+}
+
+// Start sweeps idle clients from every limiter on the given interval until Stop is called.
+// Calling Start while a sweep is running does nothing.
+func (rl *rateLimiters) Start(interval time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.stop != nil {
+		return
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	rl.stop, rl.done = stop, done
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				for _, l := range rl.all() {
+					l.sweep(now)
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the sweep started by Start and waits for it to return.
+func (rl *rateLimiters) Stop() {
+	rl.mu.Lock()
+	stop, done := rl.stop, rl.done
+	rl.stop, rl.done = nil, nil
+	rl.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// all returns the limiters registered so far.
+func (rl *rateLimiters) all() []*clientLimiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return slices.Collect(maps.Values(rl.limiters))
+}
+
+// snapshot returns the number of tracked and evicted clients of each limiter.
+func (rl *rateLimiters) snapshot() map[string]limiterSnapshot {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	snapshot := make(map[string]limiterSnapshot, len(rl.limiters))
+	for name, l := range rl.limiters {
+		l.mu.Lock()
+		snapshot[name] = limiterSnapshot{Clients: len(l.clients), Evicted: l.evicted}
+		l.mu.Unlock()
+	}
+	return snapshot
+}
+
+// exempt counts a request let through without limiting for the given reason.
+func (rl *rateLimiters) exempt(reason string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.exempted == nil {
+		rl.exempted = make(map[string]int64)
+	}
+	rl.exempted[reason]++
+}
+
+// exemptions returns the number of requests let through without limiting, by reason.
+func (rl *rateLimiters) exemptions() map[string]int64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return maps.Clone(rl.exempted)
+}
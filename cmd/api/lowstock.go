@@ -0,0 +1,90 @@
+// File: cmd/api/lowstock.go
+// Description: low stock alerts by email and the low stock listing for dashboards
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/mailer"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// lowStockAlertsEnabled reports whether the low stock check runs, which needs an interval, a
+// mailer and someone to mail.
+func (app *app) lowStockAlertsEnabled() bool {
+	return app.config.lowStock.interval > 0 && app.mailer != nil && len(app.config.lowStock.recipients) > 0
+}
+
+// monitorLowStock checks stock against reorder thresholds on an interval until ctx is cancelled.
+// Checks are skipped while the database is unavailable.
+func (app *app) monitorLowStock(ctx context.Context) {
+	ticker := time.NewTicker(app.config.lowStock.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !app.readiness.ready() {
+			continue
+		}
+		if err := app.checkLowStock(); err != nil {
+			app.logger.Error("failed to check for low stock", slog.Any("error", err))
+		}
+	}
+}
+
+// checkLowStock emails the stock alert recipients one list of the products that fell below their
+// reorder threshold since the last check. Products already reported are left out until their
+// stock has been brought back to the threshold.
+func (app *app) checkLowStock() error {
+	products, err := app.models.Stock.ClaimLowStockAlerts()
+	if err != nil || len(products) == 0 {
+		return err
+	}
+
+	msg := mailer.LowStockEmail{Products: make([]mailer.LowStockItem, len(products))}
+	for i, product := range products {
+		msg.Products[i] = mailer.LowStockItem{
+			ID:               product.ID,
+			Name:             product.Name,
+			StockQuantity:    product.StockQuantity,
+			ReorderThreshold: product.ReorderThreshold,
+		}
+	}
+
+	app.logger.Warn("products below their reorder threshold", slog.Int("products", len(products)))
+	for _, recipient := range app.config.lowStock.recipients {
+		app.sendEmail(nil, recipient, "", msg)
+	}
+	return nil
+}
+
+// listLowStockHandler returns the products whose stock is below their reorder threshold,
+// largest shortfall first.
+func (app *app) listLowStockHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	filter := app.readFilters(r.URL.Query(), "low_stock", "-shortfall", []string{"-shortfall"}, v)
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	products, metadata, err := app.models.Stock.GetLowStock(filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"products": products, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
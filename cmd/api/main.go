@@ -5,19 +5,29 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/hex"
 	"expvar"
 	"flag"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"net/netip"
 	"os"
 	"regexp"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/Pedro-J-Kukul/salesapi/internal/data"
 	"github.com/Pedro-J-Kukul/salesapi/internal/mailer"
+	"github.com/Pedro-J-Kukul/salesapi/internal/metrics"
+	"github.com/Pedro-J-Kukul/salesapi/internal/notify"
+	"github.com/Pedro-J-Kukul/salesapi/internal/receipt"
+	"github.com/Pedro-J-Kukul/salesapi/internal/storage"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
 )
 
 // Application version
@@ -25,21 +35,49 @@ const version = "v0.3.0"
 
 // Server configuration settings
 type config struct {
-	port int    // server port
-	env  string // environment (development, staging, production)
-	db   struct {
-		dsn          string        // database source name
-		maxOpenConns int           // maximum number of open connections
-		maxIdleConns int           // maximum number of idle connections
-		maxIdleTime  time.Duration // maximum idle time for connections
+	port      int    // server port
+	adminPort int    // port of the operational listener for metrics, pprof and health checks, 0 disables it
+	adminAddr string // interface the operational listener binds to, loopback unless set
+	env       string // environment (development, staging, production)
+
+	baseCurrency string // currency exchange rates and revenue targets are quoted in
+
+	requestTimeoutMax time.Duration // longest deadline a client may ask for with X-Request-Timeout, 0 ignores the header
+
+	db struct {
+		dsn            string        // database source name
+		maxOpenConns   int           // maximum number of open connections
+		maxIdleConns   int           // maximum number of idle connections
+		maxIdleTime    time.Duration // maximum idle time for connections
+		healthInterval time.Duration // how often the database is pinged for readiness
+		healthTimeout  time.Duration // how long a readiness ping may take
+		timeouts       data.Timeouts // query timeouts per class of operation
 	}
 	cors struct {
-		trustedOrigins []string // list of trusted CORS origins
+		trustedOrigins   []string      // list of trusted CORS origins
+		exposedHeaders   []string      // response headers browser clients may read
+		allowCredentials bool          // whether browsers may send cookies and credentials
+		maxAge           time.Duration // how long browsers may cache a preflight response, 0 to not send it
 	}
 	limiter struct {
 		rps     float64 // requests per second
 		burst   int     // burst size
 		enabled bool    // whether the limiter is enabled
+
+		suggestRPS   float64 // requests per second for product suggestions
+		suggestBurst int     // burst size for product suggestions
+
+		receiptEmailRPS   float64 // receipt emails per second a client may send on request
+		receiptEmailBurst int     // burst size for receipt emails sent on request
+
+		loginEnabled    bool          // whether login attempts are throttled per email and client IP
+		loginAttempts   int           // login attempts allowed per minute for each email and client IP
+		loginBackoff    time.Duration // lockout after the second consecutive failed login, doubled on every further failure
+		loginMaxBackoff time.Duration // longest lockout after failed logins
+
+		exemptPaths    []string       // route prefixes never rate limited, such as health checks
+		exemptNetworks []netip.Prefix // client networks never rate limited, such as internal services
+		exemptKeys     []string       // X-API-Key values of internal services that are never rate limited
 	}
 	smtp struct {
 		host     string // SMTP host
@@ -47,10 +85,60 @@ type config struct {
 		username string // SMTP username
 		password string // SMTP password
 		sender   string // SMTP sender address
+
+		webhookSecret string // shared secret the mail provider sends with delivery callbacks
 	}
 	github struct {
 		token string // GitHub API token
 	}
+	sms struct {
+		accountSID string   // Twilio account SID
+		authToken  string   // Twilio auth token
+		from       string   // Twilio number messages are sent from
+		alertTo    []string // phone numbers that receive critical alerts
+	}
+	totp struct {
+		encryptionKey []byte // AES key used to encrypt stored TOTP secrets
+		issuer        string // issuer name shown in authenticator apps
+	}
+	tokens struct {
+		activationTTL     time.Duration // how long an emailed activation token stays valid
+		authenticationTTL time.Duration // how long a login session stays valid
+	}
+	passwordPolicy  data.PasswordPolicy  // complexity rules for new passwords
+	passwordHashing data.PasswordHashing // algorithm and cost for new password hashes
+	selftest        struct {
+		enabled    bool   // run the end-to-end smoke test instead of serving
+		migrations string // directory of the migrations applied to the temporary schema
+	}
+	pagination map[string]data.PagePolicy // default and maximum page size of each listing
+	lowStock   struct {
+		interval   time.Duration // how often stock is checked against reorder thresholds, 0 to not check
+		recipients []string      // email addresses alerted about low stock
+	}
+	scheduler struct {
+		interval time.Duration // how often due schedules are looked for, 0 to run none
+	}
+	storage struct {
+		dir      string // directory uploaded files are kept in, empty to refuse uploads
+		maxBytes int64  // largest file that may be attached to a sale
+	}
+	returns struct {
+		windowDays int // days after a sale it may be refunded without overriding the policy, 0 for no limit
+	}
+	duplicates struct {
+		window time.Duration // how close together identical sales by one user are flagged as duplicates, 0 to not flag
+	}
+	store        receipt.Store // store details printed at the top of receipts
+	reauthWindow time.Duration // how long after logging in sensitive actions go ahead without the password
+	chaos        struct {
+		enabled        bool          // inject faults into requests, refused in production
+		latencyPercent float64       // percentage of requests delayed
+		latency        time.Duration // how long a delayed request waits
+		errorPercent   float64       // percentage of requests answered with errorStatus
+		errorStatus    int           // status code of injected errors
+		dropPercent    float64       // percentage of requests whose connection is closed without a response
+	}
 }
 
 type app struct {
@@ -59,6 +147,25 @@ type app struct {
 	wg     sync.WaitGroup // wait group for managing goroutines
 	models data.Models
 	mailer *mailer.Mailer
+
+	notifier *notify.Notifier // critical alerts to administrators, nil-safe
+	files    storage.Store    // uploaded files such as sale attachments, nil when not configured
+
+	db        *sql.DB   // connection pool, pinged for readiness
+	readiness readiness // database readiness fed by monitorDatabase
+
+	limiters   rateLimiters // per-client rate limiter state, swept while serving
+	authEvents authCounters // authentication outcomes since startup
+
+	serverErrors failureCounters // 500 responses since startup by the model operation that failed
+
+	suggestions suggestionCache // short lived cache of product suggestion results
+	statuses    statusCache     // short lived cache of the public status report
+
+	requestDurations *metrics.Histogram // HTTP latency by method and status class
+	queryDurations   *metrics.Histogram // database latency by query class
+
+	clock data.Clock // the current time for handlers, shared with the models; the system clock when nil
 }
 
 func main() {
@@ -74,7 +181,10 @@ func main() {
 	logger.Info("database connection pool established") // log successful database connection
 
 	// For metrics
-	expvar.NewString("version").Set(version) // publish the application version
+	build := readBuildInfo()
+	expvar.Publish("build", expvar.Func(func() interface{} {
+		return build // publish the version, commit, build time and Go version
+	}))
 	expvar.Publish("goroutines", expvar.Func(func() interface{} {
 		return runtime.NumGoroutine() // publish the number of active goroutines
 	}))
@@ -85,17 +195,117 @@ func main() {
 		return time.Now().Unix() // publish the current Unix timestamp
 	}))
 
+	requestDurations := metrics.NewHistogram("http_request_duration_seconds", "HTTP request latency by method and status class.", "route", nil)
+	queryDurations := metrics.NewHistogram("db_query_duration_seconds", "Database query latency by query class.", "class", nil)
+	expvar.Publish("http_request_duration_seconds", requestDurations) // publish request latency histograms
+	expvar.Publish("db_query_duration_seconds", queryDurations)       // publish query latency histograms
+	cfg.db.timeouts.Observe = func(class data.QueryClass, elapsed time.Duration) {
+		queryDurations.Observe(class.String(), elapsed)
+	}
+
+	if err := data.SetPasswordPolicy(cfg.passwordPolicy); err != nil {
+		logger.Error("invalid password policy", slog.Any("error", err))
+		os.Exit(1)
+	}
+	if err := data.SetPasswordHashing(cfg.passwordHashing); err != nil {
+		logger.Error("invalid password hashing configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
 	// Initialize the application dependencies
 	app := &app{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
+		config:           cfg,
+		logger:           logger,
+		models:           data.NewModels(db, cfg.db.timeouts).WithClock(data.SystemClock),
+		db:               db,
+		requestDurations: requestDurations,
+		queryDurations:   queryDurations,
+		clock:            data.SystemClock,
+	}
+
+	app.models.ChatbotModel.Token = cfg.github.token // falls back to GITHUB_TOKEN when the flag is empty
+
+	// The smoke test brings its own schema and needs no mailer, so it runs before either is set up
+	if cfg.selftest.enabled {
+		code := app.runSelfTest(os.Stdout)
+		db.Close()
+		os.Exit(code)
+	}
+
+	expvar.Publish("readiness", expvar.Func(func() interface{} {
+		return app.readiness.snapshot() // publish the database readiness state
+	}))
+	expvar.Publish("rate_limiters", expvar.Func(func() interface{} {
+		return app.limiters.snapshot() // publish the clients tracked by each rate limiter
+	}))
+	expvar.Publish("rate_limiter_exemptions", expvar.Func(func() interface{} {
+		return app.limiters.exemptions() // publish the requests let through the rate limiters
+	}))
+	expvar.Publish("auth_events", expvar.Func(func() interface{} {
+		return app.authEvents.snapshot() // publish the authentication outcomes since startup
+	}))
+	expvar.Publish("server_errors", expvar.Func(func() interface{} {
+		return app.serverErrors.snapshot() // publish the server errors by failed operation
+	}))
+
+	// Handlers check codes from the catalog, so each of them must exist before serving
+	if err := app.models.Permissions.Seed(); err != nil {
+		logger.Error("failed to seed permissions", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	if bootstrapped, err := app.models.Bootstrap.Completed(); err != nil {
+		logger.Error("failed to check the bootstrap state", slog.Any("error", err))
+		os.Exit(1)
+	} else if !bootstrapped {
+		logger.Warn("NO USERS YET: POST /v1/bootstrap to create the first admin before anyone else does")
 	}
 
-	if cfg.smtp.host != "" && cfg.smtp.sender != "" {
+	// Broken templates fail here rather than on the first email
+	if err := mailer.ValidateTemplates(); err != nil {
+		logger.Error("invalid email template", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	if app.sandbox() {
+		// Nothing leaves the machine, so demos and frontend development need no credentials
+		app.useSandboxIntegrations()
+		logger.Warn("SANDBOX MODE: emails and alerts are logged instead of sent and the chatbot gives canned responses")
+	} else if cfg.smtp.host != "" && cfg.smtp.sender != "" {
 		app.mailer = mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender)
+	} else {
+		// loadConfig refuses this in production, so only development and staging reach here
+		logger.Warn("SMTP IS NOT CONFIGURED: activation, invitation and email change messages will not be sent")
+		if app.autoActivate() {
+			logger.Warn("development mode without SMTP: new registrations are activated immediately")
+		} else {
+			logger.Warn("issue activation tokens with POST /v1/user/:id/activation-token and invitation tokens from the POST /v1/invitations response")
+		}
 	}
 
+	// Alerts go out over every channel that has credentials
+	if !app.sandbox() {
+		var alertChannels []notify.Channel
+		if cfg.sms.accountSID != "" && cfg.sms.authToken != "" && cfg.sms.from != "" && len(cfg.sms.alertTo) > 0 {
+			alertChannels = append(alertChannels, notify.NewSMS(cfg.sms.accountSID, cfg.sms.authToken, cfg.sms.from, cfg.sms.alertTo))
+		}
+		app.notifier = notify.New(alertChannels...)
+	}
+	if app.notifier.Enabled() {
+		logger.Info("critical alerts enabled", "channels", app.notifier.Channels())
+	}
+
+	if cfg.storage.dir != "" {
+		files, err := storage.NewDir(cfg.storage.dir)
+		if err != nil {
+			logger.Error("unable to open the file storage directory", slog.Any("error", err))
+			os.Exit(1)
+		}
+		app.files = files
+	}
+
+	logger.Info("salesapi "+build.Version, "commit", build.Commit, "build_time", build.BuildTime, "go_version", build.GoVersion, "features", app.features())
+
 	err = app.serve() // start the HTTP server
 	if err != nil {
 		logger.Error("error starting server", slog.Any("error", err)) // log any error starting the server
@@ -106,25 +316,69 @@ func main() {
 // loadConfig loads configuration settings from command-line flags and environment variables.
 func loadConfig() config {
 	var cfg config
-	flag.IntVar(&cfg.port, "port", 4000, "API server port")                                        // server port
-	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)") // environment
+	flag.IntVar(&cfg.port, "port", 4000, "API server port")                                                // server port
+	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production|sandbox)") // environment
+
+	flag.IntVar(&cfg.adminPort, "admin-port", 0, "Port of a separate listener for metrics, pprof and health checks (0 disables it)") // operational port
+	flag.StringVar(&cfg.adminAddr, "admin-addr", "", "Interface the admin listener binds to (default 127.0.0.1)")                    // operational interface
+
+	flag.StringVar(&cfg.baseCurrency, "base-currency", data.DefaultCurrency, "Currency exchange rates and revenue targets are quoted in") // base currency
+
+	flag.DurationVar(&cfg.requestTimeoutMax, "request-timeout-max", 30*time.Second, "Longest deadline clients may set with X-Request-Timeout (0 ignores the header)") // request deadline cap
 
 	// Database settings
-	flag.StringVar(&cfg.db.dsn, "db-dsn", "", "PostgreSQL DSN")                                                   // database source name
-	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgreSQL max open connections")                 // max open connections
-	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgreSQL max idle connections")                 // max idle connections
-	flag.DurationVar(&cfg.db.maxIdleTime, "db-max-idle-time", time.Minute, "PostgreSQL max connection idle time") // max idle time
+	flag.StringVar(&cfg.db.dsn, "db-dsn", "", "PostgreSQL DSN")                                                                                    // database source name
+	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgreSQL max open connections")                                                  // max open connections
+	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgreSQL max idle connections")                                                  // max idle connections
+	flag.DurationVar(&cfg.db.maxIdleTime, "db-max-idle-time", time.Minute, "PostgreSQL max connection idle time")                                  // max idle time
+	flag.DurationVar(&cfg.db.healthInterval, "db-health-interval", 5*time.Second, "Interval between readiness pings")                              // readiness ping interval
+	flag.DurationVar(&cfg.db.healthTimeout, "db-health-timeout", 2*time.Second, "Timeout for each readiness ping")                                 // readiness ping timeout
+	flag.DurationVar(&cfg.db.timeouts.Read, "db-timeout-read", data.DefaultTimeouts.Read, "Timeout for lookups, lists and authentication queries") // read query timeout
+	flag.DurationVar(&cfg.db.timeouts.Write, "db-timeout-write", data.DefaultTimeouts.Write, "Timeout for inserts, updates and deletes")           // write query timeout
+	flag.DurationVar(&cfg.db.timeouts.Report, "db-timeout-report", data.DefaultTimeouts.Report, "Timeout for reports and bulk operations")         // report query timeout
+	flag.DurationVar(&cfg.db.timeouts.Export, "db-timeout-export", data.DefaultTimeouts.Export, "Timeout for exports")                             // export query timeout
 
 	// CORS settings
 	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(s string) error {
 		cfg.cors.trustedOrigins = strings.Fields(s) // split the input string by spaces and assign to trustedOrigins
 		return nil
 	})
+	cfg.cors.exposedHeaders = defaultExposedHeaders
+	flag.Func("cors-exposed-headers", "Response headers browser clients may read (space separated, default \""+strings.Join(defaultExposedHeaders, " ")+"\")", func(s string) error {
+		cfg.cors.exposedHeaders = strings.Fields(s)
+		return nil
+	})
+	flag.BoolVar(&cfg.cors.allowCredentials, "cors-allow-credentials", false, "Allow trusted origins to send cookies and credentials") // whether credentials are allowed
+	flag.DurationVar(&cfg.cors.maxAge, "cors-max-age", 0, "How long browsers may cache preflight responses (0 = browser default)")     // preflight cache time
 
 	// Rate limiter settings
-	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second") // requests per second
-	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")               // burst size
-	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")              // whether the limiter is enabled
+	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second")                                         // requests per second
+	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")                                                       // burst size
+	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")                                                      // whether the limiter is enabled
+	flag.Float64Var(&cfg.limiter.suggestRPS, "limiter-suggest-rps", 10, "Rate limiter maximum requests per second for product suggestions") // suggestion requests per second
+	flag.IntVar(&cfg.limiter.suggestBurst, "limiter-suggest-burst", 20, "Rate limiter maximum burst for product suggestions")               // suggestion burst size
+	flag.BoolVar(&cfg.limiter.loginEnabled, "limiter-login-enabled", true, "Throttle logins per email and client IP")                       // whether logins are throttled
+	flag.IntVar(&cfg.limiter.loginAttempts, "limiter-login-attempts", 5, "Login attempts per minute per email and IP")                      // login attempts per minute
+	flag.DurationVar(&cfg.limiter.loginBackoff, "limiter-login-backoff", time.Second, "Lockout after repeated failed logins")               // base lockout
+	flag.DurationVar(&cfg.limiter.loginMaxBackoff, "limiter-login-max-backoff", 15*time.Minute, "Longest login lockout")                    // lockout cap
+
+	flag.Float64Var(&cfg.limiter.receiptEmailRPS, "limiter-receipt-email-rps", 0.1, "Rate limiter maximum receipt emails per second sent on request") // receipt emails per second
+	flag.IntVar(&cfg.limiter.receiptEmailBurst, "limiter-receipt-email-burst", 5, "Rate limiter maximum burst of receipt emails sent on request")     // receipt email burst size
+
+	cfg.limiter.exemptPaths = defaultLimiterExemptPaths
+	flag.Func("limiter-exempt-paths", "Route prefixes the rate limiter does not apply to (space separated, default \""+strings.Join(defaultLimiterExemptPaths, " ")+"\")", func(s string) error {
+		cfg.limiter.exemptPaths = strings.Fields(s)
+		return nil
+	})
+	var exemptNetworks []string
+	flag.Func("limiter-exempt-networks", "Client networks the rate limiter does not apply to, such as internal services (space separated CIDRs or addresses)", func(s string) error {
+		exemptNetworks = strings.Fields(s)
+		return nil
+	})
+	flag.Func("limiter-exempt-keys", "X-API-Key values that let internal services bypass the rate limiter (space separated)", func(s string) error {
+		cfg.limiter.exemptKeys = strings.Fields(s)
+		return nil
+	})
 
 	// SMTP settings
 	flag.StringVar(&cfg.smtp.host, "smtp-host", "smtp.mailtrap.io", "SMTP host")                             // SMTP host
@@ -132,10 +386,85 @@ func loadConfig() config {
 	flag.StringVar(&cfg.smtp.username, "smtp-username", "", "SMTP username")                                 // SMTP username
 	flag.StringVar(&cfg.smtp.password, "smtp-password", "", "SMTP password")                                 // SMTP password
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Training <noreply@example.com>", "SMTP sender address") // SMTP sender address
+	flag.StringVar(&cfg.smtp.webhookSecret, "email-webhook-secret", "", "Shared secret for mail provider delivery callbacks, the webhook is disabled when empty")
 
 	// GitHub settings
 	flag.StringVar(&cfg.github.token, "github-token", "", "GitHub API token") // GitHub API token
 
+	// SMS alert settings
+	flag.StringVar(&cfg.sms.accountSID, "sms-account-sid", "", "Twilio account SID")
+	flag.StringVar(&cfg.sms.authToken, "sms-auth-token", "", "Twilio auth token")
+	flag.StringVar(&cfg.sms.from, "sms-from", "", "Twilio phone number alerts are sent from")
+	flag.Func("alert-sms-recipients", "Phone numbers that receive critical alerts by SMS (space separated)", func(s string) error {
+		cfg.sms.alertTo = strings.Fields(s)
+		return nil
+	})
+
+	// Two-factor authentication settings
+	var totpKey string
+	flag.StringVar(&totpKey, "totp-encryption-key", "", "Hex encoded 32 byte key for encrypting TOTP secrets") // TOTP encryption key
+	flag.StringVar(&cfg.totp.issuer, "totp-issuer", "SalesAPI", "Issuer name shown in authenticator apps")     // TOTP issuer
+
+	// Token lifetime settings
+	flag.DurationVar(&cfg.tokens.activationTTL, "activation-token-ttl", defaultActivationTokenTTL, "How long activation tokens stay valid (1h to 30 days)")
+	flag.DurationVar(&cfg.tokens.authenticationTTL, "auth-token-ttl", defaultAuthenticationTokenTTL, "How long login sessions stay valid (5m to 30 days)")
+
+	// Password hashing settings
+	flag.StringVar(&cfg.passwordHashing.Algorithm, "auth-hash-algo", data.HashBcrypt, "Password hashing algorithm (bcrypt|argon2id)")
+	flag.IntVar(&cfg.passwordHashing.Cost, "auth-hash-cost", 0, "bcrypt cost or argon2id passes, 0 for the algorithm default")
+
+	// Password policy settings
+	var passwordClasses, passwordBannedFile string
+	cfg.passwordPolicy = data.DefaultPasswordPolicy()
+	flag.IntVar(&cfg.passwordPolicy.MinLength, "password-min-length", cfg.passwordPolicy.MinLength, "Minimum password length")
+	flag.StringVar(&passwordClasses, "password-required-classes", strings.Join(cfg.passwordPolicy.RequiredClasses, ","), "Comma separated character classes (number,upper,lower,special), empty for none")
+	flag.StringVar(&passwordBannedFile, "password-banned-file", "", "File of extra banned passwords, one per line")
+
+	// Self-test settings
+	flag.BoolVar(&cfg.selftest.enabled, "selftest", false, "Run an end-to-end smoke test against a temporary schema and exit")
+	flag.StringVar(&cfg.selftest.migrations, "selftest-migrations", "./migrations", "Directory of migrations applied to the self-test schema")
+
+	// Pagination settings
+	var pageSizes string
+	flag.StringVar(&pageSizes, "page-sizes", "", "Page sizes of listings as space separated resource=default:max pairs, such as \"sales=50:200\"")
+
+	// Low stock alert settings
+	flag.DurationVar(&cfg.lowStock.interval, "low-stock-interval", 15*time.Minute, "Interval between low stock checks (0 disables the alerts)")
+	flag.Func("low-stock-recipients", "Email addresses alerted when products fall below their reorder threshold (space separated)", func(s string) error {
+		cfg.lowStock.recipients = strings.Fields(s)
+		return nil
+	})
+
+	// Scheduler settings
+	flag.DurationVar(&cfg.scheduler.interval, "schedule-interval", 30*time.Second, "Interval between checks for due job schedules (0 disables the scheduler)")
+
+	// File storage settings
+	flag.StringVar(&cfg.storage.dir, "storage-dir", "", "Directory sale attachments are stored in (empty disables attachments)")
+	flag.Int64Var(&cfg.storage.maxBytes, "attachment-max-bytes", 5_000_000, "Largest file that may be attached to a sale")
+
+	// Returns policy settings
+	flag.IntVar(&cfg.returns.windowDays, "returns-window-days", 30, "Days after a sale it may be refunded without policy:override (0 for no limit)")
+
+	// Duplicate sale detection settings
+	flag.DurationVar(&cfg.duplicates.window, "duplicate-sale-window", time.Minute, "How close together identical sales by one user are flagged as likely duplicates (0 disables the check)")
+
+	// Sensitive action settings
+	flag.DurationVar(&cfg.reauthWindow, "reauth-window", 5*time.Minute, "How long after logging in sensitive actions need no password confirmation (0 always asks)")
+
+	// Store details printed on receipts
+	flag.StringVar(&cfg.store.Name, "store-name", "ACM Sales", "Store name printed on receipts")
+	flag.StringVar(&cfg.store.Address, "store-address", "", "Store address printed on receipts")
+	flag.StringVar(&cfg.store.Phone, "store-phone", "", "Store phone number printed on receipts")
+	flag.StringVar(&cfg.store.TaxID, "store-tax-id", "", "Tax registration number printed on receipts")
+
+	// Fault injection settings
+	flag.BoolVar(&cfg.chaos.enabled, "chaos-enabled", false, "Inject latency, errors and dropped connections (refused in production)")
+	flag.Float64Var(&cfg.chaos.latencyPercent, "chaos-latency-percent", 0, "Percentage of requests delayed by -chaos-latency")
+	flag.DurationVar(&cfg.chaos.latency, "chaos-latency", 2*time.Second, "Delay added to requests picked for latency")
+	flag.Float64Var(&cfg.chaos.errorPercent, "chaos-error-percent", 0, "Percentage of requests answered with -chaos-error-status")
+	flag.IntVar(&cfg.chaos.errorStatus, "chaos-error-status", http.StatusServiceUnavailable, "Status code of injected errors (5xx)")
+	flag.Float64Var(&cfg.chaos.dropPercent, "chaos-drop-percent", 0, "Percentage of requests whose connection is dropped")
+
 	flag.Parse() // parse the command-line flags
 
 	// Print out all the flag values for debugging
@@ -149,6 +478,25 @@ func loadConfig() config {
 	re := regexp.MustCompile(`#(.*?)#`)
 	cfg.smtp.sender = re.ReplaceAllString(cfg.smtp.sender, "<$1>")
 
+	switch cfg.env {
+	case "development", "staging", "production", "sandbox":
+	default:
+		panic("env must be development, staging, production or sandbox, got " + cfg.env)
+	}
+
+	if cfg.adminPort == 0 {
+		cfg.adminPort, _ = strconv.Atoi(os.Getenv("ADMIN_PORT"))
+	}
+	if cfg.adminPort < 0 || cfg.adminPort > 65535 || (cfg.adminPort != 0 && cfg.adminPort == cfg.port) {
+		panic("admin-port must be a port other than the API port, or 0 to disable the admin listener")
+	}
+	if cfg.adminAddr == "" {
+		cfg.adminAddr = os.Getenv("ADMIN_ADDR")
+	}
+	if cfg.adminAddr == "" {
+		cfg.adminAddr = "127.0.0.1"
+	}
+
 	if cfg.db.dsn == "" {
 		cfg.db.dsn = os.Getenv("DB_DSN")
 	}
@@ -156,11 +504,34 @@ func loadConfig() config {
 		panic("db-dsn must be provided via flag or DB_DSN environment variable")
 	}
 
+	if cfg.baseCurrency == data.DefaultCurrency {
+		if currency := os.Getenv("BASE_CURRENCY"); currency != "" {
+			cfg.baseCurrency = currency
+		}
+	}
+	if !data.CurrencyRX.MatchString(cfg.baseCurrency) {
+		panic("base-currency must be a three letter currency code such as USD")
+	}
+
 	if len(cfg.cors.trustedOrigins) == 0 {
 		if origins := strings.Fields(os.Getenv("CORS_TRUSTED_ORIGINS")); len(origins) > 0 {
 			cfg.cors.trustedOrigins = origins
 		}
 	}
+	if headers, found := os.LookupEnv("CORS_EXPOSED_HEADERS"); found && slices.Equal(cfg.cors.exposedHeaders, defaultExposedHeaders) {
+		cfg.cors.exposedHeaders = strings.Fields(headers)
+	}
+	if !cfg.cors.allowCredentials {
+		cfg.cors.allowCredentials, _ = strconv.ParseBool(os.Getenv("CORS_ALLOW_CREDENTIALS"))
+	}
+	if cfg.cors.maxAge == 0 {
+		if maxAge, err := time.ParseDuration(os.Getenv("CORS_MAX_AGE")); err == nil {
+			cfg.cors.maxAge = maxAge
+		}
+	}
+	if cfg.cors.maxAge < 0 {
+		panic("cors-max-age must not be negative")
+	}
 
 	if cfg.smtp.host == "" {
 		cfg.smtp.host = os.Getenv("SMTP_HOST")
@@ -177,6 +548,221 @@ func loadConfig() config {
 		}
 	}
 
+	if cfg.smtp.webhookSecret == "" {
+		cfg.smtp.webhookSecret = os.Getenv("EMAIL_WEBHOOK_SECRET")
+	}
+
+	if cfg.env == "production" && (cfg.smtp.host == "" || cfg.smtp.sender == "") {
+		panic("smtp-host and smtp-sender must be provided in production, users cannot activate their accounts without email")
+	}
+
+	if cfg.sms.accountSID == "" {
+		cfg.sms.accountSID = os.Getenv("TWILIO_ACCOUNT_SID")
+	}
+	if cfg.sms.authToken == "" {
+		cfg.sms.authToken = os.Getenv("TWILIO_AUTH_TOKEN")
+	}
+	if cfg.sms.from == "" {
+		cfg.sms.from = os.Getenv("TWILIO_FROM_NUMBER")
+	}
+	if len(cfg.sms.alertTo) == 0 {
+		cfg.sms.alertTo = strings.Fields(os.Getenv("ALERT_SMS_RECIPIENTS"))
+	}
+
+	if pageSizes == "" {
+		pageSizes = os.Getenv("PAGE_SIZES")
+	}
+	pagination, err := parsePagePolicies(pageSizes)
+	if err != nil {
+		panic("page-sizes: " + err.Error())
+	}
+	cfg.pagination = pagination
+
+	if len(cfg.lowStock.recipients) == 0 {
+		cfg.lowStock.recipients = strings.Fields(os.Getenv("LOW_STOCK_RECIPIENTS"))
+	}
+	for _, recipient := range cfg.lowStock.recipients {
+		if !validator.EmailRX.MatchString(recipient) {
+			panic("low-stock-recipients must be email addresses, got " + recipient)
+		}
+	}
+	if cfg.lowStock.interval < 0 {
+		panic("low-stock-interval must not be negative")
+	}
+	if cfg.scheduler.interval < 0 {
+		panic("schedule-interval must not be negative")
+	}
+
+	if cfg.storage.dir == "" {
+		cfg.storage.dir = os.Getenv("STORAGE_DIR")
+	}
+	if cfg.storage.maxBytes < 1 {
+		panic("attachment-max-bytes must be positive")
+	}
+
+	if cfg.returns.windowDays == 30 {
+		if days, err := strconv.Atoi(os.Getenv("RETURNS_WINDOW_DAYS")); err == nil {
+			cfg.returns.windowDays = days
+		}
+	}
+	if cfg.returns.windowDays < 0 {
+		panic("returns-window-days must not be negative")
+	}
+	if cfg.duplicates.window == time.Minute {
+		if window, err := time.ParseDuration(os.Getenv("DUPLICATE_SALE_WINDOW")); err == nil {
+			cfg.duplicates.window = window
+		}
+	}
+	if cfg.duplicates.window < 0 {
+		panic("duplicate-sale-window must not be negative")
+	}
+
+	if cfg.reauthWindow == 5*time.Minute {
+		if window, err := time.ParseDuration(os.Getenv("REAUTH_WINDOW")); err == nil {
+			cfg.reauthWindow = window
+		}
+	}
+	if cfg.reauthWindow < 0 {
+		panic("reauth-window must not be negative")
+	}
+
+	if cfg.store.Name == "ACM Sales" {
+		if name := os.Getenv("STORE_NAME"); name != "" {
+			cfg.store.Name = name
+		}
+	}
+	if cfg.store.Address == "" {
+		cfg.store.Address = os.Getenv("STORE_ADDRESS")
+	}
+	if cfg.store.Phone == "" {
+		cfg.store.Phone = os.Getenv("STORE_PHONE")
+	}
+	if cfg.store.TaxID == "" {
+		cfg.store.TaxID = os.Getenv("STORE_TAX_ID")
+	}
+
+	if !cfg.chaos.enabled {
+		cfg.chaos.enabled, _ = strconv.ParseBool(os.Getenv("CHAOS_ENABLED"))
+	}
+	if cfg.chaos.enabled {
+		if cfg.env == "production" {
+			panic("chaos-enabled must not be set in production")
+		}
+		for _, percent := range []float64{cfg.chaos.latencyPercent, cfg.chaos.errorPercent, cfg.chaos.dropPercent} {
+			if percent < 0 || percent > 100 {
+				panic("chaos percentages must be between 0 and 100")
+			}
+		}
+		if cfg.chaos.errorStatus < 500 || cfg.chaos.errorStatus > 599 {
+			panic("chaos-error-status must be a 5xx status code")
+		}
+	}
+
+	if cfg.tokens.activationTTL == defaultActivationTokenTTL {
+		if ttl, err := time.ParseDuration(os.Getenv("ACTIVATION_TOKEN_TTL")); err == nil {
+			cfg.tokens.activationTTL = ttl
+		}
+	}
+	if cfg.tokens.activationTTL < time.Hour || cfg.tokens.activationTTL > maxTokenTTL {
+		panic("activation-token-ttl must be between 1h and 720h")
+	}
+	if cfg.tokens.authenticationTTL == defaultAuthenticationTokenTTL {
+		if ttl, err := time.ParseDuration(os.Getenv("AUTH_TOKEN_TTL")); err == nil {
+			cfg.tokens.authenticationTTL = ttl
+		}
+	}
+	if cfg.tokens.authenticationTTL < 5*time.Minute || cfg.tokens.authenticationTTL > maxTokenTTL {
+		panic("auth-token-ttl must be between 5m and 720h")
+	}
+
+	if totpKey == "" {
+		totpKey = os.Getenv("TOTP_ENCRYPTION_KEY")
+	}
+	if totpKey != "" {
+		key, err := hex.DecodeString(totpKey)
+		if err != nil || len(key) != 32 {
+			panic("totp-encryption-key must be 64 hex characters (32 bytes)")
+		}
+		cfg.totp.encryptionKey = key
+	}
+
+	if cfg.passwordPolicy.MinLength == data.DefaultPasswordPolicy().MinLength {
+		if minLength, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil {
+			cfg.passwordPolicy.MinLength = minLength
+		}
+	}
+	if classes, found := os.LookupEnv("PASSWORD_REQUIRED_CLASSES"); found && passwordClasses == strings.Join(data.PasswordClasses, ",") {
+		passwordClasses = classes
+	}
+	cfg.passwordPolicy.RequiredClasses = []string{}
+	for _, class := range strings.Split(passwordClasses, ",") {
+		if class = strings.TrimSpace(class); class != "" {
+			cfg.passwordPolicy.RequiredClasses = append(cfg.passwordPolicy.RequiredClasses, class)
+		}
+	}
+	if passwordBannedFile == "" {
+		passwordBannedFile = os.Getenv("PASSWORD_BANNED_FILE")
+	}
+	if passwordBannedFile != "" {
+		contents, err := os.ReadFile(passwordBannedFile)
+		if err != nil {
+			panic(fmt.Sprintf("password-banned-file could not be read: %v", err))
+		}
+		for _, line := range strings.Split(string(contents), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				cfg.passwordPolicy.BannedPasswords = append(cfg.passwordPolicy.BannedPasswords, line)
+			}
+		}
+	}
+	if err := cfg.passwordPolicy.Validate(); err != nil {
+		panic(err.Error())
+	}
+
+	if cfg.passwordHashing.Algorithm == data.HashBcrypt {
+		if algorithm := os.Getenv("AUTH_HASH_ALGO"); algorithm != "" {
+			cfg.passwordHashing.Algorithm = algorithm
+		}
+	}
+	if cfg.passwordHashing.Cost == 0 {
+		if cost, err := strconv.Atoi(os.Getenv("AUTH_HASH_COST")); err == nil {
+			cfg.passwordHashing.Cost = cost
+		}
+	}
+	if err := cfg.passwordHashing.Validate(); err != nil {
+		panic(err.Error())
+	}
+
+	if paths, found := os.LookupEnv("LIMITER_EXEMPT_PATHS"); found && slices.Equal(cfg.limiter.exemptPaths, defaultLimiterExemptPaths) {
+		cfg.limiter.exemptPaths = strings.Fields(paths)
+	}
+	for _, path := range cfg.limiter.exemptPaths {
+		if !strings.HasPrefix(path, "/") {
+			panic("limiter-exempt-paths must start with /, got " + path)
+		}
+	}
+	if len(exemptNetworks) == 0 {
+		exemptNetworks = strings.Fields(os.Getenv("LIMITER_EXEMPT_NETWORKS"))
+	}
+	for _, network := range exemptNetworks {
+		prefix, err := parseNetwork(network)
+		if err != nil {
+			panic("limiter-exempt-networks: " + err.Error())
+		}
+		cfg.limiter.exemptNetworks = append(cfg.limiter.exemptNetworks, prefix)
+	}
+	if len(cfg.limiter.exemptKeys) == 0 {
+		cfg.limiter.exemptKeys = strings.Fields(os.Getenv("LIMITER_EXEMPT_KEYS"))
+	}
+	for _, key := range cfg.limiter.exemptKeys {
+		if len(key) < 16 {
+			panic("limiter-exempt-keys must be at least 16 characters long")
+		}
+	}
+
+	if cfg.limiter.loginEnabled && (cfg.limiter.loginAttempts < 1 || cfg.limiter.loginBackoff <= 0 || cfg.limiter.loginMaxBackoff < cfg.limiter.loginBackoff) {
+		panic("limiter-login-attempts and limiter-login-backoff must be positive and limiter-login-max-backoff at least the backoff")
+	}
+
 	return cfg // return the populated configuration
 }
 
@@ -0,0 +1,120 @@
+// File: cmd/api/metrics.go
+// Description: Prometheus exposition of the latency histograms
+
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/metrics"
+)
+
+// prometheusMetricsHandler writes the request and query latency histograms, the rate limiter
+// client counts and exempted traffic, the authentication outcomes and the server errors by failed
+// operation, in the Prometheus text format.
+// The expvar counters remain available as JSON on /v1/metrics.
+func (app *app) prometheusMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	for _, histogram := range []*metrics.Histogram{app.requestDurations, app.queryDurations} {
+		if histogram == nil {
+			continue
+		}
+		if err := histogram.WritePrometheus(w); err != nil {
+			app.logger.Error("failed to write prometheus metrics", "error", err)
+			return
+		}
+	}
+
+	if err := writeLimiterMetrics(w, app.limiters.snapshot()); err != nil {
+		app.logger.Error("failed to write prometheus metrics", "error", err)
+		return
+	}
+	if err := writeLimiterExemptions(w, app.limiters.exemptions()); err != nil {
+		app.logger.Error("failed to write prometheus metrics", "error", err)
+		return
+	}
+	if err := writeAuthEvents(w, app.authEvents.snapshot()); err != nil {
+		app.logger.Error("failed to write prometheus metrics", "error", err)
+		return
+	}
+	if err := writeServerErrors(w, app.serverErrors.snapshot()); err != nil {
+		app.logger.Error("failed to write prometheus metrics", "error", err)
+	}
+}
+
+// expvarHandler writes the published expvar values as JSON like expvar.Handler, leaving out
+// cmdline because the command line carries the DSN and other secrets passed as flags.
+func (app *app) expvarHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	fmt.Fprint(w, "{\n")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if kv.Key == "cmdline" {
+			return
+		}
+		if !first {
+			fmt.Fprint(w, ",\n")
+		}
+		first = false
+		fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+	})
+	fmt.Fprint(w, "\n}\n")
+}
+
+// writeServerErrors writes the server errors counted since startup by the model operation that failed.
+func writeServerErrors(w io.Writer, counts map[string]int64) error {
+	if _, err := fmt.Fprint(w, "# HELP server_errors_total Server errors since startup by the model operation that failed.\n# TYPE server_errors_total counter\n"); err != nil {
+		return err
+	}
+	for _, operation := range slices.Sorted(maps.Keys(counts)) {
+		if _, err := fmt.Fprintf(w, "server_errors_total{operation=%q} %d\n", operation, counts[operation]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeLimiterMetrics writes the clients tracked and evicted by each rate limiter.
+func writeLimiterMetrics(w io.Writer, snapshot map[string]limiterSnapshot) error {
+	names := slices.Sorted(maps.Keys(snapshot))
+
+	if _, err := fmt.Fprint(w, "# HELP rate_limiter_clients Clients currently tracked by each rate limiter.\n# TYPE rate_limiter_clients gauge\n"); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "rate_limiter_clients{limiter=%q} %d\n", name, snapshot[name].Clients); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprint(w, "# HELP rate_limiter_evicted_total Idle clients forgotten by each rate limiter.\n# TYPE rate_limiter_evicted_total counter\n"); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "rate_limiter_evicted_total{limiter=%q} %d\n", name, snapshot[name].Evicted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeLimiterExemptions writes the requests let through the rate limiters by exemption reason.
+func writeLimiterExemptions(w io.Writer, exempted map[string]int64) error {
+	if _, err := fmt.Fprint(w, "# HELP rate_limiter_exempt_requests_total Requests let through the rate limiters by exemption reason.\n# TYPE rate_limiter_exempt_requests_total counter\n"); err != nil {
+		return err
+	}
+	for _, reason := range slices.Sorted(maps.Keys(exempted)) {
+		if _, err := fmt.Fprintf(w, "rate_limiter_exempt_requests_total{reason=%q} %d\n", reason, exempted[reason]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
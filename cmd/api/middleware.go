@@ -2,10 +2,17 @@
 package main
 
 import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
 	"errors"
 	"expvar"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"net/netip"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -38,79 +45,318 @@ func (app *app) recoverPanic(next http.Handler) http.Handler {
  * rate limiting
  ************************************************************************************************/
 
+// defaultLimiterExemptPaths are the routes polled by load balancers, which are never rate limited
+// unless -limiter-exempt-paths says otherwise. Metrics scrapers are exempted by adding /v1/metrics
+// there, by a trusted network or by an API key.
+var defaultLimiterExemptPaths = []string{"/v1/healthcheck"}
+
 // rateLimit is a middleware that limits the rate of incoming requests.
+// Routes with their own limiter, such as product suggestions, are skipped here.
 func (app *app) rateLimit(next http.Handler) http.Handler {
-	// client is a struct to hold information about each client
-	type client struct {
-		limiter  *rate.Limiter // Rate limiter for the client
-		lastSeen time.Time     // Last time the client was seen
+	limited := app.limitPerClient("global", func() (float64, int) {
+		return app.config.limiter.rps, app.config.limiter.burst
+	})(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/suggest/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limited.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitSuggestions is a route middleware for search-as-you-type endpoints, which send a request
+// per keystroke and so get a larger budget than the rest of the API.
+func (app *app) rateLimitSuggestions(next http.Handler) http.Handler {
+	return app.limitPerClient("suggest", func() (float64, int) {
+		return app.config.limiter.suggestRPS, app.config.limiter.suggestBurst
+	})(next)
+}
+
+// rateLimitReceiptEmails is a route middleware for receipts emailed on request, which send mail to
+// any address and so get a much smaller budget than the rest of the API.
+func (app *app) rateLimitReceiptEmails(next http.Handler) http.Handler {
+	return app.limitPerClient("receipt_email", func() (float64, int) {
+		return app.config.limiter.receiptEmailRPS, app.config.limiter.receiptEmailBurst
+	})(next)
+}
+
+// limitPerClient returns a middleware that gives every client IP its own token bucket from the
+// app's named limiter, sized by limits when the client is first seen. Idle clients are swept by
+// app.limiters while the server runs. Exempt requests are let through without touching a bucket.
+func (app *app) limitPerClient(name string, limits func() (rps float64, burst int)) func(http.Handler) http.Handler {
+	limiter := app.limiters.get(name, limits)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if app.config.limiter.enabled { // Check if rate limiting is enabled
+				if reason := app.rateLimitExemption(r); reason != "" {
+					app.limiters.exempt(reason) // Count the traffic let through, for metrics
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				allowed, state := limiter.allow(clientIP(r), time.Now())
+
+				state.setHeaders(w.Header()) // Report the client's budget on every response
+				if !allowed {
+					w.Header().Set("Retry-After", strconv.Itoa(state.RetryAfter))
+					app.rateLimitExceededResponse(w, r, state) // Send a 429 Too Many Requests response
+					return
+				}
+			}
+			next.ServeHTTP(w, r) // Call the next handler in the chain
+		})
+	}
+}
+
+// rateLimitExemption returns why the request bypasses the per-client limiters, or "" when it
+// does not: "path" for routes such as health checks and metrics scrapes, "network" for clients in
+// a trusted network and "api_key" for internal services sending a trusted key in X-API-Key.
+// Login throttling still applies to exempt clients.
+func (app *app) rateLimitExemption(r *http.Request) string {
+	for _, prefix := range app.config.limiter.exemptPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return "path"
+		}
+	}
+
+	if len(app.config.limiter.exemptNetworks) > 0 {
+		if addr, err := netip.ParseAddr(clientIP(r)); err == nil {
+			addr = addr.Unmap() // IPv4 clients of a dual stack listener arrive as ::ffff:a.b.c.d
+			for _, network := range app.config.limiter.exemptNetworks {
+				if network.Contains(addr) {
+					return "network"
+				}
+			}
+		}
+	}
+
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		for _, exemptKey := range app.config.limiter.exemptKeys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(exemptKey)) == 1 {
+				return "api_key"
+			}
+		}
+	}
+	return ""
+}
+
+// rateLimitState describes a client's rate limit budget, returned in X-RateLimit-* headers and 429 bodies.
+type rateLimitState struct {
+	Limit      int `json:"limit"`       // maximum burst of requests
+	Remaining  int `json:"remaining"`   // requests that can be made right now
+	Reset      int `json:"reset"`       // seconds until the full burst is available again
+	RetryAfter int `json:"retry_after"` // seconds until the next request will be allowed
+}
+
+// newRateLimitState computes the budget left on a limiter at the given time.
+func newRateLimitState(limiter *rate.Limiter, now time.Time) rateLimitState {
+	tokens := limiter.TokensAt(now)
+	rps := float64(limiter.Limit())
+
+	state := rateLimitState{
+		Limit:     limiter.Burst(),
+		Remaining: max(0, int(math.Floor(tokens))),
+	}
+	if rps > 0 {
+		state.Reset = int(math.Ceil((float64(state.Limit) - tokens) / rps))
+		if tokens < 1 {
+			state.RetryAfter = max(1, int(math.Ceil((1-tokens)/rps)))
+		}
+	}
+	return state
+}
+
+// setHeaders writes the X-RateLimit-* headers for the state.
+func (s rateLimitState) setHeaders(h http.Header) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(s.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(s.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(s.Reset))
+}
+
+/***********************************************************************************************
+ * login throttling
+ ************************************************************************************************/
+
+// limitLogins is a route middleware for the login endpoint. On top of the global limiter it gives
+// every email address and every client IP a small per-minute budget, and locks them out for an
+// exponentially growing time after consecutive failed logins.
+func (app *app) limitLogins(next http.Handler) http.Handler {
+	// loginClient tracks the attempts made for one email address or client IP
+	type loginClient struct {
+		limiter     *rate.Limiter // per-minute attempt budget
+		failures    int           // consecutive failed logins
+		lockedUntil time.Time     // no attempts are accepted before this time
+		lastSeen    time.Time     // last attempt, used to forget idle clients
 	}
 
 	var (
-		mu      sync.Mutex                 // Mutex to protect access to the clients map
-		clients = make(map[string]*client) // Map to hold clients by their IP address
+		mu          sync.Mutex                      // Mutex to protect access to the clients map
+		clients     = make(map[string]*loginClient) // Clients keyed by "email:" or "ip:" prefix
+		lastCleanup time.Time                       // last time idle clients were removed
 	)
 
-	// Start a background goroutine to clean up old clients every minute
-	go func() {
-		for {
-			time.Sleep(time.Minute) // Sleep for one minute
-			mu.Lock()               // Lock the mutex to safely access the clients map
-			for ip, client := range clients {
-				if time.Since(client.lastSeen) > 3*time.Minute { // If the client hasn't been seen for over 3 minutes
-					delete(clients, ip) // Remove the client from the map
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.limiter.loginEnabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		keys := []string{"ip:" + clientIP(r)}
+		if email := peekLoginEmail(r); email != "" {
+			keys = append(keys, "email:"+email)
+		}
+
+		now := time.Now()
+		var retryAfter time.Duration
+
+		mu.Lock()
+		// Failures are remembered well past the lockout so the backoff keeps growing
+		if now.Sub(lastCleanup) > time.Minute {
+			for key, client := range clients {
+				if now.Sub(client.lastSeen) > 2*app.config.limiter.loginMaxBackoff && now.After(client.lockedUntil) {
+					delete(clients, key)
 				}
 			}
-			mu.Unlock() // Unlock the mutex
+			lastCleanup = now
 		}
-	}()
+		tracked := make([]*loginClient, len(keys)) // kept for after the handler, when the sweep may have dropped them
+		for i, key := range keys {
+			client, found := clients[key]
+			if !found {
+				attempts := app.config.limiter.loginAttempts
+				client = &loginClient{limiter: rate.NewLimiter(rate.Limit(float64(attempts)/60), attempts)}
+				clients[key] = client
+			}
+			tracked[i] = client
+			client.lastSeen = now
+			if now.Before(client.lockedUntil) {
+				retryAfter = max(retryAfter, client.lockedUntil.Sub(now))
+			}
+		}
+		// Only spend the budget when the attempt will actually be made
+		if retryAfter == 0 {
+			for _, client := range tracked {
+				if reservation := client.limiter.ReserveN(now, 1); reservation.DelayFrom(now) > 0 {
+					retryAfter = max(retryAfter, reservation.DelayFrom(now))
+					reservation.CancelAt(now)
+				}
+			}
+		}
+		mu.Unlock()
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.config.limiter.enabled { // Check if rate limiting is enabled
-			ip := r.RemoteAddr // Get the client's IP address
+		if retryAfter > 0 {
+			app.recordAuthEvent(r, data.AuthLoginThrottled, nil)
+			app.loginThrottledResponse(w, r, retryAfter)
+			return
+		}
 
-			mu.Lock()                            // Lock the mutex to safely access the clients map
-			if _, found := clients[ip]; !found { // If the client is not already in the map
-				clients[ip] = &client{
-					limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst), // Create a new rate limiter for the client
+		mw := newMetricsResponseWriter(w)
+		next.ServeHTTP(mw, r)
+
+		mu.Lock()
+		defer mu.Unlock()
+		// Another request may have swept an entry while this one was running; put it back
+		for i, key := range keys {
+			if client, found := clients[key]; found {
+				tracked[i] = client
+			} else {
+				clients[key] = tracked[i]
+			}
+		}
+		switch mw.statusCode {
+		case http.StatusUnauthorized:
+			locked := false
+			for _, client := range tracked {
+				client.failures++
+				if backoff := loginBackoff(client.failures, app.config.limiter.loginBackoff, app.config.limiter.loginMaxBackoff); backoff > 0 {
+					client.lockedUntil = time.Now().Add(backoff)
+					locked = true
 				}
 			}
-			clients[ip].lastSeen = time.Now() // Update the last seen time for the client
-			if !clients[ip].limiter.Allow() { // Check if the client is allowed to make a request
-				mu.Unlock()                         // Unlock the mutex before returning
-				app.rateLimitExceededResponse(w, r) // Send a 429 Too Many Requests response
-				return
+			if locked {
+				app.recordAuthEvent(r, data.AuthLockout, nil)
+			}
+		case http.StatusCreated:
+			// Only the account is cleared, otherwise logging into an attacker's own account
+			// between guesses would reset the lockout of their IP
+			if len(keys) > 1 {
+				client := tracked[1]
+				client.failures = 0
+				client.lockedUntil = time.Time{}
 			}
-			mu.Unlock() // Unlock the mutex
 		}
-		next.ServeHTTP(w, r) // Call the next handler in the chain
 	})
 }
 
+// loginBackoff returns the lockout after the given number of consecutive failures. A single
+// mistyped password is not punished; from the second failure on the lockout doubles up to limit.
+func loginBackoff(failures int, base, limit time.Duration) time.Duration {
+	if failures < 2 {
+		return 0
+	}
+	backoff := base
+	for i := 2; i < failures && backoff < limit; i++ {
+		backoff *= 2
+	}
+	if backoff > limit {
+		return limit
+	}
+	return backoff
+}
+
+// peekLoginEmail returns the normalised email address from a login request body and leaves the
+// body in place for the handler. Malformed bodies yield an empty email and are rejected later.
+func peekLoginEmail(r *http.Request) string {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 256_000))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil {
+		return ""
+	}
+
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(body, &input); err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(input.Email))
+}
+
 /***********************************************************************************************
  * Enabling CORS
  ************************************************************************************************/
+// defaultExposedHeaders lets browser clients read where created resources live and their rate limit budget.
+var defaultExposedHeaders = []string{"Location", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"}
+
 // enableCORS is a middleware that adds CORS headers to the response.
 func (app *app) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Vary", "Origin")                        // Indicate that the response varies based on the Origin header
-		w.Header().Set("Vary", "Access-Control-Request-Method") // Indicate that the response varies based on the Access-Control-Request-Method header
+		w.Header().Add("Vary", "Origin")                        // Indicate that the response varies based on the Origin header
+		w.Header().Add("Vary", "Access-Control-Request-Method") // Indicate that the response varies based on the Access-Control-Request-Method header
 
 		origin := r.Header.Get("Origin") // Get the Origin header from the request
 
-		if origin != "" {
-			for i := range app.config.cors.trustedOrigins {
-				// Check if the origin is in the trusted origins list
-				if origin == app.config.cors.trustedOrigins[i] {
-					w.Header().Set("Access-Control-Allow-Origin", origin) // Allow the specific origin
-					if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
-						// Handle preflight request
-						w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE") // Allowed methods
-						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type") // Allowed headers
-						w.WriteHeader(http.StatusOK)                                                  // Respond with 200 OK
-						return
-					}
+		if origin != "" && slices.Contains(app.config.cors.trustedOrigins, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin) // Allow the specific origin
+			if app.config.cors.allowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true") // Let the browser send cookies and read the response
+			}
+			if len(app.config.cors.exposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(app.config.cors.exposedHeaders, ", ")) // Let browser clients read these headers
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				// Handle preflight request
+				w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")                                        // Allowed methods
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-Request-Timeout, X-Confirm-Password") // Allowed headers
+				if maxAge := int(app.config.cors.maxAge.Seconds()); maxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", strconv.Itoa(maxAge)) // Let the browser cache the preflight
 				}
+				w.WriteHeader(http.StatusOK) // Respond with 200 OK
+				return
 			}
 		}
 		next.ServeHTTP(w, r) // Call the next handler in the chain
@@ -163,8 +409,16 @@ func (app *app) authenticate(next http.Handler) http.Handler {
 			return // Return to avoid further processing
 		}
 
+		// Record activity for the session list at most once per minute, a failure here should not block the request
+		if user.SessionTouchDue(app.now()) {
+			if err := app.models.Tokens.Touch(tokenPlaintext); err != nil {
+				app.logger.Error("failed to record session use", "user_id", user.ID, "error", err)
+			}
+		}
+
 		// Set the user in the request context
-		r = app.contextSetUser(r, user) // Set the authenticated user in the context
+		r = app.contextSetUser(r, user)            // Set the authenticated user in the context
+		r = app.contextSetToken(r, tokenPlaintext) // Keep the token so the current session can be identified
 
 		next.ServeHTTP(w, r) // Call the next handler in the chain
 	})
@@ -199,6 +453,41 @@ func (app *app) requireActivatedUser(next http.Handler) http.Handler {
 	return app.requireAuthenticatedUser(fn)
 }
 
+// requirePasswordConfirmation is a middleware that guards sensitive actions: the session must have
+// been opened within the reauth window, or the current password must be sent in X-Confirm-Password.
+func (app *app) requirePasswordConfirmation(next http.Handler) http.Handler {
+	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		password := r.Header.Get("X-Confirm-Password")
+		confirmed, err := app.passwordConfirmed(app.contextGetUser(r), password)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !confirmed {
+			if password == "" {
+				app.passwordConfirmationRequiredResponse(w, r) // Send a 401 asking for the password
+			} else {
+				app.invalidCredentialsResponse(w, r) // Send a 401 for the wrong password
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r) // Call the next handler in the chain
+	})
+	return app.requireAuthenticatedUser(fn)
+}
+
+// passwordConfirmed reports whether a sensitive action may go ahead for the user: their session was
+// opened within the reauth window, or the password sent matches theirs. Impersonated sessions
+// always need the password, which the admin acting as the user does not know.
+func (app *app) passwordConfirmed(user *data.User, password string) (bool, error) {
+	if password == "" {
+		fresh := !user.IsImpersonated() && !user.LoggedInAt.IsZero() && app.now().Sub(user.LoggedInAt.Time) < app.config.reauthWindow
+		return fresh, nil
+	}
+	return user.Password.Matches(password)
+}
+
 /************************************************************************************************************/
 // Permissions
 /************************************************************************************************************/
@@ -283,7 +572,13 @@ func (app *app) metrics(next http.Handler) http.Handler {
 		next.ServeHTTP(mw, r)                                          // Call the next handler in the chain
 		totalResponsesSent.Add(1)                                      // Increment the total responses sent counter
 		totalResponsesSentByStatus.Add(strconv.Itoa(mw.statusCode), 1) // Increment the count for the specific status code
-		duration := time.Since(start).Microseconds()                   // Calculate the processing time in microseconds
-		totalProcessingTimeMicroseconds.Add(duration)                  // Add the processing time to the total
+		elapsed := time.Since(start)                                   // Calculate the processing time
+		totalProcessingTimeMicroseconds.Add(elapsed.Microseconds())    // Add the processing time to the total
+		app.requestDurations.Observe(requestLabel(r, mw.statusCode), elapsed)
 	})
 }
+
+// requestLabel groups a request by method and status class, e.g. "GET 2xx", leaving out the path to bound cardinality.
+func requestLabel(r *http.Request, status int) string {
+	return fmt.Sprintf("%s %dxx", r.Method, status/100)
+}
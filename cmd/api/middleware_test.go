@@ -0,0 +1,504 @@
+// File: cmd/api/middleware_test.go
+// Description: test suite for middleware - rate limiting focused
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"golang.org/x/time/rate"
+)
+
+// TestNewRateLimitState tests the budget computed from a client's limiter
+func TestNewRateLimitState(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		rps      float64
+		burst    int
+		used     int
+		expected rateLimitState
+	}{
+		{
+			name:     "Fresh Limiter",
+			rps:      2,
+			burst:    4,
+			used:     0,
+			expected: rateLimitState{Limit: 4, Remaining: 4, Reset: 0, RetryAfter: 0},
+		},
+		{
+			name:     "Partially Used",
+			rps:      2,
+			burst:    4,
+			used:     3,
+			expected: rateLimitState{Limit: 4, Remaining: 1, Reset: 2, RetryAfter: 0},
+		},
+		{
+			name:     "Exhausted",
+			rps:      2,
+			burst:    4,
+			used:     4,
+			expected: rateLimitState{Limit: 4, Remaining: 0, Reset: 2, RetryAfter: 1},
+		},
+		{
+			name:     "Slow Refill",
+			rps:      0.5,
+			burst:    2,
+			used:     2,
+			expected: rateLimitState{Limit: 2, Remaining: 0, Reset: 4, RetryAfter: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := rate.NewLimiter(rate.Limit(tt.rps), tt.burst)
+			limiter.AllowN(now, tt.used)
+
+			state := newRateLimitState(limiter, now)
+			if state != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, state)
+			}
+		})
+	}
+}
+
+// TestRateLimitHeaders tests that responses carry X-RateLimit-* headers and 429s carry Retry-After
+func TestRateLimitHeaders(t *testing.T) {
+	app := newTestApp()
+	app.config.limiter.enabled = true
+	app.config.limiter.rps = 1
+	app.config.limiter.burst = 2
+
+	handler := app.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	expected := []struct {
+		status    int
+		remaining string
+	}{
+		{status: http.StatusOK, remaining: "1"},
+		{status: http.StatusOK, remaining: "0"},
+		{status: http.StatusTooManyRequests, remaining: "0"},
+	}
+
+	for i, want := range expected {
+		req := httptest.NewRequest(http.MethodGet, "/v1/products", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != want.status {
+			t.Fatalf("request %d: expected status %d, got %d", i+1, want.status, rr.Code)
+		}
+		if got := rr.Header().Get("X-RateLimit-Limit"); got != "2" {
+			t.Errorf("request %d: expected X-RateLimit-Limit 2, got %q", i+1, got)
+		}
+		if got := rr.Header().Get("X-RateLimit-Remaining"); got != want.remaining {
+			t.Errorf("request %d: expected X-RateLimit-Remaining %s, got %q", i+1, want.remaining, got)
+		}
+
+		if rr.Code == http.StatusTooManyRequests {
+			if got := rr.Header().Get("Retry-After"); got != "1" {
+				t.Errorf("expected Retry-After 1, got %q", got)
+			}
+
+			var body struct {
+				Error     string         `json:"error"`
+				RateLimit rateLimitState `json:"rate_limit"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode 429 body: %v", err)
+			}
+			if body.RateLimit.Limit != 2 || body.RateLimit.RetryAfter != 1 {
+				t.Errorf("unexpected rate_limit body: %+v", body.RateLimit)
+			}
+		}
+	}
+}
+
+// TestLoginBackoff tests that the lockout starts at the second failure and doubles up to the limit
+func TestLoginBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{failures: 1, expected: 0},
+		{failures: 2, expected: time.Second},
+		{failures: 3, expected: 2 * time.Second},
+		{failures: 5, expected: 8 * time.Second},
+		{failures: 200, expected: time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := loginBackoff(tt.failures, time.Second, time.Minute); got != tt.expected {
+			t.Errorf("failures %d: expected %s, got %s", tt.failures, tt.expected, got)
+		}
+	}
+}
+
+// TestLimitLogins tests the per-email and per-IP login throttling
+func TestLimitLogins(t *testing.T) {
+	newHandler := func(attempts int) http.Handler {
+		app := newTestApp()
+		app.config.limiter.loginEnabled = true
+		app.config.limiter.loginAttempts = attempts
+		app.config.limiter.loginBackoff = time.Minute
+		app.config.limiter.loginMaxBackoff = time.Hour
+
+		// The stand-in login accepts one password and checks the body is still readable
+		return app.limitLogins(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var input struct {
+				Email    string `json:"email"`
+				Password string `json:"password"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Email == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if input.Password != "right" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		}))
+	}
+
+	login := func(handler http.Handler, ip, email, password string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"email": %q, "password": %q}`, email, password)
+		req := httptest.NewRequest(http.MethodPost, "/v1/tokens/authentication", strings.NewReader(body))
+		req.RemoteAddr = ip + ":1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("failures lock out the email and the IP", func(t *testing.T) {
+		handler := newHandler(10)
+
+		for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+			if rr := login(handler, "192.0.2.1", "a@example.com", "wrong"); rr.Code != want {
+				t.Fatalf("attempt %d: expected status %d, got %d", i+1, want, rr.Code)
+			}
+		}
+
+		rr := login(handler, "192.0.2.1", "b@example.com", "right")
+		if rr.Code != http.StatusTooManyRequests {
+			t.Errorf("expected the IP to be locked, got %d", rr.Code)
+		}
+		if got := rr.Header().Get("Retry-After"); got != "60" {
+			t.Errorf("expected Retry-After 60, got %q", got)
+		}
+		if rr := login(handler, "192.0.2.2", "A@example.com", "right"); rr.Code != http.StatusTooManyRequests {
+			t.Errorf("expected the email to be locked from another IP, got %d", rr.Code)
+		}
+		if rr := login(handler, "192.0.2.2", "b@example.com", "right"); rr.Code != http.StatusCreated {
+			t.Errorf("expected another email and IP to log in, got %d", rr.Code)
+		}
+	})
+
+	t.Run("successful login clears the email", func(t *testing.T) {
+		handler := newHandler(10)
+
+		login(handler, "192.0.2.1", "a@example.com", "wrong")
+		login(handler, "192.0.2.2", "a@example.com", "right")
+		if rr := login(handler, "192.0.2.3", "a@example.com", "wrong"); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status 401, got %d", rr.Code)
+		}
+		if rr := login(handler, "192.0.2.4", "a@example.com", "right"); rr.Code != http.StatusCreated {
+			t.Errorf("expected the earlier failure to be forgotten, got %d", rr.Code)
+		}
+	})
+
+	t.Run("attempts per minute are limited", func(t *testing.T) {
+		handler := newHandler(2)
+
+		for i, want := range []int{http.StatusBadRequest, http.StatusBadRequest, http.StatusTooManyRequests} {
+			if rr := login(handler, "192.0.2.1", "", "x"); rr.Code != want {
+				t.Fatalf("attempt %d: expected status %d, got %d", i+1, want, rr.Code)
+			}
+		}
+	})
+}
+
+// TestClientLimiterSweep tests that idle clients are forgotten and counted
+func TestClientLimiterSweep(t *testing.T) {
+	var limiters rateLimiters
+	limiter := limiters.get("global", func() (float64, int) { return 1, 1 })
+	if again := limiters.get("global", nil); again != limiter {
+		t.Fatal("expected the same limiter for the same name")
+	}
+
+	now := time.Now()
+	limiter.allow("192.0.2.1:1234", now.Add(-limiterIdleTimeout-time.Second))
+	limiter.allow("192.0.2.2:1234", now)
+
+	if removed := limiter.sweep(now); removed != 1 {
+		t.Errorf("expected 1 idle client removed, got %d", removed)
+	}
+	if got := limiters.snapshot()["global"]; got != (limiterSnapshot{Clients: 1, Evicted: 1}) {
+		t.Errorf("expected 1 client and 1 eviction, got %+v", got)
+	}
+
+	// A forgotten client starts again with a full bucket
+	if allowed, _ := limiter.allow("192.0.2.1:1234", now); !allowed {
+		t.Error("expected the returning client to be allowed")
+	}
+}
+
+// TestRateLimitersLifecycle tests that the sweep runs between Start and Stop and that state outlives the router
+func TestRateLimitersLifecycle(t *testing.T) {
+	app := newTestApp()
+	app.config.limiter.enabled = true
+	app.config.limiter.rps = 1
+	app.config.limiter.burst = 1
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/products", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rr := httptest.NewRecorder()
+		app.rateLimit(ok).ServeHTTP(rr, req) // a new middleware each time, as when routes() is rebuilt
+		if rr.Code != want {
+			t.Fatalf("request %d: expected status %d, got %d", i+1, want, rr.Code)
+		}
+	}
+
+	app.limiters.get("global", nil).allow("192.0.2.9:1234", time.Now().Add(-time.Hour))
+	app.limiters.Start(time.Millisecond)
+	app.limiters.Start(time.Millisecond) // a second Start is ignored
+
+	deadline := time.Now().Add(time.Second)
+	for app.limiters.snapshot()["global"].Evicted == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	app.limiters.Stop()
+	app.limiters.Stop() // stopping twice is harmless
+
+	if got := app.limiters.snapshot()["global"]; got != (limiterSnapshot{Clients: 1, Evicted: 1}) {
+		t.Errorf("expected the idle client to be swept, got %+v", got)
+	}
+
+	var out strings.Builder
+	if err := writeLimiterMetrics(&out, app.limiters.snapshot()); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`rate_limiter_clients{limiter="global"} 1`, `rate_limiter_evicted_total{limiter="global"} 1`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics are missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+// TestRateLimitExemptions tests that exempt paths, networks and API keys bypass the limiter and are
+// counted, while other clients are limited by IP whatever their port
+func TestRateLimitExemptions(t *testing.T) {
+	app := newTestApp()
+	app.config.limiter.enabled = true
+	app.config.limiter.rps = 1
+	app.config.limiter.burst = 1
+	app.config.limiter.exemptPaths = defaultLimiterExemptPaths
+	app.config.limiter.exemptNetworks = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	app.config.limiter.exemptKeys = []string{"internal-service-key"}
+
+	handler := app.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name       string
+		path       string
+		remoteAddr string
+		key        string
+		expected   int
+	}{
+		{name: "first request", path: "/v1/products", remoteAddr: "192.0.2.1:1000", expected: http.StatusOK},
+		{name: "same client on another port", path: "/v1/products", remoteAddr: "192.0.2.1:2000", expected: http.StatusTooManyRequests},
+		{name: "health check", path: "/v1/healthcheck", remoteAddr: "192.0.2.1:1000", expected: http.StatusOK},
+		{name: "metrics scrape", path: "/v1/metrics/prometheus", remoteAddr: "192.0.2.1:1000", expected: http.StatusTooManyRequests},
+		{name: "wrong key", path: "/v1/products", remoteAddr: "192.0.2.1:1000", key: "guess", expected: http.StatusTooManyRequests},
+		{name: "trusted key", path: "/v1/products", remoteAddr: "192.0.2.1:1000", key: "internal-service-key", expected: http.StatusOK},
+		{name: "trusted network", path: "/v1/products", remoteAddr: "10.1.2.3:1000", expected: http.StatusOK},
+		{name: "trusted network again", path: "/v1/products", remoteAddr: "[::ffff:10.1.2.3]:1000", expected: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.RemoteAddr = tt.remoteAddr
+		if tt.key != "" {
+			req.Header.Set("X-API-Key", tt.key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expected {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.expected, rr.Code)
+		}
+	}
+
+	expected := map[string]int64{"path": 1, "api_key": 1, "network": 2}
+	if got := app.limiters.exemptions(); !maps.Equal(got, expected) {
+		t.Errorf("expected exemptions %v, got %v", expected, got)
+	}
+
+	var out strings.Builder
+	if err := writeLimiterExemptions(&out, app.limiters.exemptions()); err != nil {
+		t.Fatal(err)
+	}
+	if want := `rate_limiter_exempt_requests_total{reason="network"} 2`; !strings.Contains(out.String(), want) {
+		t.Errorf("metrics are missing %q:\n%s", want, out.String())
+	}
+}
+
+// TestEnableCORS tests the CORS headers sent to trusted and untrusted origins
+func TestEnableCORS(t *testing.T) {
+	tests := []struct {
+		name        string
+		origin      string
+		preflight   bool
+		credentials bool
+		maxAge      time.Duration
+		expected    map[string]string // header values, empty for headers that must be absent
+		status      int
+	}{
+		{
+			name:   "Trusted Origin",
+			origin: "https://pos.example.com",
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "https://pos.example.com",
+				"Access-Control-Expose-Headers":    "Location, Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset",
+				"Access-Control-Allow-Credentials": "",
+			},
+			status: http.StatusTeapot,
+		},
+		{
+			name:        "Credentials Allowed",
+			origin:      "https://pos.example.com",
+			credentials: true,
+			expected:    map[string]string{"Access-Control-Allow-Credentials": "true"},
+			status:      http.StatusTeapot,
+		},
+		{
+			name:      "Preflight With Max Age",
+			origin:    "https://pos.example.com",
+			preflight: true,
+			maxAge:    10 * time.Minute,
+			expected: map[string]string{
+				"Access-Control-Allow-Methods": "OPTIONS, PUT, PATCH, DELETE",
+				"Access-Control-Allow-Headers": "Authorization, Content-Type, X-Request-Timeout, X-Confirm-Password",
+				"Access-Control-Max-Age":       "600",
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:      "Preflight Without Max Age",
+			origin:    "https://pos.example.com",
+			preflight: true,
+			expected:  map[string]string{"Access-Control-Max-Age": ""},
+			status:    http.StatusOK,
+		},
+		{
+			name:        "Untrusted Origin",
+			origin:      "https://evil.example.com",
+			preflight:   true,
+			credentials: true,
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":      "",
+				"Access-Control-Allow-Credentials": "",
+				"Access-Control-Expose-Headers":    "",
+			},
+			status: http.StatusTeapot,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp()
+			app.config.cors.trustedOrigins = []string{"https://admin.example.com", "https://pos.example.com"}
+			app.config.cors.exposedHeaders = defaultExposedHeaders
+			app.config.cors.allowCredentials = tt.credentials
+			app.config.cors.maxAge = tt.maxAge
+
+			handler := app.enableCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/products", nil)
+			if tt.preflight {
+				req = httptest.NewRequest(http.MethodOptions, "/v1/products", nil)
+				req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+			}
+			req.Header.Set("Origin", tt.origin)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rr.Code)
+			}
+			for header, want := range tt.expected {
+				if got := rr.Header().Get(header); got != want {
+					t.Errorf("expected %s %q, got %q", header, want, got)
+				}
+			}
+			if vary := rr.Header().Values("Vary"); len(vary) != 2 {
+				t.Errorf("expected Vary on Origin and Access-Control-Request-Method, got %v", vary)
+			}
+		})
+	}
+}
+
+// TestRequirePasswordConfirmation tests that sensitive actions go ahead for fresh sessions or the
+// right password, and ask stale and impersonated sessions for the password
+func TestRequirePasswordConfirmation(t *testing.T) {
+	app := newTestApp()
+	app.config.reauthWindow = 5 * time.Minute
+	now := time.Date(2025, 3, 14, 15, 30, 0, 0, time.UTC)
+	app.clock = data.NewFrozenClock(now)
+	impersonator := int64(1)
+
+	handler := app.requirePasswordConfirmation(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name         string
+		loggedInAt   time.Time
+		impersonated bool
+		password     string
+		expected     int
+	}{
+		{name: "fresh session", loggedInAt: now.Add(-time.Minute), expected: http.StatusOK},
+		{name: "stale session", loggedInAt: now.Add(-time.Hour), expected: http.StatusUnauthorized},
+		{name: "stale session with password", loggedInAt: now.Add(-time.Hour), password: "SecurePassword123!", expected: http.StatusOK},
+		{name: "wrong password", loggedInAt: now.Add(-time.Minute), password: "guess", expected: http.StatusUnauthorized},
+		{name: "fresh impersonation", loggedInAt: now, impersonated: true, expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		user := &data.User{ID: 7, LoggedInAt: data.NewTimestamp(tt.loggedInAt)}
+		if err := user.Password.Set("SecurePassword123!"); err != nil {
+			t.Fatal(err)
+		}
+		if tt.impersonated {
+			user.ImpersonatedBy = &impersonator
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/users/2fa/setup", nil)
+		if tt.password != "" {
+			req.Header.Set("X-Confirm-Password", tt.password)
+		}
+		req = app.contextSetUser(req, user)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expected {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.expected, rr.Code, rr.Body.String())
+		}
+	}
+}
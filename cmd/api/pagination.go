@@ -0,0 +1,80 @@
+// File: cmd/api/pagination.go
+// Description: per-listing default and maximum page sizes
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// defaultPagePolicies are the page sizes of each paginated listing, keyed by the resource name
+// used with -page-sizes.
+var defaultPagePolicies = map[string]data.PagePolicy{
+	"activity":        data.DefaultPagePolicy,
+	"customers":       data.DefaultPagePolicy,
+	"duplicate_sales": data.DefaultPagePolicy,
+	"incidents":       data.DefaultPagePolicy,
+	"low_stock":       data.DefaultPagePolicy,
+	"price_history":   data.DefaultPagePolicy,
+	"products":        data.DefaultPagePolicy,
+	"sales":           data.DefaultPagePolicy,
+	"saved_reports":   data.DefaultPagePolicy,
+	"stock_movements": data.DefaultPagePolicy,
+	"users":           data.DefaultPagePolicy,
+}
+
+// parsePagePolicies overrides the default page policies with space separated resource=default:max
+// pairs, such as "sales=50:200 users=10:50". Resources left out keep their defaults.
+func parsePagePolicies(s string) (map[string]data.PagePolicy, error) {
+	policies := maps.Clone(defaultPagePolicies)
+	v := validator.New()
+
+	for _, field := range strings.Fields(s) {
+		resource, sizes, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not in the form resource=default:max", field)
+		}
+		if _, known := defaultPagePolicies[resource]; !known {
+			return nil, fmt.Errorf("unknown resource %q, expected one of %s", resource, strings.Join(slices.Sorted(maps.Keys(defaultPagePolicies)), ", "))
+		}
+
+		defaultSize, maxSize, ok := strings.Cut(sizes, ":")
+		if !ok {
+			return nil, fmt.Errorf("%q is not in the form resource=default:max", field)
+		}
+		var policy data.PagePolicy
+		var err error
+		if policy.Default, err = strconv.ParseInt(defaultSize, 10, 64); err != nil {
+			return nil, fmt.Errorf("%s: default page size must be an integer", resource)
+		}
+		if policy.Max, err = strconv.ParseInt(maxSize, 10, 64); err != nil {
+			return nil, fmt.Errorf("%s: maximum page size must be an integer", resource)
+		}
+
+		data.ValidatePagePolicy(v, resource, policy)
+		policies[resource] = policy
+	}
+
+	for resource, message := range v.Errors {
+		return nil, fmt.Errorf("%s: %s", resource, message)
+	}
+	return policies, nil
+}
+
+// pagePolicy returns the page sizes configured for a listing.
+func (app *app) pagePolicy(resource string) data.PagePolicy {
+	if policy, ok := app.config.pagination[resource]; ok {
+		return policy
+	}
+	if policy, ok := defaultPagePolicies[resource]; ok {
+		return policy
+	}
+	return data.DefaultPagePolicy
+}
@@ -0,0 +1,63 @@
+// File: cmd/api/pagination_test.go
+// Description: tests for the page size configuration of listings
+
+package main
+
+import (
+	"testing"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+)
+
+// TestParsePagePolicies tests parsing and validating -page-sizes overrides
+func TestParsePagePolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]data.PagePolicy
+		wantErr bool
+	}{
+		{name: "empty keeps defaults", input: "", want: map[string]data.PagePolicy{"sales": data.DefaultPagePolicy, "users": data.DefaultPagePolicy}},
+		{name: "overrides", input: "sales=50:200  users=10:50", want: map[string]data.PagePolicy{"sales": {Default: 50, Max: 200}, "users": {Default: 10, Max: 50}, "products": data.DefaultPagePolicy}},
+		{name: "unknown resource", input: "orders=10:20", wantErr: true},
+		{name: "missing maximum", input: "sales=50", wantErr: true},
+		{name: "missing sizes", input: "sales", wantErr: true},
+		{name: "not a number", input: "sales=ten:20", wantErr: true},
+		{name: "zero default", input: "sales=0:20", wantErr: true},
+		{name: "default above maximum", input: "sales=50:20", wantErr: true},
+		{name: "maximum above limit", input: "sales=50:5000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePagePolicies(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for resource, policy := range tt.want {
+				if got[resource] != policy {
+					t.Errorf("%s: got %+v, want %+v", resource, got[resource], policy)
+				}
+			}
+		})
+	}
+}
+
+// TestPagePolicy tests that listings fall back to the default policy when none is configured
+func TestPagePolicy(t *testing.T) {
+	app := newTestApp()
+	if got := app.pagePolicy("sales"); got != data.DefaultPagePolicy {
+		t.Errorf("unconfigured app: got %+v, want %+v", got, data.DefaultPagePolicy)
+	}
+
+	app.config.pagination = map[string]data.PagePolicy{"sales": {Default: 50, Max: 200}}
+	if got := app.pagePolicy("sales"); got != (data.PagePolicy{Default: 50, Max: 200}) {
+		t.Errorf("configured app: got %+v", got)
+	}
+}
@@ -0,0 +1,160 @@
+// File: cmd/api/passwords.go
+// Description: handlers for setting a new password through a one-time emailed token
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/mailer"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// passwordResetTokenTTL is how long the set-password token stays valid.
+const passwordResetTokenTTL = 45 * time.Minute
+
+// createPasswordResetTokenHandler emails a one-time set-password token to an activated user.
+// The response is the same whether or not the address belongs to an account.
+func (app *app) createPasswordResetTokenHandler(w http.ResponseWriter, r *http.Request) {
+	// Without a mailer the token could never reach the user
+	if app.mailer == nil {
+		app.mailerUnavailableResponse(w, r)
+		return
+	}
+
+	// PasswordResetPayload struct to hold the incoming JSON payload
+	var PasswordResetPayload struct {
+		Email string `json:"email"`
+	}
+
+	if err := app.readJSON(w, r, &PasswordResetPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateEmail(v, PasswordResetPayload.Email); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	env := envelope{"message": "if an activated account uses this email address, a password reset token has been sent to it"}
+
+	user, err := app.models.Users.GetByEmail(PasswordResetPayload.Email)
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		if err := app.writeJSON(w, http.StatusAccepted, env, nil); err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Throttled requests get the usual 202 rather than a 429, which would reveal that the account exists
+	throttled, _, err := app.emailThrottled(&user.ID, user.Email, mailer.PasswordResetEmail{})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if throttled {
+		app.logger.Warn("password reset email throttled", "user_id", user.ID)
+	}
+
+	if user.IsActive && !throttled {
+		// New replaces any earlier reset token, so only the latest email works
+		token, err := app.models.Tokens.New(user.ID, passwordResetTokenTTL, data.ScopePasswordReset)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		app.sendEmail(&user.ID, user.Email, user.Locale, mailer.PasswordResetEmail{
+			FirstName:          user.FirstName,
+			Email:              user.Email,
+			PasswordResetToken: token.Plaintext,
+		})
+		app.recordAuthEvent(r, data.AuthPasswordResetRequested, &user.ID)
+	}
+
+	if err := app.writeJSON(w, http.StatusAccepted, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// updatePasswordHandler sets a new password from a one-time token and ends every session of the user.
+func (app *app) updatePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	// UpdatePasswordPayload struct to hold the incoming JSON payload
+	var UpdatePasswordPayload struct {
+		TokenPlaintext string `json:"token"`
+		Password       string `json:"password"`
+	}
+
+	if err := app.readJSON(w, r, &UpdatePasswordPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	data.ValidateTokenPlaintext(v, UpdatePasswordPayload.TokenPlaintext)
+	data.ValidatePasswordPlaintext(v, UpdatePasswordPayload.Password)
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopePasswordReset, UpdatePasswordPayload.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired password reset token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := user.Password.Set(UpdatePasswordPayload.Password); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.models.Users.Update(user); err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// The token is single use, and sessions opened with the old password end here
+	for _, scope := range []string{data.ScopePasswordReset, data.ScopeAuthentication} {
+		if err := app.models.Tokens.DeleteAllForUser(scope, user.ID); err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	app.recordAuthEvent(r, data.AuthPasswordResetCompleted, &user.ID)
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"message": "your password was successfully reset"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// showPasswordPolicyHandler returns the active password rules so clients can validate before submitting.
+func (app *app) showPasswordPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.writeJSON(w, http.StatusOK, envelope{"password_policy": data.ActivePasswordPolicy()}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,107 @@
+// File: cmd/api/payments.go
+// Description: handlers for recording and listing the payments of a sale
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// listSalePaymentsHandler returns the payments of a sale and the balance left to pay.
+func (app *app) listSalePaymentsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	payments, err := app.models.Payments.GetForSale(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"payments": payments}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// recordSalePaymentsHandler records one or more payments, split across tenders as the customer
+// likes, that together settle the balance due on a sale.
+func (app *app) recordSalePaymentsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// RecordPaymentsPayload struct to hold the incoming JSON payload
+	var RecordPaymentsPayload struct {
+		Payments []*struct {
+			Method    string   `json:"method"`
+			Amount    float64  `json:"amount"`
+			Tendered  *float64 `json:"tendered"` // Optional - cash handed over, for working out the change
+			Reference string   `json:"reference"`
+		} `json:"payments"`
+	}
+
+	if err := app.readJSON(w, r, &RecordPaymentsPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	userID := app.contextGetUser(r).ID
+	payments := make([]*data.Payment, len(RecordPaymentsPayload.Payments))
+	for i, payment := range RecordPaymentsPayload.Payments {
+		if payment != nil {
+			payments[i] = &data.Payment{
+				Method:     payment.Method,
+				Amount:     payment.Amount,
+				Tendered:   payment.Tendered,
+				Reference:  payment.Reference,
+				RecordedBy: &userID,
+			}
+		}
+	}
+
+	v := validator.New()
+	if data.ValidatePayments(v, payments); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	state, err := app.models.Payments.Record(id, payments)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrSaleNotCompleted):
+			app.saleNotCompletedResponse(w, r)
+		case errors.Is(err, data.ErrInsufficientCash):
+			v.AddError("payments", fmt.Sprintf("must cover the balance due of %.2f %s, with enough cash tendered", state.BalanceDue, state.Currency))
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrOverpayment):
+			v.AddError("payments", fmt.Sprintf("must not be more than the balance due of %.2f %s", state.BalanceDue, state.Currency))
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"payments": state}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
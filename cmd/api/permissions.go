@@ -0,0 +1,139 @@
+// File: cmd/api/permissions.go
+// Description: per-user permission grant and revoke handlers
+
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// listUserPermissionsHandler returns the permissions a user holds and whether each was granted individually.
+func (app *app) listUserPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.readUserFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	permissions, err := app.models.Permissions.GetAllDetailedForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"permissions": permissions}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// grantUserPermissionHandler gives a user one permission beyond their role defaults.
+func (app *app) grantUserPermissionHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.readUserFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// GrantPermissionPayload struct to hold the incoming JSON payload
+	var GrantPermissionPayload struct {
+		Code string `json:"code"`
+	}
+
+	if err := app.readJSON(w, r, &GrantPermissionPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(GrantPermissionPayload.Code != "", "code", "must be provided")
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Admins can only hand out permissions they hold themselves
+	granted, err := app.models.Permissions.GetAllForUser(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !granted.Includes(GrantPermissionPayload.Code) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	if err := app.models.Permissions.Grant(user.ID, GrantPermissionPayload.Code); err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnknownPermission):
+			v.AddError("code", "must be a known permission code")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	permissions, err := app.models.Permissions.GetAllDetailedForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"permissions": permissions}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// revokeUserPermissionHandler removes one permission from a user.
+func (app *app) revokeUserPermissionHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.readUserFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	code := httprouter.ParamsFromContext(r.Context()).ByName("code")
+
+	if err := app.models.Permissions.Revoke(user.ID, code); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusNoContent, nil, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// readUserFromPath loads the user named by the :id URL parameter, writing a response and returning false on failure.
+func (app *app) readUserFromPath(w http.ResponseWriter, r *http.Request) (*data.User, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	user, err := app.models.Users.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return user, true
+}
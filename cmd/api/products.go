@@ -4,8 +4,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/Pedro-J-Kukul/salesapi/internal/data"
 	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
@@ -15,8 +18,12 @@ import (
 func (app *app) createProductHandler(w http.ResponseWriter, r *http.Request) {
 	// Create Payload Struct
 	var ProductCreatePayload struct {
-		Name  string  `json:"name"`
-		Price float64 `json:"price"`
+		Name     string   `json:"name"`
+		Price    float64  `json:"price"`
+		Currency string   `json:"currency"` // defaults to the base currency
+		Tags     []string `json:"tags"`
+
+		ReorderThreshold int64 `json:"reorder_threshold"` // Optional - 0 never reports the stock as low
 	}
 
 	err := app.readJSON(w, r, &ProductCreatePayload)
@@ -26,8 +33,16 @@ func (app *app) createProductHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	product := &data.Product{
-		Name:  ProductCreatePayload.Name,
-		Price: ProductCreatePayload.Price,
+		Name:      ProductCreatePayload.Name,
+		Price:     ProductCreatePayload.Price,
+		Currency:  ProductCreatePayload.Currency,
+		Tags:      data.NormalizeTags(ProductCreatePayload.Tags),
+		CreatedBy: &app.contextGetUser(r).ID,
+
+		ReorderThreshold: ProductCreatePayload.ReorderThreshold,
+	}
+	if product.Currency == "" {
+		product.Currency = app.config.baseCurrency
 	}
 
 	// Validate Product
@@ -37,6 +52,13 @@ func (app *app) createProductHandler(w http.ResponseWriter, r *http.Request) {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
+	if err := app.checkCurrency(v, product.Currency); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	} else if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
 
 	err = app.models.Products.Insert(product)
 	if err != nil {
@@ -62,13 +84,23 @@ func (app *app) listProductsHandler(w http.ResponseWriter, r *http.Request) {
 	ProductSortSafelist := []string{"id", "name", "price", "-id", "-name", "-price"}
 
 	// Read Query Parameters
-	filters := app.readFilters(query, "id", 20, ProductSortSafelist, v)
+	filters := app.readFilters(query, "products", "id", ProductSortSafelist, v)
 	// Create ProductFilter struct
 	productFilter := data.ProductFilter{
-		Filter:   filters,
-		MinPrice: app.getSingleFloatQueryParameter(query, "min_price", 0, v),
-		MaxPrice: app.getSingleFloatQueryParameter(query, "max_price", 0, v),
-		Name:     app.getSingleQueryParameter(query, "name", ""),
+		Filter:    filters,
+		MinPrice:  app.getSingleFloatQueryParameter(query, "min_price", 0, v),
+		MaxPrice:  app.getSingleFloatQueryParameter(query, "max_price", 0, v),
+		Name:      app.getSingleQueryParameter(query, "name", ""),
+		CreatedBy: app.getOptionalInt64QueryParameter(query, "created_by", v),
+		UpdatedBy: app.getOptionalInt64QueryParameter(query, "updated_by", v),
+		Tags:      data.NormalizeTags(app.getMultipleQueryParameter(query, "tags", nil)),
+	}
+	data.ValidateTags(v, "tags", productFilter.Tags)
+	if includeInactive := app.getOptionalBoolQueryParameter(query, "include_inactive", v); includeInactive != nil {
+		productFilter.IncludeInactive = *includeInactive
+	}
+	if includeArchived := app.getOptionalBoolQueryParameter(query, "include_archived", v); includeArchived != nil {
+		productFilter.IncludeArchived = *includeArchived
 	}
 
 	// Validate ProductFilter
@@ -77,12 +109,31 @@ func (app *app) listProductsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Prices are only converted when a currency is asked for
+	var fx *data.Conversion
+	if query.Has("currency") {
+		var err error
+		if fx, err = app.readConversion(query, v); err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		} else if !v.IsValid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	}
+
 	// Get Products from database
 	products, metadata, err := app.models.Products.GetAll(productFilter)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrInvalidSort):
+			app.invalidSortResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
+	convertPrices(fx, products...)
 	err = app.writeJSON(w, http.StatusOK, envelope{"products": products, "metadata": metadata}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -90,7 +141,113 @@ func (app *app) listProductsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// deleteProductsHandler handles deleting a product by ID.
+// bulkUpdatePricesHandler sets the prices of many products at once, from explicit prices or a rule
+// such as +10% on every product tagged "summer sale". With dry_run the changes are returned
+// without being saved.
+func (app *app) bulkUpdatePricesHandler(w http.ResponseWriter, r *http.Request) {
+	// BulkUpdatePricesPayload struct to hold the incoming JSON payload
+	var BulkUpdatePricesPayload struct {
+		data.PriceUpdate
+		DryRun bool `json:"dry_run"`
+	}
+
+	if err := app.readJSON(w, r, &BulkUpdatePricesPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	update := &BulkUpdatePricesPayload.PriceUpdate
+	if update.Rule != nil {
+		if tags := data.NormalizeTags([]string{update.Rule.Tag}); len(tags) > 0 {
+			update.Rule.Tag = tags[0]
+		} else {
+			update.Rule.Tag = ""
+		}
+	}
+
+	v := validator.New()
+	if data.ValidatePriceUpdate(v, update); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	changes, err := app.modelsFor(r).Products.BulkUpdatePrices(update, user.ID, BulkUpdatePricesPayload.DryRun)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			found := make(map[int64]bool, len(changes))
+			for _, change := range changes {
+				found[change.ID] = true
+			}
+			var missing []string
+			for _, price := range update.Prices {
+				if !found[price.ID] {
+					missing = append(missing, strconv.FormatInt(price.ID, 10))
+				}
+			}
+			v.AddError("prices", "contains unknown or archived products: "+strings.Join(missing, ", "))
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrInvalidData):
+			var outOfRange []string
+			for _, change := range changes {
+				if change.NewPrice < 0 || change.NewPrice >= data.MaxPrice {
+					outOfRange = append(outOfRange, strconv.FormatInt(change.ID, 10))
+				}
+			}
+			v.AddError("rule", "would take prices out of range for products: "+strings.Join(outOfRange, ", "))
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	changed := 0
+	ids := make([]int64, 0, len(changes))
+	for _, change := range changes {
+		if change.OldPrice != change.NewPrice {
+			changed++
+			ids = append(ids, change.ID)
+		}
+	}
+
+	if !BulkUpdatePricesPayload.DryRun && changed > 0 {
+		entry := &data.AuditEntry{
+			UserID:    &user.ID,
+			Action:    data.ActionBulkPriceUpdate,
+			Entity:    "products",
+			EntityIDs: ids,
+			Details:   map[string]any{"rule": update.Rule, "explicit": len(update.Prices)},
+		}
+		if err := app.models.Audit.Insert(entry); err != nil {
+			app.logger.Error("failed to write audit log", "action", entry.Action, "user_id", user.ID, "product_ids", ids, "error", err)
+		}
+	}
+
+	env := envelope{"dry_run": BulkUpdatePricesPayload.DryRun, "matched": len(changes), "changed": changed, "changes": changes}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// listTagsHandler returns every tag in use on unarchived products, with how many carry it.
+func (app *app) listTagsHandler(w http.ResponseWriter, r *http.Request) {
+	tags, err := app.models.Products.GetTags()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"tags": tags}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// deleteProductHandler handles deleting a product by ID. The product is archived rather than
+// removed, so the sales recorded against it are kept.
 func (app *app) deleteProductHandler(w http.ResponseWriter, r *http.Request) {
 	// Read ID parameter from URL
 	id, err := app.readIDParameter(r)
@@ -99,11 +256,12 @@ func (app *app) deleteProductHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete product from database
-	err = app.models.Products.Delete(id)
+	// Archive product in database
+	product := &data.Product{ID: id, IsArchived: true, UpdatedBy: &app.contextGetUser(r).ID}
+	err = app.models.Products.SetArchived(product)
 	if err != nil {
 		switch {
-		case err == data.ErrRecordNotFound:
+		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
@@ -132,7 +290,7 @@ func (app *app) updateProductHandler(w http.ResponseWriter, r *http.Request) {
 	product, err := app.models.Products.Get(id)
 	if err != nil {
 		switch {
-		case err == data.ErrRecordNotFound:
+		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
@@ -142,8 +300,12 @@ func (app *app) updateProductHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Create Payload Struct
 	var ProductUpdatePayload struct {
-		Name  *string  `json:"name"`
-		Price *float64 `json:"price"`
+		Name     *string   `json:"name"`
+		Price    *float64  `json:"price"`
+		Currency *string   `json:"currency"`
+		Tags     *[]string `json:"tags"` // replaces every tag, [] removes them all
+
+		ReorderThreshold *int64 `json:"reorder_threshold"`
 	}
 
 	err = app.readJSON(w, r, &ProductUpdatePayload)
@@ -159,6 +321,16 @@ func (app *app) updateProductHandler(w http.ResponseWriter, r *http.Request) {
 	if ProductUpdatePayload.Price != nil {
 		product.Price = *ProductUpdatePayload.Price
 	}
+	if ProductUpdatePayload.Currency != nil {
+		product.Currency = *ProductUpdatePayload.Currency
+	}
+	if ProductUpdatePayload.Tags != nil {
+		product.Tags = data.NormalizeTags(*ProductUpdatePayload.Tags)
+	}
+	if ProductUpdatePayload.ReorderThreshold != nil {
+		product.ReorderThreshold = *ProductUpdatePayload.ReorderThreshold
+	}
+	product.UpdatedBy = &app.contextGetUser(r).ID
 
 	// Validate updated product
 	v := validator.New()
@@ -166,11 +338,23 @@ func (app *app) updateProductHandler(w http.ResponseWriter, r *http.Request) {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
+	if err := app.checkCurrency(v, product.Currency); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	} else if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
 
-	// Update product in database
+	// Update product in database, a price change is added to its history
 	err = app.models.Products.Update(product)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -191,17 +375,31 @@ func (app *app) getProductHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Prices are only converted when a currency is asked for
+	var fx *data.Conversion
+	if query := r.URL.Query(); query.Has("currency") {
+		v := validator.New()
+		if fx, err = app.readConversion(query, v); err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		} else if !v.IsValid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	}
+
 	// Fetch product from database
 	product, err := app.models.Products.Get(id)
 	if err != nil {
 		switch {
-		case err == data.ErrRecordNotFound:
+		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
+	convertPrices(fx, product)
 
 	// Return the product
 	err = app.writeJSON(w, http.StatusOK, envelope{"product": product}, nil)
@@ -210,3 +408,132 @@ func (app *app) getProductHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// deactivateProductHandler takes a product off sale while keeping it for historical sales.
+func (app *app) deactivateProductHandler(w http.ResponseWriter, r *http.Request) {
+	app.setProductActive(w, r, false)
+}
+
+// reactivateProductHandler puts a deactivated product back on sale.
+func (app *app) reactivateProductHandler(w http.ResponseWriter, r *http.Request) {
+	app.setProductActive(w, r, true)
+}
+
+// unarchiveProductHandler restores an archived product to the product listings.
+func (app *app) unarchiveProductHandler(w http.ResponseWriter, r *http.Request) {
+	// Read ID parameter from URL
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Fetch existing product from database
+	product, err := app.models.Products.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	product.IsArchived = false
+	product.UpdatedBy = &app.contextGetUser(r).ID
+
+	if err := app.models.Products.SetArchived(product); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"product": product}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// setProductActive sets the active flag of the product named by the :id URL parameter.
+func (app *app) setProductActive(w http.ResponseWriter, r *http.Request, active bool) {
+	// Read ID parameter from URL
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Fetch existing product from database
+	product, err := app.models.Products.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	product.IsActive = active
+	product.UpdatedBy = &app.contextGetUser(r).ID
+
+	if err := app.models.Products.SetActive(product); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"product": product}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// listProductPriceHistoryHandler returns the price changes of a product, newest first.
+func (app *app) listProductPriceHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	filter := app.readFilters(r.URL.Query(), "price_history", "-changed_at", []string{"-changed_at"}, v)
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	product, err := app.models.Products.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	changes, metadata, err := app.models.Products.GetPriceHistory(id, filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"price": product.Price, "price_history": changes, "metadata": metadata}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
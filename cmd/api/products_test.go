@@ -5,6 +5,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/Pedro-J-Kukul/salesapi/internal/data"
 	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/julienschmidt/httprouter"
 )
 
 // TestCreateProductHandler tests the product creation endpoint
@@ -88,6 +90,9 @@ func TestProductValidation(t *testing.T) {
 		name          string
 		productName   string
 		productPrice  float64
+		currency      string
+		tags          []string
+		threshold     int64
 		expectedValid bool
 	}{
 		{
@@ -120,13 +125,69 @@ func TestProductValidation(t *testing.T) {
 			productPrice:  10.0,
 			expectedValid: false,
 		},
+		{
+			name:          "Lower Case Currency",
+			productName:   "Product",
+			productPrice:  10.0,
+			currency:      "bzd",
+			expectedValid: false,
+		},
+		{
+			name:          "Currency Symbol",
+			productName:   "Product",
+			productPrice:  10.0,
+			currency:      "$",
+			expectedValid: false,
+		},
+		{
+			name:          "Promotion Tags",
+			productName:   "Product",
+			productPrice:  10.0,
+			tags:          []string{"  Summer   Sale ", "clearance", "summer sale", ""},
+			expectedValid: true,
+		},
+		{
+			name:          "Tag With Punctuation",
+			productName:   "Product",
+			productPrice:  10.0,
+			tags:          []string{"50% off!"},
+			expectedValid: false,
+		},
+		{
+			name:          "Too Many Tags",
+			productName:   "Product",
+			productPrice:  10.0,
+			tags:          []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o", "p", "q", "r", "s", "t", "u"},
+			expectedValid: false,
+		},
+		{
+			name:          "Reorder Threshold",
+			productName:   "Product",
+			productPrice:  10.0,
+			threshold:     25,
+			expectedValid: true,
+		},
+		{
+			name:          "Negative Reorder Threshold",
+			productName:   "Product",
+			productPrice:  10.0,
+			threshold:     -1,
+			expectedValid: false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			product := &data.Product{
-				Name:  tt.productName,
-				Price: tt.productPrice,
+				Name:     tt.productName,
+				Price:    tt.productPrice,
+				Currency: tt.currency,
+				Tags:     data.NormalizeTags(tt.tags),
+
+				ReorderThreshold: tt.threshold,
+			}
+			if product.Currency == "" {
+				product.Currency = data.DefaultCurrency
 			}
 
 			v := validator.New()
@@ -244,10 +305,132 @@ func TestProductURLParameters(t *testing.T) {
 	}
 }
 
+// TestListProductPriceHistoryHandler tests the checks made before the price history is loaded
+func TestListProductPriceHistoryHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		query          string
+		expectedStatus int
+	}{
+		{name: "Invalid ID", id: "abc", expectedStatus: http.StatusNotFound},
+		{name: "Zero ID", id: "0", expectedStatus: http.StatusNotFound},
+		{name: "Unknown Sort", id: "3", query: "?sort=old_price", expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Page Too Large", id: "3", query: "?page_size=500", expectedStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp()
+			req := httptest.NewRequest(http.MethodGet, "/v1/products/"+tt.id+"/price-history"+tt.query, nil)
+			params := httprouter.Params{{Key: "id", Value: tt.id}}
+			req = req.WithContext(context.WithValue(req.Context(), httprouter.ParamsKey, params))
+			rr := httptest.NewRecorder()
+
+			app.listProductPriceHistoryHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+// TestArchiveProductHandlers tests that delete and unarchive reject bad IDs before touching the database
+func TestArchiveProductHandlers(t *testing.T) {
+	app := newTestApp()
+
+	tests := []struct {
+		name    string
+		method  string
+		handler http.HandlerFunc
+		id      string
+	}{
+		{"Delete Invalid ID", http.MethodDelete, app.deleteProductHandler, "abc"},
+		{"Delete Negative ID", http.MethodDelete, app.deleteProductHandler, "-4"},
+		{"Unarchive Invalid ID", http.MethodPost, app.unarchiveProductHandler, "abc"},
+		{"Unarchive Zero ID", http.MethodPost, app.unarchiveProductHandler, "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/v1/products/"+tt.id, nil)
+			params := httprouter.Params{{Key: "id", Value: tt.id}}
+			req = req.WithContext(context.WithValue(req.Context(), httprouter.ParamsKey, params))
+			req = app.contextSetUser(req, &data.User{ID: 7})
+			rr := httptest.NewRecorder()
+
+			tt.handler(rr, req)
+
+			if rr.Code != http.StatusNotFound {
+				t.Errorf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+			}
+		})
+	}
+}
+
 // newTestApp creates a minimal app instance for testing
 func newTestApp() *app {
 	logger := setUpLogger("test")
-	return &app{
+	app := &app{
 		logger: logger,
 	}
+	app.config.tokens.activationTTL = defaultActivationTokenTTL
+	app.config.tokens.authenticationTTL = defaultAuthenticationTokenTTL
+	return app
+}
+
+// TestNormalizeTags tests that tags are trimmed, lower cased and deduplicated in order
+func TestNormalizeTags(t *testing.T) {
+	got := data.NormalizeTags([]string{" Summer  Sale", "clearance", "SUMMER SALE", "", "  "})
+	expected := []string{"summer sale", "clearance"}
+
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, got)
+		}
+	}
+	if tags := data.NormalizeTags(nil); tags == nil {
+		t.Error("expected an empty slice rather than nil, so products are stored without tags")
+	}
+}
+
+// TestBulkUpdatePricesHandlerValidation tests that malformed bulk price updates are rejected before the database is used
+func TestBulkUpdatePricesHandlerValidation(t *testing.T) {
+	app := newTestApp()
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{"Empty Update", `{"dry_run": true}`, http.StatusUnprocessableEntity},
+		{"Prices And Rule", `{"prices": [{"id": 1, "price": 5}], "rule": {"tag": "summer sale", "percent": 10}}`, http.StatusUnprocessableEntity},
+		{"Duplicate Product", `{"prices": [{"id": 1, "price": 5}, {"id": 1, "price": 6}]}`, http.StatusUnprocessableEntity},
+		{"Negative Price", `{"prices": [{"id": 1, "price": -5}]}`, http.StatusUnprocessableEntity},
+		{"Rule Without Selection", `{"rule": {"percent": 10}}`, http.StatusUnprocessableEntity},
+		{"Rule Without Adjustment", `{"rule": {"tag": "summer sale"}}`, http.StatusUnprocessableEntity},
+		{"Rule Percent And Amount", `{"rule": {"tag": "summer sale", "percent": 10, "amount": 1}}`, http.StatusUnprocessableEntity},
+		{"Rule Removes Whole Price", `{"rule": {"product_ids": [1, 2], "percent": -100}}`, http.StatusUnprocessableEntity},
+		{"Rule Blank Tag", `{"rule": {"tag": "   ", "percent": 10}}`, http.StatusUnprocessableEntity},
+		{"Unknown Field", `{"rule": {"category": "drinks", "percent": 10}}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/v1/admin/products/bulk-price", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req = app.contextSetUser(req, &data.User{ID: 7})
+			rr := httptest.NewRecorder()
+
+			app.bulkUpdatePricesHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
 }
@@ -0,0 +1,163 @@
+// File: cmd/api/readiness.go
+// Description: database readiness tracking and request gating
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+)
+
+// readinessFailureThreshold is how many consecutive failed pings mark the database as unavailable.
+const readinessFailureThreshold = 2
+
+// readiness tracks whether the database is reachable, fed by periodic pings.
+// The zero value reports the database as available.
+type readiness struct {
+	unavailable atomic.Bool // read on every request, so kept outside the mutex
+
+	mu          sync.Mutex
+	failures    int       // consecutive failed pings
+	lastChecked time.Time // time of the most recent ping
+	lastError   string    // error from the most recent failed ping
+	transitions int64     // number of times the state has flipped
+}
+
+// readinessSnapshot is the readiness state published in metrics and the healthcheck.
+type readinessSnapshot struct {
+	Ready       bool           `json:"ready"`
+	Failures    int            `json:"consecutive_failures"`
+	LastChecked data.Timestamp `json:"last_checked"`
+	LastError   string         `json:"last_error,omitempty"`
+	Transitions int64          `json:"transitions"`
+}
+
+// ready reports whether requests that need the database should be served.
+func (rd *readiness) ready() bool {
+	return !rd.unavailable.Load()
+}
+
+// record updates the state with the result of a ping and reports whether the state flipped.
+func (rd *readiness) record(err error, at time.Time) bool {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	rd.lastChecked = at
+	wasReady := !rd.unavailable.Load()
+
+	if err == nil {
+		rd.failures = 0
+		rd.lastError = ""
+		rd.unavailable.Store(false)
+	} else {
+		rd.failures++
+		rd.lastError = err.Error()
+		if rd.failures >= readinessFailureThreshold {
+			rd.unavailable.Store(true)
+		}
+	}
+
+	changed := wasReady != !rd.unavailable.Load()
+	if changed {
+		rd.transitions++
+	}
+	return changed
+}
+
+// snapshot returns a copy of the current state.
+func (rd *readiness) snapshot() readinessSnapshot {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	return readinessSnapshot{
+		Ready:       !rd.unavailable.Load(),
+		Failures:    rd.failures,
+		LastChecked: data.NewTimestamp(rd.lastChecked),
+		LastError:   rd.lastError,
+		Transitions: rd.transitions,
+	}
+}
+
+// monitorDatabase pings the database on an interval until ctx is cancelled, updating the readiness state.
+func (app *app) monitorDatabase(ctx context.Context) {
+	ticker := time.NewTicker(app.config.db.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, app.config.db.healthTimeout)
+		err := app.db.PingContext(pingCtx)
+		cancel()
+
+		if app.readiness.record(err, time.Now()) {
+			if app.readiness.ready() {
+				app.logger.Info("database is reachable again, accepting requests")
+				app.alert("Sales API recovered", "the database is reachable again")
+			} else {
+				app.logger.Error("database is unreachable, rejecting requests", slog.Any("error", err))
+				app.alert("Sales API is down", "the database is unreachable: "+err.Error())
+			}
+		}
+	}
+}
+
+// requireReady is a middleware that short-circuits requests with a 503 while the database is unavailable.
+func (app *app) requireReady(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The healthcheck and status page stay up so the outage can be observed. Metrics need a token,
+		// which cannot be checked without the database, so during an outage they come from the admin listener
+		if r.URL.Path == "/v1/healthcheck" || r.URL.Path == "/v1/status" || r.URL.Path == "/v1/version" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !app.readiness.ready() {
+			retryAfter := int(app.config.db.healthInterval.Seconds())
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			app.serviceUnavailableResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// healthcheckHandler reports the application status and database readiness.
+func (app *app) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
+	snapshot := app.readiness.snapshot()
+
+	status := "available"
+	code := http.StatusOK
+	if !snapshot.Ready {
+		status = "unavailable"
+		code = http.StatusServiceUnavailable
+	}
+
+	env := envelope{
+		"status": status,
+		"system_info": map[string]string{
+			"environment": app.config.env,
+			"version":     version,
+		},
+		"database": snapshot,
+	}
+
+	if err := app.writeJSON(w, code, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,129 @@
+// File: cmd/api/readiness_test.go
+// Description: test suite for database readiness gating
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestReadinessTransitions tests that readiness flips only after repeated failures and recovers on success
+func TestReadinessTransitions(t *testing.T) {
+	pingErr := errors.New("connection refused")
+
+	tests := []struct {
+		name          string
+		results       []error
+		expectedReady bool
+		expectedFlips int64
+	}{
+		{
+			name:          "Healthy Pings",
+			results:       []error{nil, nil, nil},
+			expectedReady: true,
+			expectedFlips: 0,
+		},
+		{
+			name:          "Single Failure Tolerated",
+			results:       []error{nil, pingErr},
+			expectedReady: true,
+			expectedFlips: 0,
+		},
+		{
+			name:          "Consecutive Failures Mark Unavailable",
+			results:       []error{pingErr, pingErr},
+			expectedReady: false,
+			expectedFlips: 1,
+		},
+		{
+			name:          "Recovers After Success",
+			results:       []error{pingErr, pingErr, pingErr, nil},
+			expectedReady: true,
+			expectedFlips: 2,
+		},
+		{
+			name:          "Failure Count Resets On Success",
+			results:       []error{pingErr, nil, pingErr},
+			expectedReady: true,
+			expectedFlips: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rd readiness
+			for _, err := range tt.results {
+				rd.record(err, time.Now())
+			}
+
+			snapshot := rd.snapshot()
+			if snapshot.Ready != tt.expectedReady {
+				t.Errorf("expected ready=%v, got=%v", tt.expectedReady, snapshot.Ready)
+			}
+			if snapshot.Transitions != tt.expectedFlips {
+				t.Errorf("expected %d transitions, got %d", tt.expectedFlips, snapshot.Transitions)
+			}
+		})
+	}
+}
+
+// TestRequireReadyMiddleware tests that requests are rejected with 503 while the database is unavailable
+func TestRequireReadyMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		ready          bool
+		expectedStatus int
+	}{
+		{
+			name:           "Ready Passes Through",
+			path:           "/v1/products",
+			ready:          true,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Unavailable Rejects Requests",
+			path:           "/v1/products",
+			ready:          false,
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:           "Unavailable Still Serves Healthcheck",
+			path:           "/v1/healthcheck",
+			ready:          false,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Unavailable Rejects Metrics",
+			path:           "/v1/metrics",
+			ready:          false,
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp()
+			app.config.db.healthInterval = 5 * time.Second
+			app.readiness.unavailable.Store(!tt.ready)
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			rr := httptest.NewRecorder()
+			app.requireReady(next).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if rr.Code == http.StatusServiceUnavailable && rr.Header().Get("Retry-After") != "5" {
+				t.Errorf("expected Retry-After of 5, got %q", rr.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
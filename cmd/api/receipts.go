@@ -0,0 +1,352 @@
+// File: cmd/api/receipts.go
+// Description: email receipts for customers who opted in, the handler recording that choice, and
+// printable receipts downloaded or emailed on request
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/mailer"
+	"github.com/Pedro-J-Kukul/salesapi/internal/receipt"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// receiptFormats are the formats a printable receipt can be rendered in.
+var receiptFormats = []string{"html", "pdf"}
+
+// emailReceipt emails the itemized receipt of a sale to its customer in the background, when the
+// sale has a customer email that opted in to email receipts. The email is tracked like any other,
+// so its delivery shows in the email log. Times are shown in loc, the cashier's timezone.
+func (app *app) emailReceipt(sale *data.Sale, loc *time.Location) {
+	if app.mailer == nil || sale.CustomerEmail == "" {
+		return
+	}
+
+	app.background(func() {
+		logger := app.logger.With("sale_id", sale.ID)
+
+		wants, err := app.models.Receipts.WantsEmail(sale.CustomerEmail)
+		if err != nil {
+			logger.Error("failed to check the receipt preference", "error", err)
+			return
+		}
+		if !wants {
+			return
+		}
+
+		msg, err := app.receiptEmail(sale, loc)
+		if err != nil {
+			logger.Error("failed to prepare the receipt email", "error", err)
+			return
+		}
+		app.sendEmail(nil, sale.CustomerEmail, mailer.DefaultLocale, msg)
+	})
+}
+
+// saleProductNames returns the current name of every product of a sale. Products deleted since
+// are named by their ID.
+func (app *app) saleProductNames(sale *data.Sale) (map[int64]string, error) {
+	ids := make([]int64, 0, len(sale.Items))
+	for _, item := range sale.Items {
+		ids = append(ids, item.ProductID)
+	}
+	names, err := app.models.Products.GetNames(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if _, ok := names[id]; !ok {
+			names[id] = fmt.Sprintf("Product #%d", id)
+		}
+	}
+	return names, nil
+}
+
+// receiptEmail builds the receipt email of a sale, naming its products as they are now called.
+func (app *app) receiptEmail(sale *data.Sale, loc *time.Location) (mailer.ReceiptEmail, error) {
+	names, err := app.saleProductNames(sale)
+	if err != nil {
+		return mailer.ReceiptEmail{}, err
+	}
+
+	msg := mailer.ReceiptEmail{
+		SaleID:   sale.ID,
+		SoldAt:   sale.SoldAt.In(loc).Format("2 Jan 2006 15:04 MST"),
+		Items:    make([]mailer.ReceiptItem, 0, len(sale.Items)),
+		Subtotal: fmt.Sprintf("%.2f", sale.Subtotal),
+		Discount: fmt.Sprintf("%.2f", sale.Discount),
+		Tax:      fmt.Sprintf("%.2f", sale.Tax),
+		Total:    fmt.Sprintf("%.2f", sale.Total),
+		Currency: sale.Currency,
+	}
+	for _, item := range sale.Items {
+		msg.Items = append(msg.Items, mailer.ReceiptItem{
+			Name:      names[item.ProductID],
+			Quantity:  item.Quantity,
+			UnitPrice: fmt.Sprintf("%.2f", item.UnitPrice),
+			LineTotal: fmt.Sprintf("%.2f", item.LineTotal),
+		})
+	}
+	return msg, nil
+}
+
+// setReceiptPreferenceHandler records whether a customer address wants its receipts emailed, for
+// customers who change their mind away from a sale.
+func (app *app) setReceiptPreferenceHandler(w http.ResponseWriter, r *http.Request) {
+	// ReceiptPreferencePayload struct to hold the incoming JSON payload
+	var ReceiptPreferencePayload struct {
+		Email         string `json:"email"`
+		EmailReceipts *bool  `json:"email_receipts"`
+	}
+
+	if err := app.readJSON(w, r, &ReceiptPreferencePayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	data.ValidateEmail(v, ReceiptPreferencePayload.Email)
+	v.Check(ReceiptPreferencePayload.EmailReceipts != nil, "email_receipts", "must be provided")
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	preference := &data.ReceiptPreference{
+		Email:         ReceiptPreferencePayload.Email,
+		EmailReceipts: *ReceiptPreferencePayload.EmailReceipts,
+		UpdatedBy:     &app.contextGetUser(r).ID,
+	}
+	if err := app.models.Receipts.SetPreference(preference); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"receipt_preference": preference}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// saleReceipt builds the printable receipt of a sale with the store details, its payments and its
+// customer. Times are shown in loc.
+func (app *app) saleReceipt(sale *data.Sale, loc *time.Location) (*receipt.Receipt, error) {
+	names, err := app.saleProductNames(sale)
+	if err != nil {
+		return nil, err
+	}
+	payments, err := app.models.Payments.GetForSale(sale.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	rcpt := &receipt.Receipt{
+		Store:      app.config.store,
+		SaleID:     sale.ID,
+		SoldAt:     sale.SoldAt.In(loc).Format("2 Jan 2006 15:04 MST"),
+		Customer:   sale.CustomerEmail,
+		Items:      make([]receipt.Item, 0, len(sale.Items)),
+		Subtotal:   fmt.Sprintf("%.2f", sale.Subtotal),
+		Discount:   fmt.Sprintf("%.2f", sale.Discount),
+		Tax:        fmt.Sprintf("%.2f", sale.Tax),
+		Total:      fmt.Sprintf("%.2f", sale.Total),
+		Currency:   sale.Currency,
+		Payments:   make([]receipt.Payment, 0, len(payments.Payments)),
+		BalanceDue: fmt.Sprintf("%.2f", max(payments.BalanceDue, 0)),
+		Note:       sale.Note,
+	}
+	if sale.Status != data.SaleCompleted {
+		rcpt.Status = strings.ToUpper(sale.Status)
+	}
+	if sale.CustomerID != nil {
+		customer, err := app.models.Customers.Get(*sale.CustomerID)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			return nil, err
+		}
+		if customer != nil {
+			rcpt.Customer = customer.Name
+		}
+	}
+	for _, item := range sale.Items {
+		rcpt.Items = append(rcpt.Items, receipt.Item{
+			Name:      names[item.ProductID],
+			Quantity:  item.Quantity,
+			UnitPrice: fmt.Sprintf("%.2f", item.UnitPrice),
+			LineTotal: fmt.Sprintf("%.2f", item.LineTotal),
+		})
+	}
+	for _, payment := range payments.Payments {
+		rcpt.Payments = append(rcpt.Payments, receipt.Payment{
+			Method:    payment.Method,
+			Amount:    fmt.Sprintf("%.2f", payment.Amount),
+			Change:    fmt.Sprintf("%.2f", payment.Change),
+			Reference: payment.Reference,
+		})
+	}
+	return rcpt, nil
+}
+
+// saleReceiptHandler renders the printable receipt of a sale as HTML, or as PDF with ?format=pdf.
+// ?download=true serves it as a file to save rather than to show.
+func (app *app) saleReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	v := validator.New()
+	format := app.getSingleQueryParameter(query, "format", "html")
+	v.Check(v.Permitted(format, receiptFormats...), "format", "must be html or pdf")
+	download := app.getOptionalBoolQueryParameter(query, "download", v)
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	sale, err := app.models.Sales.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	rcpt, err := app.saleReceipt(sale, app.contextGetUser(r).Location())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Rendered in full before anything is written, so a failure still gets an error response
+	body := new(bytes.Buffer)
+	contentType := "text/html; charset=utf-8"
+	if format == "pdf" {
+		contentType = "application/pdf"
+		err = rcpt.PDF(body)
+	} else {
+		err = rcpt.HTML(body)
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	disposition := "inline"
+	if download != nil && *download {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": fmt.Sprintf("receipt-%d.%s", sale.ID, format)}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := body.WriteTo(w); err != nil {
+		app.logError(r, err)
+	}
+}
+
+// emailSaleReceiptHandler emails the receipt of a sale with its PDF attached, to the given email
+// or else to the sale's customer email. It is sent whether or not the address opted in to email
+// receipts, since the customer asked for it.
+func (app *app) emailSaleReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	if app.mailer == nil {
+		app.mailerUnavailableResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// EmailReceiptPayload struct to hold the incoming JSON payload
+	var EmailReceiptPayload struct {
+		Email string `json:"email"` // Optional - the sale's customer email when left out
+	}
+
+	if r.ContentLength != 0 {
+		if err := app.readJSON(w, r, &EmailReceiptPayload); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	sale, err := app.models.Sales.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	recipient := EmailReceiptPayload.Email
+	if recipient == "" {
+		recipient = sale.CustomerEmail
+	}
+	v := validator.New()
+	if recipient == "" {
+		v.AddError("email", "must be provided for a sale without a customer email")
+	} else {
+		data.ValidateEmail(v, recipient)
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	throttled, retryAfter, err := app.emailThrottled(nil, recipient, mailer.ReceiptEmail{})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if throttled {
+		app.emailRateLimitResponse(w, r, retryAfter)
+		return
+	}
+
+	loc := app.contextGetUser(r).Location()
+	msg, err := app.receiptEmail(sale, loc)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	rcpt, err := app.saleReceipt(sale, loc)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	pdf := new(bytes.Buffer)
+	if err := rcpt.PDF(pdf); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.sendEmail(nil, recipient, mailer.DefaultLocale, msg, mailer.Attachment{
+		Filename: fmt.Sprintf("receipt-%d.pdf", sale.ID),
+		Data:     pdf.Bytes(),
+	})
+
+	if err := app.writeJSON(w, http.StatusAccepted, envelope{"message": fmt.Sprintf("the receipt is being emailed to %s", recipient)}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,159 @@
+// File: cmd/api/refunds.go
+// Description: handlers for refunding sales and listing their refunds
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// refundSaleHandler refunds the given items of a sale, or everything left on it when no items
+// are given, putting the goods back into stock. Sales older than the returns window are only
+// refunded by users allowed to override the policy, with a reason, and the override is audited.
+func (app *app) refundSaleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// RefundSalePayload struct to hold the incoming JSON payload
+	var RefundSalePayload struct {
+		Reason string `json:"reason"`
+		Items  []*struct {
+			ProductID int64 `json:"product_id"`
+			Quantity  int64 `json:"quantity"`
+		} `json:"items"` // Optional - refunds the whole sale when left out
+	}
+
+	if err := app.readJSON(w, r, &RefundSalePayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	refund := &data.Refund{
+		SaleID:    id,
+		Reason:    RefundSalePayload.Reason,
+		Items:     make([]*data.RefundItem, len(RefundSalePayload.Items)),
+		CreatedBy: &app.contextGetUser(r).ID,
+	}
+	for i, item := range RefundSalePayload.Items {
+		if item != nil {
+			refund.Items[i] = &data.RefundItem{ProductID: item.ProductID, Quantity: item.Quantity}
+		}
+	}
+
+	v := validator.New()
+	if data.ValidateRefund(v, refund); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	overridden, err := app.checkReturnsWindow(v, refund)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Refunds.Insert(refund); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrSaleNotCompleted):
+			app.saleNotCompletedResponse(w, r)
+		case errors.Is(err, data.ErrRefundExceedsSale):
+			v.AddError("items", "must only refund products of the sale, up to the units not refunded yet")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if overridden {
+		entry := &data.AuditEntry{
+			UserID:    refund.CreatedBy,
+			Action:    data.ActionRefundOverride,
+			Entity:    "sales",
+			EntityIDs: []int64{refund.SaleID},
+			Details:   map[string]any{"refund_id": refund.ID, "reason": refund.Reason, "window_days": app.config.returns.windowDays},
+		}
+		if err := app.models.Audit.Insert(entry); err != nil {
+			app.logger.Error("failed to write audit log", "action", entry.Action, "user_id", *refund.CreatedBy, "sale_id", refund.SaleID, "error", err)
+		}
+	}
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"refund": refund}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// checkReturnsWindow adds a validation error when the sale of a refund is older than the returns
+// window and the user may not override it, or did not give a reason for doing so. It reports
+// whether the refund overrides the policy, and returns ErrRecordNotFound when the sale is gone.
+func (app *app) checkReturnsWindow(v *validator.Validator, refund *data.Refund) (bool, error) {
+	days := app.config.returns.windowDays
+	if days == 0 {
+		return false, nil
+	}
+
+	sale, err := app.models.Sales.Get(refund.SaleID)
+	if err != nil {
+		return false, err
+	}
+	if data.WithinReturnsWindow(sale.SoldAt.Time, app.now(), days) {
+		return false, nil
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(*refund.CreatedBy)
+	if err != nil {
+		return false, err
+	}
+	if !permissions.Includes(data.PermissionPolicyOverride) {
+		v.AddError("sale", fmt.Sprintf("was sold more than %d days ago, outside the returns window; refunding it requires the %s permission", days, data.PermissionPolicyOverride))
+		return false, nil
+	}
+	v.Check(strings.TrimSpace(refund.Reason) != "", "reason", "must be provided to refund a sale outside the returns window")
+	return true, nil
+}
+
+// listSaleRefundsHandler returns the refunds of a sale, oldest first.
+func (app *app) listSaleRefundsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	refunds, err := app.models.Refunds.GetAllForSale(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"refunds": refunds}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
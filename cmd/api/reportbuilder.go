@@ -0,0 +1,259 @@
+// File: cmd/api/reportbuilder.go
+// Description: handlers for custom report definitions, run on demand or saved for later
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// runCustomReportHandler runs the report definition in the body over the range given by the
+// from, to and timezone query parameters, in ?currency=, without saving it.
+func (app *app) runCustomReportHandler(w http.ResponseWriter, r *http.Request) {
+	var definition data.ReportDefinition
+	if err := app.readJSON(w, r, &definition); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	reportRange := app.readReportRange(r.URL.Query(), app.contextGetUser(r), v)
+	if data.ValidateReportDefinition(v, &definition); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	fx, err := app.readConversion(r.URL.Query(), v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	app.writeCustomReport(w, r, &definition, reportRange, fx)
+}
+
+// listSavedReportsHandler returns the report definitions the user saved, by name.
+func (app *app) listSavedReportsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	filter := app.readFilters(r.URL.Query(), "saved_reports", "name", []string{"name"}, v)
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	reports, metadata, err := app.models.SavedReports.GetAllForUser(app.contextGetUser(r).ID, filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"saved_reports": reports, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// createSavedReportHandler saves a report definition under a name for the user.
+func (app *app) createSavedReportHandler(w http.ResponseWriter, r *http.Request) {
+	// CreateSavedReportPayload struct to hold the incoming JSON payload
+	var CreateSavedReportPayload struct {
+		Name       string                `json:"name"`
+		Definition data.ReportDefinition `json:"definition"`
+	}
+
+	if err := app.readJSON(w, r, &CreateSavedReportPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	report := &data.SavedReport{
+		Name:       CreateSavedReportPayload.Name,
+		Definition: CreateSavedReportPayload.Definition,
+		CreatedBy:  app.contextGetUser(r).ID,
+	}
+
+	v := validator.New()
+	if data.ValidateSavedReport(v, report); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.SavedReports.Insert(report); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateReportName):
+			v.AddError("name", "you already have a saved report with this name")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/reports/saved/%d", report.ID))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"saved_report": report}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// getSavedReportHandler returns one of the user's saved reports.
+func (app *app) getSavedReportHandler(w http.ResponseWriter, r *http.Request) {
+	report, ok := app.readSavedReport(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"saved_report": report}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// updateSavedReportHandler renames a saved report or replaces its definition.
+func (app *app) updateSavedReportHandler(w http.ResponseWriter, r *http.Request) {
+	report, ok := app.readSavedReport(w, r)
+	if !ok {
+		return
+	}
+
+	// UpdateSavedReportPayload struct to hold the incoming JSON payload
+	var UpdateSavedReportPayload struct {
+		Name       *string                `json:"name"`
+		Definition *data.ReportDefinition `json:"definition"`
+	}
+
+	if err := app.readJSON(w, r, &UpdateSavedReportPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if UpdateSavedReportPayload.Name != nil {
+		report.Name = *UpdateSavedReportPayload.Name
+	}
+	if UpdateSavedReportPayload.Definition != nil {
+		report.Definition = *UpdateSavedReportPayload.Definition
+	}
+
+	v := validator.New()
+	if data.ValidateSavedReport(v, report); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.SavedReports.Update(report); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateReportName):
+			v.AddError("name", "you already have a saved report with this name")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"saved_report": report}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// deleteSavedReportHandler removes one of the user's saved reports.
+func (app *app) deleteSavedReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.models.SavedReports.Delete(id, app.contextGetUser(r).ID); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusNoContent, nil, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// runSavedReportHandler runs a saved report over the range given by the from, to and timezone
+// query parameters, in ?currency=. Definitions hold no dates, so one saved report serves any period.
+func (app *app) runSavedReportHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	reportRange := app.readReportRange(r.URL.Query(), app.contextGetUser(r), v)
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	fx, err := app.readConversion(r.URL.Query(), v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	report, ok := app.readSavedReport(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeCustomReport(w, r, &report.Definition, reportRange, fx)
+}
+
+// readSavedReport loads the saved report named by the :id URL parameter, writing a not found
+// response when it does not exist or belongs to another user.
+func (app *app) readSavedReport(w http.ResponseWriter, r *http.Request) (*data.SavedReport, bool) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	report, err := app.models.SavedReports.Get(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	return report, true
+}
+
+// writeCustomReport runs a validated definition and writes its result.
+func (app *app) writeCustomReport(w http.ResponseWriter, r *http.Request, definition *data.ReportDefinition, reportRange data.ReportRange, fx *data.Conversion) {
+	report, err := app.modelsFor(r).Reports.CustomReport(definition, reportRange, fx)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"report": report}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
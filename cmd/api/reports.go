@@ -0,0 +1,260 @@
+// File: cmd/api/reports.go
+// Description: handlers for aggregate sales reports
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// reportDefaultDays is the range used when a report is requested without dates, four full weeks
+// so every weekday is counted the same number of times.
+const reportDefaultDays = 28
+
+// salesHeatmapHandler returns sales counts and revenue by weekday and hour over a date range,
+// so managers can see when the stores are busiest and plan staffing. Revenue is in ?currency=,
+// the base currency by default.
+func (app *app) salesHeatmapHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	reportRange := app.readReportRange(r.URL.Query(), app.contextGetUser(r), v)
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	fx, err := app.readConversion(r.URL.Query(), v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	heatmap, err := app.modelsFor(r).Reports.SalesHeatmap(reportRange, fx)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"heatmap": heatmap}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// revenueSeriesHandler returns the revenue, sales and items sold over a date range in hourly,
+// daily (the default) or monthly buckets given by ?granularity=, with empty periods as zero
+// buckets, for dashboard charts. Revenue is in ?currency=, the base currency by default.
+func (app *app) revenueSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	v := validator.New()
+	reportRange := app.readReportRange(query, app.contextGetUser(r), v)
+	granularity := app.getSingleQueryParameter(query, "granularity", "day")
+	if v.IsValid() {
+		data.ValidateRevenueSeries(v, reportRange, granularity)
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	fx, err := app.readConversion(query, v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	series, err := app.modelsFor(r).Reports.RevenueSeries(reportRange, granularity, fx)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"series": series}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// defaultTopProducts is how many products the top products report returns without ?limit=.
+const defaultTopProducts = 10
+
+// salesSummaryHandler returns the sales, units, revenue, tax and averages per sale over a date
+// range, grouped by ?group_by= day (the default), week, month, product or cashier, with the totals
+// of the whole range. Dashboards read it instead of paging through the sales themselves. Revenue is
+// in ?currency=, the base currency by default. Grouping by cashier compares individual users, so it
+// needs the same permission as the cashier report.
+func (app *app) salesSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	user := app.contextGetUser(r)
+	v := validator.New()
+	reportRange := app.readReportRange(query, user, v)
+	groupBy := app.getSingleQueryParameter(query, "group_by", "day")
+	v.Check(v.Permitted(groupBy, data.SalesSummaryGroups...), "group_by", "must be one of "+strings.Join(data.SalesSummaryGroups, ", "))
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if groupBy == "cashier" {
+		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !permissions.Includes(data.PermissionReportsCashiers) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+
+	fx, err := app.readConversion(query, v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	summary, err := app.modelsFor(r).Reports.SalesSummary(reportRange, groupBy, fx)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"summary": summary}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// cashierReportHandler returns each user's sales, revenue and average basket, in revenue and units
+// per sale, over a date range so managers can compare their cashiers. Users without sales in the
+// range are left out. Revenue is in ?currency=, the base currency by default.
+func (app *app) cashierReportHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	v := validator.New()
+	reportRange := app.readReportRange(query, app.contextGetUser(r), v)
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	fx, err := app.readConversion(query, v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	report, err := app.modelsFor(r).Reports.SalesSummary(reportRange, "cashier", fx)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"report": report}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// topProductsHandler returns the products with the most revenue over a date range, or the most
+// units sold with ?by=quantity, up to ?limit= products (10 by default). Revenue is in ?currency=,
+// the base currency by default.
+func (app *app) topProductsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	v := validator.New()
+	reportRange := app.readReportRange(query, app.contextGetUser(r), v)
+	orderBy := app.getSingleQueryParameter(query, "by", "revenue")
+	v.Check(v.Permitted(orderBy, data.TopProductsOrders...), "by", "must be one of "+strings.Join(data.TopProductsOrders, ", "))
+	limit := app.getSingleIntQueryParameter(query, "limit", defaultTopProducts, v)
+	v.Check(limit >= 1 && limit <= data.MaxTopProducts, "limit", fmt.Sprintf("must be between 1 and %d", data.MaxTopProducts))
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	fx, err := app.readConversion(query, v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	products, err := app.modelsFor(r).Reports.TopProducts(reportRange, orderBy, int(limit), fx)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{
+		"from":     reportRange.From.Format(time.DateOnly),
+		"to":       reportRange.To.Format(time.DateOnly),
+		"timezone": reportRange.Location.String(),
+		"currency": fx.Currency,
+		"by":       orderBy,
+		"products": products,
+	}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// readReportRange reads the from, to and timezone query parameters of a report. Dates are whole
+// days in the timezone, which defaults to the user's own. Without dates the range is the last
+// reportDefaultDays days up to today.
+func (app *app) readReportRange(query url.Values, user *data.User, v *validator.Validator) data.ReportRange {
+	return app.readDateRange(query, "from", "to", user, v)
+}
+
+// readDateRange reads a report range whose first and last days are given by the fromKey and toKey
+// query parameters, as readReportRange does for from and to.
+func (app *app) readDateRange(query url.Values, fromKey, toKey string, user *data.User, v *validator.Validator) data.ReportRange {
+	loc := user.Location()
+	if timezone := query.Get("timezone"); timezone != "" {
+		if data.ValidateTimezone(v, timezone); !v.IsValid() {
+			return data.ReportRange{}
+		}
+		loc, _ = time.LoadLocation(timezone)
+	}
+
+	now := app.now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	reportRange := data.ReportRange{Location: loc, To: today}
+
+	if to := app.getSingleDateQueryParameter(query, toKey, "", v); to != "" {
+		reportRange.To, _ = time.ParseInLocation(time.DateOnly, to, loc)
+	}
+	reportRange.From = reportRange.To.AddDate(0, 0, 1-reportDefaultDays)
+	if from := app.getSingleDateQueryParameter(query, fromKey, "", v); from != "" {
+		reportRange.From, _ = time.ParseInLocation(time.DateOnly, from, loc)
+	}
+
+	if v.IsValid() {
+		data.ValidateDateRange(v, reportRange, fromKey, toKey)
+	}
+	return reportRange
+}
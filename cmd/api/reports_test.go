@@ -0,0 +1,175 @@
+// File: cmd/api/reports_test.go
+// Description: test suite for report parameters
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// TestReadReportRange tests the dates, timezone and defaults of report ranges
+func TestReadReportRange(t *testing.T) {
+	app := newTestApp()
+	user := &data.User{ID: 7, Timezone: "America/Belize"}
+
+	tests := []struct {
+		name             string
+		query            string
+		expectedFrom     string
+		expectedTo       string
+		expectedTimezone string
+		expectedErrors   []string
+	}{
+		{"Explicit Range", "from=2025-03-01&to=2025-03-31", "2025-03-01", "2025-03-31", "America/Belize", nil},
+		{"Single Day", "from=2025-03-01&to=2025-03-01", "2025-03-01", "2025-03-01", "America/Belize", nil},
+		{"Timezone Override", "from=2025-03-01&to=2025-03-07&timezone=Europe/Madrid", "2025-03-01", "2025-03-07", "Europe/Madrid", nil},
+		{"Default From", "to=2025-03-28", "2025-03-01", "2025-03-28", "America/Belize", nil},
+		{"Reversed Range", "from=2025-03-31&to=2025-03-01", "", "", "", []string{"to"}},
+		{"Too Long", "from=2024-01-01&to=2025-01-01", "", "", "", []string{"to"}},
+		{"Impossible Date", "from=2025-02-30&to=2025-03-01", "", "", "", []string{"from"}},
+		{"Unknown Timezone", "timezone=Mars/Olympus", "", "", "", []string{"timezone"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			v := validator.New()
+			got := app.readReportRange(query, user, v)
+
+			for _, key := range tt.expectedErrors {
+				if _, ok := v.Errors[key]; !ok {
+					t.Errorf("expected an error for %s, got %v", key, v.Errors)
+				}
+			}
+			if len(tt.expectedErrors) > 0 {
+				return
+			}
+			if !v.IsValid() {
+				t.Fatalf("unexpected errors: %v", v.Errors)
+			}
+			if from := got.From.Format(time.DateOnly); from != tt.expectedFrom {
+				t.Errorf("expected from %s, got %s", tt.expectedFrom, from)
+			}
+			if to := got.To.Format(time.DateOnly); to != tt.expectedTo {
+				t.Errorf("expected to %s, got %s", tt.expectedTo, to)
+			}
+			if got.Location.String() != tt.expectedTimezone || got.From.Location() != got.Location {
+				t.Errorf("expected dates in %s, got %s", tt.expectedTimezone, got.From.Location())
+			}
+		})
+	}
+
+	t.Run("Default Range Ends Today", func(t *testing.T) {
+		app := newTestApp()
+		app.clock = data.NewFrozenClock(time.Date(2025, 3, 15, 3, 0, 0, 0, time.UTC))
+
+		v := validator.New()
+		got := app.readReportRange(url.Values{}, &data.User{ID: 7}, v)
+		if !v.IsValid() || got.To.Format(time.DateOnly) != "2025-03-15" || got.Location != time.UTC {
+			t.Fatalf("expected a range ending 2025-03-15 in UTC, got %v to %v (%v)", got.From, got.To, v.Errors)
+		}
+		if days := got.To.Sub(got.From) / (24 * time.Hour); days != reportDefaultDays-1 {
+			t.Errorf("expected %d days, got %d", reportDefaultDays, days+1)
+		}
+
+		// Still the evening before in Belize
+		got = app.readReportRange(url.Values{}, &data.User{ID: 7, Timezone: "America/Belize"}, v)
+		if to := got.To.Format(time.DateOnly); !v.IsValid() || to != "2025-03-14" {
+			t.Errorf("expected a range ending 2025-03-14 in the user's timezone, got %s (%v)", to, v.Errors)
+		}
+	})
+}
+
+// TestSalesHeatmapHandlerValidation tests that bad parameters are rejected before querying
+func TestSalesHeatmapHandlerValidation(t *testing.T) {
+	app := newTestApp()
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/reports/sales/heatmap?from=2025-03-31&to=2025-03-01", nil)
+	req = app.contextSetUser(req, &data.User{ID: 7})
+	rr := httptest.NewRecorder()
+	app.salesHeatmapHandler(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+}
+
+// TestRunCustomReportHandlerValidation tests that bad definitions and ranges are rejected before querying
+func TestRunCustomReportHandlerValidation(t *testing.T) {
+	app := newTestApp()
+
+	tests := []struct {
+		name           string
+		query          string
+		body           string
+		expectedStatus int
+	}{
+		{"Unknown Dimension", "", `{"dimensions": ["store"], "measures": ["revenue"]}`, http.StatusUnprocessableEntity},
+		{"Raw SQL Sort", "", `{"measures": ["count"], "sort": "1; DROP TABLE sales"}`, http.StatusUnprocessableEntity},
+		{"Reversed Range", "?from=2025-03-31&to=2025-03-01", `{"measures": ["count"]}`, http.StatusUnprocessableEntity},
+		{"Unknown Field", "", `{"measures": ["count"], "sql": "SELECT 1"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/reports/custom"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req = app.contextSetUser(req, &data.User{ID: 7})
+			rr := httptest.NewRecorder()
+
+			app.runCustomReportHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+// TestSalesSummaryParameters tests that the summary, top products and revenue reports refuse
+// unknown groupings, orders, limits and granularities before the database is used
+func TestSalesSummaryParameters(t *testing.T) {
+	app := newTestApp()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		query   string
+		field   string
+	}{
+		{"Unknown Grouping", app.salesSummaryHandler, "group_by=year", "group_by"},
+		{"Reversed Summary Range", app.salesSummaryHandler, "group_by=week&from=2025-03-31&to=2025-03-01", "to"},
+		{"Unknown Order", app.topProductsHandler, "by=margin", "by"},
+		{"Zero Limit", app.topProductsHandler, "limit=0", "limit"},
+		{"Limit Too High", app.topProductsHandler, "limit=101", "limit"},
+		{"Limit Not A Number", app.topProductsHandler, "limit=ten", "limit"},
+		{"Unknown Granularity", app.revenueSeriesHandler, "granularity=week", "granularity"},
+		{"Hourly Range Too Long", app.revenueSeriesHandler, "granularity=hour&from=2025-03-01&to=2025-04-15", "to"},
+		{"Reversed Cashier Range", app.cashierReportHandler, "from=2025-03-31&to=2025-03-01", "to"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/reports/sales?"+tt.query, nil)
+			req = app.contextSetUser(req, &data.User{ID: 7})
+			rr := httptest.NewRecorder()
+
+			tt.handler(rr, req)
+
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("expected status 422, got %d: %s", rr.Code, rr.Body.String())
+			}
+			if !strings.Contains(rr.Body.String(), `"`+tt.field+`"`) {
+				t.Errorf("expected a %s error, got %s", tt.field, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+// File: cmd/api/roles.go
+// Description: role and permission bundle management handlers
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// listRolesHandler returns every role with its permission bundle.
+func (app *app) listRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := app.models.Roles.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"roles": roles}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// createRoleHandler creates a new role with the given permission bundle.
+func (app *app) createRoleHandler(w http.ResponseWriter, r *http.Request) {
+	// CreateRolePayload struct to hold the incoming JSON payload
+	var CreateRolePayload struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+
+	if err := app.readJSON(w, r, &CreateRolePayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	role := &data.Role{
+		Name:        CreateRolePayload.Name,
+		Description: CreateRolePayload.Description,
+		Permissions: CreateRolePayload.Permissions,
+	}
+
+	v := validator.New()
+	if data.ValidateRole(v, role); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Roles.Insert(role); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateRole):
+			v.AddError("name", "a role with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrUnknownPermission):
+			v.AddError("permissions", "must only contain known permission codes")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/roles/%d", role.ID))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"role": role}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// showRoleHandler returns a single role by ID.
+func (app *app) showRoleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	role, err := app.models.Roles.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"role": role}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// updateRoleHandler edits a role and re-applies its permission bundle to the users holding it.
+func (app *app) updateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	role, err := app.models.Roles.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// UpdateRolePayload struct to hold the incoming JSON payload
+	var UpdateRolePayload struct {
+		Name        *string  `json:"name"`
+		Description *string  `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+
+	if err := app.readJSON(w, r, &UpdateRolePayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if UpdateRolePayload.Name != nil && *UpdateRolePayload.Name != role.Name {
+		// Built in roles are referenced by name elsewhere, so they keep their names
+		if role.IsSystem {
+			app.systemRoleResponse(w, r)
+			return
+		}
+		role.Name = *UpdateRolePayload.Name
+	}
+	if UpdateRolePayload.Description != nil {
+		role.Description = *UpdateRolePayload.Description
+	}
+	if UpdateRolePayload.Permissions != nil {
+		role.Permissions = UpdateRolePayload.Permissions
+	}
+
+	v := validator.New()
+	if data.ValidateRole(v, role); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Roles.Update(role); err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateRole):
+			v.AddError("name", "a role with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrUnknownPermission):
+			v.AddError("permissions", "must only contain known permission codes")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"role": role}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// deleteRoleHandler removes a custom role that is no longer assigned to any user.
+func (app *app) deleteRoleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	role, err := app.models.Roles.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if role.IsSystem {
+		app.systemRoleResponse(w, r)
+		return
+	}
+
+	if err := app.models.Roles.Delete(role.ID); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrRoleInUse):
+			app.roleInUseResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusNoContent, nil, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// roleAssignable reports whether a user holding held may move users into or out of role. They must
+// hold every permission the role grants, and users:admin when the role carries it.
+func roleAssignable(held data.Permissions, role *data.Role) bool {
+	if role.Permissions.Includes(data.PermissionUsersAdmin) && !held.Includes(data.PermissionUsersAdmin) {
+		return false
+	}
+	for _, code := range role.Permissions {
+		if !held.Includes(code) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+// File: cmd/api/roles_test.go
+// Description: test suite for role handlers - validation focused
+
+package main
+
+import (
+	"testing"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// TestRoleValidation tests role validation logic
+func TestRoleValidation(t *testing.T) {
+	tests := []struct {
+		name          string
+		roleName      string
+		description   string
+		permissions   []string
+		expectedValid bool
+		errorField    string
+	}{
+		{
+			name:          "Valid Role",
+			roleName:      "shift-manager",
+			description:   "Runs the till and voids sales",
+			permissions:   []string{data.PermissionSaleCreate, data.PermissionSaleView, data.PermissionSaleDelete},
+			expectedValid: true,
+		},
+		{
+			name:          "Empty Permission Bundle",
+			roleName:      "auditor",
+			permissions:   []string{},
+			expectedValid: true,
+		},
+		{
+			name:          "Empty Name",
+			roleName:      "",
+			permissions:   []string{data.PermissionSaleView},
+			expectedValid: false,
+			errorField:    "name",
+		},
+		{
+			name:          "Uppercase Name",
+			roleName:      "Manager",
+			permissions:   []string{data.PermissionSaleView},
+			expectedValid: false,
+			errorField:    "name",
+		},
+		{
+			name:          "Name With Spaces",
+			roleName:      "shift manager",
+			permissions:   []string{data.PermissionSaleView},
+			expectedValid: false,
+			errorField:    "name",
+		},
+		{
+			name:          "Missing Permissions",
+			roleName:      "auditor",
+			permissions:   nil,
+			expectedValid: false,
+			errorField:    "permissions",
+		},
+		{
+			name:          "Duplicate Permissions",
+			roleName:      "auditor",
+			permissions:   []string{data.PermissionSaleView, data.PermissionProductView, data.PermissionSaleView},
+			expectedValid: false,
+			errorField:    "permissions",
+		},
+		{
+			name:          "Unknown Permission Code",
+			roleName:      "auditor",
+			permissions:   []string{"products:read"},
+			expectedValid: false,
+			errorField:    "permissions",
+		},
+		{
+			name:          "Description Too Long",
+			roleName:      "auditor",
+			description:   string(make([]byte, 256)),
+			permissions:   []string{data.PermissionSaleView},
+			expectedValid: false,
+			errorField:    "description",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role := &data.Role{
+				Name:        tt.roleName,
+				Description: tt.description,
+				Permissions: tt.permissions,
+			}
+
+			v := validator.New()
+			data.ValidateRole(v, role)
+
+			isValid := v.IsValid()
+			if isValid != tt.expectedValid {
+				t.Errorf("expected valid=%v, got=%v. Errors: %v", tt.expectedValid, isValid, v.Errors)
+			}
+
+			if !tt.expectedValid && tt.errorField != "" {
+				if _, hasError := v.Errors[tt.errorField]; !hasError {
+					t.Errorf("expected error on field '%s', but got errors: %v", tt.errorField, v.Errors)
+				}
+			}
+		})
+	}
+}
+
+// TestRoleAssignable tests that a role can only be handed out by someone holding every permission
+// it grants, whatever the role is called
+func TestRoleAssignable(t *testing.T) {
+	manager := data.Permissions{data.PermissionUsersUpdate, data.PermissionSaleView, data.PermissionSaleCreate}
+
+	tests := []struct {
+		name     string
+		held     data.Permissions
+		role     *data.Role
+		expected bool
+	}{
+		{"Subset Of Held", manager, &data.Role{Name: "cashier", Permissions: data.Permissions{data.PermissionSaleView, data.PermissionSaleCreate}}, true},
+		{"Empty Bundle", manager, &data.Role{Name: "guest", Permissions: data.Permissions{}}, true},
+		{"Custom Role With Users Admin", manager, &data.Role{Name: "helpdesk", Permissions: data.Permissions{data.PermissionUsersAdmin}}, false},
+		{"Custom Role With Role Management", manager, &data.Role{Name: "helpdesk", Permissions: data.Permissions{data.PermissionRolesUpdate}}, false},
+		{"Custom Role With Permission Grants", manager, &data.Role{Name: "helpdesk", Permissions: data.Permissions{data.PermissionUsersPermissions}}, false},
+		{"Admin Holding Everything", append(manager, data.PermissionUsersAdmin), &data.Role{Name: "admin", Permissions: data.Permissions{data.PermissionUsersAdmin, data.PermissionSaleView}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := roleAssignable(tt.held, tt.role); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
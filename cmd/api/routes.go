@@ -4,9 +4,9 @@
 package main
 
 import (
-	"expvar"
 	"net/http"
 
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
 	// Importing Route Package
 	"github.com/julienschmidt/httprouter"
 )
@@ -23,39 +23,191 @@ func (app *app) routes() http.Handler {
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
 	// Health Check Route
-	// router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
-	// Metrics Route
-	router.Handler(http.MethodGet, "/v1/metrics", expvar.Handler())
+	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	// Build Info Route, for checking which build a deploy is serving
+	router.HandlerFunc(http.MethodGet, "/v1/version", app.versionHandler)
+	// Metrics Routes, which reveal internals of the deployment and so need metrics:view
+	router.Handler(http.MethodGet, "/v1/metrics", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionMetricsView)(http.HandlerFunc(app.expvarHandler))))
+	router.Handler(http.MethodGet, "/v1/metrics/prometheus", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionMetricsView)(http.HandlerFunc(app.prometheusMetricsHandler))))
+	// Public Status Route, cached and open to any origin
+	router.HandlerFunc(http.MethodGet, "/v1/status", app.statusHandler)
+
+	// Mail provider delivery callbacks, authenticated by a shared secret header
+	router.HandlerFunc(http.MethodPost, "/v1/webhooks/email", app.emailWebhookHandler)
+
+	// First-run Setup Routes, refused once the first admin exists
+	router.HandlerFunc(http.MethodGet, "/v1/bootstrap", app.showBootstrapHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/bootstrap", app.bootstrapHandler)
 
 	// Authentication and User Routes
-	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)                                                                            // User Registration
-	router.HandlerFunc(http.MethodPut, "/v1/users/activate", app.activateUserHandler)                                                                    // User Activation
-	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)                                               // Login
-	router.Handler(http.MethodDelete, "/v1/tokens/authentication", app.requireAuthenticatedUser(http.HandlerFunc(app.deleteAuthenticationTokenHandler))) // Logout
+	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)                                                                                                           // User Registration
+	router.HandlerFunc(http.MethodPut, "/v1/users/activate", app.activateUserHandler)                                                                                                   // User Activation
+	router.HandlerFunc(http.MethodPost, "/v1/invitations/accept", app.acceptInvitationHandler)                                                                                          // Accept Invitation
+	router.Handler(http.MethodPost, "/v1/invitations", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionUsersCreate)(http.HandlerFunc(app.createInvitationHandler)))) // Invite User
+	router.Handler(http.MethodPost, "/v1/users/import", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionUsersCreate)(http.HandlerFunc(app.importUsersHandler))))     // Bulk Import Users
+	router.Handler(http.MethodPost, "/v1/tokens/authentication", app.limitLogins(http.HandlerFunc(app.createAuthenticationTokenHandler)))                                               // Login
+	router.Handler(http.MethodDelete, "/v1/tokens/authentication", app.requireAuthenticatedUser(http.HandlerFunc(app.deleteAuthenticationTokenHandler)))                                // Logout
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/password-reset", app.createPasswordResetTokenHandler)                                                                               // Request Password Reset Token
+	router.HandlerFunc(http.MethodPut, "/v1/users/password", app.updatePasswordHandler)                                                                                                 // Set Password With Reset Token
+	router.HandlerFunc(http.MethodGet, "/v1/policies/password", app.showPasswordPolicyHandler)                                                                                          // Password Policy
 	router.Handler(http.MethodPost, "/v1/chatbot", app.requireAuthenticatedUser(http.HandlerFunc(app.chatbotHandler)))
 	// Authenticated User Routes
-	router.Handler(http.MethodGet, "/v1/users/profile", app.requireAuthenticatedUser(http.HandlerFunc(app.showCurrentUserHandler))) // Get Authenticated User Info
-	router.Handler(http.MethodPut, "/v1/users/profile/:id", app.requireAuthenticatedUser(http.HandlerFunc(app.updateUserHandler)))  // Update Authenticated User Info
+	router.Handler(http.MethodGet, "/v1/users/profile", app.requireAuthenticatedUser(http.HandlerFunc(app.showCurrentUserHandler)))                                                     // Get Authenticated User Info
+	router.Handler(http.MethodPut, "/v1/users/profile", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSelfUpdate)(http.HandlerFunc(app.updateOwnProfileHandler)))) // Update Authenticated User Info
+	router.Handler(http.MethodGet, "/v1/users/sessions", app.requireAuthenticatedUser(http.HandlerFunc(app.listSessionsHandler)))                                                       // List Active Sessions
+	router.Handler(http.MethodPost, "/v1/users/2fa/setup", app.requireActivatedUser(app.requirePasswordConfirmation(http.HandlerFunc(app.setupTwoFactorHandler))))                      // Start TOTP 2FA Setup
+	router.Handler(http.MethodPost, "/v1/users/2fa/verify", app.requireActivatedUser(http.HandlerFunc(app.verifyTwoFactorHandler)))                                                     // Verify and Enable TOTP 2FA
+	router.Handler(http.MethodPost, "/v1/users/email-change", app.requireActivatedUser(http.HandlerFunc(app.requestEmailChangeHandler)))                                                // Request Email Change
+	router.HandlerFunc(http.MethodPut, "/v1/users/email-change/confirm", app.confirmEmailChangeHandler)                                                                                 // Confirm Email Change
 
 	// User Routes
-	router.Handler(http.MethodGet, "/v1/user", app.requireAuthenticatedUser(app.requirePermissions("users:view")(http.HandlerFunc(app.listUsersHandler))))           // List All Users
-	router.Handler(http.MethodGet, "/v1/user/:id", app.requireAuthenticatedUser(app.requirePermissions("users:view")(http.HandlerFunc(app.showUserHandler))))        // Get User by ID
-	router.Handler(http.MethodDelete, "/v1/user/:id", app.requireAuthenticatedUser(app.requirePermissions("users:delete")(http.HandlerFunc(app.deleteUserHandler)))) // Delete User by ID
-	router.Handler(http.MethodPut, "/v1/user/:id", app.requireAuthenticatedUser(app.requirePermissions("users:update")(http.HandlerFunc(app.updateUserHandler))))    // Update User by ID
+	router.Handler(http.MethodGet, "/v1/user", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionUsersView)(http.HandlerFunc(app.listUsersHandler))))                                                          // List All Users
+	router.Handler(http.MethodGet, "/v1/user/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionUsersView)(http.HandlerFunc(app.showUserHandler))))                                                       // Get User by ID
+	router.Handler(http.MethodGet, "/v1/user/:id/sales", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.listUserSalesHandler))))                                             // List a User's Sales
+	router.Handler(http.MethodGet, "/v1/user/:id/activity", app.requireAuthenticatedUser(http.HandlerFunc(app.listUserActivityHandler)))                                                                                        // List a User's Recent Activity
+	router.Handler(http.MethodDelete, "/v1/user/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionUsersDelete)(http.HandlerFunc(app.deleteUserHandler))))                                                // Delete User by ID
+	router.Handler(http.MethodPut, "/v1/user/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionUsersUpdate)(http.HandlerFunc(app.updateUserHandler))))                                                   // Update User by ID
+	router.Handler(http.MethodPost, "/v1/user/:id/deactivate", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionUsersUpdate)(http.HandlerFunc(app.deactivateUserHandler))))                                   // Deactivate User and End Sessions
+	router.Handler(http.MethodPost, "/v1/user/:id/restore", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionUsersDelete)(http.HandlerFunc(app.restoreUserHandler))))                                         // Restore Soft Deleted User
+	router.Handler(http.MethodPost, "/v1/user/:id/activation-token", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionUsersAdmin)(http.HandlerFunc(app.issueActivationTokenHandler))))                        // Issue Activation Token Without Email
+	router.Handler(http.MethodPost, "/v1/user/:id/impersonate", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionUsersAdmin)(app.requirePasswordConfirmation(http.HandlerFunc(app.impersonateUserHandler))))) // Act As Another User
+
+	// User Permission Routes, individual grants on top of the user's role
+	router.Handler(http.MethodGet, "/v1/user/:id/permissions", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionUsersPermissions)(http.HandlerFunc(app.listUserPermissionsHandler))))           // List User Permissions
+	router.Handler(http.MethodPost, "/v1/user/:id/permissions", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionUsersPermissions)(http.HandlerFunc(app.grantUserPermissionHandler))))          // Grant User Permission
+	router.Handler(http.MethodDelete, "/v1/user/:id/permissions/:code", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionUsersPermissions)(http.HandlerFunc(app.revokeUserPermissionHandler)))) // Revoke User Permission
+
+	// Role Routes, permission bundles assigned to users by role
+	router.Handler(http.MethodGet, "/v1/roles", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionRolesView)(http.HandlerFunc(app.listRolesHandler))))           // List All Roles
+	router.Handler(http.MethodGet, "/v1/roles/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionRolesView)(http.HandlerFunc(app.showRoleHandler))))        // Get Role by ID
+	router.Handler(http.MethodPost, "/v1/roles", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionRolesCreate)(http.HandlerFunc(app.createRoleHandler))))       // Create New Role
+	router.Handler(http.MethodPut, "/v1/roles/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionRolesUpdate)(http.HandlerFunc(app.updateRoleHandler))))    // Update Role by ID
+	router.Handler(http.MethodDelete, "/v1/roles/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionRolesDelete)(http.HandlerFunc(app.deleteRoleHandler)))) // Delete Role by ID
 
 	// Product Routes, all but view require authentication, the rest require specific permissions
-	router.Handler(http.MethodGet, "/v1/products", app.requireAuthenticatedUser(app.requirePermissions("product:view")(http.HandlerFunc(app.listProductsHandler))))           // List All Products
-	router.Handler(http.MethodGet, "/v1/products/:id", app.requireAuthenticatedUser(app.requirePermissions("product:view")(http.HandlerFunc(app.getProductHandler))))         // Get Product by ID
-	router.Handler(http.MethodPost, "/v1/products", app.requireAuthenticatedUser(app.requirePermissions("product:create")(http.HandlerFunc(app.createProductHandler))))       // Create New Product
-	router.Handler(http.MethodPut, "/v1/products/:id", app.requireAuthenticatedUser(app.requirePermissions("product:update")(http.HandlerFunc(app.updateProductHandler))))    // Update Product by ID
-	router.Handler(http.MethodDelete, "/v1/products/:id", app.requireAuthenticatedUser(app.requirePermissions("product:delete")(http.HandlerFunc(app.deleteProductHandler)))) // Delete Product by ID
+	router.Handler(http.MethodGet, "/v1/products", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionProductView)(http.HandlerFunc(app.listProductsHandler))))                              // List All Products
+	router.Handler(http.MethodGet, "/v1/products/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionProductView)(http.HandlerFunc(app.getProductHandler))))                            // Get Product by ID
+	router.Handler(http.MethodPost, "/v1/products", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionProductCreate)(http.HandlerFunc(app.createProductHandler))))                          // Create New Product
+	router.Handler(http.MethodPut, "/v1/products/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionProductUpdate)(http.HandlerFunc(app.updateProductHandler))))                       // Update Product by ID
+	router.Handler(http.MethodDelete, "/v1/products/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionProductDelete)(http.HandlerFunc(app.deleteProductHandler))))                    // Delete Product by ID
+	router.Handler(http.MethodGet, "/v1/products/:id/price-history", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionProductView)(http.HandlerFunc(app.listProductPriceHistoryHandler)))) // Price Changes of a Product
+	router.Handler(http.MethodGet, "/v1/products/:id/stock", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionProductView)(http.HandlerFunc(app.listStockMovementsHandler))))              // Stock History of a Product
+	router.Handler(http.MethodPost, "/v1/products/:id/stock", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionProductUpdate)(http.HandlerFunc(app.recordStockHandler))))                  // Receive or Adjust Stock
+	router.Handler(http.MethodPost, "/v1/products/:id/deactivate", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionProductUpdate)(http.HandlerFunc(app.deactivateProductHandler))))       // Deactivate Product by ID
+	router.Handler(http.MethodPost, "/v1/products/:id/reactivate", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionProductUpdate)(http.HandlerFunc(app.reactivateProductHandler))))       // Reactivate Product by ID
+	router.Handler(http.MethodPost, "/v1/products/:id/unarchive", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionProductDelete)(http.HandlerFunc(app.unarchiveProductHandler))))         // Restore a Deleted Product by ID
+
+	// Exchange Rate Routes, rates are quoted against the configured base currency
+	router.Handler(http.MethodGet, "/v1/exchange-rates", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionProductView)(http.HandlerFunc(app.listExchangeRatesHandler))))         // List Exchange Rates
+	router.Handler(http.MethodPut, "/v1/exchange-rates/:currency", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionRatesUpdate)(http.HandlerFunc(app.setExchangeRateHandler)))) // Set a Currency's Rate
+
+	// Incident Routes, notes shown on the public status endpoint
+	router.Handler(http.MethodGet, "/v1/admin/incidents", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionIncidentsManage)(http.HandlerFunc(app.listIncidentsHandler))))         // List Incidents
+	router.Handler(http.MethodPost, "/v1/admin/incidents", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionIncidentsManage)(http.HandlerFunc(app.createIncidentHandler))))       // Post an Incident
+	router.Handler(http.MethodPut, "/v1/admin/incidents/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionIncidentsManage)(http.HandlerFunc(app.updateIncidentHandler))))    // Update an Incident
+	router.Handler(http.MethodDelete, "/v1/admin/incidents/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionIncidentsManage)(http.HandlerFunc(app.deleteIncidentHandler)))) // Delete an Incident
+
+	// Security Routes, responses to suspected breaches
+	router.Handler(http.MethodPost, "/v1/admin/security/revoke-all-tokens", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSecurityManage)(app.requirePasswordConfirmation(http.HandlerFunc(app.revokeAllTokensHandler))))) // Sign Users Out Everywhere
+	router.Handler(http.MethodGet, "/v1/admin/security/auth-report", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSecurityManage)(http.HandlerFunc(app.authReportHandler))))                                              // Login, Activation and Reset Outcomes per Day
+
+	// Business Calendar Routes, the opening hours and holidays reports pace revenue over
+	router.Handler(http.MethodGet, "/v1/admin/calendar", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionCalendarManage)(http.HandlerFunc(app.getCalendarHandler))))                   // Get the Opening Hours and Holidays
+	router.Handler(http.MethodPut, "/v1/admin/calendar/hours", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionCalendarManage)(http.HandlerFunc(app.updateBusinessHoursHandler))))     // Set Weekday Opening Hours
+	router.Handler(http.MethodPost, "/v1/admin/calendar/holidays", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionCalendarManage)(http.HandlerFunc(app.createHolidayHandler))))       // Add a Holiday
+	router.Handler(http.MethodPut, "/v1/admin/calendar/holidays/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionCalendarManage)(http.HandlerFunc(app.updateHolidayHandler))))    // Update a Holiday
+	router.Handler(http.MethodDelete, "/v1/admin/calendar/holidays/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionCalendarManage)(http.HandlerFunc(app.deleteHolidayHandler)))) // Remove a Holiday
+
+	// Schedule Routes, the background jobs run on cron expressions
+	router.Handler(http.MethodGet, "/v1/schedules", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSchedulesManage)(http.HandlerFunc(app.listSchedulesHandler))))         // List Schedules and Their Last and Next Runs
+	router.Handler(http.MethodPost, "/v1/schedules", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSchedulesManage)(http.HandlerFunc(app.createScheduleHandler))))       // Create a Schedule
+	router.Handler(http.MethodPut, "/v1/schedules/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSchedulesManage)(http.HandlerFunc(app.updateScheduleHandler))))    // Update a Schedule
+	router.Handler(http.MethodDelete, "/v1/schedules/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSchedulesManage)(http.HandlerFunc(app.deleteScheduleHandler)))) // Delete a Schedule
+	router.Handler(http.MethodPost, "/v1/schedules/:id/run", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSchedulesManage)(http.HandlerFunc(app.runScheduleHandler))))  // Run a Schedule's Job Now
+
+	// Discount Routes, the codes taken off sales at the till
+	router.Handler(http.MethodGet, "/v1/admin/discounts", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionDiscountsManage)(http.HandlerFunc(app.listDiscountsHandler))))         // List Discounts
+	router.Handler(http.MethodPost, "/v1/admin/discounts", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionDiscountsManage)(http.HandlerFunc(app.createDiscountHandler))))       // Create a Discount
+	router.Handler(http.MethodGet, "/v1/admin/discounts/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionDiscountsManage)(http.HandlerFunc(app.getDiscountHandler))))       // Get a Discount by ID
+	router.Handler(http.MethodPut, "/v1/admin/discounts/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionDiscountsManage)(http.HandlerFunc(app.updateDiscountHandler))))    // Replace a Discount
+	router.Handler(http.MethodDelete, "/v1/admin/discounts/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionDiscountsManage)(http.HandlerFunc(app.deleteDiscountHandler)))) // Delete a Discount
+
+	// Tax Routes, the rates added on top of sales
+	router.Handler(http.MethodGet, "/v1/admin/taxes", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionTaxesManage)(http.HandlerFunc(app.listTaxRatesHandler))))         // List Tax Rates
+	router.Handler(http.MethodPost, "/v1/admin/taxes", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionTaxesManage)(http.HandlerFunc(app.createTaxRateHandler))))       // Create a Tax Rate
+	router.Handler(http.MethodGet, "/v1/admin/taxes/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionTaxesManage)(http.HandlerFunc(app.getTaxRateHandler))))       // Get a Tax Rate by ID
+	router.Handler(http.MethodPut, "/v1/admin/taxes/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionTaxesManage)(http.HandlerFunc(app.updateTaxRateHandler))))    // Replace a Tax Rate
+	router.Handler(http.MethodDelete, "/v1/admin/taxes/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionTaxesManage)(http.HandlerFunc(app.deleteTaxRateHandler)))) // Delete a Tax Rate
+
+	// Bulk Price Route, kept off /v1/products/ because the router cannot mix static segments with :id
+	router.Handler(http.MethodPatch, "/v1/admin/products/bulk-price", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionProductUpdate)(http.HandlerFunc(app.bulkUpdatePricesHandler)))) // Bulk Update Product Prices
+
+	// Inventory Routes, kept off /v1/products/ because the router cannot mix static segments with :id
+	router.Handler(http.MethodGet, "/v1/inventory/low-stock", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionProductView)(http.HandlerFunc(app.listLowStockHandler)))) // Products Below Their Reorder Threshold
+
+	// Tag Routes, the tags in use on products
+	router.Handler(http.MethodGet, "/v1/tags", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionProductView)(http.HandlerFunc(app.listTagsHandler)))) // List Product Tags
+
+	// Suggestion Routes, kept off /v1/products/ because the router cannot mix static segments with :id
+	router.Handler(http.MethodGet, "/v1/suggest/products", app.rateLimitSuggestions(app.requireAuthenticatedUser(app.requirePermissions(data.PermissionProductView)(http.HandlerFunc(app.suggestProductsHandler))))) // Product Name Suggestions
 
 	// Sales Routes, all but viewall require authentication, the rest require specific permissions
-	router.Handler(http.MethodGet, "/v1/sales", app.requirePermissions("sale:view")(http.HandlerFunc(app.listSalesHandler)))                                          // List All Sales
-	router.Handler(http.MethodGet, "/v1/sales/:id", app.requireAuthenticatedUser(app.requirePermissions("sale:view")(http.HandlerFunc(app.getSaleHandler))))          // Get Sale by ID
-	router.Handler(http.MethodPost, "/v1/sales", app.requireAuthenticatedUser(app.requirePermissions("sale:create")(http.HandlerFunc(app.createSaleHandler))))        // Create New Sale
-	router.Handler(http.MethodPut, "/v1/sales/:id", app.requireAuthenticatedUser(app.requirePermissions("sale:update")(http.HandlerFunc(app.updateSaleHandler))))     // Update Sale by ID
-	router.Handler(http.MethodDelete, "/v1/sales/:id", app.requireAuthenticatedUser(app.requirePermissions("sale:delete")(http.HandlerFunc(app.deleteSalesHandler)))) // Delete Sale by ID
+	router.Handler(http.MethodGet, "/v1/sales", app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.listSalesHandler)))                                                                              // List All Sales
+	router.Handler(http.MethodGet, "/v1/sales/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.getSaleHandler))))                                              // Get Sale by ID
+	router.Handler(http.MethodPost, "/v1/sales", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleCreate)(http.HandlerFunc(app.createSaleHandler))))                                            // Create New Sale
+	router.Handler(http.MethodPut, "/v1/sales/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleUpdate)(http.HandlerFunc(app.updateSaleHandler))))                                         // Update Sale by ID
+	router.Handler(http.MethodDelete, "/v1/sales/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleDelete)(http.HandlerFunc(app.deleteSalesHandler))))                                     // Delete Sale by ID
+	router.Handler(http.MethodPut, "/v1/receipt-preferences", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleCreate)(http.HandlerFunc(app.setReceiptPreferenceHandler))))                     // Set a Customer's Email Receipt Preference
+	router.Handler(http.MethodGet, "/v1/sales/:id/payments", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.listSalePaymentsHandler))))                            // List a Sale's Payments
+	router.Handler(http.MethodPost, "/v1/sales/:id/payments", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleCreate)(http.HandlerFunc(app.recordSalePaymentsHandler))))                       // Record Payments Settling a Sale
+	router.Handler(http.MethodGet, "/v1/sales/:id/receipt", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.saleReceiptHandler))))                                  // Printable Receipt as HTML or PDF
+	router.Handler(http.MethodGet, "/v1/sales/:id/refunds", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.listSaleRefundsHandler))))                              // List a Sale's Refunds
+	router.Handler(http.MethodPost, "/v1/sales/:id/refund", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleRefund)(http.HandlerFunc(app.refundSaleHandler))))                                 // Refund a Sale in Whole or in Part
+	router.Handler(http.MethodPost, "/v1/sales/:id/complete", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleCreate)(http.HandlerFunc(app.completeSaleHandler))))                             // Complete a Draft Sale
+	router.Handler(http.MethodPost, "/v1/sales/:id/void", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleVoid)(http.HandlerFunc(app.voidSaleHandler))))                                       // Void a Sale Made by Mistake
+	router.Handler(http.MethodGet, "/v1/sales/:id/attachments", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.listSaleAttachmentsHandler))))                      // List a Sale's Attachments
+	router.Handler(http.MethodPost, "/v1/sales/:id/attachments", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleUpdate)(http.HandlerFunc(app.uploadSaleAttachmentHandler))))                  // Attach a File to a Sale
+	router.Handler(http.MethodGet, "/v1/sales/:id/attachments/:attachment_id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.downloadSaleAttachmentHandler))))    // Download an Attachment
+	router.Handler(http.MethodDelete, "/v1/sales/:id/attachments/:attachment_id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleUpdate)(http.HandlerFunc(app.deleteSaleAttachmentHandler)))) // Delete an Attachment
+	router.Handler(http.MethodPost, "/v1/admin/sales/bulk-delete", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleDelete)(http.HandlerFunc(app.bulkDeleteSalesHandler))))                     // Bulk Delete or Archive Sales
+
+	// Receipts emailed on request send mail to any address, so they need sale:create and have their own limiter
+	router.Handler(http.MethodPost, "/v1/sales/:id/receipt/email", app.rateLimitReceiptEmails(app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleCreate)(http.HandlerFunc(app.emailSaleReceiptHandler))))) // Email the Receipt to the Customer
+
+	// Customer Routes, the people sales are attributed to and their purchase history
+	router.Handler(http.MethodGet, "/v1/customers", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionCustomerView)(http.HandlerFunc(app.listCustomersHandler))))               // List Customers
+	router.Handler(http.MethodPost, "/v1/customers", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionCustomerCreate)(http.HandlerFunc(app.createCustomerHandler))))           // Create Customer
+	router.Handler(http.MethodGet, "/v1/customers/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionCustomerView)(http.HandlerFunc(app.getCustomerHandler))))             // Get Customer by ID
+	router.Handler(http.MethodPut, "/v1/customers/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionCustomerUpdate)(http.HandlerFunc(app.updateCustomerHandler))))        // Update Customer by ID
+	router.Handler(http.MethodDelete, "/v1/customers/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionCustomerDelete)(http.HandlerFunc(app.deleteCustomerHandler))))     // Delete Customer by ID
+	router.Handler(http.MethodGet, "/v1/customers/:id/sales", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionCustomerView)(http.HandlerFunc(app.listCustomerSalesHandler)))) // List a Customer's Sales
+
+	// Report Routes, aggregates over the sales a user may view
+	router.Handler(http.MethodGet, "/v1/reports/sales/heatmap", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.salesHeatmapHandler))))        // Sales by Weekday and Hour
+	router.Handler(http.MethodGet, "/v1/reports/sales/duplicates", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleDelete)(http.HandlerFunc(app.duplicateSalesHandler)))) // Sales Flagged as Likely Duplicates
+	router.Handler(http.MethodGet, "/v1/reports/sales/summary", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.salesSummaryHandler))))        // Sales Totals by Period, Product or Cashier
+	router.Handler(http.MethodGet, "/v1/reports/sales/top-products", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.topProductsHandler))))    // Best Selling Products
+	router.Handler(http.MethodGet, "/v1/reports/sales/export", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.exportSalesHandler))))          // Download Sales as CSV or XLSX
+	router.Handler(http.MethodGet, "/v1/reports/sales/export.csv", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.exportSalesHandler))))      // Download Sales as CSV
+	router.Handler(http.MethodGet, "/v1/reports/revenue", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.revenueSeriesHandler))))             // Revenue per Hour, Day or Month
+	router.Handler(http.MethodGet, "/v1/reports/cashiers", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionReportsCashiers)(http.HandlerFunc(app.cashierReportHandler))))     // Sales Performance per Cashier
+	router.Handler(http.MethodPost, "/v1/reports/custom", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.runCustomReportHandler))))           // Run a Custom Report Definition
+	router.Handler(http.MethodGet, "/v1/reports/saved", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.listSavedReportsHandler))))            // List the User's Saved Reports
+	router.Handler(http.MethodPost, "/v1/reports/saved", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.createSavedReportHandler))))          // Save a Report Definition
+	router.Handler(http.MethodGet, "/v1/reports/saved/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.getSavedReportHandler))))          // Get a Saved Report
+	router.Handler(http.MethodPut, "/v1/reports/saved/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.updateSavedReportHandler))))       // Update a Saved Report
+	router.Handler(http.MethodDelete, "/v1/reports/saved/:id", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.deleteSavedReportHandler))))    // Delete a Saved Report
+	router.Handler(http.MethodGet, "/v1/reports/saved/:id/run", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.runSavedReportHandler))))      // Run a Saved Report
+
+	// Revenue Target Routes, one target per calendar month
+	router.Handler(http.MethodGet, "/v1/targets", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionTargetsView)(http.HandlerFunc(app.listTargetsHandler))))                     // List a Year's Targets
+	router.Handler(http.MethodPut, "/v1/targets/:month", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionTargetsUpdate)(http.HandlerFunc(app.setTargetHandler))))              // Set a Month's Target
+	router.Handler(http.MethodDelete, "/v1/targets/:month", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionTargetsUpdate)(http.HandlerFunc(app.deleteTargetHandler))))        // Remove a Month's Target
+	router.Handler(http.MethodGet, "/v1/reports/targets/progress", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionTargetsView)(http.HandlerFunc(app.targetProgressHandler)))) // Progress Towards a Month's Target
+
+	// Offline Sync Routes, terminals push queued sales and pull server side changes
+	router.Handler(http.MethodPost, "/v1/sync/sales", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleCreate)(http.HandlerFunc(app.pushSalesSyncHandler)))) // Push Queued Sales
+	router.Handler(http.MethodGet, "/v1/sync/sales", app.requireAuthenticatedUser(app.requirePermissions(data.PermissionSaleView)(http.HandlerFunc(app.pullSalesSyncHandler))))    // Pull Sales Changes
 
-	return app.recoverPanic(app.enableCORS(app.metrics(app.rateLimit(app.authenticate(router)))))
+	return app.recoverPanic(app.enableCORS(app.metrics(app.injectFaults(app.requireReady(app.requestDeadline(app.rateLimit(app.authenticate(router))))))))
 }
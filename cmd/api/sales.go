@@ -4,8 +4,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"time"
 
 	"github.com/Pedro-J-Kukul/salesapi/internal/data"
 	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
@@ -15,9 +18,17 @@ import (
 func (app *app) createSaleHandler(w http.ResponseWriter, r *http.Request) {
 	// Create Payload Struct
 	var SaleCreatePayload struct {
-		UserID    int64 `json:"user_id"`
-		ProductID int64 `json:"product_id"`
-		Quantity  int64 `json:"quantity"`
+		UserID    int64            `json:"user_id"` // Optional - the seller, the authenticated user by default
+		Items     []*data.SaleItem `json:"items"`
+		ProductID int64            `json:"product_id"` // Optional - a sale of one product, instead of items
+		Quantity  int64            `json:"quantity"`
+		Discount  string           `json:"discount_code"` // Optional - taken off the items it covers
+		Note      string           `json:"note"`
+		Customer  string           `json:"customer_email"` // Optional - where the receipt is emailed, the customer's email when left out
+		Receipt   *bool            `json:"email_receipt"`  // Optional - records whether the customer wants email receipts
+
+		CustomerID *int64 `json:"customer_id"` // Optional - the customer the sale is attributed to
+		Status     string `json:"status"`      // Optional - "draft" parks the cart without taking stock, "completed" by default
 	}
 
 	err := app.readJSON(w, r, &SaleCreatePayload)
@@ -26,30 +37,100 @@ func (app *app) createSaleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sale := &data.Sale{
-		UserID:    SaleCreatePayload.UserID,
-		ProductID: SaleCreatePayload.ProductID,
-		Quantity:  SaleCreatePayload.Quantity,
+	// Sales are attributed to whoever records them unless they may assign them to someone else
+	user := app.contextGetUser(r)
+	if SaleCreatePayload.UserID == 0 {
+		SaleCreatePayload.UserID = user.ID
+	}
+	if SaleCreatePayload.UserID != user.ID {
+		allowed, err := app.canAssignSales(user)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !allowed {
+			app.notPermittedResponse(w, r)
+			return
+		}
 	}
 
 	// Validate Sale
 	v := validator.New()
 
+	sale := &data.Sale{
+		UserID:        SaleCreatePayload.UserID,
+		Items:         saleItems(v, SaleCreatePayload.Items, SaleCreatePayload.ProductID, SaleCreatePayload.Quantity),
+		Note:          SaleCreatePayload.Note,
+		CreatedBy:     &user.ID,
+		CustomerID:    SaleCreatePayload.CustomerID,
+		CustomerEmail: SaleCreatePayload.Customer,
+		Status:        SaleCreatePayload.Status,
+	}
+	if code := data.NormalizeDiscountCode(SaleCreatePayload.Discount); code != "" {
+		sale.DiscountCode = &code
+	}
+
+	v.Check(sale.Status == "" || v.Permitted(sale.Status, data.SaleDraft, data.SaleCompleted), "status", "must be draft or completed")
 	if data.ValidateSale(v, sale); !v.IsValid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
+	if err := app.checkSaleProducts(v, sale.Items, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if err := app.checkSaleCustomer(v, sale); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	v.Check(SaleCreatePayload.Receipt == nil || sale.CustomerEmail != "", "email_receipt", "must be sent with customer_email or a customer that has an email")
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
 	err = app.models.Sales.Insert(sale)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrInsufficientStock):
+			app.insufficientStockResponse(w, r)
+		case errors.Is(err, data.ErrMixedCurrencies):
+			app.mixedCurrenciesResponse(w, r)
+		case errors.Is(err, data.ErrUnknownUser), errors.Is(err, data.ErrUnknownProduct), errors.Is(err, data.ErrUnknownCustomer):
+			app.failedValidationResponse(w, r, unknownReferenceErrors(err))
+		case errors.Is(err, data.ErrInvalidDiscount):
+			v.AddError("discount_code", "must be an active discount code")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDiscountNotApplicable):
+			v.AddError("discount_code", "must apply to at least one item of the sale")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
+	// The sale is recorded either way, so a lost preference is only logged
+	if SaleCreatePayload.Receipt != nil {
+		preference := &data.ReceiptPreference{Email: sale.CustomerEmail, EmailReceipts: *SaleCreatePayload.Receipt, UpdatedBy: sale.CreatedBy}
+		if err := app.models.Receipts.SetPreference(preference); err != nil {
+			app.logError(r, err)
+		}
+	}
+
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/sales/%d", sale.ID))
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"sale": sale}, headers)
+	// Drafts are receipted and checked for duplicates once they are completed
+	env := envelope{"sale": sale}
+	if sale.Status == data.SaleCompleted {
+		app.emailReceipt(sale, app.contextGetUser(r).Location())
+		if warnings := app.duplicateSaleWarnings(r, sale); len(warnings) > 0 {
+			env["warnings"] = warnings
+		}
+	}
+	err = app.writeJSON(w, http.StatusCreated, env, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -62,12 +143,7 @@ func (app *app) listSalesHandler(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 	v := validator.New()
 
-	SaleSafeList := []string{
-		"id", "user_id", "product_id", "quantity", "sold_at",
-		"-id", "-user_id", "-product_id", "-quantity", "-sold_at",
-	}
-
-	filter := app.readFilters(query, "id", 20, SaleSafeList, v)
+	filter := app.readFilters(query, "sales", "id", saleSortSafelist, v)
 	filters := data.SaleFilter{
 		Filter:    filter,
 		UserID:    app.getSingleIntQueryParameter(query, "user_id", 0, v),
@@ -76,6 +152,15 @@ func (app *app) listSalesHandler(w http.ResponseWriter, r *http.Request) {
 		MaxQty:    app.getSingleIntQueryParameter(query, "max_qty", 0, v),
 		MinDate:   app.getSingleDateQueryParameter(query, "min_date", "", v),
 		MaxDate:   app.getSingleDateQueryParameter(query, "max_date", "", v),
+		CreatedBy: app.getOptionalInt64QueryParameter(query, "created_by", v),
+		UpdatedBy: app.getOptionalInt64QueryParameter(query, "updated_by", v),
+
+		CustomerID: app.getSingleIntQueryParameter(query, "customer_id", 0, v),
+		Status:     app.getSingleQueryParameter(query, "status", ""),
+	}
+	v.Check(filters.Status == "" || v.Permitted(filters.Status, data.SaleDraft, data.SaleCompleted, data.SaleVoided), "status", "must be draft, completed or voided")
+	if includeArchived := app.getOptionalBoolQueryParameter(query, "include_archived", v); includeArchived != nil {
+		filters.IncludeArchived = *includeArchived
 	}
 
 	if !v.IsValid() {
@@ -85,7 +170,12 @@ func (app *app) listSalesHandler(w http.ResponseWriter, r *http.Request) {
 
 	sales, metadata, err := app.models.Sales.GetAll(filters)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrInvalidSort):
+			app.invalidSortResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -108,7 +198,7 @@ func (app *app) deleteSalesHandler(w http.ResponseWriter, r *http.Request) {
 	err = app.models.Sales.Delete(id)
 	if err != nil {
 		switch {
-		case err == data.ErrRecordNotFound:
+		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
@@ -116,7 +206,7 @@ func (app *app) deleteSalesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "sale successfully deleted"}, nil)
+	err = app.writeJSON(w, http.StatusNoContent, nil, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -135,7 +225,7 @@ func (app *app) updateSaleHandler(w http.ResponseWriter, r *http.Request) {
 	sales, err := app.models.Sales.Get(id)
 	if err != nil {
 		switch {
-		case err == data.ErrRecordNotFound:
+		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
@@ -145,9 +235,13 @@ func (app *app) updateSaleHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Create Payload Struct
 	var SaleUpdatePayload struct {
-		UserID    *int64 `json:"user_id"`
-		ProductID *int64 `json:"product_id"`
-		Quantity  *int64 `json:"quantity"`
+		UserID   *int64            `json:"user_id"`
+		Items    *[]*data.SaleItem `json:"items"`         // replaces every item of the sale
+		Discount *string           `json:"discount_code"` // replaces the sale's discount, "" removes it
+		Note     *string           `json:"note"`
+		Customer *string           `json:"customer_email"` // "" removes it
+
+		CustomerID *int64 `json:"customer_id"` // 0 removes it
 	}
 
 	err = app.readJSON(w, r, &SaleUpdatePayload)
@@ -156,15 +250,41 @@ func (app *app) updateSaleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if SaleUpdatePayload.UserID != nil {
+	previous := sales.Items
+	if SaleUpdatePayload.UserID != nil && *SaleUpdatePayload.UserID != sales.UserID {
+		allowed, err := app.canAssignSales(app.contextGetUser(r))
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !allowed {
+			app.notPermittedResponse(w, r)
+			return
+		}
 		sales.UserID = *SaleUpdatePayload.UserID
 	}
-	if SaleUpdatePayload.ProductID != nil {
-		sales.ProductID = *SaleUpdatePayload.ProductID
+	if SaleUpdatePayload.Items != nil {
+		sales.Items = *SaleUpdatePayload.Items
+	}
+	if SaleUpdatePayload.Note != nil {
+		sales.Note = *SaleUpdatePayload.Note
+	}
+	if SaleUpdatePayload.Customer != nil {
+		sales.CustomerEmail = *SaleUpdatePayload.Customer
+	}
+	if SaleUpdatePayload.CustomerID != nil {
+		sales.CustomerID = nil
+		if *SaleUpdatePayload.CustomerID != 0 {
+			sales.CustomerID = SaleUpdatePayload.CustomerID
+		}
 	}
-	if SaleUpdatePayload.Quantity != nil {
-		sales.Quantity = *SaleUpdatePayload.Quantity
+	if SaleUpdatePayload.Discount != nil {
+		sales.DiscountID, sales.DiscountCode = nil, nil
+		if code := data.NormalizeDiscountCode(*SaleUpdatePayload.Discount); code != "" {
+			sales.DiscountCode = &code
+		}
 	}
+	sales.UpdatedBy = &app.contextGetUser(r).ID
 
 	// Validate Sale
 	v := validator.New()
@@ -174,9 +294,42 @@ func (app *app) updateSaleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Existing sales keep their products even after they are deactivated
+	if err := app.checkSaleProducts(v, sales.Items, previous); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if err := app.checkSaleCustomer(v, sales); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
 	err = app.models.Sales.Update(sales)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrMixedCurrencies):
+			app.mixedCurrenciesResponse(w, r)
+		case errors.Is(err, data.ErrUnknownUser), errors.Is(err, data.ErrUnknownProduct), errors.Is(err, data.ErrUnknownCustomer):
+			app.failedValidationResponse(w, r, unknownReferenceErrors(err))
+		case errors.Is(err, data.ErrSaleRefunded):
+			app.saleRefundedResponse(w, r)
+		case errors.Is(err, data.ErrSaleVoided):
+			app.saleVoidedResponse(w, r)
+		case errors.Is(err, data.ErrInvalidDiscount):
+			v.AddError("discount_code", "must be an active discount code")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDiscountNotApplicable):
+			v.AddError("discount_code", "must apply to at least one item of the sale")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -199,7 +352,7 @@ func (app *app) getSaleHandler(w http.ResponseWriter, r *http.Request) {
 	sale, err := app.models.Sales.Get(id)
 	if err != nil {
 		switch {
-		case err == data.ErrRecordNotFound:
+		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
@@ -213,3 +366,184 @@ func (app *app) getSaleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// saleSortSafelist are the fields sales listings can be sorted by.
+var saleSortSafelist = []string{
+	"id", "user_id", "quantity", "sold_at",
+	"-id", "-user_id", "-quantity", "-sold_at",
+}
+
+// mixedCurrenciesErrors are the validation errors of a sale whose products are priced in different
+// currencies, which cannot be totalled.
+var mixedCurrenciesErrors = map[string]string{"items": "must all be priced in the same currency"}
+
+// unknownReferenceErrors returns the validation errors of a sale naming a user, product or customer
+// that does not exist, or nil when err is not one of those.
+func unknownReferenceErrors(err error) map[string]string {
+	switch {
+	case errors.Is(err, data.ErrUnknownUser):
+		return map[string]string{"user_id": "must be an existing user"}
+	case errors.Is(err, data.ErrUnknownProduct):
+		return map[string]string{"items": "must only contain existing products"}
+	case errors.Is(err, data.ErrUnknownCustomer):
+		return map[string]string{"customer_id": "must be an existing customer"}
+	}
+	return nil
+}
+
+// saleItems returns the items of a sale payload. Clients may still send a single product_id and
+// quantity instead, which become the one item of the sale; sending both forms is a validation error.
+func saleItems(v *validator.Validator, items []*data.SaleItem, productID, quantity int64) []*data.SaleItem {
+	if productID == 0 && quantity == 0 {
+		return items
+	}
+	v.Check(len(items) == 0, "items", "must not be combined with product_id and quantity")
+	return []*data.SaleItem{{ProductID: productID, Quantity: quantity}}
+}
+
+// canAssignSales reports whether the user may record sales on behalf of others, or move a sale to
+// another seller.
+func (app *app) canAssignSales(user *data.User) (bool, error) {
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		return false, err
+	}
+	return permissions.Includes(data.PermissionSaleAssign), nil
+}
+
+// checkSaleProducts adds a validation error when an item's product does not exist or is no longer
+// on sale. Products among the previous items of the sale are not checked again.
+func (app *app) checkSaleProducts(v *validator.Validator, items, previous []*data.SaleItem) error {
+	for _, item := range items {
+		if slices.ContainsFunc(previous, func(p *data.SaleItem) bool { return p.ProductID == item.ProductID }) {
+			continue
+		}
+
+		product, err := app.models.Products.Get(item.ProductID)
+		if err != nil {
+			if errors.Is(err, data.ErrRecordNotFound) {
+				v.AddError("items", fmt.Sprintf("product %d does not exist", item.ProductID))
+				return nil
+			}
+			return err
+		}
+		v.Check(product.IsActive, "items", fmt.Sprintf("product %d has been deactivated", item.ProductID))
+		v.Check(!product.IsArchived, "items", fmt.Sprintf("product %d has been deleted", item.ProductID))
+		if !v.IsValid() {
+			return nil
+		}
+	}
+	return nil
+}
+
+// bulkConfirmationTTL is how long a previewed bulk operation may wait for confirmation.
+const bulkConfirmationTTL = 5 * time.Minute
+
+// bulkDeleteSalesHandler deletes or archives many sales at once. A call without a confirmation token
+// previews the selection and returns a token; repeating the call with that token performs the operation.
+func (app *app) bulkDeleteSalesHandler(w http.ResponseWriter, r *http.Request) {
+	// BulkDeleteSalesPayload struct to hold the incoming JSON payload
+	var BulkDeleteSalesPayload struct {
+		IDs    []int64 `json:"ids"`
+		Filter struct {
+			UserID    int64  `json:"user_id"`
+			ProductID int64  `json:"product_id"`
+			MinDate   string `json:"min_date"`
+			MaxDate   string `json:"max_date"`
+		} `json:"filter"`
+		Archive           bool   `json:"archive"`
+		ConfirmationToken string `json:"confirmation_token"`
+	}
+
+	if err := app.readJSON(w, r, &BulkDeleteSalesPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	action := data.ActionBulkDeleteSales
+	if BulkDeleteSalesPayload.Archive {
+		action = data.ActionBulkArchiveSales
+	}
+
+	v := validator.New()
+
+	// Second step: run the previewed operation
+	if BulkDeleteSalesPayload.ConfirmationToken != "" {
+		confirmation, err := app.models.Confirmations.Consume(user.ID, action, BulkDeleteSalesPayload.ConfirmationToken)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrInvalidToken):
+				v.AddError("confirmation_token", "invalid or expired confirmation token")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		var affected []int64
+		if BulkDeleteSalesPayload.Archive {
+			affected, err = app.modelsFor(r).Sales.ArchiveMany(confirmation.EntityIDs, user.ID)
+		} else {
+			affected, err = app.modelsFor(r).Sales.DeleteMany(confirmation.EntityIDs)
+		}
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		entry := &data.AuditEntry{
+			UserID:    &user.ID,
+			Action:    action,
+			Entity:    "sales",
+			EntityIDs: affected,
+			Details:   map[string]any{"confirmed_ids": confirmation.EntityIDs},
+		}
+		if err := app.models.Audit.Insert(entry); err != nil {
+			app.logger.Error("failed to write audit log", "action", action, "user_id", user.ID, "sale_ids", affected, "error", err)
+		}
+
+		if err := app.writeJSON(w, http.StatusOK, envelope{"action": action, "affected": len(affected), "ids": affected}, nil); err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		return
+	}
+
+	// First step: resolve the selection and hand back a confirmation token
+	selection := data.SaleSelection{
+		IDs:       BulkDeleteSalesPayload.IDs,
+		UserID:    BulkDeleteSalesPayload.Filter.UserID,
+		ProductID: BulkDeleteSalesPayload.Filter.ProductID,
+		MinDate:   BulkDeleteSalesPayload.Filter.MinDate,
+		MaxDate:   BulkDeleteSalesPayload.Filter.MaxDate,
+	}
+	if data.ValidateSaleSelection(v, &selection); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	ids, err := app.models.Sales.SelectIDs(selection)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	v.Check(len(ids) > 0, "selection", "does not match any sales")
+	v.Check(len(ids) <= data.MaxBulkSales, "selection", "matches more than 1000 sales, narrow the filter")
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	confirmation, err := app.models.Confirmations.New(user.ID, action, ids, bulkConfirmationTTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusAccepted, envelope{"confirmation": confirmation}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
@@ -5,13 +5,17 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Pedro-J-Kukul/salesapi/internal/data"
 	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/julienschmidt/httprouter"
 )
 
 // TestSaleValidation tests sale validation logic
@@ -21,6 +25,7 @@ func TestSaleValidation(t *testing.T) {
 		userID        int64
 		productID     int64
 		quantity      int64
+		extraItems    []*data.SaleItem
 		expectedValid bool
 		errorField    string
 	}{
@@ -37,7 +42,7 @@ func TestSaleValidation(t *testing.T) {
 			productID:     1,
 			quantity:      0,
 			expectedValid: false,
-			errorField:    "quantity",
+			errorField:    "items",
 		},
 		{
 			name:          "Negative Quantity",
@@ -45,7 +50,7 @@ func TestSaleValidation(t *testing.T) {
 			productID:     1,
 			quantity:      -5,
 			expectedValid: false,
-			errorField:    "quantity",
+			errorField:    "items",
 		},
 		{
 			name:          "Zero User ID",
@@ -61,7 +66,7 @@ func TestSaleValidation(t *testing.T) {
 			productID:     0,
 			quantity:      5,
 			expectedValid: false,
-			errorField:    "product_id",
+			errorField:    "items",
 		},
 		{
 			name:          "Negative User ID",
@@ -71,15 +76,38 @@ func TestSaleValidation(t *testing.T) {
 			expectedValid: false,
 			errorField:    "user_id",
 		},
+		{
+			name:          "Several Items",
+			userID:        1,
+			productID:     1,
+			quantity:      5,
+			extraItems:    []*data.SaleItem{{ProductID: 2, Quantity: 1}, {ProductID: 3, Quantity: 12}},
+			expectedValid: true,
+		},
+		{
+			name:          "Same Product Twice",
+			userID:        1,
+			productID:     1,
+			quantity:      5,
+			extraItems:    []*data.SaleItem{{ProductID: 1, Quantity: 2}},
+			expectedValid: false,
+			errorField:    "items",
+		},
+		{
+			name:          "No Items",
+			userID:        1,
+			expectedValid: false,
+			errorField:    "items",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			sale := &data.Sale{
-				UserID:    tt.userID,
-				ProductID: tt.productID,
-				Quantity:  tt.quantity,
+			sale := &data.Sale{UserID: tt.userID}
+			if tt.productID != 0 || tt.quantity != 0 {
+				sale.Items = append(sale.Items, &data.SaleItem{ProductID: tt.productID, Quantity: tt.quantity})
 			}
+			sale.Items = append(sale.Items, tt.extraItems...)
 
 			v := validator.New()
 			data.ValidateSale(v, sale)
@@ -229,18 +257,212 @@ func TestSaleJSONParsing(t *testing.T) {
 	}
 }
 
+// TestSaleItems tests that the single product form of a sale payload becomes one item
+func TestSaleItems(t *testing.T) {
+	tests := []struct {
+		name          string
+		items         []*data.SaleItem
+		productID     int64
+		quantity      int64
+		expectedItems int
+		expectedValid bool
+	}{
+		{name: "Items", items: []*data.SaleItem{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}}, expectedItems: 2, expectedValid: true},
+		{name: "Single Product", productID: 4, quantity: 3, expectedItems: 1, expectedValid: true},
+		{name: "Both Forms", items: []*data.SaleItem{{ProductID: 1, Quantity: 2}}, productID: 4, quantity: 3, expectedItems: 1, expectedValid: false},
+		{name: "Neither Form", expectedItems: 0, expectedValid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			items := saleItems(v, tt.items, tt.productID, tt.quantity)
+
+			if len(items) != tt.expectedItems {
+				t.Errorf("expected %d items, got %d", tt.expectedItems, len(items))
+			}
+			if v.IsValid() != tt.expectedValid {
+				t.Errorf("expected valid=%v, got errors %v", tt.expectedValid, v.Errors)
+			}
+			if tt.productID != 0 && len(items) == 1 && (items[0].ProductID != tt.productID || items[0].Quantity != tt.quantity) {
+				t.Errorf("expected the item to be product %d x%d, got %+v", tt.productID, tt.quantity, items[0])
+			}
+		})
+	}
+}
+
 // TestCreateSaleHandler_Integration is marked as integration test
 func TestCreateSaleHandler_Integration(t *testing.T) {
 	t.Skip("Integration test - requires database connection")
 
 	// This would test the actual endpoint with a real database
 	payload := map[string]interface{}{
-		"user_id":    1,
-		"product_id": 1,
-		"quantity":   5,
+		"user_id": 1,
+		"items":   []map[string]int64{{"product_id": 1, "quantity": 5}, {"product_id": 2, "quantity": 1}},
 	}
 	body, _ := json.Marshal(payload)
 	req := httptest.NewRequest(http.MethodPost, "/v1/sales", bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
 	_ = req
 }
+
+// TestSaleTimestampJSON tests that sale timestamps are always rendered as RFC3339 in UTC
+func TestSaleTimestampJSON(t *testing.T) {
+	belize := time.FixedZone("CST", -6*60*60)
+
+	tests := []struct {
+		name     string
+		soldAt   time.Time
+		expected string
+	}{
+		{
+			name:     "UTC Time",
+			soldAt:   time.Date(2025, 3, 14, 9, 30, 0, 0, time.UTC),
+			expected: `"2025-03-14T09:30:00Z"`,
+		},
+		{
+			name:     "Offset Time Converted To UTC",
+			soldAt:   time.Date(2025, 3, 14, 9, 30, 0, 0, belize),
+			expected: `"2025-03-14T15:30:00Z"`,
+		},
+		{
+			name:     "Fractional Seconds Kept",
+			soldAt:   time.Date(2025, 3, 14, 9, 30, 0, 500000000, time.UTC),
+			expected: `"2025-03-14T09:30:00.5Z"`,
+		},
+		{
+			name:     "Zero Time Is Null",
+			soldAt:   time.Time{},
+			expected: `null`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(data.Sale{SoldAt: data.NewTimestamp(tt.soldAt)})
+			if err != nil {
+				t.Fatalf("failed to marshal sale: %v", err)
+			}
+
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(body, &fields); err != nil {
+				t.Fatalf("failed to unmarshal sale: %v", err)
+			}
+
+			if got := string(fields["sold_at"]); got != tt.expected {
+				t.Errorf("expected sold_at=%s, got=%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestSaleSelectionValidation tests validation of bulk sale selections
+func TestSaleSelectionValidation(t *testing.T) {
+	tooMany := make([]int64, data.MaxBulkSales+1)
+	for i := range tooMany {
+		tooMany[i] = int64(i + 1)
+	}
+
+	tests := []struct {
+		name          string
+		selection     data.SaleSelection
+		expectedValid bool
+		errorField    string
+	}{
+		{
+			name:          "Valid IDs",
+			selection:     data.SaleSelection{IDs: []int64{1, 2, 3}},
+			expectedValid: true,
+		},
+		{
+			name:          "Valid Filter",
+			selection:     data.SaleSelection{UserID: 4, MinDate: "2025-01-01", MaxDate: "2025-01-31"},
+			expectedValid: true,
+		},
+		{
+			name:          "Empty Selection",
+			selection:     data.SaleSelection{},
+			expectedValid: false,
+			errorField:    "selection",
+		},
+		{
+			name:          "IDs And Filter",
+			selection:     data.SaleSelection{IDs: []int64{1}, ProductID: 2},
+			expectedValid: false,
+			errorField:    "selection",
+		},
+		{
+			name:          "Too Many IDs",
+			selection:     data.SaleSelection{IDs: tooMany},
+			expectedValid: false,
+			errorField:    "ids",
+		},
+		{
+			name:          "Non Positive ID",
+			selection:     data.SaleSelection{IDs: []int64{1, 0}},
+			expectedValid: false,
+			errorField:    "ids",
+		},
+		{
+			name:          "Invalid Date",
+			selection:     data.SaleSelection{MinDate: "2025-13-01"},
+			expectedValid: false,
+			errorField:    "min_date",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			data.ValidateSaleSelection(v, &tt.selection)
+
+			if v.IsValid() != tt.expectedValid {
+				t.Errorf("expected valid=%v, got valid=%v, errors=%v", tt.expectedValid, v.IsValid(), v.Errors)
+			}
+			if !tt.expectedValid {
+				if _, exists := v.Errors[tt.errorField]; !exists {
+					t.Errorf("expected error for field %s, got errors=%v", tt.errorField, v.Errors)
+				}
+			}
+		})
+	}
+}
+
+// TestSaleStatusValidation tests that sales may only be created as drafts or completed, and that
+// voids with overlong reasons are refused, before the database is used
+func TestSaleStatusValidation(t *testing.T) {
+	app := newTestApp()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		field   string
+	}{
+		{"Create Voided", app.createSaleHandler, `{"items": [{"product_id": 1, "quantity": 1}], "status": "voided"}`, "status"},
+		{"Create Unknown Status", app.createSaleHandler, `{"items": [{"product_id": 1, "quantity": 1}], "status": "parked"}`, "status"},
+		{"Void Reason Too Long", app.voidSaleHandler, `{"reason": "` + strings.Repeat("x", data.MaxVoidReason+1) + `"}`, "reason"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/sales/1/void", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(context.WithValue(req.Context(), httprouter.ParamsKey, httprouter.Params{{Key: "id", Value: "1"}}))
+			req = app.contextSetUser(req, &data.User{ID: 7})
+			rr := httptest.NewRecorder()
+
+			tt.handler(rr, req)
+
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Errorf("expected status %d, got %d: %s", http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+			}
+			if !strings.Contains(rr.Body.String(), `"`+tt.field+`"`) {
+				t.Errorf("expected a %s error, got %s", tt.field, rr.Body.String())
+			}
+			if strings.Contains(rr.Body.String(), `"user_id"`) {
+				t.Errorf("expected the sale to be attributed to the authenticated user, got %s", rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+// File: cmd/api/salesexport.go
+// Description: spreadsheet exports of sales, streamed as CSV or Excel workbooks
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/Pedro-J-Kukul/salesapi/internal/xlsx"
+)
+
+// salesExportColumns is the header row of a sales export in every format, one column per field of
+// data.SaleExportRow.
+var salesExportColumns = []string{
+	"sale_id", "sold_at", "status", "user_id", "cashier", "customer_id", "customer",
+	"product_id", "product", "quantity", "unit_price", "line_total", "discount_amount", "tax_amount",
+	"currency", "sale_total", "discount_code", "note", "refund_id", "refunded_at",
+}
+
+// salesExportFormats lists the file formats of the sales export, the first being the default.
+var salesExportFormats = []string{"csv", "xlsx"}
+
+// exportSalesHandler streams the items sold and refunded between ?start_date= and ?end_date=,
+// one row per item with the names of the cashier, customer and product, as a download in
+// ?format= csv (the default) or xlsx. Dates are whole days in ?timezone=, the user's own by
+// default, and the range defaults to the last reportDefaultDays days. It needs no spreadsheet
+// integration, so any deployment can use it.
+func (app *app) exportSalesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	v := validator.New()
+	reportRange := app.readDateRange(query, "start_date", "end_date", app.contextGetUser(r), v)
+	format := app.getSingleQueryParameter(query, "format", salesExportFormats[0])
+	v.Check(v.Permitted(format, salesExportFormats...), "format", "must be one of "+strings.Join(salesExportFormats, ", "))
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Large exports outlast the server's write timeout, so this response gets the export's own
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(app.models.Sales.Timeouts.For(data.ExportQuery) + 5*time.Second)); err != nil {
+		app.logger.Warn("could not extend the write deadline of a sales export", "error", err)
+	}
+
+	filename := fmt.Sprintf("sales-%s-to-%s.%s", reportRange.From.Format(time.DateOnly), reportRange.To.Format(time.DateOnly), format)
+
+	// Headers go out with the first row, so a query that fails straight away still gets a JSON error
+	var out salesExporter
+	start := func() error {
+		if out != nil {
+			return nil
+		}
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		w.Header().Set("Cache-Control", "no-store")
+		if format == "xlsx" {
+			w.Header().Set("Content-Type", xlsx.ContentType)
+			w.WriteHeader(http.StatusOK)
+			out = newXLSXSalesExporter(w, reportRange)
+		} else {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			out = newCSVSalesExporter(w)
+		}
+		return out.start()
+	}
+
+	rows := 0
+	err := app.modelsFor(r).Sales.Export(reportRange, func(row *data.SaleExportRow) error {
+		if err := start(); err != nil {
+			return err
+		}
+		rows++
+		if err := out.writeRow(row); err != nil {
+			return err
+		}
+		// Send the rows in batches so the client sees progress and memory stays flat
+		if rows%500 == 0 {
+			if err := out.flush(); err != nil {
+				return err
+			}
+			if err := rc.Flush(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if out == nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		// The status has been sent, so all that is left is to cut the file short
+		app.logger.Error("sales export stopped early", "user_id", app.contextGetUser(r).ID, "format", format, "rows", rows, "error", err)
+		return
+	}
+
+	if err := start(); err != nil {
+		app.logger.Error("failed to write sales export", "format", format, "error", err)
+		return
+	}
+	if err := out.close(); err != nil {
+		app.logger.Error("failed to write sales export", "format", format, "error", err)
+	}
+}
+
+// salesExporter writes the rows of a sales export in one file format.
+type salesExporter interface {
+	start() error                           // write anything that comes before the rows
+	writeRow(row *data.SaleExportRow) error // add one sold or refunded item
+	flush() error                           // send the rows written so far
+	close() error                           // finish the file
+}
+
+// csvSalesExporter writes a sales export as CSV, a header row and then one line per item.
+type csvSalesExporter struct {
+	out *csv.Writer
+}
+
+// newCSVSalesExporter returns an exporter writing CSV to w.
+func newCSVSalesExporter(w io.Writer) *csvSalesExporter {
+	return &csvSalesExporter{out: csv.NewWriter(w)}
+}
+
+func (e *csvSalesExporter) start() error {
+	return e.out.Write(salesExportColumns)
+}
+
+func (e *csvSalesExporter) writeRow(row *data.SaleExportRow) error {
+	return e.out.Write(salesExportRecord(row))
+}
+
+func (e *csvSalesExporter) flush() error {
+	e.out.Flush()
+	return e.out.Error()
+}
+
+func (e *csvSalesExporter) close() error {
+	return e.flush()
+}
+
+// xlsxSalesExporter writes a sales export as an Excel workbook: a Sales sheet with the same
+// columns as the CSV, numbers stored as numbers, and a Summary sheet totalling the export.
+type xlsxSalesExporter struct {
+	book    *xlsx.Writer
+	summary salesExportSummary
+}
+
+// newXLSXSalesExporter returns an exporter writing a workbook for the range to w.
+func newXLSXSalesExporter(w io.Writer, reportRange data.ReportRange) *xlsxSalesExporter {
+	return &xlsxSalesExporter{book: xlsx.NewWriter(w), summary: salesExportSummary{reportRange: reportRange}}
+}
+
+func (e *xlsxSalesExporter) start() error {
+	if err := e.book.AddSheet("Sales"); err != nil {
+		return err
+	}
+	header := make([]any, len(salesExportColumns))
+	for i, column := range salesExportColumns {
+		header[i] = column
+	}
+	return e.book.WriteHeader(header...)
+}
+
+func (e *xlsxSalesExporter) writeRow(row *data.SaleExportRow) error {
+	e.summary.add(row)
+
+	optionalID := func(id *int64) any {
+		if id == nil {
+			return nil
+		}
+		return *id
+	}
+	return e.book.WriteRow(
+		row.SaleID, row.SoldAt.Format(time.RFC3339), row.Status, row.UserID, row.Cashier,
+		optionalID(row.CustomerID), row.Customer, optionalID(row.ProductID), row.Product,
+		row.Quantity, row.UnitPrice, row.LineTotal, row.Discount, row.Tax,
+		row.Currency, row.SaleTotal, row.DiscountCode, row.Note,
+		optionalID(row.RefundID), refundedAt(row),
+	)
+}
+
+func (e *xlsxSalesExporter) flush() error {
+	return e.book.Flush()
+}
+
+func (e *xlsxSalesExporter) close() error {
+	if err := e.book.AddSheet("Summary"); err != nil {
+		return err
+	}
+	if err := e.summary.write(e.book); err != nil {
+		return err
+	}
+	return e.book.Close()
+}
+
+// salesExportSummary totals the rows of an export as they stream past, for the Summary sheet.
+type salesExportSummary struct {
+	reportRange  data.ReportRange
+	rows         int64
+	lastSaleID   int64
+	lastRefundID int64
+	currencies   map[string]*salesExportTotals // revenue by currency
+}
+
+// salesExportTotals are the totals of the sold and refunded items in one currency.
+type salesExportTotals struct {
+	sales, refunds, items         int64
+	revenue, discount, tax, total float64
+}
+
+// add counts one row. Rows of a sale, and of a refund, arrive together, so each is counted on its
+// first row. Refunded items are negative, so they come off the totals.
+func (s *salesExportSummary) add(row *data.SaleExportRow) {
+	if s.currencies == nil {
+		s.currencies = make(map[string]*salesExportTotals)
+	}
+	s.rows++
+
+	totals, ok := s.currencies[row.Currency]
+	if !ok {
+		totals = &salesExportTotals{}
+		s.currencies[row.Currency] = totals
+	}
+	if row.RefundID == nil {
+		if row.SaleID != s.lastSaleID {
+			totals.sales++
+		}
+		s.lastSaleID = row.SaleID
+	} else {
+		if *row.RefundID != s.lastRefundID {
+			totals.refunds++
+		}
+		s.lastRefundID = *row.RefundID
+	}
+	totals.items += row.Quantity
+	totals.revenue += row.LineTotal
+	totals.discount += row.Discount
+	totals.tax += row.Tax
+	totals.total += row.LineTotal + row.Tax
+}
+
+// write adds the summary sections to the open sheet of the workbook: the range and the revenue by
+// currency, net of refunds.
+func (s *salesExportSummary) write(book *xlsx.Writer) error {
+	cents := func(amount float64) float64 {
+		return math.Round(amount*100) / 100
+	}
+
+	rows := [][]any{
+		{"From", s.reportRange.From.Format(time.DateOnly)},
+		{"To", s.reportRange.To.Format(time.DateOnly)},
+		{"Timezone", s.reportRange.Location.String()},
+		{"Rows", s.rows},
+	}
+	if err := book.WriteHeader("Sales export"); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := book.WriteRow(row...); err != nil {
+			return err
+		}
+	}
+
+	if err := book.WriteRow(); err != nil {
+		return err
+	}
+	if err := book.WriteHeader("Revenue", "sales", "refunds", "items", "revenue", "discount_amount", "tax_amount", "total_amount"); err != nil {
+		return err
+	}
+	for _, currency := range slices.Sorted(maps.Keys(s.currencies)) {
+		t := s.currencies[currency]
+		if err := book.WriteRow(currency, t.sales, t.refunds, t.items, cents(t.revenue), cents(t.discount), cents(t.tax), cents(t.total)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refundedAt is the time a refunded item was given back, or "" for a sold item.
+func refundedAt(row *data.SaleExportRow) string {
+	if row.RefundID == nil {
+		return ""
+	}
+	return row.OccurredAt.Format(time.RFC3339)
+}
+
+// salesExportRecord formats one export row in the order of salesExportColumns. Missing IDs are
+// left empty and free text is escaped so spreadsheets do not run it as a formula.
+func salesExportRecord(row *data.SaleExportRow) []string {
+	optionalID := func(id *int64) string {
+		if id == nil {
+			return ""
+		}
+		return strconv.FormatInt(*id, 10)
+	}
+	money := func(amount float64) string {
+		return strconv.FormatFloat(amount, 'f', 2, 64)
+	}
+
+	return []string{
+		strconv.FormatInt(row.SaleID, 10),
+		row.SoldAt.Format(time.RFC3339),
+		row.Status,
+		strconv.FormatInt(row.UserID, 10),
+		spreadsheetText(row.Cashier),
+		optionalID(row.CustomerID),
+		spreadsheetText(row.Customer),
+		optionalID(row.ProductID),
+		spreadsheetText(row.Product),
+		strconv.FormatInt(row.Quantity, 10),
+		money(row.UnitPrice),
+		money(row.LineTotal),
+		money(row.Discount),
+		money(row.Tax),
+		row.Currency,
+		money(row.SaleTotal),
+		spreadsheetText(row.DiscountCode),
+		spreadsheetText(row.Note),
+		optionalID(row.RefundID),
+		refundedAt(row),
+	}
+}
+
+// spreadsheetText prefixes text starting with a formula character with a quote, so a name such as
+// "=HYPERLINK(...)" shows as text when the export is opened in a spreadsheet.
+func spreadsheetText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
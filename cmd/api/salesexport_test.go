@@ -0,0 +1,178 @@
+// File: cmd/api/salesexport_test.go
+// Description: test suite for the CSV and XLSX sales exports
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+)
+
+// TestExportSalesCSVParameters tests that the date range is validated against its own parameter
+// names before the database is used
+func TestExportSalesCSVParameters(t *testing.T) {
+	app := newTestApp()
+
+	tests := []struct {
+		name  string
+		query string
+		field string
+	}{
+		{"Invalid Start", "start_date=2025-02-30", "start_date"},
+		{"Invalid End", "end_date=tomorrow", "end_date"},
+		{"Reversed Range", "start_date=2025-03-31&end_date=2025-03-01", "end_date"},
+		{"Range Too Long", "start_date=2024-01-01&end_date=2025-03-01", "end_date"},
+		{"Unknown Timezone", "timezone=Mars/Base", "timezone"},
+		{"Unknown Format", "format=pdf", "format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/reports/sales/export.csv?"+tt.query, nil)
+			req = app.contextSetUser(req, &data.User{ID: 7})
+			rr := httptest.NewRecorder()
+
+			app.exportSalesHandler(rr, req)
+
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("expected status 422, got %d: %s", rr.Code, rr.Body.String())
+			}
+			if !strings.Contains(rr.Body.String(), `"`+tt.field+`"`) {
+				t.Errorf("expected a %s error, got %s", tt.field, rr.Body.String())
+			}
+		})
+	}
+}
+
+// TestSalesExportRecord tests that rows follow the header, leave missing IDs empty and keep
+// spreadsheets from running names as formulas
+func TestSalesExportRecord(t *testing.T) {
+	customerID := int64(3)
+	row := &data.SaleExportRow{
+		SaleID:     12,
+		SoldAt:     time.Date(2025, 3, 14, 15, 30, 0, 0, time.UTC),
+		Status:     data.SaleCompleted,
+		UserID:     7,
+		Cashier:    "Ana Doe",
+		CustomerID: &customerID,
+		Customer:   "=HYPERLINK(\"http://example.com\")",
+		Quantity:   2,
+		UnitPrice:  9.99,
+		LineTotal:  19.98,
+		Tax:        2.5,
+		Currency:   "BZD",
+		SaleTotal:  22.48,
+		Note:       "-gift wrapped",
+	}
+
+	got := salesExportRecord(row)
+	want := []string{"12", "2025-03-14T15:30:00Z", "completed", "7", "Ana Doe", "3", "'=HYPERLINK(\"http://example.com\")", "", "", "2", "9.99", "19.98", "0.00", "2.50", "BZD", "22.48", "", "'-gift wrapped", "", ""}
+
+	if len(got) != len(salesExportColumns) {
+		t.Fatalf("expected %d columns to match the header, got %d", len(salesExportColumns), len(got))
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+// TestSalesExportRefundRecord tests that a refunded item is exported as a negative line naming its
+// refund and when it was given back
+func TestSalesExportRefundRecord(t *testing.T) {
+	refundID := int64(5)
+	productID := int64(9)
+	row := &data.SaleExportRow{
+		SaleID:     12,
+		RefundID:   &refundID,
+		OccurredAt: time.Date(2025, 3, 16, 9, 0, 0, 0, time.UTC),
+		SoldAt:     time.Date(2025, 3, 14, 15, 30, 0, 0, time.UTC),
+		Status:     data.SaleCompleted,
+		UserID:     7,
+		ProductID:  &productID,
+		Product:    "Coffee",
+		Quantity:   -1,
+		UnitPrice:  9.99,
+		LineTotal:  -9.99,
+		Tax:        -1.25,
+		Currency:   "BZD",
+		SaleTotal:  22.48,
+	}
+
+	got := salesExportRecord(row)
+	want := []string{"12", "2025-03-14T15:30:00Z", "completed", "7", "", "", "", "9", "Coffee", "-1", "9.99", "-9.99", "0.00", "-1.25", "BZD", "22.48", "", "", "5", "2025-03-16T09:00:00Z"}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+// TestXLSXSalesExporterSummary tests that the Summary sheet counts each sale and refund once and
+// nets refunded items off the revenue, per currency
+func TestXLSXSalesExporterSummary(t *testing.T) {
+	reportRange := data.ReportRange{
+		From:     time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC),
+		Location: time.UTC,
+	}
+	refundID := int64(5)
+	rows := []*data.SaleExportRow{
+		{SaleID: 1, Status: data.SaleCompleted, Quantity: 2, LineTotal: 10, Tax: 1, Currency: "BZD", SaleTotal: 14.5},
+		{SaleID: 1, Status: data.SaleCompleted, Quantity: 1, LineTotal: 3.5, Currency: "BZD", SaleTotal: 14.5},
+		{SaleID: 3, Status: data.SaleCompleted, Quantity: 1, LineTotal: 7.2, Discount: 0.8, Currency: "USD", SaleTotal: 7.2},
+		{SaleID: 1, RefundID: &refundID, Status: data.SaleCompleted, Quantity: -1, LineTotal: -3.5, Currency: "BZD", SaleTotal: 14.5},
+	}
+
+	var buf bytes.Buffer
+	out := newXLSXSalesExporter(&buf, reportRange)
+	if err := out.start(); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := out.writeRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := out.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	book, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("expected a valid workbook: %v", err)
+	}
+	var summary string
+	for _, f := range book.File {
+		if f.Name != "xl/worksheets/sheet2.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		summary = string(b)
+	}
+	if summary == "" {
+		t.Fatal("expected a Summary sheet")
+	}
+
+	for _, want := range []string{
+		// BZD: one sale of 3 items, one of them refunded
+		`<t xml:space="preserve">BZD</t></is></c><c r="B8"><v>1</v></c><c r="C8"><v>1</v></c><c r="D8"><v>2</v></c><c r="E8"><v>10</v></c><c r="F8"><v>0</v></c><c r="G8"><v>1</v></c><c r="H8"><v>11</v></c>`,
+		`<t xml:space="preserve">USD</t></is></c><c r="B9"><v>1</v></c><c r="C9"><v>0</v></c><c r="D9"><v>1</v></c><c r="E9"><v>7.2</v></c><c r="F9"><v>0.8</v></c><c r="G9"><v>0</v></c><c r="H9"><v>7.2</v></c>`,
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("expected the summary to contain %s, got %s", want, summary)
+		}
+	}
+}
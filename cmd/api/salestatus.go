@@ -0,0 +1,111 @@
+// File: cmd/api/salestatus.go
+// Description: handlers moving sales from draft to completed, and voiding them
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// completeSaleHandler completes a parked draft sale, taking its items from stock. Its receipt is
+// emailed and it is checked for duplicates as a newly created sale would be.
+func (app *app) completeSaleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	sale, err := app.models.Sales.Complete(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrSaleNotDraft):
+			app.saleNotDraftResponse(w, r)
+		case errors.Is(err, data.ErrInsufficientStock):
+			app.insufficientStockResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.emailReceipt(sale, user.Location())
+
+	env := envelope{"sale": sale}
+	if warnings := app.duplicateSaleWarnings(r, sale); len(warnings) > 0 {
+		env["warnings"] = warnings
+	}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// voidSaleHandler voids a sale made by mistake with an optional reason, keeping it for the audit
+// trail. Sales that were paid or refunded are refunded instead. Every void is written to the audit log.
+func (app *app) voidSaleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// VoidSalePayload struct to hold the incoming JSON payload
+	var VoidSalePayload struct {
+		Reason string `json:"reason"` // Optional - why the sale was voided, kept in the audit log
+	}
+
+	if r.ContentLength != 0 {
+		if err := app.readJSON(w, r, &VoidSalePayload); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	v := validator.New()
+	v.Check(len(VoidSalePayload.Reason) <= data.MaxVoidReason, "reason", fmt.Sprintf("must not be more than %d bytes long", data.MaxVoidReason))
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	sale, err := app.models.Sales.Void(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrSaleVoided):
+			app.saleVoidedResponse(w, r)
+		case errors.Is(err, data.ErrSalePaid), errors.Is(err, data.ErrSaleRefunded):
+			app.salePaidResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	entry := &data.AuditEntry{
+		UserID:    &user.ID,
+		Action:    data.ActionVoidSale,
+		Entity:    "sales",
+		EntityIDs: []int64{sale.ID},
+		Details:   map[string]any{"reason": VoidSalePayload.Reason},
+	}
+	if err := app.models.Audit.Insert(entry); err != nil {
+		app.logger.Error("failed to write audit log", "action", entry.Action, "user_id", user.ID, "sale_id", sale.ID, "error", err)
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"sale": sale}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
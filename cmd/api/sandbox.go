@@ -0,0 +1,78 @@
+// File: cmd/api/sandbox.go
+// Description: the sandbox environment, which stubs every external integration so the API runs
+// for demos and frontend development without credentials
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/mailer"
+	"github.com/Pedro-J-Kukul/salesapi/internal/notify"
+)
+
+// sandbox reports whether the instance runs with -env=sandbox.
+func (app *app) sandbox() bool {
+	return app.config.env == "sandbox"
+}
+
+// useSandboxIntegrations swaps the external integrations for local stand-ins: emails and alerts
+// are written to the log, and the chatbot's AI provider answers with canned responses. Any
+// SMTP, Twilio or AI provider credentials configured are ignored.
+func (app *app) useSandboxIntegrations() {
+	app.mailer = mailer.NewLogger(app.config.smtp.sender, app.logger)
+	app.notifier = notify.New(notify.NewLog(app.logger))
+	app.models.ChatbotModel.Token = "sandbox"
+	app.models.ChatbotModel.Client = &http.Client{Transport: sandboxAI{}}
+}
+
+// sandboxAI stands in for the AI provider's chat completions endpoint, answering every request
+// with a canned response that quotes the user's message.
+type sandboxAI struct{}
+
+// RoundTrip answers the request without sending it anywhere.
+func (sandboxAI) RoundTrip(r *http.Request) (*http.Response, error) {
+	var request data.GitHubChatRequest
+	if r.Body != nil {
+		defer r.Body.Close()
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			return nil, err
+		}
+	}
+
+	var message string
+	for _, m := range request.Messages {
+		if m.Role == "user" {
+			message = m.Content
+		}
+	}
+
+	var response data.GitHubChatResponse
+	response.Choices = make([]struct {
+		Message data.GitHubMessage `json:"message"`
+	}, 1)
+	response.Choices[0].Message = data.GitHubMessage{
+		Role:    "assistant",
+		Content: fmt.Sprintf("This is a canned sandbox response to %q. Outside the sandbox the AI provider answers from your store's data.", message),
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Status:        "200 OK",
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}, nil
+}
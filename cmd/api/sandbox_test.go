@@ -0,0 +1,65 @@
+// File: cmd/api/sandbox_test.go
+// Description: test suite for the sandbox environment's stand-in integrations
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/mailer"
+	"github.com/Pedro-J-Kukul/salesapi/internal/notify"
+)
+
+// TestSandboxIntegrations tests that emails and alerts are logged and the AI provider answers
+// without any request leaving the process
+func TestSandboxIntegrations(t *testing.T) {
+	logs := new(bytes.Buffer)
+	app := newTestApp()
+	app.logger = slog.New(slog.NewTextHandler(logs, nil))
+	app.config.env = "sandbox"
+	app.config.smtp.sender = "Sandbox <noreply@example.com>"
+	app.useSandboxIntegrations()
+
+	if !app.features()["sandbox"] || !app.features()["email"] || !app.features()["chatbot"] {
+		t.Fatalf("expected sandbox, email and chatbot features, got %v", app.features())
+	}
+
+	msg := mailer.EmailChangeEmail{FirstName: "Ana", CurrentEmail: "old@example.com", NewEmail: "new@example.com", Token: "abc123"}
+	if err := app.mailer.Send("new@example.com", mailer.DefaultLocale, msg); err != nil {
+		t.Fatalf("unexpected error sending email: %v", err)
+	}
+	if !strings.Contains(logs.String(), "email not sent") || !strings.Contains(logs.String(), "abc123") {
+		t.Errorf("expected the email and its token in the log, got %s", logs.String())
+	}
+
+	if err := app.notifier.Send(notify.Alert{Subject: "Database down", Body: "unreachable"}); err != nil {
+		t.Fatalf("unexpected error sending alert: %v", err)
+	}
+	if !strings.Contains(logs.String(), "Database down") {
+		t.Errorf("expected the alert in the log, got %s", logs.String())
+	}
+
+	body, _ := json.Marshal(data.GitHubChatRequest{Messages: []data.GitHubMessage{
+		{Role: "system", Content: "prompt"},
+		{Role: "user", Content: "What sold best today?"},
+	}})
+	resp, err := app.models.ChatbotModel.Client.Post("https://ai.invalid/chat/completions", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("unexpected error calling the AI provider: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response data.GitHubChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || len(response.Choices) != 1 || !strings.Contains(response.Choices[0].Message.Content, "What sold best today?") {
+		t.Errorf("expected a canned response quoting the message, got %d %+v", resp.StatusCode, response)
+	}
+}
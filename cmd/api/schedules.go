@@ -0,0 +1,314 @@
+// File: cmd/api/schedules.go
+// Description: the scheduler of background jobs and the admin handlers for managing its schedules
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// scheduledJobs are the jobs behind data.ScheduleJobs, by name.
+var scheduledJobs = map[string]func(app *app) error{
+	"cleanup":          (*app).cleanupExpired,
+	"low_stock_alerts": (*app).sendLowStockAlerts,
+}
+
+// runSchedules starts the jobs whose schedules have come due, checking on an interval until ctx is
+// cancelled. Checks are skipped while the database is unavailable.
+func (app *app) runSchedules(ctx context.Context) {
+	ticker := time.NewTicker(app.config.scheduler.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !app.readiness.ready() {
+			continue
+		}
+		schedules, err := app.models.Schedules.ClaimDue(app.now())
+		if err != nil {
+			app.logger.Error("failed to claim due schedules", slog.Any("error", err))
+			continue
+		}
+		for _, schedule := range schedules {
+			app.startScheduledJob(schedule)
+		}
+	}
+}
+
+// startScheduledJob runs the job of a schedule claimed as running in the background and records
+// how the run went.
+func (app *app) startScheduledJob(schedule *data.Schedule) {
+	app.background(func() {
+		logger := app.logger.With(slog.Int64("schedule_id", schedule.ID), slog.String("job", schedule.Job))
+		logger.Info("running scheduled job")
+
+		runErr := app.runJob(schedule.Job)
+		if runErr != nil {
+			logger.Error("scheduled job failed", slog.Any("error", runErr))
+		}
+		if err := app.models.Schedules.FinishRun(schedule.ID, schedule.LastRunAt.Time, runErr); err != nil {
+			logger.Error("failed to record the outcome of a scheduled job", slog.Any("error", err))
+		}
+	})
+}
+
+// runJob runs a job by name, turning a panic into an error so the run is still recorded as failed.
+func (app *app) runJob(name string) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+
+	job, ok := scheduledJobs[name]
+	if !ok {
+		return fmt.Errorf("unknown job %q", name)
+	}
+	return job(app)
+}
+
+// cleanupExpired deletes the tokens and bulk confirmations that have expired, auth events past
+// their 90 days, and the stored files of sale attachments that have been deleted.
+func (app *app) cleanupExpired() error {
+	tokens, err := app.models.Tokens.DeleteExpired()
+	if err != nil {
+		return err
+	}
+	confirmations, err := app.models.Confirmations.DeleteExpired()
+	if err != nil {
+		return err
+	}
+	authEvents, err := app.models.AuthEvents.DeleteExpired()
+	if err != nil {
+		return err
+	}
+
+	files, err := app.removeDeletedFiles()
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("cleaned up expired records", slog.Int64("tokens", tokens), slog.Int64("confirmations", confirmations), slog.Int64("auth_events", authEvents), slog.Int("files", files))
+	return nil
+}
+
+// sendLowStockAlerts emails the stock alert recipients about products below their reorder
+// threshold, as the interval check does.
+func (app *app) sendLowStockAlerts() error {
+	if app.mailer == nil || len(app.config.lowStock.recipients) == 0 {
+		return errors.New("low stock alerts need a mailer and at least one recipient")
+	}
+	return app.checkLowStock()
+}
+
+// listSchedulesHandler returns every schedule with the outcome of its last run and its next run.
+func (app *app) listSchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	schedules, err := app.modelsFor(r).Schedules.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"schedules": schedules, "jobs": data.ScheduleJobs}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// createScheduleHandler adds a schedule, enabled unless asked otherwise, in UTC unless a timezone
+// is given.
+func (app *app) createScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	// CreateSchedulePayload struct to hold the incoming JSON payload
+	var CreateSchedulePayload struct {
+		Name     string `json:"name"`
+		Job      string `json:"job"`
+		Cron     string `json:"cron"`
+		Timezone string `json:"timezone"`
+		Enabled  *bool  `json:"enabled"`
+	}
+
+	if err := app.readJSON(w, r, &CreateSchedulePayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	schedule := &data.Schedule{
+		Name:      CreateSchedulePayload.Name,
+		Job:       CreateSchedulePayload.Job,
+		Cron:      CreateSchedulePayload.Cron,
+		Timezone:  CreateSchedulePayload.Timezone,
+		Enabled:   CreateSchedulePayload.Enabled == nil || *CreateSchedulePayload.Enabled,
+		CreatedBy: &app.contextGetUser(r).ID,
+	}
+	if schedule.Timezone == "" {
+		schedule.Timezone = "UTC"
+	}
+
+	v := validator.New()
+	if data.ValidateSchedule(v, schedule); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Schedules.Insert(schedule); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateScheduleName):
+			v.AddError("name", "a schedule with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/schedules/%d", schedule.ID))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"schedule": schedule}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// updateScheduleHandler changes the fields of a schedule that are given, working out its next run
+// again. Disabling a schedule clears its next run.
+func (app *app) updateScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// UpdateSchedulePayload struct to hold the incoming JSON payload
+	var UpdateSchedulePayload struct {
+		Name     *string `json:"name"`
+		Job      *string `json:"job"`
+		Cron     *string `json:"cron"`
+		Timezone *string `json:"timezone"`
+		Enabled  *bool   `json:"enabled"`
+	}
+
+	if err := app.readJSON(w, r, &UpdateSchedulePayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	schedule, err := app.models.Schedules.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if UpdateSchedulePayload.Name != nil {
+		schedule.Name = *UpdateSchedulePayload.Name
+	}
+	if UpdateSchedulePayload.Job != nil {
+		schedule.Job = *UpdateSchedulePayload.Job
+	}
+	if UpdateSchedulePayload.Cron != nil {
+		schedule.Cron = *UpdateSchedulePayload.Cron
+	}
+	if UpdateSchedulePayload.Timezone != nil {
+		schedule.Timezone = *UpdateSchedulePayload.Timezone
+	}
+	if UpdateSchedulePayload.Enabled != nil {
+		schedule.Enabled = *UpdateSchedulePayload.Enabled
+	}
+	schedule.UpdatedBy = &app.contextGetUser(r).ID
+
+	v := validator.New()
+	if data.ValidateSchedule(v, schedule); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Schedules.Update(schedule); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateScheduleName):
+			v.AddError("name", "a schedule with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"schedule": schedule}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// deleteScheduleHandler removes a schedule.
+func (app *app) deleteScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.models.Schedules.Delete(id); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusNoContent, nil, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// runScheduleHandler starts the job of a schedule now, whether or not it is enabled, leaving its
+// next run as it was. The job runs in the background; its outcome shows on the schedule.
+func (app *app) runScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	schedule, err := app.models.Schedules.StartRun(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrScheduleRunning):
+			app.scheduleRunningResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.startScheduledJob(schedule)
+
+	if err := app.writeJSON(w, http.StatusAccepted, envelope{"schedule": schedule}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
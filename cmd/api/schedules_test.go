@@ -0,0 +1,38 @@
+// File: cmd/api/schedules_test.go
+// Description: test suite for the jobs the scheduler runs
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+)
+
+// TestScheduledJobs checks every job a schedule can name has something to run and nothing else does
+func TestScheduledJobs(t *testing.T) {
+	for _, name := range data.ScheduleJobs {
+		if _, ok := scheduledJobs[name]; !ok {
+			t.Errorf("job %q can be scheduled but has nothing to run", name)
+		}
+	}
+	if len(scheduledJobs) != len(data.ScheduleJobs) {
+		t.Errorf("expected %d jobs, got %d", len(data.ScheduleJobs), len(scheduledJobs))
+	}
+}
+
+// TestRunJobRecoversPanics checks a panicking job is reported as a failed run
+func TestRunJobRecoversPanics(t *testing.T) {
+	scheduledJobs["panics"] = func(*app) error { panic("boom") }
+	defer delete(scheduledJobs, "panics")
+
+	app := newTestApp()
+
+	if err := app.runJob("panics"); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected the panic as an error, got %v", err)
+	}
+	if err := app.runJob("missing"); err == nil {
+		t.Error("expected an error for an unknown job")
+	}
+}
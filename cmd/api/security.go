@@ -0,0 +1,112 @@
+// File: cmd/api/security.go
+// Description: security incident response handlers
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/mailer"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// Limits on a bulk revocation: the longest reason and the most users that can be listed.
+const (
+	maxRevokeReason = 500
+	maxRevokeUsers  = 1000
+)
+
+// revokeAllTokensHandler signs users out of every session after a suspected breach: everyone, the
+// users of one role, or the listed users. The admin making the request keeps their current session.
+// Every affected user is emailed and the revocation is written to the audit log.
+func (app *app) revokeAllTokensHandler(w http.ResponseWriter, r *http.Request) {
+	admin := app.contextGetUser(r)
+	// An impersonated session would sign users out in someone else's name
+	if admin.IsImpersonated() {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	// RevokeTokensPayload struct to hold the incoming JSON payload
+	var RevokeTokensPayload struct {
+		Role    string  `json:"role"`     // Optional - only revoke the sessions of this role's users
+		UserIDs []int64 `json:"user_ids"` // Optional - only revoke the sessions of these users
+		Reason  string  `json:"reason"`   // why the sessions were revoked, kept in the audit log
+	}
+
+	if err := app.readJSON(w, r, &RevokeTokensPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(RevokeTokensPayload.Reason != "", "reason", "must be provided")
+	v.Check(len(RevokeTokensPayload.Reason) <= maxRevokeReason, "reason", fmt.Sprintf("must not be more than %d bytes long", maxRevokeReason))
+	v.Check(RevokeTokensPayload.Role == "" || len(RevokeTokensPayload.UserIDs) == 0, "role", "must not be sent with user_ids")
+	v.Check(len(RevokeTokensPayload.UserIDs) <= maxRevokeUsers, "user_ids", fmt.Sprintf("must not contain more than %d ids", maxRevokeUsers))
+	for _, id := range RevokeTokensPayload.UserIDs {
+		if id < 1 {
+			v.AddError("user_ids", "must only contain positive integers")
+			break
+		}
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if RevokeTokensPayload.Role != "" {
+		if _, err := app.models.Roles.GetByName(RevokeTokensPayload.Role); err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddError("role", "must be an existing role")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	revoked, err := app.models.Tokens.RevokeAuthentication(RevokeTokensPayload.Role, RevokeTokensPayload.UserIDs, app.contextGetToken(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	sessions := int64(0)
+	userIDs := make([]int64, 0, len(revoked))
+	for _, user := range revoked {
+		sessions += user.Sessions
+		userIDs = append(userIDs, user.UserID)
+		app.sendEmail(&user.UserID, user.Email, user.Locale, mailer.SessionsRevokedEmail{
+			FirstName: user.FirstName,
+			Email:     user.Email,
+		})
+	}
+
+	entry := &data.AuditEntry{
+		UserID:    &admin.ID,
+		Action:    data.ActionRevokeTokens,
+		Entity:    "users",
+		EntityIDs: userIDs,
+		Details: map[string]any{
+			"reason":   RevokeTokensPayload.Reason,
+			"role":     RevokeTokensPayload.Role,
+			"user_ids": RevokeTokensPayload.UserIDs,
+			"sessions": sessions,
+		},
+	}
+	if err := app.models.Audit.Insert(entry); err != nil {
+		app.logger.Error("failed to write audit log", "action", entry.Action, "user_id", admin.ID, "error", err)
+	}
+	app.logger.Warn("authentication tokens revoked", "user_id", admin.ID, "users", len(revoked), "sessions", sessions, "reason", RevokeTokensPayload.Reason)
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"users": revoked, "sessions": sessions}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,53 @@
+// File: cmd/api/security_test.go
+// Description: test suite for the security incident response handlers
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+)
+
+// TestRevokeAllTokensValidation tests that bulk revocations need a reason and a single scope, and
+// are refused to impersonated sessions, before the database is used
+func TestRevokeAllTokensValidation(t *testing.T) {
+	app := newTestApp()
+	impersonator := int64(1)
+
+	tests := []struct {
+		name   string
+		user   *data.User
+		body   string
+		status int
+		field  string
+	}{
+		{"Missing Reason", &data.User{ID: 7}, `{}`, http.StatusUnprocessableEntity, "reason"},
+		{"Reason Too Long", &data.User{ID: 7}, `{"reason": "` + strings.Repeat("x", maxRevokeReason+1) + `"}`, http.StatusUnprocessableEntity, "reason"},
+		{"Role And Users", &data.User{ID: 7}, `{"reason": "leaked laptop", "role": "cashier", "user_ids": [2]}`, http.StatusUnprocessableEntity, "role"},
+		{"Non Positive User", &data.User{ID: 7}, `{"reason": "leaked laptop", "user_ids": [2, 0]}`, http.StatusUnprocessableEntity, "user_ids"},
+		{"Impersonated", &data.User{ID: 7, ImpersonatedBy: &impersonator}, `{"reason": "leaked laptop"}`, http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/admin/security/revoke-all-tokens", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req = app.contextSetUser(req, tt.user)
+			rr := httptest.NewRecorder()
+
+			app.revokeAllTokensHandler(rr, req)
+
+			if rr.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+			if tt.field != "" && !strings.Contains(rr.Body.String(), `"`+tt.field+`"`) {
+				t.Errorf("expected a %s error, got %s", tt.field, rr.Body.String())
+			}
+		})
+	}
+}
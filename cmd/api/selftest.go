@@ -0,0 +1,526 @@
+// File: cmd/api/selftest.go
+// Description: end-to-end smoke test run against a temporary schema, for deployment pipelines
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+)
+
+// selfTestStep is one stage of the smoke test. A step with a skip reason is reported without running.
+type selfTestStep struct {
+	name string
+	skip string
+	run  func() error
+}
+
+// selfTestResult is the outcome of one step.
+type selfTestResult struct {
+	name    string
+	status  string // PASS, FAIL or SKIP
+	detail  string
+	elapsed time.Duration
+}
+
+// runSelfTest migrates a fresh schema, drives the main user journey through the real routes and
+// prints a summary. It returns the process exit code, 1 when any step failed. The schema is
+// dropped afterwards, so the test can run against the production database before a rollout.
+func (app *app) runSelfTest(out io.Writer) int {
+	schema, err := selfTestSchemaName()
+	if err != nil {
+		fmt.Fprintln(out, "FAIL  setup:", err)
+		return 1
+	}
+
+	// The schema is created and dropped over the main pool, the steps use a pool confined to it
+	bootstrap := app.db
+	if _, err := bootstrap.Exec(fmt.Sprintf("CREATE SCHEMA %s", schema)); err != nil {
+		fmt.Fprintln(out, "FAIL  setup: creating schema:", err)
+		return 1
+	}
+	defer func() {
+		if _, err := bootstrap.Exec(fmt.Sprintf("DROP SCHEMA %s CASCADE", schema)); err != nil {
+			app.logger.Error("failed to drop self-test schema", "schema", schema, "error", err)
+		}
+	}()
+
+	cfg := app.config
+	cfg.db.dsn = withSearchPath(cfg.db.dsn, schema)
+	db, err := openDB(cfg)
+	if err != nil {
+		fmt.Fprintln(out, "FAIL  setup: connecting to schema:", err)
+		return 1
+	}
+	defer db.Close()
+
+	if err := applyMigrations(db, cfg.selftest.migrations); err != nil {
+		fmt.Fprintln(out, "FAIL  setup:", err)
+		return 1
+	}
+
+	// The journey must go through activation, and every request comes from the same client IP
+	app.db = db
+	app.models = data.NewModels(db, cfg.db.timeouts)
+	app.mailer = nil
+	app.config.env = "staging"
+	app.config.limiter.enabled = false
+	app.config.limiter.loginEnabled = false
+
+	if err := app.models.Permissions.Seed(); err != nil {
+		fmt.Fprintln(out, "FAIL  setup: seeding permissions:", err)
+		return 1
+	}
+
+	server := httptest.NewServer(app.routes())
+	defer server.Close()
+
+	st := &selfTest{app: app, client: server.Client(), baseURL: server.URL}
+	results := runSelfTestSteps(st.steps())
+	printSelfTestSummary(out, schema, results)
+
+	for _, result := range results {
+		if result.status == "FAIL" {
+			return 1
+		}
+	}
+	return 0
+}
+
+// runSelfTestSteps runs the steps in order. Each step builds on the previous ones, so after a
+// failure the rest are skipped.
+func runSelfTestSteps(steps []selfTestStep) []selfTestResult {
+	results := make([]selfTestResult, 0, len(steps))
+	failed := false
+	for _, step := range steps {
+		result := selfTestResult{name: step.name}
+		switch {
+		case failed:
+			result.status, result.detail = "SKIP", "an earlier step failed"
+		case step.skip != "":
+			result.status, result.detail = "SKIP", step.skip
+		default:
+			start := time.Now()
+			err := step.run()
+			result.elapsed = time.Since(start)
+			result.status = "PASS"
+			if err != nil {
+				result.status, result.detail = "FAIL", err.Error()
+				failed = true
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// printSelfTestSummary writes one line per step followed by the totals.
+func printSelfTestSummary(out io.Writer, schema string, results []selfTestResult) {
+	fmt.Fprintf(out, "Self-test against temporary schema %s\n", schema)
+	counts := make(map[string]int)
+	for _, result := range results {
+		counts[result.status]++
+		line := fmt.Sprintf("%-4s  %-16s", result.status, result.name)
+		if result.status == "PASS" {
+			line += " " + result.elapsed.Round(time.Millisecond).String()
+		}
+		if result.detail != "" {
+			line += " " + result.detail
+		}
+		fmt.Fprintln(out, strings.TrimRight(line, " "))
+	}
+
+	verdict := "PASSED"
+	if counts["FAIL"] > 0 {
+		verdict = "FAILED"
+	}
+	fmt.Fprintf(out, "Self-test %s: %d passed, %d failed, %d skipped\n", verdict, counts["PASS"], counts["FAIL"], counts["SKIP"])
+}
+
+// selfTest holds the state passed from one step to the next.
+type selfTest struct {
+	app     *app
+	client  *http.Client
+	baseURL string
+
+	email     string
+	password  string
+	userID    int64
+	token     string
+	productID int64
+	saleID    int64
+}
+
+// steps lists the journey a deployment must support.
+func (st *selfTest) steps() []selfTestStep {
+	return []selfTestStep{
+		{name: "register", run: st.register},
+		{name: "activate", run: st.activate},
+		{name: "login", run: st.login},
+		{name: "create product", run: st.createProduct},
+		{name: "create sale", run: st.createSale},
+		{name: "list sales", run: st.listSales},
+		{name: "report", run: st.report},
+		{name: "refund", run: st.refund},
+		{name: "export csv", run: st.exportCSV},
+		{name: "concurrent sales", run: st.concurrentSales},
+	}
+}
+
+// register signs up a new account through the public endpoint.
+func (st *selfTest) register() error {
+	st.email = fmt.Sprintf("selftest-%s@example.com", strings.ToLower(rand.Text()[:10]))
+	st.password = rand.Text() + "a!" // upper case and digits from the text, lower case and special added
+	body := map[string]string{"first_name": "Self", "last_name": "Test", "email": st.email, "password": st.password}
+
+	var resp struct {
+		User data.User `json:"user"`
+	}
+	if err := st.do(http.MethodPost, "/v1/users", body, http.StatusCreated, &resp); err != nil {
+		return err
+	}
+	if resp.User.IsActive {
+		return errors.New("new account is already active")
+	}
+	st.userID = resp.User.ID
+	return nil
+}
+
+// activate issues the token the welcome email would carry and redeems it. The account is then
+// promoted to admin, as an administrator would, so the remaining steps are permitted.
+func (st *selfTest) activate() error {
+	token, err := st.app.models.Tokens.New(st.userID, st.app.config.tokens.activationTTL, data.ScopeActivation)
+	if err != nil {
+		return err
+	}
+	if err := st.do(http.MethodPut, "/v1/users/activate", map[string]string{"token": token.Plaintext}, http.StatusOK, nil); err != nil {
+		return err
+	}
+
+	user, err := st.app.models.Users.GetByID(st.userID)
+	if err != nil {
+		return err
+	}
+	if !user.IsActive {
+		return errors.New("account is still inactive after activation")
+	}
+	role, err := st.app.models.Roles.GetByName(data.AdminRole)
+	if err != nil {
+		return fmt.Errorf("looking up admin role: %w", err)
+	}
+	user.Role = role.Name
+	if err := st.app.models.Users.Update(user); err != nil {
+		return fmt.Errorf("promoting account: %w", err)
+	}
+	return st.app.models.Permissions.AssignPermissions(user.ID, role.Permissions)
+}
+
+// login exchanges the credentials for an authentication token.
+func (st *selfTest) login() error {
+	var resp struct {
+		Token string `json:"authentication_token"`
+	}
+	body := map[string]string{"email": st.email, "password": st.password}
+	if err := st.do(http.MethodPost, "/v1/tokens/authentication", body, http.StatusCreated, &resp); err != nil {
+		return err
+	}
+	if resp.Token == "" {
+		return errors.New("response has no authentication token")
+	}
+	st.token = resp.Token
+	return nil
+}
+
+// createProduct adds the product the sale is recorded against.
+func (st *selfTest) createProduct() error {
+	var resp struct {
+		Product data.Product `json:"product"`
+	}
+	body := map[string]any{"name": "Self-test " + rand.Text()[:8], "price": 9.99}
+	if err := st.do(http.MethodPost, "/v1/products", body, http.StatusCreated, &resp); err != nil {
+		return err
+	}
+	st.productID = resp.Product.ID
+	return nil
+}
+
+// createSale records a sale of the product by the logged in account.
+func (st *selfTest) createSale() error {
+	var resp struct {
+		Sale data.Sale `json:"sale"`
+	}
+	body := map[string]any{"user_id": st.userID, "items": []map[string]int64{{"product_id": st.productID, "quantity": 2}}}
+	if err := st.do(http.MethodPost, "/v1/sales", body, http.StatusCreated, &resp); err != nil {
+		return err
+	}
+	st.saleID = resp.Sale.ID
+	return nil
+}
+
+// listSales reads the sale back through the filtered listing.
+func (st *selfTest) listSales() error {
+	var resp struct {
+		Sales []data.Sale `json:"sales"`
+	}
+	path := fmt.Sprintf("/v1/sales?product_id=%d", st.productID)
+	if err := st.do(http.MethodGet, path, nil, http.StatusOK, &resp); err != nil {
+		return err
+	}
+	if len(resp.Sales) != 1 || len(resp.Sales[0].Items) != 1 || resp.Sales[0].Quantity != 2 {
+		return fmt.Errorf("expected the one sale of one item with quantity 2, got %d sales", len(resp.Sales))
+	}
+	if sale := resp.Sales[0]; sale.Items[0].UnitPrice != 9.99 || sale.Total != 19.98 {
+		return fmt.Errorf("expected 2 units at 9.99 totalling 19.98, got %.2f totalling %.2f", sale.Items[0].UnitPrice, sale.Total)
+	}
+	return nil
+}
+
+// report checks that the sale shows up in the heatmap of the last four weeks.
+func (st *selfTest) report() error {
+	var resp struct {
+		Heatmap data.SalesHeatmap `json:"heatmap"`
+	}
+	if err := st.do(http.MethodGet, "/v1/reports/sales/heatmap", nil, http.StatusOK, &resp); err != nil {
+		return err
+	}
+	if resp.Heatmap.TotalCount != 1 || resp.Heatmap.TotalRevenue != 19.98 {
+		return fmt.Errorf("expected 1 sale worth 19.98, got %d worth %.2f", resp.Heatmap.TotalCount, resp.Heatmap.TotalRevenue)
+	}
+	return nil
+}
+
+// refund gives back one of the two units sold and checks the heatmap nets it off the revenue.
+func (st *selfTest) refund() error {
+	var resp struct {
+		Refund data.Refund `json:"refund"`
+	}
+	body := map[string]any{"reason": "self-test", "items": []map[string]int64{{"product_id": st.productID, "quantity": 1}}}
+	if err := st.do(http.MethodPost, fmt.Sprintf("/v1/sales/%d/refund", st.saleID), body, http.StatusCreated, &resp); err != nil {
+		return err
+	}
+	if resp.Refund.Total != 9.99 {
+		return fmt.Errorf("expected a refund of 9.99, got %.2f", resp.Refund.Total)
+	}
+
+	var report struct {
+		Heatmap data.SalesHeatmap `json:"heatmap"`
+	}
+	if err := st.do(http.MethodGet, "/v1/reports/sales/heatmap", nil, http.StatusOK, &report); err != nil {
+		return err
+	}
+	if report.Heatmap.TotalCount != 1 || report.Heatmap.TotalRevenue != 9.99 {
+		return fmt.Errorf("expected 1 sale worth 9.99 after the refund, got %d worth %.2f", report.Heatmap.TotalCount, report.Heatmap.TotalRevenue)
+	}
+	return nil
+}
+
+// exportCSV downloads the sales export and looks for the sale's item in it, and for the refunded
+// unit as a negative line, as the reports count it.
+func (st *selfTest) exportCSV() error {
+	var body []byte
+	if err := st.do(http.MethodGet, "/v1/reports/sales/export.csv", nil, http.StatusOK, &body); err != nil {
+		return err
+	}
+	records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	if err != nil {
+		return fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return errors.New("expected a header row, got an empty file")
+	}
+
+	saleID, productID := strconv.FormatInt(st.saleID, 10), strconv.FormatInt(st.productID, 10)
+	var sold, refunded bool
+	for _, record := range records[1:] {
+		if record[0] != saleID || record[7] != productID {
+			continue
+		}
+		switch {
+		case record[18] == "":
+			if record[9] != "2" || record[11] != "19.98" {
+				return fmt.Errorf("expected 2 units totalling 19.98, got %s totalling %s", record[9], record[11])
+			}
+			sold = true
+		default:
+			if record[9] != "-1" || record[11] != "-9.99" {
+				return fmt.Errorf("expected a refund of -1 unit totalling -9.99, got %s totalling %s", record[9], record[11])
+			}
+			refunded = true
+		}
+	}
+	if !sold || !refunded {
+		return fmt.Errorf("expected the sale's item and its refund in %d exported rows", len(records)-1)
+	}
+	return nil
+}
+
+// concurrentSales stocks the product with a few units and has more clients than that buy one
+// each at the same time. Exactly the stocked units must sell, the rest must be refused for lack
+// of stock and the product must end with none left.
+func (st *selfTest) concurrentSales() error {
+	const stocked, buyers = 3, 10
+
+	stock := map[string]any{"quantity": stocked, "note": "self-test"}
+	if err := st.do(http.MethodPost, fmt.Sprintf("/v1/products/%d/stock", st.productID), stock, http.StatusCreated, nil); err != nil {
+		return err
+	}
+
+	sale := map[string]any{"user_id": st.userID, "items": []map[string]int64{{"product_id": st.productID, "quantity": 1}}}
+	statuses := make([]int, buyers)
+	errs := make([]error, buyers)
+	var wg sync.WaitGroup
+	for i := range buyers {
+		wg.Go(func() {
+			resp, err := st.send(http.MethodPost, "/v1/sales", sale)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			resp.Body.Close()
+			statuses[i] = resp.StatusCode
+		})
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	var sold, refused int
+	for _, status := range statuses {
+		switch status {
+		case http.StatusCreated:
+			sold++
+		case http.StatusConflict:
+			refused++
+		default:
+			return fmt.Errorf("POST /v1/sales: expected %d or %d, got %d", http.StatusCreated, http.StatusConflict, status)
+		}
+	}
+	if sold != stocked || refused != buyers-stocked {
+		return fmt.Errorf("expected %d of %d sales to go through, got %d", stocked, buyers, sold)
+	}
+
+	var resp struct {
+		Product data.Product `json:"product"`
+	}
+	if err := st.do(http.MethodGet, fmt.Sprintf("/v1/products/%d", st.productID), nil, http.StatusOK, &resp); err != nil {
+		return err
+	}
+	if resp.Product.StockQuantity == nil || *resp.Product.StockQuantity != 0 {
+		return fmt.Errorf("expected no stock left, got %v", resp.Product.StockQuantity)
+	}
+	return nil
+}
+
+// do sends a JSON request with the session token and decodes the response into dest when it is
+// not nil, or copies the body as is into a *[]byte. Any status other than want is an error
+// holding the start of the response body.
+func (st *selfTest) do(method, path string, body any, want int, dest any) error {
+	resp, err := st.send(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("%s %s: expected %d, got %d %s", method, path, want, resp.StatusCode, strings.Join(strings.Fields(string(snippet)), " "))
+	}
+	if raw, ok := dest.(*[]byte); ok {
+		*raw, err = io.ReadAll(resp.Body)
+		return err
+	}
+	if dest != nil {
+		return json.NewDecoder(resp.Body).Decode(dest)
+	}
+	return nil
+}
+
+// send sends a JSON request with the session token and returns the response as is. It is safe
+// to call from several goroutines at once.
+func (st *selfTest) send(method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, st.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if st.token != "" {
+		req.Header.Set("Authorization", "Bearer "+st.token)
+	}
+	return st.client.Do(req)
+}
+
+// selfTestSchemaName returns a random schema name, so parallel runs do not collide.
+func selfTestSchemaName() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "selftest_" + hex.EncodeToString(b), nil
+}
+
+// withSearchPath points every connection of a DSN at the given schema. lib/pq passes unknown
+// settings to the server as run-time parameters, in both URL and key=value DSNs.
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err == nil {
+			q := u.Query()
+			q.Set("search_path", schema)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return strings.TrimSpace(dsn) + " search_path=" + schema
+}
+
+// applyMigrations runs every up migration of dir in order.
+func applyMigrations(db *sql.DB, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no migrations found in %s", dir)
+	}
+	slices.Sort(files)
+
+	for _, file := range files {
+		script, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec(string(script)); err != nil {
+			return fmt.Errorf("migration %s: %w", filepath.Base(file), err)
+		}
+	}
+	return nil
+}
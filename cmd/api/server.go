@@ -6,9 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 )
@@ -26,21 +28,76 @@ func (app *app) serve() error {
 		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError), // custom error logger
 	}
 
+	// Serve metrics, pprof and health checks on their own port, away from the public API. The
+	// port is bound before starting so a clash stops startup rather than going unnoticed.
+	var adminSrv *http.Server
+	if app.config.adminPort > 0 {
+		adminSrv = &http.Server{
+			Addr:         net.JoinHostPort(app.config.adminAddr, strconv.Itoa(app.config.adminPort)),
+			Handler:      app.adminRoutes(),
+			IdleTimeout:  1 * time.Minute,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 2 * time.Minute, // long enough for CPU profiles and traces
+			ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+		}
+		listener, err := net.Listen("tcp", adminSrv.Addr)
+		if err != nil {
+			return fmt.Errorf("admin listener: %w", err)
+		}
+		go func() {
+			app.logger.Info("starting admin server", slog.String("addr", adminSrv.Addr))
+			if err := adminSrv.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+				app.logger.Error("admin server stopped", "error", err)
+			}
+		}()
+	}
+
 	shutdown := make(chan error) // channel for shutdown errors
 
+	// Keep pinging the database so requests are rejected quickly while it is down
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	defer stopMonitor()
+	if app.db != nil {
+		go app.monitorDatabase(monitorCtx)
+	}
+
+	// Email the stock alert recipients when products fall below their reorder threshold
+	if app.db != nil && app.lowStockAlertsEnabled() {
+		go app.monitorLowStock(monitorCtx)
+	}
+
+	// Run background jobs as their schedules come due
+	if app.db != nil && app.config.scheduler.interval > 0 {
+		go app.runSchedules(monitorCtx)
+	}
+
+	// Forget idle rate limiter clients until the server stops
+	app.limiters.Start(limiterSweepInterval)
+	defer app.limiters.Stop()
+
 	// Start a goroutine to listen for shutdown signals
 	go func() {
 		quit := make(chan os.Signal, 1)                                              // channel for OS signals
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)                         // listen for interrupt and terminate signals
 		sig := <-quit                                                                // block until a signal is received
 		app.logger.Info("shutting down server", slog.String("signal", sig.String())) // log the shutdown signal
+		stopMonitor()                                                                // stop the readiness pings
 
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second) // context with timeout for shutdown
 		defer cancel()                                                           // ensure the context is cancelled to free resources
 
 		err := srv.Shutdown(ctx) // attempt to gracefully shutdown the server
+
+		// The admin server goes last, so the drain can still be watched through its metrics
+		if adminSrv != nil {
+			if err := adminSrv.Shutdown(ctx); err != nil {
+				app.logger.Error("failed to shut down admin server", "error", err)
+			}
+		}
+
 		if err != nil {
-			shutdown <- err // send any shutdown error to the channel
+			shutdown <- err // send the shutdown error, serve reads only one result
+			return
 		}
 
 		app.logger.Info("completing background tasks") // log completion of background tasks
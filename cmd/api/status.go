@@ -0,0 +1,357 @@
+// File: cmd/api/status.go
+// Description: public status endpoint and the admin handlers for the incident notes it shows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+const (
+	statusCacheTTL       = 30 * time.Second   // how long a status report is served before it is rebuilt
+	statusEmailWindow    = time.Hour          // window of sends the email component is judged on
+	statusIncidentWindow = 7 * 24 * time.Hour // how long resolved incidents stay on the status page
+)
+
+// Component and overall states reported by the status endpoint.
+const (
+	statusOperational   = "operational"
+	statusDegraded      = "degraded"
+	statusMajorOutage   = "major_outage"
+	statusNotConfigured = "not_configured"
+	statusUnknown       = "unknown"
+)
+
+// statusComponent is the health of one part of the service.
+type statusComponent struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// statusReport is the body of the public status endpoint.
+type statusReport struct {
+	Status     string            `json:"status"`
+	Components []statusComponent `json:"components"`
+	Incidents  []*data.Incident  `json:"incidents"`
+	UpdatedAt  data.Timestamp    `json:"updated_at"`
+}
+
+// statusCache holds the last status report so polling clients cannot load the database, and the
+// last incidents read so they are still shown while the database is down. The zero value is ready to use.
+type statusCache struct {
+	mu        sync.Mutex
+	report    *statusReport
+	expires   time.Time
+	incidents []*data.Incident
+}
+
+// get returns the cached report if it has not expired.
+func (c *statusCache) get(now time.Time) (*statusReport, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.report == nil || !now.Before(c.expires) {
+		return nil, false
+	}
+	return c.report, true
+}
+
+// set caches a report until the TTL passes.
+func (c *statusCache) set(report *statusReport, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.report = report
+	c.expires = now.Add(statusCacheTTL)
+}
+
+// invalidate drops the cached report so the next request sees a changed incident.
+func (c *statusCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.report = nil
+}
+
+// lastIncidents returns the incidents from the last successful read, remembering incidents when
+// they are given.
+func (c *statusCache) lastIncidents(incidents []*data.Incident) []*data.Incident {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if incidents != nil {
+		c.incidents = incidents
+	}
+	if c.incidents == nil {
+		return []*data.Incident{}
+	}
+	return c.incidents
+}
+
+// overallStatus rolls the component states up into one. The service is down when the API or the
+// database is, and degraded when any other component is.
+func overallStatus(components []statusComponent) string {
+	overall := statusOperational
+	for _, component := range components {
+		switch {
+		case component.Status == statusMajorOutage && (component.Name == "api" || component.Name == "database"):
+			return statusMajorOutage
+		case component.Status == statusMajorOutage || component.Status == statusDegraded:
+			overall = statusDegraded
+		}
+	}
+	return overall
+}
+
+// buildStatusReport checks every component and reads the recent incidents.
+func (app *app) buildStatusReport(now time.Time) *statusReport {
+	dbReady := app.readiness.ready()
+
+	components := []statusComponent{{Name: "api", Status: statusOperational}}
+	if dbReady {
+		components = append(components, statusComponent{Name: "database", Status: statusOperational})
+	} else {
+		components = append(components, statusComponent{Name: "database", Status: statusMajorOutage})
+	}
+	components = append(components,
+		statusComponent{Name: "email", Status: app.emailStatus(dbReady)},
+		statusComponent{Name: "ai_provider", Status: app.aiProviderStatus()},
+	)
+
+	var incidents []*data.Incident
+	if dbReady {
+		recent, err := app.models.Incidents.GetRecent(statusIncidentWindow)
+		if err != nil {
+			app.logger.Error("failed to read incidents for the status page", slog.Any("error", err))
+		} else {
+			incidents = recent
+		}
+	}
+
+	return &statusReport{
+		Status:     overallStatus(components),
+		Components: components,
+		Incidents:  app.statuses.lastIncidents(incidents),
+		UpdatedAt:  data.NewTimestamp(now),
+	}
+}
+
+// emailStatus reports mail as degraded when every send in the last hour was refused.
+func (app *app) emailStatus(dbReady bool) string {
+	if app.mailer == nil {
+		return statusNotConfigured
+	}
+	if !dbReady {
+		return statusUnknown
+	}
+
+	sent, failed, err := app.models.Emails.SendResults(statusEmailWindow)
+	if err != nil {
+		app.logger.Error("failed to read email results for the status page", slog.Any("error", err))
+		return statusUnknown
+	}
+	if failed > 0 && sent == 0 {
+		return statusDegraded
+	}
+	return statusOperational
+}
+
+// aiProviderStatus reports whether the chatbot can reach an AI provider at all.
+func (app *app) aiProviderStatus() string {
+	if !app.models.ChatbotModel.Configured() {
+		return statusNotConfigured
+	}
+	return statusOperational
+}
+
+// statusHandler returns the health of each component and recent incident notes. It needs no
+// authentication and serves a cached report so it can be polled by status pages.
+func (app *app) statusHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	report, found := app.statuses.get(now)
+	if !found {
+		report = app.buildStatusReport(now)
+		app.statuses.set(report, now)
+	}
+
+	headers := make(http.Header)
+	headers.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(statusCacheTTL.Seconds())))
+	// Status pages on other domains fetch this, so any origin may read it
+	if w.Header().Get("Access-Control-Allow-Origin") == "" {
+		headers.Set("Access-Control-Allow-Origin", "*")
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"status": report}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// listIncidentsHandler returns every incident note, newest first.
+func (app *app) listIncidentsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	filter := app.readFilters(r.URL.Query(), "incidents", "-created_at", []string{"-created_at"}, v)
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	incidents, metadata, err := app.models.Incidents.GetAll(filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"incidents": incidents, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// createIncidentHandler posts an incident note to the status page.
+func (app *app) createIncidentHandler(w http.ResponseWriter, r *http.Request) {
+	// CreateIncidentPayload struct to hold the incoming JSON payload
+	var CreateIncidentPayload struct {
+		Title   string `json:"title"`
+		Message string `json:"message"`
+		Impact  string `json:"impact"`
+		Status  string `json:"status"`
+	}
+
+	if err := app.readJSON(w, r, &CreateIncidentPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	incident := &data.Incident{
+		Title:     CreateIncidentPayload.Title,
+		Message:   CreateIncidentPayload.Message,
+		Impact:    CreateIncidentPayload.Impact,
+		Status:    CreateIncidentPayload.Status,
+		CreatedBy: &app.contextGetUser(r).ID,
+	}
+	if incident.Status == "" {
+		incident.Status = "investigating"
+	}
+
+	v := validator.New()
+	if data.ValidateIncident(v, incident); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Incidents.Insert(incident); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.statuses.invalidate()
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/admin/incidents/%d", incident.ID))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"incident": incident}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// updateIncidentHandler edits an incident note, typically to move it on to resolved.
+func (app *app) updateIncidentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// UpdateIncidentPayload struct to hold the incoming JSON payload
+	var UpdateIncidentPayload struct {
+		Title   *string `json:"title"`
+		Message *string `json:"message"`
+		Impact  *string `json:"impact"`
+		Status  *string `json:"status"`
+	}
+
+	if err := app.readJSON(w, r, &UpdateIncidentPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	incident, err := app.models.Incidents.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if UpdateIncidentPayload.Title != nil {
+		incident.Title = *UpdateIncidentPayload.Title
+	}
+	if UpdateIncidentPayload.Message != nil {
+		incident.Message = *UpdateIncidentPayload.Message
+	}
+	if UpdateIncidentPayload.Impact != nil {
+		incident.Impact = *UpdateIncidentPayload.Impact
+	}
+	if UpdateIncidentPayload.Status != nil {
+		incident.Status = *UpdateIncidentPayload.Status
+	}
+
+	v := validator.New()
+	if data.ValidateIncident(v, incident); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Incidents.Update(incident); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.statuses.invalidate()
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"incident": incident}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// deleteIncidentHandler removes an incident note posted by mistake.
+func (app *app) deleteIncidentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.models.Incidents.Delete(id); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.statuses.invalidate()
+
+	if err := app.writeJSON(w, http.StatusNoContent, nil, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
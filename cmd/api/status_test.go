@@ -0,0 +1,130 @@
+// File: cmd/api/status_test.go
+// Description: test suite for the public status endpoint and incident handlers
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/julienschmidt/httprouter"
+)
+
+// TestOverallStatus tests how component states roll up into the overall status
+func TestOverallStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		components []statusComponent
+		expected   string
+	}{
+		{"All Operational", []statusComponent{{"api", statusOperational}, {"database", statusOperational}}, statusOperational},
+		{"Optional Not Configured", []statusComponent{{"database", statusOperational}, {"email", statusNotConfigured}}, statusOperational},
+		{"Email Degraded", []statusComponent{{"database", statusOperational}, {"email", statusDegraded}}, statusDegraded},
+		{"Database Down", []statusComponent{{"database", statusMajorOutage}, {"email", statusUnknown}}, statusMajorOutage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := overallStatus(tt.components); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestStatusCache tests expiry, invalidation and the fallback to the last known incidents
+func TestStatusCache(t *testing.T) {
+	now := time.Now()
+	var cache statusCache
+
+	if _, found := cache.get(now); found {
+		t.Fatal("expected empty cache to miss")
+	}
+
+	cache.set(&statusReport{Status: statusOperational}, now)
+	if _, found := cache.get(now.Add(statusCacheTTL - time.Second)); !found {
+		t.Error("expected cached report before expiry")
+	}
+	if _, found := cache.get(now.Add(statusCacheTTL)); found {
+		t.Error("expected cached report to expire after the TTL")
+	}
+
+	cache.invalidate()
+	if _, found := cache.get(now); found {
+		t.Error("expected invalidated cache to miss")
+	}
+
+	if got := cache.lastIncidents(nil); got == nil || len(got) != 0 {
+		t.Errorf("expected an empty list before any read, got %v", got)
+	}
+	cache.lastIncidents([]*data.Incident{{ID: 1}})
+	if got := cache.lastIncidents(nil); len(got) != 1 {
+		t.Errorf("expected the last read incidents while the database is down, got %v", got)
+	}
+}
+
+// TestStatusHandlerServesCache tests that a cached report is served with public caching headers
+func TestStatusHandlerServesCache(t *testing.T) {
+	app := newTestApp()
+	app.statuses.set(&statusReport{Status: statusDegraded, Incidents: []*data.Incident{}}, time.Now())
+
+	rr := httptest.NewRecorder()
+	app.statusHandler(rr, httptest.NewRequest(http.MethodGet, "/v1/status", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `"status": "degraded"`) {
+		t.Errorf("expected the cached report, got %s", rr.Body.String())
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "public, max-age=30" {
+		t.Errorf("expected public caching, got %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected any origin to be allowed, got %q", got)
+	}
+}
+
+// TestIncidentHandlersValidation tests that bad incidents are rejected before the database is used
+func TestIncidentHandlersValidation(t *testing.T) {
+	app := newTestApp()
+
+	tests := []struct {
+		name           string
+		handler        http.HandlerFunc
+		method         string
+		id             string
+		body           string
+		expectedStatus int
+	}{
+		{"Create Missing Title", app.createIncidentHandler, http.MethodPost, "", `{"impact": "minor"}`, http.StatusUnprocessableEntity},
+		{"Create Unknown Impact", app.createIncidentHandler, http.MethodPost, "", `{"title": "Slow reports", "impact": "severe"}`, http.StatusUnprocessableEntity},
+		{"Create Unknown Status", app.createIncidentHandler, http.MethodPost, "", `{"title": "Slow reports", "impact": "minor", "status": "fixed"}`, http.StatusUnprocessableEntity},
+		{"Create Unknown Field", app.createIncidentHandler, http.MethodPost, "", `{"title": "Slow reports", "impact": "minor", "component": "db"}`, http.StatusBadRequest},
+		{"Update Invalid ID", app.updateIncidentHandler, http.MethodPut, "abc", `{"status": "resolved"}`, http.StatusNotFound},
+		{"Update Bad JSON", app.updateIncidentHandler, http.MethodPut, "3", `{"status": `, http.StatusBadRequest},
+		{"Delete Invalid ID", app.deleteIncidentHandler, http.MethodDelete, "0", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/v1/admin/incidents", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			params := httprouter.Params{{Key: "id", Value: tt.id}}
+			req = req.WithContext(context.WithValue(req.Context(), httprouter.ParamsKey, params))
+			req = app.contextSetUser(req, &data.User{ID: 7})
+			rr := httptest.NewRecorder()
+
+			tt.handler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// File: cmd/api/stock.go
+// Description: handlers for receiving and adjusting product stock
+
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// recordStockHandler adds received stock to a product, or corrects its level after a count.
+func (app *app) recordStockHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// RecordStockPayload struct to hold the incoming JSON payload
+	var RecordStockPayload struct {
+		Quantity int64  `json:"quantity"`
+		Reason   string `json:"reason,omitempty"` // Optional - will default to receipt
+		Note     string `json:"note,omitempty"`
+	}
+
+	if err := app.readJSON(w, r, &RecordStockPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if RecordStockPayload.Reason == "" {
+		RecordStockPayload.Reason = data.StockReceipt
+	}
+	movement := &data.StockMovement{
+		ProductID: id,
+		Quantity:  RecordStockPayload.Quantity,
+		Reason:    RecordStockPayload.Reason,
+		Note:      RecordStockPayload.Note,
+		CreatedBy: &app.contextGetUser(r).ID,
+	}
+
+	v := validator.New()
+	if data.ValidateStockMovement(v, movement); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	stock, err := app.models.Stock.Record(movement)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrInsufficientStock):
+			app.insufficientStockResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"stock_movement": movement, "stock_quantity": stock}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// listStockMovementsHandler returns the stock history of a product, newest first.
+func (app *app) listStockMovementsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	filter := app.readFilters(r.URL.Query(), "stock_movements", "-created_at", []string{"-created_at"}, v)
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	product, err := app.models.Products.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	movements, metadata, err := app.models.Stock.GetAllForProduct(id, filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"stock_quantity": product.StockQuantity, "stock_movements": movements, "metadata": metadata}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,52 @@
+// File: cmd/api/stock_test.go
+// Description: test suite for stock handlers - validation focused
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// TestStockMovementValidation tests stock receipt and adjustment validation logic
+func TestStockMovementValidation(t *testing.T) {
+	tests := []struct {
+		name          string
+		quantity      int64
+		reason        string
+		note          string
+		expectedValid bool
+		errorField    string
+	}{
+		{name: "Valid Receipt", quantity: 24, reason: data.StockReceipt, note: "Delivery 1142", expectedValid: true},
+		{name: "Negative Adjustment", quantity: -3, reason: data.StockAdjustment, note: "Broken in storage", expectedValid: true},
+		{name: "Positive Adjustment", quantity: 2, reason: data.StockAdjustment, expectedValid: true},
+		{name: "Negative Receipt", quantity: -5, reason: data.StockReceipt, expectedValid: false, errorField: "quantity"},
+		{name: "Zero Quantity", quantity: 0, reason: data.StockAdjustment, expectedValid: false, errorField: "quantity"},
+		{name: "Huge Quantity", quantity: 1_000_001, reason: data.StockReceipt, expectedValid: false, errorField: "quantity"},
+		{name: "Sale Reason", quantity: -1, reason: data.StockSale, expectedValid: false, errorField: "reason"},
+		{name: "Unknown Reason", quantity: 1, reason: "theft", expectedValid: false, errorField: "reason"},
+		{name: "Note Too Long", quantity: 1, reason: data.StockReceipt, note: strings.Repeat("a", 501), expectedValid: false, errorField: "note"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movement := &data.StockMovement{ProductID: 1, Quantity: tt.quantity, Reason: tt.reason, Note: tt.note}
+
+			v := validator.New()
+			data.ValidateStockMovement(v, movement)
+
+			if v.IsValid() != tt.expectedValid {
+				t.Errorf("expected valid=%v, got=%v. Errors: %v", tt.expectedValid, v.IsValid(), v.Errors)
+			}
+			if !tt.expectedValid && tt.errorField != "" {
+				if _, hasError := v.Errors[tt.errorField]; !hasError {
+					t.Errorf("expected error on field '%s', but got errors: %v", tt.errorField, v.Errors)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// File: cmd/api/suggest.go
+// Description: search-as-you-type product suggestions with a short lived result cache
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+const (
+	suggestionCacheTTL     = 30 * time.Second // how long a cached result is served
+	suggestionCacheMaxSize = 1000             // entries kept before the cache is reset
+	suggestionDefaultLimit = 10               // results returned when no limit is given
+	suggestionMaxLimit     = 25               // largest limit a client may ask for
+)
+
+// suggestionCache holds recent suggestion results keyed by normalized query and limit.
+// The zero value is ready to use.
+type suggestionCache struct {
+	mu      sync.Mutex
+	entries map[string]suggestionCacheEntry
+}
+
+// suggestionCacheEntry is a cached result and the time it stops being served.
+type suggestionCacheEntry struct {
+	suggestions []*data.ProductSuggestion
+	expires     time.Time
+}
+
+// get returns the cached result for key if it has not expired.
+func (c *suggestionCache) get(key string, now time.Time) ([]*data.ProductSuggestion, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, found := c.entries[key]
+	if !found || !now.Before(entry.expires) {
+		return nil, false
+	}
+	return entry.suggestions, true
+}
+
+// set stores a result for key. When the cache is full it is emptied rather than tracking
+// recency, since entries only live for a few seconds anyway.
+func (c *suggestionCache) set(key string, suggestions []*data.ProductSuggestion, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil || len(c.entries) >= suggestionCacheMaxSize {
+		c.entries = make(map[string]suggestionCacheEntry)
+	}
+	c.entries[key] = suggestionCacheEntry{suggestions: suggestions, expires: now.Add(suggestionCacheTTL)}
+}
+
+// suggestProductsHandler returns active products whose name starts with ?q=, for the POS search box.
+func (app *app) suggestProductsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	v := validator.New()
+
+	q := strings.TrimSpace(app.getSingleQueryParameter(query, "q", ""))
+	limit := app.getSingleIntQueryParameter(query, "limit", suggestionDefaultLimit, v)
+
+	v.Check(q != "", "q", "must be provided")
+	v.Check(len(q) <= 100, "q", "must not be more than 100 bytes long")
+	v.Check(limit >= 1 && limit <= suggestionMaxLimit, "limit", fmt.Sprintf("must be between 1 and %d", suggestionMaxLimit))
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	now := time.Now()
+	key := fmt.Sprintf("%d:%s", limit, strings.ToLower(q))
+
+	suggestions, found := app.suggestions.get(key, now)
+	if !found {
+		var err error
+		suggestions, err = app.models.Products.Suggest(q, int(limit))
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.suggestions.set(key, suggestions, now)
+	}
+
+	headers := make(http.Header)
+	headers.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(suggestionCacheTTL.Seconds())))
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,72 @@
+// File: cmd/api/suggest_test.go
+// Description: test suite for product suggestions - cache and rate limit focused
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+)
+
+// TestSuggestionCache tests expiry and reset of cached suggestion results
+func TestSuggestionCache(t *testing.T) {
+	now := time.Date(2025, 3, 14, 9, 30, 0, 0, time.UTC)
+	result := []*data.ProductSuggestion{{ID: 1, Name: "Coffee", Price: 2.5}}
+
+	var cache suggestionCache
+
+	if _, found := cache.get("10:cof", now); found {
+		t.Fatal("expected empty cache to miss")
+	}
+
+	cache.set("10:cof", result, now)
+
+	if got, found := cache.get("10:cof", now.Add(suggestionCacheTTL-time.Second)); !found || len(got) != 1 {
+		t.Errorf("expected cached result before expiry, got found=%v result=%v", found, got)
+	}
+	if _, found := cache.get("10:cof", now.Add(suggestionCacheTTL)); found {
+		t.Error("expected cached result to expire after the TTL")
+	}
+
+	for i := range suggestionCacheMaxSize {
+		cache.set(fmt.Sprintf("10:product %d", i), result, now)
+	}
+	if len(cache.entries) > suggestionCacheMaxSize {
+		t.Errorf("expected at most %d entries, got %d", suggestionCacheMaxSize, len(cache.entries))
+	}
+}
+
+// TestSuggestionsSkipGlobalRateLimit tests that suggestion requests are not counted by the global limiter
+func TestSuggestionsSkipGlobalRateLimit(t *testing.T) {
+	app := newTestApp()
+	app.config.limiter.enabled = true
+	app.config.limiter.rps = 1
+	app.config.limiter.burst = 1
+
+	handler := app.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := range 3 {
+		req := httptest.NewRequest(http.MethodGet, "/v1/suggest/products?q=cof", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status %d, got %d", i+1, http.StatusOK, rr.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/products", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected first list request to pass, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,169 @@
+// File: cmd/api/sync.go
+// Description: offline terminal sync handlers for sales
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// maxSyncBatchSize is the largest number of queued sales a terminal may push in one request.
+const maxSyncBatchSize = 100
+
+// syncAcknowledgement reports what happened to one queued sale pushed by a terminal.
+type syncAcknowledgement struct {
+	ClientUUID string            `json:"client_uuid"`
+	Status     string            `json:"status"` // created, duplicate or rejected
+	Sale       *data.Sale        `json:"sale,omitempty"`
+	Errors     map[string]string `json:"errors,omitempty"`
+	Warnings   []string          `json:"warnings,omitempty"` // such as a likely duplicate of another sale
+}
+
+// pushSalesSyncHandler accepts a batch of sales queued offline and acknowledges each one by client UUID.
+func (app *app) pushSalesSyncHandler(w http.ResponseWriter, r *http.Request) {
+	// SyncSalesPayload struct to hold the incoming JSON payload
+	var SyncSalesPayload struct {
+		Sales []struct {
+			ClientUUID string           `json:"client_uuid"`
+			UserID     int64            `json:"user_id"` // Optional - the seller, the authenticated user by default
+			Items      []*data.SaleItem `json:"items"`
+			ProductID  int64            `json:"product_id"` // Optional - a sale of one product, instead of items
+			Quantity   int64            `json:"quantity"`
+			SoldAt     *time.Time       `json:"sold_at"`
+			Note       string           `json:"note"`
+			Customer   string           `json:"customer_email"`
+		} `json:"sales"`
+	}
+
+	if err := app.readJSON(w, r, &SyncSalesPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate the batch as a whole
+	v := validator.New()
+	v.Check(len(SyncSalesPayload.Sales) > 0, "sales", "must contain at least one sale")
+	v.Check(len(SyncSalesPayload.Sales) <= maxSyncBatchSize, "sales", "must not contain more than 100 sales")
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Each sale is acknowledged individually so one bad row does not block the queue
+	user := app.contextGetUser(r)
+	var canAssign *bool // looked up on the first sale attributed to someone else
+	acks := make([]syncAcknowledgement, 0, len(SyncSalesPayload.Sales))
+	for _, item := range SyncSalesPayload.Sales {
+		clientUUID := item.ClientUUID
+		iv := validator.New()
+		if item.UserID == 0 {
+			item.UserID = user.ID
+		}
+		if item.UserID != user.ID && canAssign == nil {
+			allowed, err := app.canAssignSales(user)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			canAssign = &allowed
+		}
+		if item.UserID != user.ID && !*canAssign {
+			iv.AddError("user_id", "must be your own user id without the sale:assign permission")
+			acks = append(acks, syncAcknowledgement{ClientUUID: clientUUID, Status: "rejected", Errors: iv.Errors})
+			continue
+		}
+		sale := &data.Sale{
+			ClientUUID:    &clientUUID,
+			UserID:        item.UserID,
+			Items:         saleItems(iv, item.Items, item.ProductID, item.Quantity),
+			Note:          item.Note,
+			CreatedBy:     &user.ID,
+			CustomerEmail: item.Customer,
+		}
+		if item.SoldAt != nil {
+			sale.SoldAt = data.NewTimestamp(*item.SoldAt)
+		}
+
+		if data.ValidateSyncedSale(iv, sale, app.now()); !iv.IsValid() {
+			acks = append(acks, syncAcknowledgement{ClientUUID: clientUUID, Status: "rejected", Errors: iv.Errors})
+			continue
+		}
+
+		created, err := app.models.Sales.InsertSynced(sale)
+		if errors.Is(err, data.ErrMixedCurrencies) {
+			acks = append(acks, syncAcknowledgement{ClientUUID: clientUUID, Status: "rejected", Errors: mixedCurrenciesErrors})
+			continue
+		}
+		if errs := unknownReferenceErrors(err); errs != nil {
+			acks = append(acks, syncAcknowledgement{ClientUUID: clientUUID, Status: "rejected", Errors: errs})
+			continue
+		}
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		ack := syncAcknowledgement{ClientUUID: clientUUID, Status: "created", Sale: sale}
+		if !created {
+			ack.Status = "duplicate"
+		} else {
+			app.emailReceipt(sale, user.Location())
+			ack.Warnings = app.duplicateSaleWarnings(r, sale)
+		}
+		acks = append(acks, ack)
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"acknowledgements": acks}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// pullSalesSyncHandler returns sales changed or deleted on the server since the given timestamp,
+// or after the cursor returned by the previous pull.
+func (app *app) pullSalesSyncHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	v := validator.New()
+
+	// An empty since and cursor means the terminal is syncing for the first time
+	var cursor data.SyncCursor
+	if value := query.Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			v.AddError("since", "must be an RFC3339 timestamp")
+		}
+		cursor = data.NewSyncCursor(parsed)
+	}
+	if value := query.Get("cursor"); value != "" {
+		v.Check(query.Get("since") == "", "cursor", "cannot be combined with since")
+		parsed, err := data.ParseSyncCursor(value)
+		if err != nil {
+			v.AddError("cursor", "must be the next_cursor of an earlier pull")
+		}
+		cursor = parsed
+	}
+	limit := app.getSingleIntQueryParameter(query, "limit", 500, v)
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 1000, "limit", "must be a maximum of 1000")
+
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	changes, err := app.models.Sales.GetChangesSince(cursor, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"changes": changes}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
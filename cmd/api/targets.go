@@ -0,0 +1,157 @@
+// File: cmd/api/targets.go
+// Description: handlers for monthly revenue targets and the progress towards them
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// listTargetsHandler returns the targets of a year, the current one unless ?year= is given.
+func (app *app) listTargetsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	year := app.getSingleIntQueryParameter(r.URL.Query(), "year", int64(app.now().In(app.contextGetUser(r).Location()).Year()), v)
+	v.Check(year >= 2000 && year <= 9999, "year", "must be between 2000 and 9999")
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	targets, err := app.models.Targets.GetAllForYear(int(year))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"year": year, "currency": app.config.baseCurrency, "targets": targets}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// setTargetHandler sets the revenue target of the month in the URL, replacing any existing one.
+func (app *app) setTargetHandler(w http.ResponseWriter, r *http.Request) {
+	// SetTargetPayload struct to hold the incoming JSON payload
+	var SetTargetPayload struct {
+		Amount float64 `json:"amount"`
+	}
+
+	if err := app.readJSON(w, r, &SetTargetPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	target := &data.RevenueTarget{
+		Month:     httprouter.ParamsFromContext(r.Context()).ByName("month"),
+		Amount:    SetTargetPayload.Amount,
+		UpdatedBy: &app.contextGetUser(r).ID,
+	}
+
+	v := validator.New()
+	if data.ValidateRevenueTarget(v, target); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Targets.Upsert(target); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"target": target}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// deleteTargetHandler removes the revenue target of the month in the URL.
+func (app *app) deleteTargetHandler(w http.ResponseWriter, r *http.Request) {
+	month := httprouter.ParamsFromContext(r.Context()).ByName("month")
+	if _, err := time.Parse(data.MonthLayout, month); err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.models.Targets.Delete(month); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusNoContent, nil, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// targetProgressHandler compares the revenue of a month so far with its target and projects
+// where the month will end at the current pace. The month defaults to the current one and is
+// taken in ?timezone=, or the user's timezone, and amounts are in ?currency=, or the base currency.
+func (app *app) targetProgressHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	v := validator.New()
+
+	loc := app.contextGetUser(r).Location()
+	if timezone := query.Get("timezone"); timezone != "" {
+		if data.ValidateTimezone(v, timezone); !v.IsValid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+		loc, _ = time.LoadLocation(timezone)
+	}
+
+	now := app.now().In(loc)
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	if month := query.Get("month"); month != "" {
+		parsed, err := time.ParseInLocation(data.MonthLayout, month, loc)
+		if err != nil {
+			v.AddError("month", "must be a valid month in YYYY-MM format")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+		start = parsed
+	}
+
+	fx, err := app.readConversion(query, v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	models := app.modelsFor(r)
+	cal, err := models.Calendar.Get(start, start.AddDate(0, 1, -1))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	progress, err := models.Targets.Progress(start, now, fx, cal)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"progress": progress}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
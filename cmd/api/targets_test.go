@@ -0,0 +1,55 @@
+// File: cmd/api/targets_test.go
+// Description: test suite for revenue target handlers - validation focused
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/julienschmidt/httprouter"
+)
+
+// TestTargetHandlersValidation tests that bad months, amounts and timezones are rejected before the database is used
+func TestTargetHandlersValidation(t *testing.T) {
+	app := newTestApp()
+
+	tests := []struct {
+		name           string
+		handler        http.HandlerFunc
+		method         string
+		url            string
+		month          string
+		body           string
+		expectedStatus int
+	}{
+		{"Set Invalid Month", app.setTargetHandler, http.MethodPut, "/v1/targets/2025-13", "2025-13", `{"amount": 1000}`, http.StatusUnprocessableEntity},
+		{"Set Zero Amount", app.setTargetHandler, http.MethodPut, "/v1/targets/2025-03", "2025-03", `{"amount": 0}`, http.StatusUnprocessableEntity},
+		{"Set Unknown Field", app.setTargetHandler, http.MethodPut, "/v1/targets/2025-03", "2025-03", `{"amount": 1000, "store": 2}`, http.StatusBadRequest},
+		{"Delete Invalid Month", app.deleteTargetHandler, http.MethodDelete, "/v1/targets/march", "march", "", http.StatusNotFound},
+		{"List Invalid Year", app.listTargetsHandler, http.MethodGet, "/v1/targets?year=99", "", "", http.StatusUnprocessableEntity},
+		{"Progress Invalid Month", app.targetProgressHandler, http.MethodGet, "/v1/reports/targets/progress?month=2025-3", "", "", http.StatusUnprocessableEntity},
+		{"Progress Unknown Timezone", app.targetProgressHandler, http.MethodGet, "/v1/reports/targets/progress?timezone=Nowhere/Land", "", "", http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			params := httprouter.Params{{Key: "month", Value: tt.month}}
+			req = req.WithContext(context.WithValue(req.Context(), httprouter.ParamsKey, params))
+			req = app.contextSetUser(req, &data.User{ID: 7})
+			rr := httptest.NewRecorder()
+
+			tt.handler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// File: cmd/api/taxes.go
+// Description: admin handlers for managing the tax rates added to sales
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// readTaxRate reads the fields of a tax rate from the request body into rate and validates them.
+// Tags are lower cased, so they can be typed in any case.
+func (app *app) readTaxRate(w http.ResponseWriter, r *http.Request, v *validator.Validator, rate *data.TaxRate) error {
+	// TaxRatePayload struct to hold the incoming JSON payload
+	var TaxRatePayload struct {
+		Name string   `json:"name"`
+		Rate *float64 `json:"rate"` // percent, 0 exempts the tag
+		Tag  string   `json:"tag"`  // Optional - only products carrying this tag, every product when left out
+	}
+
+	if err := app.readJSON(w, r, &TaxRatePayload); err != nil {
+		return err
+	}
+
+	rate.Name = TaxRatePayload.Name
+	v.Check(TaxRatePayload.Rate != nil, "rate", "must be provided")
+	if TaxRatePayload.Rate != nil {
+		rate.Rate = *TaxRatePayload.Rate
+	}
+	rate.Tag = ""
+	if tags := data.NormalizeTags([]string{TaxRatePayload.Tag}); len(tags) > 0 {
+		rate.Tag = tags[0]
+	}
+	data.ValidateTaxRate(v, rate)
+	return nil
+}
+
+// listTaxRatesHandler returns every tax rate, the rate of every product first.
+func (app *app) listTaxRatesHandler(w http.ResponseWriter, r *http.Request) {
+	rates, err := app.models.Taxes.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"tax_rates": rates}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// createTaxRateHandler adds a tax rate, for every product or for the products of a tag.
+func (app *app) createTaxRateHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	rate := &data.TaxRate{CreatedBy: &app.contextGetUser(r).ID}
+	if err := app.readTaxRate(w, r, v, rate); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Taxes.Insert(rate); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateTaxTag):
+			v.AddError("tag", "a tax rate for this tag already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/admin/taxes/%d", rate.ID))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"tax_rate": rate}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// getTaxRateHandler returns a tax rate by ID.
+func (app *app) getTaxRateHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	rate, err := app.models.Taxes.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"tax_rate": rate}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// updateTaxRateHandler replaces every field of a tax rate. Sales already made keep the tax they
+// were charged.
+func (app *app) updateTaxRateHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	rate := &data.TaxRate{ID: id, UpdatedBy: &app.contextGetUser(r).ID}
+	if err := app.readTaxRate(w, r, v, rate); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Taxes.Update(rate); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateTaxTag):
+			v.AddError("tag", "a tax rate for this tag already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"tax_rate": rate}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// deleteTaxRateHandler removes a tax rate. Sales already made keep the tax they were charged.
+func (app *app) deleteTaxRateHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParameter(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.models.Taxes.Delete(id); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusNoContent, nil, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
@@ -11,12 +11,20 @@ import (
 	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
 )
 
+// Default lifetimes of activation tokens and login sessions, and the longest either can be set to.
+const (
+	defaultActivationTokenTTL     = 3 * 24 * time.Hour
+	defaultAuthenticationTokenTTL = 24 * time.Hour
+	maxTokenTTL                   = 30 * 24 * time.Hour
+)
+
 // createAuthenticationTokenHandler handles the creation of authentication tokens.
 func (app *app) createAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
 	// Define the structure for the expected JSON payload.
 	var input struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		TOTPCode string `json:"totp_code"`
 	}
 
 	// Read and parse the JSON payload from the request body.
@@ -29,7 +37,9 @@ func (app *app) createAuthenticationTokenHandler(w http.ResponseWriter, r *http.
 	// Validate the input data.
 	v := validator.New()
 	data.ValidateEmail(v, input.Email)
-	data.ValidatePasswordPlaintext(v, input.Password)
+	// Only the shape is checked here, passwords set under an older policy must still log in
+	v.Check(input.Password != "", "password", "must be provided")
+	v.Check(len(input.Password) <= validator.PasswordMaxLength, "password", "must not be more than 72 characters long")
 	if !v.IsValid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
@@ -40,6 +50,7 @@ func (app *app) createAuthenticationTokenHandler(w http.ResponseWriter, r *http.
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
+			app.recordAuthEvent(r, data.AuthLoginFailed, nil)
 			app.invalidCredentialsResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
@@ -57,16 +68,43 @@ func (app *app) createAuthenticationTokenHandler(w http.ResponseWriter, r *http.
 		return
 	}
 	if !match {
+		app.recordAuthEvent(r, data.AuthLoginFailed, &user.ID)
 		app.invalidCredentialsResponse(w, r)
 		return
 	}
 
+	// Users with 2FA enabled must also present a current code from their authenticator
+	if user.TOTPEnabled {
+		if input.TOTPCode == "" {
+			app.twoFactorRequiredResponse(w, r)
+			return
+		}
+		valid, err := app.validateTOTPCode(user, input.TOTPCode)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !valid {
+			app.recordAuthEvent(r, data.AuthLoginFailed, &user.ID)
+			app.invalidCredentialsResponse(w, r)
+			return
+		}
+	}
+
+	// Upgrade hashes written with an older algorithm or cost now that the plaintext is known
+	if user.Password.NeedsRehash() {
+		if err := app.models.Users.RehashPassword(user, input.Password); err != nil {
+			app.logger.Error("failed to rehash password", "user_id", user.ID, "error", err)
+		}
+	}
+
 	// Generate a new authentication token for the authenticated user.
-	token, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeAuthentication)
+	token, err := app.models.Tokens.NewSession(user.ID, app.config.tokens.authenticationTTL, truncate(r.UserAgent(), 512), clientIP(r))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+	app.recordAuthEvent(r, data.AuthLoginSucceeded, &user.ID)
 
 	// Send the token back in the response.
 	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": token.Plaintext}, nil)
@@ -78,20 +116,42 @@ func (app *app) createAuthenticationTokenHandler(w http.ResponseWriter, r *http.
 
 // deleteAuthenticationTokenHandler handles the deletion of authentication tokens.
 func (app *app) deleteAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
-	// get user id from context
-	userID := app.contextGetUser(r).ID
+	user := app.contextGetUser(r)
 
-	// delete all authentication tokens for the user
-	err := app.models.Tokens.DeleteAllForUser(data.ScopeAuthentication, userID)
+	// An admin ending an impersonation only drops their own token, the user stays signed in
+	var err error
+	if user.IsImpersonated() {
+		err = app.models.Tokens.Delete(app.contextGetToken(r))
+	} else {
+		// delete all authentication tokens for the user
+		err = app.models.Tokens.DeleteAllForUser(data.ScopeAuthentication, user.ID)
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Return a 204 No Content response
+	err = app.writeJSON(w, http.StatusNoContent, nil, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+}
+
+// listSessionsHandler lists the authenticated user's active sessions so unfamiliar devices or
+// locations can be spotted. Tokens themselves are never returned.
+func (app *app) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
 
-	// send a success response
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "authentication tokens deleted successfully"}, nil)
+	sessions, err := app.models.Tokens.GetSessions(user.ID, app.contextGetToken(r))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"sessions": sessions}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 }
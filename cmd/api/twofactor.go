@@ -0,0 +1,130 @@
+// File: cmd/api/twofactor.go
+// Description: TOTP two-factor authentication setup and verification handlers
+
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/totp"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// totpSkew is the number of 30 second steps of clock drift tolerated either side of now.
+const totpSkew = 1
+
+// errTOTPNotConfigured is returned when 2FA is used without an encryption key configured.
+var errTOTPNotConfigured = errors.New("totp encryption key is not configured")
+
+// setupTwoFactorHandler generates a new TOTP secret for the authenticated user.
+// 2FA stays disabled until the user proves their authenticator works via verifyTwoFactorHandler.
+func (app *app) setupTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	if len(app.config.totp.encryptionKey) == 0 {
+		app.serverErrorResponse(w, r, errTOTPNotConfigured)
+		return
+	}
+
+	// Replacing the secret of an enabled user would silently lock them out of their authenticator
+	if user.TOTPEnabled {
+		app.conflictResponse(w, r)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	encrypted, err := totp.Encrypt(app.config.totp.encryptionKey, secret)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.models.Users.SetTOTPSecret(user.ID, encrypted); err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.conflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	response := envelope{
+		"secret":     totp.EncodeSecret(secret),
+		"qr_payload": totp.URL(app.config.totp.issuer, user.Email, secret),
+	}
+	if err := app.writeJSON(w, http.StatusOK, response, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// verifyTwoFactorHandler enables 2FA once the user submits a valid code for their pending secret.
+func (app *app) verifyTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	// VerifyTwoFactorPayload struct to hold the incoming JSON payload
+	var VerifyTwoFactorPayload struct {
+		Code string `json:"code"`
+	}
+
+	if err := app.readJSON(w, r, &VerifyTwoFactorPayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(VerifyTwoFactorPayload.Code != "", "code", "must be provided")
+	v.Check(len(user.TOTPSecret) > 0, "code", "two-factor setup has not been started")
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if user.TOTPEnabled {
+		app.conflictResponse(w, r)
+		return
+	}
+
+	valid, err := app.validateTOTPCode(user, VerifyTwoFactorPayload.Code)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !valid {
+		v.AddError("code", "invalid or expired code")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Users.EnableTOTP(user.ID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"message": "two-factor authentication enabled"}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// validateTOTPCode decrypts the user's stored secret and checks a submitted code against it.
+func (app *app) validateTOTPCode(user *data.User, code string) (bool, error) {
+	if len(app.config.totp.encryptionKey) == 0 {
+		return false, errTOTPNotConfigured
+	}
+
+	secret, err := totp.Decrypt(app.config.totp.encryptionKey, user.TOTPSecret)
+	if err != nil {
+		return false, err
+	}
+
+	return totp.Validate(secret, code, app.now(), totpSkew), nil
+}
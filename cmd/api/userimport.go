@@ -0,0 +1,285 @@
+// File: cmd/api/userimport.go
+// Description: handler for creating many staff accounts at once from a CSV or JSON upload
+
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/mailer"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// userImportMaxRows caps one import, each row is inserted and mailed on its own.
+const userImportMaxRows = 200
+
+// staffAccountTokenTTL is how long an imported user has to choose their password.
+const staffAccountTokenTTL = invitationTTL
+
+// userImportColumns are the CSV columns, first_name, last_name and email are required.
+var userImportColumns = []string{"first_name", "last_name", "email", "role", "timezone", "locale"}
+
+// userImportRow is one account to create. Role defaults to guest, timezone and locale to the usual defaults.
+type userImportRow struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Role      string `json:"role,omitempty"`
+	Timezone  string `json:"timezone,omitempty"`
+	Locale    string `json:"locale,omitempty"`
+}
+
+// userImportResult reports what happened to one row, numbered from 1 after the CSV header.
+type userImportResult struct {
+	Row     int               `json:"row"`
+	Email   string            `json:"email"`
+	UserID  int64             `json:"user_id,omitempty"`
+	Invited bool              `json:"invited,omitempty"`
+	Errors  map[string]string `json:"errors,omitempty"`
+}
+
+// importUsersHandler creates an activated account for every valid row of a CSV or JSON upload.
+// Rows fail on their own, the response lists the outcome of each. Imported accounts have no
+// usable password until their owner sets one, with ?invite=true each is emailed a token to do so.
+func (app *app) importUsersHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	invite := app.getOptionalBoolQueryParameter(r.URL.Query(), "invite", v)
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	sendInvites := invite != nil && *invite
+
+	// Without a mailer the tokens could never reach the new users
+	if sendInvites && app.mailer == nil {
+		app.mailerUnavailableResponse(w, r)
+		return
+	}
+
+	rows, err := app.readUserImport(w, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v.Check(len(rows) > 0, "users", "must contain at least one row")
+	v.Check(len(rows) <= userImportMaxRows, "users", fmt.Sprintf("must not contain more than %d rows", userImportMaxRows))
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	importer := app.contextGetUser(r)
+	permissions, err := app.models.Permissions.GetAllForUser(importer.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	roles := make(map[string]*data.Role)
+	results := make([]userImportResult, len(rows))
+	var created []int64
+	for i, row := range rows {
+		results[i] = app.importUser(row, importer, permissions, roles, sendInvites)
+		results[i].Row = i + 1
+		if results[i].UserID != 0 {
+			created = append(created, results[i].UserID)
+		}
+	}
+
+	if len(created) > 0 {
+		entry := &data.AuditEntry{
+			UserID:    &importer.ID,
+			Action:    "import",
+			Entity:    "users",
+			EntityIDs: created,
+			Details:   map[string]any{"rows": len(rows), "invited": sendInvites},
+		}
+		if err := app.models.Audit.Insert(entry); err != nil {
+			app.logger.Error("failed to audit user import", "user_id", importer.ID, "error", err)
+		}
+	}
+
+	env := envelope{"created": len(created), "failed": len(rows) - len(created), "results": results}
+	if err := app.writeJSON(w, http.StatusOK, env, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// importUser creates the account of one row. Unexpected errors are logged and reported on the
+// row, so rows created before them are still listed in the response.
+func (app *app) importUser(row userImportRow, importer *data.User, permissions data.Permissions, roles map[string]*data.Role, invite bool) userImportResult {
+	result := userImportResult{Email: row.Email}
+	fail := func(key, message string) userImportResult {
+		result.Errors = map[string]string{key: message}
+		return result
+	}
+
+	if row.Role == "" {
+		row.Role = data.DefaultRole
+	}
+	role, ok := roles[row.Role]
+	if !ok {
+		var err error
+		role, err = app.models.Roles.GetByName(row.Role)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			role = nil
+		case err != nil:
+			app.logger.Error("failed to look up role for import", "role", row.Role, "error", err)
+			return fail("user", "could not be created, please retry")
+		}
+		roles[row.Role] = role
+	}
+
+	user := &data.User{
+		FirstName: row.FirstName,
+		LastName:  row.LastName,
+		Role:      row.Role,
+		Email:     row.Email,
+		IsActive:  true, // the owner proves the address by using the emailed token, or a password reset
+		Timezone:  row.Timezone,
+		Locale:    row.Locale,
+	}
+
+	v := validator.New()
+	data.ValidateUser(v, user)
+	v.Check(role != nil, "role", "must be one of the permitted values")
+	if !v.IsValid() {
+		result.Errors = v.Errors
+		return result
+	}
+
+	// Creating users in a role needs the same permissions as moving them into it
+	if !roleAssignable(permissions, role) {
+		return fail("role", "you do not have the necessary permissions to assign this role")
+	}
+
+	if err := user.Password.SetRandom(); err != nil {
+		app.logger.Error("failed to set imported user password", "email", user.Email, "error", err)
+		return fail("user", "could not be created, please retry")
+	}
+
+	if err := app.models.Users.Insert(user); err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			return fail("email", "a user with this email address already exists")
+		case errors.Is(err, data.ErrInvalidData):
+			return fail("user", "invalid user data provided")
+		default:
+			app.logger.Error("failed to insert imported user", "email", user.Email, "error", err)
+			return fail("user", "could not be created, please retry")
+		}
+	}
+	result.UserID = user.ID
+
+	if err := app.models.Permissions.AssignPermissions(user.ID, role.Permissions); err != nil {
+		// Same as registration, the account exists and permissions can be assigned later
+		app.logger.Error("failed to assign permissions", "user_id", user.ID, "error", err)
+	}
+
+	if invite {
+		token, err := app.models.Tokens.New(user.ID, staffAccountTokenTTL, data.ScopePasswordReset)
+		if err != nil {
+			// The owner can still use the password reset endpoint
+			app.logger.Error("failed to generate imported user token", "user_id", user.ID, "error", err)
+			return result
+		}
+
+		app.sendEmail(&user.ID, user.Email, user.Locale, mailer.StaffAccountEmail{
+			FirstName:          user.FirstName,
+			Email:              user.Email,
+			Role:               user.Role,
+			CreatorName:        fmt.Sprintf("%s %s", importer.FirstName, importer.LastName),
+			PasswordResetToken: token.Plaintext,
+		})
+		result.Invited = true
+	}
+
+	return result
+}
+
+// readUserImport decodes the rows of a text/csv body, or of a JSON array for any other content type.
+func (app *app) readUserImport(w http.ResponseWriter, r *http.Request) ([]userImportRow, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "text/csv" {
+		var rows []userImportRow
+		err := app.readJSON(w, r, &rows)
+		return rows, err
+	}
+
+	// Same body limit as readJSON
+	r.Body = http.MaxBytesReader(w, r.Body, 256_000)
+	rows, err := parseUserImportCSV(r.Body)
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return nil, fmt.Errorf("the body must not be larger than %d bytes", maxBytesError.Limit)
+	}
+	return rows, err
+}
+
+// parseUserImportCSV reads rows under a header naming the columns in any order.
+func parseUserImportCSV(body io.Reader) ([]userImportRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the body must not be empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("the body contains badly-formed CSV: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) // spreadsheets often save a byte order mark
+		if !slices.Contains(userImportColumns, name) {
+			return nil, fmt.Errorf("the CSV header contains unknown column %q", name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("the CSV header contains column %q twice", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range userImportColumns[:3] {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the CSV header must contain a %q column", name)
+		}
+	}
+
+	var rows []userImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("the body contains badly-formed CSV: %w", err)
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		rows = append(rows, userImportRow{
+			FirstName: field("first_name"),
+			LastName:  field("last_name"),
+			Email:     field("email"),
+			Role:      field("role"),
+			Timezone:  field("timezone"),
+			Locale:    field("locale"),
+		})
+	}
+}
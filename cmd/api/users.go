@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/mailer"
 	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
 )
 
@@ -17,9 +18,11 @@ func (app *app) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 	var RegisterUserPayload struct {
 		FirstName string `json:"first_name"`
 		LastName  string `json:"last_name"`
-		Role      string `json:"role,omitempty"` // Optional - will default to guest
+		Role      string `json:"role,omitempty"` // Optional - only the default role is accepted
 		Email     string `json:"email"`
 		Password  string `json:"password"`
+		Timezone  string `json:"timezone,omitempty"` // Optional - will default to UTC
+		Locale    string `json:"locale,omitempty"`   // Optional - will default to en
 	}
 
 	if err := app.readJSON(w, r, &RegisterUserPayload); err != nil {
@@ -27,19 +30,27 @@ func (app *app) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set default role if not provided or invalid
-	validRoles := map[string]bool{"admin": true, "cashier": true, "guest": true}
-	if RegisterUserPayload.Role == "" || !validRoles[RegisterUserPayload.Role] {
-		RegisterUserPayload.Role = "guest"
+	// Self-registration always gets the default role; elevated roles come from invitations, import or an admin
+	v := validator.New()
+	if validateRegistrationRole(v, RegisterUserPayload.Role); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	role, err := app.models.Roles.GetByName(data.DefaultRole)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
 	// Create a new User struct
 	user := &data.User{
 		FirstName: RegisterUserPayload.FirstName,
 		LastName:  RegisterUserPayload.LastName,
-		Role:      RegisterUserPayload.Role,
+		Role:      role.Name,
 		Email:     RegisterUserPayload.Email,
-		IsActive:  false, // New users start inactive until activation
+		IsActive:  app.autoActivate(), // New users start inactive until activation, unless there is no mailer in development
+		Timezone:  RegisterUserPayload.Timezone,
+		Locale:    RegisterUserPayload.Locale,
 	}
 
 	if err := user.Password.Set(RegisterUserPayload.Password); err != nil {
@@ -48,7 +59,6 @@ func (app *app) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate the user data
-	v := validator.New()
 	if data.ValidateUser(v, user); !v.IsValid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
@@ -71,34 +81,42 @@ func (app *app) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Assign permissions based on role
-	var permissions data.Permissions
-	switch user.Role {
-	case "admin":
-		permissions = data.Permissions{
-			"sale:create", "sale:view", "sale:delete", "sale:update",
-			"product:create", "product:view", "product:delete", "product:update",
-			"users:create", "users:view", "users:delete", "users:update",
-			"self:create", "self:view", "self:delete", "self:update",
-		}
-	case "cashier":
-		permissions = data.Permissions{
-			"sale:create", "sale:view", "product:create", "product:view",
-			"users:view", "self:create", "self:view", "self:update",
-		}
-	case "guest":
-		permissions = data.Permissions{"product:view", "self:view"}
-	default:
-		permissions = data.Permissions{"product:view", "self:view"}
-	}
-
-	// Assign permissions to the user
-	if err := app.models.Permissions.AssignPermissions(user.ID, permissions); err != nil {
+	// Assign the role's permission bundle to the user
+	if err := app.models.Permissions.AssignPermissions(user.ID, role.Permissions); err != nil {
 		// Log the error but don't fail the registration
 		app.logger.Error("failed to assign permissions", "user_id", user.ID, "error", err)
 		// Continue with registration - permissions can be assigned later
 	}
 
+	if !user.IsActive {
+		app.recordAuthEvent(r, data.AuthRegistered, &user.ID)
+	}
+
+	switch {
+	case user.IsActive:
+		app.logger.Warn("user activated without email because SMTP is not configured", "user_id", user.ID)
+	case app.mailer == nil:
+		app.logger.Warn("activation email not sent because SMTP is not configured, an admin must issue the token", "user_id", user.ID)
+	default:
+		app.sendActivationEmail(user)
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/users/%d", user.ID))
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"user": user}, headers); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// validateRegistrationRole rejects any role other than the default on self-registration.
+func validateRegistrationRole(v *validator.Validator, role string) {
+	v.Check(role == "" || role == data.DefaultRole, "role", fmt.Sprintf("must be empty or %q; other roles are assigned by an admin", data.DefaultRole))
+}
+
+// sendActivationEmail creates an activation token for the user and mails it in the background.
+func (app *app) sendActivationEmail(user *data.User) {
 	// Clear existing activation tokens (in case of re-registration)
 	if err := app.models.Tokens.DeleteAllForUser(data.ScopeActivation, user.ID); err != nil {
 		app.logger.Error("failed to clear existing tokens", "user_id", user.ID, "error", err)
@@ -106,36 +124,20 @@ func (app *app) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Generate a new activation token
-	token, err := app.models.Tokens.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
+	token, err := app.models.Tokens.New(user.ID, app.config.tokens.activationTTL, data.ScopeActivation)
 	if err != nil {
 		app.logger.Error("failed to generate activation token", "user_id", user.ID, "error", err)
 		// Still return success - user is created, they can request new token later
+		return
 	}
 
 	// Send activation email (background process)
-	if app.mailer != nil && token != nil {
-		app.background(func() {
-			emailData := map[string]any{
-				"userID":          user.ID,
-				"firstName":       user.FirstName,
-				"lastName":        user.LastName,
-				"email":           user.Email,
-				"password":        RegisterUserPayload.Password,
-				"activationToken": token.Plaintext,
-			}
-			if err := app.mailer.Send(user.Email, "user_welcome.tmpl", emailData); err != nil {
-				app.logger.Error("failed to send activation email", "user_id", user.ID, "error", err)
-			}
-		})
-	}
-
-	headers := make(http.Header)
-	headers.Set("Location", fmt.Sprintf("/v1/users/%d", user.ID))
-
-	if err := app.writeJSON(w, http.StatusCreated, envelope{"user": user}, headers); err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
+	app.sendEmail(&user.ID, user.Email, user.Locale, mailer.WelcomeEmail{
+		UserID:          user.ID,
+		Email:           user.Email,
+		ActivationToken: token.Plaintext,
+		ExpiresInHours:  int64(app.config.tokens.activationTTL.Hours()),
+	})
 }
 
 // activateUserHandler handles user account activation.
@@ -185,6 +187,7 @@ func (app *app) activateUserHandler(w http.ResponseWriter, r *http.Request) {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+	app.recordAuthEvent(r, data.AuthActivated, &user.ID)
 
 	// Send a confirmation response
 	if err := app.writeJSON(w, http.StatusOK, envelope{"message": "account successfully activated"}, nil); err != nil {
@@ -203,6 +206,80 @@ func (app *app) showCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// updateOwnProfileHandler lets the authenticated user change their own name and password.
+// Role, activation and other admin controlled fields are not accepted here.
+func (app *app) updateOwnProfileHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	// UpdateProfilePayload struct to hold the incoming JSON payload
+	var UpdateProfilePayload struct {
+		FirstName       *string `json:"first_name"`
+		LastName        *string `json:"last_name"`
+		Email           *string `json:"email"`
+		Password        *string `json:"password"`
+		CurrentPassword string  `json:"current_password"`
+		Locale          *string `json:"locale"`
+	}
+
+	if err := app.readJSON(w, r, &UpdateProfilePayload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if UpdateProfilePayload.FirstName != nil {
+		user.FirstName = *UpdateProfilePayload.FirstName
+	}
+	if UpdateProfilePayload.LastName != nil {
+		user.LastName = *UpdateProfilePayload.LastName
+	}
+	// Email addresses are only changed after the new inbox confirms, see requestEmailChangeHandler
+	if UpdateProfilePayload.Email != nil && *UpdateProfilePayload.Email != user.Email {
+		v.AddError("email", "must be changed through POST /v1/users/email-change")
+	}
+	if UpdateProfilePayload.Locale != nil {
+		user.Locale = *UpdateProfilePayload.Locale
+		data.ValidateLocale(v, user.Locale)
+	}
+	if UpdateProfilePayload.Password != nil {
+		// A new password needs the current one, unless the session was just opened, so a hijacked
+		// session cannot lock the owner out
+		confirmed, err := app.passwordConfirmed(user, UpdateProfilePayload.CurrentPassword)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !confirmed {
+			v.AddError("current_password", "must match your current password")
+		}
+		if err := user.Password.Set(*UpdateProfilePayload.Password); err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if data.ValidateUser(v, user); !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.Users.Update(user); err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
 // ShowUserHandler handles retrieving a user by ID.
 func (app *app) showUserHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
@@ -228,6 +305,129 @@ func (app *app) showUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// readUserSubresourceOwner loads the user named in the URL for their sub-resources. Users can
+// always read their own; anyone else needs users:view. It writes the error response and returns
+// nil when the request cannot go on.
+func (app *app) readUserSubresourceOwner(w http.ResponseWriter, r *http.Request) *data.User {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+
+	if current := app.contextGetUser(r); current.ID != id {
+		permissions, err := app.models.Permissions.GetAllForUser(current.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return nil
+		}
+		if !permissions.Includes(data.PermissionUsersView) {
+			app.notPermittedResponse(w, r)
+			return nil
+		}
+	}
+
+	user, err := app.models.Users.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+	return user
+}
+
+// listUserSalesHandler lists the sales made by one user, with the filters of the global sales list.
+func (app *app) listUserSalesHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.readUserSubresourceOwner(w, r)
+	if user == nil {
+		return
+	}
+
+	query := r.URL.Query()
+	v := validator.New()
+
+	filter := app.readFilters(query, "sales", "-sold_at", userSaleSortSafelist, v)
+	data.ValidateFilters(v, filter)
+	filters := data.SaleFilter{
+		Filter:    filter,
+		UserID:    user.ID,
+		ProductID: app.getSingleIntQueryParameter(query, "product_id", 0, v),
+		MinDate:   app.getSingleDateQueryParameter(query, "min_date", "", v),
+		MaxDate:   app.getSingleDateQueryParameter(query, "max_date", "", v),
+	}
+	if includeArchived := app.getOptionalBoolQueryParameter(query, "include_archived", v); includeArchived != nil {
+		filters.IncludeArchived = *includeArchived
+	}
+
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	sales, metadata, err := app.models.Sales.GetAll(filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidSort):
+			app.invalidSortResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"sales": sales, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// listUserActivityHandler lists a user's recent activity, newest first by default: the sales
+// they recorded and the audited actions they took.
+func (app *app) listUserActivityHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.readUserSubresourceOwner(w, r)
+	if user == nil {
+		return
+	}
+
+	v := validator.New()
+	filter := app.readFilters(r.URL.Query(), "activity", "-occurred_at", activitySortSafelist, v)
+	data.ValidateFilters(v, filter)
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	activity, metadata, err := app.models.Audit.GetActivityForUser(user.ID, filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidSort):
+			app.invalidSortResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"activity": activity, "metadata": metadata}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// userSaleSortSafelist are the fields a user's sales can be sorted by. They all belong to the same
+// user, so unlike saleSortSafelist there is no user_id.
+var userSaleSortSafelist = []string{
+	"id", "quantity", "sold_at",
+	"-id", "-quantity", "-sold_at",
+}
+
+// activitySortSafelist are the fields a user's activity can be sorted by.
+var activitySortSafelist = []string{"occurred_at", "-occurred_at"}
+
 // listUsersHandler handles listing users with optional filters.
 func (app *app) listUsersHandler(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
@@ -236,7 +436,7 @@ func (app *app) listUsersHandler(w http.ResponseWriter, r *http.Request) {
 	UsersSortSafelist := []string{"id", "first_name", "last_name", "email", "-id", "-first_name", "-last_name", "-email"}
 
 	// Read Query Parameters
-	filters := app.readFilters(query, "id", 20, UsersSortSafelist, v)
+	filters := app.readFilters(query, "users", "id", UsersSortSafelist, v)
 	// Validate Filters
 	data.ValidateFilters(v, filters)
 	if !v.IsValid() {
@@ -251,10 +451,34 @@ func (app *app) listUsersHandler(w http.ResponseWriter, r *http.Request) {
 		Role:     app.getSingleQueryParameter(query, "role", ""),
 		IsActive: app.getOptionalBoolQueryParameter(query, "is_active", v),
 	}
+	includeDeleted := app.getOptionalBoolQueryParameter(query, "include_deleted", v)
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Soft deleted users are only listed for admins who can delete and restore users
+	if includeDeleted != nil && *includeDeleted {
+		permissions, err := app.models.Permissions.GetAllForUser(app.contextGetUser(r).ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !permissions.Includes(data.PermissionUsersDelete) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+		userFilter.IncludeDeleted = true
+	}
 	// Get Users from database
 	users, metadata, err := app.models.Users.GetAll(userFilter)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrInvalidSort):
+			app.invalidSortResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 	err = app.writeJSON(w, http.StatusOK, envelope{"users": users, "metadata": metadata}, nil)
@@ -273,20 +497,278 @@ func (app *app) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete user from database
+	// Soft delete the user, keeping the row for records that reference it
 	err = app.models.Users.Delete(id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrLastAdmin):
+			// Deleting the last active admin would lock everyone out
+			app.lastAdminResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	// Send a confirmation response
-	if err := app.writeJSON(w, http.StatusOK, envelope{"message": "user successfully deleted"}, nil); err != nil {
+	// Return a 204 No Content response
+	if err := app.writeJSON(w, http.StatusNoContent, nil, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// restoreUserHandler brings back a soft deleted user by ID.
+func (app *app) restoreUserHandler(w http.ResponseWriter, r *http.Request) {
+	// Read ID parameter from URL
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if err := app.models.Users.Restore(id); err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.models.Users.GetByID(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// deactivateUserHandler turns off a user account, ending every session, and optionally hands their
+// sales to another user or anonymizes them. The body may be omitted to only deactivate.
+func (app *app) deactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// DeactivateUserPayload struct to hold the incoming JSON payload
+	var DeactivateUserPayload struct {
+		ReassignSalesTo *int64 `json:"reassign_sales_to"` // Optional - user to take over the sales
+		Anonymize       bool   `json:"anonymize"`         // Optional - scrub name and email, cannot be undone
+	}
+
+	if r.ContentLength != 0 {
+		if err := app.readJSON(w, r, &DeactivateUserPayload); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	v := validator.New()
+	v.Check(id != app.contextGetUser(r).ID, "user", "you cannot deactivate yourself")
+	if target := DeactivateUserPayload.ReassignSalesTo; target != nil {
+		v.Check(*target != id, "reassign_sales_to", "must be another user")
+		if v.IsValid() {
+			owner, err := app.models.Users.GetByID(*target)
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddError("reassign_sales_to", "must be an existing user")
+			case err != nil:
+				app.serverErrorResponse(w, r, err)
+				return
+			default:
+				v.Check(owner.IsActive, "reassign_sales_to", "must be an active user")
+			}
+		}
+	}
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	reassigned, err := app.models.Users.Deactivate(id, data.DeactivateOptions{
+		ReassignSalesTo: DeactivateUserPayload.ReassignSalesTo,
+		Anonymize:       DeactivateUserPayload.Anonymize,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrLastAdmin):
+			// Deactivating the last active admin would lock everyone out
+			app.lastAdminResponse(w, r)
+		case errors.Is(err, data.ErrInvalidData):
+			// The new owner was deleted between the check and the transaction
+			v.AddError("reassign_sales_to", "must be an existing user")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	entry := &data.AuditEntry{
+		UserID:    &app.contextGetUser(r).ID,
+		Action:    "deactivate",
+		Entity:    "users",
+		EntityIDs: []int64{id},
+		Details: map[string]any{
+			"reassign_sales_to": DeactivateUserPayload.ReassignSalesTo,
+			"sales_reassigned":  reassigned,
+			"anonymized":        DeactivateUserPayload.Anonymize,
+		},
+	}
+	if err := app.models.Audit.Insert(entry); err != nil {
+		app.logger.Error("failed to audit user deactivation", "user_id", id, "error", err)
+	}
+
+	user, err := app.models.Users.GetByID(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"user": user, "sales_reassigned": reassigned}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// issueActivationTokenHandler hands an admin a fresh activation token for an inactive user, so the
+// token can be passed on by other means while email delivery is not configured.
+func (app *app) issueActivationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if app.mailer != nil {
+		app.mailerConfiguredResponse(w, r)
+		return
+	}
+
+	// Read ID parameter from URL
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user, err := app.models.Users.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if user.IsActive {
+		v := validator.New()
+		v.AddError("user", "account is already activated")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Older tokens are dropped so only the one handed out here works
+	if err := app.models.Tokens.DeleteAllForUser(data.ScopeActivation, user.ID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.New(user.ID, app.config.tokens.activationTTL, data.ScopeActivation)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	admin := app.contextGetUser(r)
+	app.logger.Warn("activation token issued by admin because SMTP is not configured", "user_id", user.ID, "admin_id", admin.ID)
+
+	if err := app.writeJSON(w, http.StatusCreated, envelope{"activation_token": token}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// impersonationTokenTTL is how long an admin can act as another user with one token.
+const impersonationTokenTTL = 30 * time.Minute
+
+// impersonateUserHandler issues a short-lived authentication token that lets an admin act as
+// another user, for example to reproduce a permission problem. Every use is recorded in the audit log.
+func (app *app) impersonateUserHandler(w http.ResponseWriter, r *http.Request) {
+	// Read ID parameter from URL
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	admin := app.contextGetUser(r)
+	// Impersonation cannot be chained, it would hide who is really acting
+	if admin.IsImpersonated() {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	user, err := app.models.Users.GetByID(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	v := validator.New()
+	v.Check(user.ID != admin.ID, "user", "you cannot impersonate yourself")
+	v.Check(user.IsActive, "user", "account must be activated to be impersonated")
+	if !v.IsValid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Other admins are off limits, acting as one would grant nothing new but hide the actor
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if permissions.Includes(data.PermissionUsersAdmin) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	// The audit entry is written first, no token is handed out without a record of it
+	entry := &data.AuditEntry{
+		UserID:    &admin.ID,
+		Action:    "impersonate",
+		Entity:    "users",
+		EntityIDs: []int64{user.ID},
+		Details:   map[string]any{"ip": clientIP(r), "ttl_seconds": impersonationTokenTTL.Seconds()},
+	}
+	if err := app.models.Audit.Insert(entry); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.NewImpersonation(user.ID, admin.ID, impersonationTokenTTL, truncate(r.UserAgent(), 512), clientIP(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.logger.Warn("admin is impersonating a user", "user_id", user.ID, "admin_id", admin.ID)
+
+	env := envelope{"authentication_token": token.Plaintext, "expires_at": token.ExpiresAt, "user": user}
+	if err := app.writeJSON(w, http.StatusCreated, env, nil); err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
@@ -321,6 +803,8 @@ func (app *app) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 		Email     *string `json:"email"`
 		Password  *string `json:"password"`
 		IsActive  *bool   `json:"is_active"`
+		Timezone  *string `json:"timezone"`
+		Locale    *string `json:"locale"`
 	}
 
 	if err := app.readJSON(w, r, &UpdateUserPayload); err != nil {
@@ -328,6 +812,9 @@ func (app *app) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Remember the role before applying changes
+	originalRole := user.Role
+
 	// Update fields if provided
 	if UpdateUserPayload.FirstName != nil {
 		user.FirstName = *UpdateUserPayload.FirstName
@@ -338,9 +825,6 @@ func (app *app) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 	if UpdateUserPayload.Role != nil {
 		user.Role = *UpdateUserPayload.Role
 	}
-	if UpdateUserPayload.Email != nil {
-		user.Email = *UpdateUserPayload.Email
-	}
 	if UpdateUserPayload.Password != nil {
 		if err := user.Password.Set(*UpdateUserPayload.Password); err != nil {
 			app.serverErrorResponse(w, r, err)
@@ -350,20 +834,75 @@ func (app *app) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 	if UpdateUserPayload.IsActive != nil {
 		user.IsActive = *UpdateUserPayload.IsActive
 	}
+	if UpdateUserPayload.Timezone != nil {
+		user.Timezone = *UpdateUserPayload.Timezone
+	}
+	if UpdateUserPayload.Locale != nil {
+		user.Locale = *UpdateUserPayload.Locale
+	}
 
 	// Validate the updated user data
 	v := validator.New()
+	// Email addresses are only changed after the new inbox confirms, see requestEmailChangeHandler
+	if UpdateUserPayload.Email != nil && *UpdateUserPayload.Email != user.Email {
+		v.AddError("email", "must be changed through POST /v1/users/email-change")
+	}
+	if UpdateUserPayload.Timezone != nil {
+		data.ValidateTimezone(v, user.Timezone)
+	}
+	if UpdateUserPayload.Locale != nil {
+		data.ValidateLocale(v, user.Locale)
+	}
 	if data.ValidateUser(v, user); !v.IsValid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
+	// A new role must exist so its permission bundle can be applied
+	var role *data.Role
+	if UpdateUserPayload.Role != nil {
+		role, err = app.models.Roles.GetByName(user.Role)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddError("role", "must be one of the permitted values")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	// Moving a user into or out of a role needs every permission the role grants, so nobody can
+	// hand out or take away more than they hold, whatever the role is called
+	if user.Role != originalRole {
+		permissions, err := app.models.Permissions.GetAllForUser(app.contextGetUser(r).ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		previous, err := app.models.Roles.GetByName(originalRole)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !roleAssignable(permissions, role) || (previous != nil && !roleAssignable(permissions, previous)) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+
 	// Update the user record in the database
 	if err := app.models.Users.Update(user); err != nil {
 		switch {
-		case errors.Is(err, data.ErrDuplicateEmail):
-			v.AddError("email", "a user with this email address already exists")
-			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+			return
+		case errors.Is(err, data.ErrLastAdmin):
+			// Demoting or deactivating the last active admin would lock everyone out
+			app.lastAdminResponse(w, r)
+			return
 		default:
 			app.serverErrorResponse(w, r, err)
 			return
@@ -374,35 +913,14 @@ func (app *app) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	// If role was updated clear and reassign permissions
 	if UpdateUserPayload.Role != nil {
-		// Clear existing permissions
-		if err := app.models.Permissions.ClearPermissions(user.ID); err != nil {
+		// Clear existing permissions, the user may not have had any
+		if err := app.models.Permissions.ClearPermissions(user.ID); err != nil && !errors.Is(err, data.ErrNoRecords) {
 			app.serverErrorResponse(w, r, err)
 			return
 		}
 
-		// Assign new permissions based on updated role
-		var permissions data.Permissions
-		switch user.Role {
-		case "admin":
-			permissions = data.Permissions{
-				"sale:create", "sale:view", "sale:delete", "sale:update",
-				"product:create", "product:view", "product:delete", "product:update",
-				"users:create", "users:view", "users:delete", "users:update",
-				"self:create", "self:view", "self:delete", "self:update",
-			}
-		case "cashier":
-			permissions = data.Permissions{
-				"sale:create", "sale:view", "product:create", "product:view",
-				"users:view", "self:create", "self:view", "self:update",
-			}
-		case "guest":
-			permissions = data.Permissions{"product:view", "self:view"}
-		default:
-			permissions = data.Permissions{"product:view", "self:view"}
-		}
-
-		// Assign the new permissions
-		if err := app.models.Permissions.AssignPermissions(user.ID, permissions); err != nil {
+		// Assign the new role's permission bundle, which may be empty
+		if err := app.models.Permissions.AssignPermissions(user.ID, role.Permissions); err != nil && !errors.Is(err, data.ErrNoRecords) {
 			app.serverErrorResponse(w, r, err)
 			return
 		}
@@ -5,13 +5,18 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/Pedro-J-Kukul/salesapi/internal/data"
+	"github.com/Pedro-J-Kukul/salesapi/internal/mailer"
 	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/julienschmidt/httprouter"
 )
 
 // TestUserValidation tests user validation logic
@@ -23,6 +28,7 @@ func TestUserValidation(t *testing.T) {
 		email         string
 		password      string
 		role          string
+		timezone      string
 		expectedValid bool
 		errorField    string
 	}{
@@ -85,6 +91,27 @@ func TestUserValidation(t *testing.T) {
 			expectedValid: false,
 			errorField:    "password",
 		},
+		{
+			name:          "Valid Timezone",
+			firstName:     "John",
+			lastName:      "Doe",
+			email:         "john@example.com",
+			password:      "SecurePassword123!",
+			role:          "guest",
+			timezone:      "America/Belize",
+			expectedValid: true,
+		},
+		{
+			name:          "Invalid Timezone",
+			firstName:     "John",
+			lastName:      "Doe",
+			email:         "john@example.com",
+			password:      "SecurePassword123!",
+			role:          "guest",
+			timezone:      "Mars/Olympus_Mons",
+			expectedValid: false,
+			errorField:    "timezone",
+		},
 	}
 
 	for _, tt := range tests {
@@ -95,6 +122,7 @@ func TestUserValidation(t *testing.T) {
 				Email:     tt.email,
 				Role:      tt.role,
 				IsActive:  false,
+				Timezone:  tt.timezone,
 			}
 
 			// Set password if provided
@@ -122,57 +150,134 @@ func TestUserValidation(t *testing.T) {
 	}
 }
 
-// TestUserRoles tests role validation and defaults
-func TestUserRoles(t *testing.T) {
+// TestPasswordPolicy checks that configured rules replace the default complexity checks
+func TestPasswordPolicy(t *testing.T) {
+	defer data.SetPasswordPolicy(data.DefaultPasswordPolicy())
+
+	relaxed := data.DefaultPasswordPolicy()
+	relaxed.MinLength = 12
+	relaxed.RequiredClasses = []string{data.PasswordClassLower}
+	relaxed.BannedPasswords = append(relaxed.BannedPasswords, "Correct Horse Battery")
+	if err := data.SetPasswordPolicy(relaxed); err != nil {
+		t.Fatalf("unexpected error setting policy: %v", err)
+	}
+
 	tests := []struct {
-		name         string
-		inputRole    string
-		expectedRole string
-		isValid      bool
+		name          string
+		password      string
+		expectedValid bool
+	}{
+		{name: "Long Lowercase Passphrase", password: "purple monkey dishwasher", expectedValid: true},
+		{name: "Too Short", password: "Sh0rt!pass", expectedValid: false},
+		{name: "Missing Required Class", password: "ALLUPPERCASE123", expectedValid: false},
+		{name: "Banned Ignoring Case", password: "correct horse battery", expectedValid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			data.ValidatePasswordPlaintext(v, tt.password)
+			if v.IsValid() != tt.expectedValid {
+				t.Errorf("expected valid=%v, got errors: %v", tt.expectedValid, v.Errors)
+			}
+		})
+	}
+
+	invalid := data.DefaultPasswordPolicy()
+	invalid.RequiredClasses = []string{"emoji"}
+	if err := data.SetPasswordPolicy(invalid); err == nil {
+		t.Error("expected an error for an unknown character class")
+	}
+}
+
+// TestPasswordHashing checks both algorithms and the rehash decision when the configuration changes
+func TestPasswordHashing(t *testing.T) {
+	defer data.SetPasswordHashing(data.DefaultPasswordHashing())
+
+	tests := []struct {
+		name    string
+		hashing data.PasswordHashing
+		next    data.PasswordHashing // configuration the hash should be upgraded to
 	}{
 		{
-			name:         "Admin Role",
-			inputRole:    "admin",
-			expectedRole: "admin",
-			isValid:      true,
-		},
-		{
-			name:         "Cashier Role",
-			inputRole:    "cashier",
-			expectedRole: "cashier",
-			isValid:      true,
-		},
-		{
-			name:         "Guest Role",
-			inputRole:    "guest",
-			expectedRole: "guest",
-			isValid:      true,
+			name:    "Bcrypt Cost Change",
+			hashing: data.PasswordHashing{Algorithm: data.HashBcrypt, Cost: 4},
+			next:    data.PasswordHashing{Algorithm: data.HashBcrypt, Cost: 5},
 		},
 		{
-			name:         "Empty Role (should default to guest)",
-			inputRole:    "",
-			expectedRole: "guest",
-			isValid:      true,
+			name:    "Bcrypt To Argon2id",
+			hashing: data.PasswordHashing{Algorithm: data.HashBcrypt, Cost: 4},
+			next:    data.PasswordHashing{Algorithm: data.HashArgon2id, Cost: 1},
 		},
 		{
-			name:         "Invalid Role (should default to guest)",
-			inputRole:    "superuser",
-			expectedRole: "guest",
-			isValid:      true,
+			name:    "Argon2id Passes Change",
+			hashing: data.PasswordHashing{Algorithm: data.HashArgon2id, Cost: 1},
+			next:    data.PasswordHashing{Algorithm: data.HashArgon2id, Cost: 2},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Simulate role validation logic from registerUserHandler
-			validRoles := map[string]bool{"admin": true, "cashier": true, "guest": true}
-			role := tt.inputRole
-			if role == "" || !validRoles[role] {
-				role = "guest"
+			if err := data.SetPasswordHashing(tt.hashing); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var password data.Password
+			if err := password.Set("SecurePassword123!"); err != nil {
+				t.Fatalf("failed to set password: %v", err)
+			}
+			if password.NeedsRehash() {
+				t.Error("expected a fresh hash not to need rehashing")
+			}
+
+			for plaintext, expected := range map[string]bool{"SecurePassword123!": true, "WrongPassword123!": false} {
+				match, err := password.Matches(plaintext)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if match != expected {
+					t.Errorf("Matches(%q): expected %v, got %v", plaintext, expected, match)
+				}
+			}
+
+			// Older hashes keep verifying after the configuration changes
+			if err := data.SetPasswordHashing(tt.next); err != nil {
+				t.Fatalf("unexpected error: %v", err)
 			}
+			if !password.NeedsRehash() {
+				t.Error("expected the hash to need rehashing after the configuration changed")
+			}
+			if match, err := password.Matches("SecurePassword123!"); err != nil || !match {
+				t.Errorf("expected the old hash to still match, got %v, %v", match, err)
+			}
+		})
+	}
 
-			if role != tt.expectedRole {
-				t.Errorf("expected role %s, got %s", tt.expectedRole, role)
+	if err := data.SetPasswordHashing(data.PasswordHashing{Algorithm: "md5"}); err == nil {
+		t.Error("expected an error for an unknown algorithm")
+	}
+}
+
+// TestUserRoles tests that self-registration only accepts the default role
+func TestUserRoles(t *testing.T) {
+	tests := []struct {
+		name      string
+		inputRole string
+		isValid   bool
+	}{
+		{name: "Admin Role", inputRole: "admin", isValid: false},
+		{name: "Cashier Role", inputRole: "cashier", isValid: false},
+		{name: "Custom Role", inputRole: "supervisor", isValid: false},
+		{name: "Guest Role", inputRole: "guest", isValid: true},
+		{name: "Empty Role (defaults to guest)", inputRole: "", isValid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			validateRegistrationRole(v, tt.inputRole)
+			if v.IsValid() != tt.isValid {
+				t.Errorf("role %q: expected valid=%v, got errors %v", tt.inputRole, tt.isValid, v.Errors)
 			}
 		})
 	}
@@ -337,6 +442,43 @@ func TestRegisterUserHandler_Integration(t *testing.T) {
 	_ = req
 }
 
+// TestNoMailerMode checks auto activation and the guard on admin issued activation tokens
+func TestNoMailerMode(t *testing.T) {
+	tests := []struct {
+		name                 string
+		env                  string
+		withMailer           bool
+		expectedAutoActivate bool
+		expectedTokenStatus  int // only checked when the guard rejects before reading the database
+	}{
+		{name: "Development Without Mailer", env: "development", expectedAutoActivate: true},
+		{name: "Staging Without Mailer", env: "staging", expectedAutoActivate: false},
+		{name: "Development With Mailer", env: "development", withMailer: true, expectedAutoActivate: false, expectedTokenStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp()
+			app.config.env = tt.env
+			if tt.withMailer {
+				app.mailer = mailer.New("localhost", 2525, "", "", "Test <test@example.com>")
+			}
+
+			if got := app.autoActivate(); got != tt.expectedAutoActivate {
+				t.Errorf("expected autoActivate %v, got %v", tt.expectedAutoActivate, got)
+			}
+
+			if tt.expectedTokenStatus != 0 {
+				rr := httptest.NewRecorder()
+				app.issueActivationTokenHandler(rr, httptest.NewRequest(http.MethodPost, "/v1/user/1/activation-token", nil))
+				if rr.Code != tt.expectedTokenStatus {
+					t.Errorf("expected status %d, got %d", tt.expectedTokenStatus, rr.Code)
+				}
+			}
+		})
+	}
+}
+
 // Helper functions
 func boolPtr(b bool) *bool {
 	return &b
@@ -351,3 +493,150 @@ func boolPtrEqual(a, b *bool) bool {
 	}
 	return *a == *b
 }
+
+// TestParseUserImportCSV tests reading bulk import rows from CSV
+func TestParseUserImportCSV(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		expected    []userImportRow
+		expectedErr bool
+	}{
+		{
+			name: "Columns In Any Order",
+			body: "email,last_name,first_name,role\njane@example.com, Doe ,Jane,cashier\n",
+			expected: []userImportRow{
+				{FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Role: "cashier"},
+			},
+		},
+		{
+			name: "Byte Order Mark And Optional Columns",
+			body: "\ufeffFirst_Name,last_name,email,timezone,locale\nJuan,Perez,juan@example.com,America/Belize,es\nAna,Lopez,ana@example.com,,\n",
+			expected: []userImportRow{
+				{FirstName: "Juan", LastName: "Perez", Email: "juan@example.com", Timezone: "America/Belize", Locale: "es"},
+				{FirstName: "Ana", LastName: "Lopez", Email: "ana@example.com"},
+			},
+		},
+		{
+			name:     "Header Only",
+			body:     "first_name,last_name,email\n",
+			expected: nil,
+		},
+		{
+			name:        "Empty Body",
+			body:        "",
+			expectedErr: true,
+		},
+		{
+			name:        "Missing Required Column",
+			body:        "first_name,email\nJane,jane@example.com\n",
+			expectedErr: true,
+		},
+		{
+			name:        "Unknown Column",
+			body:        "first_name,last_name,email,password\nJane,Doe,jane@example.com,secret\n",
+			expectedErr: true,
+		},
+		{
+			name:        "Duplicate Column",
+			body:        "first_name,last_name,email,email\nJane,Doe,jane@example.com,jane@example.com\n",
+			expectedErr: true,
+		},
+		{
+			name:        "Ragged Row",
+			body:        "first_name,last_name,email\nJane,Doe\n",
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := parseUserImportCSV(strings.NewReader(tt.body))
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error=%v, got %v", tt.expectedErr, err)
+			}
+			if !slices.Equal(rows, tt.expected) {
+				t.Errorf("expected rows %+v, got %+v", tt.expected, rows)
+			}
+		})
+	}
+}
+
+// TestImportUsersHandler tests the request checks made before any row is imported
+func TestImportUsersHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		contentType    string
+		body           string
+		expectedStatus int
+	}{
+		{"Invite Without Mailer", "/v1/users/import?invite=true", "application/json", `[]`, http.StatusServiceUnavailable},
+		{"Invalid Invite Flag", "/v1/users/import?invite=maybe", "application/json", `[]`, http.StatusUnprocessableEntity},
+		{"Empty JSON Array", "/v1/users/import", "application/json", `[]`, http.StatusUnprocessableEntity},
+		{"JSON Object Instead Of Array", "/v1/users/import", "application/json", `{"email": "jane@example.com"}`, http.StatusBadRequest},
+		{"Unknown JSON Field", "/v1/users/import", "application/json", `[{"email": "jane@example.com", "password": "x"}]`, http.StatusBadRequest},
+		{"Malformed CSV", "/v1/users/import", "text/csv; charset=utf-8", "first_name,email\nJane,jane@example.com\n", http.StatusBadRequest},
+		{"Header Only CSV", "/v1/users/import", "text/csv", "first_name,last_name,email\n", http.StatusUnprocessableEntity},
+	}
+
+	app := newTestApp()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+
+			app.importUsersHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+// TestDeactivateUserHandler tests the request checks made before the account is touched
+func TestDeactivateUserHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		body           string
+		expectedStatus int
+		errorField     string
+	}{
+		{"Invalid ID", "abc", "", http.StatusNotFound, ""},
+		{"Deactivate Yourself", "7", "", http.StatusUnprocessableEntity, "user"},
+		{"Reassign To Same User", "8", `{"reassign_sales_to": 8}`, http.StatusUnprocessableEntity, "reassign_sales_to"},
+		{"Unknown Field", "8", `{"reassign_to": 9}`, http.StatusBadRequest, ""},
+		{"Malformed Body", "8", `{"anonymize": "yes"}`, http.StatusBadRequest, ""},
+	}
+
+	app := newTestApp()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/user/"+tt.id+"/deactivate", strings.NewReader(tt.body))
+			req = app.contextSetUser(req, &data.User{ID: 7})
+			params := httprouter.Params{{Key: "id", Value: tt.id}}
+			req = req.WithContext(context.WithValue(req.Context(), httprouter.ParamsKey, params))
+			rr := httptest.NewRecorder()
+
+			app.deactivateUserHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.errorField != "" {
+				var response struct {
+					Error map[string]string `json:"error"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if _, ok := response.Error[tt.errorField]; !ok {
+					t.Errorf("expected error on field %q, got %v", tt.errorField, response.Error)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+// File: cmd/loadtest/main.go
+// Description: drives a weighted mix of API requests against a running server and reports latency and errors
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Load test configuration settings
+type config struct {
+	target      string        // base URL of the API
+	email       string        // account used for every request
+	password    string        // password of the account
+	duration    time.Duration // how long requests are sent
+	concurrency int           // number of workers sending requests
+	rps         float64       // total requests per second, zero sends as fast as the workers can
+	timeout     time.Duration // longest wait for a single response
+	mix         mix           // weighted actions to pick from
+	productID   int64         // product sold by the sale action
+}
+
+// client holds what every worker shares.
+type client struct {
+	cfg    config
+	http   *http.Client
+	token  string // authentication token from the first login
+	userID int64  // ID of the logged in account, recorded as the seller
+}
+
+func main() {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := &client{cfg: cfg, http: &http.Client{Timeout: cfg.timeout}}
+	if err := c.authenticate(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "login failed:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Sending %s to %s for %s with %d workers\n", cfg.mix, cfg.target, cfg.duration, cfg.concurrency)
+	results := c.run(ctx)
+	printReport(os.Stdout, results)
+}
+
+// loadConfig reads the flags, falling back to LOADTEST_EMAIL and LOADTEST_PASSWORD for the credentials.
+func loadConfig() (config, error) {
+	var cfg config
+	var mixSpec string
+
+	flag.StringVar(&cfg.target, "target", "http://localhost:4000", "Base URL of the API under test")
+	flag.StringVar(&cfg.email, "email", os.Getenv("LOADTEST_EMAIL"), "Email of an activated account")
+	flag.StringVar(&cfg.password, "password", os.Getenv("LOADTEST_PASSWORD"), "Password of the account")
+	flag.DurationVar(&cfg.duration, "duration", 30*time.Second, "How long to send requests")
+	flag.IntVar(&cfg.concurrency, "concurrency", 10, "Number of concurrent workers")
+	flag.Float64Var(&cfg.rps, "rps", 0, "Total requests per second (0 = unlimited)")
+	flag.DurationVar(&cfg.timeout, "timeout", 10*time.Second, "Timeout for a single request")
+	flag.StringVar(&mixSpec, "mix", "login=1,products=6,sales=2,report=1", "Weighted actions: login, products, sales, report")
+	flag.Int64Var(&cfg.productID, "product-id", 1, "Product recorded by the sales action")
+	flag.Parse()
+
+	var err error
+	if cfg.mix, err = parseMix(mixSpec); err != nil {
+		return cfg, err
+	}
+	switch {
+	case cfg.email == "" || cfg.password == "":
+		return cfg, errors.New("-email and -password (or LOADTEST_EMAIL and LOADTEST_PASSWORD) are required")
+	case cfg.duration <= 0:
+		return cfg, errors.New("-duration must be positive")
+	case cfg.concurrency < 1:
+		return cfg, errors.New("-concurrency must be at least 1")
+	case cfg.rps < 0:
+		return cfg, errors.New("-rps must not be negative")
+	}
+	cfg.target = strings.TrimSuffix(cfg.target, "/")
+	return cfg, nil
+}
+
+// authenticate logs in once so the other actions share a token, and looks up the account's ID.
+func (c *client) authenticate(ctx context.Context) error {
+	var login struct {
+		Token string `json:"authentication_token"`
+	}
+	if _, err := c.do(ctx, http.MethodPost, "/v1/tokens/authentication", c.credentials(), &login); err != nil {
+		return err
+	}
+	c.token = login.Token
+
+	var profile struct {
+		User struct {
+			ID int64 `json:"id"`
+		} `json:"user"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/v1/users/profile", nil, &profile); err != nil {
+		return err
+	}
+	c.userID = profile.User.ID
+	return nil
+}
+
+// run sends requests from every worker until the duration ends or ctx is cancelled.
+func (c *client) run(ctx context.Context) *results {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.duration)
+	defer cancel()
+
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if c.cfg.rps > 0 {
+		limiter = rate.NewLimiter(rate.Limit(c.cfg.rps), 1)
+	}
+
+	res := newResults()
+	var wg sync.WaitGroup
+	for range c.cfg.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if err := limiter.Wait(ctx); err != nil {
+					return // the run is over
+				}
+				a := c.cfg.mix.pick(rand.IntN)
+				start := time.Now()
+				status, err := c.action(ctx, a)
+				if ctx.Err() != nil {
+					return // cut off by the end of the run, not a real failure
+				}
+				res.record(a, time.Since(start), status, err)
+			}
+		}()
+	}
+	wg.Wait()
+	res.elapsed = min(time.Since(res.started), c.cfg.duration)
+	return res
+}
+
+// action sends one request of the given kind and returns its status code.
+func (c *client) action(ctx context.Context, a string) (int, error) {
+	switch a {
+	case actionLogin:
+		return c.do(ctx, http.MethodPost, "/v1/tokens/authentication", c.credentials(), nil)
+	case actionProducts:
+		return c.do(ctx, http.MethodGet, "/v1/products?page_size=20", nil, nil)
+	case actionSales:
+		body := map[string]int64{"user_id": c.userID, "product_id": c.cfg.productID, "quantity": 1}
+		return c.do(ctx, http.MethodPost, "/v1/sales", body, nil)
+	case actionReport:
+		// A month of sales aggregated by weekday and hour
+		to := time.Now().UTC()
+		from := to.AddDate(0, -1, 0)
+		path := fmt.Sprintf("/v1/reports/sales/heatmap?from=%s&to=%s", from.Format(time.DateOnly), to.Format(time.DateOnly))
+		return c.do(ctx, http.MethodGet, path, nil, nil)
+	}
+	return 0, fmt.Errorf("unknown action %q", a)
+}
+
+// credentials returns the login request body.
+func (c *client) credentials() map[string]string {
+	return map[string]string{"email": c.cfg.email, "password": c.cfg.password}
+}
+
+// do sends a JSON request with the shared token and decodes a 2xx response into dest when it is not nil.
+// Any other status is returned with an error holding the start of the response body.
+func (c *client) do(ctx context.Context, method, path string, body, dest any) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.cfg.target+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return resp.StatusCode, fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	if dest != nil {
+		return resp.StatusCode, json.NewDecoder(resp.Body).Decode(dest)
+	}
+	_, err = io.Copy(io.Discard, resp.Body) // drain so the connection is reused
+	return resp.StatusCode, err
+}
+
+// statusText names a status code for the report, zero meaning the request never got a response.
+func statusText(status int) string {
+	if status == 0 {
+		return "transport error"
+	}
+	return strconv.Itoa(status)
+}
@@ -0,0 +1,195 @@
+// File: cmd/loadtest/stats.go
+// Description: request mix parsing and latency statistics for the load test
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Actions the load test can send.
+const (
+	actionLogin    = "login"
+	actionProducts = "products"
+	actionSales    = "sales"
+	actionReport   = "report"
+)
+
+var actions = []string{actionLogin, actionProducts, actionSales, actionReport}
+
+// weight is one action of a mix and how often it is picked relative to the others.
+type weight struct {
+	action string
+	weight int
+}
+
+// mix is a weighted list of actions.
+type mix []weight
+
+// parseMix reads a mix such as "login=1,products=6". Actions with weight 0 are left out.
+func parseMix(spec string) (mix, error) {
+	var m mix
+	seen := make(map[string]bool)
+	for part := range strings.SplitSeq(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("mix entry %q must look like action=weight", part)
+		}
+		if !slices.Contains(actions, name) {
+			return nil, fmt.Errorf("unknown action %q in mix, expected one of %s", name, strings.Join(actions, ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("action %q appears twice in mix", name)
+		}
+		seen[name] = true
+
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("weight of %q must be a non-negative integer", name)
+		}
+		if n > 0 {
+			m = append(m, weight{action: name, weight: n})
+		}
+	}
+	if len(m) == 0 {
+		return nil, fmt.Errorf("mix %q has no action with a positive weight", spec)
+	}
+	return m, nil
+}
+
+// pick returns an action with probability proportional to its weight. intN is rand.IntN,
+// passed in so tests can pick deterministically.
+func (m mix) pick(intN func(int) int) string {
+	total := 0
+	for _, w := range m {
+		total += w.weight
+	}
+	n := intN(total)
+	for _, w := range m {
+		if n < w.weight {
+			return w.action
+		}
+		n -= w.weight
+	}
+	return m[len(m)-1].action
+}
+
+// String formats the mix as percentages.
+func (m mix) String() string {
+	total := 0
+	for _, w := range m {
+		total += w.weight
+	}
+	parts := make([]string, len(m))
+	for i, w := range m {
+		parts[i] = fmt.Sprintf("%s %.0f%%", w.action, 100*float64(w.weight)/float64(total))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// stats collects the outcomes of one action.
+type stats struct {
+	latencies []time.Duration
+	errors    int
+	throttled int            // 429 responses, counted apart to see the rate limiters at work
+	statuses  map[string]int // failures by status code
+}
+
+// results collects the stats of every action, safe for concurrent use.
+type results struct {
+	mu      sync.Mutex
+	actions map[string]*stats
+	started time.Time
+	elapsed time.Duration
+}
+
+func newResults() *results {
+	return &results{actions: make(map[string]*stats), started: time.Now()}
+}
+
+// record adds the outcome of one request.
+func (r *results) record(action string, latency time.Duration, status int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.actions[action]
+	if !ok {
+		s = &stats{statuses: make(map[string]int)}
+		r.actions[action] = s
+	}
+	s.latencies = append(s.latencies, latency)
+	if err != nil {
+		s.errors++
+		s.statuses[statusText(status)]++
+		if status == 429 {
+			s.throttled++
+		}
+	}
+}
+
+// percentile returns the latency below which p percent of the sorted latencies fall.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p/100+0.5) - 1
+	return sorted[max(0, min(i, len(sorted)-1))]
+}
+
+// printReport writes a table of throughput, error rate and latency percentiles per action.
+func printReport(w io.Writer, r *results) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "action\trequests\treq/s\terrors\t429s\tp50\tp90\tp99\tmax\t")
+
+	var all []time.Duration
+	var allErrors, allThrottled int
+	for _, action := range slices.Sorted(maps.Keys(r.actions)) {
+		s := r.actions[action]
+		slices.Sort(s.latencies)
+		all = append(all, s.latencies...)
+		allErrors += s.errors
+		allThrottled += s.throttled
+		printRow(tw, action, s.latencies, s.errors, s.throttled, r.elapsed)
+	}
+	slices.Sort(all)
+	printRow(tw, "total", all, allErrors, allThrottled, r.elapsed)
+	tw.Flush()
+
+	for _, action := range slices.Sorted(maps.Keys(r.actions)) {
+		s := r.actions[action]
+		for _, status := range slices.Sorted(maps.Keys(s.statuses)) {
+			fmt.Fprintf(w, "%s failures with %s: %d\n", action, status, s.statuses[status])
+		}
+	}
+}
+
+// printRow writes the statistics of one line of the report.
+func printRow(w io.Writer, name string, sorted []time.Duration, errors, throttled int, elapsed time.Duration) {
+	count := len(sorted)
+	errorRate := 0.0
+	if count > 0 {
+		errorRate = 100 * float64(errors) / float64(count)
+	}
+	throughput := 0.0
+	if elapsed > 0 {
+		throughput = float64(count) / elapsed.Seconds()
+	}
+	fmt.Fprintf(w, "%s\t%d\t%.1f\t%d (%.1f%%)\t%d\t%s\t%s\t%s\t%s\t\n", name, count, throughput, errors, errorRate, throttled,
+		round(percentile(sorted, 50)), round(percentile(sorted, 90)), round(percentile(sorted, 99)), round(percentile(sorted, 100)))
+}
+
+// round trims a latency to a readable precision.
+func round(d time.Duration) time.Duration {
+	if d > time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(10 * time.Microsecond)
+}
@@ -0,0 +1,152 @@
+// File: cmd/loadtest/stats_test.go
+// Description: test suite for the load test mix, statistics and request loop
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestParseMix tests reading weighted action lists
+func TestParseMix(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        string
+		expected    string
+		expectedErr bool
+	}{
+		{"Default Mix", "login=1,products=6,sales=2,report=1", "login 10%, products 60%, sales 20%, report 10%", false},
+		{"Spaces And Zero Weight", " products=1 , sales=0", "products 100%", false},
+		{"Unknown Action", "products=1,checkout=2", "", true},
+		{"Missing Weight", "products", "", true},
+		{"Negative Weight", "products=-1", "", true},
+		{"Duplicate Action", "products=1,products=2", "", true},
+		{"All Zero", "products=0,sales=0", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := parseMix(tt.spec)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error=%v, got %v", tt.expectedErr, err)
+			}
+			if err == nil && m.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, m.String())
+			}
+		})
+	}
+}
+
+// TestMixPick tests that every slot of the weight range maps to its action
+func TestMixPick(t *testing.T) {
+	m := mix{{actionLogin, 1}, {actionProducts, 3}, {actionSales, 2}}
+	expected := []string{actionLogin, actionProducts, actionProducts, actionProducts, actionSales, actionSales}
+	for n, want := range expected {
+		got := m.pick(func(total int) int {
+			if total != 6 {
+				t.Fatalf("expected total weight 6, got %d", total)
+			}
+			return n
+		})
+		if got != want {
+			t.Errorf("slot %d: expected %s, got %s", n, want, got)
+		}
+	}
+}
+
+// TestPercentile tests nearest-rank percentiles
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+
+	tests := []struct {
+		p        float64
+		expected time.Duration
+	}{
+		{50, 50 * time.Millisecond},
+		{90, 90 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+		{0, time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.expected {
+			t.Errorf("p%.0f: expected %s, got %s", tt.p, tt.expected, got)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("expected 0 for no latencies, got %s", got)
+	}
+}
+
+// TestRun tests a short run against a fake API, counting throttled logins as errors
+func TestRun(t *testing.T) {
+	var sales atomic.Int64
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/tokens/authentication", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusTooManyRequests) // logins after the first one are throttled
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"authentication_token": "TOKEN"}`))
+	})
+	mux.HandleFunc("GET /v1/users/profile", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"user": {"id": 42}}`))
+	})
+	mux.HandleFunc("POST /v1/sales", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer TOKEN" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		sales.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sale": {}}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	m, _ := parseMix("login=1,sales=1")
+	c := &client{
+		cfg:  config{target: server.URL, email: "a@example.com", password: "secret", duration: 200 * time.Millisecond, concurrency: 2, rps: 100, mix: m, productID: 1},
+		http: server.Client(),
+	}
+	if err := c.authenticate(context.Background()); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if c.userID != 42 {
+		t.Fatalf("expected user ID 42, got %d", c.userID)
+	}
+
+	res := c.run(context.Background())
+	login, sale := res.actions[actionLogin], res.actions[actionSales]
+	if login == nil || sale == nil {
+		t.Fatalf("expected both actions to run, got %v", res.actions)
+	}
+	if login.errors != len(login.latencies) || login.throttled != login.errors {
+		t.Errorf("expected every login to be throttled, got %d requests, %d errors, %d 429s", len(login.latencies), login.errors, login.throttled)
+	}
+	if sale.errors != 0 || int64(len(sale.latencies)) != sales.Load() {
+		t.Errorf("expected %d successful sales, got %d requests with %d errors", sales.Load(), len(sale.latencies), sale.errors)
+	}
+	if total := len(login.latencies) + len(sale.latencies); total > 25 {
+		t.Errorf("expected -rps to cap the run near 20 requests, got %d", total)
+	}
+
+	var out bytes.Buffer
+	printReport(&out, res)
+	for _, want := range []string{"login", "sales", "total", "login failures with 429"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, out.String())
+		}
+	}
+}
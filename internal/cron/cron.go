@@ -0,0 +1,194 @@
+// File: internal/cron/cron.go
+// Description: parsing of standard five-field cron expressions and working out when they next fire
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// ErrNoNextRun is returned by Next when an expression never fires, such as on the 31st of February.
+var ErrNoNextRun = errors.New("cron: expression never fires")
+
+// macros are the shorthands accepted in place of the five fields.
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field is the range of values one field of an expression takes.
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = [5]field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7}, // 0 and 7 are both Sunday
+}
+
+// Schedule is a parsed cron expression. Each field is a bitmask of the values it matches.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool // the day fields were *, which changes how they combine
+}
+
+// ----------------------------------------------------------------------
+//
+//	Parsing
+//
+// ----------------------------------------------------------------------
+
+// Parse reads a cron expression of five space-separated fields, minute, hour, day of month, month
+// and day of week, or one of the macros such as @daily. Fields take *, single values, ranges (1-5),
+// steps (*/15, 1-30/2) and comma-separated lists of those.
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := macros[expr]; ok {
+		expr = macro
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("cron: expected 5 fields, got %d", len(parts))
+	}
+
+	var masks [5]uint64
+	for i, part := range parts {
+		mask, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, err
+		}
+		masks[i] = mask
+	}
+
+	s := &Schedule{
+		minute:  masks[0],
+		hour:    masks[1],
+		dom:     masks[2],
+		month:   masks[3],
+		dow:     masks[4],
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // Sunday as 7
+	}
+	return s, nil
+}
+
+// parseField turns one field of an expression into a bitmask of the values it matches.
+func parseField(s string, f field) (uint64, error) {
+	var mask uint64
+	for _, item := range strings.Split(s, ",") {
+		rng, stepText, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("cron: invalid step %q in %s field", stepText, f.name)
+			}
+			step = n
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			lowText, highText, _ := strings.Cut(rng, "-")
+			var err error
+			if low, err = parseValue(lowText, f); err != nil {
+				return 0, err
+			}
+			if high, err = parseValue(highText, f); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("cron: range %q in %s field runs backwards", rng, f.name)
+			}
+		default:
+			value, err := parseValue(rng, f)
+			if err != nil {
+				return 0, err
+			}
+			low = value
+			if !hasStep {
+				high = value
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			mask |= 1 << v
+		}
+	}
+	return mask, nil
+}
+
+// parseValue reads a single number of a field and checks it is in range.
+func parseValue(s string, f field) (int, error) {
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("cron: invalid value %q in %s field", s, f.name)
+	}
+	if value < f.min || value > f.max {
+		return 0, fmt.Errorf("cron: %s must be between %d and %d, got %d", f.name, f.min, f.max, value)
+	}
+	return value, nil
+}
+
+// ----------------------------------------------------------------------
+//
+//	Matching
+//
+// ----------------------------------------------------------------------
+
+// Next returns the first minute strictly after t at which the schedule fires, in t's location. It
+// returns ErrNoNextRun when nothing matches within the next five years.
+func (s *Schedule) Next(t time.Time) (time.Time, error) {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrNoNextRun
+}
+
+// dayMatches reports whether the day of t matches. As in cron, when both day fields are
+// restricted a day matching either of them is enough.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,107 @@
+// File: internal/cron/cron_test.go
+// Description: test suite for parsing cron expressions and finding their next run
+
+package cron
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestParse checks which expressions are accepted
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr  string
+		valid bool
+	}{
+		{"* * * * *", true},
+		{"*/15 9-17 * * 1-5", true},
+		{"0 3 1,15 * *", true},
+		{"30 2 * * 7", true},
+		{"@daily", true},
+		{"  @hourly ", true},
+		{"* * * *", false},
+		{"* * * * * *", false},
+		{"60 * * * *", false},
+		{"* 24 * * *", false},
+		{"* * 0 * *", false},
+		{"* * * 13 *", false},
+		{"5-1 * * * *", false},
+		{"*/0 * * * *", false},
+		{"a * * * *", false},
+		{"@often", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			if (err == nil) != tt.valid {
+				t.Errorf("Parse(%q) error = %v, want valid %v", tt.expr, err, tt.valid)
+			}
+		})
+	}
+}
+
+// TestNext checks the next run of expressions from fixed times
+func TestNext(t *testing.T) {
+	from := time.Date(2026, 1, 30, 10, 7, 30, 0, time.UTC) // a Friday
+	tests := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2026, 1, 30, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 1, 30, 10, 15, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2026, 1, 31, 3, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2026, 2, 2, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 0 15 * 1", time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC)}, // either day field matches
+		{"@weekly", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+			next, err := s.Next(from)
+			if err != nil {
+				t.Fatalf("Next: %v", err)
+			}
+			if !next.Equal(tt.expected) {
+				t.Errorf("Next = %v, want %v", next, tt.expected)
+			}
+		})
+	}
+}
+
+// TestNextInLocation checks runs are worked out in the wall clock of the time given
+func TestNextInLocation(t *testing.T) {
+	kolkata := time.FixedZone("IST", 5*60*60+30*60)
+	s, err := Parse("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := s.Next(time.Date(2026, 3, 1, 10, 40, 0, 0, kolkata))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Date(2026, 3, 1, 11, 0, 0, 0, kolkata); !next.Equal(expected) {
+		t.Errorf("Next = %v, want %v", next, expected)
+	}
+}
+
+// TestNextNever checks expressions that cannot fire are reported
+func TestNextNever(t *testing.T) {
+	s, err := Parse("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Next(time.Now()); !errors.Is(err, ErrNoNextRun) {
+		t.Errorf("expected ErrNoNextRun, got %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+// File: internal/data/attachments.go
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// Attachment is a file attached to a sale, such as a photo of a damaged item that was refunded. The
+// file lives in the file store under Key; the row only describes it.
+type Attachment struct {
+	ID          int64     `json:"id"`
+	SaleID      int64     `json:"sale_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"` // sniffed from the contents, never taken from the client
+	Size        int64     `json:"size_bytes"`
+	Key         string    `json:"-"`
+	CreatedBy   *int64    `json:"created_by"`
+	CreatedAt   Timestamp `json:"created_at"`
+}
+
+// MaxSaleAttachments is the most files one sale may have attached.
+const MaxSaleAttachments = 10
+
+// AttachmentTypes are the content types files may have, with the extension they are stored under.
+var AttachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+}
+
+// AttachmentModel wraps a sql.DB connection pool.
+type AttachmentModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// ValidateAttachment checks the name, type and size of an attachment against maxSize bytes.
+func ValidateAttachment(v *validator.Validator, attachment *Attachment, maxSize int64) {
+	v.Check(strings.TrimSpace(attachment.Filename) != "", "filename", "must be provided")
+	v.Check(len(attachment.Filename) <= 255, "filename", "must not be more than 255 bytes long")
+	v.Check(utf8.ValidString(attachment.Filename) && !strings.ContainsAny(attachment.Filename, "/\\\x00"), "filename", "must be a plain file name")
+
+	_, ok := AttachmentTypes[attachment.ContentType]
+	v.Check(ok, "file", "must be a JPEG, PNG, GIF or WebP image, a PDF or plain text")
+
+	v.Check(attachment.Size > 0, "file", "must not be empty")
+	v.Check(attachment.Size <= maxSize, "file", fmt.Sprintf("must not be larger than %d bytes", maxSize))
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// Insert records an attachment whose file has already been stored. The sale is locked meanwhile,
+// so two uploads cannot both take its last free place. It returns ErrRecordNotFound when the sale
+// does not exist and ErrTooManyAttachments when it already has MaxSaleAttachments.
+func (m *AttachmentModel) Insert(attachment *Attachment) error {
+	lockQuery := `
+		SELECT (SELECT COUNT(*) FROM sale_attachments WHERE sale_id = s.id)
+		FROM sales s
+		WHERE s.id = $1
+		FOR UPDATE
+	`
+	query := `
+		INSERT INTO sale_attachments (sale_id, filename, content_type, size_bytes, storage_key, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapError("attachment", "Insert", err)
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRowContext(ctx, lockQuery, attachment.SaleID).Scan(&count); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return wrapError("attachment", "Insert", err)
+	}
+	if count >= MaxSaleAttachments {
+		return ErrTooManyAttachments
+	}
+
+	args := []any{attachment.SaleID, attachment.Filename, attachment.ContentType, attachment.Size, attachment.Key, attachment.CreatedBy}
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&attachment.ID, &attachment.CreatedAt); err != nil {
+		return wrapError("attachment", "Insert", err)
+	}
+	return wrapError("attachment", "Insert", tx.Commit())
+}
+
+// attachmentColumns are the columns scanned by scanAttachment, in order.
+const attachmentColumns = `id, sale_id, filename, content_type, size_bytes, storage_key, created_by, created_at`
+
+// scanAttachment reads a row of attachmentColumns.
+func scanAttachment(row interface{ Scan(...any) error }) (*Attachment, error) {
+	a := &Attachment{}
+	err := row.Scan(&a.ID, &a.SaleID, &a.Filename, &a.ContentType, &a.Size, &a.Key, &a.CreatedBy, &a.CreatedAt)
+	return a, err
+}
+
+// Get retrieves an attachment of a sale by its ID.
+func (m *AttachmentModel) Get(saleID, id int64) (*Attachment, error) {
+	query := `
+		SELECT ` + attachmentColumns + `
+		FROM sale_attachments
+		WHERE id = $1 AND sale_id = $2
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	attachment, err := scanAttachment(m.DB.QueryRowContext(ctx, query, id, saleID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, wrapError("attachment", "Get", err)
+	}
+	return attachment, nil
+}
+
+// GetAllForSale returns the attachments of a sale, oldest first. It returns ErrRecordNotFound when
+// the sale does not exist.
+func (m *AttachmentModel) GetAllForSale(saleID int64) ([]*Attachment, error) {
+	saleQuery := `
+		SELECT EXISTS (SELECT 1 FROM sales WHERE id = $1)
+	`
+	query := `
+		SELECT ` + attachmentColumns + `
+		FROM sale_attachments
+		WHERE sale_id = $1
+		ORDER BY id
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, wrapError("attachment", "GetAllForSale", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, saleQuery, saleID).Scan(&exists); err != nil {
+		return nil, wrapError("attachment", "GetAllForSale", err)
+	}
+	if !exists {
+		return nil, ErrRecordNotFound
+	}
+
+	rows, err := tx.QueryContext(ctx, query, saleID)
+	if err != nil {
+		return nil, wrapError("attachment", "GetAllForSale", err)
+	}
+	defer rows.Close()
+
+	attachments := []*Attachment{}
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, wrapError("attachment", "GetAllForSale", err)
+		}
+		attachments = append(attachments, attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("attachment", "GetAllForSale", err)
+	}
+
+	return attachments, wrapError("attachment", "GetAllForSale", tx.Commit())
+}
+
+// Delete removes an attachment of a sale and returns it, so its file can be removed too. Its file is
+// also queued for the cleanup job in case that fails.
+func (m *AttachmentModel) Delete(saleID, id int64) (*Attachment, error) {
+	query := `
+		DELETE FROM sale_attachments
+		WHERE id = $1 AND sale_id = $2
+		RETURNING id, sale_id, filename, content_type, size_bytes, storage_key, created_by, created_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	attachment, err := scanAttachment(m.DB.QueryRowContext(ctx, query, id, saleID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, wrapError("attachment", "Delete", err)
+	}
+	return attachment, nil
+}
+
+// PendingFileDeletions returns up to limit keys of files whose attachments are gone, oldest first.
+func (m *AttachmentModel) PendingFileDeletions(limit int) ([]string, error) {
+	query := `
+		SELECT storage_key
+		FROM storage_deletions
+		ORDER BY deleted_at
+		LIMIT $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, wrapError("attachment", "PendingFileDeletions", err)
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, wrapError("attachment", "PendingFileDeletions", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, wrapError("attachment", "PendingFileDeletions", rows.Err())
+}
+
+// ForgetFileDeletions drops keys from the pending file deletions once their files are removed.
+func (m *AttachmentModel) ForgetFileDeletions(keys []string) error {
+	query := `
+		DELETE FROM storage_deletions
+		WHERE storage_key = ANY($1)
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, pq.Array(keys))
+	return wrapError("attachment", "ForgetFileDeletions", err)
+}
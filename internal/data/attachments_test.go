@@ -0,0 +1,43 @@
+// File: internal/data/attachments_test.go
+// Description: test suite for validating sale attachments
+
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// TestValidateAttachment tests the name, type and size rules of attachments
+func TestValidateAttachment(t *testing.T) {
+	tests := []struct {
+		name       string
+		attachment Attachment
+		errorField string
+	}{
+		{name: "Photo", attachment: Attachment{Filename: "damaged box.jpg", ContentType: "image/jpeg", Size: 2048}},
+		{name: "Receipt", attachment: Attachment{Filename: "receipt.pdf", ContentType: "application/pdf", Size: 1000}},
+		{name: "No Filename", attachment: Attachment{Filename: " ", ContentType: "image/png", Size: 10}, errorField: "filename"},
+		{name: "Long Filename", attachment: Attachment{Filename: strings.Repeat("a", 256), ContentType: "image/png", Size: 10}, errorField: "filename"},
+		{name: "Path In Filename", attachment: Attachment{Filename: "../photo.png", ContentType: "image/png", Size: 10}, errorField: "filename"},
+		{name: "Disallowed Type", attachment: Attachment{Filename: "page.html", ContentType: "text/html", Size: 10}, errorField: "file"},
+		{name: "Empty", attachment: Attachment{Filename: "empty.txt", ContentType: "text/plain"}, errorField: "file"},
+		{name: "Too Large", attachment: Attachment{Filename: "big.png", ContentType: "image/png", Size: 4097}, errorField: "file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateAttachment(v, &tt.attachment, 4096)
+
+			if tt.errorField == "" && !v.IsValid() {
+				t.Errorf("expected a valid attachment, got %v", v.Errors)
+			}
+			if _, ok := v.Errors[tt.errorField]; tt.errorField != "" && !ok {
+				t.Errorf("expected an error on %s, got %v", tt.errorField, v.Errors)
+			}
+		})
+	}
+}
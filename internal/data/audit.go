@@ -0,0 +1,136 @@
+// File: internal/data/audit.go
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// AuditEntry records a sensitive action taken by a user and the records it touched.
+type AuditEntry struct {
+	ID        int64          `json:"id"`
+	UserID    *int64         `json:"user_id"`
+	Action    string         `json:"action"`
+	Entity    string         `json:"entity"`
+	EntityIDs []int64        `json:"entity_ids"`
+	Details   map[string]any `json:"details"`
+	CreatedAt Timestamp      `json:"created_at"`
+}
+
+// Activity is one entry in a user's recent activity: a sale they recorded or an audited action.
+type Activity struct {
+	Type       string         `json:"type"`
+	Entity     string         `json:"entity"`
+	EntityIDs  []int64        `json:"entity_ids"`
+	Details    map[string]any `json:"details"`
+	OccurredAt Timestamp      `json:"occurred_at"`
+}
+
+// AuditModel wraps a sql.DB connection pool.
+type AuditModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// Insert adds an entry to the audit log.
+func (m *AuditModel) Insert(entry *AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (user_id, action, entity, entity_ids, details, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING id, created_at
+	`
+
+	details := entry.Details
+	if details == nil {
+		details = map[string]any{}
+	}
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return wrapError("audit", "Insert", err)
+	}
+
+	entityIDs := entry.EntityIDs
+	if entityIDs == nil {
+		entityIDs = []int64{}
+	}
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, entry.UserID, entry.Action, entry.Entity, pq.Array(entityIDs), detailsJSON).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// GetActivityForUser returns a page of the sales a user recorded and the audited actions they
+// took, merged into one timeline.
+func (m *AuditModel) GetActivityForUser(userID int64, filter Filter) ([]*Activity, MetaData, error) {
+	sortColumn, err := filter.SortColumn()
+	if err != nil {
+		return nil, MetaData{}, wrapError("audit", "GetActivityForUser", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), type, entity, entity_ids, details, occurred_at
+		FROM (
+			SELECT 'sale_recorded' AS type, 'sales' AS entity, ARRAY[id] AS entity_ids,
+			       jsonb_build_object('user_id', user_id, 'items', (
+			           SELECT jsonb_agg(jsonb_build_object('product_id', i.product_id, 'quantity', i.quantity) ORDER BY i.id)
+			           FROM sale_items i
+			           WHERE i.sale_id = sales.id
+			       )) AS details,
+			       sold_at AS occurred_at
+			FROM sales
+			WHERE created_by = $1
+			UNION ALL
+			SELECT action, entity, entity_ids, details, created_at
+			FROM audit_log
+			WHERE user_id = $1
+		) activity
+		ORDER BY %s %s
+		LIMIT $2 OFFSET $3
+	`, sortColumn, filter.SortDirection())
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filter.Limit(), filter.Offset())
+	if err != nil {
+		return nil, MetaData{}, wrapError("audit", "GetActivityForUser", err)
+	}
+	defer rows.Close()
+
+	activities := []*Activity{}
+	totalRecords := int64(0)
+
+	for rows.Next() {
+		activity := &Activity{}
+		var details []byte
+		if err := rows.Scan(&totalRecords, &activity.Type, &activity.Entity, pq.Array(&activity.EntityIDs), &details, &activity.OccurredAt); err != nil {
+			return nil, MetaData{}, wrapError("audit", "GetActivityForUser", err)
+		}
+		if err := json.Unmarshal(details, &activity.Details); err != nil {
+			return nil, MetaData{}, wrapError("audit", "GetActivityForUser", err)
+		}
+		activities = append(activities, activity)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, MetaData{}, wrapError("audit", "GetActivityForUser", err)
+	}
+
+	return activities, CalculateMetaData(totalRecords, filter), nil
+}
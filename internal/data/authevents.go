@@ -0,0 +1,175 @@
+// File: internal/data/authevents.go
+package data
+
+import (
+	"database/sql"
+	"math"
+	"time"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// Authentication outcomes recorded in the auth events log.
+const (
+	AuthLoginSucceeded         = "login_succeeded"          // a session was issued
+	AuthLoginFailed            = "login_failed"             // wrong email, password or 2FA code
+	AuthLoginThrottled         = "login_throttled"          // refused by the login throttle without checking the password
+	AuthLockout                = "lockout"                  // repeated failures locked out an email address or IP
+	AuthRegistered             = "registered"               // a new account is waiting for activation
+	AuthActivated              = "activated"                // an account was activated with its token
+	AuthPasswordResetRequested = "password_reset_requested" // a reset token was emailed
+	AuthPasswordResetCompleted = "password_reset_completed" // a reset token was used to set a password
+)
+
+// AuthEvents lists the recorded authentication outcomes, in the order they are reported.
+var AuthEvents = []string{
+	AuthLoginSucceeded, AuthLoginFailed, AuthLoginThrottled, AuthLockout,
+	AuthRegistered, AuthActivated, AuthPasswordResetRequested, AuthPasswordResetCompleted,
+}
+
+// authEventRetention is how long auth events are kept before the cleanup job removes them.
+const authEventRetention = 90 * 24 * time.Hour
+
+// AuthReport counts the authentication outcomes of a range of days, per day and in total.
+type AuthReport struct {
+	From     string           `json:"from"`
+	To       string           `json:"to"`
+	Timezone string           `json:"timezone"`
+	Days     []*AuthReportDay `json:"days"`
+	Totals   map[string]int64 `json:"totals"`
+	Rates    AuthReportRates  `json:"rates"`
+}
+
+// AuthReportDay counts the authentication outcomes of one day, by event.
+type AuthReportDay struct {
+	Day    string           `json:"day"`
+	Counts map[string]int64 `json:"counts"`
+}
+
+// AuthReportRates are the ratios derived from the totals of an AuthReport, 0 when nothing was
+// recorded to divide by.
+type AuthReportRates struct {
+	LoginFailure float64 `json:"login_failure"` // failed logins among all checked logins
+	Activation   float64 `json:"activation"`    // activations per registration
+}
+
+// AuthEventModel wraps a sql.DB connection pool.
+type AuthEventModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+	Clock    Clock
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// newAuthCounts returns counts with every event at zero, so reports always list them all.
+func newAuthCounts() map[string]int64 {
+	counts := make(map[string]int64, len(AuthEvents))
+	for _, event := range AuthEvents {
+		counts[event] = 0
+	}
+	return counts
+}
+
+// authRate returns part over whole, rounded to four decimal places, or 0 for an empty whole.
+func authRate(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(whole)*10000) / 10000
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// Insert records an authentication outcome. The user is nil when the attempt named no account.
+func (m *AuthEventModel) Insert(event string, userID *int64, ip string) error {
+	query := `
+		INSERT INTO auth_events (event, user_id, ip, occurred_at)
+		VALUES ($1, $2, $3, NOW())
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, event, userID, ip)
+	return wrapError("auth_event", "Insert", err)
+}
+
+// Report counts the events of the range by day of its timezone and in total.
+func (m *AuthEventModel) Report(r ReportRange) (*AuthReport, error) {
+	query := `
+		SELECT to_char(occurred_at AT TIME ZONE $3, 'YYYY-MM-DD') AS day, event, COUNT(*)
+		FROM auth_events
+		WHERE occurred_at >= $1 AND occurred_at < $2
+		GROUP BY day, event
+		ORDER BY day, event
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReportQuery)
+	defer cancel()
+
+	start, end := r.bounds()
+	rows, err := m.DB.QueryContext(ctx, query, start, end, r.Location.String())
+	if err != nil {
+		return nil, wrapError("auth_event", "Report", err)
+	}
+	defer rows.Close()
+
+	report := &AuthReport{
+		From:     r.From.Format(time.DateOnly),
+		To:       r.To.Format(time.DateOnly),
+		Timezone: r.Location.String(),
+		Days:     []*AuthReportDay{},
+		Totals:   newAuthCounts(),
+	}
+	for rows.Next() {
+		var day, event string
+		var count int64
+		if err := rows.Scan(&day, &event, &count); err != nil {
+			return nil, wrapError("auth_event", "Report", err)
+		}
+		if len(report.Days) == 0 || report.Days[len(report.Days)-1].Day != day {
+			report.Days = append(report.Days, &AuthReportDay{Day: day, Counts: newAuthCounts()})
+		}
+		report.Days[len(report.Days)-1].Counts[event] = count
+		report.Totals[event] += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("auth_event", "Report", err)
+	}
+
+	succeeded, failed := report.Totals[AuthLoginSucceeded], report.Totals[AuthLoginFailed]
+	report.Rates.LoginFailure = authRate(failed, succeeded+failed)
+	report.Rates.Activation = authRate(report.Totals[AuthActivated], report.Totals[AuthRegistered])
+
+	return report, nil
+}
+
+// DeleteExpired removes the events older than the 90 days they are kept for.
+func (m *AuthEventModel) DeleteExpired() (int64, error) {
+	query := `
+		DELETE FROM auth_events
+		WHERE occurred_at < $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, clockNow(m.Clock).Add(-authEventRetention))
+	if err != nil {
+		return 0, wrapError("auth_event", "DeleteExpired", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,154 @@
+// File: internal/data/bootstrap.go
+package data
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// Permission bundles of the built in cashier and guest roles, used when bootstrap finds them missing.
+var (
+	cashierRolePermissions = Permissions{PermissionSaleCreate, PermissionSaleView, PermissionProductCreate, PermissionProductView, PermissionUsersView, PermissionSelfCreate, PermissionSelfView, PermissionSelfUpdate}
+	guestRolePermissions   = Permissions{PermissionProductView, PermissionSelfView}
+)
+
+// ActionBootstrap is the audit log action recorded when the first admin is created.
+const ActionBootstrap = "system:bootstrap"
+
+// BootstrapModel wraps a sql.DB connection pool.
+type BootstrapModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// Completed reports whether the install has been bootstrapped or already has users.
+func (m *BootstrapModel) Completed() (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM bootstrap) OR EXISTS (SELECT 1 FROM users)`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	var completed bool
+	err := m.DB.QueryRowContext(ctx, query).Scan(&completed)
+	return completed, wrapError("bootstrap", "Completed", err)
+}
+
+// Run sets up an empty database in one transaction: it seeds the permission catalog and the built
+// in roles, giving the admin role every permission, creates the user as an active admin and
+// marks bootstrap complete. It returns ErrBootstrapped if that was already done or any user
+// exists, so it can only ever succeed once.
+func (m *BootstrapModel) Run(user *User) error {
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapError("bootstrap", "Run", err)
+	}
+	defer tx.Rollback()
+
+	// Claiming the single bootstrap row first makes concurrent attempts wait for this one, then fail
+	var claimed bool
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO bootstrap (id)
+		SELECT TRUE
+		WHERE NOT EXISTS (SELECT 1 FROM users)
+		ON CONFLICT DO NOTHING
+		RETURNING id`).Scan(&claimed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrBootstrapped
+		}
+		return wrapError("bootstrap", "Run", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO permissions (code)
+		SELECT UNNEST($1::text[])
+		ON CONFLICT (code) DO NOTHING`, pq.Array(PermissionCatalog)); err != nil {
+		return wrapError("bootstrap", "Run", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO roles (name, description, is_system) VALUES
+		('admin', 'Full access to all resources', TRUE),
+		('cashier', 'Records sales and manages products', TRUE),
+		('guest', 'Read only access to products', TRUE)
+		ON CONFLICT (name) DO NOTHING`); err != nil {
+		return wrapError("bootstrap", "Run", err)
+	}
+
+	// The admin role gets every permission; the cashier and guest roles only get their bundles if they have none
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT r.id, p.id
+		FROM roles r
+		INNER JOIN permissions p ON
+			(r.name = 'admin' AND p.code = ANY($1))
+			OR (r.name = 'cashier' AND p.code = ANY($2) AND NOT EXISTS (SELECT 1 FROM role_permissions rp WHERE rp.role_id = r.id))
+			OR (r.name = 'guest' AND p.code = ANY($3) AND NOT EXISTS (SELECT 1 FROM role_permissions rp WHERE rp.role_id = r.id))
+		ON CONFLICT DO NOTHING`, pq.Array(PermissionCatalog), pq.Array(cashierRolePermissions), pq.Array(guestRolePermissions)); err != nil {
+		return wrapError("bootstrap", "Run", err)
+	}
+
+	user.Role = AdminRole
+	user.IsActive = true
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+	if user.Locale == "" {
+		user.Locale = DefaultLocale
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO users (first_name, last_name, email, password_hash, role, is_active, timezone, locale, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
+		RETURNING id, created_at, updated_at, version`,
+		user.FirstName,
+		user.LastName,
+		user.Email,
+		user.Password.hash,
+		user.Role,
+		user.IsActive,
+		user.Timezone,
+		user.Locale,
+	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Version)
+	if err != nil {
+		if pqError, ok := err.(*pq.Error); ok && (pqError.Code == "23514" || pqError.Code == "23502") { // check_violation, not_null_violation
+			return ErrInvalidData
+		}
+		return wrapError("bootstrap", "Run", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO users_permissions (user_id, permission_id)
+		SELECT $1, rp.permission_id
+		FROM role_permissions rp
+		INNER JOIN roles r ON r.id = rp.role_id
+		WHERE r.name = $2
+		ON CONFLICT DO NOTHING`, user.ID, AdminRole); err != nil {
+		return wrapError("bootstrap", "Run", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE bootstrap SET user_id = $1, completed_at = NOW()`, user.ID); err != nil {
+		return wrapError("bootstrap", "Run", err)
+	}
+
+	return wrapError("bootstrap", "Run", tx.Commit())
+}
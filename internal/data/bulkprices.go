@@ -0,0 +1,244 @@
+// File: internal/data/bulkprices.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// ActionBulkPriceUpdate is the audit log action of a committed bulk price update.
+const ActionBulkPriceUpdate = "products:bulk_price"
+
+// MaxPrice is the exclusive upper bound of a price, set by the NUMERIC(10, 2) price column.
+const MaxPrice = 100_000_000
+
+// maxBulkPriceProducts caps how many products one bulk price update may name explicitly.
+const maxBulkPriceProducts = 1000
+
+// ProductPrice sets the price of one product in a bulk price update.
+type ProductPrice struct {
+	ID    int64   `json:"id"`
+	Price float64 `json:"price"`
+}
+
+// PriceRule changes the prices of the unarchived products carrying Tag and, when ProductIDs is
+// given, among those IDs. Percent scales each price (10 is +10%), Amount is added to it in the
+// product's own currency. Results are rounded to cents.
+type PriceRule struct {
+	Tag        string   `json:"tag"`
+	ProductIDs []int64  `json:"product_ids"`
+	Percent    *float64 `json:"percent"`
+	Amount     *float64 `json:"amount"`
+}
+
+// PriceUpdate is a bulk price update, either explicit prices or a rule.
+type PriceUpdate struct {
+	Prices []ProductPrice `json:"prices"`
+	Rule   *PriceRule     `json:"rule"`
+}
+
+// BulkPriceChange is the effect of a bulk price update on one product.
+type BulkPriceChange struct {
+	ID       int64   `json:"id"`
+	Name     string  `json:"name"`
+	Currency string  `json:"currency"`
+	OldPrice float64 `json:"old_price"`
+	NewPrice float64 `json:"new_price"`
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// ValidatePriceUpdate checks that an update has either explicit prices or a rule, and that the
+// prices, selection and adjustment are in range.
+func ValidatePriceUpdate(v *validator.Validator, update *PriceUpdate) {
+	v.Check(len(update.Prices) > 0 || update.Rule != nil, "prices", "must be provided, or a rule instead")
+	v.Check(len(update.Prices) == 0 || update.Rule == nil, "rule", "must not be combined with prices")
+
+	if len(update.Prices) > 0 {
+		v.Check(len(update.Prices) <= maxBulkPriceProducts, "prices", "must not contain more than 1000 products")
+		seen := make(map[int64]bool, len(update.Prices))
+		for _, price := range update.Prices {
+			if price.ID < 1 || seen[price.ID] {
+				v.AddError("prices", "must contain distinct positive product ids")
+				break
+			}
+			seen[price.ID] = true
+			if price.Price < 0 || price.Price >= MaxPrice {
+				v.AddError("prices", "must contain prices between 0 and 99999999.99")
+				break
+			}
+		}
+	}
+
+	if rule := update.Rule; rule != nil {
+		v.Check(rule.Tag != "" || len(rule.ProductIDs) > 0, "rule.tag", "must be provided, or rule.product_ids")
+		if rule.Tag != "" {
+			ValidateTags(v, "rule.tag", []string{rule.Tag})
+		}
+		v.Check(len(rule.ProductIDs) <= maxBulkPriceProducts, "rule.product_ids", "must not contain more than 1000 products")
+		v.Check(!slices.ContainsFunc(rule.ProductIDs, func(id int64) bool { return id < 1 }), "rule.product_ids", "must contain positive product ids")
+
+		v.Check(rule.Percent != nil || rule.Amount != nil, "rule.percent", "must be provided, or rule.amount")
+		v.Check(rule.Percent == nil || rule.Amount == nil, "rule.amount", "must not be combined with rule.percent")
+		if rule.Percent != nil {
+			v.Check(*rule.Percent > -100 && *rule.Percent <= 1000, "rule.percent", "must be greater than -100 and at most 1000")
+		}
+		if rule.Amount != nil {
+			v.Check(*rule.Amount > -MaxPrice && *rule.Amount < MaxPrice, "rule.amount", "must be between -99999999.99 and 99999999.99")
+		}
+	}
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// BulkUpdatePrices applies a validated price update in one transaction, recording every price
+// that changes in the price history. A dry run computes the changes and rolls back. The changes
+// are returned by product ID, including products whose price stays the same.
+//
+// Explicit prices naming unknown or archived products return ErrRecordNotFound, and a rule that
+// takes any price below zero or past MaxPrice returns ErrInvalidData. Both return the changes
+// that were computed, so the caller can tell which products were at fault, and change nothing.
+func (m *ProductModel) BulkUpdatePrices(update *PriceUpdate, userID int64, dryRun bool) ([]*BulkPriceChange, error) {
+	ctx, cancel := m.Timeouts.queryContext(ReportQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, wrapError("product", "BulkUpdatePrices", err)
+	}
+	defer tx.Rollback()
+
+	changes, err := selectPriceChanges(ctx, tx, update)
+	if err != nil {
+		return nil, wrapError("product", "BulkUpdatePrices", err)
+	}
+
+	if len(changes) < len(update.Prices) {
+		return changes, ErrRecordNotFound
+	}
+	for _, change := range changes {
+		if change.NewPrice < 0 || change.NewPrice >= MaxPrice {
+			return changes, ErrInvalidData
+		}
+	}
+	if dryRun {
+		return changes, nil
+	}
+
+	var ids []int64
+	var oldPrices, newPrices []float64
+	for _, change := range changes {
+		if change.OldPrice != change.NewPrice {
+			ids = append(ids, change.ID)
+			oldPrices = append(oldPrices, change.OldPrice)
+			newPrices = append(newPrices, change.NewPrice)
+		}
+	}
+	if len(ids) == 0 {
+		return changes, wrapError("product", "BulkUpdatePrices", tx.Commit())
+	}
+
+	query := `
+		UPDATE products p
+		SET price = c.new_price, updated_by = $3, updated_at = NOW()
+		FROM unnest($1::bigint[], $2::numeric[]) AS c (id, new_price)
+		WHERE p.id = c.id
+	`
+	if _, err := tx.ExecContext(ctx, query, pq.Array(ids), pq.Array(newPrices), userID); err != nil {
+		return nil, wrapError("product", "BulkUpdatePrices", err)
+	}
+
+	query = `
+		INSERT INTO product_price_history (product_id, old_price, new_price, changed_by)
+		SELECT c.id, c.old_price, c.new_price, $4
+		FROM unnest($1::bigint[], $2::numeric[], $3::numeric[]) AS c (id, old_price, new_price)
+	`
+	if _, err := tx.ExecContext(ctx, query, pq.Array(ids), pq.Array(oldPrices), pq.Array(newPrices), userID); err != nil {
+		return nil, wrapError("product", "BulkUpdatePrices", err)
+	}
+
+	return changes, wrapError("product", "BulkUpdatePrices", tx.Commit())
+}
+
+// selectPriceChanges locks the unarchived products an update touches and computes their new prices.
+func selectPriceChanges(ctx context.Context, tx *sql.Tx, update *PriceUpdate) ([]*BulkPriceChange, error) {
+	var rows *sql.Rows
+	var err error
+
+	if update.Rule == nil {
+		ids := make([]int64, len(update.Prices))
+		prices := make([]float64, len(update.Prices))
+		for i, price := range update.Prices {
+			ids[i], prices[i] = price.ID, price.Price
+		}
+
+		query := `
+			SELECT p.id, p.name, p.currency, p.price, ROUND(c.new_price, 2)
+			FROM products p
+			INNER JOIN unnest($1::bigint[], $2::numeric[]) AS c (id, new_price) ON c.id = p.id
+			WHERE NOT p.is_archived
+			ORDER BY p.id
+			FOR UPDATE OF p
+		`
+		rows, err = tx.QueryContext(ctx, query, pq.Array(ids), pq.Array(prices))
+	} else {
+		percent, amount := 0.0, 0.0
+		if update.Rule.Percent != nil {
+			percent = *update.Rule.Percent
+		}
+		if update.Rule.Amount != nil {
+			amount = *update.Rule.Amount
+		}
+
+		var productIDs any
+		if len(update.Rule.ProductIDs) > 0 {
+			productIDs = pq.Array(update.Rule.ProductIDs)
+		}
+
+		query := `
+			SELECT id, name, currency, price, ROUND(price * (1 + $1::numeric / 100) + $2::numeric, 2)
+			FROM products
+			WHERE NOT is_archived
+			  AND ($3 = '' OR $3 = ANY(tags))
+			  AND ($4::bigint[] IS NULL OR id = ANY($4::bigint[]))
+			ORDER BY id
+			FOR UPDATE
+		`
+		rows, err = tx.QueryContext(ctx, query, percent, amount, update.Rule.Tag, productIDs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []*BulkPriceChange{}
+	for rows.Next() {
+		change := &BulkPriceChange{}
+		if err := rows.Scan(&change.ID, &change.Name, &change.Currency, &change.OldPrice, &change.NewPrice); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,348 @@
+// File: internal/data/calendar.go
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// ClockLayout is the format of opening and closing times.
+const ClockLayout = "15:04"
+
+// BusinessHours are the opening hours of one weekday. Opens and Closes are local times, both null
+// for a day that is open all day.
+type BusinessHours struct {
+	Weekday   string    `json:"weekday"` // one of Weekdays
+	Closed    bool      `json:"closed"`
+	Opens     *string   `json:"opens"`  // HH:MM
+	Closes    *string   `json:"closes"` // HH:MM
+	UpdatedBy *int64    `json:"updated_by"`
+	UpdatedAt Timestamp `json:"updated_at"`
+}
+
+// Holiday is a date the business is closed on whatever its weekday.
+type Holiday struct {
+	ID        int64     `json:"id"`
+	Date      string    `json:"date"` // YYYY-MM-DD
+	Name      string    `json:"name"`
+	CreatedBy *int64    `json:"created_by"`
+	CreatedAt Timestamp `json:"created_at"`
+	UpdatedAt Timestamp `json:"updated_at"`
+}
+
+// BusinessCalendar is the week of opening hours and the holidays of a period. A nil calendar, or
+// one without hours, is open every day, all day.
+type BusinessCalendar struct {
+	Hours    []*BusinessHours `json:"hours"`
+	Holidays []*Holiday       `json:"holidays"`
+}
+
+// CalendarModel wraps a sql.DB connection pool.
+type CalendarModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// ValidateBusinessHours checks the weekday and times of a day's opening hours. Times are given
+// together, the opening before the closing, and not at all on a closed day.
+func ValidateBusinessHours(v *validator.Validator, hours *BusinessHours) {
+	v.Check(v.Permitted(hours.Weekday, Weekdays...), "weekday", "must be one of "+strings.Join(Weekdays, ", "))
+	v.Check((hours.Opens == nil) == (hours.Closes == nil), "hours", "opens and closes must be given together")
+	v.Check(!hours.Closed || hours.Opens == nil, "hours", "must not be given for a closed day")
+	if hours.Opens == nil || hours.Closes == nil {
+		return
+	}
+
+	opens, opensErr := time.Parse(ClockLayout, *hours.Opens)
+	v.Check(opensErr == nil, "opens", "must be a time in HH:MM format")
+	closes, closesErr := time.Parse(ClockLayout, *hours.Closes)
+	v.Check(closesErr == nil, "closes", "must be a time in HH:MM format")
+	if opensErr == nil && closesErr == nil {
+		v.Check(opens.Before(closes), "closes", "must be after opens")
+	}
+}
+
+// ValidateHoliday checks the date and name of a holiday.
+func ValidateHoliday(v *validator.Validator, holiday *Holiday) {
+	_, err := time.Parse(time.DateOnly, holiday.Date)
+	v.Check(err == nil, "date", "must be a valid date in YYYY-MM-DD format")
+	v.Check(strings.TrimSpace(holiday.Name) != "", "name", "must be provided")
+	v.Check(len(holiday.Name) <= 100, "name", "must not be more than 100 bytes long")
+}
+
+// weekdayIndex returns the row of day's weekday in Weekdays, 0 for Monday.
+func weekdayIndex(day time.Time) int {
+	return (int(day.Weekday()) + 6) % 7
+}
+
+// hoursOn returns the opening hours of day's weekday, nil when the calendar has none for it.
+func (c *BusinessCalendar) hoursOn(day time.Time) *BusinessHours {
+	if c == nil {
+		return nil
+	}
+	weekday := Weekdays[weekdayIndex(day)]
+	for _, hours := range c.Hours {
+		if hours.Weekday == weekday {
+			return hours
+		}
+	}
+	return nil
+}
+
+// IsOpen reports whether the business opens on the day of day in its location.
+func (c *BusinessCalendar) IsOpen(day time.Time) bool {
+	if c == nil {
+		return true
+	}
+	if hours := c.hoursOn(day); hours != nil && hours.Closed {
+		return false
+	}
+	date := day.Format(time.DateOnly)
+	return !slices.ContainsFunc(c.Holidays, func(h *Holiday) bool { return h.Date == date })
+}
+
+// openFraction returns the part of the opening hours of the day starting at day that has passed
+// by now, from 0 before it opens to 1 after it closes. Closed days count as 0.
+func (c *BusinessCalendar) openFraction(day, now time.Time) float64 {
+	if !c.IsOpen(day) {
+		return 0
+	}
+
+	opens, closes := day, day.AddDate(0, 0, 1)
+	if hours := c.hoursOn(day); hours != nil && hours.Opens != nil && hours.Closes != nil {
+		opens, closes = clockOn(day, *hours.Opens), clockOn(day, *hours.Closes)
+	}
+	return min(max(now.Sub(opens).Hours()/closes.Sub(opens).Hours(), 0), 1)
+}
+
+// clockOn returns the time of an HH:MM clock on the day of day, in its location.
+func clockOn(day time.Time, clock string) time.Time {
+	t, _ := time.Parse(ClockLayout, clock)
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location())
+}
+
+// OpenDays counts the days from start up to end the business opens on, and how many of those
+// have passed by now, with the current day counted by the part of its opening hours gone by.
+// start should be a midnight, and days are those of its location.
+func (c *BusinessCalendar) OpenDays(start, end, now time.Time) (int, float64) {
+	days, elapsed := 0, 0.0
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if !c.IsOpen(day) {
+			continue
+		}
+		days++
+		elapsed += c.openFraction(day, now)
+	}
+	return days, math.Round(elapsed*10) / 10
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// Get returns the opening hours and the holidays from the date of from to the date of to, inclusive.
+func (m *CalendarModel) Get(from, to time.Time) (*BusinessCalendar, error) {
+	hours, err := m.GetHours()
+	if err != nil {
+		return nil, wrapError("calendar", "Get", err)
+	}
+	holidays, err := m.GetHolidays(from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, wrapError("calendar", "Get", err)
+	}
+	return &BusinessCalendar{Hours: hours, Holidays: holidays}, nil
+}
+
+// GetHours returns the opening hours of every weekday, Monday first.
+func (m *CalendarModel) GetHours() ([]*BusinessHours, error) {
+	query := `
+		SELECT weekday, closed, to_char(opens, 'HH24:MI'), to_char(closes, 'HH24:MI'), updated_by, updated_at
+		FROM business_hours
+		ORDER BY weekday
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, wrapError("calendar", "GetHours", err)
+	}
+	defer rows.Close()
+
+	week := []*BusinessHours{}
+	for rows.Next() {
+		var weekday int
+		hours := &BusinessHours{}
+		if err := rows.Scan(&weekday, &hours.Closed, &hours.Opens, &hours.Closes, &hours.UpdatedBy, &hours.UpdatedAt); err != nil {
+			return nil, wrapError("calendar", "GetHours", err)
+		}
+		hours.Weekday = Weekdays[weekday]
+		week = append(week, hours)
+	}
+	return week, wrapError("calendar", "GetHours", rows.Err())
+}
+
+// UpdateHours saves the opening hours of the given weekdays in one transaction, leaving the other
+// days as they are. UpdatedBy is recorded as the user who made the change.
+func (m *CalendarModel) UpdateHours(week []*BusinessHours) error {
+	query := `
+		UPDATE business_hours
+		SET closed = $2, opens = $3::time, closes = $4::time, updated_by = $5, updated_at = NOW()
+		WHERE weekday = $1
+		RETURNING updated_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapError("calendar", "UpdateHours", err)
+	}
+	defer tx.Rollback()
+
+	for _, hours := range week {
+		weekday := slices.Index(Weekdays, hours.Weekday)
+		if err := tx.QueryRowContext(ctx, query, weekday, hours.Closed, hours.Opens, hours.Closes, hours.UpdatedBy).Scan(&hours.UpdatedAt); err != nil {
+			return wrapError("calendar", "UpdateHours", err)
+		}
+	}
+	return wrapError("calendar", "UpdateHours", tx.Commit())
+}
+
+// GetHolidays returns the holidays from one YYYY-MM-DD date to another, inclusive, earliest first.
+func (m *CalendarModel) GetHolidays(from, to string) ([]*Holiday, error) {
+	query := `
+		SELECT id, to_char(date, 'YYYY-MM-DD'), name, created_by, created_at, updated_at
+		FROM business_holidays
+		WHERE date BETWEEN $1::date AND $2::date
+		ORDER BY date
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, wrapError("calendar", "GetHolidays", err)
+	}
+	defer rows.Close()
+
+	holidays := []*Holiday{}
+	for rows.Next() {
+		holiday := &Holiday{}
+		if err := rows.Scan(&holiday.ID, &holiday.Date, &holiday.Name, &holiday.CreatedBy, &holiday.CreatedAt, &holiday.UpdatedAt); err != nil {
+			return nil, wrapError("calendar", "GetHolidays", err)
+		}
+		holidays = append(holidays, holiday)
+	}
+	return holidays, wrapError("calendar", "GetHolidays", rows.Err())
+}
+
+// GetHoliday retrieves a holiday by its ID.
+func (m *CalendarModel) GetHoliday(id int64) (*Holiday, error) {
+	query := `
+		SELECT id, to_char(date, 'YYYY-MM-DD'), name, created_by, created_at, updated_at
+		FROM business_holidays
+		WHERE id = $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	holiday := &Holiday{}
+	if err := m.DB.QueryRowContext(ctx, query, id).Scan(&holiday.ID, &holiday.Date, &holiday.Name, &holiday.CreatedBy, &holiday.CreatedAt, &holiday.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, wrapError("calendar", "GetHoliday", err)
+	}
+	return holiday, nil
+}
+
+// InsertHoliday adds a holiday. It returns ErrDuplicateHoliday when the date already is one.
+func (m *CalendarModel) InsertHoliday(holiday *Holiday) error {
+	query := `
+		INSERT INTO business_holidays (date, name, created_by)
+		VALUES ($1::date, $2, $3)
+		RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, holiday.Date, holiday.Name, holiday.CreatedBy).Scan(&holiday.ID, &holiday.CreatedAt, &holiday.UpdatedAt)
+	if pqError, ok := err.(*pq.Error); ok && pqError.Code == "23505" { // unique_violation
+		return ErrDuplicateHoliday
+	}
+	return wrapError("calendar", "InsertHoliday", err)
+}
+
+// UpdateHoliday saves the date and name of a holiday. It returns ErrRecordNotFound when the
+// holiday is gone and ErrDuplicateHoliday when the new date already is one.
+func (m *CalendarModel) UpdateHoliday(holiday *Holiday) error {
+	query := `
+		UPDATE business_holidays
+		SET date = $1::date, name = $2, updated_at = NOW()
+		WHERE id = $3
+		RETURNING updated_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, holiday.Date, holiday.Name, holiday.ID).Scan(&holiday.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRecordNotFound
+	}
+	if pqError, ok := err.(*pq.Error); ok && pqError.Code == "23505" { // unique_violation
+		return ErrDuplicateHoliday
+	}
+	return wrapError("calendar", "UpdateHoliday", err)
+}
+
+// DeleteHoliday removes a holiday, returning ErrRecordNotFound when there is none with the ID.
+func (m *CalendarModel) DeleteHoliday(id int64) error {
+	query := `
+		DELETE FROM business_holidays
+		WHERE id = $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return wrapError("calendar", "DeleteHoliday", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return wrapError("calendar", "DeleteHoliday", err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// File: internal/data/calendar_test.go
+// Description: test suite for opening hours, holidays and open day counting
+
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// TestValidateBusinessHours tests the weekday and opening time rules of a day's hours
+func TestValidateBusinessHours(t *testing.T) {
+	clock := func(s string) *string { return &s }
+	tests := []struct {
+		name       string
+		hours      BusinessHours
+		errorField string
+	}{
+		{name: "Open All Day", hours: BusinessHours{Weekday: "Monday"}},
+		{name: "Opening Hours", hours: BusinessHours{Weekday: "Friday", Opens: clock("08:30"), Closes: clock("17:00")}},
+		{name: "Closed", hours: BusinessHours{Weekday: "Sunday", Closed: true}},
+		{name: "Unknown Weekday", hours: BusinessHours{Weekday: "monday"}, errorField: "weekday"},
+		{name: "Opens Only", hours: BusinessHours{Weekday: "Monday", Opens: clock("08:00")}, errorField: "hours"},
+		{name: "Hours On Closed Day", hours: BusinessHours{Weekday: "Sunday", Closed: true, Opens: clock("08:00"), Closes: clock("12:00")}, errorField: "hours"},
+		{name: "Bad Time", hours: BusinessHours{Weekday: "Monday", Opens: clock("8am"), Closes: clock("17:00")}, errorField: "opens"},
+		{name: "Closes Before Opening", hours: BusinessHours{Weekday: "Monday", Opens: clock("17:00"), Closes: clock("08:00")}, errorField: "closes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateBusinessHours(v, &tt.hours)
+
+			if tt.errorField == "" && !v.IsValid() {
+				t.Errorf("expected valid hours, got %v", v.Errors)
+			}
+			if _, ok := v.Errors[tt.errorField]; tt.errorField != "" && !ok {
+				t.Errorf("expected an error on %s, got %v", tt.errorField, v.Errors)
+			}
+		})
+	}
+}
+
+// TestBusinessCalendarOpenDays tests counting open days and the opening hours passed on the current day
+func TestBusinessCalendarOpenDays(t *testing.T) {
+	opens, closes := "09:00", "17:00"
+	cal := &BusinessCalendar{
+		Hours: []*BusinessHours{
+			{Weekday: "Monday", Opens: &opens, Closes: &closes},
+			{Weekday: "Saturday", Closed: true},
+			{Weekday: "Sunday", Closed: true},
+		},
+		Holidays: []*Holiday{{Date: "2025-06-04", Name: "Closed for stocktake"}},
+	}
+	start := time.Date(2025, time.June, 2, 0, 0, 0, 0, time.UTC) // a Monday
+	end := start.AddDate(0, 0, 7)
+
+	tests := []struct {
+		name    string
+		now     time.Time
+		elapsed float64
+	}{
+		{name: "Before Opening", now: start.Add(8 * time.Hour), elapsed: 0},
+		{name: "Halfway Through Opening Hours", now: start.Add(13 * time.Hour), elapsed: 0.5},
+		{name: "Holiday Passes Without Counting", now: start.AddDate(0, 0, 3), elapsed: 2},
+		{name: "Weekend", now: start.AddDate(0, 0, 6), elapsed: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			days, elapsed := cal.OpenDays(start, end, tt.now)
+			if days != 4 || elapsed != tt.elapsed {
+				t.Errorf("expected %v of 4 open days, got %v of %d", tt.elapsed, elapsed, days)
+			}
+		})
+	}
+
+	if days, elapsed := (*BusinessCalendar)(nil).OpenDays(start, end, end); days != 7 || elapsed != 7 {
+		t.Errorf("expected a nil calendar open every day, got %v of %d", elapsed, days)
+	}
+}
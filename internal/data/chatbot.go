@@ -10,6 +10,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 )
 
@@ -28,6 +29,7 @@ var (
 	- Answer questions using the data provided above
 	- Be conversational and helpful
 	- Do any calculations needed (totals, averages, trends, etc.)
+	- Leave days the business calendar has closed (closed weekdays and holidays) out of daily averages
 	- If asked about restricted data, politely explain the limitation
 	- Keep responses under 300 words
 	- The current time is: %s
@@ -52,6 +54,10 @@ var (
 
 	// AI Service URL
 	aiServiceURL = "https://models.inference.ai.azure.com/chat/completions"
+
+	// Retries after a 429 from the AI service, and the longest Retry-After honoured
+	aiMaxRetries    = 2
+	aiMaxRetryAfter = 5 * time.Second
 )
 
 type GitHubMessage struct {
@@ -76,18 +82,56 @@ type GitHubChatResponse struct {
 type ChatResponse struct {
 	Response  string                 `json:"response"`
 	Data      map[string]interface{} `json:"data,omitempty"`
-	Timestamp time.Time              `json:"timestamp"`
+	Timestamp Timestamp              `json:"timestamp"`
 	Type      string                 `json:"type"`
 }
 
 // ChatbotModel wraps database connection
 type ChatbotModel struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Timeouts Timeouts
+	Clock    Clock
+
+	// AI provider settings, left empty for the GitHub Models endpoint, the GITHUB_TOKEN
+	// environment variable and a client with a 12 second timeout
+	ServiceURL string
+	Token      string
+	Client     *http.Client
+}
+
+// serviceURL returns the chat completions endpoint.
+func (m *ChatbotModel) serviceURL() string {
+	if m.ServiceURL != "" {
+		return m.ServiceURL
+	}
+	return aiServiceURL
+}
+
+// token returns the AI provider token, empty when the AI is not configured.
+func (m *ChatbotModel) token() string {
+	if m.Token != "" {
+		return m.Token
+	}
+	return os.Getenv("GITHUB_TOKEN")
+}
+
+// Configured reports whether an AI provider token is set. Without one every message gets the
+// fallback response.
+func (m *ChatbotModel) Configured() bool {
+	return m.token() != ""
+}
+
+// client returns the HTTP client for the AI provider.
+func (m *ChatbotModel) client() *http.Client {
+	if m.Client != nil {
+		return m.Client
+	}
+	return &http.Client{Timeout: 12 * time.Second}
 }
 
 // ProcessMessage handles the user's message and returns a response
 func (m *ChatbotModel) ProcessMessage(message string, user *User) (*ChatResponse, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(ReportQuery)
 	defer cancel()
 
 	fmt.Printf("Processing: '%s' for %s (%s)\n", message, user.Email, user.Role)
@@ -96,12 +140,11 @@ func (m *ChatbotModel) ProcessMessage(message string, user *User) (*ChatResponse
 	rawData, err := m.getRawDataForUser(user)
 	if err != nil {
 		fmt.Printf("Failed to get raw data: %v\n", err)
-		return nil, err
+		return nil, wrapError("chatbot", "ProcessMessage", err)
 	}
 
 	// Check GitHub token first
-	githubToken := os.Getenv("GITHUB_TOKEN")
-	if githubToken == "" {
+	if m.token() == "" {
 		fmt.Println("GITHUB_TOKEN not set, using fallback response")
 		return m.createFallbackResponse(message, user, rawData), nil
 	}
@@ -122,9 +165,10 @@ func (m *ChatbotModel) getRawDataForUser(user *User) (map[string]interface{}, er
 	data := make(map[string]interface{})
 
 	// Initialize models
-	productModel := ProductModel{DB: m.DB}
-	saleModel := SaleModel{DB: m.DB}
-	userModel := UserModel{DB: m.DB}
+	productModel := ProductModel{DB: m.DB, Timeouts: m.Timeouts}
+	saleModel := SaleModel{DB: m.DB, Timeouts: m.Timeouts}
+	userModel := UserModel{DB: m.DB, Timeouts: m.Timeouts}
+	calendarModel := CalendarModel{DB: m.DB, Timeouts: m.Timeouts}
 
 	// Everyone can see products
 	// Use a large page size to get all products for the context
@@ -141,6 +185,14 @@ func (m *ChatbotModel) getRawDataForUser(user *User) (map[string]interface{}, er
 		data["products"] = products
 	}
 
+	// Everyone can see when the business opens, the last month of holidays included so closed
+	// days can be left out of averages over recent sales
+	today := clockNow(m.Clock).In(user.Location())
+	calendar, err := calendarModel.Get(today.AddDate(0, 0, -31), today.AddDate(0, 0, 90))
+	if err == nil {
+		data["business_calendar"] = calendar
+	}
+
 	// Cashiers and admins can see sales
 	if user.Role == "cashier" || user.Role == "admin" {
 		saleFilter := SaleFilter{
@@ -176,7 +228,7 @@ func (m *ChatbotModel) getRawDataForUser(user *User) (map[string]interface{}, er
 	}
 
 	data["current_user_role"] = user.Role
-	data["current_time"] = time.Now().Format("2006-01-02 15:04:05")
+	data["current_time"] = clockNow(m.Clock).UTC().Format(time.RFC3339)
 
 	return data, nil
 }
@@ -185,7 +237,6 @@ func (m *ChatbotModel) getRawDataForUser(user *User) (map[string]interface{}, er
 func (m *ChatbotModel) callGitHubAI(ctx context.Context, message string, user *User, rawData map[string]interface{}) (*ChatResponse, error) {
 	systemPrompt := m.buildSimplePrompt(user.Role, rawData)
 
-	githubToken := os.Getenv("GITHUB_TOKEN")
 	request := GitHubChatRequest{
 		Messages: []GitHubMessage{
 			{Role: "system", Content: systemPrompt},
@@ -201,24 +252,37 @@ func (m *ChatbotModel) callGitHubAI(ctx context.Context, message string, user *U
 		return nil, fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", aiServiceURL, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
-	}
+	client := m.client()
+	var resp *http.Response
+	var body []byte
+	for attempt := 0; ; attempt++ {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", m.serviceURL(), bytes.NewReader(jsonBody))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create HTTP request: %v", err)
+		}
 
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+githubToken)
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer "+m.token())
 
-	client := &http.Client{Timeout: 12 * time.Second}
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %v", err)
-	}
-	defer resp.Body.Close()
+		resp, err = client.Do(httpReq)
+		if err != nil {
+			return nil, fmt.Errorf("HTTP request failed: %v", err)
+		}
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %v", err)
+		}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
+		// Rate limited requests are retried a few times after the wait the service asks for
+		if resp.StatusCode != http.StatusTooManyRequests || attempt == aiMaxRetries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("AI service rate limited: %v", ctx.Err())
+		case <-time.After(aiRetryAfter(resp.Header.Get("Retry-After"))):
+		}
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -238,12 +302,22 @@ func (m *ChatbotModel) callGitHubAI(ctx context.Context, message string, user *U
 
 	return &ChatResponse{
 		Response:  aiResponseText,
-		Timestamp: time.Now(),
+		Timestamp: Now(),
 		Type:      "ai",
 		Data:      map[string]interface{}{"role": user.Role},
 	}, nil
 }
 
+// aiRetryAfter reads the seconds to wait from a Retry-After header, defaulting to one second
+// and capped at aiMaxRetryAfter.
+func aiRetryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return time.Second
+	}
+	return min(time.Duration(seconds)*time.Second, aiMaxRetryAfter)
+}
+
 // buildSimplePrompt creates system prompt with raw data
 func (m *ChatbotModel) buildSimplePrompt(userRole string, rawData map[string]interface{}) string {
 	dataJSON, _ := json.MarshalIndent(rawData, "", "  ")
@@ -296,7 +370,7 @@ func (m *ChatbotModel) createFallbackResponse(message string, user *User, rawDat
 
 	return &ChatResponse{
 		Response:  response,
-		Timestamp: time.Now(),
+		Timestamp: Now(),
 		Type:      "fallback",
 		Data:      map[string]interface{}{"fallback": true},
 	}
@@ -0,0 +1,196 @@
+// File: internal/data/chatbot_test.go
+// Description: contract tests for the chatbot's AI provider, run against a mock chat completions server
+
+package data
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newMockAIServer serves chat completions with the given handler and returns a chatbot pointed at it
+func newMockAIServer(t *testing.T, handler http.HandlerFunc) *ChatbotModel {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return &ChatbotModel{
+		Timeouts:   DefaultTimeouts,
+		ServiceURL: server.URL,
+		Token:      "test-token",
+		Client:     &http.Client{Timeout: time.Second},
+	}
+}
+
+// writeCompletion answers with a single choice
+func writeCompletion(w http.ResponseWriter, content string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": content}}},
+	})
+}
+
+// TestCallGitHubAIRequestShape checks the endpoint, headers and body sent to the provider
+func TestCallGitHubAIRequestShape(t *testing.T) {
+	chatbot := newMockAIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST, got %s", r.Method)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+			t.Errorf("expected bearer token, got %q", got)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("expected JSON content type, got %q", got)
+		}
+
+		var req GitHubChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		if req.Model != model || req.MaxTokens != maxTokens || req.Temperature != temperature {
+			t.Errorf("unexpected model settings: %+v", req)
+		}
+		if len(req.Messages) != 2 || req.Messages[0].Role != "system" || req.Messages[1].Role != "user" {
+			t.Fatalf("expected a system and a user message, got %+v", req.Messages)
+		}
+		if req.Messages[1].Content != "How many sales today?" {
+			t.Errorf("unexpected user message %q", req.Messages[1].Content)
+		}
+		if !strings.Contains(req.Messages[0].Content, "USER ROLE: cashier") {
+			t.Errorf("expected the system prompt to carry the role, got %q", req.Messages[0].Content)
+		}
+
+		writeCompletion(w, "You made 3 sales today.")
+	})
+
+	user := &User{Role: "cashier"}
+	resp, err := chatbot.callGitHubAI(t.Context(), "How many sales today?", user, map[string]interface{}{"current_time": "now"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Response != "You made 3 sales today." || resp.Type != "ai" || resp.Data["role"] != "cashier" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+// TestCallGitHubAIErrors checks that provider failures surface as errors, so ProcessMessage falls back
+func TestCallGitHubAIErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		errText string
+	}{
+		{
+			name: "Server Error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "upstream failure", http.StatusInternalServerError)
+			},
+			errText: "API error 500",
+		},
+		{
+			name: "Unauthorized",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "bad credentials", http.StatusUnauthorized)
+			},
+			errText: "API error 401",
+		},
+		{
+			name: "Malformed JSON",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"choices": [`))
+			},
+			errText: "failed to parse response JSON",
+		},
+		{
+			name: "No Choices",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"choices": []}`))
+			},
+			errText: "no response choices",
+		},
+		{
+			name: "Timeout",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(500 * time.Millisecond):
+				}
+			},
+			errText: "HTTP request failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chatbot := newMockAIServer(t, tt.handler)
+			chatbot.Client = &http.Client{Timeout: 100 * time.Millisecond}
+
+			_, err := chatbot.callGitHubAI(t.Context(), "hello", &User{Role: "guest"}, map[string]interface{}{})
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("expected an error containing %q, got %v", tt.errText, err)
+			}
+		})
+	}
+}
+
+// TestCallGitHubAIRetriesRateLimit checks that a 429 is retried and gives up after aiMaxRetries
+func TestCallGitHubAIRetriesRateLimit(t *testing.T) {
+	t.Run("Recovers", func(t *testing.T) {
+		var calls atomic.Int32
+		chatbot := newMockAIServer(t, func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.Header().Set("Retry-After", "0")
+				http.Error(w, "slow down", http.StatusTooManyRequests)
+				return
+			}
+			writeCompletion(w, "done")
+		})
+
+		resp, err := chatbot.callGitHubAI(t.Context(), "hello", &User{Role: "guest"}, map[string]interface{}{})
+		if err != nil || resp.Response != "done" {
+			t.Fatalf("expected the retry to succeed, got %+v, %v", resp, err)
+		}
+		if calls.Load() != 2 {
+			t.Errorf("expected 2 calls, got %d", calls.Load())
+		}
+	})
+
+	t.Run("Gives Up", func(t *testing.T) {
+		var calls atomic.Int32
+		chatbot := newMockAIServer(t, func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		})
+
+		_, err := chatbot.callGitHubAI(t.Context(), "hello", &User{Role: "guest"}, map[string]interface{}{})
+		if err == nil || !strings.Contains(err.Error(), "API error 429") {
+			t.Errorf("expected a 429 error, got %v", err)
+		}
+		if int(calls.Load()) != aiMaxRetries+1 {
+			t.Errorf("expected %d calls, got %d", aiMaxRetries+1, calls.Load())
+		}
+	})
+}
+
+// TestAIRetryAfter checks parsing and capping of the Retry-After header
+func TestAIRetryAfter(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":     time.Second,
+		"soon": time.Second,
+		"-1":   time.Second,
+		"0":    0,
+		"2":    2 * time.Second,
+		"600":  aiMaxRetryAfter,
+	}
+	for header, expected := range tests {
+		if got := aiRetryAfter(header); got != expected {
+			t.Errorf("Retry-After %q: expected %s, got %s", header, expected, got)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// File: internal/data/clock_test.go
+// Description: test suite for the injectable clock
+
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+// TestFrozenClock tests that a frozen clock only moves when set or advanced, and that models
+// without a clock fall back to the real time
+func TestFrozenClock(t *testing.T) {
+	start := time.Date(2025, 3, 14, 15, 30, 0, 0, time.UTC)
+	clock := NewFrozenClock(start)
+
+	if got := clock.Now(); !got.Equal(start) {
+		t.Fatalf("expected %v, got %v", start, got)
+	}
+	clock.Advance(90 * time.Minute)
+	if got, want := clock.Now(), start.Add(90*time.Minute); !got.Equal(want) {
+		t.Errorf("expected %v after advancing, got %v", want, got)
+	}
+	clock.Set(start)
+	if got := clockNow(clock); !got.Equal(start) {
+		t.Errorf("expected %v after setting, got %v", start, got)
+	}
+
+	before := time.Now()
+	if got := clockNow(nil); got.Before(before) || got.After(time.Now()) {
+		t.Errorf("expected the real time without a clock, got %v", got)
+	}
+}
+
+// TestGenerateTokenExpiry tests that tokens expire exactly their lifetime after the clock's time
+func TestGenerateTokenExpiry(t *testing.T) {
+	clock := NewFrozenClock(time.Date(2025, 3, 14, 15, 30, 0, 0, time.UTC))
+
+	token, err := generateToken(7, 24*time.Hour, ScopeActivation, clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, 3, 15, 15, 30, 0, 0, time.UTC); !token.ExpiresAt.Equal(want) {
+		t.Errorf("expected the token to expire at %v, got %v", want, token.ExpiresAt.Time)
+	}
+
+	clock.Advance(24*time.Hour - time.Second)
+	if !clock.Now().Before(token.ExpiresAt.Time) {
+		t.Error("expected the token to be valid a second before it expires")
+	}
+	clock.Advance(time.Second)
+	if clock.Now().Before(token.ExpiresAt.Time) {
+		t.Error("expected the token to have expired at its expiry time")
+	}
+}
+
+// TestSessionTouchDue tests that a session is only recorded as used again once the last use is
+// older than the resolution of the session list
+func TestSessionTouchDue(t *testing.T) {
+	now := time.Date(2025, 3, 14, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		usedAt   time.Time
+		expected bool
+	}{
+		{name: "Never Used", expected: true},
+		{name: "Just Used", usedAt: now.Add(-time.Second), expected: false},
+		{name: "Used Within Resolution", usedAt: now.Add(-sessionLastUsedResolution + time.Second), expected: false},
+		{name: "Used At Resolution", usedAt: now.Add(-sessionLastUsedResolution), expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{SessionUsedAt: NewTimestamp(tt.usedAt)}
+			if got := user.SessionTouchDue(now); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
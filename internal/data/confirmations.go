@@ -0,0 +1,126 @@
+// File: internal/data/confirmations.go
+package data
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// Actions that require a confirmation token before they run.
+const (
+	ActionBulkDeleteSales  = "sales:bulk_delete"
+	ActionBulkArchiveSales = "sales:bulk_archive"
+)
+
+// BulkConfirmation is a short lived, single use token that confirms a previewed bulk operation.
+// The affected IDs are stored with it so the confirmed run touches exactly what was previewed.
+type BulkConfirmation struct {
+	Plaintext string    `json:"token"`
+	Hash      []byte    `json:"-"`
+	UserID    int64     `json:"-"`
+	Action    string    `json:"action"`
+	EntityIDs []int64   `json:"ids"`
+	ExpiresAt Timestamp `json:"expires_at"`
+}
+
+// ConfirmationModel wraps a sql.DB connection pool.
+type ConfirmationModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+	Clock    Clock
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// New stores a confirmation for the given action and IDs and returns it with its plaintext token.
+func (m *ConfirmationModel) New(userID int64, action string, ids []int64, ttl time.Duration) (*BulkConfirmation, error) {
+	token, err := generateToken(userID, ttl, action, clockNow(m.Clock))
+	if err != nil {
+		return nil, wrapError("confirmation", "New", err)
+	}
+
+	confirmation := &BulkConfirmation{
+		Plaintext: token.Plaintext,
+		Hash:      token.Hash,
+		UserID:    userID,
+		Action:    action,
+		EntityIDs: ids,
+		ExpiresAt: token.ExpiresAt,
+	}
+
+	query := `
+		INSERT INTO bulk_confirmations (hash, user_id, action, entity_ids, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	if _, err := m.DB.ExecContext(ctx, query, confirmation.Hash, userID, action, pq.Array(ids), confirmation.ExpiresAt); err != nil {
+		return nil, wrapError("confirmation", "New", err)
+	}
+
+	return confirmation, nil
+}
+
+// Consume looks up and deletes an unexpired confirmation issued to the user for the action.
+// It returns ErrInvalidToken when no such confirmation exists.
+func (m *ConfirmationModel) Consume(userID int64, action, plaintext string) (*BulkConfirmation, error) {
+	query := `
+		DELETE FROM bulk_confirmations
+		WHERE hash = $1 AND user_id = $2 AND action = $3 AND expires_at > NOW()
+		RETURNING entity_ids, expires_at
+	`
+
+	hash := sha256.Sum256([]byte(plaintext))
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	confirmation := &BulkConfirmation{
+		Hash:   hash[:],
+		UserID: userID,
+		Action: action,
+	}
+
+	err := m.DB.QueryRowContext(ctx, query, hash[:], userID, action).Scan(pq.Array(&confirmation.EntityIDs), &confirmation.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, wrapError("confirmation", "Consume", err)
+	}
+
+	return confirmation, nil
+}
+
+// DeleteExpired removes the confirmations that expired unused, returning how many there were.
+func (m *ConfirmationModel) DeleteExpired() (int64, error) {
+	query := `
+		DELETE FROM bulk_confirmations
+		WHERE expires_at <= NOW()
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query)
+	if err != nil {
+		return 0, wrapError("confirmation", "DeleteExpired", err)
+	}
+	return result.RowsAffected()
+}
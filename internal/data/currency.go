@@ -0,0 +1,183 @@
+// File: internal/data/currency.go
+package data
+
+import (
+	"database/sql"
+	"math"
+	"regexp"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// DefaultCurrency is the base currency when none is configured, and the currency of the products
+// that existed before prices had one.
+const DefaultCurrency = "USD"
+
+// CurrencyRX matches ISO 4217 style currency codes.
+var CurrencyRX = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ExchangeRate is the number of units of a currency that one unit of the base currency buys.
+// The base currency itself has no row, its rate is always 1.
+type ExchangeRate struct {
+	Currency  string    `json:"currency"`
+	Rate      float64   `json:"rate"`
+	UpdatedBy *int64    `json:"updated_by"`
+	UpdatedAt Timestamp `json:"updated_at"`
+}
+
+// Conversion converts amounts from any currency with a known rate into one target currency.
+type Conversion struct {
+	Base     string             // the currency rates are quoted against
+	Currency string             // the currency amounts are converted into
+	factors  map[string]float64 // multiplier from each known currency into Currency
+}
+
+// ExchangeRateModel wraps a sql.DB connection pool.
+type ExchangeRateModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// ValidateCurrency checks that a currency code is three upper case letters.
+func ValidateCurrency(v *validator.Validator, key, currency string) {
+	v.Check(v.Matches(currency, CurrencyRX), key, "must be a three letter currency code such as USD")
+}
+
+// ValidateExchangeRate checks the currency and rate of an exchange rate.
+func ValidateExchangeRate(v *validator.Validator, rate *ExchangeRate) {
+	ValidateCurrency(v, "currency", rate.Currency)
+	v.Check(rate.Rate > 0, "rate", "must be greater than zero")
+	v.Check(rate.Rate < 1e12, "rate", "must be less than 1000000000000")
+}
+
+// NewConversion builds the conversion into target from the rates against base. It returns false
+// when target is neither the base currency nor has a rate.
+func NewConversion(base, target string, rates []*ExchangeRate) (*Conversion, bool) {
+	perBase := map[string]float64{base: 1}
+	for _, rate := range rates {
+		if rate.Currency != base {
+			perBase[rate.Currency] = rate.Rate
+		}
+	}
+
+	targetRate, ok := perBase[target]
+	if !ok {
+		return nil, false
+	}
+
+	conversion := &Conversion{Base: base, Currency: target, factors: make(map[string]float64, len(perBase))}
+	for currency, rate := range perBase {
+		conversion.factors[currency] = targetRate / rate
+	}
+	return conversion, true
+}
+
+// Knows reports whether amounts in currency can be converted.
+func (c *Conversion) Knows(currency string) bool {
+	_, ok := c.factors[currency]
+	return ok
+}
+
+// Convert converts an amount from currency into the target currency, rounded to cents. Amounts
+// in a currency without a rate are returned unchanged.
+func (c *Conversion) Convert(amount float64, currency string) float64 {
+	factor, ok := c.factors[currency]
+	if !ok || currency == c.Currency {
+		return amount
+	}
+	return math.Round(amount*factor*100) / 100
+}
+
+// sqlArgs returns the known currencies and their factors as parallel arrays, for queries that
+// convert with LEFT JOIN unnest($n::text[], $m::float8[]) AS fx (currency, factor).
+func (c *Conversion) sqlArgs() (any, any) {
+	currencies := make([]string, 0, len(c.factors))
+	factors := make([]float64, 0, len(c.factors))
+	for currency, factor := range c.factors {
+		currencies = append(currencies, currency)
+		factors = append(factors, factor)
+	}
+	return pq.Array(currencies), pq.Array(factors)
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// Upsert sets the rate of a currency against the base currency, replacing any existing one.
+func (m *ExchangeRateModel) Upsert(rate *ExchangeRate) error {
+	query := `
+		INSERT INTO exchange_rates (currency, rate, updated_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (currency) DO UPDATE
+		SET rate = EXCLUDED.rate, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+		RETURNING updated_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, rate.Currency, rate.Rate, rate.UpdatedBy).Scan(&rate.UpdatedAt)
+}
+
+// GetAll returns every exchange rate, by currency.
+func (m *ExchangeRateModel) GetAll() ([]*ExchangeRate, error) {
+	query := `
+		SELECT currency, rate::float8, updated_by, updated_at
+		FROM exchange_rates
+		ORDER BY currency
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, wrapError("exchange_rate", "GetAll", err)
+	}
+	defer rows.Close()
+
+	rates := []*ExchangeRate{}
+	for rows.Next() {
+		rate := &ExchangeRate{}
+		if err := rows.Scan(&rate.Currency, &rate.Rate, &rate.UpdatedBy, &rate.UpdatedAt); err != nil {
+			return nil, wrapError("exchange_rate", "GetAll", err)
+		}
+		rates = append(rates, rate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("exchange_rate", "GetAll", err)
+	}
+	return rates, nil
+}
+
+// Conversion loads the rates and builds the conversion from base into target, returning
+// ErrUnknownCurrency when target has no rate.
+func (m *ExchangeRateModel) Conversion(base, target string) (*Conversion, error) {
+	rates, err := m.GetAll()
+	if err != nil {
+		return nil, wrapError("exchange_rate", "Conversion", err)
+	}
+
+	conversion, ok := NewConversion(base, target, rates)
+	if !ok {
+		return nil, ErrUnknownCurrency
+	}
+	return conversion, nil
+}
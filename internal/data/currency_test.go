@@ -0,0 +1,71 @@
+// File: internal/data/currency_test.go
+// Description: test suite for currency codes and conversions between them
+
+package data
+
+import (
+	"testing"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// TestValidateCurrency tests the accepted shape of currency codes
+func TestValidateCurrency(t *testing.T) {
+	tests := []struct {
+		currency string
+		valid    bool
+	}{
+		{"USD", true},
+		{"BZD", true},
+		{"usd", false},
+		{"US", false},
+		{"USDT", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			v := validator.New()
+			ValidateCurrency(v, "currency", tt.currency)
+			if v.IsValid() != tt.valid {
+				t.Errorf("expected valid=%v for %q, got %v", tt.valid, tt.currency, v.Errors)
+			}
+		})
+	}
+}
+
+// TestConversion tests converting between the base currency and currencies quoted against it
+func TestConversion(t *testing.T) {
+	rates := []*ExchangeRate{{Currency: "BZD", Rate: 2}, {Currency: "MXN", Rate: 17.5}}
+
+	tests := []struct {
+		name     string
+		target   string
+		amount   float64
+		from     string
+		expected float64
+	}{
+		{"Base To Base", "USD", 10, "USD", 10},
+		{"Quoted To Base", "USD", 25, "BZD", 12.5},
+		{"Base To Quoted", "BZD", 12.5, "USD", 25},
+		{"Between Quoted", "MXN", 10, "BZD", 87.5},
+		{"Rounded To Cents", "USD", 10, "MXN", 0.57},
+		{"Unknown Source Unchanged", "USD", 10, "EUR", 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fx, ok := NewConversion("USD", tt.target, rates)
+			if !ok {
+				t.Fatalf("expected a conversion into %s", tt.target)
+			}
+			if got := fx.Convert(tt.amount, tt.from); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	if _, ok := NewConversion("USD", "EUR", rates); ok {
+		t.Error("expected no conversion into a currency without a rate")
+	}
+}
@@ -0,0 +1,232 @@
+// File: internal/data/customers.go
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// Customer is a person sales can be attributed to, so their purchase history can be looked up.
+type Customer struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"` // empty for none, otherwise unique whatever its case
+	Phone     string    `json:"phone"` // empty for none
+	CreatedBy *int64    `json:"created_by"`
+	UpdatedBy *int64    `json:"updated_by"`
+	CreatedAt Timestamp `json:"created_at"`
+	UpdatedAt Timestamp `json:"updated_at"`
+}
+
+// PhoneRX matches phone numbers as they are usually written: 7 to 20 characters of digits, spaces,
+// dashes, dots and parentheses, starting and ending with a digit, optionally after a leading +.
+var PhoneRX = regexp.MustCompile(`^\+?[0-9][0-9 ().-]{5,18}[0-9]$`)
+
+// CustomerModel wraps a sql.DB connection pool.
+type CustomerModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// CustomerFilter represents filtering criteria for querying customers. The text filters match any
+// part of the field, ignoring case.
+type CustomerFilter struct {
+	Filter Filter `json:"filter"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Phone  string `json:"phone"`
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// NormalizeCustomer trims the fields of a customer and collapses the whitespace inside its name.
+func NormalizeCustomer(customer *Customer) {
+	customer.Name = strings.Join(strings.Fields(customer.Name), " ")
+	customer.Email = strings.TrimSpace(customer.Email)
+	customer.Phone = strings.TrimSpace(customer.Phone)
+}
+
+// ValidateCustomer checks the name, email and phone of a customer.
+func ValidateCustomer(v *validator.Validator, customer *Customer) {
+	v.Check(customer.Name != "", "name", "must be provided")
+	v.Check(len(customer.Name) <= 200, "name", "must not be more than 200 bytes long")
+	if customer.Email != "" {
+		v.Check(len(customer.Email) <= 254, "email", "must not be more than 254 characters long")
+		v.Check(v.Matches(customer.Email, validator.EmailRX), "email", "must be a valid email address")
+	}
+	if customer.Phone != "" {
+		v.Check(v.Matches(customer.Phone, PhoneRX), "phone", "must be a valid phone number")
+	}
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// Insert adds a customer. It returns ErrDuplicateEmail when another customer has the same email.
+func (m *CustomerModel) Insert(customer *Customer) error {
+	query := `
+		INSERT INTO customers (name, email, phone, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $4)
+		RETURNING id, updated_by, created_at, updated_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, customer.Name, customer.Email, customer.Phone, customer.CreatedBy).Scan(&customer.ID, &customer.UpdatedBy, &customer.CreatedAt, &customer.UpdatedAt)
+	if err != nil {
+		var pqError *pq.Error
+		if errors.As(err, &pqError) && pqError.Code == "23505" { // unique_violation
+			return ErrDuplicateEmail
+		}
+		return wrapError("customer", "Insert", err)
+	}
+	return nil
+}
+
+// customerColumns are the columns scanned by scanCustomer, in order.
+const customerColumns = `id, name, email, phone, created_by, updated_by, created_at, updated_at`
+
+// scanCustomer reads a row of customerColumns.
+func scanCustomer(row interface{ Scan(...any) error }) (*Customer, error) {
+	c := &Customer{}
+	err := row.Scan(&c.ID, &c.Name, &c.Email, &c.Phone, &c.CreatedBy, &c.UpdatedBy, &c.CreatedAt, &c.UpdatedAt)
+	return c, err
+}
+
+// Get retrieves a customer by its ID.
+func (m *CustomerModel) Get(id int64) (*Customer, error) {
+	query := `
+		SELECT ` + customerColumns + `
+		FROM customers
+		WHERE id = $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	customer, err := scanCustomer(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, wrapError("customer", "Get", err)
+	}
+	return customer, nil
+}
+
+// GetAll retrieves customers based on filtering criteria and pagination.
+func (m *CustomerModel) GetAll(filter CustomerFilter) ([]*Customer, MetaData, error) {
+	sortColumn, err := filter.Filter.SortColumn()
+	if err != nil {
+		return nil, MetaData{}, wrapError("customer", "GetAll", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), id, name, email, phone, created_by, updated_by, created_at, updated_at
+		FROM customers
+		WHERE (name ILIKE '%%' || $1 || '%%' OR $1 = '')
+		  AND (email ILIKE '%%' || $2 || '%%' OR $2 = '')
+		  AND (phone ILIKE '%%' || $3 || '%%' OR $3 = '')
+		ORDER BY %s %s, id ASC
+		LIMIT $4 OFFSET $5
+	`, sortColumn, filter.Filter.SortDirection())
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	args := []any{likeEscaper.Replace(filter.Name), likeEscaper.Replace(filter.Email), likeEscaper.Replace(filter.Phone), filter.Filter.Limit(), filter.Filter.Offset()}
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, MetaData{}, wrapError("customer", "GetAll", err)
+	}
+	defer rows.Close()
+
+	customers := []*Customer{}
+	totalRecords := int64(0)
+
+	for rows.Next() {
+		customer := &Customer{}
+		if err := rows.Scan(&totalRecords, &customer.ID, &customer.Name, &customer.Email, &customer.Phone, &customer.CreatedBy, &customer.UpdatedBy, &customer.CreatedAt, &customer.UpdatedAt); err != nil {
+			return nil, MetaData{}, wrapError("customer", "GetAll", err)
+		}
+		customers = append(customers, customer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, MetaData{}, wrapError("customer", "GetAll", err)
+	}
+
+	return customers, CalculateMetaData(totalRecords, filter.Filter), nil
+}
+
+// Update saves the name, email and phone of a customer. It returns ErrDuplicateEmail when another
+// customer has the new email.
+func (m *CustomerModel) Update(customer *Customer) error {
+	query := `
+		UPDATE customers
+		SET name = $1, email = $2, phone = $3, updated_by = $4, updated_at = NOW()
+		WHERE id = $5
+		RETURNING updated_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, customer.Name, customer.Email, customer.Phone, customer.UpdatedBy, customer.ID).Scan(&customer.UpdatedAt)
+	if err != nil {
+		var pqError *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case errors.As(err, &pqError) && pqError.Code == "23505": // unique_violation
+			return ErrDuplicateEmail
+		default:
+			return wrapError("customer", "Update", err)
+		}
+	}
+	return nil
+}
+
+// Delete removes a customer. Their sales are kept, no longer attributed to anyone.
+func (m *CustomerModel) Delete(id int64) error {
+	query := `
+		DELETE FROM customers
+		WHERE id = $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return wrapError("customer", "Delete", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return wrapError("customer", "Delete", err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
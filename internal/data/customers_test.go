@@ -0,0 +1,44 @@
+// File: internal/data/customers_test.go
+// Description: test suite for validating customers
+
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// TestValidateCustomer tests the name, email and phone rules of customers once normalized
+func TestValidateCustomer(t *testing.T) {
+	tests := []struct {
+		name       string
+		customer   Customer
+		errorField string
+	}{
+		{name: "Name Only", customer: Customer{Name: "Ana Lopez"}},
+		{name: "All Fields", customer: Customer{Name: "Ana Lopez", Email: "ana@example.com", Phone: "+501 (222) 333-4444"}},
+		{name: "Padded Fields", customer: Customer{Name: "  Ana   Lopez ", Email: " ana@example.com ", Phone: " 610-1234 "}},
+		{name: "No Name", customer: Customer{Name: "   ", Email: "ana@example.com"}, errorField: "name"},
+		{name: "Long Name", customer: Customer{Name: strings.Repeat("a", 201)}, errorField: "name"},
+		{name: "Invalid Email", customer: Customer{Name: "Ana", Email: "ana@"}, errorField: "email"},
+		{name: "Short Phone", customer: Customer{Name: "Ana", Phone: "12345"}, errorField: "phone"},
+		{name: "Phone With Letters", customer: Customer{Name: "Ana", Phone: "555-CALL-NOW"}, errorField: "phone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			NormalizeCustomer(&tt.customer)
+			ValidateCustomer(v, &tt.customer)
+
+			if tt.errorField == "" && !v.IsValid() {
+				t.Errorf("expected a valid customer, got %v", v.Errors)
+			}
+			if _, ok := v.Errors[tt.errorField]; tt.errorField != "" && !ok {
+				t.Errorf("expected an error on %s, got %v", tt.errorField, v.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,371 @@
+// File: internal/data/discounts.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// Kinds of discount. A percentage comes off each item it covers, a fixed amount comes off the items
+// it covers together and is shared between them by their line totals.
+const (
+	DiscountPercentage = "percentage"
+	DiscountFixed      = "fixed"
+)
+
+// DiscountKinds are the kinds a discount can be.
+var DiscountKinds = []string{DiscountPercentage, DiscountFixed}
+
+// DiscountCodeRX matches discount codes once upper cased: 3 to 32 letters, digits, dashes and
+// underscores, starting with a letter or digit.
+var DiscountCodeRX = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_-]{2,31}$`)
+
+// Discount is a code given at the till to take money off a sale. It covers the products carrying
+// Tag and, when ProductIDs is given, among those IDs; with neither it covers the whole sale. It can
+// be used while active and inside its validity window, either end of which may be left open.
+type Discount struct {
+	ID          int64     `json:"id"`
+	Code        string    `json:"code"` // upper case, unique
+	Description string    `json:"description"`
+	Kind        string    `json:"kind"`
+	Value       float64   `json:"value"`              // percent off, or the amount off in Currency
+	Currency    string    `json:"currency,omitempty"` // of a fixed amount, which only applies to sales in it
+	Tag         string    `json:"tag"`
+	ProductIDs  []int64   `json:"product_ids"`
+	StartsAt    Timestamp `json:"starts_at"`
+	EndsAt      Timestamp `json:"ends_at"`
+	Active      bool      `json:"active"`
+	CreatedBy   *int64    `json:"created_by"`
+	UpdatedBy   *int64    `json:"updated_by"`
+	CreatedAt   Timestamp `json:"created_at"`
+	UpdatedAt   Timestamp `json:"updated_at"`
+}
+
+// DiscountModel wraps a sql.DB connection pool.
+type DiscountModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// NormalizeDiscountCode trims and upper cases a discount code, so codes can be typed in any case.
+func NormalizeDiscountCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ValidateDiscount checks the code, kind, value, scope and validity window of a discount.
+func ValidateDiscount(v *validator.Validator, discount *Discount) {
+	v.Check(v.Matches(discount.Code, DiscountCodeRX), "code", "must be 3 to 32 letters, digits, dashes or underscores")
+	v.Check(len(discount.Description) <= 500, "description", "must not be more than 500 bytes long")
+	v.Check(v.Permitted(discount.Kind, DiscountKinds...), "kind", "must be one of "+strings.Join(DiscountKinds, ", "))
+	v.Check(discount.Value > 0, "value", "must be greater than zero")
+
+	switch discount.Kind {
+	case DiscountPercentage:
+		v.Check(discount.Value <= 100, "value", "must not be more than 100 percent")
+		v.Check(discount.Currency == "", "currency", "must not be provided for a percentage")
+	case DiscountFixed:
+		v.Check(discount.Value < MaxPrice, "value", "must be less than 100000000")
+		ValidateCurrency(v, "currency", discount.Currency)
+	}
+
+	if discount.Tag != "" {
+		ValidateTags(v, "tag", []string{discount.Tag})
+	}
+	v.Check(len(discount.ProductIDs) <= maxBulkPriceProducts, "product_ids", "must not contain more than 1000 products")
+	v.Check(!slices.ContainsFunc(discount.ProductIDs, func(id int64) bool { return id < 1 }), "product_ids", "must contain positive product ids")
+
+	if !discount.StartsAt.IsZero() && !discount.EndsAt.IsZero() {
+		v.Check(discount.StartsAt.Before(discount.EndsAt.Time), "ends_at", "must be after starts_at")
+	}
+}
+
+// ValidAt reports whether the discount can be used at t.
+func (d *Discount) ValidAt(t time.Time) bool {
+	return d.Active && (d.StartsAt.IsZero() || !t.Before(d.StartsAt.Time)) && (d.EndsAt.IsZero() || t.Before(d.EndsAt.Time))
+}
+
+// covers reports whether the discount applies to a product with the given tags.
+func (d *Discount) covers(productID int64, tags []string) bool {
+	return (d.Tag == "" || slices.Contains(tags, d.Tag)) && (len(d.ProductIDs) == 0 || slices.Contains(d.ProductIDs, productID))
+}
+
+// allocate sets the discount of each item of a sale, given the tags of its products. Percentages
+// are rounded per item; a fixed amount is capped at the total of the items it covers and shared
+// between them by line total, the last item taking the cents left over. It returns
+// ErrDiscountNotApplicable when no item is covered or a fixed amount is in another currency.
+func (d *Discount) allocate(items []*SaleItem, tags map[int64][]string) error {
+	var covered []*SaleItem
+	var coveredCents int64
+	for _, item := range items {
+		item.Discount = 0
+		if d.covers(item.ProductID, tags[item.ProductID]) && item.LineTotal > 0 {
+			covered = append(covered, item)
+			coveredCents += toCents(item.LineTotal)
+		}
+	}
+	if len(covered) == 0 || (d.Kind == DiscountFixed && covered[0].Currency != d.Currency) {
+		return ErrDiscountNotApplicable
+	}
+
+	switch d.Kind {
+	case DiscountPercentage:
+		for _, item := range covered {
+			item.Discount = math.Round(float64(toCents(item.LineTotal))*d.Value/100) / 100
+		}
+	case DiscountFixed:
+		cents := min(toCents(d.Value), coveredCents)
+		left := cents
+		for i, item := range covered {
+			share := left
+			if i < len(covered)-1 {
+				share = int64(float64(cents) * float64(toCents(item.LineTotal)) / float64(coveredCents))
+			}
+			item.Discount = float64(share) / 100
+			left -= share
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// discountColumns are the columns scanned by scanDiscount, in order.
+const discountColumns = `id, code, description, kind, value, COALESCE(currency, ''), tag, product_ids, starts_at, ends_at,
+	active, created_by, updated_by, created_at, updated_at`
+
+// scanDiscount reads a row of discountColumns.
+func scanDiscount(row interface{ Scan(...any) error }) (*Discount, error) {
+	d := &Discount{}
+	err := row.Scan(&d.ID, &d.Code, &d.Description, &d.Kind, &d.Value, &d.Currency, &d.Tag, pq.Array(&d.ProductIDs), &d.StartsAt, &d.EndsAt,
+		&d.Active, &d.CreatedBy, &d.UpdatedBy, &d.CreatedAt, &d.UpdatedAt)
+	return d, err
+}
+
+// Insert adds a discount. It returns ErrDuplicateDiscountCode when the code is taken.
+func (m *DiscountModel) Insert(discount *Discount) error {
+	query := `
+		INSERT INTO discounts (code, description, kind, value, currency, tag, product_ids, starts_at, ends_at, active, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10, $11, $11)
+		RETURNING id, updated_by, created_at, updated_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	args := []any{discount.Code, discount.Description, discount.Kind, discount.Value, discount.Currency, discount.Tag, pq.Array(discount.productIDs()), discount.StartsAt, discount.EndsAt, discount.Active, discount.CreatedBy}
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&discount.ID, &discount.UpdatedBy, &discount.CreatedAt, &discount.UpdatedAt)
+	if err != nil {
+		var pqError *pq.Error
+		if errors.As(err, &pqError) && pqError.Code == "23505" { // unique_violation
+			return ErrDuplicateDiscountCode
+		}
+		return wrapError("discount", "Insert", err)
+	}
+	return nil
+}
+
+// productIDs returns the product IDs of the discount, never nil so that it is stored as an empty
+// array.
+func (d *Discount) productIDs() []int64 {
+	if d.ProductIDs == nil {
+		return []int64{}
+	}
+	return d.ProductIDs
+}
+
+// Get retrieves a discount by its ID.
+func (m *DiscountModel) Get(id int64) (*Discount, error) {
+	query := `
+		SELECT ` + discountColumns + `
+		FROM discounts
+		WHERE id = $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	discount, err := scanDiscount(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, wrapError("discount", "Get", err)
+	}
+	return discount, nil
+}
+
+// GetAll returns every discount, newest first.
+func (m *DiscountModel) GetAll() ([]*Discount, error) {
+	query := `
+		SELECT ` + discountColumns + `
+		FROM discounts
+		ORDER BY id DESC
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, wrapError("discount", "GetAll", err)
+	}
+	defer rows.Close()
+
+	discounts := []*Discount{}
+	for rows.Next() {
+		discount, err := scanDiscount(rows)
+		if err != nil {
+			return nil, wrapError("discount", "GetAll", err)
+		}
+		discounts = append(discounts, discount)
+	}
+	return discounts, wrapError("discount", "GetAll", rows.Err())
+}
+
+// Update saves every field of a discount. Sales already made with it keep the amounts they were
+// sold at. It returns ErrDuplicateDiscountCode when the new code is taken.
+func (m *DiscountModel) Update(discount *Discount) error {
+	query := `
+		UPDATE discounts
+		SET code = $1, description = $2, kind = $3, value = $4, currency = NULLIF($5, ''), tag = $6, product_ids = $7,
+		    starts_at = $8, ends_at = $9, active = $10, updated_by = $11, updated_at = NOW()
+		WHERE id = $12
+		RETURNING created_by, created_at, updated_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	args := []any{discount.Code, discount.Description, discount.Kind, discount.Value, discount.Currency, discount.Tag, pq.Array(discount.productIDs()), discount.StartsAt, discount.EndsAt, discount.Active, discount.UpdatedBy, discount.ID}
+	if err := m.DB.QueryRowContext(ctx, query, args...).Scan(&discount.CreatedBy, &discount.CreatedAt, &discount.UpdatedAt); err != nil {
+		var pqError *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case errors.As(err, &pqError) && pqError.Code == "23505": // unique_violation
+			return ErrDuplicateDiscountCode
+		default:
+			return wrapError("discount", "Update", err)
+		}
+	}
+	return nil
+}
+
+// Delete removes a discount. Sales made with it keep its code and the amounts they were sold at.
+func (m *DiscountModel) Delete(id int64) error {
+	query := `
+		DELETE FROM discounts
+		WHERE id = $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return wrapError("discount", "Delete", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return wrapError("discount", "Delete", err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// getDiscount retrieves a discount by its id or code inside tx.
+func getDiscount(ctx context.Context, tx *sql.Tx, column string, value any) (*Discount, error) {
+	query := `
+		SELECT ` + discountColumns + `
+		FROM discounts
+		WHERE ` + column + ` = $1
+	`
+
+	discount, err := scanDiscount(tx.QueryRowContext(ctx, query, value))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return discount, nil
+}
+
+// saleDiscount finds the discount of a sale inside tx: the one the sale already has, which keeps
+// applying after its window closes and is dropped once deleted, or else the one its code names,
+// which must be usable now. It returns ErrInvalidDiscount for an unknown or unusable code.
+func saleDiscount(ctx context.Context, tx *sql.Tx, sale *Sale, now time.Time) (*Discount, error) {
+	switch {
+	case sale.DiscountID != nil:
+		discount, err := getDiscount(ctx, tx, "id", *sale.DiscountID)
+		if errors.Is(err, ErrRecordNotFound) {
+			return nil, nil
+		}
+		return discount, err
+	case sale.DiscountCode != nil:
+		discount, err := getDiscount(ctx, tx, "code", *sale.DiscountCode)
+		if errors.Is(err, ErrRecordNotFound) || (err == nil && !discount.ValidAt(now)) {
+			return nil, ErrInvalidDiscount
+		}
+		return discount, err
+	}
+	return nil, nil
+}
+
+// productTags returns the tags of the products of a sale's items inside tx, keyed by product ID.
+func productTags(ctx context.Context, tx *sql.Tx, items []*SaleItem) (map[int64][]string, error) {
+	query := `
+		SELECT id, tags
+		FROM products
+		WHERE id = ANY($1)
+	`
+
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		ids[i] = item.ProductID
+	}
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[int64][]string, len(ids))
+	for rows.Next() {
+		var id int64
+		var productTags []string
+		if err := rows.Scan(&id, pq.Array(&productTags)); err != nil {
+			return nil, err
+		}
+		tags[id] = productTags
+	}
+	return tags, rows.Err()
+}
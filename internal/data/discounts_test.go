@@ -0,0 +1,125 @@
+// File: internal/data/discounts_test.go
+// Description: test suite for validating discounts and sharing them between the items of a sale
+
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// TestValidateDiscount tests the code, kind, value, scope and window rules of discounts
+func TestValidateDiscount(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		discount   Discount
+		errorField string
+	}{
+		{name: "Percentage", discount: Discount{Code: "SUMMER-10", Kind: DiscountPercentage, Value: 10}},
+		{name: "Fixed", discount: Discount{Code: "FIVE_OFF", Kind: DiscountFixed, Value: 5, Currency: "USD", Tag: "promotions"}},
+		{name: "Windowed", discount: Discount{Code: "WEEKEND", Kind: DiscountPercentage, Value: 15, StartsAt: NewTimestamp(now), EndsAt: NewTimestamp(now.Add(48 * time.Hour))}},
+		{name: "Short Code", discount: Discount{Code: "AB", Kind: DiscountPercentage, Value: 10}, errorField: "code"},
+		{name: "Lower Case Code", discount: Discount{Code: "summer", Kind: DiscountPercentage, Value: 10}, errorField: "code"},
+		{name: "Unknown Kind", discount: Discount{Code: "SUMMER", Kind: "bogo", Value: 10}, errorField: "kind"},
+		{name: "Zero Value", discount: Discount{Code: "SUMMER", Kind: DiscountPercentage}, errorField: "value"},
+		{name: "Over 100 Percent", discount: Discount{Code: "SUMMER", Kind: DiscountPercentage, Value: 101}, errorField: "value"},
+		{name: "Percentage With Currency", discount: Discount{Code: "SUMMER", Kind: DiscountPercentage, Value: 10, Currency: "USD"}, errorField: "currency"},
+		{name: "Fixed Without Currency", discount: Discount{Code: "FIVE_OFF", Kind: DiscountFixed, Value: 5}, errorField: "currency"},
+		{name: "Bad Tag", discount: Discount{Code: "SUMMER", Kind: DiscountPercentage, Value: 10, Tag: "Promotions"}, errorField: "tag"},
+		{name: "Bad Product", discount: Discount{Code: "SUMMER", Kind: DiscountPercentage, Value: 10, ProductIDs: []int64{1, 0}}, errorField: "product_ids"},
+		{name: "Window Backwards", discount: Discount{Code: "WEEKEND", Kind: DiscountPercentage, Value: 15, StartsAt: NewTimestamp(now), EndsAt: NewTimestamp(now.Add(-time.Hour))}, errorField: "ends_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateDiscount(v, &tt.discount)
+
+			if tt.errorField == "" && !v.IsValid() {
+				t.Errorf("expected a valid discount, got %v", v.Errors)
+			}
+			if _, ok := v.Errors[tt.errorField]; tt.errorField != "" && !ok {
+				t.Errorf("expected an error on %s, got %v", tt.errorField, v.Errors)
+			}
+		})
+	}
+}
+
+// TestDiscountValidAt tests that discounts are usable while active and inside their window only
+func TestDiscountValidAt(t *testing.T) {
+	now := time.Now()
+	start, end := NewTimestamp(now.Add(-time.Hour)), NewTimestamp(now.Add(time.Hour))
+
+	tests := []struct {
+		name     string
+		discount Discount
+		at       time.Time
+		expected bool
+	}{
+		{name: "Open Ended", discount: Discount{Active: true}, at: now, expected: true},
+		{name: "Inactive", discount: Discount{}, at: now},
+		{name: "Inside Window", discount: Discount{Active: true, StartsAt: start, EndsAt: end}, at: now, expected: true},
+		{name: "At Start", discount: Discount{Active: true, StartsAt: start}, at: start.Time, expected: true},
+		{name: "Before Start", discount: Discount{Active: true, StartsAt: start}, at: now.Add(-2 * time.Hour)},
+		{name: "At End", discount: Discount{Active: true, EndsAt: end}, at: end.Time},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.discount.ValidAt(tt.at); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestDiscountAllocate tests how discounts are shared between the items they cover, to the cent
+func TestDiscountAllocate(t *testing.T) {
+	items := func() []*SaleItem {
+		return []*SaleItem{
+			{ProductID: 1, Currency: "USD", LineTotal: 10},
+			{ProductID: 2, Currency: "USD", LineTotal: 20},
+			{ProductID: 3, Currency: "USD", LineTotal: 0.05},
+		}
+	}
+	tags := map[int64][]string{1: {"promotions"}, 2: {"promotions", "drinks"}}
+
+	tests := []struct {
+		name      string
+		discount  Discount
+		expected  error
+		discounts []float64
+	}{
+		{name: "Percentage Of Everything", discount: Discount{Kind: DiscountPercentage, Value: 10}, discounts: []float64{1, 2, 0.01}},
+		{name: "Percentage Of A Tag", discount: Discount{Kind: DiscountPercentage, Value: 10, Tag: "drinks"}, discounts: []float64{0, 2, 0}},
+		{name: "Percentage Of Products", discount: Discount{Kind: DiscountPercentage, Value: 50, ProductIDs: []int64{1, 3}}, discounts: []float64{5, 0, 0.03}},
+		{name: "Tag And Products", discount: Discount{Kind: DiscountPercentage, Value: 10, Tag: "promotions", ProductIDs: []int64{2, 3}}, discounts: []float64{0, 2, 0}},
+		{name: "Fixed Shared", discount: Discount{Kind: DiscountFixed, Value: 10, Currency: "USD", Tag: "promotions"}, discounts: []float64{3.33, 6.67, 0}},
+		{name: "Fixed Capped", discount: Discount{Kind: DiscountFixed, Value: 50, Currency: "USD", ProductIDs: []int64{1}}, discounts: []float64{10, 0, 0}},
+		{name: "Fixed Other Currency", discount: Discount{Kind: DiscountFixed, Value: 5, Currency: "EUR"}, expected: ErrDiscountNotApplicable},
+		{name: "Nothing Covered", discount: Discount{Kind: DiscountPercentage, Value: 10, Tag: "bakery"}, expected: ErrDiscountNotApplicable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := items()
+			err := tt.discount.allocate(items, tags)
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, err)
+			}
+			if err != nil {
+				return
+			}
+			for i, item := range items {
+				if item.Discount != tt.discounts[i] {
+					t.Errorf("item %d: expected a discount of %.2f, got %.2f", i, tt.discounts[i], item.Discount)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// File: internal/data/duplicates.go
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// SaleDuplicate is a sale flagged as a likely duplicate of another: the same user sold the same
+// products in the same quantities moments apart, as when an item is scanned twice. Flags are only
+// a warning and both sales stay recorded until someone removes one.
+type SaleDuplicate struct {
+	SaleID          int64     `json:"sale_id"`
+	DuplicateOf     int64     `json:"duplicate_of"`
+	UserID          int64     `json:"user_id"`
+	SoldAt          Timestamp `json:"sold_at"`
+	DuplicateSoldAt Timestamp `json:"duplicate_sold_at"`
+	SecondsApart    float64   `json:"seconds_apart"`
+	Total           float64   `json:"total_amount"`
+	Currency        string    `json:"currency"`
+	FlaggedAt       Timestamp `json:"flagged_at"`
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// FlagDuplicate looks for another sale by the same user with exactly the same products and
+// quantities, sold within window of the sale, and flags the sale as its duplicate. It returns the
+// ID of the closest such sale, or nil when there is none. Only completed sales that have not been
+// archived are compared.
+func (m *SaleModel) FlagDuplicate(sale *Sale, window time.Duration) (*int64, error) {
+	query := `
+		WITH items AS (
+			SELECT sale_id, array_agg(ARRAY[product_id, quantity] ORDER BY product_id) AS items
+			FROM sale_items
+			WHERE sale_id = ANY (
+				SELECT id FROM sales
+				WHERE user_id = $2 AND sold_at BETWEEN $3::timestamptz - $4::float8 * INTERVAL '1 second' AND $3::timestamptz + $4::float8 * INTERVAL '1 second'
+			)
+			GROUP BY sale_id
+		), candidate AS (
+			SELECT s.id
+			FROM sales s
+			JOIN items i ON i.sale_id = s.id
+			JOIN items own ON own.sale_id = $1 AND own.items = i.items
+			WHERE s.id <> $1 AND s.archived_at IS NULL AND s.status = 'completed'
+			ORDER BY ABS(EXTRACT(EPOCH FROM s.sold_at - $3::timestamptz)), s.id
+			LIMIT 1
+		)
+		INSERT INTO sale_duplicates (sale_id, duplicate_of)
+		SELECT $1, id FROM candidate
+		ON CONFLICT (sale_id) DO UPDATE SET duplicate_of = EXCLUDED.duplicate_of, flagged_at = NOW()
+		RETURNING duplicate_of
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	var duplicateOf int64
+	err := m.DB.QueryRowContext(ctx, query, sale.ID, sale.UserID, sale.SoldAt.Time, window.Seconds()).Scan(&duplicateOf)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, wrapError("sale", "FlagDuplicate", err)
+	}
+	return &duplicateOf, nil
+}
+
+// DuplicateSales returns the sales of the range flagged as likely duplicates, newest first, with
+// the sale each one repeats. Pairs where either sale has been archived or voided are left out.
+func (m *ReportModel) DuplicateSales(r ReportRange, filter Filter) ([]*SaleDuplicate, MetaData, error) {
+	query := `
+		SELECT COUNT(*) OVER(), d.sale_id, d.duplicate_of, s.user_id, s.sold_at, o.sold_at,
+		       ABS(EXTRACT(EPOCH FROM s.sold_at - o.sold_at))::float8, s.total_amount, s.currency, d.flagged_at
+		FROM sale_duplicates d
+		JOIN sales s ON s.id = d.sale_id
+		JOIN sales o ON o.id = d.duplicate_of
+		WHERE s.archived_at IS NULL AND o.archived_at IS NULL
+		  AND s.status <> 'voided' AND o.status <> 'voided'
+		  AND s.sold_at >= $1 AND s.sold_at < $2
+		ORDER BY s.sold_at DESC, d.sale_id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReportQuery)
+	defer cancel()
+
+	start, end := r.bounds()
+	rows, err := m.DB.QueryContext(ctx, query, start, end, filter.Limit(), filter.Offset())
+	if err != nil {
+		return nil, MetaData{}, wrapError("report", "DuplicateSales", err)
+	}
+	defer rows.Close()
+
+	duplicates := []*SaleDuplicate{}
+	totalRecords := int64(0)
+
+	for rows.Next() {
+		d := &SaleDuplicate{}
+		if err := rows.Scan(&totalRecords, &d.SaleID, &d.DuplicateOf, &d.UserID, &d.SoldAt, &d.DuplicateSoldAt, &d.SecondsApart, &d.Total, &d.Currency, &d.FlaggedAt); err != nil {
+			return nil, MetaData{}, wrapError("report", "DuplicateSales", err)
+		}
+		duplicates = append(duplicates, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, MetaData{}, wrapError("report", "DuplicateSales", err)
+	}
+
+	return duplicates, CalculateMetaData(totalRecords, filter), nil
+}
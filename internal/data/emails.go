@@ -0,0 +1,188 @@
+// File: internal/data/emails.go
+package data
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// Delivery statuses of an outgoing email. Queued, sent and failed are set by the API itself;
+// the rest arrive from the mail provider's webhook.
+const (
+	EmailQueued     = "queued"
+	EmailSent       = "sent"
+	EmailFailed     = "failed"
+	EmailDelivered  = "delivered"
+	EmailBounced    = "bounced"
+	EmailComplained = "complained"
+	EmailSuppressed = "suppressed"
+)
+
+// Reasons an address is suppressed.
+const (
+	SuppressBounce    = "bounce"
+	SuppressComplaint = "complaint"
+)
+
+// Email is the delivery record of one outgoing message, matched to provider callbacks by MessageID.
+type Email struct {
+	ID        int64     `json:"id"`
+	MessageID string    `json:"message_id"`
+	UserID    *int64    `json:"user_id"`
+	Recipient string    `json:"recipient"`
+	Template  string    `json:"template"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt Timestamp `json:"created_at"`
+	UpdatedAt Timestamp `json:"updated_at"`
+}
+
+// EmailModel wraps a sql.DB connection pool.
+type EmailModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// NewMessageID returns a random RFC 5322 Message-ID under the given domain.
+func NewMessageID(domain string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain), nil
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// Insert records an outgoing email. Its status defaults to queued when empty.
+func (m *EmailModel) Insert(email *Email) error {
+	if email.Status == "" {
+		email.Status = EmailQueued
+	}
+
+	query := `
+		INSERT INTO emails (message_id, user_id, recipient, template, status, error)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, email.MessageID, email.UserID, email.Recipient, email.Template, email.Status, email.Error).Scan(
+		&email.ID,
+		&email.CreatedAt,
+		&email.UpdatedAt,
+	)
+}
+
+// SetStatus updates the delivery status of an email and returns its recipient.
+// It returns ErrRecordNotFound for an unknown message ID.
+func (m *EmailModel) SetStatus(messageID, status, errorText string) (string, error) {
+	query := `
+		UPDATE emails
+		SET status = $1, error = $2, updated_at = NOW()
+		WHERE message_id = $3
+		RETURNING recipient
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	var recipient string
+	err := m.DB.QueryRowContext(ctx, query, status, errorText, messageID).Scan(&recipient)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrRecordNotFound
+		}
+		return "", wrapError("email", "SetStatus", err)
+	}
+	return recipient, nil
+}
+
+// CountRecent counts emails from one template sent to the recipient or to the user within the
+// window, and returns when the oldest of them was recorded so callers can say when to retry.
+func (m *EmailModel) CountRecent(userID *int64, recipient, template string, window time.Duration) (int, time.Time, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(MIN(created_at), NOW())
+		FROM emails
+		WHERE template = $1
+		  AND created_at > NOW() - make_interval(secs => $2)
+		  AND (recipient = $3 OR user_id = $4)
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	var count int
+	var oldest time.Time
+	err := m.DB.QueryRowContext(ctx, query, template, window.Seconds(), recipient, userID).Scan(&count, &oldest)
+	return count, oldest, wrapError("email", "CountRecent", err)
+}
+
+// SendResults counts the emails the API handed to the mail server within the window, and how
+// many of those the server refused.
+func (m *EmailModel) SendResults(window time.Duration) (int, int, error) {
+	query := `
+		SELECT COUNT(*) FILTER (WHERE status NOT IN ('queued', 'suppressed', 'failed')),
+		       COUNT(*) FILTER (WHERE status = 'failed')
+		FROM emails
+		WHERE created_at > NOW() - make_interval(secs => $1)
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	var sent, failed int
+	err := m.DB.QueryRowContext(ctx, query, window.Seconds()).Scan(&sent, &failed)
+	return sent, failed, wrapError("email", "SendResults", err)
+}
+
+// IsSuppressed reports whether mail to the address is blocked after a hard bounce or complaint.
+func (m *EmailModel) IsSuppressed(email string) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM email_suppressions WHERE email = $1)
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	var suppressed bool
+	err := m.DB.QueryRowContext(ctx, query, email).Scan(&suppressed)
+	return suppressed, wrapError("email", "IsSuppressed", err)
+}
+
+// Suppress blocks further mail to the address. Suppressing an address twice keeps the first reason.
+func (m *EmailModel) Suppress(email, reason, messageID string) error {
+	query := `
+		INSERT INTO email_suppressions (email, reason, message_id)
+		VALUES ($1, $2, NULLIF($3, ''))
+		ON CONFLICT (email) DO NOTHING
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, email, reason, messageID)
+	return wrapError("email", "Suppress", err)
+}
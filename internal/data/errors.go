@@ -5,14 +5,80 @@ import "errors"
 
 // Define custom error variables for common error scenarios.
 var (
-	ErrRecordNotFound   = errors.New("record not found")
-	ErrEditConflict     = errors.New("edit conflict")
-	ErrInvalidID        = errors.New("invalid ID")
-	ErrNoRecords        = errors.New("no matching records found")
-	ErrDuplicateEmail   = errors.New("duplicate email")
-	ErrInsufficientCash = errors.New("insufficient cash provided")
-	ErrInvalidData      = errors.New("invalid data provided")
-	ErrInvalidRole      = errors.New("invalid role specified")
-	ErrAccountNotActive = errors.New("account is not active")
-	ErrInvalidToken     = errors.New("invalid or expired token")
+	ErrRecordNotFound        = errors.New("record not found")
+	ErrEditConflict          = errors.New("edit conflict")
+	ErrInvalidID             = errors.New("invalid ID")
+	ErrNoRecords             = errors.New("no matching records found")
+	ErrDuplicateEmail        = errors.New("duplicate email")
+	ErrInsufficientCash      = errors.New("insufficient cash provided")
+	ErrInvalidData           = errors.New("invalid data provided")
+	ErrInvalidRole           = errors.New("invalid role specified")
+	ErrAccountNotActive      = errors.New("account is not active")
+	ErrInvalidToken          = errors.New("invalid or expired token")
+	ErrDuplicateRole         = errors.New("duplicate role name")
+	ErrRoleInUse             = errors.New("role is still assigned to users")
+	ErrUnknownPermission     = errors.New("unknown permission code")
+	ErrLastAdmin             = errors.New("cannot remove the last active admin")
+	ErrInsufficientStock     = errors.New("insufficient stock")
+	ErrDuplicateReportName   = errors.New("duplicate saved report name")
+	ErrUnknownCurrency       = errors.New("no exchange rate for currency")
+	ErrInvalidSort           = errors.New("invalid sort value")
+	ErrMixedCurrencies       = errors.New("sale items are priced in different currencies")
+	ErrDuplicateHoliday      = errors.New("duplicate holiday date")
+	ErrOverpayment           = errors.New("payments exceed the balance due")
+	ErrDuplicateScheduleName = errors.New("duplicate schedule name")
+	ErrRefundExceedsSale     = errors.New("refund exceeds the units left on the sale")
+	ErrSaleRefunded          = errors.New("sale has refunds")
+	ErrScheduleRunning       = errors.New("schedule is already running")
+	ErrDuplicateDiscountCode = errors.New("duplicate discount code")
+	ErrInvalidDiscount       = errors.New("discount code is unknown, inactive or out of its validity window")
+	ErrDiscountNotApplicable = errors.New("discount does not apply to any item of the sale")
+	ErrTooManyAttachments    = errors.New("sale has too many attachments")
+	ErrDuplicateTaxTag       = errors.New("duplicate tax rate tag")
+	ErrSaleNotCompleted      = errors.New("sale is a draft or has been voided")
+	ErrSaleNotDraft          = errors.New("sale is not a draft")
+	ErrSaleVoided            = errors.New("sale has been voided")
+	ErrSalePaid              = errors.New("sale has payments")
+	ErrUnknownUser           = errors.New("user does not exist")
+	ErrUnknownProduct        = errors.New("product does not exist")
+	ErrUnknownCustomer       = errors.New("customer does not exist")
+	ErrBootstrapped          = errors.New("bootstrap has already been completed")
+	ErrInvalidCursor         = errors.New("invalid sync cursor")
 )
+
+// DomainError records which model operation an error came from, so a failure reaching a handler
+// says what was being done and not just what the driver reported. It unwraps to the cause, so
+// errors.Is and errors.As see the sentinels and driver errors underneath.
+type DomainError struct {
+	Entity string // the model, such as "sale"
+	Op     string // the model method, such as "Insert"
+	Err    error  // the cause
+}
+
+// Error formats the error as entity.Op: cause.
+func (e *DomainError) Error() string {
+	return e.Entity + "." + e.Op + ": " + e.Err.Error()
+}
+
+// Unwrap returns the cause.
+func (e *DomainError) Unwrap() error {
+	return e.Err
+}
+
+// Operation names the failed operation as entity.Op, the label failures are counted under.
+func (e *DomainError) Operation() string {
+	return e.Entity + "." + e.Op
+}
+
+// wrapError wraps err in a DomainError for the entity and operation. Nil stays nil, and an error
+// already wrapped by an inner operation keeps that more precise context.
+func wrapError(entity, op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var domainErr *DomainError
+	if errors.As(err, &domainErr) {
+		return err
+	}
+	return &DomainError{Entity: entity, Op: op, Err: err}
+}
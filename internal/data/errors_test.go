@@ -0,0 +1,47 @@
+// File: internal/data/errors_test.go
+// Description: test suite for the operation context added to data layer errors
+
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// TestWrapError tests that errors carry the failed operation while the causes underneath stay
+// visible to errors.Is and errors.As
+func TestWrapError(t *testing.T) {
+	if err := wrapError("sale", "Get", nil); err != nil {
+		t.Fatalf("expected nil to stay nil, got %v", err)
+	}
+
+	err := wrapError("sale", "Get", sql.ErrConnDone)
+	if got := err.Error(); got != "sale.Get: "+sql.ErrConnDone.Error() {
+		t.Errorf("expected the operation before the cause, got %q", got)
+	}
+	if !errors.Is(err, sql.ErrConnDone) {
+		t.Errorf("expected errors.Is to find the cause")
+	}
+
+	var domainErr *DomainError
+	if !errors.As(err, &domainErr) || domainErr.Operation() != "sale.Get" {
+		t.Fatalf("expected a DomainError for sale.Get, got %#v", err)
+	}
+
+	// An outer operation keeps the inner one, which says more precisely what failed
+	outer := wrapError("role", "Update", wrapError("role", "getBy", ErrRecordNotFound))
+	if !errors.As(outer, &domainErr) || domainErr.Operation() != "role.getBy" {
+		t.Errorf("expected the inner operation to be kept, got %v", outer)
+	}
+	if !errors.Is(outer, ErrRecordNotFound) {
+		t.Errorf("expected errors.Is to find the sentinel through both operations")
+	}
+
+	var pqError *pq.Error
+	if !errors.As(wrapError("user", "Insert", &pq.Error{Code: "23505"}), &pqError) || pqError.Code != "23505" {
+		t.Errorf("expected errors.As to find the driver error")
+	}
+}
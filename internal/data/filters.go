@@ -3,6 +3,7 @@
 package data
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
@@ -18,14 +19,28 @@ import (
 type Filter struct {
 	Page         int64    `json:"page"`
 	PageSize     int64    `json:"page_size"`
+	MaxPageSize  int64    `json:"-"` // largest page size the listing allows, DefaultPagePolicy.Max when zero
 	SortBy       string   `json:"sort_by"`
 	SortSafeList []string `json:"-"`
 }
 
+// PagePolicy is the page size a listing uses when none is asked for, and the largest it allows.
+type PagePolicy struct {
+	Default int64 `json:"default"`
+	Max     int64 `json:"max"`
+}
+
+// DefaultPagePolicy applies to listings without a policy of their own.
+var DefaultPagePolicy = PagePolicy{Default: 20, Max: 100}
+
+// MaxPageSizeLimit is the largest maximum page size a policy may set.
+const MaxPageSizeLimit = 1000
+
 // MetaData contains pagination metadata.
 type MetaData struct {
 	CurrentPage  int64 `json:"current_page,omitempty"`  // Current page number
 	PageSize     int64 `json:"page_size,omitempty"`     // Number of records per page
+	MaxPageSize  int64 `json:"max_page_size,omitempty"` // Largest page size the listing allows
 	FirstPage    int64 `json:"first_page,omitempty"`    // First page number
 	LastPage     int64 `json:"last_page,omitempty"`     // Last page number
 	TotalRecords int64 `json:"total_records,omitempty"` // Total number of records
@@ -39,11 +54,28 @@ type MetaData struct {
 
 // ValidateFilters checks the validity of the filter parameters.
 func ValidateFilters(v *validator.Validator, f Filter) {
-	v.Check(f.Page > 0, "page", "must be greater than zero")                        // Page must be greater than 0
-	v.Check(f.Page <= 500, "page", "must be a maximum of 500")                      // Page must be at most 500
-	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")               // PageSize must be greater than 0
-	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")             // PageSize must be at most 100
-	v.Check(v.Permitted(f.SortBy, f.SortSafeList...), "sort", "invalid sort value") // Sort must be in the safelist
+	maxPageSize := f.maxPageSize()
+	v.Check(f.Page > 0, "page", "must be greater than zero")                                             // Page must be greater than 0
+	v.Check(f.Page <= 500, "page", "must be a maximum of 500")                                           // Page must be at most 500
+	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")                                    // PageSize must be greater than 0
+	v.Check(f.PageSize <= maxPageSize, "page_size", fmt.Sprintf("must be a maximum of %d", maxPageSize)) // PageSize must be at most the listing's maximum
+	v.Check(v.Permitted(f.SortBy, f.SortSafeList...), "sort", "invalid sort value")                      // Sort must be in the safelist
+}
+
+// ValidatePagePolicy checks that a listing's default page size is within its maximum, and the
+// maximum within MaxPageSizeLimit.
+func ValidatePagePolicy(v *validator.Validator, key string, policy PagePolicy) {
+	v.Check(policy.Default > 0, key, "default page size must be greater than zero")
+	v.Check(policy.Default <= policy.Max, key, "default page size must not be more than the maximum")
+	v.Check(policy.Max <= MaxPageSizeLimit, key, fmt.Sprintf("maximum page size must not be more than %d", MaxPageSizeLimit))
+}
+
+// maxPageSize returns the largest page size the filter allows.
+func (f Filter) maxPageSize() int64 {
+	if f.MaxPageSize > 0 {
+		return f.MaxPageSize
+	}
+	return DefaultPagePolicy.Max
 }
 
 // Limit calculates the SQL LIMIT value based on the page size.
@@ -57,13 +89,15 @@ func (f Filter) Offset() int64 {
 }
 
 // SortColumn returns the column name to sort by, removing any leading '-' for descending order.
-func (f Filter) SortColumn() string {
+// It returns ErrInvalidSort when the sort value is not in the safelist, so the value never
+// reaches a query.
+func (f Filter) SortColumn() (string, error) {
 	for _, safeValue := range f.SortSafeList {
 		if f.SortBy == safeValue {
-			return strings.TrimPrefix(f.SortBy, "-") // Remove leading '-' if present
+			return strings.TrimPrefix(f.SortBy, "-"), nil // Remove leading '-' if present
 		}
 	}
-	panic("unsafe sort parameter: " + f.SortBy) // Panic if the sort parameter is not in the safelist
+	return "", ErrInvalidSort
 }
 
 // SortDirection returns the sort direction ("ASC" or "DESC") based on the SortBy field.
@@ -74,17 +108,20 @@ func (f Filter) SortDirection() string {
 	return "ASC"
 }
 
-// CalculateMetaData computes pagination metadata based on total records, current page, and page size.
-func CalculateMetaData(totalRecords, page, pageSize int64) MetaData {
+// CalculateMetaData computes pagination metadata based on total records and the filter's page and
+// page size. The applied page size and its maximum are reported even when nothing matched, so
+// clients can adapt their requests.
+func CalculateMetaData(totalRecords int64, filter Filter) MetaData {
 	if totalRecords == 0 {
-		return MetaData{}
+		return MetaData{PageSize: filter.PageSize, MaxPageSize: filter.maxPageSize()}
 	}
 
-	lastPage := (totalRecords + pageSize - 1) / pageSize // Calculate last page number
+	lastPage := (totalRecords + filter.PageSize - 1) / filter.PageSize // Calculate last page number
 
 	return MetaData{
-		CurrentPage:  page,
-		PageSize:     pageSize,
+		CurrentPage:  filter.Page,
+		PageSize:     filter.PageSize,
+		MaxPageSize:  filter.maxPageSize(),
 		FirstPage:    1,
 		LastPage:     lastPage,
 		TotalRecords: totalRecords,
@@ -0,0 +1,47 @@
+// File: internal/data/filters_test.go
+// Description: test suite for filter validation and sort clauses
+
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// TestSortColumn tests that only safelisted sort values produce a column
+func TestSortColumn(t *testing.T) {
+	safelist := []string{"id", "name", "-id", "-name"}
+	tests := []struct {
+		sortBy  string
+		want    string
+		wantErr error
+	}{
+		{sortBy: "id", want: "id"},
+		{sortBy: "-name", want: "name"},
+		{sortBy: "price", wantErr: ErrInvalidSort},
+		{sortBy: "id; DROP TABLE sales", wantErr: ErrInvalidSort},
+		{sortBy: "-id, (SELECT pg_sleep(10))", wantErr: ErrInvalidSort},
+		{sortBy: "", wantErr: ErrInvalidSort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sortBy, func(t *testing.T) {
+			filter := Filter{Page: 1, PageSize: 20, SortBy: tt.sortBy, SortSafeList: safelist}
+			got, err := filter.SortColumn()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got column %q, want %q", got, tt.want)
+			}
+
+			v := validator.New()
+			ValidateFilters(v, filter)
+			if _, invalid := v.Errors["sort"]; invalid != (tt.wantErr != nil) {
+				t.Errorf("ValidateFilters sort errors %v disagree with SortColumn", v.Errors)
+			}
+		})
+	}
+}
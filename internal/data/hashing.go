@@ -0,0 +1,196 @@
+// File: internal/data/hashing.go
+package data
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// Supported password hashing algorithms.
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+// Fixed Argon2id parameters; the configurable cost is the number of passes.
+const (
+	argon2Memory      = 64 * 1024 // KiB
+	argon2Parallelism = 2
+	argon2SaltLength  = 16
+	argon2KeyLength   = 32
+)
+
+// argon2Prefix marks hashes in the PHC string format, bcrypt hashes start with "$2".
+var argon2Prefix = []byte("$argon2id$")
+
+// PasswordHashing selects the algorithm and cost used for new password hashes. Cost is the
+// bcrypt cost or the number of Argon2id passes; zero picks the algorithm's default.
+type PasswordHashing struct {
+	Algorithm string
+	Cost      int
+}
+
+// DefaultPasswordHashing matches the hashes written before hashing became configurable.
+func DefaultPasswordHashing() PasswordHashing {
+	return PasswordHashing{Algorithm: HashBcrypt, Cost: bcrypt.DefaultCost}
+}
+
+var (
+	passwordHashingMu sync.RWMutex
+	passwordHashing   = DefaultPasswordHashing()
+)
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// Validate fills in the default cost and checks the configuration.
+func (h *PasswordHashing) Validate() error {
+	switch h.Algorithm {
+	case HashBcrypt:
+		if h.Cost == 0 {
+			h.Cost = bcrypt.DefaultCost
+		}
+		if h.Cost < bcrypt.MinCost || h.Cost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case HashArgon2id:
+		if h.Cost == 0 {
+			h.Cost = 3
+		}
+		if h.Cost < 1 || h.Cost > 10 {
+			return errors.New("argon2id cost must be between 1 and 10 passes")
+		}
+	default:
+		return fmt.Errorf("unknown hash algorithm %q, expected %s or %s", h.Algorithm, HashBcrypt, HashArgon2id)
+	}
+	return nil
+}
+
+// SetPasswordHashing replaces the configuration used by Password.Set.
+func SetPasswordHashing(h PasswordHashing) error {
+	if err := h.Validate(); err != nil {
+		return err
+	}
+
+	passwordHashingMu.Lock()
+	defer passwordHashingMu.Unlock()
+	passwordHashing = h
+	return nil
+}
+
+// ActivePasswordHashing returns the configuration currently used for new hashes.
+func ActivePasswordHashing() PasswordHashing {
+	passwordHashingMu.RLock()
+	defer passwordHashingMu.RUnlock()
+	return passwordHashing
+}
+
+// hashPassword hashes a plaintext password with the given configuration.
+func hashPassword(h PasswordHashing, plaintext string) ([]byte, error) {
+	if h.Algorithm == HashArgon2id {
+		salt := make([]byte, argon2SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		key := argon2.IDKey([]byte(plaintext), salt, uint32(h.Cost), argon2Memory, argon2Parallelism, argon2KeyLength)
+		encoded := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, argon2Memory, h.Cost, argon2Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+		return []byte(encoded), nil
+	}
+	return bcrypt.GenerateFromPassword([]byte(plaintext), h.Cost)
+}
+
+// argon2Params holds the parameters decoded from a PHC string.
+type argon2Params struct {
+	version     int
+	memory      uint32
+	passes      uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+// decodeArgon2 parses a hash written by hashPassword.
+func decodeArgon2(hash []byte) (*argon2Params, error) {
+	parts := bytes.Split(hash, []byte("$"))
+	if len(parts) != 6 {
+		return nil, errors.New("malformed argon2id hash")
+	}
+
+	p := &argon2Params{}
+	if _, err := fmt.Sscanf(string(parts[2]), "v=%d", &p.version); err != nil {
+		return nil, fmt.Errorf("malformed argon2id version: %w", err)
+	}
+	if _, err := fmt.Sscanf(string(parts[3]), "m=%d,t=%d,p=%d", &p.memory, &p.passes, &p.parallelism); err != nil {
+		return nil, fmt.Errorf("malformed argon2id parameters: %w", err)
+	}
+
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(string(parts[4])); err != nil {
+		return nil, fmt.Errorf("malformed argon2id salt: %w", err)
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(string(parts[5])); err != nil {
+		return nil, fmt.Errorf("malformed argon2id key: %w", err)
+	}
+	return p, nil
+}
+
+// comparePassword reports whether the plaintext matches a bcrypt or Argon2id hash.
+func comparePassword(hash []byte, plaintext string) (bool, error) {
+	if !bytes.HasPrefix(hash, argon2Prefix) {
+		err := bcrypt.CompareHashAndPassword(hash, []byte(plaintext))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	p, err := decodeArgon2(hash)
+	if err != nil {
+		return false, err
+	}
+	key := argon2.IDKey([]byte(plaintext), p.salt, p.passes, p.memory, p.parallelism, uint32(len(p.key)))
+	return subtle.ConstantTimeCompare(key, p.key) == 1, nil
+}
+
+// needsRehash reports whether a hash was written with another algorithm or other parameters
+// than the given configuration.
+func needsRehash(h PasswordHashing, hash []byte) bool {
+	isArgon2 := bytes.HasPrefix(hash, argon2Prefix)
+	switch h.Algorithm {
+	case HashArgon2id:
+		if !isArgon2 {
+			return true
+		}
+		p, err := decodeArgon2(hash)
+		if err != nil {
+			return true
+		}
+		return p.version != argon2.Version || p.memory != argon2Memory || p.passes != uint32(h.Cost) ||
+			p.parallelism != argon2Parallelism || len(p.key) != argon2KeyLength
+	default:
+		if isArgon2 {
+			return true
+		}
+		cost, err := bcrypt.Cost(hash)
+		return err != nil || cost != h.Cost
+	}
+}
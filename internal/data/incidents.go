@@ -0,0 +1,218 @@
+// File: internal/data/incidents.go
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// Impacts of an incident on users, least severe first.
+var IncidentImpacts = []string{"none", "minor", "major", "critical"}
+
+// Statuses an incident moves through. Resolving one stamps ResolvedAt.
+var IncidentStatuses = []string{"investigating", "identified", "monitoring", "resolved"}
+
+// IncidentResolved is the status of an incident that is over.
+const IncidentResolved = "resolved"
+
+// Incident is a note about an outage or degradation, shown on the public status endpoint while
+// it is open and for a while after it is resolved.
+type Incident struct {
+	ID         int64     `json:"id"`
+	Title      string    `json:"title"`
+	Message    string    `json:"message"`
+	Impact     string    `json:"impact"`
+	Status     string    `json:"status"`
+	CreatedBy  *int64    `json:"created_by,omitempty"`
+	CreatedAt  Timestamp `json:"created_at"`
+	UpdatedAt  Timestamp `json:"updated_at"`
+	ResolvedAt Timestamp `json:"resolved_at"`
+}
+
+// IncidentModel wraps a sql.DB connection pool.
+type IncidentModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// ValidateIncident checks the title, message, impact and status of an incident.
+func ValidateIncident(v *validator.Validator, incident *Incident) {
+	v.Check(strings.TrimSpace(incident.Title) != "", "title", "must be provided")
+	v.Check(len(incident.Title) <= 200, "title", "must not be more than 200 bytes long")
+	v.Check(len(incident.Message) <= 5000, "message", "must not be more than 5000 bytes long")
+	v.Check(v.Permitted(incident.Impact, IncidentImpacts...), "impact", "must be one of "+strings.Join(IncidentImpacts, ", "))
+	v.Check(v.Permitted(incident.Status, IncidentStatuses...), "status", "must be one of "+strings.Join(IncidentStatuses, ", "))
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// Insert records a new incident.
+func (m *IncidentModel) Insert(incident *Incident) error {
+	query := `
+		INSERT INTO status_incidents (title, message, impact, status, created_by, resolved_at)
+		VALUES ($1, $2, $3, $4, $5, CASE WHEN $4 = 'resolved' THEN NOW() END)
+		RETURNING id, created_at, updated_at, resolved_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, incident.Title, incident.Message, incident.Impact, incident.Status, incident.CreatedBy).Scan(&incident.ID, &incident.CreatedAt, &incident.UpdatedAt, &incident.ResolvedAt)
+}
+
+// Get retrieves an incident by its ID.
+func (m *IncidentModel) Get(id int64) (*Incident, error) {
+	query := `
+		SELECT id, title, message, impact, status, created_by, created_at, updated_at, resolved_at
+		FROM status_incidents
+		WHERE id = $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	incident := &Incident{}
+	if err := m.DB.QueryRowContext(ctx, query, id).Scan(&incident.ID, &incident.Title, &incident.Message, &incident.Impact, &incident.Status, &incident.CreatedBy, &incident.CreatedAt, &incident.UpdatedAt, &incident.ResolvedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, wrapError("incident", "Get", err)
+	}
+	return incident, nil
+}
+
+// Update saves the title, message, impact and status of an incident. Moving it to resolved
+// stamps ResolvedAt, reopening it clears the stamp.
+func (m *IncidentModel) Update(incident *Incident) error {
+	query := `
+		UPDATE status_incidents
+		SET title = $1, message = $2, impact = $3, status = $4, updated_at = NOW(),
+		    resolved_at = CASE WHEN $4 = 'resolved' THEN COALESCE(resolved_at, NOW()) END
+		WHERE id = $5
+		RETURNING updated_at, resolved_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	if err := m.DB.QueryRowContext(ctx, query, incident.Title, incident.Message, incident.Impact, incident.Status, incident.ID).Scan(&incident.UpdatedAt, &incident.ResolvedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return wrapError("incident", "Update", err)
+	}
+	return nil
+}
+
+// Delete removes an incident, for notes posted by mistake.
+func (m *IncidentModel) Delete(id int64) error {
+	query := `
+		DELETE FROM status_incidents
+		WHERE id = $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return wrapError("incident", "Delete", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return wrapError("incident", "Delete", err)
+	} else if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetAll returns a page of incidents, newest first.
+func (m *IncidentModel) GetAll(filter Filter) ([]*Incident, MetaData, error) {
+	query := `
+		SELECT COUNT(*) OVER(), id, title, message, impact, status, created_by, created_at, updated_at, resolved_at
+		FROM status_incidents
+		ORDER BY created_at DESC, id DESC
+		LIMIT $1 OFFSET $2
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filter.Limit(), filter.Offset())
+	if err != nil {
+		return nil, MetaData{}, wrapError("incident", "GetAll", err)
+	}
+	defer rows.Close()
+
+	incidents := []*Incident{}
+	totalRecords := int64(0)
+
+	for rows.Next() {
+		incident := &Incident{}
+		if err := rows.Scan(&totalRecords, &incident.ID, &incident.Title, &incident.Message, &incident.Impact, &incident.Status, &incident.CreatedBy, &incident.CreatedAt, &incident.UpdatedAt, &incident.ResolvedAt); err != nil {
+			return nil, MetaData{}, wrapError("incident", "GetAll", err)
+		}
+		incidents = append(incidents, incident)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, MetaData{}, wrapError("incident", "GetAll", err)
+	}
+
+	return incidents, CalculateMetaData(totalRecords, filter), nil
+}
+
+// GetRecent returns the open incidents and those resolved within the window, newest first, for
+// the public status endpoint. Who posted them is left out.
+func (m *IncidentModel) GetRecent(window time.Duration) ([]*Incident, error) {
+	query := `
+		SELECT id, title, message, impact, status, created_at, updated_at, resolved_at
+		FROM status_incidents
+		WHERE resolved_at IS NULL OR resolved_at > NOW() - make_interval(secs => $1)
+		ORDER BY created_at DESC, id DESC
+		LIMIT 20
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, window.Seconds())
+	if err != nil {
+		return nil, wrapError("incident", "GetRecent", err)
+	}
+	defer rows.Close()
+
+	incidents := []*Incident{}
+	for rows.Next() {
+		incident := &Incident{}
+		if err := rows.Scan(&incident.ID, &incident.Title, &incident.Message, &incident.Impact, &incident.Status, &incident.CreatedAt, &incident.UpdatedAt, &incident.ResolvedAt); err != nil {
+			return nil, wrapError("incident", "GetRecent", err)
+		}
+		incidents = append(incidents, incident)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("incident", "GetRecent", err)
+	}
+	return incidents, nil
+}
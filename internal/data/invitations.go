@@ -0,0 +1,192 @@
+// File: internal/data/invitations.go
+package data
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// Invitation lets a person create their own account with a role chosen by an admin.
+// Only the hash of the token is stored; the plaintext is emailed to the invitee.
+type Invitation struct {
+	Plaintext string    `json:"-"`
+	Hash      []byte    `json:"-"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	InvitedBy *int64    `json:"invited_by"`
+	CreatedAt Timestamp `json:"created_at"`
+	ExpiresAt Timestamp `json:"expires_at"`
+}
+
+// InvitationModel wraps a sql.DB connection pool.
+type InvitationModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+	Clock    Clock
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// New generates a token for the invitation and stores it, replacing any earlier invitation for the same email.
+func (m *InvitationModel) New(invitation *Invitation, ttl time.Duration) error {
+	var invitedBy int64
+	if invitation.InvitedBy != nil {
+		invitedBy = *invitation.InvitedBy
+	}
+	token, err := generateToken(invitedBy, ttl, "invitation", clockNow(m.Clock))
+	if err != nil {
+		return wrapError("invitation", "New", err)
+	}
+	invitation.Plaintext = token.Plaintext
+	invitation.Hash = token.Hash
+	invitation.ExpiresAt = token.ExpiresAt
+
+	query := `
+		INSERT INTO invitations (hash, email, role, invited_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, NOW(), $5)
+		ON CONFLICT (email) DO UPDATE
+		SET hash = EXCLUDED.hash, role = EXCLUDED.role, invited_by = EXCLUDED.invited_by,
+		    created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		RETURNING created_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, invitation.Hash, invitation.Email, invitation.Role, invitation.InvitedBy, invitation.ExpiresAt).Scan(&invitation.CreatedAt)
+	if err != nil {
+		if pqError, ok := err.(*pq.Error); ok && pqError.Code == "23503" { // foreign_key_violation
+			return ErrInvalidRole
+		}
+		return wrapError("invitation", "New", err)
+	}
+	return nil
+}
+
+// GetForToken retrieves an unexpired invitation by its plaintext token.
+func (m *InvitationModel) GetForToken(plaintext string) (*Invitation, error) {
+	query := `
+		SELECT hash, email, role, invited_by, created_at, expires_at
+		FROM invitations
+		WHERE hash = $1 AND expires_at > NOW()
+	`
+
+	hash := sha256.Sum256([]byte(plaintext))
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	invitation := &Invitation{Plaintext: plaintext}
+	err := m.DB.QueryRowContext(ctx, query, hash[:]).Scan(
+		&invitation.Hash,
+		&invitation.Email,
+		&invitation.Role,
+		&invitation.InvitedBy,
+		&invitation.CreatedAt,
+		&invitation.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, wrapError("invitation", "GetForToken", err)
+	}
+	return invitation, nil
+}
+
+// Accept creates an activated user for the invitation, gives them the permissions of the
+// invited role and removes the invitation, all in one transaction.
+func (m *InvitationModel) Accept(invitation *Invitation, user *User) error {
+	deleteQuery := `
+		DELETE FROM invitations
+		WHERE hash = $1 AND expires_at > NOW()
+	`
+	userQuery := `
+		INSERT INTO users (first_name, last_name, email, password_hash, role, is_active, timezone, locale, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, TRUE, $6, $7, NOW(), NOW())
+		RETURNING id, is_active, created_at, updated_at, version
+	`
+	permissionsQuery := `
+		INSERT INTO users_permissions (user_id, permission_id)
+		SELECT $1, rp.permission_id
+		FROM role_permissions rp
+		INNER JOIN roles r ON r.id = rp.role_id
+		WHERE r.name = $2
+		ON CONFLICT (user_id, permission_id) DO NOTHING
+	`
+
+	user.Email = invitation.Email
+	user.Role = invitation.Role
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+	if user.Locale == "" {
+		user.Locale = DefaultLocale
+	}
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapError("invitation", "Accept", err)
+	}
+	defer tx.Rollback()
+
+	// Deleting first makes the token single use even if two requests race
+	result, err := tx.ExecContext(ctx, deleteQuery, invitation.Hash)
+	if err != nil {
+		return wrapError("invitation", "Accept", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return wrapError("invitation", "Accept", err)
+	}
+	if rowsAffected == 0 {
+		return ErrInvalidToken
+	}
+
+	err = tx.QueryRowContext(ctx, userQuery,
+		user.FirstName,
+		user.LastName,
+		user.Email,
+		user.Password.hash,
+		user.Role,
+		user.Timezone,
+		user.Locale,
+	).Scan(&user.ID, &user.IsActive, &user.CreatedAt, &user.UpdatedAt, &user.Version)
+	if err != nil {
+		if pqError, ok := err.(*pq.Error); ok {
+			switch pqError.Code {
+			case "23505": // unique_violation
+				if strings.Contains(pqError.Detail, "email") {
+					return ErrDuplicateEmail
+				}
+			case "23503": // foreign_key_violation
+				return ErrInvalidRole
+			}
+		}
+		return wrapError("invitation", "Accept", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, permissionsQuery, user.ID, user.Role); err != nil {
+		return wrapError("invitation", "Accept", err)
+	}
+
+	return wrapError("invitation", "Accept", tx.Commit())
+}
@@ -1,24 +1,120 @@
 // File: internal/data/export.go
 package data
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+)
 
 type Models struct {
-	Permissions  PermissionModel
-	Products     ProductModel
-	Tokens       TokenModel
-	Users        UserModel
-	Sales        SaleModel
-	ChatbotModel ChatbotModel
+	Attachments   AttachmentModel
+	Audit         AuditModel
+	AuthEvents    AuthEventModel
+	Bootstrap     BootstrapModel
+	Calendar      CalendarModel
+	Confirmations ConfirmationModel
+	Customers     CustomerModel
+	Discounts     DiscountModel
+	Emails        EmailModel
+	ExchangeRates ExchangeRateModel
+	Incidents     IncidentModel
+	Invitations   InvitationModel
+	Payments      PaymentModel
+	Permissions   PermissionModel
+	Products      ProductModel
+	Receipts      ReceiptModel
+	Refunds       RefundModel
+	Reports       ReportModel
+	Roles         RoleModel
+	SavedReports  SavedReportModel
+	Schedules     ScheduleModel
+	Taxes         TaxModel
+	Tokens        TokenModel
+	Users         UserModel
+	Sales         SaleModel
+	Stock         StockModel
+	Targets       TargetModel
+	ChatbotModel  ChatbotModel
 }
 
-func NewModels(db *sql.DB) Models {
+// NewModels wires every model to the connection pool and the per class query timeouts.
+func NewModels(db *sql.DB, timeouts Timeouts) Models {
 	return Models{
-		Permissions:  PermissionModel{DB: db},
-		Products:     ProductModel{DB: db},
-		Tokens:       TokenModel{DB: db},
-		Users:        UserModel{DB: db},
-		Sales:        SaleModel{DB: db},
-		ChatbotModel: ChatbotModel{DB: db},
+		Attachments:   AttachmentModel{DB: db, Timeouts: timeouts},
+		Audit:         AuditModel{DB: db, Timeouts: timeouts},
+		AuthEvents:    AuthEventModel{DB: db, Timeouts: timeouts},
+		Bootstrap:     BootstrapModel{DB: db, Timeouts: timeouts},
+		Calendar:      CalendarModel{DB: db, Timeouts: timeouts},
+		Confirmations: ConfirmationModel{DB: db, Timeouts: timeouts},
+		Customers:     CustomerModel{DB: db, Timeouts: timeouts},
+		Discounts:     DiscountModel{DB: db, Timeouts: timeouts},
+		Emails:        EmailModel{DB: db, Timeouts: timeouts},
+		ExchangeRates: ExchangeRateModel{DB: db, Timeouts: timeouts},
+		Incidents:     IncidentModel{DB: db, Timeouts: timeouts},
+		Invitations:   InvitationModel{DB: db, Timeouts: timeouts},
+		Payments:      PaymentModel{DB: db, Timeouts: timeouts},
+		Permissions:   PermissionModel{DB: db, Timeouts: timeouts},
+		Products:      ProductModel{DB: db, Timeouts: timeouts},
+		Receipts:      ReceiptModel{DB: db, Timeouts: timeouts},
+		Refunds:       RefundModel{DB: db, Timeouts: timeouts},
+		Reports:       ReportModel{DB: db, Timeouts: timeouts},
+		Roles:         RoleModel{DB: db, Timeouts: timeouts},
+		SavedReports:  SavedReportModel{DB: db, Timeouts: timeouts},
+		Schedules:     ScheduleModel{DB: db, Timeouts: timeouts},
+		Taxes:         TaxModel{DB: db, Timeouts: timeouts},
+		Tokens:        TokenModel{DB: db, Timeouts: timeouts},
+		Users:         UserModel{DB: db, Timeouts: timeouts},
+		Sales:         SaleModel{DB: db, Timeouts: timeouts},
+		Stock:         StockModel{DB: db, Timeouts: timeouts},
+		Targets:       TargetModel{DB: db, Timeouts: timeouts},
+		ChatbotModel:  ChatbotModel{DB: db, Timeouts: timeouts},
 	}
 }
+
+// WithContext returns a copy of the models whose queries are cancelled when ctx is done, for
+// request handlers whose work should stop once the client has given up.
+func (m Models) WithContext(ctx context.Context) Models {
+	m.Attachments.Timeouts = m.Attachments.Timeouts.WithContext(ctx)
+	m.Audit.Timeouts = m.Audit.Timeouts.WithContext(ctx)
+	m.AuthEvents.Timeouts = m.AuthEvents.Timeouts.WithContext(ctx)
+	m.Bootstrap.Timeouts = m.Bootstrap.Timeouts.WithContext(ctx)
+	m.Calendar.Timeouts = m.Calendar.Timeouts.WithContext(ctx)
+	m.Confirmations.Timeouts = m.Confirmations.Timeouts.WithContext(ctx)
+	m.Customers.Timeouts = m.Customers.Timeouts.WithContext(ctx)
+	m.Discounts.Timeouts = m.Discounts.Timeouts.WithContext(ctx)
+	m.Emails.Timeouts = m.Emails.Timeouts.WithContext(ctx)
+	m.ExchangeRates.Timeouts = m.ExchangeRates.Timeouts.WithContext(ctx)
+	m.Incidents.Timeouts = m.Incidents.Timeouts.WithContext(ctx)
+	m.Invitations.Timeouts = m.Invitations.Timeouts.WithContext(ctx)
+	m.Payments.Timeouts = m.Payments.Timeouts.WithContext(ctx)
+	m.Permissions.Timeouts = m.Permissions.Timeouts.WithContext(ctx)
+	m.Products.Timeouts = m.Products.Timeouts.WithContext(ctx)
+	m.Receipts.Timeouts = m.Receipts.Timeouts.WithContext(ctx)
+	m.Refunds.Timeouts = m.Refunds.Timeouts.WithContext(ctx)
+	m.Reports.Timeouts = m.Reports.Timeouts.WithContext(ctx)
+	m.Roles.Timeouts = m.Roles.Timeouts.WithContext(ctx)
+	m.SavedReports.Timeouts = m.SavedReports.Timeouts.WithContext(ctx)
+	m.Schedules.Timeouts = m.Schedules.Timeouts.WithContext(ctx)
+	m.Taxes.Timeouts = m.Taxes.Timeouts.WithContext(ctx)
+	m.Tokens.Timeouts = m.Tokens.Timeouts.WithContext(ctx)
+	m.Users.Timeouts = m.Users.Timeouts.WithContext(ctx)
+	m.Sales.Timeouts = m.Sales.Timeouts.WithContext(ctx)
+	m.Stock.Timeouts = m.Stock.Timeouts.WithContext(ctx)
+	m.Targets.Timeouts = m.Targets.Timeouts.WithContext(ctx)
+	m.ChatbotModel.Timeouts = m.ChatbotModel.Timeouts.WithContext(ctx)
+	return m
+}
+
+// WithClock returns a copy of the models that read the current time from clock, for tests that
+// need tokens to expire or sales to be stamped at a known time.
+func (m Models) WithClock(clock Clock) Models {
+	m.AuthEvents.Clock = clock
+	m.Confirmations.Clock = clock
+	m.Invitations.Clock = clock
+	m.Schedules.Clock = clock
+	m.Tokens.Clock = clock
+	m.Users.Clock = clock
+	m.Sales.Clock = clock
+	m.ChatbotModel.Clock = clock
+	return m
+}
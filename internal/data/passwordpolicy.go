@@ -0,0 +1,154 @@
+// File: internal/data/passwordpolicy.go
+package data
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// Character classes a password policy can require.
+const (
+	PasswordClassNumber  = "number"
+	PasswordClassUpper   = "upper"
+	PasswordClassLower   = "lower"
+	PasswordClassSpecial = "special"
+)
+
+// PasswordClasses lists every class a policy can require, in display order.
+var PasswordClasses = []string{PasswordClassNumber, PasswordClassUpper, PasswordClassLower, PasswordClassSpecial}
+
+// PasswordPolicy holds the complexity rules applied to new passwords. It is served as JSON so
+// frontends can mirror the rules in their own validation.
+type PasswordPolicy struct {
+	MinLength         int      `json:"min_length"`
+	MaxLength         int      `json:"max_length"` // bcrypt ignores anything past 72 bytes
+	RequiredClasses   []string `json:"required_classes"`
+	SpecialCharacters string   `json:"special_characters"`
+	BannedPasswords   []string `json:"banned_passwords"` // compared case insensitively
+}
+
+// DefaultBannedPasswords are common passwords that pass the default complexity rules.
+var DefaultBannedPasswords = []string{
+	"password1!",
+	"passw0rd!",
+	"p@ssw0rd",
+	"p@ssw0rd1",
+	"p@ssword1",
+	"welcome1!",
+	"qwerty123!",
+	"admin123!",
+	"letmein1!",
+	"changeme1!",
+}
+
+// DefaultPasswordPolicy matches the rules enforced before the policy became configurable.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:         validator.PasswordMinLength,
+		MaxLength:         validator.PasswordMaxLength,
+		RequiredClasses:   slices.Clone(PasswordClasses),
+		SpecialCharacters: "!@#~$%^&*()+|_",
+		BannedPasswords:   slices.Clone(DefaultBannedPasswords),
+	}
+}
+
+var (
+	passwordPolicyMu sync.RWMutex
+	passwordPolicy   = DefaultPasswordPolicy()
+)
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// Validate checks that the policy itself is usable.
+func (p PasswordPolicy) Validate() error {
+	if p.MinLength < 1 || p.MinLength > p.MaxLength {
+		return fmt.Errorf("password minimum length must be between 1 and %d", p.MaxLength)
+	}
+	if p.MaxLength > validator.PasswordMaxLength {
+		return fmt.Errorf("password maximum length cannot exceed %d", validator.PasswordMaxLength)
+	}
+	for _, class := range p.RequiredClasses {
+		if !slices.Contains(PasswordClasses, class) {
+			return fmt.Errorf("unknown password class %q, expected one of %s", class, strings.Join(PasswordClasses, ", "))
+		}
+	}
+	if slices.Contains(p.RequiredClasses, PasswordClassSpecial) && p.SpecialCharacters == "" {
+		return errors.New("special characters must be listed when the special class is required")
+	}
+	return nil
+}
+
+// SetPasswordPolicy replaces the policy used by ValidatePasswordPlaintext.
+func SetPasswordPolicy(p PasswordPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	p.BannedPasswords = slices.Clone(p.BannedPasswords)
+	p.RequiredClasses = slices.Clone(p.RequiredClasses)
+	for i, banned := range p.BannedPasswords {
+		p.BannedPasswords[i] = strings.ToLower(banned)
+	}
+
+	passwordPolicyMu.Lock()
+	defer passwordPolicyMu.Unlock()
+	passwordPolicy = p
+	return nil
+}
+
+// ActivePasswordPolicy returns the policy currently applied to new passwords.
+func ActivePasswordPolicy() PasswordPolicy {
+	passwordPolicyMu.RLock()
+	defer passwordPolicyMu.RUnlock()
+	return passwordPolicy
+}
+
+// hasClass reports whether the password contains a character of the class.
+func (p PasswordPolicy) hasClass(password, class string) bool {
+	switch class {
+	case PasswordClassNumber:
+		return validator.PasswordNumberRX.MatchString(password)
+	case PasswordClassUpper:
+		return validator.PasswordUpperRX.MatchString(password)
+	case PasswordClassLower:
+		return validator.PasswordLowerRX.MatchString(password)
+	case PasswordClassSpecial:
+		return strings.ContainsAny(password, p.SpecialCharacters)
+	}
+	return false
+}
+
+// classMessages are the validation messages for a missing class.
+var classMessages = map[string]string{
+	PasswordClassNumber:  "must contain at least one number",
+	PasswordClassUpper:   "must contain at least one uppercase letter",
+	PasswordClassLower:   "must contain at least one lowercase letter",
+	PasswordClassSpecial: "must contain at least one special character",
+}
+
+// ValidatePasswordPlaintext checks a new password against the active policy.
+func ValidatePasswordPlaintext(v *validator.Validator, password string) {
+	p := ActivePasswordPolicy()
+
+	v.Check(password != "", "password", "must be provided")
+	v.Check(len(password) >= p.MinLength, "password", fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	v.Check(len(password) <= p.MaxLength, "password", fmt.Sprintf("must not be more than %d characters long", p.MaxLength))
+	for _, class := range p.RequiredClasses {
+		v.Check(p.hasClass(password, class), "password", classMessages[class])
+	}
+	v.Check(!slices.Contains(p.BannedPasswords, strings.ToLower(password)), "password", "is too common, please choose another")
+}
@@ -0,0 +1,252 @@
+// File: internal/data/payments.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// PaymentMethods are the tenders a sale can be paid with.
+var PaymentMethods = []string{"cash", "card", "transfer"}
+
+// MaxPaymentsPerRequest is the most payments one request may record against a sale.
+const MaxPaymentsPerRequest = 10
+
+// maxPaymentAmount is the largest amount the NUMERIC(14, 2) columns hold.
+const maxPaymentAmount = 999_999_999_999.99
+
+// Payment is one tender towards a sale, in the sale's currency. Cash payments may record the
+// amount handed over as Tendered, and the change given back is the difference.
+type Payment struct {
+	ID         int64     `json:"id"`
+	SaleID     int64     `json:"sale_id"`
+	Method     string    `json:"method"`
+	Amount     float64   `json:"amount"`
+	Tendered   *float64  `json:"tendered,omitempty"`
+	Change     float64   `json:"change"`
+	Reference  string    `json:"reference"` // card slip or transfer reference
+	RecordedBy *int64    `json:"recorded_by"`
+	CreatedAt  Timestamp `json:"created_at"`
+}
+
+// SalePayments are the payments of a sale and what is left to pay.
+type SalePayments struct {
+	SaleID     int64      `json:"sale_id"`
+	Currency   string     `json:"currency"`
+	Total      float64    `json:"total_amount"`
+	Paid       float64    `json:"amount_paid"`
+	BalanceDue float64    `json:"balance_due"` // negative when more was paid than the sale now totals
+	Payments   []*Payment `json:"payments"`
+}
+
+// PaymentModel wraps a sql.DB connection pool.
+type PaymentModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// ValidatePayment checks the method, amounts and reference of a payment. Only cash may be
+// tendered, and never less than the amount it pays.
+func ValidatePayment(v *validator.Validator, payment *Payment) {
+	v.Check(v.Permitted(payment.Method, PaymentMethods...), "method", "must be one of "+strings.Join(PaymentMethods, ", "))
+	v.Check(payment.Amount > 0, "amount", "must be greater than zero")
+	v.Check(payment.Amount <= maxPaymentAmount, "amount", "must not be more than 999999999999.99")
+	v.Check(isCents(payment.Amount), "amount", "must not have more than two decimal places")
+	v.Check(len(payment.Reference) <= 100, "reference", "must not be more than 100 bytes long")
+	if payment.Tendered != nil {
+		v.Check(payment.Method == "cash", "tendered", "must only be given for cash")
+		v.Check(*payment.Tendered <= maxPaymentAmount, "tendered", "must not be more than 999999999999.99")
+		v.Check(isCents(*payment.Tendered), "tendered", "must not have more than two decimal places")
+	}
+}
+
+// ValidatePayments checks a batch of payments recorded together against a sale.
+func ValidatePayments(v *validator.Validator, payments []*Payment) {
+	v.Check(len(payments) > 0, "payments", "must contain at least one payment")
+	v.Check(len(payments) <= MaxPaymentsPerRequest, "payments", fmt.Sprintf("must not contain more than %d payments", MaxPaymentsPerRequest))
+	for _, payment := range payments {
+		if payment == nil {
+			v.AddError("payments", "must not contain null")
+			return
+		}
+		if ValidatePayment(v, payment); !v.IsValid() {
+			return
+		}
+	}
+}
+
+// isCents reports whether an amount has at most two decimal places.
+func isCents(amount float64) bool {
+	return math.Abs(amount*100-math.Round(amount*100)) < 1e-6
+}
+
+// toCents converts an amount to whole cents, so sums of amounts compare exactly.
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// settle checks that payments pay off the balance due exactly, both in cents, and that cash
+// tendered covers the amount it pays. It returns ErrInsufficientCash when they fall short and
+// ErrOverpayment when they pay more than is due.
+func settle(dueCents int64, payments []*Payment) error {
+	var paidCents int64
+	for _, payment := range payments {
+		if payment.Tendered != nil && toCents(*payment.Tendered) < toCents(payment.Amount) {
+			return ErrInsufficientCash
+		}
+		paidCents += toCents(payment.Amount)
+	}
+
+	switch {
+	case paidCents < dueCents:
+		return ErrInsufficientCash
+	case paidCents > dueCents:
+		return ErrOverpayment
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// Record adds payments that together settle the balance due on a sale, in one transaction. The
+// sale is locked meanwhile, so two tills cannot both settle it. It returns the sale's payments
+// afterwards, or as they were along with ErrInsufficientCash when the payments fall short of the
+// balance and ErrOverpayment when they exceed it. ErrRecordNotFound means the sale is gone and
+// ErrSaleNotCompleted that it is a draft or was voided.
+func (m *PaymentModel) Record(saleID int64, payments []*Payment) (*SalePayments, error) {
+	query := `
+		INSERT INTO payments (sale_id, method, amount, tendered, reference, recorded_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, wrapError("payment", "Record", err)
+	}
+	defer tx.Rollback()
+
+	state, err := salePayments(ctx, tx, saleID, true)
+	if err != nil {
+		return nil, wrapError("payment", "Record", err)
+	}
+	if err := settle(toCents(state.BalanceDue), payments); err != nil {
+		return state, wrapError("payment", "Record", err)
+	}
+
+	for _, payment := range payments {
+		payment.SaleID = saleID
+		if err := tx.QueryRowContext(ctx, query, saleID, payment.Method, payment.Amount, payment.Tendered, payment.Reference, payment.RecordedBy).Scan(&payment.ID, &payment.CreatedAt); err != nil {
+			return nil, wrapError("payment", "Record", err)
+		}
+		if payment.Tendered != nil {
+			payment.Change = float64(toCents(*payment.Tendered)-toCents(payment.Amount)) / 100
+		}
+		state.Payments = append(state.Payments, payment)
+		state.Paid = float64(toCents(state.Paid)+toCents(payment.Amount)) / 100
+	}
+	state.BalanceDue = float64(toCents(state.Total)-toCents(state.Paid)) / 100
+
+	if err := tx.Commit(); err != nil {
+		return nil, wrapError("payment", "Record", err)
+	}
+	return state, nil
+}
+
+// GetForSale returns the payments of a sale, oldest first, and what is left to pay. It returns
+// ErrRecordNotFound when the sale does not exist.
+func (m *PaymentModel) GetForSale(saleID int64) (*SalePayments, error) {
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, wrapError("payment", "GetForSale", err)
+	}
+	defer tx.Rollback()
+
+	state, err := salePayments(ctx, tx, saleID, false)
+	if err != nil {
+		return nil, wrapError("payment", "GetForSale", err)
+	}
+	return state, wrapError("payment", "GetForSale", tx.Commit())
+}
+
+// salePayments reads the total and payments of a sale inside tx, locking the sale when lock is set
+// to pay it, which returns ErrSaleNotCompleted for drafts and voided sales.
+func salePayments(ctx context.Context, tx *sql.Tx, saleID int64, lock bool) (*SalePayments, error) {
+	saleQuery := `
+		SELECT id, currency, total_amount, status
+		FROM sales
+		WHERE id = $1
+	`
+	if lock {
+		saleQuery += "FOR UPDATE"
+	}
+	paymentsQuery := `
+		SELECT id, sale_id, method, amount, tendered, COALESCE(tendered - amount, 0), reference, recorded_by, created_at
+		FROM payments
+		WHERE sale_id = $1
+		ORDER BY id
+	`
+
+	state := &SalePayments{Payments: []*Payment{}}
+	var status string
+	if err := tx.QueryRowContext(ctx, saleQuery, saleID).Scan(&state.SaleID, &state.Currency, &state.Total, &status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	if lock && status != SaleCompleted {
+		return nil, ErrSaleNotCompleted // only completed sales are paid
+	}
+
+	rows, err := tx.QueryContext(ctx, paymentsQuery, saleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var paidCents int64
+	for rows.Next() {
+		payment := &Payment{}
+		if err := rows.Scan(&payment.ID, &payment.SaleID, &payment.Method, &payment.Amount, &payment.Tendered, &payment.Change, &payment.Reference, &payment.RecordedBy, &payment.CreatedAt); err != nil {
+			return nil, err
+		}
+		paidCents += toCents(payment.Amount)
+		state.Payments = append(state.Payments, payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	state.Paid = float64(paidCents) / 100
+	state.BalanceDue = float64(toCents(state.Total)-paidCents) / 100
+	return state, nil
+}
@@ -0,0 +1,70 @@
+// File: internal/data/payments_test.go
+// Description: test suite for payment validation and settling a sale's balance
+
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// TestValidatePayment tests the method, amount, tendered and reference rules of payments
+func TestValidatePayment(t *testing.T) {
+	amount := func(a float64) *float64 { return &a }
+	tests := []struct {
+		name       string
+		payment    Payment
+		errorField string
+	}{
+		{name: "Card", payment: Payment{Method: "card", Amount: 12.5, Reference: "slip 0042"}},
+		{name: "Cash With Change", payment: Payment{Method: "cash", Amount: 12.5, Tendered: amount(20)}},
+		{name: "Unknown Method", payment: Payment{Method: "cheque", Amount: 12.5}, errorField: "method"},
+		{name: "Zero Amount", payment: Payment{Method: "cash", Amount: 0}, errorField: "amount"},
+		{name: "Fractions Of A Cent", payment: Payment{Method: "cash", Amount: 0.125}, errorField: "amount"},
+		{name: "Tendered By Card", payment: Payment{Method: "card", Amount: 10, Tendered: amount(20)}, errorField: "tendered"},
+		{name: "Long Reference", payment: Payment{Method: "transfer", Amount: 10, Reference: string(make([]byte, 101))}, errorField: "reference"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidatePayment(v, &tt.payment)
+
+			if tt.errorField == "" && !v.IsValid() {
+				t.Errorf("expected a valid payment, got %v", v.Errors)
+			}
+			if _, ok := v.Errors[tt.errorField]; tt.errorField != "" && !ok {
+				t.Errorf("expected an error on %s, got %v", tt.errorField, v.Errors)
+			}
+		})
+	}
+}
+
+// TestSettle tests that payments must pay off the balance due exactly, with enough cash tendered
+func TestSettle(t *testing.T) {
+	amount := func(a float64) *float64 { return &a }
+	tests := []struct {
+		name     string
+		due      int64
+		payments []*Payment
+		expected error
+	}{
+		{name: "Exact Card", due: 1998, payments: []*Payment{{Method: "card", Amount: 19.98}}},
+		{name: "Split Cash And Card", due: 3000, payments: []*Payment{{Method: "cash", Amount: 10.1, Tendered: amount(20)}, {Method: "card", Amount: 19.9}}},
+		{name: "Cents Add Up", due: 30, payments: []*Payment{{Method: "cash", Amount: 0.1}, {Method: "cash", Amount: 0.2}}},
+		{name: "Short", due: 3000, payments: []*Payment{{Method: "card", Amount: 29.99}}, expected: ErrInsufficientCash},
+		{name: "Too Little Cash Tendered", due: 1000, payments: []*Payment{{Method: "cash", Amount: 10, Tendered: amount(5)}}, expected: ErrInsufficientCash},
+		{name: "Over", due: 1000, payments: []*Payment{{Method: "transfer", Amount: 10.01}}, expected: ErrOverpayment},
+		{name: "Already Settled", due: 0, payments: []*Payment{{Method: "cash", Amount: 1}}, expected: ErrOverpayment},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := settle(tt.due, tt.payments); !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
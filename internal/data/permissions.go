@@ -2,10 +2,8 @@
 package data
 
 import (
-	"context"
 	"database/sql"
 	"slices"
-	"time"
 
 	"github.com/lib/pq"
 )
@@ -14,6 +12,83 @@ import (
 // Permission Declarations
 /************************************************************************************************************/
 
+// Permission codes checked by the handlers. Every code the API knows is listed in PermissionCatalog,
+// which Seed writes to the permissions table.
+const (
+	PermissionSaleCreate = "sale:create"
+	PermissionSaleView   = "sale:view"
+	PermissionSaleUpdate = "sale:update"
+	PermissionSaleDelete = "sale:delete"
+	PermissionSaleRefund = "sale:refund" // refund sales in whole or in part, restocking the goods
+	PermissionSaleVoid   = "sale:void"   // void completed sales made by mistake, restocking the goods
+	PermissionSaleAssign = "sale:assign" // record and reattribute sales on behalf of other users
+
+	PermissionPolicyOverride = "policy:override" // refund sales outside the returns window, with a reason
+
+	PermissionProductCreate = "product:create"
+	PermissionProductView   = "product:view"
+	PermissionProductUpdate = "product:update"
+	PermissionProductDelete = "product:delete"
+
+	PermissionCustomerCreate = "customer:create"
+	PermissionCustomerView   = "customer:view"
+	PermissionCustomerUpdate = "customer:update"
+	PermissionCustomerDelete = "customer:delete"
+
+	PermissionUsersCreate      = "users:create"
+	PermissionUsersView        = "users:view"
+	PermissionUsersUpdate      = "users:update"
+	PermissionUsersDelete      = "users:delete"
+	PermissionUsersPermissions = "users:permissions" // grant and revoke individual permissions
+	PermissionUsersAdmin       = "users:admin"       // move users into or out of the admin role, act as other users
+
+	PermissionRolesCreate = "roles:create"
+	PermissionRolesView   = "roles:view"
+	PermissionRolesUpdate = "roles:update"
+	PermissionRolesDelete = "roles:delete"
+
+	PermissionReportsCashiers = "reports:cashiers" // compare the sales performance of individual users
+
+	PermissionTargetsView   = "targets:view"
+	PermissionTargetsUpdate = "targets:update" // set and remove monthly revenue targets
+
+	PermissionRatesUpdate = "rates:update" // set exchange rates against the base currency
+
+	PermissionIncidentsManage = "incidents:manage" // post and resolve incident notes on the public status endpoint
+
+	PermissionSecurityManage = "security:manage" // respond to suspected breaches, such as by signing users out in bulk
+
+	PermissionCalendarManage = "calendar:manage" // set opening hours and holidays
+
+	PermissionSchedulesManage = "schedules:manage" // schedule background jobs and run them on demand
+
+	PermissionDiscountsManage = "discounts:manage" // create, change and remove discount codes
+
+	PermissionTaxesManage = "taxes:manage" // set the tax rates added to sales
+
+	PermissionMetricsView = "metrics:view" // read the operational metrics, which expose internals of the deployment
+
+	PermissionSelfCreate = "self:create"
+	PermissionSelfView   = "self:view"
+	PermissionSelfUpdate = "self:update"
+	PermissionSelfDelete = "self:delete"
+)
+
+// PermissionCatalog lists every permission code in the system.
+var PermissionCatalog = Permissions{
+	PermissionSaleCreate, PermissionSaleView, PermissionSaleUpdate, PermissionSaleDelete, PermissionSaleRefund, PermissionSaleVoid, PermissionSaleAssign,
+	PermissionPolicyOverride,
+	PermissionProductCreate, PermissionProductView, PermissionProductUpdate, PermissionProductDelete,
+	PermissionCustomerCreate, PermissionCustomerView, PermissionCustomerUpdate, PermissionCustomerDelete,
+	PermissionUsersCreate, PermissionUsersView, PermissionUsersUpdate, PermissionUsersDelete, PermissionUsersPermissions, PermissionUsersAdmin,
+	PermissionRolesCreate, PermissionRolesView, PermissionRolesUpdate, PermissionRolesDelete,
+	PermissionReportsCashiers,
+	PermissionTargetsView, PermissionTargetsUpdate,
+	PermissionRatesUpdate, PermissionIncidentsManage, PermissionSecurityManage, PermissionCalendarManage, PermissionSchedulesManage,
+	PermissionDiscountsManage, PermissionTaxesManage, PermissionMetricsView,
+	PermissionSelfCreate, PermissionSelfView, PermissionSelfUpdate, PermissionSelfDelete,
+}
+
 // Permission struct to represent a permission in the system
 type Permission struct {
 	ID   int64  `json:"id"`
@@ -22,12 +97,20 @@ type Permission struct {
 
 // PermissionModel struct to interact with the permissions table in the database
 type PermissionModel struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Timeouts Timeouts
 }
 
 // Permissions type to represent a list of permissions
 type Permissions []string
 
+// UserPermission is a permission held by a user and whether it was granted individually
+// rather than copied from the user's role.
+type UserPermission struct {
+	Code    string `json:"code"`
+	IsGrant bool   `json:"is_grant"`
+}
+
 // Includes - Check if a specific permission code exists in the Permissions slice
 func (p Permissions) Includes(code string) bool {
 	return slices.Contains(p, code)
@@ -37,6 +120,20 @@ func (p Permissions) Includes(code string) bool {
 // Methods
 /*************************************************************************************************************/
 
+// Seed adds any code of PermissionCatalog missing from the permissions table.
+func (m *PermissionModel) Seed() error {
+	query := `
+		INSERT INTO permissions (code)
+		SELECT UNNEST($1::text[])
+		ON CONFLICT (code) DO NOTHING`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, pq.Array(PermissionCatalog))
+	return wrapError("permission", "Seed", err)
+}
+
 // GetAllForUser - Retrieve all permissions associated with a specific user role
 func (m *PermissionModel) GetAllForUser(user_id int64) (Permissions, error) {
 	query := `
@@ -46,13 +143,13 @@ func (m *PermissionModel) GetAllForUser(user_id int64) (Permissions, error) {
 		INNER JOIN users u ON up.user_id = u.id
 		WHERE up.user_id = $1`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second) // Set a 3-second timeout
-	defer cancel()                                                          // Ensure the context is canceled to free resources
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery) // Set a 3-second timeout
+	defer cancel()                                    // Ensure the context is canceled to free resources
 
 	// Execute the query
 	rows, err := m.DB.QueryContext(ctx, query, user_id)
 	if err != nil {
-		return nil, err
+		return nil, wrapError("permission", "GetAllForUser", err)
 	}
 
 	// Ensure the rows are closed after reading
@@ -64,7 +161,7 @@ func (m *PermissionModel) GetAllForUser(user_id int64) (Permissions, error) {
 		var code string // Temporary variable to hold the scanned code
 		// Scan the code from the current row
 		if err := rows.Scan(&code); err != nil {
-			return nil, err
+			return nil, wrapError("permission", "GetAllForUser", err)
 		}
 		permissions = append(permissions, code) // Append the scanned code to the permissions slice
 	}
@@ -81,19 +178,20 @@ func (m *PermissionModel) AssignPermissions(userID int64, codes Permissions) err
 		INSERT INTO users_permissions (user_id, permission_id)
 		SELECT $1, p.id
 		FROM permissions p
-		WHERE p.code = ANY($2)`
+		WHERE p.code = ANY($2)
+		ON CONFLICT (user_id, permission_id) DO NOTHING`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
 	defer cancel()
 
 	// Execute the insert statement with the provided role ID and permission codes
 	result, err := m.DB.ExecContext(ctx, query, userID, pq.Array(cleanCodes))
 	if err != nil {
-		return err
+		return wrapError("permission", "AssignPermissions", err)
 	}
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return wrapError("permission", "AssignPermissions", err)
 	}
 	if rowsAffected == 0 {
 		return ErrNoRecords
@@ -102,23 +200,23 @@ func (m *PermissionModel) AssignPermissions(userID int64, codes Permissions) err
 	return nil
 }
 
-// clearPermissions - Remove all permissions associated with a specific user
+// clearPermissions - Remove the role permissions associated with a specific user, keeping individual grants
 func (m *PermissionModel) ClearPermissions(userID int64) error {
 	query := `
 		DELETE FROM users_permissions
-		WHERE user_id = $1`
+		WHERE user_id = $1 AND is_grant = FALSE`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
 	defer cancel()
 
 	// Execute the delete statement with the provided user ID
 	result, err := m.DB.ExecContext(ctx, query, userID)
 	if err != nil {
-		return err
+		return wrapError("permission", "ClearPermissions", err)
 	}
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return wrapError("permission", "ClearPermissions", err)
 	}
 	if rowsAffected == 0 {
 		return ErrNoRecords
@@ -126,3 +224,93 @@ func (m *PermissionModel) ClearPermissions(userID int64) error {
 
 	return nil
 }
+
+// GetAllDetailedForUser - Retrieve the permissions of a user, flagging the ones granted individually
+func (m *PermissionModel) GetAllDetailedForUser(userID int64) ([]UserPermission, error) {
+	query := `
+		SELECT p.code, up.is_grant
+		FROM permissions p
+		INNER JOIN users_permissions up ON up.permission_id = p.id
+		WHERE up.user_id = $1
+		ORDER BY p.code ASC`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, wrapError("permission", "GetAllDetailedForUser", err)
+	}
+	defer rows.Close()
+
+	permissions := []UserPermission{}
+	for rows.Next() {
+		var permission UserPermission
+		if err := rows.Scan(&permission.Code, &permission.IsGrant); err != nil {
+			return nil, wrapError("permission", "GetAllDetailedForUser", err)
+		}
+		permissions = append(permissions, permission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("permission", "GetAllDetailedForUser", err)
+	}
+
+	return permissions, nil
+}
+
+// Grant - Give a user a single permission on top of their role defaults
+func (m *PermissionModel) Grant(userID int64, code string) error {
+	query := `
+		INSERT INTO users_permissions (user_id, permission_id, is_grant)
+		SELECT $1, p.id, TRUE
+		FROM permissions p
+		WHERE p.code = $2
+		ON CONFLICT (user_id, permission_id) DO UPDATE SET is_grant = TRUE`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, code)
+	if err != nil {
+		if pqError, ok := err.(*pq.Error); ok && pqError.Code == "23503" { // foreign_key_violation
+			return ErrRecordNotFound
+		}
+		return wrapError("permission", "Grant", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return wrapError("permission", "Grant", err)
+	}
+	if rowsAffected == 0 {
+		return ErrUnknownPermission
+	}
+
+	return nil
+}
+
+// Revoke - Remove a single permission from a user, whether it came from their role or a grant
+func (m *PermissionModel) Revoke(userID int64, code string) error {
+	query := `
+		DELETE FROM users_permissions up
+		USING permissions p
+		WHERE up.permission_id = p.id
+		AND up.user_id = $1
+		AND p.code = $2`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, code)
+	if err != nil {
+		return wrapError("permission", "Revoke", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return wrapError("permission", "Revoke", err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -2,10 +2,12 @@
 package data
 
 import (
-	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"time"
+	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
 	"github.com/lib/pq"
@@ -19,24 +21,71 @@ import (
 
 // Product represents a product in the system.
 type Product struct {
+	ID               int64     `json:"id"`
+	Name             string    `json:"name"`
+	Price            float64   `json:"price"`
+	Currency         string    `json:"currency"`
+	Tags             []string  `json:"tags"`              // lower case labels for groupings such as promotions
+	IsActive         bool      `json:"is_active"`         // inactive products cannot be sold but stay resolvable for past sales
+	IsArchived       bool      `json:"is_archived"`       // archived products are deleted as far as listings go, their sales are kept
+	StockQuantity    *int64    `json:"stock_quantity"`    // units on hand, null while stock is not tracked
+	ReorderThreshold int64     `json:"reorder_threshold"` // tracked stock below this is reported as low, 0 never is
+	CreatedAt        Timestamp `json:"created_at"`
+	UpdatedAt        Timestamp `json:"updated_at"`
+	CreatedBy        *int64    `json:"created_by"` // user who created the product, null for legacy rows
+	UpdatedBy        *int64    `json:"updated_by"` // user who last modified the product
+}
+
+// ProductPriceChange is one change of a product's price, recorded by ProductModel.Update.
+type ProductPriceChange struct {
 	ID        int64     `json:"id"`
-	Name      string    `json:"name"`
-	Price     float64   `json:"price"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ProductID int64     `json:"product_id"`
+	OldPrice  float64   `json:"old_price"`
+	NewPrice  float64   `json:"new_price"`
+	ChangedBy *int64    `json:"changed_by"` // null once the user who made the change is removed
+	ChangedAt Timestamp `json:"changed_at"`
+}
+
+// ProductSuggestion is the trimmed down product returned to search-as-you-type clients.
+type ProductSuggestion struct {
+	ID    int64   `json:"id"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
 }
 
+// ProductTag is a tag and the number of unarchived products carrying it.
+type ProductTag struct {
+	Tag      string `json:"tag"`
+	Products int64  `json:"products"`
+}
+
+// MaxProductTags is the most tags one product may carry.
+const MaxProductTags = 20
+
+// TagRX matches a normalized tag: letters and digits, with spaces, hyphens and underscores inside.
+var TagRX = regexp.MustCompile(`^[\p{Ll}\p{Lo}\p{N}]([\p{Ll}\p{Lo}\p{N} _-]*[\p{Ll}\p{Lo}\p{N}])?$`)
+
+// likeEscaper escapes the LIKE wildcards in user input.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
 // ProductModel wraps a sql.DB connection pool.
 type ProductModel struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Timeouts Timeouts
 }
 
 // ProductFilter represents filtering criteria for querying products.
 type ProductFilter struct {
-	Filter   Filter  `json:"filter"`
-	MinPrice float64 `json:"min_price"`
-	MaxPrice float64 `json:"max_price"`
-	Name     string  `json:"name"`
+	Filter    Filter   `json:"filter"`
+	MinPrice  float64  `json:"min_price"`
+	MaxPrice  float64  `json:"max_price"`
+	Name      string   `json:"name"`
+	CreatedBy *int64   `json:"created_by"`
+	UpdatedBy *int64   `json:"updated_by"`
+	Tags      []string `json:"tags"` // products must carry every one of these tags
+
+	IncludeInactive bool `json:"include_inactive"`
+	IncludeArchived bool `json:"include_archived"`
 }
 
 // ----------------------------------------------------------------------
@@ -49,20 +98,51 @@ func ValidateProduct(v *validator.Validator, product *Product) {
 	v.Check(product.Name != "", "name", "must be provided")
 	v.Check(len(product.Name) <= 200, "name", "must not be more than 200 bytes long")
 	v.Check(product.Price >= 0, "price", "must be a non-negative number")
+	ValidateCurrency(v, "currency", product.Currency)
+	ValidateTags(v, "tags", product.Tags)
+	v.Check(product.ReorderThreshold >= 0 && product.ReorderThreshold <= 1_000_000, "reorder_threshold", "must be between 0 and 1000000")
+}
+
+// NormalizeTags trims and lower cases tags, collapses inner whitespace and drops empty and
+// repeated ones, keeping the first occurrence order.
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// ValidateTags checks the number and format of normalized tags.
+func ValidateTags(v *validator.Validator, key string, tags []string) {
+	v.Check(len(tags) <= MaxProductTags, key, fmt.Sprintf("must not contain more than %d tags", MaxProductTags))
+	for _, tag := range tags {
+		if len(tag) > 50 {
+			v.AddError(key, "must not contain tags more than 50 bytes long")
+			return
+		}
+		if !v.Matches(tag, TagRX) {
+			v.AddError(key, "must only contain letters, digits, spaces, hyphens and underscores")
+			return
+		}
+	}
 }
 
 // Insert adds a new product to the database.
 func (m *ProductModel) Insert(product *Product) error {
 	query := `
-		INSERT INTO products (name, price, created_at, updated_at)
-		VALUES ($1, $2, NOW(), NOW())
-		RETURNING id, created_at, updated_at
+		INSERT INTO products (name, price, currency, tags, reorder_threshold, created_by, updated_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $5, $6, $4, $4, NOW(), NOW())
+		RETURNING id, is_active, created_at, updated_at
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
 	defer cancel()
 
-	if err := m.DB.QueryRowContext(ctx, query, product.Name, product.Price).Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt); err != nil {
+	if err := m.DB.QueryRowContext(ctx, query, product.Name, product.Price, product.Currency, product.CreatedBy, pq.Array(product.Tags), product.ReorderThreshold).Scan(&product.ID, &product.IsActive, &product.CreatedAt, &product.UpdatedAt); err != nil {
 		if pqError, ok := err.(*pq.Error); ok {
 			switch pqError.Code {
 			case "23514": // check_violation
@@ -71,47 +151,82 @@ func (m *ProductModel) Insert(product *Product) error {
 				return ErrInvalidData
 			}
 		}
-		return err
+		return wrapError("product", "Insert", err)
 	}
+	product.UpdatedBy = product.CreatedBy
 	return nil
 }
 
-// Update modifies an existing product in the database.
+// Update modifies an existing product in the database. A change of price is recorded in the
+// price history in the same statement, with UpdatedBy as the user who made it.
 func (m *ProductModel) Update(product *Product) error {
+	query := `
+		WITH previous AS (
+			SELECT id, price FROM products WHERE id = $4 FOR UPDATE
+		), updated AS (
+			UPDATE products p
+			SET name = $1, price = $2, currency = $5, tags = $6, reorder_threshold = $7, updated_by = $3, updated_at = NOW()
+			FROM previous
+			WHERE p.id = previous.id
+			RETURNING p.id, p.updated_at, previous.price AS old_price, p.price AS new_price
+		), history AS (
+			INSERT INTO product_price_history (product_id, old_price, new_price, changed_by)
+			SELECT id, old_price, new_price, $3 FROM updated WHERE old_price <> new_price
+		)
+		SELECT updated_at FROM updated
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	if err := m.DB.QueryRowContext(ctx, query, product.Name, product.Price, product.UpdatedBy, product.ID, product.Currency, pq.Array(product.Tags), product.ReorderThreshold).Scan(&product.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return wrapError("product", "Update", err)
+	}
+	return nil
+}
+
+// SetArchived archives or unarchives a product, recording who made the change. Products are
+// archived instead of deleted so the sales pointing at them keep their history.
+func (m *ProductModel) SetArchived(product *Product) error {
 	query := `
 		UPDATE products
-		SET name = $1, price = $2, updated_at = NOW()
+		SET is_archived = $1, updated_by = $2, updated_at = NOW()
 		WHERE id = $3
 		RETURNING updated_at
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
 	defer cancel()
 
-	if err := m.DB.QueryRowContext(ctx, query, product.Name, product.Price, product.ID).Scan(&product.UpdatedAt); err != nil {
-		return err
+	if err := m.DB.QueryRowContext(ctx, query, product.IsArchived, product.UpdatedBy, product.ID).Scan(&product.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return wrapError("product", "SetArchived", err)
 	}
 	return nil
 }
 
-// Delete removes a product from the database.
-func (m *ProductModel) Delete(id int64) error {
+// SetActive deactivates or reactivates a product, recording who made the change.
+func (m *ProductModel) SetActive(product *Product) error {
 	query := `
-		DELETE FROM products
-		WHERE id = $1
+		UPDATE products
+		SET is_active = $1, updated_by = $2, updated_at = NOW()
+		WHERE id = $3
+		RETURNING updated_at
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
-	if err != nil {
-		return err
-	}
-	if rowsAffected, err := result.RowsAffected(); err != nil {
-		return err
-	} else if rowsAffected == 0 {
-		return sql.ErrNoRows
+	if err := m.DB.QueryRowContext(ctx, query, product.IsActive, product.UpdatedBy, product.ID).Scan(&product.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return wrapError("product", "SetActive", err)
 	}
 	return nil
 }
@@ -119,39 +234,81 @@ func (m *ProductModel) Delete(id int64) error {
 // Get retrieves a product by its ID.
 func (m *ProductModel) Get(id int64) (*Product, error) {
 	query := `
-		SELECT id, name, price, created_at, updated_at
+		SELECT id, name, price, currency, tags, is_active, is_archived, stock_quantity, reorder_threshold, created_at, updated_at, created_by, updated_by
 		FROM products
 		WHERE id = $1
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
 	defer cancel()
 
-	product := &Product{}
-	if err := m.DB.QueryRowContext(ctx, query, id).Scan(&product.ID, &product.Name, &product.Price, &product.CreatedAt, &product.UpdatedAt); err != nil {
-		return nil, err
+	product := &Product{Tags: []string{}}
+	if err := m.DB.QueryRowContext(ctx, query, id).Scan(&product.ID, &product.Name, &product.Price, &product.Currency, pq.Array(&product.Tags), &product.IsActive, &product.IsArchived, &product.StockQuantity, &product.ReorderThreshold, &product.CreatedAt, &product.UpdatedAt, &product.CreatedBy, &product.UpdatedBy); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, wrapError("product", "Get", err)
 	}
 	return product, nil
 }
 
+// GetNames returns the names of the products with the given IDs, keyed by ID. Unknown IDs are left out.
+func (m *ProductModel) GetNames(ids []int64) (map[int64]string, error) {
+	query := `
+		SELECT id, name
+		FROM products
+		WHERE id = ANY($1)
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, wrapError("product", "GetNames", err)
+	}
+	defer rows.Close()
+
+	names := make(map[int64]string, len(ids))
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, wrapError("product", "GetNames", err)
+		}
+		names[id] = name
+	}
+	return names, wrapError("product", "GetNames", rows.Err())
+}
+
 // GetAll retrieves products based on filtering criteria and pagination.
 func (m *ProductModel) GetAll(filter ProductFilter) ([]*Product, MetaData, error) {
+	sortColumn, err := filter.Filter.SortColumn()
+	if err != nil {
+		return nil, MetaData{}, wrapError("product", "GetAll", err)
+	}
+
 	query := fmt.Sprintf(`
-		SELECT id, name, price, created_at, updated_at
+		SELECT COUNT(*) OVER(), id, name, price, currency, tags, is_active, is_archived, stock_quantity, reorder_threshold, created_at, updated_at, created_by, updated_by
 		FROM products
 		WHERE (price >= $1 OR $1 = 0)
 		  AND (price <= $2 OR $2 = 0)
 		  AND (name ILIKE '%%' || $3 || '%%' OR $3 = '')
+		  AND (created_by = $4 OR $4 IS NULL)
+		  AND (updated_by = $5 OR $5 IS NULL)
+		  AND (is_active OR $6)
+		  AND (NOT is_archived OR $7)
+		  AND (tags @> $10 OR $10 IS NULL)
 		ORDER BY %s %s
-		LIMIT $4 OFFSET $5
-	`, filter.Filter.SortColumn(), filter.Filter.SortDirection())
+		LIMIT $8 OFFSET $9
+	`, sortColumn, filter.Filter.SortDirection())
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query, filter.MinPrice, filter.MaxPrice, filter.Name, filter.Filter.Limit(), filter.Filter.Offset())
+	rows, err := m.DB.QueryContext(ctx, query, filter.MinPrice, filter.MaxPrice, filter.Name, filter.CreatedBy, filter.UpdatedBy, filter.IncludeInactive, filter.IncludeArchived, filter.Filter.Limit(), filter.Filter.Offset(), pq.Array(filter.Tags))
 	if err != nil {
-		return nil, MetaData{}, err
+		return nil, MetaData{}, wrapError("product", "GetAll", err)
 	}
 	defer rows.Close()
 
@@ -159,19 +316,125 @@ func (m *ProductModel) GetAll(filter ProductFilter) ([]*Product, MetaData, error
 	totalRecords := int64(0)
 
 	for rows.Next() {
-		product := &Product{}
-		if err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.CreatedAt, &product.UpdatedAt); err != nil {
-			return nil, MetaData{}, err
+		product := &Product{Tags: []string{}}
+		if err := rows.Scan(&totalRecords, &product.ID, &product.Name, &product.Price, &product.Currency, pq.Array(&product.Tags), &product.IsActive, &product.IsArchived, &product.StockQuantity, &product.ReorderThreshold, &product.CreatedAt, &product.UpdatedAt, &product.CreatedBy, &product.UpdatedBy); err != nil {
+			return nil, MetaData{}, wrapError("product", "GetAll", err)
 		}
 		products = append(products, product)
-		totalRecords++
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, MetaData{}, err
+		return nil, MetaData{}, wrapError("product", "GetAll", err)
 	}
 
-	metadata := CalculateMetaData(totalRecords, filter.Filter.Page, filter.Filter.PageSize)
+	metadata := CalculateMetaData(totalRecords, filter.Filter)
 
 	return products, metadata, nil
 }
+
+// Suggest returns active, unarchived products whose name starts with prefix, ignoring case, ordered by name.
+func (m *ProductModel) Suggest(prefix string, limit int) ([]*ProductSuggestion, error) {
+	query := `
+		SELECT id, name, price
+		FROM products
+		WHERE is_active AND NOT is_archived AND LOWER(name) LIKE $1
+		ORDER BY LOWER(name) ASC, id ASC
+		LIMIT $2
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	pattern := likeEscaper.Replace(strings.ToLower(prefix)) + "%"
+
+	rows, err := m.DB.QueryContext(ctx, query, pattern, limit)
+	if err != nil {
+		return nil, wrapError("product", "Suggest", err)
+	}
+	defer rows.Close()
+
+	suggestions := []*ProductSuggestion{}
+	for rows.Next() {
+		suggestion := &ProductSuggestion{}
+		if err := rows.Scan(&suggestion.ID, &suggestion.Name, &suggestion.Price); err != nil {
+			return nil, wrapError("product", "Suggest", err)
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("product", "Suggest", err)
+	}
+
+	return suggestions, nil
+}
+
+// GetTags returns every tag on an unarchived product with the number of products carrying it, by tag.
+func (m *ProductModel) GetTags() ([]*ProductTag, error) {
+	query := `
+		SELECT tag, COUNT(*)
+		FROM products, unnest(tags) AS tag
+		WHERE NOT is_archived
+		GROUP BY tag
+		ORDER BY tag
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, wrapError("product", "GetTags", err)
+	}
+	defer rows.Close()
+
+	tags := []*ProductTag{}
+	for rows.Next() {
+		tag := &ProductTag{}
+		if err := rows.Scan(&tag.Tag, &tag.Products); err != nil {
+			return nil, wrapError("product", "GetTags", err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("product", "GetTags", err)
+	}
+
+	return tags, nil
+}
+
+// GetPriceHistory returns the price changes of a product, newest first.
+func (m *ProductModel) GetPriceHistory(productID int64, filter Filter) ([]*ProductPriceChange, MetaData, error) {
+	query := `
+		SELECT COUNT(*) OVER(), id, product_id, old_price, new_price, changed_by, changed_at
+		FROM product_price_history
+		WHERE product_id = $1
+		ORDER BY changed_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, productID, filter.Limit(), filter.Offset())
+	if err != nil {
+		return nil, MetaData{}, wrapError("product", "GetPriceHistory", err)
+	}
+	defer rows.Close()
+
+	changes := []*ProductPriceChange{}
+	totalRecords := int64(0)
+
+	for rows.Next() {
+		change := &ProductPriceChange{}
+		if err := rows.Scan(&totalRecords, &change.ID, &change.ProductID, &change.OldPrice, &change.NewPrice, &change.ChangedBy, &change.ChangedAt); err != nil {
+			return nil, MetaData{}, wrapError("product", "GetPriceHistory", err)
+		}
+		changes = append(changes, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, MetaData{}, wrapError("product", "GetPriceHistory", err)
+	}
+
+	return changes, CalculateMetaData(totalRecords, filter), nil
+}
@@ -0,0 +1,64 @@
+// File: internal/data/receipts.go
+package data
+
+import (
+	"database/sql"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// ReceiptPreference records whether a customer address wants its receipts emailed.
+type ReceiptPreference struct {
+	Email         string    `json:"email"`
+	EmailReceipts bool      `json:"email_receipts"`
+	UpdatedBy     *int64    `json:"updated_by"`
+	UpdatedAt     Timestamp `json:"updated_at"`
+}
+
+// ReceiptModel wraps a sql.DB connection pool.
+type ReceiptModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// SetPreference records whether the address wants email receipts, replacing any earlier choice.
+// Addresses are compared without regard to case.
+func (m *ReceiptModel) SetPreference(preference *ReceiptPreference) error {
+	query := `
+		INSERT INTO receipt_preferences (email, email_receipts, updated_by, updated_at)
+		VALUES (LOWER($1), $2, $3, NOW())
+		ON CONFLICT (email) DO UPDATE
+		SET email_receipts = EXCLUDED.email_receipts, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+		RETURNING email, updated_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, preference.Email, preference.EmailReceipts, preference.UpdatedBy).Scan(&preference.Email, &preference.UpdatedAt)
+}
+
+// WantsEmail reports whether the address has opted in to email receipts. Addresses that never
+// chose have not.
+func (m *ReceiptModel) WantsEmail(email string) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM receipt_preferences WHERE email = LOWER($1) AND email_receipts)
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	var wants bool
+	err := m.DB.QueryRowContext(ctx, query, email).Scan(&wants)
+	return wants, wrapError("receipt", "WantsEmail", err)
+}
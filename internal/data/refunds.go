@@ -0,0 +1,341 @@
+// File: internal/data/refunds.go
+package data
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// Refund gives back some or all of the items of a sale, at the prices they were sold at less their
+// part of the sale's discount and with their part of its tax. Refunded goods go back into stock, and
+// reports count the refund as negative revenue and tax when it happened.
+type Refund struct {
+	ID         int64         `json:"id"`
+	SaleID     int64         `json:"sale_id"`
+	Reason     string        `json:"reason"`
+	Items      []*RefundItem `json:"items"`
+	Quantity   int64         `json:"quantity"`     // units across all items
+	Tax        float64       `json:"tax_amount"`   // the tax given back, included in the total
+	Total      float64       `json:"total_amount"` // sum of the line totals, in the sale's currency
+	Currency   string        `json:"currency"`
+	CreatedBy  *int64        `json:"created_by"`
+	RefundedAt Timestamp     `json:"refunded_at"`
+}
+
+// RefundItem is how many units of one product of a sale were refunded.
+type RefundItem struct {
+	ProductID int64   `json:"product_id"`
+	Quantity  int64   `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"` // as sold, before the sale's discount
+	Currency  string  `json:"currency"`
+	LineTotal float64 `json:"line_total"` // what is given back, after the sale's discount and with tax
+	Tax       float64 `json:"tax_amount"` // the part of the line total that is tax
+}
+
+// refundable is what is left to refund of one product of a sale: the units not yet refunded with
+// the price they were sold at, and the amounts in cents that were paid for and already given back,
+// in all and of tax.
+type refundable struct {
+	RefundItem
+	sold             int64
+	paidCents        int64
+	refundedCents    int64
+	taxCents         int64
+	refundedTaxCents int64
+}
+
+// ActionRefundOverride is the audit log action of a refund made outside the returns window.
+const ActionRefundOverride = "sales:refund_override"
+
+// RefundModel wraps a sql.DB connection pool.
+type RefundModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// ValidateRefund checks the reason and items of a refund. No items means everything left on the
+// sale is refunded.
+func ValidateRefund(v *validator.Validator, refund *Refund) {
+	v.Check(len(refund.Reason) <= 500, "reason", "must not be more than 500 bytes long")
+	v.Check(len(refund.Items) <= MaxSaleItems, "items", "must not contain more than 100 items")
+
+	seen := make(map[int64]bool, len(refund.Items))
+	for _, item := range refund.Items {
+		if item == nil {
+			v.AddError("items", "must not contain null")
+			return
+		}
+		v.Check(item.ProductID > 0, "items", "must only contain positive product IDs")
+		v.Check(item.Quantity > 0, "items", "must only contain quantities greater than zero")
+		v.Check(!seen[item.ProductID], "items", "must not contain a product more than once")
+		seen[item.ProductID] = true
+	}
+}
+
+// WithinReturnsWindow reports whether a sale sold at soldAt may still be refunded at now, under a
+// returns window of days after the sale. A window of 0 days allows refunds at any time.
+func WithinReturnsWindow(soldAt, now time.Time, days int) bool {
+	return days <= 0 || !now.After(soldAt.AddDate(0, 0, days))
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// Insert refunds the items of a sale in one transaction, pricing each as it was sold and putting
+// tracked products back into stock. With no items, every unit not yet refunded is. The sale is
+// locked meanwhile, so two refunds cannot both give back the same units. It returns
+// ErrRecordNotFound when the sale is gone and ErrRefundExceedsSale when an item is not on the sale
+// or more units are asked for than are left to refund.
+func (m *RefundModel) Insert(refund *Refund) error {
+	query := `
+		INSERT INTO refunds (sale_id, reason, tax_amount, total_amount, currency, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, refunded_at
+	`
+	itemQuery := `
+		INSERT INTO refund_items (refund_id, product_id, quantity, unit_price, currency, line_total, tax_amount)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapError("refund", "Insert", err)
+	}
+	defer tx.Rollback()
+
+	refundable, err := refundableItems(ctx, tx, refund.SaleID)
+	if err != nil {
+		return wrapError("refund", "Insert", err)
+	}
+	if err := refund.price(refundable); err != nil {
+		return wrapError("refund", "Insert", err)
+	}
+
+	err = tx.QueryRowContext(ctx, query, refund.SaleID, refund.Reason, refund.Tax, refund.Total, refund.Currency, refund.CreatedBy).Scan(&refund.ID, &refund.RefundedAt)
+	if err != nil {
+		return wrapError("refund", "Insert", err)
+	}
+	for _, item := range refund.Items {
+		if _, err := tx.ExecContext(ctx, itemQuery, refund.ID, item.ProductID, item.Quantity, item.UnitPrice, item.Currency, item.LineTotal, item.Tax); err != nil {
+			return wrapError("refund", "Insert", err)
+		}
+	}
+	// Stock goes back in product ID order, the order sales take it in, so they cannot deadlock
+	for _, item := range slices.SortedStableFunc(slices.Values(refund.Items), func(a, b *RefundItem) int { return cmp.Compare(a.ProductID, b.ProductID) }) {
+		if err := returnRefundStock(ctx, tx, refund, item); err != nil {
+			return wrapError("refund", "Insert", err)
+		}
+	}
+
+	return wrapError("refund", "Insert", tx.Commit())
+}
+
+// price fills in the prices and totals of the refund's items from what is left to refund of each
+// product, or refunds all of it when no items were asked for. Units are given back at their share of
+// what was paid for the item after discounts and with tax, of which their share of the tax is
+// recorded; the last units refunded take whatever is left of both, so refunds never add up to more
+// or less than was paid.
+func (refund *Refund) price(items []*refundable) error {
+	if len(refund.Items) == 0 {
+		for _, left := range items {
+			if left.Quantity > 0 {
+				refund.Items = append(refund.Items, &RefundItem{ProductID: left.ProductID, Quantity: left.Quantity})
+			}
+		}
+		if len(refund.Items) == 0 {
+			return ErrRefundExceedsSale
+		}
+	}
+
+	var totalCents, totalTaxCents int64
+	refund.Quantity = 0
+	for _, item := range refund.Items {
+		var left *refundable
+		for _, candidate := range items {
+			if candidate.ProductID == item.ProductID {
+				left = candidate
+				break
+			}
+		}
+		if left == nil || item.Quantity > left.Quantity {
+			return ErrRefundExceedsSale
+		}
+
+		item.UnitPrice, item.Currency = left.UnitPrice, left.Currency
+		lineCents, taxCents := left.paidCents-left.refundedCents, left.taxCents-left.refundedTaxCents
+		if item.Quantity < left.Quantity {
+			lineCents = int64(math.Round(float64(left.paidCents) * float64(item.Quantity) / float64(left.sold)))
+			taxCents = int64(math.Round(float64(left.taxCents) * float64(item.Quantity) / float64(left.sold)))
+		}
+		item.LineTotal, item.Tax = float64(lineCents)/100, float64(taxCents)/100
+		totalCents += lineCents
+		totalTaxCents += taxCents
+		refund.Quantity += item.Quantity
+		refund.Currency = item.Currency
+	}
+	refund.Total, refund.Tax = float64(totalCents)/100, float64(totalTaxCents)/100
+	return nil
+}
+
+// refundableItems locks a sale inside tx and returns what is left to refund of each of its products,
+// in the order of the sale. Only completed sales can be refunded, others return ErrSaleNotCompleted.
+func refundableItems(ctx context.Context, tx *sql.Tx, saleID int64) ([]*refundable, error) {
+	lockQuery := `
+		SELECT status
+		FROM sales
+		WHERE id = $1
+		FOR UPDATE
+	`
+	query := `
+		SELECT i.product_id, i.quantity - COALESCE(r.quantity, 0), i.unit_price, i.currency,
+		       i.quantity, i.line_total - i.discount_amount + i.tax_amount, COALESCE(r.line_total, 0),
+		       i.tax_amount, COALESCE(r.tax_amount, 0)
+		FROM sale_items i
+		LEFT JOIN (
+			SELECT ri.product_id, SUM(ri.quantity) AS quantity, SUM(ri.line_total) AS line_total, SUM(ri.tax_amount) AS tax_amount
+			FROM refund_items ri
+			JOIN refunds r ON r.id = ri.refund_id
+			WHERE r.sale_id = $1
+			GROUP BY ri.product_id
+		) r ON r.product_id = i.product_id
+		WHERE i.sale_id = $1
+		ORDER BY i.id
+	`
+
+	var status string
+	if err := tx.QueryRowContext(ctx, lockQuery, saleID).Scan(&status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	if status != SaleCompleted {
+		return nil, ErrSaleNotCompleted
+	}
+
+	rows, err := tx.QueryContext(ctx, query, saleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*refundable{}
+	for rows.Next() {
+		item := &refundable{}
+		var paid, refunded, tax, refundedTax float64
+		if err := rows.Scan(&item.ProductID, &item.Quantity, &item.UnitPrice, &item.Currency, &item.sold, &paid, &refunded, &tax, &refundedTax); err != nil {
+			return nil, err
+		}
+		item.paidCents, item.refundedCents = toCents(paid), toCents(refunded)
+		item.taxCents, item.refundedTaxCents = toCents(tax), toCents(refundedTax)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetAllForSale returns the refunds of a sale, oldest first, with their items. It returns
+// ErrRecordNotFound when the sale does not exist.
+func (m *RefundModel) GetAllForSale(saleID int64) ([]*Refund, error) {
+	saleQuery := `
+		SELECT EXISTS (SELECT 1 FROM sales WHERE id = $1)
+	`
+	query := `
+		SELECT id, sale_id, reason, tax_amount, total_amount, currency, created_by, refunded_at
+		FROM refunds
+		WHERE sale_id = $1
+		ORDER BY id
+	`
+	itemsQuery := `
+		SELECT ri.refund_id, ri.product_id, ri.quantity, ri.unit_price, ri.currency, ri.line_total, ri.tax_amount
+		FROM refund_items ri
+		JOIN refunds r ON r.id = ri.refund_id
+		WHERE r.sale_id = $1
+		ORDER BY ri.refund_id, ri.id
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, wrapError("refund", "GetAllForSale", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, saleQuery, saleID).Scan(&exists); err != nil {
+		return nil, wrapError("refund", "GetAllForSale", err)
+	}
+	if !exists {
+		return nil, ErrRecordNotFound
+	}
+
+	rows, err := tx.QueryContext(ctx, query, saleID)
+	if err != nil {
+		return nil, wrapError("refund", "GetAllForSale", err)
+	}
+	defer rows.Close()
+
+	refunds := []*Refund{}
+	byID := make(map[int64]*Refund)
+	for rows.Next() {
+		refund := &Refund{Items: []*RefundItem{}}
+		if err := rows.Scan(&refund.ID, &refund.SaleID, &refund.Reason, &refund.Tax, &refund.Total, &refund.Currency, &refund.CreatedBy, &refund.RefundedAt); err != nil {
+			return nil, wrapError("refund", "GetAllForSale", err)
+		}
+		refunds = append(refunds, refund)
+		byID[refund.ID] = refund
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("refund", "GetAllForSale", err)
+	}
+	rows.Close()
+
+	itemRows, err := tx.QueryContext(ctx, itemsQuery, saleID)
+	if err != nil {
+		return nil, wrapError("refund", "GetAllForSale", err)
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var refundID int64
+		item := &RefundItem{}
+		if err := itemRows.Scan(&refundID, &item.ProductID, &item.Quantity, &item.UnitPrice, &item.Currency, &item.LineTotal, &item.Tax); err != nil {
+			return nil, wrapError("refund", "GetAllForSale", err)
+		}
+		if refund, ok := byID[refundID]; ok {
+			refund.Items = append(refund.Items, item)
+			refund.Quantity += item.Quantity
+		}
+	}
+	if err := itemRows.Err(); err != nil {
+		return nil, wrapError("refund", "GetAllForSale", err)
+	}
+
+	return refunds, wrapError("refund", "GetAllForSale", tx.Commit())
+}
@@ -0,0 +1,146 @@
+// File: internal/data/refunds_test.go
+// Description: test suite for validating and pricing refunds
+
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// TestValidateRefund tests the reason and item rules of refunds
+func TestValidateRefund(t *testing.T) {
+	tests := []struct {
+		name       string
+		refund     Refund
+		errorField string
+	}{
+		{name: "Whole Sale", refund: Refund{Reason: "changed their mind"}},
+		{name: "Some Items", refund: Refund{Items: []*RefundItem{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}}}},
+		{name: "Long Reason", refund: Refund{Reason: string(make([]byte, 501))}, errorField: "reason"},
+		{name: "Null Item", refund: Refund{Items: []*RefundItem{nil}}, errorField: "items"},
+		{name: "Zero Quantity", refund: Refund{Items: []*RefundItem{{ProductID: 1, Quantity: 0}}}, errorField: "items"},
+		{name: "Missing Product", refund: Refund{Items: []*RefundItem{{Quantity: 1}}}, errorField: "items"},
+		{name: "Product Twice", refund: Refund{Items: []*RefundItem{{ProductID: 1, Quantity: 1}, {ProductID: 1, Quantity: 1}}}, errorField: "items"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateRefund(v, &tt.refund)
+
+			if tt.errorField == "" && !v.IsValid() {
+				t.Errorf("expected a valid refund, got %v", v.Errors)
+			}
+			if _, ok := v.Errors[tt.errorField]; tt.errorField != "" && !ok {
+				t.Errorf("expected an error on %s, got %v", tt.errorField, v.Errors)
+			}
+		})
+	}
+}
+
+// TestRefundPrice tests that refunds are priced as paid and never give back more than is left
+func TestRefundPrice(t *testing.T) {
+	left := func(productID, quantity int64, unitPrice float64, sold, paidCents, refundedCents int64) *refundable {
+		return &refundable{
+			RefundItem: RefundItem{ProductID: productID, Quantity: quantity, UnitPrice: unitPrice, Currency: "USD"},
+			sold:       sold, paidCents: paidCents, refundedCents: refundedCents,
+		}
+	}
+	leftToRefund := func() []*refundable {
+		return []*refundable{
+			left(1, 3, 9.99, 3, 2997, 0),
+			left(2, 0, 5, 1, 500, 500), // already refunded
+			left(3, 2, 0.1, 2, 20, 0),
+			left(4, 2, 10, 3, 2000, 667), // a third off, one unit already refunded
+		}
+	}
+
+	tests := []struct {
+		name             string
+		items            []*RefundItem
+		expected         error
+		expectedTotal    float64
+		expectedQuantity int64
+		expectedItems    int
+	}{
+		{name: "Everything Left", expectedTotal: 43.50, expectedQuantity: 7, expectedItems: 3},
+		{name: "Part Of An Item", items: []*RefundItem{{ProductID: 1, Quantity: 2}}, expectedTotal: 19.98, expectedQuantity: 2, expectedItems: 1},
+		{name: "Cents Add Up", items: []*RefundItem{{ProductID: 3, Quantity: 2}}, expectedTotal: 0.2, expectedQuantity: 2, expectedItems: 1},
+		{name: "Discounted Unit", items: []*RefundItem{{ProductID: 4, Quantity: 1}}, expectedTotal: 6.67, expectedQuantity: 1, expectedItems: 1},
+		{name: "Discounted Rest", items: []*RefundItem{{ProductID: 4, Quantity: 2}}, expectedTotal: 13.33, expectedQuantity: 2, expectedItems: 1},
+		{name: "More Than Left", items: []*RefundItem{{ProductID: 1, Quantity: 4}}, expected: ErrRefundExceedsSale},
+		{name: "Already Refunded", items: []*RefundItem{{ProductID: 2, Quantity: 1}}, expected: ErrRefundExceedsSale},
+		{name: "Not On The Sale", items: []*RefundItem{{ProductID: 9, Quantity: 1}}, expected: ErrRefundExceedsSale},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refund := &Refund{Items: tt.items}
+			err := refund.price(leftToRefund())
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, err)
+			}
+			if err != nil {
+				return
+			}
+			if refund.Total != tt.expectedTotal || refund.Quantity != tt.expectedQuantity || len(refund.Items) != tt.expectedItems {
+				t.Errorf("expected %d items of %d units totalling %.2f, got %d of %d totalling %.2f",
+					tt.expectedItems, tt.expectedQuantity, tt.expectedTotal, len(refund.Items), refund.Quantity, refund.Total)
+			}
+			if refund.Currency != "USD" || refund.Items[0].UnitPrice == 0 {
+				t.Errorf("expected items priced as sold in USD, got %+v", refund.Items[0])
+			}
+		})
+	}
+
+	// Tax is given back with the units, the last ones taking what is left of it
+	taxed := []*refundable{left(5, 3, 10, 3, 3375, 0)}
+	taxed[0].taxCents = 375
+	for i, expectedTax := range []float64{1.25, 2.5} {
+		refund := &Refund{Items: []*RefundItem{{ProductID: 5, Quantity: int64(i + 1)}}}
+		if err := refund.price(taxed); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if refund.Tax != expectedTax || refund.Items[0].Tax != expectedTax {
+			t.Errorf("refunding %d taxed units: expected %.2f of tax, got %.2f", i+1, expectedTax, refund.Tax)
+		}
+		taxed[0].Quantity -= refund.Quantity
+		taxed[0].refundedCents += toCents(refund.Total)
+		taxed[0].refundedTaxCents += toCents(refund.Tax)
+	}
+
+	// Nothing left to refund at all
+	refund := &Refund{}
+	if err := refund.price([]*refundable{left(1, 0, 1, 1, 100, 100)}); !errors.Is(err, ErrRefundExceedsSale) {
+		t.Errorf("expected ErrRefundExceedsSale for a fully refunded sale, got %v", err)
+	}
+}
+
+// TestWithinReturnsWindow tests refunds are allowed up to the end of the window, and always without one
+func TestWithinReturnsWindow(t *testing.T) {
+	soldAt := time.Date(2025, time.March, 1, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		days int
+		want bool
+	}{
+		{name: "Same Day", now: soldAt.Add(time.Hour), days: 30, want: true},
+		{name: "Last Moment", now: soldAt.AddDate(0, 0, 30), days: 30, want: true},
+		{name: "Day After", now: soldAt.AddDate(0, 0, 31), days: 30, want: false},
+		{name: "No Window", now: soldAt.AddDate(2, 0, 0), days: 0, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WithinReturnsWindow(soldAt, tt.now, tt.days); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,454 @@
+// File: internal/data/reportbuilder.go
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+const (
+	// DefaultCustomReportRows is the row limit of a definition that does not set one.
+	DefaultCustomReportRows = 1000
+	// MaxCustomReportRows is the most rows a custom report may return.
+	MaxCustomReportRows = 10000
+	// maxReportFilterIDs caps the IDs a single filter may list.
+	maxReportFilterIDs = 100
+)
+
+// ReportDefinition describes a custom report: the dimensions sales are grouped by, the measures
+// totalled for each group and the sales included. Definitions only name columns from the fixed
+// lists below, so compiling one never puts caller input into the SQL text.
+type ReportDefinition struct {
+	Dimensions []string      `json:"dimensions"`
+	Measures   []string      `json:"measures"`
+	Filters    ReportFilters `json:"filters"`
+	Sort       string        `json:"sort"`  // a column of the report, "-" prefixed for descending; defaults to the dimensions in order
+	Limit      int           `json:"limit"` // defaults to DefaultCustomReportRows
+}
+
+// ReportFilters narrows the sales a custom report covers, on top of its date range.
+type ReportFilters struct {
+	ProductIDs []int64 `json:"product_ids,omitempty"`
+	UserIDs    []int64 `json:"user_ids,omitempty"`
+}
+
+// CustomReport is the result of running a ReportDefinition over a date range. Each row holds one
+// value per column, in column order.
+type CustomReport struct {
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Timezone  string   `json:"timezone"`
+	Currency  string   `json:"currency"` // of the revenue column
+	Columns   []string `json:"columns"`
+	Rows      [][]any  `json:"rows"`
+	Truncated bool     `json:"truncated"` // more groups matched than the definition's limit
+}
+
+// SavedReport is a report definition a user saved under a name to run again later.
+type SavedReport struct {
+	ID         int64            `json:"id"`
+	Name       string           `json:"name"`
+	Definition ReportDefinition `json:"definition"`
+	CreatedBy  int64            `json:"created_by"`
+	CreatedAt  Timestamp        `json:"created_at"`
+	UpdatedAt  Timestamp        `json:"updated_at"`
+}
+
+// reportColumn is a column a definition can select, with the SQL that produces it.
+type reportColumn struct {
+	name string
+	expr string
+	scan func() any // returns a fresh destination for the column's value
+}
+
+func scanInt64() any   { return new(int64) }
+func scanFloat64() any { return new(float64) }
+func scanString() any  { return new(string) }
+
+// ReportDimensions lists the dimensions a custom report can group by, in the order they are offered.
+var ReportDimensions = []string{"product", "user", "day"}
+
+// ReportMeasures lists the measures a custom report can total, in the order they are offered.
+var ReportMeasures = []string{"revenue", "tax", "quantity", "count"}
+
+// reportDimensionColumns maps each dimension to the columns it adds. The day is taken in the
+// report's timezone, always the third query parameter.
+var reportDimensionColumns = map[string][]reportColumn{
+	"product": {
+		{name: "product_id", expr: "p.id", scan: scanInt64},
+		{name: "product_name", expr: "p.name", scan: scanString},
+	},
+	"user": {
+		{name: "user_id", expr: "u.id", scan: scanInt64},
+		{name: "user_name", expr: "u.first_name || ' ' || u.last_name", scan: scanString},
+	},
+	"day": {
+		{name: "day", expr: "to_char(i.occurred_at AT TIME ZONE $3, 'YYYY-MM-DD')", scan: scanString},
+	},
+}
+
+// reportMeasureColumns maps each measure to its aggregate. Revenue is at the prices the items were
+// sold at, as in the other reports, and tax is what was charged on top of it, both converted by the
+// factors joined in as fx. Refunded items are negative lines, so revenue, tax and quantity are net
+// of refunds while count only counts sales.
+var reportMeasureColumns = map[string]reportColumn{
+	"revenue":  {name: "revenue", expr: "COALESCE(ROUND(SUM(i.line_total * fx.factor)::numeric, 2), 0)::float8", scan: scanFloat64},
+	"tax":      {name: "tax", expr: "COALESCE(ROUND(SUM(i.tax_amount * fx.factor)::numeric, 2), 0)::float8", scan: scanFloat64},
+	"quantity": {name: "quantity", expr: "COALESCE(SUM(i.quantity), 0)::bigint", scan: scanInt64},
+	"count":    {name: "count", expr: "COUNT(DISTINCT s.id) FILTER (WHERE i.refund_id IS NULL)", scan: scanInt64},
+}
+
+// SavedReportModel wraps a sql.DB connection pool.
+type SavedReportModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// ValidateReportDefinition checks that a definition only names known dimensions and measures,
+// each at most once, and that its sort, limit and filters are usable.
+func ValidateReportDefinition(v *validator.Validator, def *ReportDefinition) {
+	v.Check(len(def.Measures) > 0, "measures", "must contain at least one measure")
+	v.Check(distinct(def.Dimensions), "dimensions", "must not contain duplicate values")
+	v.Check(distinct(def.Measures), "measures", "must not contain duplicate values")
+	for _, dimension := range def.Dimensions {
+		v.Check(v.Permitted(dimension, ReportDimensions...), "dimensions", "must only contain "+strings.Join(ReportDimensions, ", "))
+	}
+	for _, measure := range def.Measures {
+		v.Check(v.Permitted(measure, ReportMeasures...), "measures", "must only contain "+strings.Join(ReportMeasures, ", "))
+	}
+
+	v.Check(def.Limit >= 0, "limit", "must not be negative")
+	v.Check(def.Limit <= MaxCustomReportRows, "limit", "must not be more than 10000")
+
+	v.Check(len(def.Filters.ProductIDs) <= maxReportFilterIDs, "filters.product_ids", "must not contain more than 100 IDs")
+	v.Check(len(def.Filters.UserIDs) <= maxReportFilterIDs, "filters.user_ids", "must not contain more than 100 IDs")
+	v.Check(!slices.ContainsFunc(def.Filters.ProductIDs, func(id int64) bool { return id < 1 }), "filters.product_ids", "must only contain positive IDs")
+	v.Check(!slices.ContainsFunc(def.Filters.UserIDs, func(id int64) bool { return id < 1 }), "filters.user_ids", "must only contain positive IDs")
+
+	if def.Sort != "" && v.IsValid() {
+		v.Check(slices.Contains(def.columnNames(), strings.TrimPrefix(def.Sort, "-")), "sort", "must be one of the report's columns")
+	}
+}
+
+// distinct reports whether no value appears twice.
+func distinct(values []string) bool {
+	sorted := slices.Sorted(slices.Values(values))
+	return len(slices.Compact(sorted)) == len(values)
+}
+
+// ValidateSavedReport checks the name and definition of a saved report.
+func ValidateSavedReport(v *validator.Validator, report *SavedReport) {
+	v.Check(strings.TrimSpace(report.Name) != "", "name", "must be provided")
+	v.Check(len(report.Name) <= 100, "name", "must not be more than 100 bytes long")
+	ValidateReportDefinition(v, &report.Definition)
+}
+
+// columns returns the columns of a validated definition, dimensions first.
+func (def *ReportDefinition) columns() []reportColumn {
+	var columns []reportColumn
+	for _, dimension := range def.Dimensions {
+		columns = append(columns, reportDimensionColumns[dimension]...)
+	}
+	for _, measure := range def.Measures {
+		columns = append(columns, reportMeasureColumns[measure])
+	}
+	return columns
+}
+
+// columnNames returns the names of the columns of a validated definition.
+func (def *ReportDefinition) columnNames() []string {
+	var names []string
+	for _, column := range def.columns() {
+		names = append(names, column.name)
+	}
+	return names
+}
+
+// compile builds the query of a validated definition. Its parameters are the range start and end,
+// the timezone, the product and user filters, the row limit and the currency conversion arrays, in
+// that order. One row more than
+// the limit is fetched to tell whether the report was truncated.
+func (def *ReportDefinition) compile() string {
+	columns := def.columns()
+
+	selects := make([]string, len(columns))
+	for i, column := range columns {
+		selects[i] = fmt.Sprintf("%s AS %q", column.expr, column.name)
+	}
+
+	var groups, orders []string
+	for i := range len(columns) - len(def.Measures) {
+		groups = append(groups, fmt.Sprint(i+1))
+		orders = append(orders, fmt.Sprintf("%q ASC", columns[i].name))
+	}
+	if def.Sort != "" {
+		direction := "ASC"
+		if strings.HasPrefix(def.Sort, "-") {
+			direction = "DESC"
+		}
+		orders = append([]string{fmt.Sprintf("%q %s", strings.TrimPrefix(def.Sort, "-"), direction)}, orders...)
+	}
+
+	query := "SELECT " + strings.Join(selects, ", ") + `
+		FROM sales s
+		JOIN revenue_lines i ON i.sale_id = s.id
+		JOIN products p ON p.id = i.product_id
+		JOIN users u ON u.id = s.user_id
+		LEFT JOIN unnest($7::text[], $8::float8[]) AS fx (currency, factor) ON fx.currency = i.currency
+		WHERE s.archived_at IS NULL
+		  AND i.occurred_at >= $1 AND i.occurred_at < $2
+		  AND $3::text IS NOT NULL -- keeps the timezone typed when no day is selected
+		  AND (cardinality($4::bigint[]) = 0 OR i.product_id = ANY($4))
+		  AND (cardinality($5::bigint[]) = 0 OR s.user_id = ANY($5))`
+	if len(groups) > 0 {
+		query += "\n\t\tGROUP BY " + strings.Join(groups, ", ")
+	}
+	if len(orders) > 0 {
+		query += "\n\t\tORDER BY " + strings.Join(orders, ", ")
+	}
+	return query + "\n\t\tLIMIT $6"
+}
+
+// rowLimit returns the number of rows the definition asks for.
+func (def *ReportDefinition) rowLimit() int {
+	if def.Limit == 0 {
+		return DefaultCustomReportRows
+	}
+	return def.Limit
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// CustomReport runs a validated definition over the sales of a range, with revenue converted by fx.
+// Archived sales are left out.
+func (m *ReportModel) CustomReport(def *ReportDefinition, r ReportRange, fx *Conversion) (*CustomReport, error) {
+	ctx, cancel := m.Timeouts.queryContext(ReportQuery)
+	defer cancel()
+
+	start, end := r.bounds()
+	limit := def.rowLimit()
+	productIDs, userIDs := def.Filters.ProductIDs, def.Filters.UserIDs
+	if productIDs == nil {
+		productIDs = []int64{}
+	}
+	if userIDs == nil {
+		userIDs = []int64{}
+	}
+
+	currencies, factors := fx.sqlArgs()
+	rows, err := m.DB.QueryContext(ctx, def.compile(), start, end, r.Location.String(), pq.Array(productIDs), pq.Array(userIDs), limit+1, currencies, factors)
+	if err != nil {
+		return nil, wrapError("report", "CustomReport", err)
+	}
+	defer rows.Close()
+
+	columns := def.columns()
+	report := &CustomReport{
+		From:     r.From.Format(time.DateOnly),
+		To:       r.To.Format(time.DateOnly),
+		Timezone: r.Location.String(),
+		Currency: fx.Currency,
+		Columns:  def.columnNames(),
+		Rows:     [][]any{},
+	}
+	for rows.Next() {
+		if len(report.Rows) == limit {
+			report.Truncated = true
+			break
+		}
+
+		dest := make([]any, len(columns))
+		for i, column := range columns {
+			dest[i] = column.scan()
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, wrapError("report", "CustomReport", err)
+		}
+
+		row := make([]any, len(dest))
+		for i, value := range dest {
+			switch value := value.(type) {
+			case *int64:
+				row[i] = *value
+			case *float64:
+				row[i] = *value
+			case *string:
+				row[i] = *value
+			}
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("report", "CustomReport", err)
+	}
+
+	return report, nil
+}
+
+// Insert saves a report definition for its creator. Names are unique per user.
+func (m *SavedReportModel) Insert(report *SavedReport) error {
+	query := `
+		INSERT INTO saved_reports (name, definition, created_by)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at
+	`
+
+	definition, err := json.Marshal(report.Definition)
+	if err != nil {
+		return wrapError("saved_report", "Insert", err)
+	}
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	if err := m.DB.QueryRowContext(ctx, query, report.Name, definition, report.CreatedBy).Scan(&report.ID, &report.CreatedAt, &report.UpdatedAt); err != nil {
+		if pqError, ok := err.(*pq.Error); ok && pqError.Code == "23505" { // unique_violation
+			return ErrDuplicateReportName
+		}
+		return wrapError("saved_report", "Insert", err)
+	}
+	return nil
+}
+
+// Get returns a saved report of a user. Reports of other users are reported as not found.
+func (m *SavedReportModel) Get(id, userID int64) (*SavedReport, error) {
+	query := `
+		SELECT id, name, definition, created_by, created_at, updated_at
+		FROM saved_reports
+		WHERE id = $1 AND created_by = $2
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	report := &SavedReport{}
+	var definition []byte
+	if err := m.DB.QueryRowContext(ctx, query, id, userID).Scan(&report.ID, &report.Name, &definition, &report.CreatedBy, &report.CreatedAt, &report.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, wrapError("saved_report", "Get", err)
+	}
+	if err := json.Unmarshal(definition, &report.Definition); err != nil {
+		return nil, wrapError("saved_report", "Get", err)
+	}
+	return report, nil
+}
+
+// GetAllForUser returns a page of a user's saved reports, by name.
+func (m *SavedReportModel) GetAllForUser(userID int64, filter Filter) ([]*SavedReport, MetaData, error) {
+	query := `
+		SELECT COUNT(*) OVER(), id, name, definition, created_by, created_at, updated_at
+		FROM saved_reports
+		WHERE created_by = $1
+		ORDER BY name ASC, id ASC
+		LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filter.Limit(), filter.Offset())
+	if err != nil {
+		return nil, MetaData{}, wrapError("saved_report", "GetAllForUser", err)
+	}
+	defer rows.Close()
+
+	reports := []*SavedReport{}
+	totalRecords := int64(0)
+
+	for rows.Next() {
+		report := &SavedReport{}
+		var definition []byte
+		if err := rows.Scan(&totalRecords, &report.ID, &report.Name, &definition, &report.CreatedBy, &report.CreatedAt, &report.UpdatedAt); err != nil {
+			return nil, MetaData{}, wrapError("saved_report", "GetAllForUser", err)
+		}
+		if err := json.Unmarshal(definition, &report.Definition); err != nil {
+			return nil, MetaData{}, wrapError("saved_report", "GetAllForUser", err)
+		}
+		reports = append(reports, report)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, MetaData{}, wrapError("saved_report", "GetAllForUser", err)
+	}
+
+	return reports, CalculateMetaData(totalRecords, filter), nil
+}
+
+// Update replaces the name and definition of a saved report.
+func (m *SavedReportModel) Update(report *SavedReport) error {
+	query := `
+		UPDATE saved_reports
+		SET name = $1, definition = $2, updated_at = NOW()
+		WHERE id = $3 AND created_by = $4
+		RETURNING updated_at
+	`
+
+	definition, err := json.Marshal(report.Definition)
+	if err != nil {
+		return wrapError("saved_report", "Update", err)
+	}
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	if err := m.DB.QueryRowContext(ctx, query, report.Name, definition, report.ID, report.CreatedBy).Scan(&report.UpdatedAt); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			if pqError, ok := err.(*pq.Error); ok && pqError.Code == "23505" { // unique_violation
+				return ErrDuplicateReportName
+			}
+			return wrapError("saved_report", "Update", err)
+		}
+	}
+	return nil
+}
+
+// Delete removes a saved report of a user.
+func (m *SavedReportModel) Delete(id, userID int64) error {
+	query := `
+		DELETE FROM saved_reports
+		WHERE id = $1 AND created_by = $2
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return wrapError("saved_report", "Delete", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return wrapError("saved_report", "Delete", err)
+	} else if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
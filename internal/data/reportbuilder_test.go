@@ -0,0 +1,77 @@
+// File: internal/data/reportbuilder_test.go
+// Description: test suite for custom report definitions and the SQL they compile to
+
+package data
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// TestValidateReportDefinition tests that only known columns, sorts and limits are accepted
+func TestValidateReportDefinition(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition ReportDefinition
+		errorField string
+	}{
+		{name: "Revenue By Product", definition: ReportDefinition{Dimensions: []string{"product"}, Measures: []string{"revenue"}}},
+		{name: "Totals Only", definition: ReportDefinition{Measures: []string{"count", "quantity"}}},
+		{name: "Sort By Measure", definition: ReportDefinition{Dimensions: []string{"day"}, Measures: []string{"revenue"}, Sort: "-revenue"}},
+		{name: "Sort By Dimension Column", definition: ReportDefinition{Dimensions: []string{"user"}, Measures: []string{"count"}, Sort: "user_name"}},
+		{name: "No Measures", definition: ReportDefinition{Dimensions: []string{"product"}}, errorField: "measures"},
+		{name: "Store Dimension", definition: ReportDefinition{Dimensions: []string{"store"}, Measures: []string{"revenue"}}, errorField: "dimensions"},
+		{name: "Duplicate Dimension", definition: ReportDefinition{Dimensions: []string{"day", "day"}, Measures: []string{"revenue"}}, errorField: "dimensions"},
+		{name: "Unknown Measure", definition: ReportDefinition{Measures: []string{"margin"}}, errorField: "measures"},
+		{name: "Sort Not Selected", definition: ReportDefinition{Measures: []string{"count"}, Sort: "revenue"}, errorField: "sort"},
+		{name: "Sort Injection", definition: ReportDefinition{Measures: []string{"count"}, Sort: "count; DROP TABLE sales"}, errorField: "sort"},
+		{name: "Limit Too Large", definition: ReportDefinition{Measures: []string{"count"}, Limit: 10001}, errorField: "limit"},
+		{name: "Negative Filter ID", definition: ReportDefinition{Measures: []string{"count"}, Filters: ReportFilters{UserIDs: []int64{3, -1}}}, errorField: "filters.user_ids"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateReportDefinition(v, &tt.definition)
+
+			if tt.errorField == "" && !v.IsValid() {
+				t.Errorf("expected a valid definition, got %v", v.Errors)
+			}
+			if _, ok := v.Errors[tt.errorField]; tt.errorField != "" && !ok {
+				t.Errorf("expected an error on %s, got %v", tt.errorField, v.Errors)
+			}
+		})
+	}
+}
+
+// TestCompileReportDefinition tests the columns, grouping and ordering of compiled queries
+func TestCompileReportDefinition(t *testing.T) {
+	definition := &ReportDefinition{Dimensions: []string{"product", "day"}, Measures: []string{"revenue", "count"}, Sort: "-revenue"}
+
+	expectedColumns := []string{"product_id", "product_name", "day", "revenue", "count"}
+	if columns := definition.columnNames(); !slices.Equal(columns, expectedColumns) {
+		t.Errorf("expected columns %v, got %v", expectedColumns, columns)
+	}
+
+	query := definition.compile()
+	for _, fragment := range []string{
+		`p.id AS "product_id"`,
+		`COUNT(DISTINCT s.id) FILTER (WHERE i.refund_id IS NULL) AS "count"`,
+		"JOIN revenue_lines i",
+		"GROUP BY 1, 2, 3",
+		`ORDER BY "revenue" DESC, "product_id" ASC, "product_name" ASC, "day" ASC`,
+		"LIMIT $6",
+	} {
+		if !strings.Contains(query, fragment) {
+			t.Errorf("expected query to contain %q, got:\n%s", fragment, query)
+		}
+	}
+
+	totals := (&ReportDefinition{Measures: []string{"quantity"}}).compile()
+	if strings.Contains(totals, "GROUP BY") || strings.Contains(totals, "ORDER BY") {
+		t.Errorf("expected a single totals row without grouping, got:\n%s", totals)
+	}
+}
@@ -0,0 +1,418 @@
+// File: internal/data/reports.go
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// MaxReportDays is the longest date range a report may cover.
+const MaxReportDays = 366
+
+// ReportRange is an inclusive range of calendar days in a timezone. Sales are bucketed by the
+// local time they happened at, so a store in Belize sees its own opening hours.
+type ReportRange struct {
+	From     time.Time // first day, at midnight in Location
+	To       time.Time // last day, at midnight in Location
+	Location *time.Location
+}
+
+// Weekdays labels the rows of a SalesHeatmap, ISO order with Monday first.
+var Weekdays = []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+
+// SalesHeatmap counts sales and sums their revenue by weekday and hour of day. Counts[d][h] is
+// the number of sales on weekday d (0 is Monday) between hour h and h+1, Revenue[d][h] their
+// value at the prices the items were sold at, converted into Currency. Archived sales are left out.
+type SalesHeatmap struct {
+	From         string         `json:"from"`
+	To           string         `json:"to"`
+	Timezone     string         `json:"timezone"`
+	Currency     string         `json:"currency"`
+	Weekdays     []string       `json:"weekdays"`
+	Counts       [7][24]int64   `json:"counts"`
+	Revenue      [7][24]float64 `json:"revenue"`
+	TotalCount   int64          `json:"total_count"`
+	TotalRevenue float64        `json:"total_revenue"`
+	TotalTax     float64        `json:"total_tax"` // charged on top of the revenue, net of refunds
+}
+
+// SalesSummaryGroups lists what a sales summary can be grouped by, in the order they are offered.
+var SalesSummaryGroups = []string{"day", "week", "month", "product", "cashier"}
+
+// TopProductsOrders lists what the top products report can rank products by.
+var TopProductsOrders = []string{"revenue", "quantity"}
+
+// MaxTopProducts is the most products the top products report returns.
+const MaxTopProducts = 100
+
+// SalesSummary totals the sales of a range for each group, and over the whole range. Revenue and
+// tax are at the prices the items were sold at, converted into Currency, and like quantity are net
+// of refunds; sales only counts sales. Archived sales are left out.
+type SalesSummary struct {
+	From     string               `json:"from"`
+	To       string               `json:"to"`
+	Timezone string               `json:"timezone"`
+	Currency string               `json:"currency"`
+	GroupBy  string               `json:"group_by"`
+	Groups   []*SalesSummaryGroup `json:"groups"`
+	Totals   SalesSummaryGroup    `json:"totals"`
+}
+
+// SalesSummaryGroup holds the totals of one group of a SalesSummary. Period is set for the day,
+// week and month groupings: the date, the Monday starting the week, or the month as YYYY-MM. ID
+// and Name are set for the product and cashier groupings.
+type SalesSummaryGroup struct {
+	Period          string  `json:"period,omitempty"`
+	ID              int64   `json:"id,omitempty"`
+	Name            string  `json:"name,omitempty"`
+	Sales           int64   `json:"sales"`
+	Quantity        int64   `json:"quantity"`
+	Revenue         float64 `json:"revenue"`
+	Tax             float64 `json:"tax"`
+	AverageSale     float64 `json:"average_sale"`     // revenue per sale
+	AverageQuantity float64 `json:"average_quantity"` // units per sale
+}
+
+// TopProduct is one product of the top products report.
+type TopProduct struct {
+	ProductID int64   `json:"product_id"`
+	Name      string  `json:"name"`
+	Sales     int64   `json:"sales"`    // sales the product was on
+	Quantity  int64   `json:"quantity"` // units sold, net of refunds
+	Revenue   float64 `json:"revenue"`  // net of refunds, converted into the report's currency
+}
+
+// RevenueGranularities lists the bucket sizes of a revenue series, in the order they are offered.
+var RevenueGranularities = []string{"hour", "day", "month"}
+
+// MaxHourlyReportDays is the longest range a revenue series can cover in hourly buckets.
+const MaxHourlyReportDays = 31
+
+// RevenueSeries is the revenue of a range in consecutive buckets of one granularity, oldest first,
+// with a zero bucket for every period without sales so charts need no gap filling. Revenue is at
+// the prices the items were sold at, converted into Currency, and like items sold is net of
+// refunds; transactions only counts sales. Archived sales are left out.
+type RevenueSeries struct {
+	From              string           `json:"from"`
+	To                string           `json:"to"`
+	Timezone          string           `json:"timezone"`
+	Currency          string           `json:"currency"`
+	Granularity       string           `json:"granularity"`
+	Buckets           []*RevenueBucket `json:"buckets"`
+	TotalRevenue      float64          `json:"total_revenue"`
+	TotalTransactions int64            `json:"total_transactions"`
+	TotalItemsSold    int64            `json:"total_items_sold"`
+}
+
+// RevenueBucket is one period of a RevenueSeries, starting at Start in the series' timezone.
+type RevenueBucket struct {
+	Start        string  `json:"start"` // RFC 3339 with the timezone's offset
+	Revenue      float64 `json:"revenue"`
+	Transactions int64   `json:"transactions"`
+	ItemsSold    int64   `json:"items_sold"`
+}
+
+// ReportModel runs the aggregate queries behind the report endpoints.
+type ReportModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// ValidateReportRange checks that the range is in order and not longer than MaxReportDays.
+func ValidateReportRange(v *validator.Validator, r ReportRange) {
+	ValidateDateRange(v, r, "from", "to")
+}
+
+// ValidateDateRange checks a report range read from the fromKey and toKey parameters, reporting
+// errors against toKey.
+func ValidateDateRange(v *validator.Validator, r ReportRange, fromKey, toKey string) {
+	v.Check(!r.To.Before(r.From), toKey, "must not be before "+fromKey)
+	v.Check(r.To.Sub(r.From) < MaxReportDays*24*time.Hour, toKey, fmt.Sprintf("must be less than %d days after %s", MaxReportDays, fromKey))
+}
+
+// ValidateRevenueSeries checks the granularity of a revenue series and that hourly series are not
+// longer than MaxHourlyReportDays.
+func ValidateRevenueSeries(v *validator.Validator, r ReportRange, granularity string) {
+	v.Check(v.Permitted(granularity, RevenueGranularities...), "granularity", "must be one of "+strings.Join(RevenueGranularities, ", "))
+	if granularity == "hour" {
+		v.Check(r.To.Sub(r.From) < MaxHourlyReportDays*24*time.Hour, "to", fmt.Sprintf("must be less than %d days after from for hourly buckets", MaxHourlyReportDays))
+	}
+}
+
+// bounds returns the start of the first day and the start of the day after the last one.
+func (r ReportRange) bounds() (time.Time, time.Time) {
+	return r.From, r.To.AddDate(0, 0, 1)
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// SalesHeatmap aggregates the sales of the range into weekday and hour buckets of its timezone,
+// with revenue converted by fx. Refunds take their revenue off the bucket they were made in.
+func (m *ReportModel) SalesHeatmap(r ReportRange, fx *Conversion) (*SalesHeatmap, error) {
+	query := `
+		SELECT EXTRACT(ISODOW FROM i.occurred_at AT TIME ZONE $3)::int - 1 AS weekday,
+		       EXTRACT(HOUR FROM i.occurred_at AT TIME ZONE $3)::int AS hour,
+		       COUNT(DISTINCT s.id) FILTER (WHERE i.refund_id IS NULL),
+		       COALESCE(ROUND(SUM(i.line_total * fx.factor)::numeric, 2), 0)::float8,
+		       COALESCE(ROUND(SUM(i.tax_amount * fx.factor)::numeric, 2), 0)::float8
+		FROM sales s
+		JOIN revenue_lines i ON i.sale_id = s.id
+		LEFT JOIN unnest($4::text[], $5::float8[]) AS fx (currency, factor) ON fx.currency = i.currency
+		WHERE s.archived_at IS NULL
+		  AND i.occurred_at >= $1 AND i.occurred_at < $2
+		GROUP BY weekday, hour
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReportQuery)
+	defer cancel()
+
+	start, end := r.bounds()
+	currencies, factors := fx.sqlArgs()
+	rows, err := m.DB.QueryContext(ctx, query, start, end, r.Location.String(), currencies, factors)
+	if err != nil {
+		return nil, wrapError("report", "SalesHeatmap", err)
+	}
+	defer rows.Close()
+
+	heatmap := &SalesHeatmap{
+		From:     r.From.Format(time.DateOnly),
+		To:       r.To.Format(time.DateOnly),
+		Timezone: r.Location.String(),
+		Currency: fx.Currency,
+		Weekdays: Weekdays,
+	}
+	var cents, taxCents int64 // revenue is totalled in cents so the sum matches the cells
+	for rows.Next() {
+		var weekday, hour int
+		var count int64
+		var revenue, tax float64
+		if err := rows.Scan(&weekday, &hour, &count, &revenue, &tax); err != nil {
+			return nil, wrapError("report", "SalesHeatmap", err)
+		}
+		heatmap.Counts[weekday][hour] = count
+		heatmap.Revenue[weekday][hour] = revenue
+		heatmap.TotalCount += count
+		cents += toCents(revenue) // cells go negative where refunds outweigh sales
+		taxCents += toCents(tax)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("report", "SalesHeatmap", err)
+	}
+	heatmap.TotalRevenue = float64(cents) / 100
+	heatmap.TotalTax = float64(taxCents) / 100
+
+	return heatmap, nil
+}
+
+// SalesSummary totals the sales of the range by groupBy, one of SalesSummaryGroups, with revenue
+// converted by fx. Days, weeks and months are taken in the range's timezone and come in date
+// order; products and cashiers come with the highest revenue first. Refunds count in the group
+// they were made in.
+func (m *ReportModel) SalesSummary(r ReportRange, groupBy string, fx *Conversion) (*SalesSummary, error) {
+	query := `
+		WITH lines AS (
+			SELECT CASE $4::text
+			           WHEN 'day' THEN to_char(i.occurred_at AT TIME ZONE $3, 'YYYY-MM-DD')
+			           WHEN 'week' THEN to_char(date_trunc('week', i.occurred_at AT TIME ZONE $3), 'YYYY-MM-DD')
+			           WHEN 'month' THEN to_char(i.occurred_at AT TIME ZONE $3, 'YYYY-MM')
+			       END AS period,
+			       CASE $4::text WHEN 'product' THEN p.id WHEN 'cashier' THEN u.id END AS id,
+			       CASE $4::text WHEN 'product' THEN p.name WHEN 'cashier' THEN u.first_name || ' ' || u.last_name END AS name,
+			       i.sale_id, i.refund_id, i.quantity, i.line_total * fx.factor AS revenue, i.tax_amount * fx.factor AS tax
+			FROM sales s
+			JOIN revenue_lines i ON i.sale_id = s.id
+			JOIN products p ON p.id = i.product_id
+			JOIN users u ON u.id = s.user_id
+			LEFT JOIN unnest($5::text[], $6::float8[]) AS fx (currency, factor) ON fx.currency = i.currency
+			WHERE s.archived_at IS NULL
+			  AND i.occurred_at >= $1 AND i.occurred_at < $2
+		)
+		SELECT GROUPING(period, id, name) = 1 AS total,
+		       COALESCE(period, ''), COALESCE(id, 0), COALESCE(name, ''),
+		       COUNT(DISTINCT sale_id) FILTER (WHERE refund_id IS NULL),
+		       COALESCE(SUM(quantity), 0)::bigint,
+		       COALESCE(ROUND(SUM(revenue)::numeric, 2), 0)::float8,
+		       COALESCE(ROUND(SUM(tax)::numeric, 2), 0)::float8,
+		       COALESCE(ROUND(SUM(revenue)::numeric / NULLIF(COUNT(DISTINCT sale_id) FILTER (WHERE refund_id IS NULL), 0), 2), 0)::float8,
+		       COALESCE(ROUND(SUM(quantity)::numeric / NULLIF(COUNT(DISTINCT sale_id) FILTER (WHERE refund_id IS NULL), 0), 2), 0)::float8
+		FROM lines
+		GROUP BY GROUPING SETS ((period, id, name), ())
+		ORDER BY GROUPING(period, id, name), period, SUM(revenue) DESC NULLS LAST, id
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReportQuery)
+	defer cancel()
+
+	start, end := r.bounds()
+	currencies, factors := fx.sqlArgs()
+	rows, err := m.DB.QueryContext(ctx, query, start, end, r.Location.String(), groupBy, currencies, factors)
+	if err != nil {
+		return nil, wrapError("report", "SalesSummary", err)
+	}
+	defer rows.Close()
+
+	summary := &SalesSummary{
+		From:     r.From.Format(time.DateOnly),
+		To:       r.To.Format(time.DateOnly),
+		Timezone: r.Location.String(),
+		Currency: fx.Currency,
+		GroupBy:  groupBy,
+		Groups:   []*SalesSummaryGroup{},
+	}
+	for rows.Next() {
+		var total bool
+		var group SalesSummaryGroup
+		if err := rows.Scan(&total, &group.Period, &group.ID, &group.Name, &group.Sales, &group.Quantity, &group.Revenue, &group.Tax, &group.AverageSale, &group.AverageQuantity); err != nil {
+			return nil, wrapError("report", "SalesSummary", err)
+		}
+		if total {
+			summary.Totals = group
+			continue
+		}
+		summary.Groups = append(summary.Groups, &group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("report", "SalesSummary", err)
+	}
+
+	return summary, nil
+}
+
+// TopProducts returns up to limit products with the most revenue, or the most units sold when
+// orderBy is "quantity", over the range, with revenue converted by fx. Refunds are taken off the
+// product's totals and archived sales are left out.
+func (m *ReportModel) TopProducts(r ReportRange, orderBy string, limit int, fx *Conversion) ([]*TopProduct, error) {
+	query := `
+		SELECT p.id, p.name,
+		       COUNT(DISTINCT s.id) FILTER (WHERE i.refund_id IS NULL),
+		       COALESCE(SUM(i.quantity), 0)::bigint,
+		       COALESCE(ROUND(SUM(i.line_total * fx.factor)::numeric, 2), 0)::float8
+		FROM sales s
+		JOIN revenue_lines i ON i.sale_id = s.id
+		JOIN products p ON p.id = i.product_id
+		LEFT JOIN unnest($5::text[], $6::float8[]) AS fx (currency, factor) ON fx.currency = i.currency
+		WHERE s.archived_at IS NULL
+		  AND i.occurred_at >= $1 AND i.occurred_at < $2
+		GROUP BY p.id, p.name
+		ORDER BY CASE WHEN $3::text = 'quantity' THEN SUM(i.quantity) END DESC NULLS LAST,
+		         SUM(i.line_total * fx.factor) DESC NULLS LAST, p.id
+		LIMIT $4
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReportQuery)
+	defer cancel()
+
+	start, end := r.bounds()
+	currencies, factors := fx.sqlArgs()
+	rows, err := m.DB.QueryContext(ctx, query, start, end, orderBy, limit, currencies, factors)
+	if err != nil {
+		return nil, wrapError("report", "TopProducts", err)
+	}
+	defer rows.Close()
+
+	products := []*TopProduct{}
+	for rows.Next() {
+		var product TopProduct
+		if err := rows.Scan(&product.ProductID, &product.Name, &product.Sales, &product.Quantity, &product.Revenue); err != nil {
+			return nil, wrapError("report", "TopProducts", err)
+		}
+		products = append(products, &product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("report", "TopProducts", err)
+	}
+
+	return products, nil
+}
+
+// RevenueSeries totals the revenue, sales and items sold of the range in buckets of granularity,
+// one of RevenueGranularities, with revenue converted by fx. Buckets follow the wall clock of the
+// range's timezone, and refunds count in the bucket they were made in.
+func (m *ReportModel) RevenueSeries(r ReportRange, granularity string, fx *Conversion) (*RevenueSeries, error) {
+	query := `
+		WITH buckets AS (
+			SELECT generate_series(
+			           date_trunc($4::text, $1::timestamptz AT TIME ZONE $3),
+			           ($2::timestamptz AT TIME ZONE $3) - interval '1 microsecond',
+			           ('1 ' || $4::text)::interval
+			       ) AS bucket
+		), totals AS (
+			SELECT date_trunc($4::text, i.occurred_at AT TIME ZONE $3) AS bucket,
+			       COUNT(DISTINCT s.id) FILTER (WHERE i.refund_id IS NULL) AS transactions,
+			       SUM(i.quantity) AS items_sold,
+			       SUM(i.line_total * fx.factor) AS revenue
+			FROM sales s
+			JOIN revenue_lines i ON i.sale_id = s.id
+			LEFT JOIN unnest($5::text[], $6::float8[]) AS fx (currency, factor) ON fx.currency = i.currency
+			WHERE s.archived_at IS NULL
+			  AND i.occurred_at >= $1 AND i.occurred_at < $2
+			GROUP BY 1
+		)
+		SELECT b.bucket AT TIME ZONE $3,
+		       COALESCE(ROUND(t.revenue::numeric, 2), 0)::float8,
+		       COALESCE(t.transactions, 0),
+		       COALESCE(t.items_sold, 0)::bigint
+		FROM buckets b
+		LEFT JOIN totals t ON t.bucket = b.bucket
+		ORDER BY b.bucket
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReportQuery)
+	defer cancel()
+
+	start, end := r.bounds()
+	currencies, factors := fx.sqlArgs()
+	rows, err := m.DB.QueryContext(ctx, query, start, end, r.Location.String(), granularity, currencies, factors)
+	if err != nil {
+		return nil, wrapError("report", "RevenueSeries", err)
+	}
+	defer rows.Close()
+
+	series := &RevenueSeries{
+		From:        r.From.Format(time.DateOnly),
+		To:          r.To.Format(time.DateOnly),
+		Timezone:    r.Location.String(),
+		Currency:    fx.Currency,
+		Granularity: granularity,
+		Buckets:     []*RevenueBucket{},
+	}
+	var cents int64 // revenue is totalled in cents so the sum matches the buckets
+	for rows.Next() {
+		var bucketStart time.Time
+		var bucket RevenueBucket
+		if err := rows.Scan(&bucketStart, &bucket.Revenue, &bucket.Transactions, &bucket.ItemsSold); err != nil {
+			return nil, wrapError("report", "RevenueSeries", err)
+		}
+		bucket.Start = bucketStart.In(r.Location).Format(time.RFC3339)
+		series.Buckets = append(series.Buckets, &bucket)
+		cents += toCents(bucket.Revenue)
+		series.TotalTransactions += bucket.Transactions
+		series.TotalItemsSold += bucket.ItemsSold
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("report", "RevenueSeries", err)
+	}
+	series.TotalRevenue = float64(cents) / 100
+
+	return series, nil
+}
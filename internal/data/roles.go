@@ -0,0 +1,324 @@
+// File: internal/data/roles.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// DefaultRole is the role given to users who register without asking for a known role.
+const DefaultRole = "guest"
+
+// AdminRole is the built in role with full access. At least one active user must always hold it.
+const AdminRole = "admin"
+
+// RoleNameRX restricts role names to lowercase slugs such as "shift-manager".
+var RoleNameRX = regexp.MustCompile("^[a-z][a-z0-9_-]*$")
+
+// Role represents a named bundle of permissions that users are assigned.
+type Role struct {
+	ID          int64       `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	IsSystem    bool        `json:"is_system"` // built in roles cannot be renamed or deleted
+	Permissions Permissions `json:"permissions"`
+	CreatedAt   Timestamp   `json:"created_at"`
+	UpdatedAt   Timestamp   `json:"updated_at"`
+	Version     int         `json:"version"`
+}
+
+// RoleModel wraps a sql.DB connection pool.
+type RoleModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// ValidateRole checks the fields of a Role struct to ensure they meet the required criteria.
+func ValidateRole(v *validator.Validator, role *Role) {
+	v.Check(role.Name != "", "name", "must be provided")
+	v.Check(len(role.Name) <= 50, "name", "must not be more than 50 characters long")
+	v.Check(v.Matches(role.Name, RoleNameRX), "name", "must be lowercase letters, numbers, dashes or underscores")
+
+	v.Check(len(role.Description) <= 255, "description", "must not be more than 255 characters long")
+
+	v.Check(role.Permissions != nil, "permissions", "must be provided")
+	v.Check(len(role.Permissions) == len(slices.Compact(slices.Sorted(slices.Values(role.Permissions)))), "permissions", "must not contain duplicate values")
+	for _, code := range role.Permissions {
+		if !PermissionCatalog.Includes(code) {
+			v.AddError("permissions", "must only contain known permission codes")
+			break
+		}
+	}
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// Insert adds a new role and its permissions to the database.
+func (m *RoleModel) Insert(role *Role) error {
+	query := `
+		INSERT INTO roles (name, description, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		RETURNING id, is_system, created_at, updated_at, version
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapError("role", "Insert", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, role.Name, role.Description).Scan(&role.ID, &role.IsSystem, &role.CreatedAt, &role.UpdatedAt, &role.Version)
+	if err != nil {
+		if pqError, ok := err.(*pq.Error); ok && pqError.Code == "23505" { // unique_violation
+			return ErrDuplicateRole
+		}
+		return wrapError("role", "Insert", err)
+	}
+
+	if err := setRolePermissions(ctx, tx, role); err != nil {
+		return wrapError("role", "Insert", err)
+	}
+
+	return wrapError("role", "Insert", tx.Commit())
+}
+
+// Get retrieves a role and its permissions by ID.
+func (m *RoleModel) Get(id int64) (*Role, error) {
+	return m.getBy("id", id)
+}
+
+// GetByName retrieves a role and its permissions by name.
+func (m *RoleModel) GetByName(name string) (*Role, error) {
+	return m.getBy("name", name)
+}
+
+// getBy looks up a single role on either its id or name column.
+func (m *RoleModel) getBy(column string, value any) (*Role, error) {
+	query := fmt.Sprintf(`
+		SELECT r.id, r.name, r.description, r.is_system, r.created_at, r.updated_at, r.version,
+		       COALESCE(ARRAY_AGG(p.code ORDER BY p.code) FILTER (WHERE p.code IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		WHERE r.%s = $1
+		GROUP BY r.id
+	`, column)
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	role := &Role{}
+
+	err := m.DB.QueryRowContext(ctx, query, value).Scan(
+		&role.ID,
+		&role.Name,
+		&role.Description,
+		&role.IsSystem,
+		&role.CreatedAt,
+		&role.UpdatedAt,
+		&role.Version,
+		pq.Array((*[]string)(&role.Permissions)),
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, wrapError("role", "getBy", err)
+	}
+
+	return role, nil
+}
+
+// GetAll retrieves every role with its permissions, ordered by name.
+func (m *RoleModel) GetAll() ([]*Role, error) {
+	query := `
+		SELECT r.id, r.name, r.description, r.is_system, r.created_at, r.updated_at, r.version,
+		       COALESCE(ARRAY_AGG(p.code ORDER BY p.code) FILTER (WHERE p.code IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		GROUP BY r.id
+		ORDER BY r.name ASC
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, wrapError("role", "GetAll", err)
+	}
+	defer rows.Close()
+
+	roles := []*Role{}
+	for rows.Next() {
+		role := &Role{}
+		err := rows.Scan(
+			&role.ID,
+			&role.Name,
+			&role.Description,
+			&role.IsSystem,
+			&role.CreatedAt,
+			&role.UpdatedAt,
+			&role.Version,
+			pq.Array((*[]string)(&role.Permissions)),
+		)
+		if err != nil {
+			return nil, wrapError("role", "GetAll", err)
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("role", "GetAll", err)
+	}
+
+	return roles, nil
+}
+
+// Update modifies a role, replaces its permission bundle and re-applies the bundle
+// to every user holding the role, all in one transaction. Individual grants are kept.
+func (m *RoleModel) Update(role *Role) error {
+	query := `
+		UPDATE roles
+		SET name = $1, description = $2, updated_at = NOW(), version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING updated_at, version
+	`
+	resetQuery := `
+		DELETE FROM users_permissions
+		WHERE user_id IN (SELECT id FROM users WHERE role = $1)
+		AND is_grant = FALSE
+	`
+	grantQuery := `
+		INSERT INTO users_permissions (user_id, permission_id)
+		SELECT u.id, rp.permission_id
+		FROM users u
+		INNER JOIN role_permissions rp ON rp.role_id = $1
+		WHERE u.role = $2
+		ON CONFLICT (user_id, permission_id) DO NOTHING
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapError("role", "Update", err)
+	}
+	defer tx.Rollback()
+
+	// Renames cascade to users.role through the foreign key
+	err = tx.QueryRowContext(ctx, query, role.Name, role.Description, role.ID, role.Version).Scan(&role.UpdatedAt, &role.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			if pqError, ok := err.(*pq.Error); ok && pqError.Code == "23505" { // unique_violation
+				return ErrDuplicateRole
+			}
+			return wrapError("role", "Update", err)
+		}
+	}
+
+	if err := setRolePermissions(ctx, tx, role); err != nil {
+		return wrapError("role", "Update", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, resetQuery, role.Name); err != nil {
+		return wrapError("role", "Update", err)
+	}
+	if _, err := tx.ExecContext(ctx, grantQuery, role.ID, role.Name); err != nil {
+		return wrapError("role", "Update", err)
+	}
+
+	return wrapError("role", "Update", tx.Commit())
+}
+
+// Delete removes a role from the database. Roles still assigned to users cannot be deleted.
+func (m *RoleModel) Delete(id int64) error {
+	query := `
+		DELETE FROM roles
+		WHERE id = $1 AND is_system = FALSE
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		if pqError, ok := err.(*pq.Error); ok && pqError.Code == "23503" { // foreign_key_violation
+			return ErrRoleInUse
+		}
+		return wrapError("role", "Delete", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return wrapError("role", "Delete", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// setRolePermissions replaces the permissions attached to a role inside a transaction.
+func setRolePermissions(ctx context.Context, tx *sql.Tx, role *Role) error {
+	deleteQuery := `
+		DELETE FROM role_permissions
+		WHERE role_id = $1
+	`
+	insertQuery := `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT $1, p.id
+		FROM permissions p
+		WHERE p.code = ANY($2)
+	`
+
+	if _, err := tx.ExecContext(ctx, deleteQuery, role.ID); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, insertQuery, role.ID, pq.Array([]string(role.Permissions)))
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected != int64(len(role.Permissions)) {
+		return ErrUnknownPermission
+	}
+
+	return nil
+}
@@ -4,10 +4,17 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/lib/pq"
 )
 
 // ----------------------------------------------------------------------
@@ -16,29 +23,171 @@ import (
 //
 // ----------------------------------------------------------------------
 
-// Sale represents a sales record in the system.
+// Sale represents a sales record in the system: one transaction at the till with one or more items.
 type Sale struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"user_id"`
-	ProductID int64     `json:"product_id"`
-	Quantity  int64     `json:"quantity"`
-	SoldAt    time.Time `json:"sold_at"`
+	ID         int64       `json:"id"`
+	ClientUUID *string     `json:"client_uuid,omitempty"`
+	UserID     int64       `json:"user_id"`
+	Items      []*SaleItem `json:"items"`
+	Quantity   int64       `json:"quantity"`        // units across all items, set when the sale is read or written
+	Subtotal   float64     `json:"subtotal_amount"` // sum of the line totals, computed by the server
+	Discount   float64     `json:"discount_amount"` // taken off the subtotal by the discount code
+	Tax        float64     `json:"tax_amount"`      // added on top of the discounted subtotal
+	Total      float64     `json:"total_amount"`    // subtotal less discount plus tax, what the customer pays
+	Currency   string      `json:"currency"`        // of the total, shared by every item
+	Note       string      `json:"note"`            // free text, such as why goods were returned
+	SoldAt     Timestamp   `json:"sold_at"`
+	UpdatedAt  Timestamp   `json:"updated_at"`
+	CreatedBy  *int64      `json:"created_by"`  // user who recorded the sale, null for legacy rows
+	UpdatedBy  *int64      `json:"updated_by"`  // user who last modified the sale
+	ArchivedAt Timestamp   `json:"archived_at"` // set when the sale was archived instead of deleted
+
+	DiscountCode *string `json:"discount_code"` // kept after the discount itself is deleted
+	DiscountID   *int64  `json:"-"`
+
+	CustomerID    *int64 `json:"customer_id"`    // the customer the sale is attributed to, null for none
+	CustomerEmail string `json:"customer_email"` // where receipts are emailed, empty for none
+
+	Status   string    `json:"status"`    // draft, completed or voided
+	VoidedAt Timestamp `json:"voided_at"` // set when the sale was voided
+	VoidedBy *int64    `json:"voided_by"` // user who voided the sale
 }
 
+// SaleItem is one product of a sale, how many units of it were sold and at what price. The price
+// is the product's price when the item was first sold and is never taken from the client.
+type SaleItem struct {
+	ID        int64   `json:"id"`
+	ProductID int64   `json:"product_id"`
+	Quantity  int64   `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	Currency  string  `json:"currency"`
+	LineTotal float64 `json:"line_total"`      // quantity times unit price
+	Discount  float64 `json:"discount_amount"` // this item's part of the sale's discount
+	Tax       float64 `json:"tax_amount"`      // on the line total less its discount
+}
+
+// Statuses of a sale. Drafts are parked carts that have not taken any stock yet, completed sales
+// count as revenue and voided sales are mistakes kept for the audit trail.
+const (
+	SaleDraft     = "draft"
+	SaleCompleted = "completed"
+	SaleVoided    = "voided"
+)
+
+// ActionVoidSale is the audit log action recorded when a sale is voided.
+const ActionVoidSale = "sales:void"
+
+// MaxVoidReason is the longest reason a void may be given, in bytes.
+const MaxVoidReason = 500
+
+// MaxSaleNote is the longest note a sale may carry, in bytes.
+const MaxSaleNote = 2000
+
+// MaxSaleItems is the most items one sale may hold.
+const MaxSaleItems = 100
+
+// MaxBulkSales is the largest number of sales a single bulk operation may affect.
+const MaxBulkSales = 1000
+
 // SaleModel wraps a sql.DB connection pool.
 type SaleModel struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Timeouts Timeouts
+	Clock    Clock
 }
 
 // SaleFilter represents filtering criteria for querying sales.
 type SaleFilter struct {
 	Filter    Filter `json:"filter"`
 	UserID    int64  `json:"user_id"`
-	ProductID int64  `json:"product_id"`
+	ProductID int64  `json:"product_id"` // sales with an item of this product
 	MinDate   string `json:"min_date"`
 	MaxDate   string `json:"max_date"`
-	MinQty    int64  `json:"min_qty"`
+	MinQty    int64  `json:"min_qty"` // bounds on the units across all items
 	MaxQty    int64  `json:"max_qty"`
+	CreatedBy *int64 `json:"created_by"`
+	UpdatedBy *int64 `json:"updated_by"`
+
+	CustomerID      int64 `json:"customer_id"` // sales attributed to this customer
+	IncludeArchived bool  `json:"include_archived"`
+
+	Status string `json:"status"` // empty for every status
+}
+
+// SaleSelection picks the sales affected by a bulk operation, either by ID or by filter.
+type SaleSelection struct {
+	IDs       []int64 `json:"ids"`
+	UserID    int64   `json:"user_id"`
+	ProductID int64   `json:"product_id"`
+	MinDate   string  `json:"min_date"`
+	MaxDate   string  `json:"max_date"`
+}
+
+// SaleDeletion records a sale removed on the server so that offline terminals can drop their copy.
+type SaleDeletion struct {
+	SaleID     int64     `json:"sale_id"`
+	ClientUUID *string   `json:"client_uuid,omitempty"`
+	DeletedAt  Timestamp `json:"deleted_at"`
+}
+
+// SaleChanges holds the server side changes returned to a syncing terminal.
+type SaleChanges struct {
+	Sales      []*Sale         `json:"sales"`
+	Deleted    []*SaleDeletion `json:"deleted"`
+	NextCursor SyncCursor      `json:"next_cursor"`
+	HasMore    bool            `json:"has_more"`
+}
+
+// SyncCursor marks how far a terminal has pulled each change feed. Both feeds are ordered by time
+// and then ID, so rows stamped with the same time, as bulk updates do, cannot fall between pages.
+type SyncCursor struct {
+	SalesAt   time.Time // updated_at of the last sale pulled
+	SaleID    int64     // id of the last sale pulled
+	DeletedAt time.Time // deleted_at of the last deletion pulled
+	DeletedID int64     // sale_id of the last deletion pulled
+}
+
+// NewSyncCursor starts both feeds after the given time, for a terminal's first pull.
+func NewSyncCursor(since time.Time) SyncCursor {
+	return SyncCursor{SalesAt: since, SaleID: math.MaxInt64, DeletedAt: since, DeletedID: math.MaxInt64}
+}
+
+// String encodes the cursor as the opaque value terminals send back.
+func (c SyncCursor) String() string {
+	raw := fmt.Sprintf("%s,%d,%s,%d", c.SalesAt.UTC().Format(time.RFC3339Nano), c.SaleID, c.DeletedAt.UTC().Format(time.RFC3339Nano), c.DeletedID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// MarshalJSON writes the cursor as its opaque string.
+func (c SyncCursor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+// ParseSyncCursor decodes a cursor returned by an earlier pull.
+func ParseSyncCursor(value string) (SyncCursor, error) {
+	var c SyncCursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), ",")
+	if len(parts) != 4 {
+		return c, ErrInvalidCursor
+	}
+	if c.SalesAt, err = time.Parse(time.RFC3339Nano, parts[0]); err != nil {
+		return c, ErrInvalidCursor
+	}
+	if c.SaleID, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return c, ErrInvalidCursor
+	}
+	if c.DeletedAt, err = time.Parse(time.RFC3339Nano, parts[2]); err != nil {
+		return c, ErrInvalidCursor
+	}
+	if c.DeletedID, err = strconv.ParseInt(parts[3], 10, 64); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
 }
 
 // ----------------------------------------------------------------------
@@ -49,63 +198,206 @@ type SaleFilter struct {
 // ValidateSale checks the fields of a Sale struct to ensure they meet the required criteria.
 func ValidateSale(v *validator.Validator, sale *Sale) {
 	v.Check(sale.UserID > 0, "user_id", "must be a positive integer")
-	v.Check(sale.ProductID > 0, "product_id", "must be a positive integer")
-	v.Check(sale.Quantity > 0, "quantity", "must be a positive integer")
+	v.Check(len(sale.Items) > 0, "items", "must contain at least one item")
+	v.Check(len(sale.Items) <= MaxSaleItems, "items", fmt.Sprintf("must not contain more than %d items", MaxSaleItems))
+	v.Check(len(sale.Note) <= MaxSaleNote, "note", fmt.Sprintf("must not be more than %d bytes long", MaxSaleNote))
+	if sale.CustomerEmail != "" {
+		v.Check(len(sale.CustomerEmail) <= 254, "customer_email", "must not be more than 254 characters long")
+		v.Check(v.Matches(sale.CustomerEmail, validator.EmailRX), "customer_email", "must be a valid email address")
+	}
+
+	seen := make(map[int64]bool, len(sale.Items))
+	for _, item := range sale.Items {
+		if item == nil || item.ProductID < 1 {
+			v.AddError("items", "must only contain positive product ids")
+			break
+		}
+		if item.Quantity < 1 {
+			v.AddError("items", "must only contain positive quantities")
+			break
+		}
+		if seen[item.ProductID] {
+			v.AddError("items", "must not contain a product more than once")
+			break
+		}
+		seen[item.ProductID] = true
+	}
+}
+
+// totalQuantity sets the sale's quantity to the units across its items.
+func (sale *Sale) totalQuantity() {
+	sale.Quantity = 0
+	for _, item := range sale.Items {
+		sale.Quantity += item.Quantity
+	}
+}
+
+// ValidateSaleSelection checks that a bulk operation targets explicit IDs or a non-empty filter.
+func ValidateSaleSelection(v *validator.Validator, sel *SaleSelection) {
+	hasFilter := sel.UserID != 0 || sel.ProductID != 0 || sel.MinDate != "" || sel.MaxDate != ""
+	v.Check(len(sel.IDs) > 0 || hasFilter, "selection", "must provide ids or at least one filter")
+	v.Check(len(sel.IDs) == 0 || !hasFilter, "selection", "must provide either ids or a filter, not both")
+	v.Check(len(sel.IDs) <= MaxBulkSales, "ids", "must not contain more than 1000 ids")
+	for _, id := range sel.IDs {
+		if id < 1 {
+			v.AddError("ids", "must only contain positive integers")
+			break
+		}
+	}
+	if sel.MinDate != "" {
+		_, err := time.Parse(time.DateOnly, sel.MinDate)
+		v.Check(err == nil, "min_date", "must be a valid date in YYYY-MM-DD format")
+	}
+	if sel.MaxDate != "" {
+		_, err := time.Parse(time.DateOnly, sel.MaxDate)
+		v.Check(err == nil, "max_date", "must be a valid date in YYYY-MM-DD format")
+	}
 }
 
-// Insert adds a new sale to the database.
+// ValidateSyncedSale checks a sale submitted by an offline terminal, as of now.
+func ValidateSyncedSale(v *validator.Validator, sale *Sale, now time.Time) {
+	ValidateSale(v, sale)
+	v.Check(sale.ClientUUID != nil && *sale.ClientUUID != "", "client_uuid", "must be provided")
+	if sale.ClientUUID != nil {
+		v.Check(v.Matches(*sale.ClientUUID, validator.UUIDRX), "client_uuid", "must be a valid UUID")
+	}
+	v.Check(sale.SoldAt.IsZero() || sale.SoldAt.Before(now.Add(5*time.Minute)), "sold_at", "must not be in the future")
+}
+
+// Insert adds a new sale and its items, priced at the products' current prices, to the database and
+// takes each item from the product's stock in the same transaction, unless the sale is a draft whose
+// stock is taken when it is completed. Sales without a status are completed. The discount named by
+// the sale's discount code, if any, is taken off the items it covers. It returns ErrInsufficientStock when a
+// tracked product has too little left, ErrMixedCurrencies when the products are priced in different
+// currencies, ErrInvalidDiscount or ErrDiscountNotApplicable when the discount code cannot be
+// used, and ErrUnknownUser, ErrUnknownProduct or ErrUnknownCustomer when the sale names a record
+// that does not exist, and then records nothing.
 func (m *SaleModel) Insert(sale *Sale) error {
 	query := `
-		INSERT INTO sales (user_id, product_id, quantity, sold_at)
-		VALUES ($1, $2, $3, NOW())
-		RETURNING id, sold_at
+		INSERT INTO sales (user_id, note, customer_id, customer_email, created_by, updated_by, status, sold_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5, $6, $7, NOW())
+		RETURNING id, sold_at, updated_at
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	if sale.Status == "" {
+		sale.Status = SaleCompleted
+	}
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
 	defer cancel()
 
-	if err := m.DB.QueryRowContext(ctx, query, sale.UserID, sale.ProductID, sale.Quantity).Scan(&sale.ID, &sale.SoldAt); err != nil {
-		return err
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapError("sale", "Insert", err)
+	}
+	defer tx.Rollback()
+
+	now := clockNow(m.Clock)
+	discount, err := saleDiscount(ctx, tx, sale, now)
+	if err != nil {
+		return wrapError("sale", "Insert", err)
 	}
+	if err := tx.QueryRowContext(ctx, query, sale.UserID, sale.Note, sale.CustomerID, sale.CustomerEmail, sale.CreatedBy, sale.Status, now).Scan(&sale.ID, &sale.SoldAt, &sale.UpdatedAt); err != nil {
+		return wrapError("sale", "Insert", saleReferenceError(err))
+	}
+	if err := insertSaleItems(ctx, tx, sale, nil, discount); err != nil {
+		return wrapError("sale", "Insert", err)
+	}
+	if sale.Status != SaleDraft {
+		if err := takeSaleItemsStock(ctx, tx, sale, true); err != nil {
+			return wrapError("sale", "Insert", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return wrapError("sale", "Insert", err)
+	}
+	sale.UpdatedBy = sale.CreatedBy
 	return nil
 }
 
-// Update modifies an existing sale in the database.
+// Update modifies an existing sale in the database, replacing all of its items. Products that stay
+// on the sale keep the price they were sold at, new ones are priced at their current price. Stock is
+// left alone, as for any edit of a sale; corrections to it are recorded as adjustments. The sale's
+// discount is shared out again over the new items, or the one its discount code names once the
+// discount ID is cleared. It returns ErrRecordNotFound when the sale is gone, ErrSaleVoided once it
+// has been voided, ErrSaleRefunded once part of it has been refunded, ErrMixedCurrencies when the items are priced in different
+// currencies, ErrInvalidDiscount or ErrDiscountNotApplicable when the discount cannot be used, and
+// ErrUnknownUser, ErrUnknownProduct or ErrUnknownCustomer when the sale names a record that does not exist.
 func (m *SaleModel) Update(sale *Sale) error {
 	query := `
 		UPDATE sales
-		SET user_id = $1, product_id = $2, quantity = $3, sold_at = NOW()
-		WHERE id = $4
-		RETURNING sold_at
+		SET user_id = $1, note = $2, customer_id = $3, customer_email = $4, updated_by = $5, sold_at = $7, updated_at = NOW()
+		WHERE id = $6
+		RETURNING sold_at, updated_at, status
+	`
+	refundedQuery := `
+		SELECT EXISTS (SELECT 1 FROM refunds WHERE sale_id = $1)
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
 	defer cancel()
 
-	if err := m.DB.QueryRowContext(ctx, query, sale.UserID, sale.ProductID, sale.Quantity, sale.ID).Scan(&sale.SoldAt); err != nil {
-		return err
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapError("sale", "Update", err)
 	}
-	return nil
+	defer tx.Rollback()
+
+	now := clockNow(m.Clock)
+	if err := tx.QueryRowContext(ctx, query, sale.UserID, sale.Note, sale.CustomerID, sale.CustomerEmail, sale.UpdatedBy, sale.ID, now).Scan(&sale.SoldAt, &sale.UpdatedAt, &sale.Status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return wrapError("sale", "Update", saleReferenceError(err))
+	}
+	if sale.Status == SaleVoided {
+		return ErrSaleVoided
+	}
+	var refunded bool
+	if err := tx.QueryRowContext(ctx, refundedQuery, sale.ID).Scan(&refunded); err != nil {
+		return wrapError("sale", "Update", err)
+	} else if refunded {
+		return ErrSaleRefunded
+	}
+	discount, err := saleDiscount(ctx, tx, sale, now)
+	if err != nil {
+		return wrapError("sale", "Update", err)
+	}
+	kept, err := deleteSaleItems(ctx, tx, sale.ID)
+	if err != nil {
+		return wrapError("sale", "Update", err)
+	}
+	if err := insertSaleItems(ctx, tx, sale, kept, discount); err != nil {
+		return wrapError("sale", "Update", err)
+	}
+
+	return wrapError("sale", "Update", tx.Commit())
 }
 
-// Delete removes a sale from the database.
+// Delete removes a sale from the database and records the deletion for syncing terminals.
 func (m *SaleModel) Delete(id int64) error {
 	query := `
-		DELETE FROM sales
-		WHERE id = $1
+		WITH deleted AS (
+			DELETE FROM sales
+			WHERE id = $1
+			RETURNING id, client_uuid
+		)
+		INSERT INTO sales_deletions (sale_id, client_uuid, deleted_at)
+		SELECT id, client_uuid, NOW() FROM deleted
+		ON CONFLICT (sale_id) DO UPDATE SET deleted_at = EXCLUDED.deleted_at
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
 	defer cancel()
 
 	result, err := m.DB.ExecContext(ctx, query, id)
 	if err != nil {
-		return err
+		return wrapError("sale", "Delete", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return wrapError("sale", "Delete", err)
 	}
 
 	if rowsAffected == 0 {
@@ -115,49 +407,199 @@ func (m *SaleModel) Delete(id int64) error {
 	return nil
 }
 
+// Complete turns a draft into a completed sale sold now, taking its items from stock. The items
+// keep the prices, discount and tax they were given while parked. It returns ErrRecordNotFound
+// when the sale is gone, ErrSaleNotDraft when it is not a draft and ErrInsufficientStock when a
+// tracked product has too little left, and then changes nothing.
+func (m *SaleModel) Complete(id, userID int64) (*Sale, error) {
+	query := `
+		UPDATE sales
+		SET status = 'completed', sold_at = $3, updated_at = NOW(), updated_by = $2
+		WHERE id = $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, wrapError("sale", "Complete", err)
+	}
+	defer tx.Rollback()
+
+	sale, err := lockSale(ctx, tx, id)
+	if err != nil {
+		return nil, wrapError("sale", "Complete", err)
+	}
+	if sale.Status != SaleDraft {
+		return nil, ErrSaleNotDraft
+	}
+	sale.CreatedBy = &userID // the stock movements are recorded as taken by whoever completed it
+	if err := takeSaleItemsStock(ctx, tx, sale, true); err != nil {
+		return nil, wrapError("sale", "Complete", err)
+	}
+	if _, err := tx.ExecContext(ctx, query, id, userID, clockNow(m.Clock)); err != nil {
+		return nil, wrapError("sale", "Complete", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, wrapError("sale", "Complete", err)
+	}
+	return m.Get(id)
+}
+
+// Void marks a sale made by mistake as voided, keeping it and its items for the audit trail while
+// taking it out of revenue. A completed sale's items go back into stock; a draft never took any.
+// It returns ErrRecordNotFound when the sale is gone, ErrSaleVoided when it already was voided, and
+// ErrSalePaid or ErrSaleRefunded once money has changed hands, which refunds are for instead.
+func (m *SaleModel) Void(id, userID int64) (*Sale, error) {
+	query := `
+		UPDATE sales
+		SET status = 'voided', voided_at = NOW(), voided_by = $2, updated_at = NOW(), updated_by = $2
+		WHERE id = $1
+	`
+	moneyQuery := `
+		SELECT EXISTS (SELECT 1 FROM payments WHERE sale_id = $1), EXISTS (SELECT 1 FROM refunds WHERE sale_id = $1)
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, wrapError("sale", "Void", err)
+	}
+	defer tx.Rollback()
+
+	sale, err := lockSale(ctx, tx, id)
+	if err != nil {
+		return nil, wrapError("sale", "Void", err)
+	}
+	if sale.Status == SaleVoided {
+		return nil, ErrSaleVoided
+	}
+	var paid, refunded bool
+	if err := tx.QueryRowContext(ctx, moneyQuery, id).Scan(&paid, &refunded); err != nil {
+		return nil, wrapError("sale", "Void", err)
+	}
+	switch {
+	case refunded:
+		return nil, ErrSaleRefunded
+	case paid:
+		return nil, ErrSalePaid
+	}
+
+	sale.VoidedBy = &userID
+	if sale.Status == SaleCompleted {
+		if err := returnVoidedItemsStock(ctx, tx, sale); err != nil {
+			return nil, wrapError("sale", "Void", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, query, id, userID); err != nil {
+		return nil, wrapError("sale", "Void", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, wrapError("sale", "Void", err)
+	}
+	return m.Get(id)
+}
+
+// lockSale locks a sale inside tx and returns its status and the products and quantities of its
+// items. It returns ErrRecordNotFound when the sale does not exist.
+func lockSale(ctx context.Context, tx *sql.Tx, id int64) (*Sale, error) {
+	query := `
+		SELECT id, status
+		FROM sales
+		WHERE id = $1
+		FOR UPDATE
+	`
+	itemsQuery := `
+		SELECT product_id, quantity
+		FROM sale_items
+		WHERE sale_id = $1
+		ORDER BY id
+	`
+
+	sale := &Sale{Items: []*SaleItem{}}
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&sale.ID, &sale.Status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, itemsQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &SaleItem{}
+		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
+			return nil, err
+		}
+		sale.Items = append(sale.Items, item)
+	}
+	return sale, rows.Err()
+}
+
 // Get retrieves a sale by its ID.
 func (m *SaleModel) Get(id int64) (*Sale, error) {
 	query := `
-		SELECT id, user_id, product_id, quantity, sold_at
+		SELECT id, client_uuid, user_id, subtotal_amount, discount_amount, tax_amount, total_amount, currency, note, customer_id, customer_email, sold_at, updated_at, created_by, updated_by, archived_at, discount_id, discount_code, status, voided_at, voided_by
 		FROM sales
 		WHERE id = $1
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
 	defer cancel()
 
 	sale := &Sale{}
 
-	if err := m.DB.QueryRowContext(ctx, query, id).Scan(&sale.ID, &sale.UserID, &sale.ProductID, &sale.Quantity, &sale.SoldAt); err != nil {
+	if err := m.DB.QueryRowContext(ctx, query, id).Scan(&sale.ID, &sale.ClientUUID, &sale.UserID, &sale.Subtotal, &sale.Discount, &sale.Tax, &sale.Total, &sale.Currency, &sale.Note, &sale.CustomerID, &sale.CustomerEmail, &sale.SoldAt, &sale.UpdatedAt, &sale.CreatedBy, &sale.UpdatedBy, &sale.ArchivedAt, &sale.DiscountID, &sale.DiscountCode, &sale.Status, &sale.VoidedAt, &sale.VoidedBy); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrRecordNotFound
 		}
-		return nil, err
+		return nil, wrapError("sale", "Get", err)
 	}
 
+	if err := m.loadItems(ctx, sale); err != nil {
+		return nil, wrapError("sale", "Get", err)
+	}
 	return sale, nil
 }
 
 // GetAll retrieves sales based on filtering criteria and pagination.
 func (m *SaleModel) GetAll(filter SaleFilter) ([]*Sale, MetaData, error) {
+	sortColumn, err := filter.Filter.SortColumn()
+	if err != nil {
+		return nil, MetaData{}, wrapError("sale", "GetAll", err)
+	}
+
 	query := fmt.Sprintf(`
-        SELECT COUNT(*) OVER(), id, user_id, product_id, quantity, sold_at
-        FROM sales
-        WHERE (user_id = $1 OR $1 = 0)
-          AND (product_id = $2 OR $2 = 0)
-          AND (CASE WHEN $3 = '' THEN TRUE ELSE sold_at >= $3::timestamp END)
-          AND (CASE WHEN $4 = '' THEN TRUE ELSE sold_at <= $4::timestamp END)
-          AND (quantity >= $5 OR $5 = 0)
-          AND (quantity <= $6 OR $6 = 0)
+        SELECT COUNT(*) OVER(), s.id, s.client_uuid, s.user_id, s.subtotal_amount, s.discount_amount, s.tax_amount, s.total_amount, s.currency, s.note, s.customer_id, s.customer_email, s.sold_at, s.updated_at, s.created_by, s.updated_by, s.archived_at, s.discount_id, s.discount_code, s.status, s.voided_at, s.voided_by
+        FROM sales s
+        CROSS JOIN LATERAL (SELECT COALESCE(SUM(quantity), 0) AS quantity FROM sale_items WHERE sale_id = s.id) t
+        WHERE (s.user_id = $1 OR $1 = 0)
+          AND ($2 = 0 OR EXISTS (SELECT 1 FROM sale_items i WHERE i.sale_id = s.id AND i.product_id = $2))
+          AND (CASE WHEN $3 = '' THEN TRUE ELSE s.sold_at >= $3::timestamp END)
+          AND (CASE WHEN $4 = '' THEN TRUE ELSE s.sold_at <= $4::timestamp END)
+          AND (t.quantity >= $5 OR $5 = 0)
+          AND (t.quantity <= $6 OR $6 = 0)
+          AND (s.created_by = $7 OR $7 IS NULL)
+          AND (s.updated_by = $8 OR $8 IS NULL)
+          AND (s.archived_at IS NULL OR $9)
+          AND (s.customer_id = $12 OR $12 = 0)
+          AND (s.status = $13 OR $13 = '')
         ORDER BY %s %s
-        LIMIT $7 OFFSET $8
-    `, filter.Filter.SortColumn(), filter.Filter.SortDirection())
+        LIMIT $10 OFFSET $11
+    `, sortColumn, filter.Filter.SortDirection())
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
 	defer cancel()
-	rows, err := m.DB.QueryContext(ctx, query, filter.UserID, filter.ProductID, filter.MinDate, filter.MaxDate, filter.MinQty, filter.MaxQty, filter.Filter.Limit(), filter.Filter.Offset())
+	rows, err := m.DB.QueryContext(ctx, query, filter.UserID, filter.ProductID, filter.MinDate, filter.MaxDate, filter.MinQty, filter.MaxQty, filter.CreatedBy, filter.UpdatedBy, filter.IncludeArchived, filter.Filter.Limit(), filter.Filter.Offset(), filter.CustomerID, filter.Status)
 	if err != nil {
-		return nil, MetaData{}, err
+		return nil, MetaData{}, wrapError("sale", "GetAll", err)
 	}
 	defer rows.Close()
 
@@ -166,17 +608,467 @@ func (m *SaleModel) GetAll(filter SaleFilter) ([]*Sale, MetaData, error) {
 
 	for rows.Next() {
 		sale := &Sale{}
-		if err := rows.Scan(&totalRecords, &sale.ID, &sale.UserID, &sale.ProductID, &sale.Quantity, &sale.SoldAt); err != nil {
-			return nil, MetaData{}, err
+		if err := rows.Scan(&totalRecords, &sale.ID, &sale.ClientUUID, &sale.UserID, &sale.Subtotal, &sale.Discount, &sale.Tax, &sale.Total, &sale.Currency, &sale.Note, &sale.CustomerID, &sale.CustomerEmail, &sale.SoldAt, &sale.UpdatedAt, &sale.CreatedBy, &sale.UpdatedBy, &sale.ArchivedAt, &sale.DiscountID, &sale.DiscountCode, &sale.Status, &sale.VoidedAt, &sale.VoidedBy); err != nil {
+			return nil, MetaData{}, wrapError("sale", "GetAll", err)
 		}
 		sales = append(sales, sale)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, MetaData{}, err
+		return nil, MetaData{}, wrapError("sale", "GetAll", err)
+	}
+	if err := m.loadItems(ctx, sales...); err != nil {
+		return nil, MetaData{}, wrapError("sale", "GetAll", err)
 	}
 
-	metadata := CalculateMetaData(totalRecords, filter.Filter.Page, filter.Filter.PageSize)
+	metadata := CalculateMetaData(totalRecords, filter.Filter)
 
 	return sales, metadata, nil
 }
+
+// InsertSynced adds a sale recorded offline, deduplicating on its client UUID, and takes its items
+// from stock. Offline terminals cannot check discount codes, so synced sales are never discounted.
+// It reports whether a new row was created; for duplicates the stored sale is loaded into sale.
+// It returns ErrUnknownUser or ErrUnknownProduct when the sale names a record that does not exist.
+func (m *SaleModel) InsertSynced(sale *Sale) (bool, error) {
+	query := `
+		INSERT INTO sales (client_uuid, user_id, note, customer_email, created_by, updated_by, sold_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5, COALESCE($6, $7), NOW())
+		ON CONFLICT (client_uuid) DO NOTHING
+		RETURNING id, sold_at, updated_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, wrapError("sale", "InsertSynced", err)
+	}
+	defer tx.Rollback()
+
+	// A zero sold_at is sent as NULL so the sale is stamped with the current time
+	err = tx.QueryRowContext(ctx, query, sale.ClientUUID, sale.UserID, sale.Note, sale.CustomerEmail, sale.CreatedBy, sale.SoldAt, clockNow(m.Clock)).Scan(&sale.ID, &sale.SoldAt, &sale.UpdatedAt)
+	if err == nil {
+		if err := insertSaleItems(ctx, tx, sale, nil, nil); err != nil {
+			return false, wrapError("sale", "InsertSynced", err)
+		}
+		// The goods already left the shop, so stock may go below zero here
+		if err := takeSaleItemsStock(ctx, tx, sale, false); err != nil {
+			return false, wrapError("sale", "InsertSynced", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return false, wrapError("sale", "InsertSynced", err)
+		}
+		sale.UpdatedBy, sale.Status = sale.CreatedBy, SaleCompleted
+		return true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, wrapError("sale", "InsertSynced", saleReferenceError(err))
+	}
+
+	// The UUID already exists, so hand back the stored copy
+	existing, err := m.GetByClientUUID(*sale.ClientUUID)
+	if err != nil {
+		return false, wrapError("sale", "InsertSynced", err)
+	}
+	*sale = *existing
+	return false, nil
+}
+
+// GetByClientUUID retrieves a sale by the UUID generated on the terminal that recorded it.
+func (m *SaleModel) GetByClientUUID(clientUUID string) (*Sale, error) {
+	query := `
+		SELECT id, client_uuid, user_id, subtotal_amount, discount_amount, tax_amount, total_amount, currency, note, customer_id, customer_email, sold_at, updated_at, created_by, updated_by, archived_at, discount_id, discount_code, status, voided_at, voided_by
+		FROM sales
+		WHERE client_uuid = $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	sale := &Sale{}
+
+	if err := m.DB.QueryRowContext(ctx, query, clientUUID).Scan(&sale.ID, &sale.ClientUUID, &sale.UserID, &sale.Subtotal, &sale.Discount, &sale.Tax, &sale.Total, &sale.Currency, &sale.Note, &sale.CustomerID, &sale.CustomerEmail, &sale.SoldAt, &sale.UpdatedAt, &sale.CreatedBy, &sale.UpdatedBy, &sale.ArchivedAt, &sale.DiscountID, &sale.DiscountCode, &sale.Status, &sale.VoidedAt, &sale.VoidedBy); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, wrapError("sale", "GetByClientUUID", err)
+	}
+
+	if err := m.loadItems(ctx, sale); err != nil {
+		return nil, wrapError("sale", "GetByClientUUID", err)
+	}
+	return sale, nil
+}
+
+// GetChangesSince returns sales created or updated and sales deleted after the cursor, oldest first.
+// Each feed pages on its own and the returned cursor carries on from the last row of each.
+func (m *SaleModel) GetChangesSince(cursor SyncCursor, limit int64) (*SaleChanges, error) {
+	salesQuery := `
+		SELECT id, client_uuid, user_id, subtotal_amount, discount_amount, tax_amount, total_amount, currency, note, customer_id, customer_email, sold_at, updated_at, created_by, updated_by, archived_at, discount_id, discount_code, status, voided_at, voided_by
+		FROM sales
+		WHERE (updated_at, id) > ($1, $2)
+		ORDER BY updated_at ASC, id ASC
+		LIMIT $3
+	`
+	deletionsQuery := `
+		SELECT sale_id, client_uuid, deleted_at
+		FROM sales_deletions
+		WHERE (deleted_at, sale_id) > ($1, $2)
+		ORDER BY deleted_at ASC, sale_id ASC
+		LIMIT $3
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	changes := &SaleChanges{
+		Sales:      []*Sale{},
+		Deleted:    []*SaleDeletion{},
+		NextCursor: cursor,
+	}
+
+	// Fetch one extra row so we can tell the client whether to keep paging
+	rows, err := m.DB.QueryContext(ctx, salesQuery, cursor.SalesAt, cursor.SaleID, limit+1)
+	if err != nil {
+		return nil, wrapError("sale", "GetChangesSince", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		sale := &Sale{}
+		if err := rows.Scan(&sale.ID, &sale.ClientUUID, &sale.UserID, &sale.Subtotal, &sale.Discount, &sale.Tax, &sale.Total, &sale.Currency, &sale.Note, &sale.CustomerID, &sale.CustomerEmail, &sale.SoldAt, &sale.UpdatedAt, &sale.CreatedBy, &sale.UpdatedBy, &sale.ArchivedAt, &sale.DiscountID, &sale.DiscountCode, &sale.Status, &sale.VoidedAt, &sale.VoidedBy); err != nil {
+			return nil, wrapError("sale", "GetChangesSince", err)
+		}
+		changes.Sales = append(changes.Sales, sale)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("sale", "GetChangesSince", err)
+	}
+	if int64(len(changes.Sales)) > limit {
+		changes.HasMore = true
+		changes.Sales = changes.Sales[:limit]
+	}
+	if err := m.loadItems(ctx, changes.Sales...); err != nil {
+		return nil, wrapError("sale", "GetChangesSince", err)
+	}
+
+	deletedRows, err := m.DB.QueryContext(ctx, deletionsQuery, cursor.DeletedAt, cursor.DeletedID, limit+1)
+	if err != nil {
+		return nil, wrapError("sale", "GetChangesSince", err)
+	}
+	defer deletedRows.Close()
+
+	for deletedRows.Next() {
+		deletion := &SaleDeletion{}
+		if err := deletedRows.Scan(&deletion.SaleID, &deletion.ClientUUID, &deletion.DeletedAt); err != nil {
+			return nil, wrapError("sale", "GetChangesSince", err)
+		}
+		changes.Deleted = append(changes.Deleted, deletion)
+	}
+	if err := deletedRows.Err(); err != nil {
+		return nil, wrapError("sale", "GetChangesSince", err)
+	}
+	if int64(len(changes.Deleted)) > limit {
+		changes.HasMore = true
+		changes.Deleted = changes.Deleted[:limit]
+	}
+
+	if n := len(changes.Sales); n > 0 {
+		changes.NextCursor.SalesAt = changes.Sales[n-1].UpdatedAt.Time
+		changes.NextCursor.SaleID = changes.Sales[n-1].ID
+	}
+	if n := len(changes.Deleted); n > 0 {
+		changes.NextCursor.DeletedAt = changes.Deleted[n-1].DeletedAt.Time
+		changes.NextCursor.DeletedID = changes.Deleted[n-1].SaleID
+	}
+
+	return changes, nil
+}
+
+// SelectIDs resolves a bulk selection to the matching sale IDs, returning at most MaxBulkSales+1
+// so callers can tell when a selection is too large.
+func (m *SaleModel) SelectIDs(sel SaleSelection) ([]int64, error) {
+	query := `
+		SELECT id
+		FROM sales
+		WHERE (id = ANY($1) OR cardinality($1::bigint[]) = 0)
+		  AND (user_id = $2 OR $2 = 0)
+		  AND ($3 = 0 OR EXISTS (SELECT 1 FROM sale_items i WHERE i.sale_id = sales.id AND i.product_id = $3))
+		  AND (CASE WHEN $4 = '' THEN TRUE ELSE sold_at >= $4::timestamptz END)
+		  AND (CASE WHEN $5 = '' THEN TRUE ELSE sold_at <= $5::timestamptz END)
+		ORDER BY id ASC
+		LIMIT $6
+	`
+
+	ids := sel.IDs
+	if ids == nil {
+		ids = []int64{}
+	}
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids), sel.UserID, sel.ProductID, sel.MinDate, sel.MaxDate, MaxBulkSales+1)
+	if err != nil {
+		return nil, wrapError("sale", "SelectIDs", err)
+	}
+	defer rows.Close()
+
+	matched := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, wrapError("sale", "SelectIDs", err)
+		}
+		matched = append(matched, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("sale", "SelectIDs", err)
+	}
+
+	return matched, nil
+}
+
+// DeleteMany removes the given sales, recording each deletion for syncing terminals,
+// and returns the IDs that were actually deleted.
+func (m *SaleModel) DeleteMany(ids []int64) ([]int64, error) {
+	query := `
+		WITH deleted AS (
+			DELETE FROM sales
+			WHERE id = ANY($1)
+			RETURNING id, client_uuid
+		), logged AS (
+			INSERT INTO sales_deletions (sale_id, client_uuid, deleted_at)
+			SELECT id, client_uuid, NOW() FROM deleted
+			ON CONFLICT (sale_id) DO UPDATE SET deleted_at = EXCLUDED.deleted_at
+		)
+		SELECT id FROM deleted ORDER BY id ASC
+	`
+
+	return m.bulkIDs(query, pq.Array(ids))
+}
+
+// ArchiveMany hides the given sales from default listings without deleting them,
+// and returns the IDs that were newly archived.
+func (m *SaleModel) ArchiveMany(ids []int64, userID int64) ([]int64, error) {
+	query := `
+		WITH archived AS (
+			UPDATE sales
+			SET archived_at = NOW(), updated_at = NOW(), updated_by = $2
+			WHERE id = ANY($1) AND archived_at IS NULL
+			RETURNING id
+		)
+		SELECT id FROM archived ORDER BY id ASC
+	`
+
+	return m.bulkIDs(query, pq.Array(ids), userID)
+}
+
+// bulkIDs runs a bulk statement that returns the affected sale IDs.
+func (m *SaleModel) bulkIDs(query string, args ...any) ([]int64, error) {
+	ctx, cancel := m.Timeouts.queryContext(ReportQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, wrapError("sale", "bulkIDs", err)
+	}
+	defer rows.Close()
+
+	affected := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, wrapError("sale", "bulkIDs", err)
+		}
+		affected = append(affected, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("sale", "bulkIDs", err)
+	}
+
+	return affected, nil
+}
+
+// loadItems fills in the items and quantity of the given sales with one query.
+func (m *SaleModel) loadItems(ctx context.Context, sales ...*Sale) error {
+	if len(sales) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT sale_id, id, product_id, quantity, unit_price, currency, line_total, discount_amount, tax_amount
+		FROM sale_items
+		WHERE sale_id = ANY($1)
+		ORDER BY sale_id, id
+	`
+
+	byID := make(map[int64]*Sale, len(sales))
+	ids := make([]int64, len(sales))
+	for i, sale := range sales {
+		sale.Items = []*SaleItem{}
+		byID[sale.ID] = sale
+		ids[i] = sale.ID
+	}
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return wrapError("sale", "loadItems", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var saleID int64
+		item := &SaleItem{}
+		if err := rows.Scan(&saleID, &item.ID, &item.ProductID, &item.Quantity, &item.UnitPrice, &item.Currency, &item.LineTotal, &item.Discount, &item.Tax); err != nil {
+			return wrapError("sale", "loadItems", err)
+		}
+		if sale, ok := byID[saleID]; ok {
+			sale.Items = append(sale.Items, item)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return wrapError("sale", "loadItems", err)
+	}
+
+	for _, sale := range sales {
+		sale.totalQuantity()
+	}
+	return nil
+}
+
+// saleReferenceError turns a foreign key violation writing a sale or its items into the error for
+// the user, product or customer that does not exist. Other errors are returned unchanged.
+func saleReferenceError(err error) error {
+	var pqError *pq.Error
+	if !errors.As(err, &pqError) || pqError.Code != "23503" { // foreign_key_violation
+		return err
+	}
+	switch {
+	case strings.Contains(pqError.Constraint, "product_id"):
+		return ErrUnknownProduct
+	case strings.Contains(pqError.Constraint, "customer_id"):
+		return ErrUnknownCustomer
+	case strings.Contains(pqError.Constraint, "user_id"):
+		return ErrUnknownUser
+	}
+	return err
+}
+
+// insertSaleItems adds the items of a sale inside the transaction that wrote the sale, in order,
+// takes the discount off them when one is given, adds the tax rates in force and stores the sale's
+// totals. Items take their price from kept when the product was already on the sale, and otherwise
+// from the product. It returns ErrUnknownProduct when a product does not exist, ErrMixedCurrencies
+// when the items are priced in different currencies and ErrDiscountNotApplicable when the discount
+// covers no item.
+func insertSaleItems(ctx context.Context, tx *sql.Tx, sale *Sale, kept map[int64]*SaleItem, discount *Discount) error {
+	query := `
+		INSERT INTO sale_items (sale_id, product_id, quantity, unit_price, currency, line_total)
+		SELECT $1::bigint, p.id, $3::bigint, p.price, p.currency, ROUND($3::bigint * p.price, 2)
+		FROM (
+			SELECT id, COALESCE($4, price) AS price, COALESCE($5, currency) AS currency
+			FROM products
+			WHERE id = $2
+		) p
+		RETURNING id, unit_price, currency, line_total
+	`
+	totalQuery := `
+		UPDATE sales s
+		SET subtotal_amount = t.subtotal, discount_amount = t.discount, tax_amount = t.tax,
+		    total_amount = t.subtotal - t.discount + t.tax, currency = $2, discount_id = $3, discount_code = $4
+		FROM (
+			SELECT COALESCE(SUM(line_total), 0) AS subtotal, COALESCE(SUM(discount_amount), 0) AS discount,
+			       COALESCE(SUM(tax_amount), 0) AS tax
+			FROM sale_items
+			WHERE sale_id = $1
+		) t
+		WHERE s.id = $1
+		RETURNING s.subtotal_amount, s.discount_amount, s.tax_amount, s.total_amount
+	`
+	adjustQuery := `
+		UPDATE sale_items
+		SET discount_amount = $1, tax_amount = $2
+		WHERE id = $3
+	`
+
+	for _, item := range sale.Items {
+		var price *float64
+		var currency *string
+		if previous, ok := kept[item.ProductID]; ok {
+			price, currency = &previous.UnitPrice, &previous.Currency
+		}
+
+		item.Discount, item.Tax = 0, 0
+		err := tx.QueryRowContext(ctx, query, sale.ID, item.ProductID, item.Quantity, price, currency).Scan(&item.ID, &item.UnitPrice, &item.Currency, &item.LineTotal)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrUnknownProduct
+			}
+			return saleReferenceError(err)
+		}
+		if item.Currency != sale.Items[0].Currency {
+			return ErrMixedCurrencies
+		}
+	}
+
+	sale.Currency = sale.Items[0].Currency
+	tags, err := productTags(ctx, tx, sale.Items)
+	if err != nil {
+		return err
+	}
+	sale.DiscountID, sale.DiscountCode = nil, nil
+	if discount != nil {
+		if err := discount.allocate(sale.Items, tags); err != nil {
+			return err
+		}
+		sale.DiscountID, sale.DiscountCode = &discount.ID, &discount.Code
+	}
+	rates, err := saleTaxRates(ctx, tx)
+	if err != nil {
+		return err
+	}
+	rates.apply(sale.Items, tags)
+
+	for _, item := range sale.Items {
+		if item.Discount == 0 && item.Tax == 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, adjustQuery, item.Discount, item.Tax, item.ID); err != nil {
+			return err
+		}
+	}
+	err = tx.QueryRowContext(ctx, totalQuery, sale.ID, sale.Currency, sale.DiscountID, sale.DiscountCode).Scan(&sale.Subtotal, &sale.Discount, &sale.Tax, &sale.Total)
+	if err != nil {
+		return err
+	}
+	sale.totalQuantity()
+	return nil
+}
+
+// deleteSaleItems removes the items of a sale inside the transaction that rewrites them and returns
+// them by product, so products that stay on the sale can keep their price.
+func deleteSaleItems(ctx context.Context, tx *sql.Tx, saleID int64) (map[int64]*SaleItem, error) {
+	query := `
+		DELETE FROM sale_items
+		WHERE sale_id = $1
+		RETURNING product_id, unit_price, currency
+	`
+
+	rows, err := tx.QueryContext(ctx, query, saleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	kept := make(map[int64]*SaleItem)
+	for rows.Next() {
+		item := &SaleItem{}
+		if err := rows.Scan(&item.ProductID, &item.UnitPrice, &item.Currency); err != nil {
+			return nil, err
+		}
+		kept[item.ProductID] = item
+	}
+	return kept, rows.Err()
+}
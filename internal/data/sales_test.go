@@ -0,0 +1,67 @@
+// File: internal/data/sales_test.go
+// Description: test suite for the errors returned when writing sales
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// TestSaleReferenceError tests that foreign key violations name the missing record and other errors
+// are passed through
+func TestSaleReferenceError(t *testing.T) {
+	other := errors.New("connection reset")
+
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{name: "Missing User", err: &pq.Error{Code: "23503", Constraint: "sales_user_id_fkey"}, expected: ErrUnknownUser},
+		{name: "Missing Product", err: &pq.Error{Code: "23503", Constraint: "sale_items_product_id_fkey"}, expected: ErrUnknownProduct},
+		{name: "Missing Customer", err: &pq.Error{Code: "23503", Constraint: "sales_customer_id_fkey"}, expected: ErrUnknownCustomer},
+		{name: "Wrapped", err: fmt.Errorf("insert sale: %w", &pq.Error{Code: "23503", Constraint: "sales_user_id_fkey"}), expected: ErrUnknownUser},
+		{name: "Other Violation", err: &pq.Error{Code: "23505", Constraint: "sales_client_uuid_key"}},
+		{name: "Other Error", err: other, expected: other},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := saleReferenceError(tt.err)
+			if tt.expected == nil {
+				if got != tt.err {
+					t.Errorf("expected the error unchanged, got %v", got)
+				}
+				return
+			}
+			if !errors.Is(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestSyncCursor tests that cursors survive the round trip to a terminal and garbage is refused
+func TestSyncCursor(t *testing.T) {
+	at := time.Date(2025, 3, 14, 15, 30, 0, 123456000, time.UTC)
+	cursor := SyncCursor{SalesAt: at, SaleID: 42, DeletedAt: at.Add(-time.Hour), DeletedID: 7}
+
+	parsed, err := ParseSyncCursor(cursor.String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !parsed.SalesAt.Equal(cursor.SalesAt) || parsed.SaleID != cursor.SaleID || !parsed.DeletedAt.Equal(cursor.DeletedAt) || parsed.DeletedID != cursor.DeletedID {
+		t.Errorf("expected %+v, got %+v", cursor, parsed)
+	}
+
+	for _, value := range []string{"2025-03-14T15:30:00Z", "bm9wZQ", "!!"} {
+		if _, err := ParseSyncCursor(value); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%q: expected ErrInvalidCursor, got %v", value, err)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+// File: internal/data/schedules.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/cron"
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// ScheduleJobs are the background jobs a schedule can run.
+var ScheduleJobs = []string{"cleanup", "low_stock_alerts"}
+
+// Outcomes of a schedule's last run. A schedule that never ran has no status.
+const (
+	ScheduleRunning   = "running"
+	ScheduleSucceeded = "succeeded"
+	ScheduleFailed    = "failed"
+)
+
+// StaleRunAfter is how long a run may stay running before it is taken to have died with its
+// process, so the schedule can run again.
+const StaleRunAfter = time.Hour
+
+// Schedule runs a background job whenever its cron expression fires in its timezone.
+type Schedule struct {
+	ID             int64     `json:"id"`
+	Name           string    `json:"name"`
+	Job            string    `json:"job"`
+	Cron           string    `json:"cron"`
+	Timezone       string    `json:"timezone"`
+	Enabled        bool      `json:"enabled"`
+	NextRunAt      Timestamp `json:"next_run_at"` // null while disabled
+	LastRunAt      Timestamp `json:"last_run_at"`
+	LastStatus     string    `json:"last_status,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+	LastDurationMS *int64    `json:"last_duration_ms"`
+	CreatedBy      *int64    `json:"created_by"`
+	UpdatedBy      *int64    `json:"updated_by"`
+	CreatedAt      Timestamp `json:"created_at"`
+	UpdatedAt      Timestamp `json:"updated_at"`
+}
+
+// ScheduleModel wraps a sql.DB connection pool.
+type ScheduleModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+	Clock    Clock
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// ValidateSchedule checks the name, job, cron expression and timezone of a schedule.
+func ValidateSchedule(v *validator.Validator, schedule *Schedule) {
+	v.Check(strings.TrimSpace(schedule.Name) != "", "name", "must be provided")
+	v.Check(len(schedule.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(v.Permitted(schedule.Job, ScheduleJobs...), "job", "must be one of "+strings.Join(ScheduleJobs, ", "))
+	ValidateTimezone(v, schedule.Timezone)
+
+	expr, err := cron.Parse(schedule.Cron)
+	if err != nil {
+		v.AddError("cron", "must be a valid cron expression: "+strings.TrimPrefix(err.Error(), "cron: "))
+		return
+	}
+	if _, err := expr.Next(time.Now()); err != nil {
+		v.AddError("cron", "must fire at least once in the next five years")
+	}
+}
+
+// Next returns when the schedule next runs after t, or the zero time while it is disabled. The
+// schedule must have passed ValidateSchedule.
+func (s *Schedule) Next(t time.Time) (time.Time, error) {
+	if !s.Enabled {
+		return time.Time{}, nil
+	}
+	expr, err := cron.Parse(s.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next, err := expr.Next(t.In(loc))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return next.UTC(), nil
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// scheduleColumns are the columns scanned by scanSchedule, in order.
+const scheduleColumns = `id, name, job, cron, timezone, enabled, next_run_at, last_run_at, COALESCE(last_status, ''),
+	last_error, last_duration_ms, created_by, updated_by, created_at, updated_at`
+
+// scanSchedule reads a row of scheduleColumns.
+func scanSchedule(row interface{ Scan(...any) error }) (*Schedule, error) {
+	s := &Schedule{}
+	err := row.Scan(&s.ID, &s.Name, &s.Job, &s.Cron, &s.Timezone, &s.Enabled, &s.NextRunAt, &s.LastRunAt, &s.LastStatus,
+		&s.LastError, &s.LastDurationMS, &s.CreatedBy, &s.UpdatedBy, &s.CreatedAt, &s.UpdatedAt)
+	return s, err
+}
+
+// Insert adds a schedule, working out its first run. It returns ErrDuplicateScheduleName when the
+// name is taken.
+func (m *ScheduleModel) Insert(schedule *Schedule) error {
+	query := `
+		INSERT INTO schedules (name, job, cron, timezone, enabled, next_run_at, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING id, updated_by, created_at, updated_at
+	`
+
+	next, err := schedule.Next(clockNow(m.Clock))
+	if err != nil {
+		return wrapError("schedule", "Insert", err)
+	}
+	schedule.NextRunAt = NewTimestamp(next)
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, schedule.Name, schedule.Job, schedule.Cron, schedule.Timezone, schedule.Enabled, schedule.NextRunAt, schedule.CreatedBy).Scan(&schedule.ID, &schedule.UpdatedBy, &schedule.CreatedAt, &schedule.UpdatedAt)
+	if err != nil {
+		var pqError *pq.Error
+		if errors.As(err, &pqError) && pqError.Code == "23505" { // unique_violation
+			return ErrDuplicateScheduleName
+		}
+		return wrapError("schedule", "Insert", err)
+	}
+	return nil
+}
+
+// Get retrieves a schedule by its ID.
+func (m *ScheduleModel) Get(id int64) (*Schedule, error) {
+	query := `
+		SELECT ` + scheduleColumns + `
+		FROM schedules
+		WHERE id = $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	schedule, err := scanSchedule(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, wrapError("schedule", "Get", err)
+	}
+	return schedule, nil
+}
+
+// GetAll returns every schedule, soonest to run first and disabled ones last.
+func (m *ScheduleModel) GetAll() ([]*Schedule, error) {
+	query := `
+		SELECT ` + scheduleColumns + `
+		FROM schedules
+		ORDER BY next_run_at NULLS LAST, name
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, wrapError("schedule", "GetAll", err)
+	}
+	defer rows.Close()
+
+	schedules := []*Schedule{}
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, wrapError("schedule", "GetAll", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("schedule", "GetAll", err)
+	}
+	return schedules, nil
+}
+
+// Update saves the name, job, cron expression, timezone and enabled flag of a schedule and works
+// out its next run again. It returns ErrDuplicateScheduleName when the name is taken.
+func (m *ScheduleModel) Update(schedule *Schedule) error {
+	query := `
+		UPDATE schedules
+		SET name = $1, job = $2, cron = $3, timezone = $4, enabled = $5, next_run_at = $6, updated_by = $7, updated_at = NOW()
+		WHERE id = $8
+		RETURNING updated_at
+	`
+
+	next, err := schedule.Next(clockNow(m.Clock))
+	if err != nil {
+		return wrapError("schedule", "Update", err)
+	}
+	schedule.NextRunAt = NewTimestamp(next)
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, schedule.Name, schedule.Job, schedule.Cron, schedule.Timezone, schedule.Enabled, schedule.NextRunAt, schedule.UpdatedBy, schedule.ID).Scan(&schedule.UpdatedAt)
+	if err != nil {
+		var pqError *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case errors.As(err, &pqError) && pqError.Code == "23505": // unique_violation
+			return ErrDuplicateScheduleName
+		}
+		return wrapError("schedule", "Update", err)
+	}
+	return nil
+}
+
+// Delete removes a schedule. A run already under way finishes.
+func (m *ScheduleModel) Delete(id int64) error {
+	query := `
+		DELETE FROM schedules
+		WHERE id = $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return wrapError("schedule", "Delete", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return wrapError("schedule", "Delete", err)
+	} else if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// ClaimDue marks the enabled schedules due by now as running and moves them on to their next run,
+// returning them for the caller to run. Schedules still running are left until they finish, and
+// rows locked by another instance are skipped, so each run is claimed once.
+func (m *ScheduleModel) ClaimDue(now time.Time) ([]*Schedule, error) {
+	query := `
+		SELECT ` + scheduleColumns + `
+		FROM schedules
+		WHERE enabled AND next_run_at <= $1
+		  AND (last_status IS DISTINCT FROM 'running' OR last_run_at < $2)
+		ORDER BY next_run_at
+		FOR UPDATE SKIP LOCKED
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, wrapError("schedule", "ClaimDue", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, now, now.Add(-StaleRunAfter))
+	if err != nil {
+		return nil, wrapError("schedule", "ClaimDue", err)
+	}
+	defer rows.Close()
+
+	due := []*Schedule{}
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, wrapError("schedule", "ClaimDue", err)
+		}
+		due = append(due, schedule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("schedule", "ClaimDue", err)
+	}
+	rows.Close()
+
+	for _, schedule := range due {
+		next, err := schedule.Next(now)
+		if err != nil {
+			return nil, wrapError("schedule", "ClaimDue", err)
+		}
+		schedule.NextRunAt = NewTimestamp(next)
+		if err := startRun(ctx, tx, schedule, now); err != nil {
+			return nil, wrapError("schedule", "ClaimDue", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, wrapError("schedule", "ClaimDue", err)
+	}
+	return due, nil
+}
+
+// StartRun marks a schedule as running now, outside its cron times, keeping its next run. It
+// returns ErrScheduleRunning when a run is already under way and ErrRecordNotFound when the
+// schedule does not exist.
+func (m *ScheduleModel) StartRun(id int64) (*Schedule, error) {
+	query := `
+		SELECT ` + scheduleColumns + `
+		FROM schedules
+		WHERE id = $1
+		FOR UPDATE
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, wrapError("schedule", "StartRun", err)
+	}
+	defer tx.Rollback()
+
+	schedule, err := scanSchedule(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, wrapError("schedule", "StartRun", err)
+	}
+
+	now := clockNow(m.Clock)
+	if schedule.LastStatus == ScheduleRunning && now.Sub(schedule.LastRunAt.Time) < StaleRunAfter {
+		return nil, ErrScheduleRunning
+	}
+	if err := startRun(ctx, tx, schedule, now); err != nil {
+		return nil, wrapError("schedule", "StartRun", err)
+	}
+	return schedule, wrapError("schedule", "StartRun", tx.Commit())
+}
+
+// startRun marks a schedule running as of now inside tx, saving its next run.
+func startRun(ctx context.Context, tx *sql.Tx, schedule *Schedule, now time.Time) error {
+	query := `
+		UPDATE schedules
+		SET last_status = 'running', last_run_at = $1, last_error = '', next_run_at = $2
+		WHERE id = $3
+	`
+
+	schedule.LastStatus = ScheduleRunning
+	schedule.LastRunAt = NewTimestamp(now.Truncate(time.Microsecond)) // as stored, for FinishRun to match
+	schedule.LastError = ""
+	_, err := tx.ExecContext(ctx, query, schedule.LastRunAt, schedule.NextRunAt, schedule.ID)
+	return err
+}
+
+// FinishRun records the outcome of the run started at startedAt, the LastRunAt of the schedule it
+// was claimed as, failed when runErr is not nil. A run that has since been superseded is ignored.
+func (m *ScheduleModel) FinishRun(id int64, startedAt time.Time, runErr error) error {
+	query := `
+		UPDATE schedules
+		SET last_status = $1, last_error = $2, last_duration_ms = $3
+		WHERE id = $4 AND last_run_at = $5
+	`
+
+	status, message := ScheduleSucceeded, ""
+	if runErr != nil {
+		status, message = ScheduleFailed, runErr.Error()
+	}
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, status, message, time.Since(startedAt).Milliseconds(), id, NewTimestamp(startedAt))
+	return wrapError("schedule", "FinishRun", err)
+}
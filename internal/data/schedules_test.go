@@ -0,0 +1,61 @@
+// File: internal/data/schedules_test.go
+// Description: test suite for validating schedules and working out their next run
+
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// TestValidateSchedule tests the name, job, cron expression and timezone rules of schedules
+func TestValidateSchedule(t *testing.T) {
+	tests := []struct {
+		name       string
+		schedule   Schedule
+		errorField string
+	}{
+		{name: "Valid", schedule: Schedule{Name: "Nightly cleanup", Job: "cleanup", Cron: "0 3 * * *", Timezone: "UTC"}},
+		{name: "Macro In Timezone", schedule: Schedule{Name: "Stock", Job: "low_stock_alerts", Cron: "@hourly", Timezone: "America/Belize"}},
+		{name: "Missing Name", schedule: Schedule{Name: " ", Job: "cleanup", Cron: "@daily", Timezone: "UTC"}, errorField: "name"},
+		{name: "Unknown Job", schedule: Schedule{Name: "Backup", Job: "backup", Cron: "@daily", Timezone: "UTC"}, errorField: "job"},
+		{name: "Bad Cron", schedule: Schedule{Name: "Cleanup", Job: "cleanup", Cron: "0 25 * * *", Timezone: "UTC"}, errorField: "cron"},
+		{name: "Never Fires", schedule: Schedule{Name: "Cleanup", Job: "cleanup", Cron: "0 0 30 2 *", Timezone: "UTC"}, errorField: "cron"},
+		{name: "Bad Timezone", schedule: Schedule{Name: "Cleanup", Job: "cleanup", Cron: "@daily", Timezone: "Mars/Olympus"}, errorField: "timezone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateSchedule(v, &tt.schedule)
+
+			if tt.errorField == "" && !v.IsValid() {
+				t.Errorf("expected a valid schedule, got %v", v.Errors)
+			}
+			if _, ok := v.Errors[tt.errorField]; tt.errorField != "" && !ok {
+				t.Errorf("expected an error on %s, got %v", tt.errorField, v.Errors)
+			}
+		})
+	}
+}
+
+// TestScheduleNext tests that runs follow the schedule's timezone and that disabled schedules have none
+func TestScheduleNext(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	schedule := &Schedule{Cron: "0 3 * * *", Timezone: "America/Belize", Enabled: true} // UTC-6
+	next, err := schedule.Next(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Date(2026, 6, 2, 9, 0, 0, 0, time.UTC); !next.Equal(expected) || next.Location() != time.UTC {
+		t.Errorf("expected %v, got %v", expected, next)
+	}
+
+	schedule.Enabled = false
+	if next, err := schedule.Next(now); err != nil || !next.IsZero() {
+		t.Errorf("expected no next run while disabled, got %v, %v", next, err)
+	}
+}
@@ -0,0 +1,386 @@
+// File: internal/data/stock.go
+package data
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// Reasons a stock level changes. Receipts and adjustments come from the stock endpoint,
+// sales from completing a sale, returns from refunds and voids from voiding a completed sale.
+const (
+	StockReceipt    = "receipt"
+	StockAdjustment = "adjustment"
+	StockSale       = "sale"
+	StockReturn     = "return"
+	StockVoid       = "void"
+)
+
+// StockMovement is one change to the stock level of a product. Quantity is positive for stock
+// coming in and negative for stock going out.
+type StockMovement struct {
+	ID        int64     `json:"id"`
+	ProductID int64     `json:"product_id"`
+	Quantity  int64     `json:"quantity"`
+	Reason    string    `json:"reason"`
+	SaleID    *int64    `json:"sale_id,omitempty"` // the sale that took the stock, or was refunded or voided, for sale, return and void movements
+	Note      string    `json:"note,omitempty"`
+	CreatedBy *int64    `json:"created_by"`
+	CreatedAt Timestamp `json:"created_at"`
+}
+
+// LowStockProduct is a tracked product whose stock is below its reorder threshold.
+type LowStockProduct struct {
+	ID               int64      `json:"id"`
+	Name             string     `json:"name"`
+	StockQuantity    int64      `json:"stock_quantity"`
+	ReorderThreshold int64      `json:"reorder_threshold"`
+	Shortfall        int64      `json:"shortfall"`   // units needed to get back to the threshold
+	NotifiedAt       *Timestamp `json:"notified_at"` // when the low stock alert went out, null until it has
+}
+
+// StockModel wraps a sql.DB connection pool.
+type StockModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// ValidateStockMovement checks a movement sent to the stock endpoint. Receipts add stock,
+// adjustments correct it in either direction after a count.
+func ValidateStockMovement(v *validator.Validator, movement *StockMovement) {
+	v.Check(v.Permitted(movement.Reason, StockReceipt, StockAdjustment), "reason", fmt.Sprintf("must be %s or %s", StockReceipt, StockAdjustment))
+	v.Check(movement.Quantity != 0, "quantity", "must not be zero")
+	if movement.Reason == StockReceipt {
+		v.Check(movement.Quantity > 0, "quantity", "must be greater than zero for a receipt")
+	}
+	v.Check(movement.Quantity <= 1_000_000 && movement.Quantity >= -1_000_000, "quantity", "must be between -1000000 and 1000000")
+	v.Check(len(movement.Note) <= 500, "note", "must not be more than 500 bytes long")
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// Record applies a receipt or adjustment to the product's stock and logs it, returning the new
+// stock level. The first movement of an untracked product starts tracking it from zero. It
+// returns ErrRecordNotFound for an unknown product and ErrInsufficientStock when an adjustment
+// would take the stock below zero.
+func (m *StockModel) Record(movement *StockMovement) (int64, error) {
+	query := `
+		UPDATE products
+		SET stock_quantity = COALESCE(stock_quantity, 0) + $1, updated_by = $2, updated_at = NOW()
+		WHERE id = $3
+		RETURNING stock_quantity
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, wrapError("stock", "Record", err)
+	}
+	defer tx.Rollback()
+
+	var stock int64
+	if err := tx.QueryRowContext(ctx, query, movement.Quantity, movement.CreatedBy, movement.ProductID).Scan(&stock); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrRecordNotFound
+		}
+		return 0, wrapError("stock", "Record", err)
+	}
+	if stock < 0 {
+		return 0, ErrInsufficientStock
+	}
+
+	if err := insertStockMovement(ctx, tx, movement); err != nil {
+		return 0, wrapError("stock", "Record", err)
+	}
+
+	return stock, wrapError("stock", "Record", tx.Commit())
+}
+
+// GetAllForProduct returns the stock movements of a product, newest first.
+func (m *StockModel) GetAllForProduct(productID int64, filter Filter) ([]*StockMovement, MetaData, error) {
+	query := `
+		SELECT COUNT(*) OVER(), id, product_id, quantity, reason, sale_id, note, created_by, created_at
+		FROM stock_movements
+		WHERE product_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, productID, filter.Limit(), filter.Offset())
+	if err != nil {
+		return nil, MetaData{}, wrapError("stock", "GetAllForProduct", err)
+	}
+	defer rows.Close()
+
+	movements := []*StockMovement{}
+	totalRecords := int64(0)
+
+	for rows.Next() {
+		movement := &StockMovement{}
+		if err := rows.Scan(&totalRecords, &movement.ID, &movement.ProductID, &movement.Quantity, &movement.Reason, &movement.SaleID, &movement.Note, &movement.CreatedBy, &movement.CreatedAt); err != nil {
+			return nil, MetaData{}, wrapError("stock", "GetAllForProduct", err)
+		}
+		movements = append(movements, movement)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, MetaData{}, wrapError("stock", "GetAllForProduct", err)
+	}
+
+	return movements, CalculateMetaData(totalRecords, filter), nil
+}
+
+// GetLowStock returns the unarchived products whose stock is below their reorder threshold,
+// largest shortfall first.
+func (m *StockModel) GetLowStock(filter Filter) ([]*LowStockProduct, MetaData, error) {
+	query := `
+		SELECT COUNT(*) OVER(), id, name, stock_quantity, reorder_threshold, reorder_threshold - stock_quantity, low_stock_notified_at
+		FROM products
+		WHERE stock_quantity < reorder_threshold AND NOT is_archived
+		ORDER BY reorder_threshold - stock_quantity DESC, id ASC
+		LIMIT $1 OFFSET $2
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filter.Limit(), filter.Offset())
+	if err != nil {
+		return nil, MetaData{}, wrapError("stock", "GetLowStock", err)
+	}
+	defer rows.Close()
+
+	products := []*LowStockProduct{}
+	totalRecords := int64(0)
+
+	for rows.Next() {
+		product := &LowStockProduct{}
+		if err := rows.Scan(&totalRecords, &product.ID, &product.Name, &product.StockQuantity, &product.ReorderThreshold, &product.Shortfall, &product.NotifiedAt); err != nil {
+			return nil, MetaData{}, wrapError("stock", "GetLowStock", err)
+		}
+		products = append(products, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, MetaData{}, wrapError("stock", "GetLowStock", err)
+	}
+
+	return products, CalculateMetaData(totalRecords, filter), nil
+}
+
+// ClaimLowStockAlerts marks the low stock products that have not been alerted on yet as notified
+// and returns them, by ID. Products back at or above their threshold are unmarked first so their
+// next shortage is reported again. Claiming with the update keeps two instances from sending the
+// same alert.
+func (m *StockModel) ClaimLowStockAlerts() ([]*LowStockProduct, error) {
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, wrapError("stock", "ClaimLowStockAlerts", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE products
+		SET low_stock_notified_at = NULL
+		WHERE low_stock_notified_at IS NOT NULL
+		  AND (stock_quantity IS NULL OR stock_quantity >= reorder_threshold)
+	`
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return nil, wrapError("stock", "ClaimLowStockAlerts", err)
+	}
+
+	query = `
+		UPDATE products
+		SET low_stock_notified_at = NOW()
+		WHERE stock_quantity < reorder_threshold AND NOT is_archived AND low_stock_notified_at IS NULL
+		RETURNING id, name, stock_quantity, reorder_threshold, reorder_threshold - stock_quantity, low_stock_notified_at
+	`
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, wrapError("stock", "ClaimLowStockAlerts", err)
+	}
+	defer rows.Close()
+
+	products := []*LowStockProduct{}
+	for rows.Next() {
+		product := &LowStockProduct{}
+		if err := rows.Scan(&product.ID, &product.Name, &product.StockQuantity, &product.ReorderThreshold, &product.Shortfall, &product.NotifiedAt); err != nil {
+			return nil, wrapError("stock", "ClaimLowStockAlerts", err)
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("stock", "ClaimLowStockAlerts", err)
+	}
+	slices.SortFunc(products, func(a, b *LowStockProduct) int { return cmp.Compare(a.ID, b.ID) })
+
+	return products, wrapError("stock", "ClaimLowStockAlerts", tx.Commit())
+}
+
+// takeSaleItemsStock takes every item of a sale from stock. Products are updated in ID order, so
+// sales sharing products lock their rows in the same order and cannot deadlock each other.
+func takeSaleItemsStock(ctx context.Context, tx *sql.Tx, sale *Sale, enforce bool) error {
+	for _, item := range inProductOrder(sale.Items) {
+		if err := takeSaleStock(ctx, tx, sale, item, enforce); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// takeSaleStock removes the quantity of a sale item from a tracked product inside the sale's
+// transaction and logs the movement. With enforce set it returns ErrInsufficientStock instead of
+// going below zero; sales synced from offline terminals have already happened, so they may. The
+// check and the decrement are one statement, and the row stays locked until the sale commits, so
+// concurrent sales of the last unit cannot both take it.
+func takeSaleStock(ctx context.Context, tx *sql.Tx, sale *Sale, item *SaleItem, enforce bool) error {
+	query := `
+		UPDATE products
+		SET stock_quantity = stock_quantity - $1
+		WHERE id = $2 AND stock_quantity IS NOT NULL AND (stock_quantity >= $1 OR NOT $3)
+		RETURNING stock_quantity
+	`
+	trackedQuery := `
+		SELECT stock_quantity IS NOT NULL
+		FROM products
+		WHERE id = $1
+	`
+
+	var stock int64
+	err := tx.QueryRowContext(ctx, query, item.Quantity, item.ProductID, enforce).Scan(&stock)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// Nothing was taken, either because stock is not tracked or because too little is left
+		var tracked bool
+		if err := tx.QueryRowContext(ctx, trackedQuery, item.ProductID).Scan(&tracked); err != nil {
+			return err
+		}
+		if tracked {
+			return ErrInsufficientStock
+		}
+		return nil
+	case err != nil:
+		return err
+	}
+
+	return insertStockMovement(ctx, tx, &StockMovement{
+		ProductID: item.ProductID,
+		Quantity:  -item.Quantity,
+		Reason:    StockSale,
+		SaleID:    &sale.ID,
+		CreatedBy: sale.CreatedBy,
+	})
+}
+
+// returnRefundStock puts the refunded units of an item back into a tracked product inside the
+// refund's transaction and logs the movement against the sale.
+func returnRefundStock(ctx context.Context, tx *sql.Tx, refund *Refund, item *RefundItem) error {
+	query := `
+		UPDATE products
+		SET stock_quantity = stock_quantity + $1
+		WHERE id = $2 AND stock_quantity IS NOT NULL
+	`
+
+	result, err := tx.ExecContext(ctx, query, item.Quantity, item.ProductID)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		return err // stock of this product is not tracked
+	}
+
+	return insertStockMovement(ctx, tx, &StockMovement{
+		ProductID: item.ProductID,
+		Quantity:  item.Quantity,
+		Reason:    StockReturn,
+		SaleID:    &refund.SaleID,
+		Note:      fmt.Sprintf("refund %d", refund.ID),
+		CreatedBy: refund.CreatedBy,
+	})
+}
+
+// returnVoidedItemsStock puts every item of a voided sale back into stock, in product ID order
+// like takeSaleItemsStock.
+func returnVoidedItemsStock(ctx context.Context, tx *sql.Tx, sale *Sale) error {
+	for _, item := range inProductOrder(sale.Items) {
+		if err := returnVoidedStock(ctx, tx, sale, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returnVoidedStock puts the units of an item of a voided sale back into a tracked product inside
+// the void's transaction and logs the movement against the sale.
+func returnVoidedStock(ctx context.Context, tx *sql.Tx, sale *Sale, item *SaleItem) error {
+	query := `
+		UPDATE products
+		SET stock_quantity = stock_quantity + $1
+		WHERE id = $2 AND stock_quantity IS NOT NULL
+	`
+
+	result, err := tx.ExecContext(ctx, query, item.Quantity, item.ProductID)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		return err // stock of this product is not tracked
+	}
+
+	return insertStockMovement(ctx, tx, &StockMovement{
+		ProductID: item.ProductID,
+		Quantity:  item.Quantity,
+		Reason:    StockVoid,
+		SaleID:    &sale.ID,
+		CreatedBy: sale.VoidedBy,
+	})
+}
+
+// insertStockMovement logs a movement inside the transaction that changed the stock.
+func insertStockMovement(ctx context.Context, tx *sql.Tx, movement *StockMovement) error {
+	query := `
+		INSERT INTO stock_movements (product_id, quantity, reason, sale_id, note, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	return tx.QueryRowContext(ctx, query, movement.ProductID, movement.Quantity, movement.Reason, movement.SaleID, movement.Note, movement.CreatedBy).Scan(&movement.ID, &movement.CreatedAt)
+}
+
+// inProductOrder returns the items sorted by product ID, leaving the sale's own order alone. Stock
+// is always changed in this order so concurrent transactions lock product rows in the same order.
+func inProductOrder(items []*SaleItem) []*SaleItem {
+	return slices.SortedStableFunc(slices.Values(items), func(a, b *SaleItem) int {
+		return cmp.Compare(a.ProductID, b.ProductID)
+	})
+}
@@ -0,0 +1,27 @@
+// File: internal/data/stock_test.go
+// Description: test suite for the order stock is taken in
+
+package data
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestInProductOrder tests that items are taken from stock in product ID order, keeping repeated
+// products in the order they were rung up and leaving the sale's own items untouched
+func TestInProductOrder(t *testing.T) {
+	first := &SaleItem{ProductID: 9, Quantity: 1}
+	items := []*SaleItem{first, {ProductID: 2, Quantity: 1}, {ProductID: 9, Quantity: 2}, {ProductID: 5, Quantity: 1}}
+
+	var got []int64
+	for _, item := range inProductOrder(items) {
+		got = append(got, item.ProductID*10+item.Quantity)
+	}
+	if want := []int64{21, 51, 91, 92}; !slices.Equal(got, want) {
+		t.Errorf("expected products and quantities %v, got %v", want, got)
+	}
+	if items[0] != first {
+		t.Error("expected the sale's items to keep their order")
+	}
+}
@@ -0,0 +1,229 @@
+// File: internal/data/targets.go
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// MonthLayout is the format of months in target routes and responses.
+const MonthLayout = "2006-01"
+
+// maxTargetAmount is the largest amount the NUMERIC(12, 2) column holds.
+const maxTargetAmount = 9_999_999_999.99
+
+// RevenueTarget is the revenue the business aims for in one calendar month, in the base currency.
+type RevenueTarget struct {
+	ID        int64     `json:"id"`
+	Month     string    `json:"month"` // YYYY-MM
+	Amount    float64   `json:"amount"`
+	CreatedBy *int64    `json:"created_by"`
+	UpdatedBy *int64    `json:"updated_by"`
+	CreatedAt Timestamp `json:"created_at"`
+	UpdatedAt Timestamp `json:"updated_at"`
+}
+
+// TargetProgress compares the revenue of a month so far with its target. The projection assumes
+// the rest of the month sells at the same rate per open day as the part already elapsed, so days
+// the business calendar has it closed count for nothing.
+type TargetProgress struct {
+	Month                string  `json:"month"`
+	Timezone             string  `json:"timezone"`
+	Currency             string  `json:"currency"`
+	Target               float64 `json:"target"`
+	Actual               float64 `json:"actual"`
+	PercentOfTarget      float64 `json:"percent_of_target"`
+	DaysInMonth          int     `json:"days_in_month"`
+	DaysElapsed          float64 `json:"days_elapsed"`
+	OpenDays             int     `json:"open_days"`         // days of the month the business opens
+	OpenDaysElapsed      float64 `json:"open_days_elapsed"` // of those, with today counted by its opening hours gone by
+	ProjectedRevenue     float64 `json:"projected_revenue"`
+	ProjectedPercent     float64 `json:"projected_percent"`
+	OnPace               bool    `json:"on_pace"`
+	RequiredDailyRevenue float64 `json:"required_daily_revenue"` // needed per remaining open day to reach the target, 0 once reached or over
+}
+
+// TargetModel wraps a sql.DB connection pool.
+type TargetModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// ValidateRevenueTarget checks the month and amount of a target.
+func ValidateRevenueTarget(v *validator.Validator, target *RevenueTarget) {
+	_, err := time.Parse(MonthLayout, target.Month)
+	v.Check(err == nil, "month", "must be a valid month in YYYY-MM format")
+	v.Check(target.Amount > 0, "amount", "must be greater than zero")
+	v.Check(target.Amount <= maxTargetAmount, "amount", "must not be more than 9999999999.99")
+}
+
+// newTargetProgress works out the progress of a month running from start to end at the time now,
+// open on the days of cal.
+func newTargetProgress(target, actual float64, start, end, now time.Time, cal *BusinessCalendar) *TargetProgress {
+	progress := &TargetProgress{
+		Month:       start.Format(MonthLayout),
+		Timezone:    start.Location().String(),
+		Target:      target,
+		Actual:      actual,
+		DaysInMonth: end.AddDate(0, 0, -1).Day(),
+	}
+	progress.PercentOfTarget = roundCents(100 * actual / target)
+
+	total := end.Sub(start)
+	elapsed := min(max(now.Sub(start), 0), total)
+	progress.DaysElapsed = math.Round(float64(progress.DaysInMonth)*elapsed.Hours()/total.Hours()*10) / 10
+	progress.OpenDays, progress.OpenDaysElapsed = cal.OpenDays(start, end, now)
+
+	switch {
+	case elapsed == total:
+		progress.ProjectedRevenue = actual
+	case progress.OpenDaysElapsed == 0:
+		progress.ProjectedRevenue = 0 // nothing to extrapolate from before the first opening
+	default:
+		progress.ProjectedRevenue = roundCents(actual * float64(progress.OpenDays) / progress.OpenDaysElapsed)
+	}
+	progress.ProjectedPercent = roundCents(100 * progress.ProjectedRevenue / target)
+	progress.OnPace = progress.ProjectedRevenue >= target
+
+	if remaining := float64(progress.OpenDays) - progress.OpenDaysElapsed; remaining > 0 && actual < target {
+		progress.RequiredDailyRevenue = roundCents((target - actual) / remaining)
+	}
+	return progress
+}
+
+// roundCents rounds an amount to two decimals.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// Upsert sets the target of a month, replacing any existing one. UpdatedBy is recorded as the
+// creator of a new target.
+func (m *TargetModel) Upsert(target *RevenueTarget) error {
+	query := `
+		INSERT INTO revenue_targets (month, amount, created_by, updated_by)
+		VALUES (($1::text || '-01')::date, $2, $3, $3)
+		ON CONFLICT (month) DO UPDATE
+		SET amount = EXCLUDED.amount, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+		RETURNING id, created_by, created_at, updated_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, target.Month, target.Amount, target.UpdatedBy).Scan(&target.ID, &target.CreatedBy, &target.CreatedAt, &target.UpdatedAt)
+}
+
+// GetAllForYear returns the targets set for the months of a year, in month order.
+func (m *TargetModel) GetAllForYear(year int) ([]*RevenueTarget, error) {
+	query := `
+		SELECT id, to_char(month, 'YYYY-MM'), amount, created_by, updated_by, created_at, updated_at
+		FROM revenue_targets
+		WHERE EXTRACT(YEAR FROM month) = $1
+		ORDER BY month
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, year)
+	if err != nil {
+		return nil, wrapError("target", "GetAllForYear", err)
+	}
+	defer rows.Close()
+
+	targets := []*RevenueTarget{}
+	for rows.Next() {
+		target := &RevenueTarget{}
+		if err := rows.Scan(&target.ID, &target.Month, &target.Amount, &target.CreatedBy, &target.UpdatedBy, &target.CreatedAt, &target.UpdatedAt); err != nil {
+			return nil, wrapError("target", "GetAllForYear", err)
+		}
+		targets = append(targets, target)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError("target", "GetAllForYear", err)
+	}
+	return targets, nil
+}
+
+// Delete removes the target of a month, returning ErrRecordNotFound when none is set.
+func (m *TargetModel) Delete(month string) error {
+	query := `
+		DELETE FROM revenue_targets
+		WHERE month = ($1::text || '-01')::date
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, month)
+	if err != nil {
+		return wrapError("target", "Delete", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return wrapError("target", "Delete", err)
+	} else if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Progress compares the revenue of the month starting at start, a first of the month in the
+// report's timezone, with its target as of now, both converted by fx, projecting over the days cal
+// has the business open. It returns ErrRecordNotFound when the month has no target. Revenue is at
+// the prices the items were sold at, less the refunds made in the month, without archived sales,
+// as in the other reports.
+func (m *TargetModel) Progress(start, now time.Time, fx *Conversion, cal *BusinessCalendar) (*TargetProgress, error) {
+	query := `
+		SELECT t.amount::float8,
+		       COALESCE((
+		           SELECT ROUND(SUM(i.line_total * fx.factor)::numeric, 2)
+		           FROM sales s
+		           JOIN revenue_lines i ON i.sale_id = s.id
+		           LEFT JOIN unnest($4::text[], $5::float8[]) AS fx (currency, factor) ON fx.currency = i.currency
+		           WHERE s.archived_at IS NULL AND i.occurred_at >= $2 AND i.occurred_at < $3
+		       ), 0)::float8
+		FROM revenue_targets t
+		WHERE t.month = $1::date
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReportQuery)
+	defer cancel()
+
+	end := start.AddDate(0, 1, 0)
+	var target, actual float64
+	currencies, factors := fx.sqlArgs()
+	err := m.DB.QueryRowContext(ctx, query, start.Format(time.DateOnly), start, end, currencies, factors).Scan(&target, &actual)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, wrapError("target", "Progress", err)
+	}
+
+	progress := newTargetProgress(fx.Convert(target, fx.Base), actual, start, end, now, cal)
+	progress.Currency = fx.Currency
+	return progress, nil
+}
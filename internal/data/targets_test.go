@@ -0,0 +1,136 @@
+// File: internal/data/targets_test.go
+// Description: test suite for revenue target validation and progress projections
+
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// TestValidateRevenueTarget tests the month and amount rules of targets
+func TestValidateRevenueTarget(t *testing.T) {
+	tests := []struct {
+		name       string
+		month      string
+		amount     float64
+		errorField string
+	}{
+		{name: "Valid Target", month: "2025-03", amount: 50000},
+		{name: "Single Digit Month", month: "2025-3", amount: 50000, errorField: "month"},
+		{name: "Month Thirteen", month: "2025-13", amount: 50000, errorField: "month"},
+		{name: "Full Date", month: "2025-03-01", amount: 50000, errorField: "month"},
+		{name: "Zero Amount", month: "2025-03", amount: 0, errorField: "amount"},
+		{name: "Too Large", month: "2025-03", amount: 10_000_000_000, errorField: "amount"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateRevenueTarget(v, &RevenueTarget{Month: tt.month, Amount: tt.amount})
+
+			if tt.errorField == "" && !v.IsValid() {
+				t.Errorf("expected a valid target, got %v", v.Errors)
+			}
+			if _, ok := v.Errors[tt.errorField]; tt.errorField != "" && !ok {
+				t.Errorf("expected an error on %s, got %v", tt.errorField, v.Errors)
+			}
+		})
+	}
+}
+
+// TestNewTargetProgress tests percentages, pace projections and the daily revenue still needed
+func TestNewTargetProgress(t *testing.T) {
+	start := time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC) // 30 days
+	end := start.AddDate(0, 1, 0)
+
+	tests := []struct {
+		name     string
+		actual   float64
+		now      time.Time
+		expected TargetProgress
+	}{
+		{
+			name:   "Behind Pace",
+			actual: 2000,
+			now:    start.AddDate(0, 0, 10),
+			expected: TargetProgress{
+				PercentOfTarget: 20, DaysElapsed: 10, ProjectedRevenue: 6000, ProjectedPercent: 60,
+				OnPace: false, RequiredDailyRevenue: 400,
+			},
+		},
+		{
+			name:   "Ahead Of Pace",
+			actual: 6000,
+			now:    start.AddDate(0, 0, 15),
+			expected: TargetProgress{
+				PercentOfTarget: 60, DaysElapsed: 15, ProjectedRevenue: 12000, ProjectedPercent: 120,
+				OnPace: true, RequiredDailyRevenue: 266.67,
+			},
+		},
+		{
+			name:   "Target Reached",
+			actual: 10500,
+			now:    start.AddDate(0, 0, 20),
+			expected: TargetProgress{
+				PercentOfTarget: 105, DaysElapsed: 20, ProjectedRevenue: 15750, ProjectedPercent: 157.5,
+				OnPace: true, RequiredDailyRevenue: 0,
+			},
+		},
+		{
+			name:   "Month Over",
+			actual: 9000,
+			now:    end.AddDate(0, 0, 3),
+			expected: TargetProgress{
+				PercentOfTarget: 90, DaysElapsed: 30, ProjectedRevenue: 9000, ProjectedPercent: 90,
+				OnPace: false, RequiredDailyRevenue: 0,
+			},
+		},
+		{
+			name:   "Month Not Started",
+			actual: 0,
+			now:    start.AddDate(0, 0, -2),
+			expected: TargetProgress{
+				PercentOfTarget: 0, DaysElapsed: 0, ProjectedRevenue: 0, ProjectedPercent: 0,
+				OnPace: false, RequiredDailyRevenue: 333.33,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newTargetProgress(10000, tt.actual, start, end, tt.now, nil)
+
+			tt.expected.Month, tt.expected.Timezone = "2025-04", "UTC"
+			tt.expected.Target, tt.expected.Actual, tt.expected.DaysInMonth = 10000, tt.actual, 30
+			tt.expected.OpenDays, tt.expected.OpenDaysElapsed = 30, tt.expected.DaysElapsed
+			if *got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, *got)
+			}
+		})
+	}
+}
+
+// TestNewTargetProgressClosedDays tests that closed weekdays and holidays are left out of the pace
+func TestNewTargetProgressClosedDays(t *testing.T) {
+	start := time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC) // a Tuesday, with four Sundays
+	end := start.AddDate(0, 1, 0)
+	cal := &BusinessCalendar{
+		Hours:    []*BusinessHours{{Weekday: "Sunday", Closed: true}},
+		Holidays: []*Holiday{{Date: "2025-04-18", Name: "Good Friday"}},
+	}
+
+	got := newTargetProgress(10000, 1800, start, end, start.AddDate(0, 0, 10), cal)
+
+	if got.OpenDays != 25 || got.OpenDaysElapsed != 9 {
+		t.Fatalf("expected 9 of 25 open days elapsed, got %v of %d", got.OpenDaysElapsed, got.OpenDays)
+	}
+	if got.DaysElapsed != 10 {
+		t.Errorf("expected 10 calendar days elapsed, got %v", got.DaysElapsed)
+	}
+	if got.ProjectedRevenue != 5000 || got.RequiredDailyRevenue != 512.5 {
+		t.Errorf("expected a projection of 5000 needing 512.5 a day, got %v needing %v", got.ProjectedRevenue, got.RequiredDailyRevenue)
+	}
+}
@@ -0,0 +1,256 @@
+// File: internal/data/taxes.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"slices"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// TaxRate is a percentage of tax added on top of what items sell for after discounts. The rate with
+// no tag, such as a general GST, applies to every product; a rate with a tag replaces it for the
+// products carrying that tag. A product with several taxed tags takes the lowest of their rates, so a
+// zero rate exempts a tag whatever else the product carries.
+type TaxRate struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Rate      float64   `json:"rate"` // percent, up to three decimal places
+	Tag       string    `json:"tag"`  // empty for the rate of every product, unique
+	CreatedBy *int64    `json:"created_by"`
+	UpdatedBy *int64    `json:"updated_by"`
+	CreatedAt Timestamp `json:"created_at"`
+	UpdatedAt Timestamp `json:"updated_at"`
+}
+
+// TaxRates are the rates in force when a sale is priced.
+type TaxRates []*TaxRate
+
+// TaxModel wraps a sql.DB connection pool.
+type TaxModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// ValidateTaxRate checks the name, rate and tag of a tax rate.
+func ValidateTaxRate(v *validator.Validator, rate *TaxRate) {
+	v.Check(rate.Name != "", "name", "must be provided")
+	v.Check(len(rate.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(rate.Rate >= 0, "rate", "must not be negative")
+	v.Check(rate.Rate <= 100, "rate", "must not be more than 100 percent")
+	v.Check(rate.Rate == math.Round(rate.Rate*1000)/1000, "rate", "must not have more than 3 decimal places")
+	if rate.Tag != "" {
+		ValidateTags(v, "tag", []string{rate.Tag})
+	}
+}
+
+// rateFor returns the percentage of tax on a product with the given tags, and whether any rate
+// applies to it at all.
+func (rates TaxRates) rateFor(tags []string) (float64, bool) {
+	var general, tagged *TaxRate
+	for _, rate := range rates {
+		switch {
+		case rate.Tag == "":
+			general = rate
+		case slices.Contains(tags, rate.Tag) && (tagged == nil || rate.Rate < tagged.Rate):
+			tagged = rate
+		}
+	}
+	switch {
+	case tagged != nil:
+		return tagged.Rate, true
+	case general != nil:
+		return general.Rate, true
+	}
+	return 0, false
+}
+
+// apply sets the tax of each item of a sale, given the tags of its products. Tax is charged on what
+// the item sells for after its discount and rounded to the cent per item.
+func (rates TaxRates) apply(items []*SaleItem, tags map[int64][]string) {
+	for _, item := range items {
+		item.Tax = 0
+		if rate, ok := rates.rateFor(tags[item.ProductID]); ok {
+			item.Tax = math.Round(float64(toCents(item.LineTotal)-toCents(item.Discount))*rate/100) / 100
+		}
+	}
+}
+
+// ----------------------------------------------------------------------
+//
+//	Database interaction methods
+//
+// ----------------------------------------------------------------------
+
+// taxRateColumns are the columns scanned by scanTaxRate, in order.
+const taxRateColumns = `id, name, rate, tag, created_by, updated_by, created_at, updated_at`
+
+// scanTaxRate reads a row of taxRateColumns.
+func scanTaxRate(row interface{ Scan(...any) error }) (*TaxRate, error) {
+	r := &TaxRate{}
+	err := row.Scan(&r.ID, &r.Name, &r.Rate, &r.Tag, &r.CreatedBy, &r.UpdatedBy, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+// Insert adds a tax rate. It returns ErrDuplicateTaxTag when the tag already has a rate.
+func (m *TaxModel) Insert(rate *TaxRate) error {
+	query := `
+		INSERT INTO tax_rates (name, rate, tag, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $4)
+		RETURNING id, updated_by, created_at, updated_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, rate.Name, rate.Rate, rate.Tag, rate.CreatedBy).Scan(&rate.ID, &rate.UpdatedBy, &rate.CreatedAt, &rate.UpdatedAt)
+	if err != nil {
+		var pqError *pq.Error
+		if errors.As(err, &pqError) && pqError.Code == "23505" { // unique_violation
+			return ErrDuplicateTaxTag
+		}
+		return wrapError("tax", "Insert", err)
+	}
+	return nil
+}
+
+// Get retrieves a tax rate by its ID.
+func (m *TaxModel) Get(id int64) (*TaxRate, error) {
+	query := `
+		SELECT ` + taxRateColumns + `
+		FROM tax_rates
+		WHERE id = $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rate, err := scanTaxRate(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, wrapError("tax", "Get", err)
+	}
+	return rate, nil
+}
+
+// GetAll returns every tax rate, the rate of every product first and then by tag.
+func (m *TaxModel) GetAll() (TaxRates, error) {
+	query := `
+		SELECT ` + taxRateColumns + `
+		FROM tax_rates
+		ORDER BY tag
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, wrapError("tax", "GetAll", err)
+	}
+	defer rows.Close()
+
+	rates := TaxRates{}
+	for rows.Next() {
+		rate, err := scanTaxRate(rows)
+		if err != nil {
+			return nil, wrapError("tax", "GetAll", err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, wrapError("tax", "GetAll", rows.Err())
+}
+
+// Update saves every field of a tax rate. Sales already made keep the tax they were charged. It
+// returns ErrDuplicateTaxTag when the new tag already has a rate.
+func (m *TaxModel) Update(rate *TaxRate) error {
+	query := `
+		UPDATE tax_rates
+		SET name = $1, rate = $2, tag = $3, updated_by = $4, updated_at = NOW()
+		WHERE id = $5
+		RETURNING created_by, created_at, updated_at
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, rate.Name, rate.Rate, rate.Tag, rate.UpdatedBy, rate.ID).Scan(&rate.CreatedBy, &rate.CreatedAt, &rate.UpdatedAt)
+	if err != nil {
+		var pqError *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case errors.As(err, &pqError) && pqError.Code == "23505": // unique_violation
+			return ErrDuplicateTaxTag
+		default:
+			return wrapError("tax", "Update", err)
+		}
+	}
+	return nil
+}
+
+// Delete removes a tax rate. Sales already made keep the tax they were charged.
+func (m *TaxModel) Delete(id int64) error {
+	query := `
+		DELETE FROM tax_rates
+		WHERE id = $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return wrapError("tax", "Delete", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return wrapError("tax", "Delete", err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// saleTaxRates reads the tax rates that price a sale inside tx.
+func saleTaxRates(ctx context.Context, tx *sql.Tx) (TaxRates, error) {
+	query := `
+		SELECT ` + taxRateColumns + `
+		FROM tax_rates
+	`
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := TaxRates{}
+	for rows.Next() {
+		rate, err := scanTaxRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
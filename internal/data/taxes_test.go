@@ -0,0 +1,83 @@
+// File: internal/data/taxes_test.go
+// Description: test suite for validating tax rates and charging them on the items of a sale
+
+package data
+
+import (
+	"testing"
+
+	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+)
+
+// TestValidateTaxRate tests the name, rate and tag rules of tax rates
+func TestValidateTaxRate(t *testing.T) {
+	tests := []struct {
+		name       string
+		rate       TaxRate
+		errorField string
+	}{
+		{name: "General", rate: TaxRate{Name: "GST", Rate: 12.5}},
+		{name: "Exempt Tag", rate: TaxRate{Name: "Basic food", Rate: 0, Tag: "basic-food"}},
+		{name: "Fine Rate", rate: TaxRate{Name: "Levy", Rate: 0.125, Tag: "fuel"}},
+		{name: "No Name", rate: TaxRate{Rate: 12.5}, errorField: "name"},
+		{name: "Negative", rate: TaxRate{Name: "GST", Rate: -1}, errorField: "rate"},
+		{name: "Over 100 Percent", rate: TaxRate{Name: "GST", Rate: 100.5}, errorField: "rate"},
+		{name: "Too Precise", rate: TaxRate{Name: "GST", Rate: 12.3456}, errorField: "rate"},
+		{name: "Bad Tag", rate: TaxRate{Name: "GST", Rate: 12.5, Tag: "Basic Food"}, errorField: "tag"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateTaxRate(v, &tt.rate)
+
+			if tt.errorField == "" && !v.IsValid() {
+				t.Errorf("expected a valid tax rate, got %v", v.Errors)
+			}
+			if _, ok := v.Errors[tt.errorField]; tt.errorField != "" && !ok {
+				t.Errorf("expected an error on %s, got %v", tt.errorField, v.Errors)
+			}
+		})
+	}
+}
+
+// TestTaxRatesApply tests which rate each item takes and the tax charged on it, to the cent
+func TestTaxRatesApply(t *testing.T) {
+	items := func() []*SaleItem {
+		return []*SaleItem{
+			{ProductID: 1, LineTotal: 10},
+			{ProductID: 2, LineTotal: 20, Discount: 2},
+			{ProductID: 3, LineTotal: 0.05},
+		}
+	}
+	tags := map[int64][]string{1: {"basic-food"}, 2: {"drinks", "imported"}}
+
+	general := &TaxRate{Rate: 12.5}
+	exempt := &TaxRate{Rate: 0, Tag: "basic-food"}
+	imported := &TaxRate{Rate: 20, Tag: "imported"}
+	drinks := &TaxRate{Rate: 15, Tag: "drinks"}
+
+	tests := []struct {
+		name     string
+		rates    TaxRates
+		expected []float64
+	}{
+		{name: "No Rates", rates: TaxRates{}, expected: []float64{0, 0, 0}},
+		{name: "General Only", rates: TaxRates{general}, expected: []float64{1.25, 2.25, 0.01}},
+		{name: "Exempt Tag", rates: TaxRates{general, exempt}, expected: []float64{0, 2.25, 0.01}},
+		{name: "Tag Replaces General", rates: TaxRates{general, imported}, expected: []float64{1.25, 3.6, 0.01}},
+		{name: "Lowest Tag Wins", rates: TaxRates{imported, drinks}, expected: []float64{0, 2.7, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := items()
+			tt.rates.apply(items, tags)
+			for i, item := range items {
+				if item.Tax != tt.expected[i] {
+					t.Errorf("item %d: expected a tax of %.2f, got %.2f", i, tt.expected[i], item.Tax)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// File: internal/data/timeouts.go
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// QueryClass groups queries that share a timeout.
+type QueryClass int
+
+const (
+	ReadQuery   QueryClass = iota // single row lookups, lists and authentication checks
+	WriteQuery                    // inserts, updates, deletes and their transactions
+	ReportQuery                   // aggregates and bulk operations over many rows
+	ExportQuery                   // full table scans streamed out to files
+)
+
+// Timeouts holds the timeout applied to each class of query. A zero field falls
+// back to the matching field of DefaultTimeouts.
+type Timeouts struct {
+	Read   time.Duration
+	Write  time.Duration
+	Report time.Duration
+	Export time.Duration
+
+	// Observe, when set, is called with how long each query context was held, for latency metrics.
+	Observe func(class QueryClass, elapsed time.Duration)
+
+	parent context.Context // request context queries are derived from, see WithContext
+}
+
+// DefaultTimeouts are used for any class that is not configured.
+var DefaultTimeouts = Timeouts{
+	Read:   2 * time.Second,
+	Write:  3 * time.Second,
+	Report: 15 * time.Second,
+	Export: 60 * time.Second,
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// String returns the name of the class used in metrics labels.
+func (c QueryClass) String() string {
+	switch c {
+	case WriteQuery:
+		return "write"
+	case ReportQuery:
+		return "report"
+	case ExportQuery:
+		return "export"
+	default:
+		return "read"
+	}
+}
+
+// For returns the timeout for a class of query.
+func (t Timeouts) For(class QueryClass) time.Duration {
+	var configured, fallback time.Duration
+	switch class {
+	case WriteQuery:
+		configured, fallback = t.Write, DefaultTimeouts.Write
+	case ReportQuery:
+		configured, fallback = t.Report, DefaultTimeouts.Report
+	case ExportQuery:
+		configured, fallback = t.Export, DefaultTimeouts.Export
+	default:
+		configured, fallback = t.Read, DefaultTimeouts.Read
+	}
+	if configured > 0 {
+		return configured
+	}
+	return fallback
+}
+
+// WithContext returns a copy of t whose queries are also cancelled when ctx is, so a request's
+// deadline or disconnect frees the connection. The class timeouts still apply.
+func (t Timeouts) WithContext(ctx context.Context) Timeouts {
+	t.parent = ctx
+	return t
+}
+
+// queryContext returns a context that expires after the timeout for the class of query, or
+// earlier when the parent context set by WithContext is done.
+// Cancelling it reports the elapsed time to Observe, which covers the query and reading its rows.
+func (t Timeouts) queryContext(class QueryClass) (context.Context, context.CancelFunc) {
+	parent := t.parent
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, t.For(class))
+	if t.Observe == nil {
+		return ctx, cancel
+	}
+
+	start := time.Now()
+	return ctx, func() {
+		cancel()
+		t.Observe(class, time.Since(start))
+	}
+}
@@ -0,0 +1,37 @@
+// File: internal/data/timeouts_test.go
+// Description: test suite for query timeouts and request contexts
+
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestQueryContextWithParent tests that query contexts end with the request context and keep their class timeout
+func TestQueryContextWithParent(t *testing.T) {
+	timeouts := Timeouts{Read: time.Hour}
+
+	ctx, cancel := timeouts.queryContext(ReadQuery)
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) < 59*time.Minute {
+		t.Errorf("expected the read timeout without a parent, got %v", deadline)
+	}
+	cancel()
+
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel = timeouts.WithContext(parent).queryContext(ReadQuery)
+	defer cancel()
+
+	cancelParent()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected the query context to end with its parent")
+	}
+
+	models := NewModels(nil, timeouts).WithContext(parent)
+	if models.Reports.Timeouts.parent != parent || models.ChatbotModel.Timeouts.parent != parent {
+		t.Error("expected every model to carry the request context")
+	}
+}
@@ -0,0 +1,87 @@
+// File: internal/data/timestamps.go
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// Timestamp wraps time.Time so that every timestamp in the API serializes the same way:
+// RFC3339 in UTC (with fractional seconds when present), or null when unset.
+// Conversion to a user's own timezone happens only at presentation endpoints such as reports and exports.
+type Timestamp struct {
+	time.Time
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// NewTimestamp wraps a time.Time as a Timestamp.
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Time: t}
+}
+
+// Now returns the current time as a Timestamp.
+func Now() Timestamp {
+	return Timestamp{Time: time.Now()}
+}
+
+// MarshalJSON encodes the timestamp as an RFC3339 string in UTC.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.UTC().Format(time.RFC3339Nano))
+}
+
+// UnmarshalJSON decodes an RFC3339 string, accepting null as the zero time.
+func (t *Timestamp) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner so timestamps can be read straight from timestamptz columns.
+func (t *Timestamp) Scan(src any) error {
+	switch value := src.(type) {
+	case nil:
+		t.Time = time.Time{}
+	case time.Time:
+		t.Time = value.UTC()
+	default:
+		return fmt.Errorf("cannot scan %T into Timestamp", src)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer so timestamps can be passed as query arguments.
+func (t Timestamp) Value() (driver.Value, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return t.UTC(), nil
+}
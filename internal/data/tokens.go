@@ -2,7 +2,7 @@
 package data
 
 import (
-	"context"
+	"bytes"
 	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/Pedro-J-Kukul/salesapi/internal/validator"
+	"github.com/lib/pq"
 )
 
 // ----------------------------------------------------------------------
@@ -23,6 +24,7 @@ const (
 	ScopeActivation     = "activation"
 	ScopeAuthentication = "authentication"
 	ScopePasswordReset  = "password_reset"
+	ScopeEmailChange    = "email_change"
 )
 
 // Token represents a token used for various purposes in the system.
@@ -30,13 +32,50 @@ type Token struct {
 	Plaintext string    `json:"token"`
 	Hash      []byte    `json:"-"`
 	UserID    int64     `json:"user_id"`
-	ExpiresAt time.Time `json:"expires_at"`
+	ExpiresAt Timestamp `json:"expires_at"`
 	Scope     string    `json:"scope"`
+
+	// Session metadata, only recorded for authentication tokens
+	UserAgent      string `json:"-"`
+	IP             string `json:"-"`
+	ImpersonatorID *int64 `json:"-"` // admin acting as the user, nil for the user's own sessions
+}
+
+// Session describes one authentication token without revealing it, so users can review where
+// they are signed in. ID is the encoded token hash.
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  Timestamp `json:"created_at"`
+	LastUsedAt Timestamp `json:"last_used_at"`
+	ExpiresAt  Timestamp `json:"expires_at"`
+	Current    bool      `json:"current"` // the session making the request
+
+	ImpersonatedBy *int64 `json:"impersonated_by,omitempty"` // admin who opened the session as this user
+}
+
+// RevokedSessions is a user whose authentication tokens were revoked in bulk, with the number of
+// sessions they lost. The contact details are for telling them and are not sent to clients.
+type RevokedSessions struct {
+	UserID    int64  `json:"user_id"`
+	Email     string `json:"-"`
+	FirstName string `json:"-"`
+	Locale    string `json:"-"`
+	Sessions  int64  `json:"sessions"`
 }
 
+// ActionRevokeTokens is the audit log action of a bulk revocation of authentication tokens.
+const ActionRevokeTokens = "security:revoke_tokens"
+
+// sessionLastUsedResolution limits how often last_used_at is written for a busy session.
+const sessionLastUsedResolution = time.Minute
+
 // TokenModel wraps a sql.DB connection pool.
 type TokenModel struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Timeouts Timeouts
+	Clock    Clock // the time tokens are issued and checked at, the system clock when nil
 }
 
 // ----------------------------------------------------------------------
@@ -45,10 +84,10 @@ type TokenModel struct {
 //
 // ----------------------------------------------------------------------
 
-func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
+func generateToken(userID int64, ttl time.Duration, scope string, now time.Time) (*Token, error) {
 	token := &Token{
 		UserID:    userID,
-		ExpiresAt: time.Now().Add(ttl),
+		ExpiresAt: NewTimestamp(now.Add(ttl)),
 		Scope:     scope,
 	}
 
@@ -76,18 +115,54 @@ func ValidateTokenPlaintext(v *validator.Validator, plaintext string) {
 // ----------------------------------------------------------------------
 // New creates a new token, inserts it into the database, and returns it.
 func (m *TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token, error) {
-	token, err := generateToken(userID, ttl, scope)
+	token, err := generateToken(userID, ttl, scope, clockNow(m.Clock))
 	if err != nil {
-		return nil, err
+		return nil, wrapError("token", "New", err)
 	}
 	// delete existing tokens for user and scope
 	err = m.DeleteAllForUser(scope, userID)
 	if err != nil {
-		return nil, err
+		return nil, wrapError("token", "New", err)
 	}
 	err = m.Insert(token)
 	if err != nil {
-		return nil, err
+		return nil, wrapError("token", "New", err)
+	}
+	return token, nil
+}
+
+// NewSession creates an authentication token carrying the client's user agent and IP. Unlike
+// New it keeps the user's other sessions, and only clears the ones that have expired.
+func (m *TokenModel) NewSession(userID int64, ttl time.Duration, userAgent, ip string) (*Token, error) {
+	token, err := generateToken(userID, ttl, ScopeAuthentication, clockNow(m.Clock))
+	if err != nil {
+		return nil, wrapError("token", "NewSession", err)
+	}
+	token.UserAgent = userAgent
+	token.IP = ip
+
+	if err := m.DeleteExpiredForUser(ScopeAuthentication, userID); err != nil {
+		return nil, wrapError("token", "NewSession", err)
+	}
+	if err := m.Insert(token); err != nil {
+		return nil, wrapError("token", "NewSession", err)
+	}
+	return token, nil
+}
+
+// NewImpersonation creates an authentication token for userID that the admin impersonatorID uses
+// to act as that user. The user's own sessions are left alone.
+func (m *TokenModel) NewImpersonation(userID, impersonatorID int64, ttl time.Duration, userAgent, ip string) (*Token, error) {
+	token, err := generateToken(userID, ttl, ScopeAuthentication, clockNow(m.Clock))
+	if err != nil {
+		return nil, wrapError("token", "NewImpersonation", err)
+	}
+	token.UserAgent = userAgent
+	token.IP = ip
+	token.ImpersonatorID = &impersonatorID
+
+	if err := m.Insert(token); err != nil {
+		return nil, wrapError("token", "NewImpersonation", err)
 	}
 	return token, nil
 }
@@ -95,14 +170,108 @@ func (m *TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token,
 // Insert inserts a new token into the database.
 func (m *TokenModel) Insert(token *Token) error {
 	query := `
-		INSERT INTO tokens (hash, user_id, expires_at, scope)
-		VALUES ($1, $2, $3, $4)`
+		INSERT INTO tokens (hash, user_id, expires_at, scope, user_agent, ip, impersonator_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, token.Hash, token.UserID, token.ExpiresAt, token.Scope, token.UserAgent, token.IP, token.ImpersonatorID)
+	return wrapError("token", "Insert", err)
+}
+
+// Touch records that a token was just used. Writes are skipped while the stored time is recent.
+func (m *TokenModel) Touch(tokenPlaintext string) error {
+	query := `
+		UPDATE tokens
+		SET last_used_at = NOW()
+		WHERE hash = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - make_interval(secs => $2))`
+
+	hash := sha256.Sum256([]byte(tokenPlaintext))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
 	defer cancel()
 
-	_, err := m.DB.ExecContext(ctx, query, token.Hash, token.UserID, token.ExpiresAt, token.Scope)
-	return err
+	_, err := m.DB.ExecContext(ctx, query, hash[:], sessionLastUsedResolution.Seconds())
+	return wrapError("token", "Touch", err)
+}
+
+// GetSessions lists the user's unexpired authentication tokens, newest first. The session whose
+// plaintext token is given is flagged as current.
+func (m *TokenModel) GetSessions(userID int64, currentPlaintext string) ([]*Session, error) {
+	query := `
+		SELECT hash, user_agent, ip, created_at, last_used_at, expires_at, impersonator_id
+		FROM tokens
+		WHERE user_id = $1 AND scope = $2 AND expires_at > $3
+		ORDER BY created_at DESC`
+
+	current := sha256.Sum256([]byte(currentPlaintext))
+
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, ScopeAuthentication, clockNow(m.Clock))
+	if err != nil {
+		return nil, wrapError("token", "GetSessions", err)
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		var hash []byte
+		var session Session
+		if err := rows.Scan(&hash, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &session.ImpersonatedBy); err != nil {
+			return nil, wrapError("token", "GetSessions", err)
+		}
+		session.ID = base64.RawURLEncoding.EncodeToString(hash)
+		session.Current = bytes.Equal(hash, current[:])
+		sessions = append(sessions, &session)
+	}
+	return sessions, wrapError("token", "GetSessions", rows.Err())
+}
+
+// DeleteExpiredForUser removes a user's expired tokens of one scope.
+func (m *TokenModel) DeleteExpiredForUser(scope string, userID int64) error {
+	query := `
+		DELETE FROM tokens
+		WHERE scope = $1 AND user_id = $2 AND expires_at <= $3`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, scope, userID, clockNow(m.Clock))
+	return wrapError("token", "DeleteExpiredForUser", err)
+}
+
+// DeleteExpired removes every expired token, returning how many there were.
+func (m *TokenModel) DeleteExpired() (int64, error) {
+	query := `
+		DELETE FROM tokens
+		WHERE expires_at <= $1`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, clockNow(m.Clock))
+	if err != nil {
+		return 0, wrapError("token", "DeleteExpired", err)
+	}
+	return result.RowsAffected()
+}
+
+// Delete removes a single token, such as the one used to make the current request.
+func (m *TokenModel) Delete(tokenPlaintext string) error {
+	query := `
+		DELETE FROM tokens
+		WHERE hash = $1`
+
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, hash[:])
+	return wrapError("token", "Delete", err)
 }
 
 // DeleteAllForUser deletes all tokens for a specific user and scope.
@@ -111,9 +280,51 @@ func (m *TokenModel) DeleteAllForUser(scope string, userID int64) error {
 		DELETE FROM tokens
 		WHERE scope = $1 AND user_id = $2`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
 	defer cancel()
 
 	_, err := m.DB.ExecContext(ctx, query, scope, userID)
-	return err
+	return wrapError("token", "DeleteAllForUser", err)
+}
+
+// RevokeAuthentication deletes the unexpired authentication tokens of every user, of the users
+// with the given role, or of the given users, signing them out everywhere. The token whose
+// plaintext is given is kept so the caller stays signed in. It returns the users who lost at
+// least one session, by ID.
+func (m *TokenModel) RevokeAuthentication(role string, userIDs []int64, keepPlaintext string) ([]*RevokedSessions, error) {
+	query := `
+		WITH revoked AS (
+			DELETE FROM tokens t
+			USING users u
+			WHERE u.id = t.user_id AND t.scope = $1 AND t.expires_at > $2 AND t.hash <> $3
+			  AND (u.role = $4 OR $4 = '')
+			  AND (t.user_id = ANY($5) OR cardinality($5::bigint[]) = 0)
+			RETURNING t.user_id
+		)
+		SELECT u.id, u.email, u.first_name, u.locale, COUNT(*)
+		FROM revoked r
+		JOIN users u ON u.id = r.user_id
+		GROUP BY u.id
+		ORDER BY u.id`
+
+	keep := sha256.Sum256([]byte(keepPlaintext))
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, ScopeAuthentication, clockNow(m.Clock), keep[:], role, pq.Array(userIDs))
+	if err != nil {
+		return nil, wrapError("token", "RevokeAuthentication", err)
+	}
+	defer rows.Close()
+
+	revoked := []*RevokedSessions{}
+	for rows.Next() {
+		var r RevokedSessions
+		if err := rows.Scan(&r.UserID, &r.Email, &r.FirstName, &r.Locale, &r.Sessions); err != nil {
+			return nil, wrapError("token", "RevokeAuthentication", err)
+		}
+		revoked = append(revoked, &r)
+	}
+	return revoked, wrapError("token", "RevokeAuthentication", rows.Err())
 }
@@ -3,6 +3,7 @@ package data
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
 	"errors"
@@ -35,25 +36,47 @@ type User struct {
 	Email     string    `json:"email"`
 	Password  Password  `json:"-"`
 	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	CreatedAt Timestamp `json:"created_at"`
+	UpdatedAt Timestamp `json:"updated_at"`
 	IsActive  bool      `json:"is_active"`
+	Timezone  string    `json:"timezone"`   // IANA zone used by presentation endpoints such as reports and exports
+	Locale    string    `json:"locale"`     // preferred language of the emails sent to the user
+	DeletedAt Timestamp `json:"deleted_at"` // set when the user is soft deleted, null otherwise
 	Version   int       `json:"version"`
+
+	TOTPEnabled bool   `json:"totp_enabled"`
+	TOTPSecret  []byte `json:"-"` // encrypted TOTP secret, set while 2FA is pending or enabled
+
+	EmailUndeliverable bool `json:"email_undeliverable"` // the address hard bounced or complained and no longer receives mail
+
+	ImpersonatedBy *int64    `json:"impersonated_by,omitempty"` // admin acting as this user, only set by GetForToken
+	LoggedInAt     Timestamp `json:"-"`                         // when the session making the request was opened, only set by GetForToken
+	SessionUsedAt  Timestamp `json:"-"`                         // when that session was last recorded as used, only set by GetForToken
 }
 
 // UserModel wraps a sql.DB connection pool.
 type UserModel struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Timeouts Timeouts
+	Clock    Clock
 }
 
 var AnonymousUser = &User{}
 
+// DeactivateOptions says what happens to the records of a deactivated user.
+type DeactivateOptions struct {
+	ReassignSalesTo *int64 // move every sale of the user to this user
+	Anonymize       bool   // replace name and email with placeholders, cannot be undone
+}
+
 type UserFilter struct {
 	Filter   Filter
 	Name     string
 	Email    string
 	Role     string
 	IsActive *bool
+
+	IncludeDeleted bool // include soft deleted users, only honoured for admins
 }
 
 // ----------------------------------------------------------------------
@@ -61,9 +84,9 @@ type UserFilter struct {
 //	Methods
 //
 // ----------------------------------------------------------------------
-// Set hashes a plaintext password and stores it in the Password struct.
+// Set hashes a plaintext password with the active hashing configuration and stores it in the Password struct.
 func (p *Password) Set(plaintextPassword string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), bcrypt.DefaultCost)
+	hashedPassword, err := hashPassword(ActivePasswordHashing(), plaintextPassword)
 	if err != nil {
 		return err
 	}
@@ -72,28 +95,77 @@ func (p *Password) Set(plaintextPassword string) error {
 	return nil
 }
 
-// Matches checks if the provided plaintext password matches the stored hashed password.
-func (p *Password) Matches(plaintextPassword string) (bool, error) {
-	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
+// SetRandom stores the hash of a random password nobody knows, for accounts whose owner sets
+// their own password later through a password reset token. The secret is never typed in, so the
+// cheapest bcrypt cost keeps bulk account creation fast without weakening anything.
+func (p *Password) SetRandom() error {
+	hashedPassword, err := hashPassword(PasswordHashing{Algorithm: HashBcrypt, Cost: bcrypt.MinCost}, rand.Text())
 	if err != nil {
-		return false, err
+		return err
 	}
-	return true, nil
+	p.plaintext = nil
+	p.hash = hashedPassword
+	return nil
+}
+
+// Matches checks if the provided plaintext password matches the stored hashed password.
+func (p *Password) Matches(plaintextPassword string) (bool, error) {
+	return comparePassword(p.hash, plaintextPassword)
+}
+
+// NeedsRehash reports whether the stored hash uses another algorithm or older parameters than
+// the active configuration, so it should be replaced the next time the plaintext is known.
+func (p *Password) NeedsRehash() bool {
+	return needsRehash(ActivePasswordHashing(), p.hash)
 }
 
 func (u *User) IsAnonymous() bool {
 	return u == AnonymousUser // Return true if the user is the anonymous user
 }
 
-// ValidatePassword checks the strength of a plaintext password.
-func ValidatePasswordPlaintext(v *validator.Validator, password string) {
-	v.Check(password != "", "password", "must be provided")                                                              // Check if password is not empty
-	v.Check(len(password) >= 8, "password", "must be at least 8 characters long")                                        // Check if password length is at least 8 characters
-	v.Check(len(password) <= 72, "password", "must not be more than 72 characters long")                                 // Check if password length is within limit
-	v.Check(v.Matches(password, validator.PasswordNumberRX), "password", "must contain at least one number")             // Check if password contains at least one number
-	v.Check(v.Matches(password, validator.PasswordUpperRX), "password", "must contain at least one uppercase letter")    // Check if password contains at least one uppercase letter
-	v.Check(v.Matches(password, validator.PasswordLowerRX), "password", "must contain at least one lowercase letter")    // Check if password contains at least one lowercase letter
-	v.Check(v.Matches(password, validator.PasswordSpecialRX), "password", "must contain at least one special character") // Check if password contains at least one special character
+// IsImpersonated reports whether the request is made by an admin acting as the user.
+func (u *User) IsImpersonated() bool {
+	return u.ImpersonatedBy != nil
+}
+
+// SessionTouchDue reports whether the session making the request should be recorded as used at now.
+// Sessions used within the resolution shown in the session list are not written again.
+func (u *User) SessionTouchDue(now time.Time) bool {
+	return u.SessionUsedAt.IsZero() || now.Sub(u.SessionUsedAt.Time) >= sessionLastUsedResolution
+}
+
+// Location returns the user's preferred timezone, falling back to UTC.
+// Timestamps are stored and returned in UTC; this is only for presentation endpoints.
+func (u *User) Location() *time.Location {
+	if u.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ValidateTimezone checks that the timezone is a known IANA zone name.
+func ValidateTimezone(v *validator.Validator, timezone string) {
+	v.Check(timezone != "", "timezone", "must be provided")
+	v.Check(len(timezone) <= 64, "timezone", "must not be more than 64 characters long")
+	_, err := time.LoadLocation(timezone)
+	v.Check(err == nil && timezone != "Local", "timezone", "must be a valid IANA timezone")
+}
+
+// DefaultLocale is the language used when a user has not chosen one, and the fallback for
+// emails without a translation.
+const DefaultLocale = "en"
+
+// SupportedLocales lists the languages users can choose for their emails.
+var SupportedLocales = []string{DefaultLocale, "es"}
+
+// ValidateLocale checks that the locale is one of the supported languages.
+func ValidateLocale(v *validator.Validator, locale string) {
+	v.Check(locale != "", "locale", "must be provided")
+	v.Check(v.Permitted(locale, SupportedLocales...), "locale", "must be one of "+strings.Join(SupportedLocales, ", "))
 }
 
 // ValidateEmail checks if the email is in a valid format.
@@ -117,8 +189,16 @@ func ValidateUser(v *validator.Validator, user *User) {
 		ValidatePasswordPlaintext(v, *user.Password.plaintext)
 	}
 
-	allowedRoles := []string{"admin", "cashier", "guest"}
-	v.Check(v.Permitted(user.Role, allowedRoles...), "role", "must be one of the permitted values")
+	// Roles are stored in the database, so handlers confirm the role exists
+	v.Check(user.Role != "", "role", "must be provided")
+	v.Check(v.Matches(user.Role, RoleNameRX), "role", "must be one of the permitted values")
+
+	if user.Timezone != "" {
+		ValidateTimezone(v, user.Timezone)
+	}
+	if user.Locale != "" {
+		ValidateLocale(v, user.Locale)
+	}
 }
 
 // ----------------------------------------------------------------------
@@ -129,16 +209,22 @@ func ValidateUser(v *validator.Validator, user *User) {
 // Insert adds a new user to the database.
 func (m *UserModel) Insert(user *User) error {
 	query := `
-		INSERT INTO users (first_name, last_name, email, password_hash, role, is_active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		INSERT INTO users (first_name, last_name, email, password_hash, role, is_active, timezone, locale, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
 		RETURNING id, created_at, updated_at, version
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
 	defer cancel()
 
 	if user.Role == "" {
-		user.Role = "guest"
+		user.Role = DefaultRole
+	}
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+	if user.Locale == "" {
+		user.Locale = DefaultLocale
 	}
 
 	user.IsActive = false
@@ -150,6 +236,8 @@ func (m *UserModel) Insert(user *User) error {
 		user.Password.hash,
 		user.Role,
 		user.IsActive,
+		user.Timezone,
+		user.Locale,
 	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Version)
 	if err != nil {
 		// Handle PostgreSQL constraint violations
@@ -163,32 +251,61 @@ func (m *UserModel) Insert(user *User) error {
 				return ErrInvalidData
 			case "23502": // not_null_violation
 				return ErrInvalidData
+			case "23503": // foreign_key_violation
+				return ErrInvalidRole
 			}
 		}
-		return err
+		return wrapError("user", "Insert", err)
 	}
 	return nil
 }
 
-// Update modifies an existing user in the database.
+// Update modifies an existing user in the database. Saving an inactive user ends their sessions
+// in the same statement, so a deactivated account cannot keep using an earlier token.
+// It returns ErrLastAdmin if the change would leave no active admin.
 func (m *UserModel) Update(user *User) error {
 	query := `
-		UPDATE users
-		SET first_name = $1, last_name = $2, email = $3, password_hash = $4, role = $5, is_active = $6, updated_at = NOW(), version = version + 1
-		WHERE id = $7 AND version = $8
-		RETURNING updated_at, version
+		WITH updated AS (
+			UPDATE users
+			SET first_name = $1, last_name = $2, email = $3, password_hash = $4, role = $5, is_active = $6, timezone = $7, locale = $8, updated_at = NOW(), version = version + 1
+			WHERE id = $9 AND version = $10 AND deleted_at IS NULL
+			RETURNING id, is_active, updated_at, version
+		), revoked AS (
+			DELETE FROM tokens
+			WHERE scope = 'authentication' AND user_id IN (SELECT id FROM updated WHERE NOT is_active)
+		)
+		SELECT updated_at, version FROM updated
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query,
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapError("user", "Update", err)
+	}
+	defer tx.Rollback()
+
+	// Demoting or deactivating the last active admin would lock everyone out
+	if user.Role != AdminRole || !user.IsActive {
+		last, err := lockLastActiveAdmin(ctx, tx, user.ID)
+		if err != nil {
+			return wrapError("user", "Update", err)
+		}
+		if last {
+			return ErrLastAdmin
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, query,
 		user.FirstName,
 		user.LastName,
 		user.Email,
 		user.Password.hash,
 		user.Role,
 		user.IsActive,
+		user.Timezone,
+		user.Locale,
 		user.ID,
 		user.Version,
 	).Scan(&user.UpdatedAt, &user.Version)
@@ -204,32 +321,173 @@ func (m *UserModel) Update(user *User) error {
 					if strings.Contains(pqError.Detail, "email") {
 						return ErrDuplicateEmail
 					}
+				case "23503": // foreign_key_violation
+					return ErrInvalidRole
 				}
 			}
-			return err
+			return wrapError("user", "Update", err)
 		}
 	}
-	return nil
+	return wrapError("user", "Update", tx.Commit())
 }
 
-// Delete removes a user from the database.
+// Delete soft deletes a user and ends their sessions. The row is kept so sales
+// and other records that reference the user stay valid. It returns ErrLastAdmin
+// if the user is the last active admin.
 func (m *UserModel) Delete(id int64) error {
 	query := `
-		DELETE FROM users
+		UPDATE users
+		SET deleted_at = NOW(), updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+	tokensQuery := `
+		DELETE FROM tokens
+		WHERE user_id = $1
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapError("user", "Delete", err)
+	}
+	defer tx.Rollback()
+
+	last, err := lockLastActiveAdmin(ctx, tx, id)
+	if err != nil {
+		return wrapError("user", "Delete", err)
+	}
+	if last {
+		return ErrLastAdmin
+	}
+
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return wrapError("user", "Delete", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return wrapError("user", "Delete", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, tokensQuery, id); err != nil {
+		return wrapError("user", "Delete", err)
+	}
+
+	return wrapError("user", "Delete", tx.Commit())
+}
+
+// Deactivate marks a user inactive, ends their sessions and, in the same transaction, optionally
+// moves their sales to another user or scrubs their personal details. It returns the number of
+// sales reassigned, ErrRecordNotFound if the user does not exist or is deleted, or ErrLastAdmin
+// if the user is the last active admin.
+func (m *UserModel) Deactivate(id int64, opts DeactivateOptions) (int64, error) {
+	query := `
+		UPDATE users
+		SET is_active = FALSE, updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+	tokensQuery := `
+		DELETE FROM tokens
+		WHERE user_id = $1
+	`
+	reassignQuery := `
+		UPDATE sales
+		SET user_id = $2, updated_at = NOW()
+		WHERE user_id = $1
+	`
+	// The row stays for the sales that reference it, but no longer says who it was
+	anonymizeQuery := `
+		UPDATE users
+		SET first_name = 'Former', last_name = 'User', email = 'user-' || id || '@anonymized.invalid',
+		    pending_email = NULL, totp_secret = NULL, totp_enabled = FALSE
 		WHERE id = $1
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, wrapError("user", "Deactivate", err)
+	}
+	defer tx.Rollback()
+
+	last, err := lockLastActiveAdmin(ctx, tx, id)
+	if err != nil {
+		return 0, wrapError("user", "Deactivate", err)
+	}
+	if last {
+		return 0, ErrLastAdmin
+	}
+
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return 0, wrapError("user", "Deactivate", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, wrapError("user", "Deactivate", err)
+	}
+
+	if rowsAffected == 0 {
+		return 0, ErrRecordNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, tokensQuery, id); err != nil {
+		return 0, wrapError("user", "Deactivate", err)
+	}
+
+	var reassigned int64
+	if opts.ReassignSalesTo != nil {
+		result, err := tx.ExecContext(ctx, reassignQuery, id, *opts.ReassignSalesTo)
+		if err != nil {
+			if pqError, ok := err.(*pq.Error); ok && pqError.Code == "23503" { // foreign_key_violation
+				return 0, ErrInvalidData
+			}
+			return 0, wrapError("user", "Deactivate", err)
+		}
+		if reassigned, err = result.RowsAffected(); err != nil {
+			return 0, wrapError("user", "Deactivate", err)
+		}
+	}
+
+	if opts.Anonymize {
+		if _, err := tx.ExecContext(ctx, anonymizeQuery, id); err != nil {
+			return 0, wrapError("user", "Deactivate", err)
+		}
+	}
+
+	return reassigned, wrapError("user", "Deactivate", tx.Commit())
+}
+
+// Restore brings back a soft deleted user. It returns ErrRecordNotFound if the user
+// does not exist or is not deleted.
+func (m *UserModel) Restore(id int64) error {
+	query := `
+		UPDATE users
+		SET deleted_at = NULL, updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
 	defer cancel()
 
 	result, err := m.DB.ExecContext(ctx, query, id)
 	if err != nil {
-		return err
+		return wrapError("user", "Restore", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return wrapError("user", "Restore", err)
 	}
 
 	if rowsAffected == 0 {
@@ -242,12 +500,13 @@ func (m *UserModel) Delete(id int64) error {
 // Get retrieves a user by its ID.
 func (m *UserModel) GetByID(id int64) (*User, error) {
 	query := `
-		SELECT id, first_name, last_name, email, password_hash, role, is_active, created_at, updated_at, version
+		SELECT id, first_name, last_name, email, password_hash, role, is_active, timezone, locale, deleted_at, created_at, updated_at, version, totp_secret, totp_enabled,
+		       EXISTS (SELECT 1 FROM email_suppressions s WHERE s.email = users.email) AS email_undeliverable
 		FROM users
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
 	defer cancel()
 
 	user := &User{}
@@ -260,15 +519,21 @@ func (m *UserModel) GetByID(id int64) (*User, error) {
 		&user.Password.hash,
 		&user.Role,
 		&user.IsActive,
+		&user.Timezone,
+		&user.Locale,
+		&user.DeletedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.Version,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.EmailUndeliverable,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrRecordNotFound
 		}
-		return nil, err
+		return nil, wrapError("user", "GetByID", err)
 	}
 
 	return user, nil
@@ -277,12 +542,13 @@ func (m *UserModel) GetByID(id int64) (*User, error) {
 // GetByEmail retrieves a user by its email.
 func (m *UserModel) GetByEmail(email string) (*User, error) {
 	query := `
-		SELECT id, first_name, last_name, email, password_hash, role, is_active, created_at, updated_at, version
+		SELECT id, first_name, last_name, email, password_hash, role, is_active, timezone, locale, deleted_at, created_at, updated_at, version, totp_secret, totp_enabled,
+		       EXISTS (SELECT 1 FROM email_suppressions s WHERE s.email = users.email) AS email_undeliverable
 		FROM users
-		WHERE email = $1
+		WHERE email = $1 AND deleted_at IS NULL
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
 	defer cancel()
 
 	user := &User{}
@@ -295,15 +561,21 @@ func (m *UserModel) GetByEmail(email string) (*User, error) {
 		&user.Password.hash,
 		&user.Role,
 		&user.IsActive,
+		&user.Timezone,
+		&user.Locale,
+		&user.DeletedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.Version,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.EmailUndeliverable,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrRecordNotFound
 		}
-		return nil, err
+		return nil, wrapError("user", "GetByEmail", err)
 	}
 
 	return user, nil
@@ -311,18 +583,25 @@ func (m *UserModel) GetByEmail(email string) (*User, error) {
 
 // GetAll retrieves a list of users based on the provided filter and pagination parameters.
 func (m *UserModel) GetAll(filter UserFilter) ([]*User, MetaData, error) {
+	sortColumn, err := filter.Filter.SortColumn()
+	if err != nil {
+		return nil, MetaData{}, wrapError("user", "GetAll", err)
+	}
+
 	query := fmt.Sprintf(`
-		SELECT COUNT(*) OVER(), id, first_name, last_name, email, password_hash, role, is_active, created_at, updated_at, version
+		SELECT COUNT(*) OVER(), id, first_name, last_name, email, password_hash, role, is_active, timezone, locale, deleted_at, created_at, updated_at, version, totp_secret, totp_enabled,
+		       EXISTS (SELECT 1 FROM email_suppressions s WHERE s.email = users.email) AS email_undeliverable
 		FROM users
 		WHERE (first_name ILIKE '%%' || $1 || '%%' OR last_name ILIKE '%%' || $1 || '%%')
 		  AND (email ILIKE '%%' || $2 || '%%')
 		  AND (role = COALESCE(NULLIF($3, ''), role))
 		  AND (is_active = COALESCE($4, is_active))
+		  AND (deleted_at IS NULL OR $5)
 		ORDER BY %s %s
-		LIMIT $5 OFFSET $6
-	`, filter.Filter.SortColumn(), filter.Filter.SortDirection())
+		LIMIT $6 OFFSET $7
+	`, sortColumn, filter.Filter.SortDirection())
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
 	defer cancel()
 
 	args := []interface{}{
@@ -330,13 +609,14 @@ func (m *UserModel) GetAll(filter UserFilter) ([]*User, MetaData, error) {
 		filter.Email,
 		filter.Role,
 		filter.IsActive,
+		filter.IncludeDeleted,
 		filter.Filter.Limit(),
 		filter.Filter.Offset(),
 	}
 
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, MetaData{}, err
+		return nil, MetaData{}, wrapError("user", "GetAll", err)
 	}
 	defer rows.Close()
 
@@ -354,22 +634,27 @@ func (m *UserModel) GetAll(filter UserFilter) ([]*User, MetaData, error) {
 			&user.Password.hash,
 			&user.Role,
 			&user.IsActive,
+			&user.Timezone,
+			&user.Locale,
+			&user.DeletedAt,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 			&user.Version,
+			&user.TOTPSecret,
+			&user.TOTPEnabled,
+			&user.EmailUndeliverable,
 		)
 		if err != nil {
-			return nil, MetaData{}, err
+			return nil, MetaData{}, wrapError("user", "GetAll", err)
 		}
 		users = append(users, user)
-		totalRecords++
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, MetaData{}, err
+		return nil, MetaData{}, wrapError("user", "GetAll", err)
 	}
 
-	meta := CalculateMetaData(totalRecords, filter.Filter.Page, filter.Filter.PageSize)
+	meta := CalculateMetaData(totalRecords, filter.Filter)
 
 	return users, meta, nil
 }
@@ -377,23 +662,26 @@ func (m *UserModel) GetAll(filter UserFilter) ([]*User, MetaData, error) {
 // GetForTokens retrieves a user based on a token scope and plaintext token.
 func (m *UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
 	query := `
-		SELECT users.id, users.first_name, users.last_name, users.email, users.password_hash, users.role, users.is_active, users.created_at, users.updated_at, users.version
+		SELECT users.id, users.first_name, users.last_name, users.email, users.password_hash, users.role, users.is_active, users.timezone, users.locale, users.deleted_at, users.created_at, users.updated_at, users.version, users.totp_secret, users.totp_enabled,
+		       EXISTS (SELECT 1 FROM email_suppressions s WHERE s.email = users.email) AS email_undeliverable,
+		       tokens.impersonator_id, tokens.created_at, tokens.last_used_at
 		FROM users
 		INNER JOIN tokens
 		ON users.id = tokens.user_id
 		WHERE tokens.scope = $1
 		AND tokens.hash = $2
 		AND tokens.expires_at > $3
+		AND users.deleted_at IS NULL
 	`
 
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := m.Timeouts.queryContext(ReadQuery)
 	defer cancel()
 
 	user := &User{}
 
-	err := m.DB.QueryRowContext(ctx, query, tokenScope, tokenHash[:], time.Now()).Scan(
+	err := m.DB.QueryRowContext(ctx, query, tokenScope, tokenHash[:], clockNow(m.Clock)).Scan(
 		&user.ID,
 		&user.FirstName,
 		&user.LastName,
@@ -401,16 +689,193 @@ func (m *UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error
 		&user.Password.hash,
 		&user.Role,
 		&user.IsActive,
+		&user.Timezone,
+		&user.Locale,
+		&user.DeletedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.Version,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.EmailUndeliverable,
+		&user.ImpersonatedBy,
+		&user.LoggedInAt,
+		&user.SessionUsedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrRecordNotFound
 		}
-		return nil, err
+		return nil, wrapError("user", "GetForToken", err)
 	}
 
 	return user, nil
 }
+
+// lockLastActiveAdmin locks every active admin row until tx ends and reports whether the user is
+// the only one. Concurrent demotions queue on the locks, so the second one sees the first's result
+// and cannot leave the system without an admin.
+func lockLastActiveAdmin(ctx context.Context, tx *sql.Tx, id int64) (bool, error) {
+	query := `
+		SELECT id
+		FROM users
+		WHERE role = $1 AND is_active AND deleted_at IS NULL
+		FOR UPDATE
+	`
+
+	rows, err := tx.QueryContext(ctx, query, AdminRole)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var admins []int64
+	for rows.Next() {
+		var adminID int64
+		if err := rows.Scan(&adminID); err != nil {
+			return false, err
+		}
+		admins = append(admins, adminID)
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	return len(admins) == 1 && admins[0] == id, nil
+}
+
+// RehashPassword replaces the stored hash with one from the active hashing configuration.
+// It only succeeds while the old hash is still in place, so a concurrent password change wins,
+// and it leaves the version alone because nothing the user sees changes.
+func (m *UserModel) RehashPassword(user *User, plaintextPassword string) error {
+	oldHash := user.Password.hash
+	if err := user.Password.Set(plaintextPassword); err != nil {
+		return wrapError("user", "RehashPassword", err)
+	}
+
+	query := `
+		UPDATE users
+		SET password_hash = $1
+		WHERE id = $2 AND password_hash = $3 AND deleted_at IS NULL
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, user.Password.hash, user.ID, oldHash)
+	return wrapError("user", "RehashPassword", err)
+}
+
+// SetPendingEmail records an email address the user has asked to switch to.
+// The address is only applied once ConfirmEmailChange is called.
+func (m *UserModel) SetPendingEmail(userID int64, email string) error {
+	query := `
+		UPDATE users
+		SET pending_email = $1, updated_at = NOW(), version = version + 1
+		WHERE id = $2 AND deleted_at IS NULL
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, email, userID)
+	if err != nil {
+		return wrapError("user", "SetPendingEmail", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return wrapError("user", "SetPendingEmail", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange replaces the user's email with their pending email and updates the user in place.
+// It returns ErrRecordNotFound if there is no pending change, and ErrDuplicateEmail if the
+// address was taken after the change was requested.
+func (m *UserModel) ConfirmEmailChange(user *User) error {
+	query := `
+		UPDATE users
+		SET email = pending_email, pending_email = NULL, updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND pending_email IS NOT NULL AND deleted_at IS NULL
+		RETURNING email, updated_at, version
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, user.ID).Scan(&user.Email, &user.UpdatedAt, &user.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			if pqError, ok := err.(*pq.Error); ok && pqError.Code == "23505" { // unique_violation
+				return ErrDuplicateEmail
+			}
+			return wrapError("user", "ConfirmEmailChange", err)
+		}
+	}
+
+	return nil
+}
+
+// SetTOTPSecret stores a new encrypted TOTP secret for a user, leaving 2FA disabled until it is verified.
+func (m *UserModel) SetTOTPSecret(userID int64, encryptedSecret []byte) error {
+	query := `
+		UPDATE users
+		SET totp_secret = $1, totp_enabled = FALSE, updated_at = NOW(), version = version + 1
+		WHERE id = $2 AND totp_enabled = FALSE
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, encryptedSecret, userID)
+	if err != nil {
+		return wrapError("user", "SetTOTPSecret", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return wrapError("user", "SetTOTPSecret", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	return nil
+}
+
+// EnableTOTP turns on two-factor authentication for a user with a pending secret.
+func (m *UserModel) EnableTOTP(userID int64) error {
+	query := `
+		UPDATE users
+		SET totp_enabled = TRUE, updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND totp_secret IS NOT NULL
+	`
+
+	ctx, cancel := m.Timeouts.queryContext(WriteQuery)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return wrapError("user", "EnableTOTP", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return wrapError("user", "EnableTOTP", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
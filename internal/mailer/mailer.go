@@ -3,7 +3,10 @@ package mailer
 import (
 	"bytes"
 	"embed"
-	"html/template"
+	"io"
+	"log/slog"
+	netmail "net/mail"
+	"strings"
 	"time"
 
 	"github.com/go-mail/mail"
@@ -16,6 +19,7 @@ var templatesFS embed.FS
 type Mailer struct {
 	dialer *mail.Dialer
 	sender string
+	logger *slog.Logger // set by NewLogger, which logs emails instead of sending them
 }
 
 // New creates a new Mailer instance.
@@ -28,29 +32,63 @@ func New(host string, port int, username, password, sender string) *Mailer {
 	}
 }
 
-// Send sends an email using the mailer service.
-func (m *Mailer) Send(to, templateName string, data any) error {
-	tmpl, err := template.ParseFS(templatesFS, "templates/"+templateName)
-	if err != nil {
-		return err
+// NewLogger creates a Mailer that renders every email as New does but writes it to the logger
+// instead of sending it, for sandboxes with no mail server.
+func NewLogger(sender string, logger *slog.Logger) *Mailer {
+	return &Mailer{
+		sender: sender,
+		logger: logger,
 	}
+}
 
-	subject := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(subject, "subject", data)
+// Domain returns the domain part of the sender address, used for Message-IDs.
+func (m *Mailer) Domain() string {
+	address, err := netmail.ParseAddress(m.sender)
 	if err != nil {
+		return "localhost"
+	}
+	_, domain, found := strings.Cut(address.Address, "@")
+	if !found || domain == "" {
+		return "localhost"
+	}
+	return domain
+}
+
+// Attachment is a file sent along with an email, such as the PDF of a receipt. Its content
+// type is taken from the extension of Filename.
+type Attachment struct {
+	Filename string
+	Data     []byte
+}
+
+// Send sends an email in the recipient's locale using the mailer service.
+func (m *Mailer) Send(to, locale string, data Message) error {
+	return m.SendMessage("", to, locale, data)
+}
+
+// SendMessage sends an email with the given Message-ID header, so provider callbacks about
+// its delivery can be matched back to it. An empty ID lets the mail library generate one.
+// The template is rendered in the locale, falling back to DefaultLocale without a translation.
+// Attachments are added after the body.
+func (m *Mailer) SendMessage(messageID, to, locale string, data Message, attachments ...Attachment) error {
+	if err := checkRequired(data); err != nil {
 		return err
 	}
 
-	plainBody := new(bytes.Buffer)                           // buffer to hold the plain text body
-	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data) // execute the plain body template
-	if err != nil {
-		return err // return error if plain body template execution fails
+	subject := new(bytes.Buffer)   // buffer to hold the subject
+	plainBody := new(bytes.Buffer) // buffer to hold the plain text body
+	htmlBody := new(bytes.Buffer)  // buffer to hold the HTML body
+	if err := render(data, locale, subject, plainBody, htmlBody); err != nil {
+		return err
 	}
 
-	htmlBody := new(bytes.Buffer)                          // buffer to hold the HTML body
-	err = tmpl.ExecuteTemplate(htmlBody, "htmlBody", data) // execute the HTML body template
-	if err != nil {
-		return err // return error if HTML body template execution fails
+	if m.logger != nil {
+		filenames := make([]string, 0, len(attachments))
+		for _, attachment := range attachments {
+			filenames = append(filenames, attachment.Filename)
+		}
+		m.logger.Info("email not sent", "from", m.sender, "to", to, "subject", subject.String(), "message_id", messageID, "attachments", filenames, "body", plainBody.String())
+		return nil
 	}
 
 	// Create a new email message
@@ -58,10 +96,21 @@ func (m *Mailer) Send(to, templateName string, data any) error {
 	msg.SetHeader("From", m.sender)
 	msg.SetHeader("To", to)
 	msg.SetHeader("Subject", subject.String())
+	if messageID != "" {
+		msg.SetHeader("Message-ID", messageID)
+	}
 	msg.SetBody("text/plain", plainBody.String())
 	msg.AddAlternative("text/html", htmlBody.String())
+	for _, attachment := range attachments {
+		// Copied from the bytes on every attempt, where a reader would be empty on a retry
+		msg.Attach(attachment.Filename, mail.SetCopyFunc(func(w io.Writer) error {
+			_, err := w.Write(attachment.Data)
+			return err
+		}))
+	}
 
 	// 3 times retry logic
+	var err error
 	for i := 0; i < 3; i++ {
 		err = m.dialer.DialAndSend(msg)
 		if err == nil {
@@ -0,0 +1,243 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"reflect"
+	"strings"
+)
+
+// Message is the typed data for one email template. Every exported field is a required
+// template variable, so a message with an empty field is rejected before it is rendered.
+type Message interface {
+	Template() string
+}
+
+// WelcomeEmail is sent after registration with the account activation token.
+type WelcomeEmail struct {
+	UserID          int64
+	Email           string
+	ActivationToken string
+	ExpiresInHours  int64 // how long the activation token stays valid
+}
+
+// InvitationEmail is sent to a person invited to create their own account.
+type InvitationEmail struct {
+	Email           string
+	Role            string
+	InviterName     string
+	InvitationToken string
+}
+
+// EmailChangeEmail is sent to the new address to confirm an email change.
+type EmailChangeEmail struct {
+	FirstName    string
+	CurrentEmail string
+	NewEmail     string
+	Token        string
+}
+
+// PasswordResetEmail is sent with a one-time token to set a new password.
+type PasswordResetEmail struct {
+	FirstName          string
+	Email              string
+	PasswordResetToken string
+}
+
+// StaffAccountEmail is sent to an account created by an admin, with a token to choose a password.
+type StaffAccountEmail struct {
+	FirstName          string
+	Email              string
+	Role               string
+	CreatorName        string
+	PasswordResetToken string
+}
+
+// LowStockEmail is sent to the stock alert recipients when products fall below their reorder threshold.
+type LowStockEmail struct {
+	Products []LowStockItem
+}
+
+// LowStockItem is one product listed in a LowStockEmail.
+type LowStockItem struct {
+	ID               int64
+	Name             string
+	StockQuantity    int64
+	ReorderThreshold int64
+}
+
+// ReceiptEmail is an itemized receipt sent to a customer who opted in to email receipts. Amounts
+// are formatted to the cent; Discount and Tax are "0.00" when there is none.
+type ReceiptEmail struct {
+	SaleID   int64
+	SoldAt   string
+	Items    []ReceiptItem
+	Subtotal string
+	Discount string
+	Tax      string
+	Total    string
+	Currency string
+}
+
+// ReceiptItem is one line of a ReceiptEmail.
+type ReceiptItem struct {
+	Name      string
+	Quantity  int64
+	UnitPrice string
+	LineTotal string
+}
+
+// SessionsRevokedEmail tells a user that an administrator signed them out of every session after
+// a suspected breach.
+type SessionsRevokedEmail struct {
+	FirstName string
+	Email     string
+}
+
+func (WelcomeEmail) Template() string       { return "user_welcome.tmpl" }
+func (InvitationEmail) Template() string    { return "user_invitation.tmpl" }
+func (EmailChangeEmail) Template() string   { return "email_change.tmpl" }
+func (PasswordResetEmail) Template() string { return "password_reset.tmpl" }
+func (StaffAccountEmail) Template() string  { return "staff_account.tmpl" }
+func (LowStockEmail) Template() string      { return "low_stock.tmpl" }
+func (ReceiptEmail) Template() string       { return "receipt.tmpl" }
+
+func (SessionsRevokedEmail) Template() string { return "sessions_revoked.tmpl" }
+
+// registry lists every message type. Each template file must belong to exactly one of them.
+var registry = []Message{
+	WelcomeEmail{},
+	InvitationEmail{},
+	EmailChangeEmail{},
+	PasswordResetEmail{},
+	StaffAccountEmail{},
+	LowStockEmail{},
+	ReceiptEmail{},
+	SessionsRevokedEmail{},
+}
+
+// DefaultLocale is the language of the templates at the top of the templates directory.
+// Translations live in a subdirectory per locale, e.g. templates/es/user_welcome.tmpl, and a
+// template without a translation is sent in the default language.
+const DefaultLocale = "en"
+
+// templateParts are the blocks every template must define.
+var templateParts = []string{"subject", "plainBody", "htmlBody"}
+
+// ErrMissingTemplateData is returned when a message leaves a required variable empty.
+var ErrMissingTemplateData = errors.New("missing template data")
+
+// ValidateTemplates renders every registered template with sample data in every locale, so a
+// template that references an unknown variable or lacks a block fails at startup instead of on
+// first send.
+func ValidateTemplates() error {
+	registered := make(map[string]bool, len(registry))
+	for _, msg := range registry {
+		if registered[msg.Template()] {
+			return fmt.Errorf("template %s is registered twice", msg.Template())
+		}
+		registered[msg.Template()] = true
+	}
+
+	for _, locale := range Locales() {
+		for _, msg := range registry {
+			if err := render(sampleMessage(msg), locale, io.Discard, io.Discard, io.Discard); err != nil {
+				return err
+			}
+		}
+
+		dir := "templates"
+		if locale != DefaultLocale {
+			dir += "/" + locale
+		}
+		files, err := fs.Glob(templatesFS, dir+"/*.tmpl")
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if name := strings.TrimPrefix(file, dir+"/"); !registered[name] {
+				return fmt.Errorf("template %s has no registered message type", file)
+			}
+		}
+	}
+	return nil
+}
+
+// Locales returns the default locale followed by every locale with translated templates.
+func Locales() []string {
+	locales := []string{DefaultLocale}
+	entries, _ := fs.ReadDir(templatesFS, "templates")
+	for _, entry := range entries {
+		if entry.IsDir() {
+			locales = append(locales, entry.Name())
+		}
+	}
+	return locales
+}
+
+// templateFile returns the path of the template in the locale, or of the default template when
+// it has not been translated.
+func templateFile(msg Message, locale string) string {
+	if locale != "" && locale != DefaultLocale {
+		file := "templates/" + locale + "/" + msg.Template()
+		if _, err := fs.Stat(templatesFS, file); err == nil {
+			return file
+		}
+	}
+	return "templates/" + msg.Template()
+}
+
+// checkRequired reports the first empty field of a message.
+func checkRequired(msg Message) error {
+	value := reflect.ValueOf(msg)
+	for i := 0; i < value.NumField(); i++ {
+		if value.Field(i).IsZero() {
+			return fmt.Errorf("%w: %s needs %s", ErrMissingTemplateData, msg.Template(), value.Type().Field(i).Name)
+		}
+	}
+	return nil
+}
+
+// sampleMessage returns a copy of the message with every field filled in.
+func sampleMessage(msg Message) Message {
+	value := reflect.New(reflect.TypeOf(msg)).Elem()
+	fillSample(value)
+	return value.Interface().(Message)
+}
+
+// fillSample sets every field of a struct to a sample value. Slices get one filled in element,
+// so the fields used inside a range are checked too.
+func fillSample(value reflect.Value) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString("sample")
+		case reflect.Int, reflect.Int64:
+			field.SetInt(1)
+		case reflect.Slice:
+			field.Set(reflect.MakeSlice(field.Type(), 1, 1))
+			if elem := field.Index(0); elem.Kind() == reflect.Struct {
+				fillSample(elem)
+			}
+		}
+	}
+}
+
+// render executes the three blocks of the message's template in the locale.
+func render(msg Message, locale string, subject, plainBody, htmlBody io.Writer) error {
+	file := templateFile(msg, locale)
+	tmpl, err := template.New("").Option("missingkey=error").ParseFS(templatesFS, file)
+	if err != nil {
+		return err
+	}
+	for i, w := range []io.Writer{subject, plainBody, htmlBody} {
+		if err := tmpl.ExecuteTemplate(w, templateParts[i], msg); err != nil {
+			return fmt.Errorf("template %s: %w", file, err)
+		}
+	}
+	return nil
+}
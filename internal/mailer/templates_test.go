@@ -0,0 +1,160 @@
+// File: internal/mailer/templates_test.go
+// Description: test suite for the email template registry
+
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestValidateTemplates renders every registered template, catching unknown variables
+func TestValidateTemplates(t *testing.T) {
+	if err := ValidateTemplates(); err != nil {
+		t.Fatalf("expected templates to be valid, got %v", err)
+	}
+}
+
+// TestCheckRequired checks that empty variables are rejected before rendering
+func TestCheckRequired(t *testing.T) {
+	tests := []struct {
+		name         string
+		msg          Message
+		missingField string
+	}{
+		{
+			name: "Complete Welcome",
+			msg:  WelcomeEmail{UserID: 7, Email: "user@example.com", ActivationToken: "token", ExpiresInHours: 72},
+		},
+		{
+			name:         "Welcome Without Token",
+			msg:          WelcomeEmail{UserID: 7, Email: "user@example.com", ExpiresInHours: 72},
+			missingField: "ActivationToken",
+		},
+		{
+			name:         "Password Reset Without Name",
+			msg:          PasswordResetEmail{Email: "user@example.com", PasswordResetToken: "token"},
+			missingField: "FirstName",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRequired(tt.msg)
+			switch {
+			case tt.missingField == "" && err != nil:
+				t.Errorf("expected no error, got %v", err)
+			case tt.missingField != "" && !errors.Is(err, ErrMissingTemplateData):
+				t.Errorf("expected ErrMissingTemplateData, got %v", err)
+			case tt.missingField != "" && !strings.Contains(err.Error(), tt.missingField):
+				t.Errorf("expected the error to name %s, got %v", tt.missingField, err)
+			}
+		})
+	}
+}
+
+// TestRenderUsesMessageFields checks that typed fields reach the rendered body
+func TestRenderUsesMessageFields(t *testing.T) {
+	msg := EmailChangeEmail{FirstName: "Ana", CurrentEmail: "old@example.com", NewEmail: "new@example.com", Token: "abc123"}
+
+	var subject, plainBody, htmlBody bytes.Buffer
+	if err := render(msg, DefaultLocale, &subject, &plainBody, &htmlBody); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{"Ana", "old@example.com", "new@example.com", "abc123"} {
+		if !strings.Contains(plainBody.String(), want) || !strings.Contains(htmlBody.String(), want) {
+			t.Errorf("expected both bodies to contain %q", want)
+		}
+	}
+}
+
+// TestRenderLowStock checks that every listed product reaches the rendered bodies
+func TestRenderLowStock(t *testing.T) {
+	msg := LowStockEmail{Products: []LowStockItem{
+		{ID: 4, Name: "Blue Pen", StockQuantity: 2, ReorderThreshold: 10},
+		{ID: 9, Name: "Stapler", StockQuantity: 0, ReorderThreshold: 3},
+	}}
+
+	var subject, plainBody, htmlBody bytes.Buffer
+	if err := render(msg, DefaultLocale, &subject, &plainBody, &htmlBody); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(subject.String(), "2 product(s)") {
+		t.Errorf("expected the subject to count the products, got %q", subject.String())
+	}
+	for _, want := range []string{"Blue Pen (#4)", "Stapler (#9)"} {
+		if !strings.Contains(plainBody.String(), want) || !strings.Contains(htmlBody.String(), want) {
+			t.Errorf("expected both bodies to contain %q", want)
+		}
+	}
+}
+
+// TestRenderReceipt checks that receipts list every item and only show a discount or tax when there is one
+func TestRenderReceipt(t *testing.T) {
+	msg := ReceiptEmail{
+		SaleID:   42,
+		SoldAt:   "2 Jan 2026 15:04 UTC",
+		Items:    []ReceiptItem{{Name: "Blue Pen", Quantity: 3, UnitPrice: "1.50", LineTotal: "4.50"}},
+		Subtotal: "4.50",
+		Discount: "0.00",
+		Tax:      "0.00",
+		Total:    "4.50",
+		Currency: "USD",
+	}
+
+	var subject, plainBody, htmlBody bytes.Buffer
+	if err := render(msg, DefaultLocale, &subject, &plainBody, &htmlBody); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(subject.String(), "#42") {
+		t.Errorf("expected the subject to name the sale, got %q", subject.String())
+	}
+	if !strings.Contains(plainBody.String(), "Blue Pen: 3 x 1.50 = 4.50") || !strings.Contains(htmlBody.String(), "Blue Pen") {
+		t.Errorf("expected both bodies to list the item")
+	}
+	if strings.Contains(plainBody.String(), "Discount") || strings.Contains(htmlBody.String(), "Tax") {
+		t.Errorf("expected no discount or tax line without them")
+	}
+
+	msg.Discount, msg.Tax, msg.Total = "0.45", "0.51", "4.56"
+	plainBody.Reset()
+	if err := render(msg, DefaultLocale, &subject, &plainBody, &htmlBody); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(plainBody.String(), "Discount: -0.45 USD") || !strings.Contains(plainBody.String(), "Tax: 0.51 USD") {
+		t.Errorf("expected discount and tax lines, got %q", plainBody.String())
+	}
+}
+
+// TestRenderLocale checks that translations are picked by locale and missing ones fall back to English
+func TestRenderLocale(t *testing.T) {
+	msg := PasswordResetEmail{FirstName: "Ana", Email: "ana@example.com", PasswordResetToken: "abc123"}
+
+	tests := []struct {
+		locale   string
+		greeting string
+	}{
+		{locale: "", greeting: "Hi Ana"},
+		{locale: "en", greeting: "Hi Ana"},
+		{locale: "es", greeting: "Hola Ana"},
+		{locale: "fr", greeting: "Hi Ana"},
+	}
+
+	for _, tt := range tests {
+		var subject, plainBody, htmlBody bytes.Buffer
+		if err := render(msg, tt.locale, &subject, &plainBody, &htmlBody); err != nil {
+			t.Fatalf("locale %q: unexpected error: %v", tt.locale, err)
+		}
+		if !strings.Contains(plainBody.String(), tt.greeting) || !strings.Contains(htmlBody.String(), tt.greeting) {
+			t.Errorf("locale %q: expected both bodies to greet with %q", tt.locale, tt.greeting)
+		}
+	}
+
+	if locales := Locales(); len(locales) < 2 || locales[0] != DefaultLocale {
+		t.Errorf("expected the default locale followed by translations, got %v", locales)
+	}
+}
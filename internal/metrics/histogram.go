@@ -0,0 +1,197 @@
+// File: internal/metrics/histogram.go
+// Description: fixed bucket latency histograms published through expvar and in Prometheus text format
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// DefaultBuckets are upper bounds in seconds suited to HTTP handlers and SQL queries.
+var DefaultBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Histogram counts observed durations into cumulative buckets, split by a single label such as
+// the route or query class. It implements expvar.Var so it can be published next to the old counters.
+type Histogram struct {
+	name    string
+	help    string
+	label   string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series holds the counts for one label value.
+type series struct {
+	counts []uint64 // per bucket, not cumulative; the last slot is +Inf
+	count  uint64
+	sum    float64
+}
+
+// Snapshot is the state of one label value, with quantiles estimated from the buckets.
+type Snapshot struct {
+	Count   uint64            `json:"count"`
+	Sum     float64           `json:"sum_seconds"`
+	P50     float64           `json:"p50_seconds"`
+	P95     float64           `json:"p95_seconds"`
+	P99     float64           `json:"p99_seconds"`
+	Buckets map[string]uint64 `json:"buckets"` // cumulative counts keyed by upper bound
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// NewHistogram creates a histogram. Buckets must be sorted ascending; nil uses DefaultBuckets.
+func NewHistogram(name, help, label string, buckets []float64) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return &Histogram{
+		name:    name,
+		help:    help,
+		label:   label,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+}
+
+// Observe records a duration for the label value. It is safe to call on a nil histogram.
+func (h *Histogram) Observe(labelValue string, d time.Duration) {
+	if h == nil {
+		return
+	}
+	seconds := d.Seconds()
+	i := sort.SearchFloat64s(h.buckets, seconds)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, found := h.series[labelValue]
+	if !found {
+		s = &series{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[labelValue] = s
+	}
+	s.counts[i]++
+	s.count++
+	s.sum += seconds
+}
+
+// Snapshot returns the state of every label value.
+func (h *Histogram) Snapshot() map[string]Snapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshots := make(map[string]Snapshot, len(h.series))
+	for labelValue, s := range h.series {
+		snapshot := Snapshot{
+			Count:   s.count,
+			Sum:     s.sum,
+			P50:     h.quantile(s, 0.50),
+			P95:     h.quantile(s, 0.95),
+			P99:     h.quantile(s, 0.99),
+			Buckets: make(map[string]uint64, len(s.counts)),
+		}
+		var cumulative uint64
+		for i, count := range s.counts {
+			cumulative += count
+			snapshot.Buckets[h.bound(i)] = cumulative
+		}
+		snapshots[labelValue] = snapshot
+	}
+	return snapshots
+}
+
+// String renders the snapshot as JSON for expvar.
+func (h *Histogram) String() string {
+	b, err := json.Marshal(h.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+// WritePrometheus writes the histogram in the Prometheus text exposition format.
+func (h *Histogram) WritePrometheus(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name); err != nil {
+		return err
+	}
+
+	labelValues := make([]string, 0, len(h.series))
+	for labelValue := range h.series {
+		labelValues = append(labelValues, labelValue)
+	}
+	sort.Strings(labelValues)
+
+	for _, labelValue := range labelValues {
+		s := h.series[labelValue]
+		label := fmt.Sprintf("%s=%q", h.label, labelValue)
+		var cumulative uint64
+		for i, count := range s.counts {
+			cumulative += count
+			if _, err := fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", h.name, label, h.bound(i), cumulative); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_sum{%s} %s\n%s_count{%s} %d\n", h.name, label, strconv.FormatFloat(s.sum, 'g', -1, 64), h.name, label, s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bound formats the upper bound of bucket i, "+Inf" for the overflow bucket.
+func (h *Histogram) bound(i int) string {
+	if i == len(h.buckets) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(h.buckets[i], 'g', -1, 64)
+}
+
+// quantile estimates the q-th quantile by linear interpolation inside the bucket that holds it.
+// Observations in the overflow bucket are reported at the largest finite bound.
+func (h *Histogram) quantile(s *series, q float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+	rank := q * float64(s.count)
+
+	var cumulative uint64
+	for i, count := range s.counts {
+		if count == 0 {
+			continue
+		}
+		if float64(cumulative+count) >= rank {
+			if i == len(h.buckets) {
+				return h.buckets[len(h.buckets)-1]
+			}
+			lower := 0.0
+			if i > 0 {
+				lower = h.buckets[i-1]
+			}
+			upper := h.buckets[i]
+			fraction := (rank - float64(cumulative)) / float64(count)
+			return lower + (upper-lower)*math.Max(0, fraction)
+		}
+		cumulative += count
+	}
+	return h.buckets[len(h.buckets)-1]
+}
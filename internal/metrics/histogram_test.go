@@ -0,0 +1,91 @@
+// File: internal/metrics/histogram_test.go
+// Description: test suite for latency histograms
+
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHistogramBuckets checks that observations land in cumulative buckets
+func TestHistogramBuckets(t *testing.T) {
+	h := NewHistogram("test_seconds", "Test latency.", "route", []float64{0.01, 0.1, 1})
+	h.Observe("GET 2xx", 5*time.Millisecond)
+	h.Observe("GET 2xx", 50*time.Millisecond)
+	h.Observe("GET 2xx", 500*time.Millisecond)
+	h.Observe("GET 2xx", 5*time.Second)
+
+	snapshot, found := h.Snapshot()["GET 2xx"]
+	if !found {
+		t.Fatal("expected a series for GET 2xx")
+	}
+	if snapshot.Count != 4 {
+		t.Errorf("expected count 4, got %d", snapshot.Count)
+	}
+	expected := map[string]uint64{"0.01": 1, "0.1": 2, "1": 3, "+Inf": 4}
+	for bound, count := range expected {
+		if snapshot.Buckets[bound] != count {
+			t.Errorf("bucket %s: expected %d, got %d", bound, count, snapshot.Buckets[bound])
+		}
+	}
+}
+
+// TestHistogramQuantiles checks interpolated quantiles
+func TestHistogramQuantiles(t *testing.T) {
+	h := NewHistogram("test_seconds", "Test latency.", "class", []float64{0.1, 0.2})
+	for i := 0; i < 100; i++ {
+		h.Observe("read", 150*time.Millisecond)
+	}
+
+	snapshot := h.Snapshot()["read"]
+	tests := []struct {
+		name     string
+		got      float64
+		expected float64
+	}{
+		{name: "p50", got: snapshot.P50, expected: 0.15},
+		{name: "p95", got: snapshot.P95, expected: 0.195},
+		{name: "p99", got: snapshot.P99, expected: 0.199},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if math.Abs(tt.got-tt.expected) > 1e-9 {
+				t.Errorf("expected %v, got %v", tt.expected, tt.got)
+			}
+		})
+	}
+}
+
+// TestHistogramPrometheus checks the text exposition output
+func TestHistogramPrometheus(t *testing.T) {
+	h := NewHistogram("db_query_duration_seconds", "Query latency.", "class", []float64{0.5})
+	h.Observe("write", 250*time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := h.WritePrometheus(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, line := range []string{
+		"# TYPE db_query_duration_seconds histogram",
+		`db_query_duration_seconds_bucket{class="write",le="0.5"} 1`,
+		`db_query_duration_seconds_bucket{class="write",le="+Inf"} 1`,
+		`db_query_duration_seconds_sum{class="write"} 0.25`,
+		`db_query_duration_seconds_count{class="write"} 1`,
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("expected output to contain %q, got:\n%s", line, buf.String())
+		}
+	}
+}
+
+// TestHistogramNil checks that a nil histogram ignores observations
+func TestHistogramNil(t *testing.T) {
+	var h *Histogram
+	h.Observe("GET 2xx", time.Second)
+}
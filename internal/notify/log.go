@@ -0,0 +1,27 @@
+// File: internal/notify/log.go
+// Description: an alert channel that writes alerts to the log instead of delivering them
+package notify
+
+import "log/slog"
+
+// Log writes alerts to a logger. It stands in for the real channels in sandboxes, where
+// nothing should leave the machine.
+type Log struct {
+	logger *slog.Logger
+}
+
+// NewLog returns a channel writing alerts to the logger.
+func NewLog(logger *slog.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+// Name identifies the channel in errors and logs.
+func (l *Log) Name() string {
+	return "log"
+}
+
+// Send logs the alert.
+func (l *Log) Send(alert Alert) error {
+	l.logger.Warn("alert not sent", "subject", alert.Subject, "body", alert.Body)
+	return nil
+}
@@ -0,0 +1,80 @@
+// File: internal/notify/notify.go
+// Description: delivery of critical operational alerts to administrators over pluggable channels
+package notify
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// Alert is a short operational message meant to reach an administrator quickly.
+type Alert struct {
+	Subject string
+	Body    string
+}
+
+// Channel delivers alerts over one medium, such as SMS.
+type Channel interface {
+	Name() string
+	Send(alert Alert) error
+}
+
+// Notifier fans an alert out to every configured channel. A nil Notifier sends nothing.
+type Notifier struct {
+	channels []Channel
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// New returns a Notifier over the given channels, skipping nil ones.
+func New(channels ...Channel) *Notifier {
+	n := &Notifier{}
+	for _, channel := range channels {
+		if channel != nil {
+			n.channels = append(n.channels, channel)
+		}
+	}
+	return n
+}
+
+// Enabled reports whether at least one channel is configured.
+func (n *Notifier) Enabled() bool {
+	return n != nil && len(n.channels) > 0
+}
+
+// Channels returns the names of the configured channels.
+func (n *Notifier) Channels() []string {
+	if n == nil {
+		return nil
+	}
+	names := make([]string, 0, len(n.channels))
+	for _, channel := range n.channels {
+		names = append(names, channel.Name())
+	}
+	return names
+}
+
+// Send delivers the alert on every channel. A failing channel does not stop the others,
+// and all failures are returned together.
+func (n *Notifier) Send(alert Alert) error {
+	if n == nil {
+		return nil
+	}
+	var errs []error
+	for _, channel := range n.channels {
+		if err := channel.Send(alert); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
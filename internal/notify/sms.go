@@ -0,0 +1,110 @@
+// File: internal/notify/sms.go
+// Description: SMS delivery through the Twilio Messages API
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+// twilioBaseURL is the Twilio REST API root, replaced in tests.
+const twilioBaseURL = "https://api.twilio.com/2010-04-01"
+
+// SMSMaxLength is the longest body Twilio accepts; longer alerts are cut short.
+const SMSMaxLength = 1600
+
+// SMS sends text messages through Twilio. As an alert channel it texts every recipient.
+type SMS struct {
+	client     *http.Client
+	baseURL    string
+	accountSID string
+	authToken  string
+	from       string
+	recipients []string // phone numbers in E.164 format that receive alerts
+}
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// NewSMS returns a Twilio sender using the account credentials and sending number.
+func NewSMS(accountSID, authToken, from string, recipients []string) *SMS {
+	return &SMS{
+		client:     &http.Client{Timeout: 10 * time.Second},
+		baseURL:    twilioBaseURL,
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		recipients: recipients,
+	}
+}
+
+// Name identifies the channel in errors and logs.
+func (s *SMS) Name() string {
+	return "sms"
+}
+
+// Send texts the alert to every recipient.
+func (s *SMS) Send(alert Alert) error {
+	if len(s.recipients) == 0 {
+		return errors.New("no recipients configured")
+	}
+
+	body := alert.Subject
+	if alert.Body != "" {
+		body += ": " + alert.Body
+	}
+
+	var errs []error
+	for _, to := range s.recipients {
+		if err := s.SendSMS(to, body); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SendSMS sends one text message to a phone number.
+func (s *SMS) SendSMS(to, body string) error {
+	if len(body) > SMSMaxLength {
+		body = body[:SMSMaxLength]
+	}
+
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", s.from)
+	form.Set("Body", body)
+
+	endpoint := fmt.Sprintf("%s/Accounts/%s/Messages.json", s.baseURL, url.PathEscape(s.accountSID))
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.accountSID, s.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("twilio returned %s for %s: %s", resp.Status, to, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// File: internal/notify/sms_test.go
+// Description: test suite for the Twilio SMS channel
+
+package notify
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestSMSSend checks the request sent to Twilio for every recipient
+func TestSMSSend(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/Accounts/AC123/Messages.json" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "AC123" || pass != "secret" {
+			t.Errorf("unexpected credentials %q %q", user, pass)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if r.PostForm.Get("From") != "+15550000000" {
+			t.Errorf("unexpected sender %q", r.PostForm.Get("From"))
+		}
+		if r.PostForm.Get("To") == "+15550000002" {
+			http.Error(w, `{"message": "unreachable"}`, http.StatusBadRequest)
+			return
+		}
+		bodies = append(bodies, r.PostForm.Get("Body"))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	sms := NewSMS("AC123", "secret", "+15550000000", []string{"+15550000001", "+15550000002"})
+	sms.baseURL = server.URL
+
+	err := New(sms, nil).Send(Alert{Subject: "Database unreachable", Body: "connection refused"})
+	if err == nil || !strings.Contains(err.Error(), "sms: twilio returned 400") {
+		t.Errorf("expected the failed recipient to be reported, got %v", err)
+	}
+	if len(bodies) != 1 || bodies[0] != "Database unreachable: connection refused" {
+		t.Errorf("unexpected messages %q", bodies)
+	}
+}
+
+// TestNotifierWithoutChannels checks that an unconfigured notifier is a no-op
+func TestNotifierWithoutChannels(t *testing.T) {
+	var n *Notifier
+	if n.Enabled() || n.Send(Alert{Subject: "test"}) != nil {
+		t.Error("expected a nil notifier to do nothing")
+	}
+	if New().Enabled() {
+		t.Error("expected a notifier without channels to be disabled")
+	}
+	if err := NewSMS("AC123", "secret", "+15550000000", nil).Send(Alert{}); err == nil {
+		t.Error("expected an error without recipients")
+	}
+}
@@ -0,0 +1,107 @@
+// File: internal/receipt/pdf.go
+// Description: a minimal PDF writer for pages of plain text
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Page layout of the PDF, in points. Text is 10 point Courier, 6 points a character, so a
+// receipt line of Width characters fits the page with room to spare.
+const (
+	pageWidth    = 420 // A5
+	pageHeight   = 595
+	pageMargin   = 40
+	fontSize     = 10
+	lineHeight   = 13
+	linesPerPage = (pageHeight - 2*pageMargin) / lineHeight
+	charsPerLine = (pageWidth - 2*pageMargin) / 6
+)
+
+// writePDF writes lines of text as a PDF document, breaking lines too long for the page and
+// starting a new page when one is full. Characters outside Windows-1252 are printed as "?".
+func writePDF(w io.Writer, lines []string) error {
+	var wrapped []string
+	for _, line := range lines {
+		runes := []rune(line)
+		for len(runes) > charsPerLine {
+			wrapped = append(wrapped, string(runes[:charsPerLine]))
+			runes = runes[charsPerLine:]
+		}
+		wrapped = append(wrapped, string(runes))
+	}
+
+	var pages [][]string
+	for len(wrapped) > linesPerPage {
+		pages = append(pages, wrapped[:linesPerPage])
+		wrapped = wrapped[linesPerPage:]
+	}
+	pages = append(pages, wrapped)
+
+	// Objects 1 to 3 are the catalog, the page tree and the font; each page then takes two
+	// objects, the page and its content stream.
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+	}
+	kids := make([]string, 0, len(pages))
+	for _, page := range pages {
+		pageObject := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageObject))
+
+		content := new(bytes.Buffer)
+		fmt.Fprintf(content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", fontSize, lineHeight, pageMargin, pageHeight-pageMargin-fontSize)
+		for _, line := range page {
+			fmt.Fprintf(content, "(%s) '\n", pdfString(line))
+		}
+		content.WriteString("ET")
+
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, pageObject+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.Bytes()),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+
+	doc := new(bytes.Buffer)
+	doc.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = doc.Len()
+		fmt.Fprintf(doc, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := doc.Len()
+	fmt.Fprintf(doc, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(doc, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(doc, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	_, err := w.Write(doc.Bytes())
+	return err
+}
+
+// pdfString encodes s as the contents of a PDF literal string in Windows-1252, escaping the
+// characters the syntax reserves.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '€':
+			b.WriteByte(0x80)
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
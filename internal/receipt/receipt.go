@@ -0,0 +1,129 @@
+// File: internal/receipt/receipt.go
+// Description: printable sale receipts, rendered from embedded templates as HTML or PDF
+package receipt
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+//go:embed templates/*
+var templatesFS embed.FS
+
+// Width is the number of characters on a line of the text receipt the PDF is printed from.
+const Width = 48
+
+// Store holds the details of the store printed at the top of every receipt. Empty fields are left out.
+type Store struct {
+	Name    string
+	Address string
+	Phone   string
+	TaxID   string // such as a VAT or GST registration number
+}
+
+// Receipt is the data of one sale's receipt. Amounts are formatted to the cent; Discount and Tax
+// are "0.00" when there is none.
+type Receipt struct {
+	Store      Store
+	SaleID     int64
+	SoldAt     string
+	Customer   string // name or email of the customer, empty for none
+	Items      []Item
+	Subtotal   string
+	Discount   string
+	Tax        string
+	Total      string
+	Currency   string
+	Payments   []Payment
+	BalanceDue string // "0.00" once the sale is settled
+	Note       string
+	Status     string // printed above the sale when it is not completed, such as "VOIDED"
+}
+
+// Item is one line of a Receipt.
+type Item struct {
+	Name      string
+	Quantity  int64
+	UnitPrice string
+	LineTotal string
+}
+
+// Payment is one tender shown on a Receipt. Change is "0.00" unless cash was handed over.
+type Payment struct {
+	Method    string
+	Amount    string
+	Change    string
+	Reference string
+}
+
+var (
+	htmlTemplate = htmltemplate.Must(htmltemplate.ParseFS(templatesFS, "templates/receipt.html"))
+	textTemplate = template.Must(template.New("receipt.txt").Funcs(template.FuncMap{
+		"left":   left,
+		"right":  right,
+		"center": center,
+		"rule":   func() string { return strings.Repeat("-", Width) },
+	}).ParseFS(templatesFS, "templates/receipt.txt"))
+)
+
+// ----------------------------------------------------------------------
+//
+//	Methods
+//
+// ----------------------------------------------------------------------
+
+// HTML writes the receipt as a standalone HTML page.
+func (r *Receipt) HTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, r)
+}
+
+// Text writes the receipt as plain text lines of at most Width characters, apart from notes and
+// names too long to fit.
+func (r *Receipt) Text(w io.Writer) error {
+	return textTemplate.Execute(w, r)
+}
+
+// PDF writes the receipt as a PDF document, its text printed in a fixed width font.
+func (r *Receipt) PDF(w io.Writer) error {
+	text := new(bytes.Buffer)
+	if err := r.Text(text); err != nil {
+		return err
+	}
+	return writePDF(w, strings.Split(strings.TrimRight(text.String(), "\n"), "\n"))
+}
+
+// left pads s with spaces to n characters, cutting it short when it is longer.
+func left(n int, s string) string {
+	if count := utf8.RuneCountInString(s); count < n {
+		return s + strings.Repeat(" ", n-count)
+	}
+	return string([]rune(s)[:n])
+}
+
+// right pads s on the left with spaces to n characters. Longer values are kept whole, so amounts
+// are never cut.
+func right(n int, s string) string {
+	if count := utf8.RuneCountInString(s); count < n {
+		return strings.Repeat(" ", n-count) + s
+	}
+	return s
+}
+
+// center pads s on the left so it sits in the middle of a line.
+func center(s string) string {
+	if count := utf8.RuneCountInString(s); count < Width {
+		return strings.Repeat(" ", (Width-count)/2) + s
+	}
+	return s
+}
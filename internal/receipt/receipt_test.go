@@ -0,0 +1,118 @@
+// File: internal/receipt/receipt_test.go
+// Description: test suite for rendering receipts
+
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// sampleReceipt returns a receipt with every section filled in.
+func sampleReceipt() *Receipt {
+	return &Receipt{
+		Store:      Store{Name: "Corner Store", Address: "1 Main Street", Phone: "+501 555 0100", TaxID: "GST-123"},
+		SaleID:     42,
+		SoldAt:     "2 Jan 2026 15:04 CST",
+		Customer:   "Ana <ana@example.com>",
+		Items:      []Item{{Name: "Coffee (large)", Quantity: 2, UnitPrice: "3.50", LineTotal: "7.00"}},
+		Subtotal:   "7.00",
+		Discount:   "0.00",
+		Tax:        "0.88",
+		Total:      "7.88",
+		Currency:   "BZD",
+		Payments:   []Payment{{Method: "cash", Amount: "7.88", Change: "2.12"}},
+		BalanceDue: "0.00",
+	}
+}
+
+// TestText tests the layout of the text receipt
+func TestText(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := sampleReceipt().Text(out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"Corner Store", "Tax ID: GST-123", "Sale #42", "Coffee (large)", "TOTAL BZD", "7.88", "Paid by cash", "Change"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("text receipt is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out.String(), "Discount") {
+		t.Errorf("text receipt shows a discount of 0.00:\n%s", out)
+	}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if utf8.RuneCountInString(line) > Width {
+			t.Errorf("line %q is longer than %d characters", line, Width)
+		}
+	}
+	voided := sampleReceipt()
+	voided.Status = "VOIDED"
+	out.Reset()
+	if err := voided.Text(out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "*** VOIDED ***") {
+		t.Errorf("text receipt of a voided sale is not marked:\n%s", out)
+	}
+}
+
+// TestHTML tests that the HTML receipt shows the sale and escapes its values
+func TestHTML(t *testing.T) {
+	out := new(bytes.Buffer)
+	if err := sampleReceipt().HTML(out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"<h1>Corner Store</h1>", "Sale #42", "Ana &lt;ana@example.com&gt;", "Paid by cash", "7.88"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("HTML receipt is missing %q", want)
+		}
+	}
+}
+
+// TestPDF tests that the PDF is well formed: every cross-reference offset points at its object,
+// text is escaped and long receipts run onto more pages
+func TestPDF(t *testing.T) {
+	receipt := sampleReceipt()
+	for i := 0; i < 60; i++ {
+		receipt.Items = append(receipt.Items, Item{Name: fmt.Sprintf("Item %d", i), Quantity: 1, UnitPrice: "1.00", LineTotal: "1.00"})
+	}
+
+	out := new(bytes.Buffer)
+	if err := receipt.PDF(out); err != nil {
+		t.Fatal(err)
+	}
+	doc := out.Bytes()
+
+	if !bytes.HasPrefix(doc, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(doc, []byte("%%EOF\n")) {
+		t.Fatal("PDF does not start with its header or end with the end of file marker")
+	}
+	if !bytes.Contains(doc, []byte(`(Coffee \(large\)`)) {
+		t.Error("parentheses in the text are not escaped")
+	}
+	if !bytes.Contains(doc, []byte("/Count 3")) {
+		t.Error("expected the receipt to take 3 pages")
+	}
+
+	start := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(doc)
+	if start == nil {
+		t.Fatal("no startxref")
+	}
+	xref, _ := strconv.Atoi(string(start[1]))
+	if !bytes.HasPrefix(doc[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(doc[xref:], -1)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !bytes.HasPrefix(doc[offset:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q", i+1, doc[offset:offset+10])
+		}
+	}
+}
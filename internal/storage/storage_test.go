@@ -0,0 +1,89 @@
+// File: internal/storage/storage_test.go
+// Description: test suite for the local directory store
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDirRoundTrip tests storing, replacing, reading and deleting a file
+func TestDirRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir, err := NewDir(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, contents := range []string{"first", "second"} {
+		if err := dir.Put(ctx, "sales/1/photo.jpg", strings.NewReader(contents)); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+
+	file, err := dir.Open(ctx, "sales/1/photo.jpg")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	got, _ := io.ReadAll(file)
+	file.Close()
+	if string(got) != "second" {
+		t.Errorf("expected the replaced contents, got %q", got)
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(dir.root, "sales", "1"))
+	if len(entries) != 1 {
+		t.Errorf("expected no temporary files to be left behind, got %d entries", len(entries))
+	}
+
+	if err := dir.Delete(ctx, "sales/1/photo.jpg"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := dir.Open(ctx, "sales/1/photo.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after deleting, got %v", err)
+	}
+	if err := dir.Delete(ctx, "sales/1/photo.jpg"); err != nil {
+		t.Errorf("expected deleting a missing file to succeed, got %v", err)
+	}
+}
+
+// TestDirInvalidKeys tests that keys cannot reach outside the store
+func TestDirInvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	dir, err := NewDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"", "../secret", "sales/../../secret", "/etc/passwd"} {
+		if err := dir.Put(ctx, key, strings.NewReader("x")); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("put %q: expected ErrInvalidKey, got %v", key, err)
+		}
+		if _, err := dir.Open(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("open %q: expected ErrInvalidKey, got %v", key, err)
+		}
+	}
+}
+
+// TestDirPutCancelled tests that a cancelled upload stores nothing
+func TestDirPutCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	dir, err := NewDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.Put(ctx, "sales/1/photo.jpg", strings.NewReader("x")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, err := dir.Open(context.Background(), "sales/1/photo.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected nothing stored, got %v", err)
+	}
+}
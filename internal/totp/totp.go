@@ -0,0 +1,159 @@
+// File: internal/totp/totp.go
+// Description: time-based one-time passwords (RFC 6238) and encryption of the shared secrets at rest
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// ----------------------------------------------------------------------
+//
+//	Definitions
+//
+// ----------------------------------------------------------------------
+
+const (
+	SecretSize = 20               // size of a generated secret in bytes (160 bits, as recommended by RFC 4226)
+	Digits     = 6                // number of digits in a code
+	Period     = 30 * time.Second // lifetime of a single code
+)
+
+// ErrInvalidCiphertext is returned when a stored secret cannot be decrypted with the configured key.
+var ErrInvalidCiphertext = errors.New("totp: invalid ciphertext")
+
+// encoding is the unpadded base32 alphabet authenticator apps expect.
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// ----------------------------------------------------------------------
+//
+//	Codes
+//
+// ----------------------------------------------------------------------
+
+// GenerateSecret returns a new random shared secret.
+func GenerateSecret() ([]byte, error) {
+	secret := make([]byte, SecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// EncodeSecret returns the base32 form of a secret for manual entry in an authenticator app.
+func EncodeSecret(secret []byte) string {
+	return encoding.EncodeToString(secret)
+}
+
+// URL builds the otpauth:// URL that authenticator apps read from a QR code.
+func URL(issuer, account string, secret []byte) string {
+	params := url.Values{}
+	params.Set("secret", EncodeSecret(secret))
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(Digits))
+	params.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// Code returns the code for the time step containing t.
+func Code(secret []byte, t time.Time) string {
+	return hotp(secret, uint64(t.Unix())/uint64(Period.Seconds()))
+}
+
+// Validate reports whether code is valid at time t, allowing for skew steps of clock drift either way.
+func Validate(secret []byte, code string, t time.Time, skew int) bool {
+	if len(code) != Digits {
+		return false
+	}
+
+	step := int64(t.Unix()) / int64(Period.Seconds())
+	for i := -skew; i <= skew; i++ {
+		candidate := hotp(secret, uint64(step+int64(i)))
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(code)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// hotp computes the HMAC-based one-time password (RFC 4226) for a counter.
+func hotp(secret []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for range Digits {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%modulo)
+}
+
+// ----------------------------------------------------------------------
+//
+//	Secret encryption
+//
+// ----------------------------------------------------------------------
+
+// Encrypt seals a secret with AES-GCM under a 16, 24 or 32 byte key, prefixing the random nonce.
+func Encrypt(key, secret []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, secret, nil), nil
+}
+
+// Decrypt opens a secret sealed by Encrypt.
+func Decrypt(key, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	secret, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return secret, nil
+}
+
+// newGCM creates an AES-GCM cipher for the given key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
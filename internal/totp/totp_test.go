@@ -0,0 +1,111 @@
+// File: internal/totp/totp_test.go
+// Description: test suite for TOTP codes and secret encryption
+
+package totp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 test secret from RFC 6238 Appendix B.
+var rfcSecret = []byte("12345678901234567890")
+
+// TestCode checks codes against the RFC 6238 test vectors (truncated to 6 digits)
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		unix     int64
+		expected string
+	}{
+		{name: "T=59", unix: 59, expected: "287082"},
+		{name: "T=1111111109", unix: 1111111109, expected: "081804"},
+		{name: "T=1111111111", unix: 1111111111, expected: "050471"},
+		{name: "T=1234567890", unix: 1234567890, expected: "005924"},
+		{name: "T=2000000000", unix: 2000000000, expected: "279037"},
+		{name: "T=20000000000", unix: 20000000000, expected: "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := Code(rfcSecret, time.Unix(tt.unix, 0))
+			if code != tt.expected {
+				t.Errorf("expected code %s, got %s", tt.expected, code)
+			}
+		})
+	}
+}
+
+// TestValidate checks clock skew handling and malformed codes
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     int
+		expected bool
+	}{
+		{name: "Current Step", code: Code(rfcSecret, now), skew: 1, expected: true},
+		{name: "Previous Step Within Skew", code: Code(rfcSecret, now.Add(-Period)), skew: 1, expected: true},
+		{name: "Next Step Within Skew", code: Code(rfcSecret, now.Add(Period)), skew: 1, expected: true},
+		{name: "Previous Step Without Skew", code: Code(rfcSecret, now.Add(-Period)), skew: 0, expected: false},
+		{name: "Two Steps Old", code: Code(rfcSecret, now.Add(-2*Period)), skew: 1, expected: false},
+		{name: "Wrong Length", code: "12345", skew: 1, expected: false},
+		{name: "Empty", code: "", skew: 1, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Validate(rfcSecret, tt.code, now, tt.skew); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestURL checks the otpauth URL carries the encoded secret and issuer
+func TestURL(t *testing.T) {
+	u := URL("SalesAPI", "jane@example.com", rfcSecret)
+
+	if !strings.HasPrefix(u, "otpauth://totp/SalesAPI:jane@example.com?") {
+		t.Errorf("unexpected URL prefix: %s", u)
+	}
+	if !strings.Contains(u, "secret="+EncodeSecret(rfcSecret)) {
+		t.Errorf("URL is missing the secret: %s", u)
+	}
+	if !strings.Contains(u, "issuer=SalesAPI") {
+		t.Errorf("URL is missing the issuer: %s", u)
+	}
+}
+
+// TestEncryptDecrypt checks secrets round trip and fail to open under the wrong key
+func TestEncryptDecrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	otherKey := bytes.Repeat([]byte{0x24}, 32)
+
+	sealed, err := Encrypt(key, rfcSecret)
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	if bytes.Contains(sealed, rfcSecret) {
+		t.Fatal("ciphertext contains the plaintext secret")
+	}
+
+	opened, err := Decrypt(key, sealed)
+	if err != nil {
+		t.Fatalf("failed to decrypt: %v", err)
+	}
+	if !bytes.Equal(opened, rfcSecret) {
+		t.Errorf("expected %q, got %q", rfcSecret, opened)
+	}
+
+	if _, err := Decrypt(otherKey, sealed); err == nil {
+		t.Error("expected an error decrypting with the wrong key")
+	}
+	if _, err := Decrypt(key, sealed[:4]); err == nil {
+		t.Error("expected an error decrypting a truncated ciphertext")
+	}
+}
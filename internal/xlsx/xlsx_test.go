@@ -0,0 +1,119 @@
+// File: internal/xlsx/xlsx_test.go
+// Description: test suite for the workbook writer
+
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+// readParts unzips a workbook into its parts by name, checking each is well formed XML.
+func readParts(t *testing.T, workbook []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(workbook), int64(len(workbook)))
+	if err != nil {
+		t.Fatalf("workbook is not a zip archive: %v", err)
+	}
+
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoder := xml.NewDecoder(bytes.NewReader(body))
+		for {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not well formed: %v", f.Name, err)
+			}
+		}
+		parts[f.Name] = string(body)
+	}
+	return parts
+}
+
+// TestWriter tests that a workbook has every part Excel needs, with strings, numbers, empty cells
+// and bold headers in the right places
+func TestWriter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriter(buf)
+
+	if err := w.WriteRow("too early"); err == nil {
+		t.Fatal("expected an error writing before a sheet is added")
+	}
+	if err := w.AddSheet("Sales"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader("name", "quantity", "price"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow(`=SUM(A1) & <b>"Ana's"</b>`, int64(2), 9.99); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow("no quantity", nil, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow(struct{}{}); err == nil {
+		t.Error("expected an error for an unsupported value")
+	}
+	if err := w.AddSheet("sales"); err == nil {
+		t.Error("expected an error for a duplicate sheet name")
+	}
+	if err := w.AddSheet("Summary: March/April and the rest of the year"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow("total", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := readParts(t, buf.Bytes())
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("workbook is missing %s", name)
+		}
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" t="inlineStr" s="1"><is><t xml:space="preserve">name</t></is></c>`,
+		`<t xml:space="preserve">=SUM(A1) &amp; &lt;b&gt;&#34;Ana&#39;s&#34;&lt;/b&gt;</t>`,
+		`<c r="B2"><v>2</v></c><c r="C2"><v>9.99</v></c>`,
+		`<row r="3"><c r="A3" t="inlineStr"><is><t xml:space="preserve">no quantity</t></is></c><c r="C3"><v>0.5</v></c></row>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet is missing %s:\n%s", want, sheet)
+		}
+	}
+	if strings.Contains(sheet, "<f>") {
+		t.Error("expected strings never to be written as formulas")
+	}
+
+	if !strings.Contains(parts["xl/workbook.xml"], `<sheet name="Summary_ March_April and the re" sheetId="2" r:id="rId2"/>`) {
+		t.Errorf("expected the second sheet's name cleaned and cut to 31 characters:\n%s", parts["xl/workbook.xml"])
+	}
+}
+
+// TestColumnName tests the spreadsheet letters of column indexes
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %s, expected %s", i, got, want)
+		}
+	}
+}
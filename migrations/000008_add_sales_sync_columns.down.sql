@@ -0,0 +1,6 @@
+-- File: migrations/000008_add_sales_sync_columns.down.sql
+-- Migration to remove the offline sync columns and deletion log
+DROP TABLE IF EXISTS "sales_deletions";
+DROP INDEX IF EXISTS "sales_updated_at_idx";
+ALTER TABLE "sales" DROP COLUMN IF EXISTS "updated_at";
+ALTER TABLE "sales" DROP COLUMN IF EXISTS "client_uuid";
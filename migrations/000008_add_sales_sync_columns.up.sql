@@ -0,0 +1,13 @@
+-- File: migrations/000008_add_sales_sync_columns.up.sql
+-- Migration to support offline terminal sync: client generated UUIDs for
+-- deduplication, an updated_at change marker and a log of deleted sales
+ALTER TABLE "sales" ADD COLUMN IF NOT EXISTS "client_uuid" UUID UNIQUE;
+ALTER TABLE "sales" ADD COLUMN IF NOT EXISTS "updated_at" TIMESTAMP NOT NULL DEFAULT NOW();
+CREATE INDEX IF NOT EXISTS "sales_updated_at_idx" ON "sales" ("updated_at");
+
+CREATE TABLE IF NOT EXISTS "sales_deletions" (
+    "sale_id" BIGINT PRIMARY KEY,
+    "client_uuid" UUID,
+    "deleted_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS "sales_deletions_deleted_at_idx" ON "sales_deletions" ("deleted_at");
@@ -0,0 +1,4 @@
+-- File: migrations/000009_add_users_totp.down.sql
+-- Migration to remove TOTP two-factor authentication from users
+ALTER TABLE "users" DROP COLUMN IF EXISTS "totp_enabled";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "totp_secret";
@@ -0,0 +1,5 @@
+-- File: migrations/000009_add_users_totp.up.sql
+-- Migration to add optional TOTP two-factor authentication to users.
+-- The secret is stored AES-GCM encrypted by the application.
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_secret" bytea;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_enabled" BOOLEAN NOT NULL DEFAULT FALSE;
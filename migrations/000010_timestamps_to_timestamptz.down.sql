@@ -0,0 +1,9 @@
+-- File: migrations/000010_timestamps_to_timestamptz.down.sql
+-- Migration to revert timestamps to columns without time zone
+ALTER TABLE "users" DROP COLUMN IF EXISTS "timezone";
+
+ALTER TABLE "sales_deletions" ALTER COLUMN "deleted_at" TYPE TIMESTAMP;
+ALTER TABLE "sales" ALTER COLUMN "sold_at" TYPE TIMESTAMP, ALTER COLUMN "updated_at" TYPE TIMESTAMP;
+ALTER TABLE "products" ALTER COLUMN "created_at" TYPE TIMESTAMP, ALTER COLUMN "updated_at" TYPE TIMESTAMP;
+ALTER TABLE "tokens" ALTER COLUMN "expires_at" TYPE TIMESTAMP;
+ALTER TABLE "users" ALTER COLUMN "created_at" TYPE TIMESTAMP, ALTER COLUMN "updated_at" TYPE TIMESTAMP;
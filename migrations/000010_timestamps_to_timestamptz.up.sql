@@ -0,0 +1,11 @@
+-- File: migrations/000010_timestamps_to_timestamptz.up.sql
+-- Migration to store every timestamp with its time zone so the API can report them in UTC.
+-- Existing values are interpreted in the session time zone, which is what NOW() used to write them.
+ALTER TABLE "users" ALTER COLUMN "created_at" TYPE TIMESTAMPTZ, ALTER COLUMN "updated_at" TYPE TIMESTAMPTZ;
+ALTER TABLE "tokens" ALTER COLUMN "expires_at" TYPE TIMESTAMPTZ;
+ALTER TABLE "products" ALTER COLUMN "created_at" TYPE TIMESTAMPTZ, ALTER COLUMN "updated_at" TYPE TIMESTAMPTZ;
+ALTER TABLE "sales" ALTER COLUMN "sold_at" TYPE TIMESTAMPTZ, ALTER COLUMN "updated_at" TYPE TIMESTAMPTZ;
+ALTER TABLE "sales_deletions" ALTER COLUMN "deleted_at" TYPE TIMESTAMPTZ;
+
+-- Preferred time zone for presentation endpoints (reports, exports)
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "timezone" TEXT NOT NULL DEFAULT 'UTC';
@@ -0,0 +1,6 @@
+-- File: migrations/000011_create_roles_tables.down.sql
+-- Migration to drop the roles tables
+ALTER TABLE "users" DROP CONSTRAINT IF EXISTS "users_role_fkey";
+DROP TABLE IF EXISTS "role_permissions";
+DROP TABLE IF EXISTS "roles";
+DELETE FROM "permissions" WHERE code LIKE 'roles:%';
@@ -0,0 +1,53 @@
+-- File: migrations/000011_create_roles_tables.up.sql
+-- Migration to move role permission bundles out of the code and into the database
+CREATE TABLE IF NOT EXISTS "roles" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "name" TEXT NOT NULL UNIQUE,
+    "description" TEXT NOT NULL DEFAULT '',
+    "is_system" BOOLEAN NOT NULL DEFAULT FALSE,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "version" INT NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS "role_permissions" (
+    "role_id" BIGINT NOT NULL REFERENCES "roles"("id") ON DELETE CASCADE,
+    "permission_id" BIGINT NOT NULL REFERENCES "permissions"("id") ON DELETE CASCADE,
+    PRIMARY KEY ("role_id", "permission_id")
+);
+
+-- Permissions for managing roles
+INSERT INTO "permissions" (code) VALUES
+('roles:create'),
+('roles:view'),
+('roles:delete'),
+('roles:update')
+ON CONFLICT (code) DO NOTHING;
+
+-- The built in roles, matching the bundles previously hard-coded in the user handlers
+INSERT INTO "roles" (name, description, is_system) VALUES
+('admin', 'Full access to all resources', TRUE),
+('cashier', 'Records sales and manages products', TRUE),
+('guest', 'Read only access to products', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON
+    (r.name = 'admin')
+    OR (r.name = 'cashier' AND p.code IN ('sale:create', 'sale:view', 'product:create', 'product:view', 'users:view', 'self:create', 'self:view', 'self:update'))
+    OR (r.name = 'guest' AND p.code IN ('product:view', 'self:view'))
+ON CONFLICT DO NOTHING;
+
+-- Existing admins pick up the new role permissions
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code LIKE 'roles:%'
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
+
+-- Every user must now hold a known role; renames follow the role
+UPDATE "users" SET "role" = 'guest' WHERE "role" NOT IN (SELECT "name" FROM "roles");
+ALTER TABLE "users" ADD CONSTRAINT "users_role_fkey" FOREIGN KEY ("role") REFERENCES "roles"("name") ON UPDATE CASCADE;
@@ -0,0 +1,9 @@
+-- File: migrations/000012_add_created_by_updated_by.down.sql
+-- Migration to drop the created_by and updated_by columns
+DROP INDEX IF EXISTS "idx_sales_updated_by";
+DROP INDEX IF EXISTS "idx_sales_created_by";
+DROP INDEX IF EXISTS "idx_products_updated_by";
+DROP INDEX IF EXISTS "idx_products_created_by";
+
+ALTER TABLE "sales" DROP COLUMN IF EXISTS "updated_by", DROP COLUMN IF EXISTS "created_by";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "updated_by", DROP COLUMN IF EXISTS "created_by";
@@ -0,0 +1,14 @@
+-- File: migrations/000012_add_created_by_updated_by.up.sql
+-- Migration to record which user created and last modified products and sales
+ALTER TABLE "products"
+    ADD COLUMN IF NOT EXISTS "created_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS "updated_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL;
+
+ALTER TABLE "sales"
+    ADD COLUMN IF NOT EXISTS "created_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS "updated_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS "idx_products_created_by" ON "products" ("created_by");
+CREATE INDEX IF NOT EXISTS "idx_products_updated_by" ON "products" ("updated_by");
+CREATE INDEX IF NOT EXISTS "idx_sales_created_by" ON "sales" ("created_by");
+CREATE INDEX IF NOT EXISTS "idx_sales_updated_by" ON "sales" ("updated_by");
@@ -0,0 +1,4 @@
+-- File: migrations/000013_add_users_permissions_grants.down.sql
+-- Migration to drop individual permission grants
+DELETE FROM "permissions" WHERE code = 'users:permissions';
+ALTER TABLE "users_permissions" DROP COLUMN IF EXISTS "is_grant";
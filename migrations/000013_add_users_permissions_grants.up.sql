@@ -0,0 +1,20 @@
+-- File: migrations/000013_add_users_permissions_grants.up.sql
+-- Migration to tell individual permission grants apart from the defaults copied from a user's role
+ALTER TABLE "users_permissions" ADD COLUMN IF NOT EXISTS "is_grant" BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Permission for granting and revoking individual user permissions
+INSERT INTO "permissions" (code) VALUES ('users:permissions') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code = 'users:permissions'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code = 'users:permissions'
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,6 @@
+-- File: migrations/000014_add_sales_bulk_operations.down.sql
+-- Migration to drop bulk sale operations support
+DROP TABLE IF EXISTS "audit_log";
+DROP TABLE IF EXISTS "bulk_confirmations";
+DROP INDEX IF EXISTS "sales_archived_at_idx";
+ALTER TABLE "sales" DROP COLUMN IF EXISTS "archived_at";
@@ -0,0 +1,25 @@
+-- File: migrations/000014_add_sales_bulk_operations.up.sql
+-- Migration to support bulk deleting or archiving sales: an archive marker,
+-- pending confirmations for bulk operations and an audit log of what was changed
+ALTER TABLE "sales" ADD COLUMN IF NOT EXISTS "archived_at" TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS "sales_archived_at_idx" ON "sales" ("archived_at");
+
+CREATE TABLE IF NOT EXISTS "bulk_confirmations" (
+    "hash" bytea PRIMARY KEY,
+    "user_id" BIGINT NOT NULL REFERENCES "users"("id") ON DELETE CASCADE,
+    "action" TEXT NOT NULL,
+    "entity_ids" BIGINT[] NOT NULL,
+    "expires_at" TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "audit_log" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "user_id" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "action" TEXT NOT NULL,
+    "entity" TEXT NOT NULL,
+    "entity_ids" BIGINT[] NOT NULL DEFAULT '{}',
+    "details" JSONB NOT NULL DEFAULT '{}',
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS "audit_log_created_at_idx" ON "audit_log" ("created_at");
+CREATE INDEX IF NOT EXISTS "audit_log_entity_idx" ON "audit_log" ("entity", "action");
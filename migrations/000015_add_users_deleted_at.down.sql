@@ -0,0 +1,6 @@
+-- File: migrations/000015_add_users_deleted_at.down.sql
+-- Rollback migration for soft deleted users, which become visible again
+DROP INDEX IF EXISTS "idx_users_deleted_at";
+
+ALTER TABLE "users"
+    DROP COLUMN IF EXISTS "deleted_at";
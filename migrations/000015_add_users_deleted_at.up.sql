@@ -0,0 +1,6 @@
+-- File: migrations/000015_add_users_deleted_at.up.sql
+-- Migration to soft delete users so their sales and audit history keep a valid user reference
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS "deleted_at" TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
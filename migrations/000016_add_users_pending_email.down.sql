@@ -0,0 +1,6 @@
+-- File: migrations/000016_add_users_pending_email.down.sql
+-- Rollback migration for pending email changes
+DELETE FROM "tokens" WHERE "scope" = 'email_change';
+
+ALTER TABLE "users"
+    DROP COLUMN IF EXISTS "pending_email";
@@ -0,0 +1,4 @@
+-- File: migrations/000016_add_users_pending_email.up.sql
+-- Migration to hold a requested email address until the user confirms it from the new inbox
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS "pending_email" TEXT;
@@ -0,0 +1,6 @@
+-- File: migrations/000017_add_products_is_active.down.sql
+-- Rollback migration for product deactivation
+DROP INDEX IF EXISTS "idx_products_is_active";
+
+ALTER TABLE "products"
+    DROP COLUMN IF EXISTS "is_active";
@@ -0,0 +1,6 @@
+-- File: migrations/000017_add_products_is_active.up.sql
+-- Migration to retire products from sale without deleting the rows historical sales point at
+ALTER TABLE "products"
+    ADD COLUMN IF NOT EXISTS "is_active" BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE INDEX IF NOT EXISTS "idx_products_is_active" ON "products" ("is_active");
@@ -0,0 +1,3 @@
+-- File: migrations/000018_add_products_name_prefix_index.down.sql
+-- Rollback migration for the product name prefix index
+DROP INDEX IF EXISTS "idx_products_name_prefix";
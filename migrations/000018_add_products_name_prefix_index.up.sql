@@ -0,0 +1,3 @@
+-- File: migrations/000018_add_products_name_prefix_index.up.sql
+-- Migration to index lowercased product names for prefix searches from the POS search box
+CREATE INDEX IF NOT EXISTS "idx_products_name_prefix" ON "products" (LOWER("name") text_pattern_ops) WHERE "is_active";
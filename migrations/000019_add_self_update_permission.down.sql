@@ -0,0 +1,3 @@
+-- File: migrations/000019_add_self_update_permission.down.sql
+-- Migration to drop the self service profile permission
+DELETE FROM "permissions" WHERE code = 'self:update';
@@ -0,0 +1,18 @@
+-- File: migrations/000019_add_self_update_permission.up.sql
+-- Migration to add the permission that lets users edit their own profile, given to every built in role
+INSERT INTO "permissions" (code) VALUES ('self:update') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code = 'self:update'
+WHERE r.is_system
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "roles" r ON r.name = u.role
+INNER JOIN "permissions" p ON p.code = 'self:update'
+WHERE r.is_system
+ON CONFLICT DO NOTHING;
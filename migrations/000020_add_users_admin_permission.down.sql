@@ -0,0 +1,3 @@
+-- File: migrations/000020_add_users_admin_permission.down.sql
+-- Migration to drop the admin role assignment permission
+DELETE FROM "permissions" WHERE code = 'users:admin';
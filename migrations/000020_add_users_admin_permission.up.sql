@@ -0,0 +1,17 @@
+-- File: migrations/000020_add_users_admin_permission.up.sql
+-- Migration to add the permission required to move users into or out of the admin role
+INSERT INTO "permissions" (code) VALUES ('users:admin') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code = 'users:admin'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code = 'users:admin'
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+-- File: migrations/000021_create_invitations_table.down.sql
+-- Migration to drop the invitations table
+DROP TABLE IF EXISTS "invitations";
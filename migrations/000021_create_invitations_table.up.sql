@@ -0,0 +1,10 @@
+-- File: migrations/000021_create_invitations_table.up.sql
+-- Migration to create the invitations table, letting admins invite staff who then set their own password
+CREATE TABLE IF NOT EXISTS "invitations" (
+    "hash" bytea PRIMARY KEY,
+    "email" TEXT NOT NULL UNIQUE,
+    "role" TEXT NOT NULL REFERENCES "roles"("name") ON UPDATE CASCADE ON DELETE CASCADE,
+    "invited_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "expires_at" TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,4 @@
+-- File: migrations/000022_create_emails_tables.down.sql
+-- Migration to drop the email tracking and suppression tables
+DROP TABLE IF EXISTS "email_suppressions";
+DROP TABLE IF EXISTS "emails";
@@ -0,0 +1,22 @@
+-- File: migrations/000022_create_emails_tables.up.sql
+-- Migration to track outgoing emails by message ID and to suppress addresses that hard bounce or complain
+CREATE TABLE IF NOT EXISTS "emails" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "message_id" TEXT NOT NULL UNIQUE,
+    "user_id" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "recipient" TEXT NOT NULL,
+    "template" TEXT NOT NULL,
+    "status" TEXT NOT NULL DEFAULT 'queued' CHECK ("status" IN ('queued', 'sent', 'failed', 'delivered', 'bounced', 'complained', 'suppressed')),
+    "error" TEXT NOT NULL DEFAULT '',
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "emails_recipient_idx" ON "emails" ("recipient");
+
+CREATE TABLE IF NOT EXISTS "email_suppressions" (
+    "email" TEXT PRIMARY KEY,
+    "reason" TEXT NOT NULL CHECK ("reason" IN ('bounce', 'complaint')),
+    "message_id" TEXT,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
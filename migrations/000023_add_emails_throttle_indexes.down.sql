@@ -0,0 +1,4 @@
+-- File: migrations/000023_add_emails_throttle_indexes.down.sql
+-- Migration to drop the email throttling indexes
+DROP INDEX IF EXISTS "emails_recipient_template_created_idx";
+DROP INDEX IF EXISTS "emails_user_template_created_idx";
@@ -0,0 +1,4 @@
+-- File: migrations/000023_add_emails_throttle_indexes.up.sql
+-- Migration to index recent emails by user and template for per user send throttling
+CREATE INDEX IF NOT EXISTS "emails_user_template_created_idx" ON "emails" ("user_id", "template", "created_at");
+CREATE INDEX IF NOT EXISTS "emails_recipient_template_created_idx" ON "emails" ("recipient", "template", "created_at");
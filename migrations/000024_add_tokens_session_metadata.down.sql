@@ -0,0 +1,8 @@
+-- File: migrations/000024_add_tokens_session_metadata.down.sql
+-- Migration to drop the session metadata from tokens
+DROP INDEX IF EXISTS "tokens_user_scope_idx";
+ALTER TABLE "tokens"
+    DROP COLUMN IF EXISTS "last_used_at",
+    DROP COLUMN IF EXISTS "ip",
+    DROP COLUMN IF EXISTS "user_agent",
+    DROP COLUMN IF EXISTS "created_at";
@@ -0,0 +1,9 @@
+-- File: migrations/000024_add_tokens_session_metadata.up.sql
+-- Migration to record where and when authentication tokens were issued and last used
+ALTER TABLE "tokens"
+    ADD COLUMN IF NOT EXISTS "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS "user_agent" TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS "ip" TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS "last_used_at" TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS "tokens_user_scope_idx" ON "tokens" ("user_id", "scope");
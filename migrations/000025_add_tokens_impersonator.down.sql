@@ -0,0 +1,3 @@
+-- File: migrations/000025_add_tokens_impersonator.down.sql
+-- Migration to drop the impersonator from tokens
+ALTER TABLE "tokens" DROP COLUMN IF EXISTS "impersonator_id";
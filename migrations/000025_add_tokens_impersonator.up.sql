@@ -0,0 +1,4 @@
+-- File: migrations/000025_add_tokens_impersonator.up.sql
+-- Migration to mark authentication tokens an admin issued to act as another user
+ALTER TABLE "tokens"
+    ADD COLUMN IF NOT EXISTS "impersonator_id" BIGINT REFERENCES "users"("id") ON DELETE CASCADE;
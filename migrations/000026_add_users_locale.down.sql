@@ -0,0 +1,3 @@
+-- File: migrations/000026_add_users_locale.down.sql
+-- Migration to drop the preferred email language of users
+ALTER TABLE "users" DROP COLUMN IF EXISTS "locale";
@@ -0,0 +1,3 @@
+-- File: migrations/000026_add_users_locale.up.sql
+-- Migration to store the language users want their emails in
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "locale" TEXT NOT NULL DEFAULT 'en';
@@ -0,0 +1,2 @@
+-- File: migrations/000027_normalize_permission_codes.down.sql
+-- Migration left empty on purpose: the legacy codes were never checked by the API, so there is nothing to restore
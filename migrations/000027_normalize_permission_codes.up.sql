@@ -0,0 +1,78 @@
+-- File: migrations/000027_normalize_permission_codes.up.sql
+-- Migration to rename legacy permission codes to the catalog in internal/data/permissions.go
+CREATE TEMPORARY TABLE "legacy_permission_codes" (
+    "legacy" TEXT PRIMARY KEY,
+    "code" TEXT NOT NULL
+);
+
+INSERT INTO "legacy_permission_codes" (legacy, code) VALUES
+('products:read', 'product:view'),
+('products:view', 'product:view'),
+('products:create', 'product:create'),
+('products:write', 'product:update'),
+('products:update', 'product:update'),
+('products:delete', 'product:delete'),
+('sales:read', 'sale:view'),
+('sales:view', 'sale:view'),
+('sales:create', 'sale:create'),
+('sales:write', 'sale:update'),
+('sales:update', 'sale:update'),
+('sales:delete', 'sale:delete'),
+('users:read', 'users:view'),
+('users:write', 'users:update'),
+('roles:read', 'roles:view'),
+('roles:write', 'roles:update');
+
+-- The canonical codes must exist before grants move onto them
+INSERT INTO "permissions" (code)
+SELECT DISTINCT l.code
+FROM "legacy_permission_codes" l
+INNER JOIN "permissions" p ON p.code = l.legacy
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id, is_grant)
+SELECT up.user_id, canonical.id, up.is_grant
+FROM "users_permissions" up
+INNER JOIN "permissions" legacy ON legacy.id = up.permission_id
+INNER JOIN "legacy_permission_codes" l ON l.legacy = legacy.code
+INNER JOIN "permissions" canonical ON canonical.code = l.code
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT rp.role_id, canonical.id
+FROM "role_permissions" rp
+INNER JOIN "permissions" legacy ON legacy.id = rp.permission_id
+INNER JOIN "legacy_permission_codes" l ON l.legacy = legacy.code
+INNER JOIN "permissions" canonical ON canonical.code = l.code
+ON CONFLICT DO NOTHING;
+
+-- Grants of the legacy codes cascade away with them
+DELETE FROM "permissions" WHERE code IN (SELECT legacy FROM "legacy_permission_codes");
+
+DROP TABLE "legacy_permission_codes";
+
+-- Every code in the catalog, the API also seeds these at startup
+INSERT INTO "permissions" (code) VALUES
+('sale:create'),
+('sale:view'),
+('sale:update'),
+('sale:delete'),
+('product:create'),
+('product:view'),
+('product:update'),
+('product:delete'),
+('users:create'),
+('users:view'),
+('users:update'),
+('users:delete'),
+('users:permissions'),
+('users:admin'),
+('roles:create'),
+('roles:view'),
+('roles:update'),
+('roles:delete'),
+('self:create'),
+('self:view'),
+('self:update'),
+('self:delete')
+ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,4 @@
+-- File: migrations/000028_create_stock_tables.down.sql
+-- Migration to drop stock tracking
+DROP TABLE IF EXISTS "stock_movements";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "stock_quantity";
@@ -0,0 +1,18 @@
+-- File: migrations/000028_create_stock_tables.up.sql
+-- Migration to track stock levels of products and the movements that changed them.
+-- A NULL stock_quantity means the product's stock is not tracked and never blocks a sale.
+ALTER TABLE "products"
+    ADD COLUMN IF NOT EXISTS "stock_quantity" BIGINT;
+
+CREATE TABLE IF NOT EXISTS "stock_movements" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "product_id" BIGINT NOT NULL REFERENCES "products"("id") ON DELETE CASCADE,
+    "quantity" BIGINT NOT NULL CHECK ("quantity" <> 0),
+    "reason" TEXT NOT NULL,
+    "sale_id" BIGINT REFERENCES "sales"("id") ON DELETE SET NULL,
+    "note" TEXT NOT NULL DEFAULT '',
+    "created_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "idx_stock_movements_product_id" ON "stock_movements" ("product_id", "created_at");
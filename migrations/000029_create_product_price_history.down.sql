@@ -0,0 +1,3 @@
+-- File: migrations/000029_create_product_price_history.down.sql
+-- Migration to drop the product price history
+DROP TABLE IF EXISTS "product_price_history";
@@ -0,0 +1,12 @@
+-- File: migrations/000029_create_product_price_history.up.sql
+-- Migration to record every change of a product's price and who made it
+CREATE TABLE IF NOT EXISTS "product_price_history" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "product_id" BIGINT NOT NULL REFERENCES "products"("id") ON DELETE CASCADE,
+    "old_price" NUMERIC(10, 2) NOT NULL,
+    "new_price" NUMERIC(10, 2) NOT NULL,
+    "changed_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "changed_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "idx_product_price_history_product_id" ON "product_price_history" ("product_id", "changed_at");
@@ -0,0 +1,4 @@
+-- File: migrations/000030_create_revenue_targets.down.sql
+-- Migration to drop revenue targets and their permissions
+DROP TABLE IF EXISTS "revenue_targets";
+DELETE FROM "permissions" WHERE code IN ('targets:view', 'targets:update');
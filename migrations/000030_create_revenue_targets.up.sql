@@ -0,0 +1,28 @@
+-- File: migrations/000030_create_revenue_targets.up.sql
+-- Migration to store monthly revenue targets, one per calendar month, and the
+-- permissions for viewing and setting them
+CREATE TABLE IF NOT EXISTS "revenue_targets" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "month" DATE NOT NULL UNIQUE CHECK (EXTRACT(DAY FROM "month") = 1),
+    "amount" NUMERIC(12, 2) NOT NULL CHECK ("amount" > 0),
+    "created_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "updated_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO "permissions" (code) VALUES ('targets:view'), ('targets:update') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code IN ('targets:view', 'targets:update')
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code IN ('targets:view', 'targets:update')
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
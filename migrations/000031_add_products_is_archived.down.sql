@@ -0,0 +1,9 @@
+-- File: migrations/000031_add_products_is_archived.down.sql
+-- Rollback migration for product archiving
+ALTER TABLE "sales" DROP CONSTRAINT IF EXISTS "sales_product_id_fkey";
+ALTER TABLE "sales" ADD CONSTRAINT "sales_product_id_fkey" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS "idx_products_is_archived";
+
+ALTER TABLE "products"
+    DROP COLUMN IF EXISTS "is_archived";
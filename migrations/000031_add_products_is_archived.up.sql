@@ -0,0 +1,9 @@
+-- File: migrations/000031_add_products_is_archived.up.sql
+-- Migration to archive products instead of deleting them, and stop product deletes taking sales with them
+ALTER TABLE "products"
+    ADD COLUMN IF NOT EXISTS "is_archived" BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS "idx_products_is_archived" ON "products" ("is_archived");
+
+ALTER TABLE "sales" DROP CONSTRAINT IF EXISTS "sales_product_id_fkey";
+ALTER TABLE "sales" ADD CONSTRAINT "sales_product_id_fkey" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE RESTRICT;
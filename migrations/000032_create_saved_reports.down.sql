@@ -0,0 +1,3 @@
+-- File: migrations/000032_create_saved_reports.down.sql
+-- Migration to drop saved report definitions
+DROP TABLE IF EXISTS "saved_reports";
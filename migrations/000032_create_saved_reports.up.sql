@@ -0,0 +1,11 @@
+-- File: migrations/000032_create_saved_reports.up.sql
+-- Migration to let users save custom report definitions and run them again later
+CREATE TABLE IF NOT EXISTS "saved_reports" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "name" TEXT NOT NULL,
+    "definition" JSONB NOT NULL,
+    "created_by" BIGINT NOT NULL REFERENCES "users"("id") ON DELETE CASCADE,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE ("created_by", "name")
+);
@@ -0,0 +1,7 @@
+-- File: migrations/000033_add_currencies.down.sql
+-- Migration to drop currencies, exchange rates and their permission
+DROP TABLE IF EXISTS "exchange_rates";
+DELETE FROM "permissions" WHERE code = 'rates:update';
+
+ALTER TABLE "products"
+    DROP COLUMN IF EXISTS "currency";
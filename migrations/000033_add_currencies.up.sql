@@ -0,0 +1,28 @@
+-- File: migrations/000033_add_currencies.up.sql
+-- Migration to price products in a currency, store exchange rates against the base currency
+-- and add the permission for maintaining them. Existing products are priced in USD.
+ALTER TABLE "products"
+    ADD COLUMN IF NOT EXISTS "currency" CHAR(3) NOT NULL DEFAULT 'USD' CHECK ("currency" ~ '^[A-Z]{3}$');
+
+CREATE TABLE IF NOT EXISTS "exchange_rates" (
+    "currency" CHAR(3) PRIMARY KEY CHECK ("currency" ~ '^[A-Z]{3}$'),
+    "rate" NUMERIC(18, 6) NOT NULL CHECK ("rate" > 0),
+    "updated_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO "permissions" (code) VALUES ('rates:update') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code = 'rates:update'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code = 'rates:update'
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
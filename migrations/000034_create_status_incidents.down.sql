@@ -0,0 +1,4 @@
+-- File: migrations/000034_create_status_incidents.down.sql
+-- Migration to drop status incidents and their permission
+DROP TABLE IF EXISTS "status_incidents";
+DELETE FROM "permissions" WHERE code = 'incidents:manage';
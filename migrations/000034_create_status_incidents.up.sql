@@ -0,0 +1,32 @@
+-- File: migrations/000034_create_status_incidents.up.sql
+-- Migration to store the incident notes shown on the public status endpoint and the
+-- permission for managing them
+CREATE TABLE IF NOT EXISTS "status_incidents" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "title" TEXT NOT NULL,
+    "message" TEXT NOT NULL DEFAULT '',
+    "impact" TEXT NOT NULL CHECK ("impact" IN ('none', 'minor', 'major', 'critical')),
+    "status" TEXT NOT NULL CHECK ("status" IN ('investigating', 'identified', 'monitoring', 'resolved')),
+    "created_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "resolved_at" TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS "idx_status_incidents_resolved_at" ON "status_incidents" ("resolved_at");
+
+INSERT INTO "permissions" (code) VALUES ('incidents:manage') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code = 'incidents:manage'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code = 'incidents:manage'
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,6 @@
+-- File: migrations/000035_add_products_tags.down.sql
+-- Rollback migration for product tags
+DROP INDEX IF EXISTS "idx_products_tags";
+
+ALTER TABLE "products"
+    DROP COLUMN IF EXISTS "tags";
@@ -0,0 +1,6 @@
+-- File: migrations/000035_add_products_tags.up.sql
+-- Migration to tag products for groupings such as promotions
+ALTER TABLE "products"
+    ADD COLUMN IF NOT EXISTS "tags" TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS "idx_products_tags" ON "products" USING GIN ("tags");
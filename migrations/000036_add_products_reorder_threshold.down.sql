@@ -0,0 +1,7 @@
+-- File: migrations/000036_add_products_reorder_threshold.down.sql
+-- Rollback migration for product reorder thresholds
+DROP INDEX IF EXISTS "idx_products_low_stock";
+
+ALTER TABLE "products"
+    DROP COLUMN IF EXISTS "low_stock_notified_at",
+    DROP COLUMN IF EXISTS "reorder_threshold";
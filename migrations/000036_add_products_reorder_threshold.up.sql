@@ -0,0 +1,10 @@
+-- File: migrations/000036_add_products_reorder_threshold.up.sql
+-- Migration to alert on tracked products whose stock falls below their reorder threshold.
+-- A threshold of 0 never alerts. low_stock_notified_at is set when an alert goes out and cleared
+-- once the stock is back at or above the threshold, so each shortage is only reported once.
+ALTER TABLE "products"
+    ADD COLUMN IF NOT EXISTS "reorder_threshold" BIGINT NOT NULL DEFAULT 0 CHECK ("reorder_threshold" >= 0),
+    ADD COLUMN IF NOT EXISTS "low_stock_notified_at" TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS "idx_products_low_stock" ON "products" ("id")
+    WHERE "stock_quantity" < "reorder_threshold";
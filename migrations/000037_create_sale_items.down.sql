@@ -0,0 +1,22 @@
+-- File: migrations/000037_create_sale_items.down.sql
+-- Rollback migration for sale items. A sale with several items keeps only its first one.
+ALTER TABLE "sales"
+    ADD COLUMN IF NOT EXISTS "product_id" BIGINT REFERENCES "products"("id") ON DELETE RESTRICT,
+    ADD COLUMN IF NOT EXISTS "quantity" INT;
+
+UPDATE "sales" s
+SET "product_id" = i."product_id", "quantity" = i."quantity"
+FROM (
+    SELECT DISTINCT ON ("sale_id") "sale_id", "product_id", "quantity"
+    FROM "sale_items"
+    ORDER BY "sale_id", "id"
+) i
+WHERE i."sale_id" = s."id";
+
+DELETE FROM "sales" WHERE "product_id" IS NULL;
+
+ALTER TABLE "sales"
+    ALTER COLUMN "product_id" SET NOT NULL,
+    ALTER COLUMN "quantity" SET NOT NULL;
+
+DROP TABLE IF EXISTS "sale_items";
@@ -0,0 +1,20 @@
+-- File: migrations/000037_create_sale_items.up.sql
+-- Migration to let one sale hold several products: the line items move to a child table and the
+-- sales row keeps the header. Every existing sale becomes a sale with one item.
+CREATE TABLE IF NOT EXISTS "sale_items" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "sale_id" BIGINT NOT NULL REFERENCES "sales"("id") ON DELETE CASCADE,
+    "product_id" BIGINT NOT NULL REFERENCES "products"("id") ON DELETE RESTRICT,
+    "quantity" BIGINT NOT NULL CHECK ("quantity" > 0),
+    UNIQUE ("sale_id", "product_id")
+);
+
+CREATE INDEX IF NOT EXISTS "idx_sale_items_product_id" ON "sale_items" ("product_id");
+
+INSERT INTO "sale_items" ("sale_id", "product_id", "quantity")
+SELECT "id", "product_id", "quantity" FROM "sales"
+ORDER BY "id";
+
+ALTER TABLE "sales"
+    DROP COLUMN IF EXISTS "product_id",
+    DROP COLUMN IF EXISTS "quantity";
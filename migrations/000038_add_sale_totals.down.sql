@@ -0,0 +1,10 @@
+-- File: migrations/000038_add_sale_totals.down.sql
+-- Rollback migration for sale totals. Reports go back to pricing sales at current product prices.
+ALTER TABLE "sales"
+    DROP COLUMN IF EXISTS "total_amount",
+    DROP COLUMN IF EXISTS "currency";
+
+ALTER TABLE "sale_items"
+    DROP COLUMN IF EXISTS "unit_price",
+    DROP COLUMN IF EXISTS "currency",
+    DROP COLUMN IF EXISTS "line_total";
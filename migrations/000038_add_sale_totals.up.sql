@@ -0,0 +1,37 @@
+-- File: migrations/000038_add_sale_totals.up.sql
+-- Migration to keep the price of every sale item as it was when the item was sold, with its line
+-- total and the sale's total, so editing a product's price no longer rewrites past sales. Items sold
+-- before prices were kept are priced at their product's current price, the only record left.
+ALTER TABLE "sale_items"
+    ADD COLUMN IF NOT EXISTS "unit_price" NUMERIC(10, 2),
+    ADD COLUMN IF NOT EXISTS "currency" CHAR(3),
+    ADD COLUMN IF NOT EXISTS "line_total" NUMERIC(14, 2);
+
+UPDATE "sale_items" i
+SET "unit_price" = p."price", "currency" = p."currency", "line_total" = ROUND(i."quantity" * p."price", 2)
+FROM "products" p
+WHERE p."id" = i."product_id";
+
+ALTER TABLE "sale_items"
+    ALTER COLUMN "unit_price" SET NOT NULL,
+    ALTER COLUMN "currency" SET NOT NULL,
+    ALTER COLUMN "line_total" SET NOT NULL,
+    ADD CONSTRAINT "sale_items_unit_price_check" CHECK ("unit_price" >= 0),
+    ADD CONSTRAINT "sale_items_currency_check" CHECK ("currency" ~ '^[A-Z]{3}$');
+
+ALTER TABLE "sales"
+    ADD COLUMN IF NOT EXISTS "total_amount" NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS "currency" CHAR(3) NOT NULL DEFAULT 'USD' CHECK ("currency" ~ '^[A-Z]{3}$');
+
+UPDATE "sales" s
+SET "total_amount" = t."total", "currency" = t."currency"
+FROM (
+    SELECT "sale_id", SUM("line_total") AS "total", MIN("currency") AS "currency"
+    FROM "sale_items"
+    GROUP BY "sale_id"
+) t
+WHERE t."sale_id" = s."id";
+
+ALTER TABLE "sales"
+    ALTER COLUMN "total_amount" DROP DEFAULT,
+    ALTER COLUMN "currency" DROP DEFAULT;
@@ -0,0 +1,5 @@
+-- File: migrations/000039_create_business_calendar.down.sql
+-- Migration to drop the business calendar and its permission
+DROP TABLE IF EXISTS "business_holidays";
+DROP TABLE IF EXISTS "business_hours";
+DELETE FROM "permissions" WHERE code = 'calendar:manage';
@@ -0,0 +1,42 @@
+-- File: migrations/000039_create_business_calendar.up.sql
+-- Migration to keep the business calendar: the opening hours of each weekday and the holidays the
+-- business is closed on, with the permission for maintaining them. Every weekday starts open all day.
+CREATE TABLE IF NOT EXISTS "business_hours" (
+    "weekday" SMALLINT PRIMARY KEY CHECK ("weekday" BETWEEN 0 AND 6), -- 0 is Monday
+    "closed" BOOLEAN NOT NULL DEFAULT FALSE,
+    "opens" TIME,
+    "closes" TIME,
+    "updated_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (("opens" IS NULL) = ("closes" IS NULL)),
+    CHECK ("opens" < "closes")
+);
+
+INSERT INTO "business_hours" ("weekday")
+SELECT generate_series(0, 6)
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS "business_holidays" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "date" DATE NOT NULL UNIQUE,
+    "name" TEXT NOT NULL,
+    "created_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO "permissions" (code) VALUES ('calendar:manage') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code = 'calendar:manage'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code = 'calendar:manage'
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+-- File: migrations/000040_create_payments.down.sql
+-- Rollback migration for sale payments
+DROP TABLE IF EXISTS "payments";
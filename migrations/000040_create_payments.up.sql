@@ -0,0 +1,16 @@
+-- File: migrations/000040_create_payments.up.sql
+-- Migration to record how sales were paid: one row per tender, so a sale can be split across cash,
+-- card and transfer. Cash payments may keep the amount tendered, from which the change is worked out.
+CREATE TABLE IF NOT EXISTS "payments" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "sale_id" BIGINT NOT NULL REFERENCES "sales"("id") ON DELETE CASCADE,
+    "method" TEXT NOT NULL CHECK ("method" IN ('cash', 'card', 'transfer')),
+    "amount" NUMERIC(14, 2) NOT NULL CHECK ("amount" > 0),
+    "tendered" NUMERIC(14, 2) CHECK ("tendered" >= "amount"),
+    "reference" TEXT NOT NULL DEFAULT '',
+    "recorded_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ("tendered" IS NULL OR "method" = 'cash')
+);
+
+CREATE INDEX IF NOT EXISTS "idx_payments_sale_id" ON "payments" ("sale_id");
@@ -0,0 +1,4 @@
+-- File: migrations/000041_create_schedules.down.sql
+-- Migration to drop the job schedules and their permission
+DROP TABLE IF EXISTS "schedules";
+DELETE FROM "permissions" WHERE code = 'schedules:manage';
@@ -0,0 +1,42 @@
+-- File: migrations/000041_create_schedules.up.sql
+-- Migration to keep the schedules of background jobs, with cron expressions, the outcome of their
+-- last run and when they run next, and the permission for managing them. Expired tokens and bulk
+-- confirmations are cleaned up nightly from the start.
+CREATE TABLE IF NOT EXISTS "schedules" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "name" TEXT NOT NULL UNIQUE,
+    "job" TEXT NOT NULL,
+    "cron" TEXT NOT NULL,
+    "timezone" TEXT NOT NULL DEFAULT 'UTC',
+    "enabled" BOOLEAN NOT NULL DEFAULT TRUE,
+    "next_run_at" TIMESTAMPTZ,
+    "last_run_at" TIMESTAMPTZ,
+    "last_status" TEXT CHECK ("last_status" IN ('running', 'succeeded', 'failed')),
+    "last_error" TEXT NOT NULL DEFAULT '',
+    "last_duration_ms" BIGINT,
+    "created_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "updated_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS "schedules_next_run_at_idx" ON "schedules" ("next_run_at") WHERE "enabled";
+
+INSERT INTO "schedules" ("name", "job", "cron", "next_run_at")
+VALUES ('Nightly cleanup', 'cleanup', '0 3 * * *', date_trunc('day', NOW() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' + INTERVAL '1 day 3 hours')
+ON CONFLICT ("name") DO NOTHING;
+
+INSERT INTO "permissions" (code) VALUES ('schedules:manage') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code = 'schedules:manage'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code = 'schedules:manage'
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
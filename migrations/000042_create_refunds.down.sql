@@ -0,0 +1,6 @@
+-- File: migrations/000042_create_refunds.down.sql
+-- Migration to drop refunds, the revenue lines view and the refund permission
+DROP VIEW IF EXISTS "revenue_lines";
+DROP TABLE IF EXISTS "refund_items";
+DROP TABLE IF EXISTS "refunds";
+DELETE FROM "permissions" WHERE code = 'sale:refund';
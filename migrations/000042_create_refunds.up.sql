@@ -0,0 +1,54 @@
+-- File: migrations/000042_create_refunds.up.sql
+-- Migration to record refunds of sales, whole or per item, at the prices the items were sold at, and
+-- the revenue_lines view reports read: every sale item at its sale time, and every refunded item
+-- as a negative line at its refund time. Refunding is granted to admins.
+CREATE TABLE IF NOT EXISTS "refunds" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "sale_id" BIGINT NOT NULL REFERENCES "sales"("id") ON DELETE CASCADE,
+    "reason" TEXT NOT NULL DEFAULT '',
+    "total_amount" NUMERIC(14, 2) NOT NULL CHECK ("total_amount" >= 0),
+    "currency" CHAR(3) NOT NULL CHECK ("currency" ~ '^[A-Z]{3}$'),
+    "created_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "refunded_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "idx_refunds_sale_id" ON "refunds" ("sale_id");
+CREATE INDEX IF NOT EXISTS "idx_refunds_refunded_at" ON "refunds" ("refunded_at");
+
+CREATE TABLE IF NOT EXISTS "refund_items" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "refund_id" BIGINT NOT NULL REFERENCES "refunds"("id") ON DELETE CASCADE,
+    "product_id" BIGINT NOT NULL REFERENCES "products"("id") ON DELETE RESTRICT,
+    "quantity" BIGINT NOT NULL CHECK ("quantity" > 0),
+    "unit_price" NUMERIC(10, 2) NOT NULL CHECK ("unit_price" >= 0),
+    "currency" CHAR(3) NOT NULL CHECK ("currency" ~ '^[A-Z]{3}$'),
+    "line_total" NUMERIC(14, 2) NOT NULL,
+    UNIQUE ("refund_id", "product_id")
+);
+
+CREATE OR REPLACE VIEW "revenue_lines" AS
+SELECT i."sale_id", NULL::bigint AS "refund_id", i."product_id", i."quantity", i."currency", i."line_total",
+       s."sold_at" AS "occurred_at"
+FROM "sale_items" i
+JOIN "sales" s ON s."id" = i."sale_id"
+UNION ALL
+SELECT r."sale_id", r."id", ri."product_id", -ri."quantity", ri."currency", -ri."line_total",
+       r."refunded_at"
+FROM "refund_items" ri
+JOIN "refunds" r ON r."id" = ri."refund_id";
+
+INSERT INTO "permissions" (code) VALUES ('sale:refund') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code = 'sale:refund'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code = 'sale:refund'
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,21 @@
+-- File: migrations/000043_create_discounts.down.sql
+-- Migration to drop discounts, the discount columns of sales and the discount permission, counting
+-- revenue before discounts again
+CREATE OR REPLACE VIEW "revenue_lines" AS
+SELECT i."sale_id", NULL::bigint AS "refund_id", i."product_id", i."quantity", i."currency", i."line_total",
+       s."sold_at" AS "occurred_at"
+FROM "sale_items" i
+JOIN "sales" s ON s."id" = i."sale_id"
+UNION ALL
+SELECT r."sale_id", r."id", ri."product_id", -ri."quantity", ri."currency", -ri."line_total",
+       r."refunded_at"
+FROM "refund_items" ri
+JOIN "refunds" r ON r."id" = ri."refund_id";
+
+ALTER TABLE "sales" DROP COLUMN IF EXISTS "discount_code";
+ALTER TABLE "sales" DROP COLUMN IF EXISTS "discount_id";
+ALTER TABLE "sales" DROP COLUMN IF EXISTS "discount_amount";
+ALTER TABLE "sales" DROP COLUMN IF EXISTS "subtotal_amount";
+ALTER TABLE "sale_items" DROP COLUMN IF EXISTS "discount_amount";
+DROP TABLE IF EXISTS "discounts";
+DELETE FROM "permissions" WHERE code = 'discounts:manage';
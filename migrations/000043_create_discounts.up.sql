@@ -0,0 +1,59 @@
+-- File: migrations/000043_create_discounts.up.sql
+-- Migration to keep discount codes, percentage or fixed amounts off products of a tag or a list,
+-- valid for a window of time, and the permission for managing them. Sales remember the code they
+-- were sold with, and each item its share of the discount, so revenue is counted after discounts.
+CREATE TABLE IF NOT EXISTS "discounts" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "code" TEXT NOT NULL UNIQUE CHECK ("code" = upper("code")),
+    "description" TEXT NOT NULL DEFAULT '',
+    "kind" TEXT NOT NULL CHECK ("kind" IN ('percentage', 'fixed')),
+    "value" NUMERIC(10, 2) NOT NULL CHECK ("value" > 0),
+    "currency" CHAR(3) CHECK ("currency" ~ '^[A-Z]{3}$'),
+    "tag" TEXT NOT NULL DEFAULT '',
+    "product_ids" BIGINT[] NOT NULL DEFAULT '{}',
+    "starts_at" TIMESTAMPTZ,
+    "ends_at" TIMESTAMPTZ,
+    "active" BOOLEAN NOT NULL DEFAULT TRUE,
+    "created_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "updated_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ("kind" <> 'percentage' OR "value" <= 100),
+    CHECK (("kind" = 'fixed') = ("currency" IS NOT NULL)),
+    CHECK ("starts_at" IS NULL OR "ends_at" IS NULL OR "starts_at" < "ends_at")
+);
+
+ALTER TABLE "sale_items" ADD COLUMN IF NOT EXISTS "discount_amount" NUMERIC(14, 2) NOT NULL DEFAULT 0;
+
+ALTER TABLE "sales" ADD COLUMN IF NOT EXISTS "subtotal_amount" NUMERIC(14, 2) NOT NULL DEFAULT 0;
+ALTER TABLE "sales" ADD COLUMN IF NOT EXISTS "discount_amount" NUMERIC(14, 2) NOT NULL DEFAULT 0;
+ALTER TABLE "sales" ADD COLUMN IF NOT EXISTS "discount_id" BIGINT REFERENCES "discounts"("id") ON DELETE SET NULL;
+ALTER TABLE "sales" ADD COLUMN IF NOT EXISTS "discount_code" TEXT;
+UPDATE "sales" SET "subtotal_amount" = "total_amount";
+
+CREATE OR REPLACE VIEW "revenue_lines" AS
+SELECT i."sale_id", NULL::bigint AS "refund_id", i."product_id", i."quantity", i."currency",
+       i."line_total" - i."discount_amount" AS "line_total", s."sold_at" AS "occurred_at"
+FROM "sale_items" i
+JOIN "sales" s ON s."id" = i."sale_id"
+UNION ALL
+SELECT r."sale_id", r."id", ri."product_id", -ri."quantity", ri."currency", -ri."line_total",
+       r."refunded_at"
+FROM "refund_items" ri
+JOIN "refunds" r ON r."id" = ri."refund_id";
+
+INSERT INTO "permissions" (code) VALUES ('discounts:manage') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code = 'discounts:manage'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code = 'discounts:manage'
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,6 @@
+-- File: migrations/000044_create_sale_attachments.down.sql
+-- Migration to drop sale notes and attachments. Files already stored are left in the file store.
+DROP TABLE IF EXISTS "sale_attachments";
+DROP FUNCTION IF EXISTS "queue_sale_attachment_deletion"();
+DROP TABLE IF EXISTS "storage_deletions";
+ALTER TABLE "sales" DROP COLUMN IF EXISTS "note";
@@ -0,0 +1,34 @@
+-- File: migrations/000044_create_sale_attachments.up.sql
+-- Migration to give sales a free text note and file attachments. The files themselves live in the
+-- file store; when an attachment row goes, with its sale or on its own, its file is queued in
+-- storage_deletions for the cleanup job to remove.
+ALTER TABLE "sales" ADD COLUMN IF NOT EXISTS "note" TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS "sale_attachments" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "sale_id" BIGINT NOT NULL REFERENCES "sales"("id") ON DELETE CASCADE,
+    "filename" TEXT NOT NULL,
+    "content_type" TEXT NOT NULL,
+    "size_bytes" BIGINT NOT NULL CHECK ("size_bytes" >= 0),
+    "storage_key" TEXT NOT NULL UNIQUE,
+    "created_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS "idx_sale_attachments_sale_id" ON "sale_attachments" ("sale_id");
+
+CREATE TABLE IF NOT EXISTS "storage_deletions" (
+    "storage_key" TEXT PRIMARY KEY,
+    "deleted_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE OR REPLACE FUNCTION "queue_sale_attachment_deletion"() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO "storage_deletions" ("storage_key") VALUES (OLD."storage_key") ON CONFLICT DO NOTHING;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS "sale_attachments_queue_deletion" ON "sale_attachments";
+CREATE TRIGGER "sale_attachments_queue_deletion"
+AFTER DELETE ON "sale_attachments"
+FOR EACH ROW EXECUTE FUNCTION "queue_sale_attachment_deletion"();
@@ -0,0 +1,4 @@
+-- File: migrations/000045_create_receipt_preferences.down.sql
+-- Migration to drop receipt preferences and the customer email of sales
+DROP TABLE IF EXISTS "receipt_preferences";
+ALTER TABLE "sales" DROP COLUMN IF EXISTS "customer_email";
@@ -0,0 +1,11 @@
+-- File: migrations/000045_create_receipt_preferences.up.sql
+-- Migration to record the customer a sale's receipt is emailed to, and whether each customer address
+-- has opted in to email receipts. Addresses without a row have not opted in.
+ALTER TABLE "sales" ADD COLUMN IF NOT EXISTS "customer_email" TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS "receipt_preferences" (
+    "email" TEXT PRIMARY KEY,
+    "email_receipts" BOOLEAN NOT NULL,
+    "updated_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,20 @@
+-- File: migrations/000046_create_tax_rates.down.sql
+-- Migration to drop tax rates, the tax columns of sales and refunds and the tax permission
+DROP VIEW IF EXISTS "revenue_lines";
+CREATE VIEW "revenue_lines" AS
+SELECT i."sale_id", NULL::bigint AS "refund_id", i."product_id", i."quantity", i."currency",
+       i."line_total" - i."discount_amount" AS "line_total", s."sold_at" AS "occurred_at"
+FROM "sale_items" i
+JOIN "sales" s ON s."id" = i."sale_id"
+UNION ALL
+SELECT r."sale_id", r."id", ri."product_id", -ri."quantity", ri."currency", -ri."line_total",
+       r."refunded_at"
+FROM "refund_items" ri
+JOIN "refunds" r ON r."id" = ri."refund_id";
+
+ALTER TABLE "refunds" DROP COLUMN IF EXISTS "tax_amount";
+ALTER TABLE "refund_items" DROP COLUMN IF EXISTS "tax_amount";
+ALTER TABLE "sales" DROP COLUMN IF EXISTS "tax_amount";
+ALTER TABLE "sale_items" DROP COLUMN IF EXISTS "tax_amount";
+DROP TABLE IF EXISTS "tax_rates";
+DELETE FROM "permissions" WHERE code = 'taxes:manage';
@@ -0,0 +1,48 @@
+-- File: migrations/000046_create_tax_rates.up.sql
+-- Migration to keep tax rates, one for every product (the empty tag) and any number for products of
+-- a tag, and the permission for managing them. Tax is added on top of what each item sells for after
+-- discounts and kept on the item, so later rate changes leave past sales alone. Refunds give back
+-- their share of it. Revenue stays net of tax, with the tax of each line beside it.
+CREATE TABLE IF NOT EXISTS "tax_rates" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "name" TEXT NOT NULL,
+    "rate" NUMERIC(6, 3) NOT NULL CHECK ("rate" >= 0 AND "rate" <= 100),
+    "tag" TEXT NOT NULL DEFAULT '' UNIQUE,
+    "created_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "updated_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE "sale_items" ADD COLUMN IF NOT EXISTS "tax_amount" NUMERIC(14, 2) NOT NULL DEFAULT 0;
+ALTER TABLE "sales" ADD COLUMN IF NOT EXISTS "tax_amount" NUMERIC(14, 2) NOT NULL DEFAULT 0;
+ALTER TABLE "refund_items" ADD COLUMN IF NOT EXISTS "tax_amount" NUMERIC(14, 2) NOT NULL DEFAULT 0;
+ALTER TABLE "refunds" ADD COLUMN IF NOT EXISTS "tax_amount" NUMERIC(14, 2) NOT NULL DEFAULT 0;
+
+CREATE OR REPLACE VIEW "revenue_lines" AS
+SELECT i."sale_id", NULL::bigint AS "refund_id", i."product_id", i."quantity", i."currency",
+       i."line_total" - i."discount_amount" AS "line_total", s."sold_at" AS "occurred_at",
+       i."tax_amount"
+FROM "sale_items" i
+JOIN "sales" s ON s."id" = i."sale_id"
+UNION ALL
+SELECT r."sale_id", r."id", ri."product_id", -ri."quantity", ri."currency", -(ri."line_total" - ri."tax_amount"),
+       r."refunded_at", -ri."tax_amount"
+FROM "refund_items" ri
+JOIN "refunds" r ON r."id" = ri."refund_id";
+
+INSERT INTO "permissions" (code) VALUES ('taxes:manage') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code = 'taxes:manage'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code = 'taxes:manage'
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,5 @@
+-- File: migrations/000047_create_customers.down.sql
+-- Migration to drop customers, the customer of sales and the customer permissions
+ALTER TABLE "sales" DROP COLUMN IF EXISTS "customer_id";
+DROP TABLE IF EXISTS "customers";
+DELETE FROM "permissions" WHERE code LIKE 'customer:%';
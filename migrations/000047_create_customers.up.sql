@@ -0,0 +1,43 @@
+-- File: migrations/000047_create_customers.up.sql
+-- Migration to create the customers sales can be attributed to. Email and phone are optional, and an
+-- email belongs to at most one customer whatever its case.
+CREATE TABLE IF NOT EXISTS "customers" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "name" TEXT NOT NULL,
+    "email" TEXT NOT NULL DEFAULT '',
+    "phone" TEXT NOT NULL DEFAULT '',
+    "created_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "updated_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "customers_email_idx" ON "customers" (LOWER("email")) WHERE "email" <> '';
+
+-- Sales keep their history when their customer is deleted
+ALTER TABLE "sales" ADD COLUMN IF NOT EXISTS "customer_id" BIGINT REFERENCES "customers"("id") ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS "sales_customer_id_idx" ON "sales" ("customer_id");
+
+INSERT INTO "permissions" (code) VALUES
+('customer:create'),
+('customer:view'),
+('customer:update'),
+('customer:delete')
+ON CONFLICT (code) DO NOTHING;
+
+-- Cashiers look customers up and add them at the till; only admins delete them
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON
+    (r.name = 'admin' AND p.code LIKE 'customer:%')
+    OR (r.name = 'cashier' AND p.code IN ('customer:create', 'customer:view', 'customer:update'))
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON
+    (u.role = 'admin' AND p.code LIKE 'customer:%')
+    OR (u.role = 'cashier' AND p.code IN ('customer:create', 'customer:view', 'customer:update'))
+ON CONFLICT DO NOTHING;
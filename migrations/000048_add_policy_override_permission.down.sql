@@ -0,0 +1,3 @@
+-- File: migrations/000048_add_policy_override_permission.down.sql
+-- Migration to drop the returns policy override permission
+DELETE FROM "permissions" WHERE code = 'policy:override';
@@ -0,0 +1,17 @@
+-- File: migrations/000048_add_policy_override_permission.up.sql
+-- Migration to add the permission to refund sales outside the returns window, granted to admins
+INSERT INTO "permissions" (code) VALUES ('policy:override') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code = 'policy:override'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code = 'policy:override'
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,4 @@
+-- File: migrations/000049_create_sale_duplicates.down.sql
+-- Migration to drop the duplicate sale flags
+DROP INDEX IF EXISTS "sales_user_id_sold_at_idx";
+DROP TABLE IF EXISTS "sale_duplicates";
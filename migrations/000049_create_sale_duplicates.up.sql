@@ -0,0 +1,13 @@
+-- File: migrations/000049_create_sale_duplicates.up.sql
+-- Migration to record sales flagged as likely duplicates of another, such as an item scanned twice.
+-- Flags are only a warning; both sales stay recorded.
+CREATE TABLE IF NOT EXISTS "sale_duplicates" (
+    "sale_id" BIGINT PRIMARY KEY REFERENCES "sales"("id") ON DELETE CASCADE,
+    "duplicate_of" BIGINT NOT NULL REFERENCES "sales"("id") ON DELETE CASCADE,
+    "flagged_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "sale_duplicates_duplicate_of_idx" ON "sale_duplicates" ("duplicate_of");
+
+-- New sales are compared with the user's sales around the same time
+CREATE INDEX IF NOT EXISTS "sales_user_id_sold_at_idx" ON "sales" ("user_id", "sold_at");
@@ -0,0 +1,22 @@
+-- File: migrations/000050_add_sale_status.down.sql
+-- Migration to drop the status of sales and the void permission. Drafts and voided sales are
+-- archived, since without a status they would count as completed sales.
+CREATE OR REPLACE VIEW "revenue_lines" AS
+SELECT i."sale_id", NULL::bigint AS "refund_id", i."product_id", i."quantity", i."currency",
+       i."line_total" - i."discount_amount" AS "line_total", s."sold_at" AS "occurred_at",
+       i."tax_amount"
+FROM "sale_items" i
+JOIN "sales" s ON s."id" = i."sale_id"
+UNION ALL
+SELECT r."sale_id", r."id", ri."product_id", -ri."quantity", ri."currency", -(ri."line_total" - ri."tax_amount"),
+       r."refunded_at", -ri."tax_amount"
+FROM "refund_items" ri
+JOIN "refunds" r ON r."id" = ri."refund_id";
+
+UPDATE "sales" SET "archived_at" = COALESCE("archived_at", NOW()) WHERE "status" <> 'completed';
+DROP INDEX IF EXISTS "sales_status_idx";
+ALTER TABLE "sales"
+    DROP COLUMN IF EXISTS "voided_by",
+    DROP COLUMN IF EXISTS "voided_at",
+    DROP COLUMN IF EXISTS "status";
+DELETE FROM "permissions" WHERE code = 'sale:void';
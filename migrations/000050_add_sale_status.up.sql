@@ -0,0 +1,38 @@
+-- File: migrations/000050_add_sale_status.up.sql
+-- Migration to give sales a status: drafts are parked carts that have not taken stock yet, and
+-- voided sales are mistakes kept for the audit trail. Only completed sales count as revenue.
+ALTER TABLE "sales"
+    ADD COLUMN IF NOT EXISTS "status" TEXT NOT NULL DEFAULT 'completed' CHECK ("status" IN ('draft', 'completed', 'voided')),
+    ADD COLUMN IF NOT EXISTS "voided_at" TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS "voided_by" BIGINT REFERENCES "users"("id") ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS "sales_status_idx" ON "sales" ("status") WHERE "status" <> 'completed';
+
+CREATE OR REPLACE VIEW "revenue_lines" AS
+SELECT i."sale_id", NULL::bigint AS "refund_id", i."product_id", i."quantity", i."currency",
+       i."line_total" - i."discount_amount" AS "line_total", s."sold_at" AS "occurred_at",
+       i."tax_amount"
+FROM "sale_items" i
+JOIN "sales" s ON s."id" = i."sale_id"
+WHERE s."status" = 'completed'
+UNION ALL
+SELECT r."sale_id", r."id", ri."product_id", -ri."quantity", ri."currency", -(ri."line_total" - ri."tax_amount"),
+       r."refunded_at", -ri."tax_amount"
+FROM "refund_items" ri
+JOIN "refunds" r ON r."id" = ri."refund_id";
+
+INSERT INTO "permissions" (code) VALUES ('sale:void') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code = 'sale:void'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code = 'sale:void'
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
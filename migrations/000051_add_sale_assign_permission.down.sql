@@ -0,0 +1,3 @@
+-- File: migrations/000051_add_sale_assign_permission.down.sql
+-- Migration to drop the permission to record sales on behalf of other users
+DELETE FROM "permissions" WHERE code = 'sale:assign';
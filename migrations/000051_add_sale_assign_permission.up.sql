@@ -0,0 +1,17 @@
+-- File: migrations/000051_add_sale_assign_permission.up.sql
+-- Migration to add the permission to record sales on behalf of other users, granted to admins
+INSERT INTO "permissions" (code) VALUES ('sale:assign') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code = 'sale:assign'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code = 'sale:assign'
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+-- File: migrations/000052_add_security_manage_permission.down.sql
+-- Migration to drop the security incident response permission
+DELETE FROM "permissions" WHERE code = 'security:manage';
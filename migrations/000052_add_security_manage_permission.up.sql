@@ -0,0 +1,17 @@
+-- File: migrations/000052_add_security_manage_permission.up.sql
+-- Migration to add the permission to respond to suspected breaches, such as by signing users out in bulk, granted to admins
+INSERT INTO "permissions" (code) VALUES ('security:manage') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code = 'security:manage'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code = 'security:manage'
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
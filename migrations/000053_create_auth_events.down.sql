@@ -0,0 +1,3 @@
+-- File: migrations/000053_create_auth_events.down.sql
+-- Migration to drop the authentication outcome log
+DROP TABLE IF EXISTS "auth_events";
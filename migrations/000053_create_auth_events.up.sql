@@ -0,0 +1,12 @@
+-- File: migrations/000053_create_auth_events.up.sql
+-- Migration to record the outcome of logins, registrations, activations and password resets, so
+-- admins can watch for credential stuffing. Events older than 90 days are removed by the cleanup job.
+CREATE TABLE IF NOT EXISTS "auth_events" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "event" TEXT NOT NULL,
+    "user_id" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "ip" TEXT NOT NULL DEFAULT '',
+    "occurred_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "auth_events_occurred_at_idx" ON "auth_events" ("occurred_at");
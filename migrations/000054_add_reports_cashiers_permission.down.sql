@@ -0,0 +1,3 @@
+-- File: migrations/000054_add_reports_cashiers_permission.down.sql
+-- Migration to remove the cashier performance report permission
+DELETE FROM "permissions" WHERE code = 'reports:cashiers';
@@ -0,0 +1,17 @@
+-- File: migrations/000054_add_reports_cashiers_permission.up.sql
+-- Migration to add the permission to compare the sales performance of individual users, granted to admins
+INSERT INTO "permissions" (code) VALUES ('reports:cashiers') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code = 'reports:cashiers'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code = 'reports:cashiers'
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
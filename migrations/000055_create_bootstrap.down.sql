@@ -0,0 +1,3 @@
+-- File: migrations/000055_create_bootstrap.down.sql
+-- Migration to drop the bootstrap record
+DROP TABLE IF EXISTS "bootstrap";
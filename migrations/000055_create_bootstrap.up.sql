@@ -0,0 +1,13 @@
+-- File: migrations/000055_create_bootstrap.up.sql
+-- Migration to record when the first admin was created through the bootstrap endpoint. The table
+-- holds at most one row; installs that already have users are marked as bootstrapped.
+CREATE TABLE IF NOT EXISTS "bootstrap" (
+    "id" BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK ("id"),
+    "user_id" BIGINT REFERENCES "users"("id") ON DELETE SET NULL,
+    "completed_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO "bootstrap" (id, user_id)
+SELECT TRUE, MIN(id) FROM "users"
+HAVING COUNT(*) > 0
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+-- File: migrations/000056_add_metrics_view_permission.down.sql
+-- Migration to remove the metrics permission
+DELETE FROM "permissions" WHERE code = 'metrics:view';
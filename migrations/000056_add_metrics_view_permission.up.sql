@@ -0,0 +1,17 @@
+-- File: migrations/000056_add_metrics_view_permission.up.sql
+-- Migration to add the permission to read the operational metrics, granted to admins
+INSERT INTO "permissions" (code) VALUES ('metrics:view') ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO "role_permissions" (role_id, permission_id)
+SELECT r.id, p.id
+FROM "roles" r
+INNER JOIN "permissions" p ON p.code = 'metrics:view'
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users_permissions" (user_id, permission_id)
+SELECT u.id, p.id
+FROM "users" u
+INNER JOIN "permissions" p ON p.code = 'metrics:view'
+WHERE u.role = 'admin'
+ON CONFLICT DO NOTHING;
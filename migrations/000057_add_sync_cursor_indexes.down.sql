@@ -0,0 +1,7 @@
+-- File: migrations/000057_add_sync_cursor_indexes.down.sql
+-- Migration to go back to the single column sync indexes
+CREATE INDEX IF NOT EXISTS "sales_updated_at_idx" ON "sales" ("updated_at");
+DROP INDEX IF EXISTS "sales_updated_at_id_idx";
+
+CREATE INDEX IF NOT EXISTS "sales_deletions_deleted_at_idx" ON "sales_deletions" ("deleted_at");
+DROP INDEX IF EXISTS "sales_deletions_deleted_at_sale_id_idx";
@@ -0,0 +1,7 @@
+-- File: migrations/000057_add_sync_cursor_indexes.up.sql
+-- Migration to order the sync feeds by time and then ID, matching the keyset cursor
+CREATE INDEX IF NOT EXISTS "sales_updated_at_id_idx" ON "sales" ("updated_at", "id");
+DROP INDEX IF EXISTS "sales_updated_at_idx";
+
+CREATE INDEX IF NOT EXISTS "sales_deletions_deleted_at_sale_id_idx" ON "sales_deletions" ("deleted_at", "sale_id");
+DROP INDEX IF EXISTS "sales_deletions_deleted_at_idx";
@@ -40,7 +40,7 @@ echo ""
 echo "✅ Sales API is ready!"
 echo ""
 echo "📍 API is available at: http://localhost:4000"
-echo "📊 Metrics available at: http://localhost:4000/v1/metrics"
+echo "📊 Metrics available at: http://localhost:4000/v1/metrics (needs a token with metrics:view)"
 echo ""
 echo "📝 Useful commands:"
 echo "  - View logs: make docker/logs"